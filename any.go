@@ -0,0 +1,495 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package binary
+
+import (
+	"errors"
+	"math/big"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+// DecodeAny decodes a payload encoded with the SelfDescribing option into generic values,
+// without the original Go type, similar to decoding JSON into an interface{}. Structs are
+// decoded into map[string]interface{}, maps into map[interface{}]interface{}, arrays and
+// slices into []interface{}, byte slices and byte arrays into []byte, and nil pointers
+// and interfaces into nil. Signed and unsigned integers are decoded into int64 and uint64,
+// floating-point numbers into float64 and complex numbers into complex128, while times
+// and arbitrary precision numbers keep their type.
+func DecodeAny(b []byte, opts ...Option) (v interface{}, err error) {
+	d := decoders.Get().(*Decoder)
+	d.r.(*SliceReader).Reset(b)
+	d.opts.reset(opts)
+	d.depth = 0
+
+	v, err = d.DecodeAny()
+	decoders.Put(d)
+	return
+}
+
+// The size of the generic values, which is charged against the allocation budget
+var anySize = reflect.TypeOf((*interface{})(nil)).Elem().Size()
+
+// DecodeAny decodes the next value of the stream, which must have been encoded with the
+// SelfDescribing option, into generic values. See the DecodeAny function for details.
+func (d *Decoder) DecodeAny() (v interface{}, err error) {
+	if d.nesting == 0 {
+		d.spent = 0
+		if d.opts.checksum {
+			d.beginChecksum()
+		}
+	}
+
+	d.nesting++
+	var s *Schema
+	switch s, err = d.readHeader(true); {
+	case err != nil:
+	case d.opts.compressor != nil:
+		err = d.readCompressed(func(d *Decoder) (err error) {
+			v, err = d.readAny(s)
+			return
+		})
+	default:
+		v, err = d.readAny(s)
+	}
+
+	if d.nesting--; d.nesting == 0 {
+		if d.opts.checksum {
+			err = d.endChecksum(err)
+		}
+		d.clearTables()
+	}
+	return
+}
+
+// readAny reads a value of the schema into a generic value.
+func (d *Decoder) readAny(s *Schema) (interface{}, error) {
+	switch s.Kind {
+	case KindBool:
+		return d.ReadBool()
+	case KindVarint:
+		return d.ReadVarint()
+	case KindUvarint:
+		return d.ReadUvarint()
+	case KindRawVarint:
+		v, err := d.ReadUvarint()
+		return int64(v), err
+	case KindInt:
+		v, err := d.readFixed(s.Size)
+		shift := 64 - 8*uint(s.Size) // Extends the sign of smaller integers
+		return int64(v<<shift) >> shift, err
+	case KindUint:
+		return d.readFixed(s.Size)
+	case KindFloat:
+		if s.Size == 4 {
+			v, err := d.ReadFloat32()
+			return float64(v), err
+		}
+		return d.ReadFloat64()
+	case KindComplex:
+		if s.Size == 8 {
+			v, err := d.ReadComplex64()
+			return complex128(v), err
+		}
+		return d.ReadComplex128()
+	case KindString:
+		return d.readAnyString(s)
+	case KindBytes:
+		return d.ReadBytes()
+	case KindTime, KindUnixNano:
+		var v time.Time
+		c := timeCodec{unixNano: s.Kind == KindUnixNano}
+		return v, c.DecodeTo(d, reflect.ValueOf(&v).Elem())
+	case KindBigInt:
+		v := new(big.Int)
+		return v, d.readBigInt(v)
+	case KindBigRat:
+		v := new(big.Rat)
+		return v, new(bigRatCodec).DecodeTo(d, reflect.ValueOf(v).Elem())
+	case KindBigFloat:
+		v := new(big.Float)
+		return v, new(bigFloatCodec).DecodeTo(d, reflect.ValueOf(v).Elem())
+
+	case KindDelta:
+		return d.readAnyDelta(s)
+	case KindVByte:
+		return d.readAnyVByte(s)
+	case KindRLE:
+		return d.readAnyRuns(s)
+	case KindBits:
+		return d.readAnyBits()
+	case KindGorilla:
+		return d.readAnyGorilla()
+	case KindSQL:
+		return d.readSQLValue()
+	case KindArray, KindSlice:
+		return d.readAnySlice(s)
+	case KindMap:
+		return d.readAnyMap(s)
+	case KindStruct:
+		return d.readAnyStruct(s)
+	case KindColumnar:
+		return d.readAnyColumns(s)
+	case KindPointer:
+		return d.readAnyPointer(s)
+	case KindInterface:
+		return d.readAnyInterface()
+	default:
+		return nil, errOpaque(s)
+	}
+}
+
+// readAnyString reads a string, which may be interned or have a fixed-width length.
+func (d *Decoder) readAnyString(s *Schema) (string, error) {
+	if d.opts.intern || s.Size != 2 {
+		return d.ReadString()
+	}
+
+	l, err := d.ReadUint16()
+	if err != nil {
+		return "", err
+	}
+
+	b, err := d.Slice(int(l))
+	if err != nil {
+		return "", err
+	}
+	return d.toString(b)
+}
+
+// readAnyDelta reads the integers of a delta-encoded slice.
+func (d *Decoder) readAnyDelta(s *Schema) (out interface{}, err error) {
+	var l int
+	if l, err = d.readSliceOf(anySize); err != nil {
+		return
+	}
+
+	signed := s.Elem.Kind == KindVarint
+	var elems []interface{}
+	err = d.readDelta(l, signed, func(_ int, v uint64) error {
+		if signed {
+			elems = append(elems, int64(v))
+		} else {
+			elems = append(elems, v)
+		}
+		return nil
+	})
+	return elems, err
+}
+
+// readAnyVByte reads the elements of a slice in the Stream VByte format.
+func (d *Decoder) readAnyVByte(s *Schema) (out interface{}, err error) {
+	var l int
+	var control, data []byte
+	if l, control, data, err = d.readVByte(); err != nil || l == 0 {
+		return
+	}
+	if err = d.allocate(l, anySize); err != nil {
+		return
+	}
+
+	elems := make([]interface{}, l) // The size was checked against the data
+	if s.Elem.Kind == KindVarint {
+		v := make([]int64, l)
+		err = readVByte(v, control, data, unzigzagTo[int64])
+		for i := range v {
+			elems[i] = v[i]
+		}
+	} else {
+		v := make([]uint64, l)
+		err = readVByte(v, control, data, uintTo[uint64])
+		for i := range v {
+			elems[i] = v[i]
+		}
+	}
+	return elems, err
+}
+
+// readAnyBits reads the booleans of a bit-packed slice.
+func (d *Decoder) readAnyBits() (out interface{}, err error) {
+	var l int
+	var b []byte
+	if l, err = d.readSliceOf(anySize); err != nil {
+		return
+	}
+	if b, err = d.Slice(bitsSize(l)); err != nil {
+		return
+	}
+
+	var elems []interface{}
+	for i := 0; i < l; i++ {
+		elems = append(elems, b[i/8]&(1<<(i%8)) != 0)
+	}
+	return elems, nil
+}
+
+// readAnyGorilla reads the values of a slice of float64 compressed with XOR.
+func (d *Decoder) readAnyGorilla() (out interface{}, err error) {
+	var l int
+	var b []byte
+	if l, b, err = d.readGorillaBits(); err != nil || l == 0 {
+		return
+	}
+	if err = d.allocate(l, anySize); err != nil {
+		return
+	}
+
+	elems := make([]interface{}, l) // The size was checked against the compressed bits
+	err = readGorilla(b, l, func(i int, v float64) {
+		elems[i] = v
+	})
+	return elems, err
+}
+
+// readAnyRuns reads the elements of a run-length encoded slice.
+func (d *Decoder) readAnyRuns(s *Schema) (out interface{}, err error) {
+	if err = d.enter(); err != nil {
+		return
+	}
+	defer d.leave()
+
+	var l, n int
+	if l, err = d.readSliceOf(anySize); err != nil {
+		return
+	}
+
+	var elems []interface{}
+	for i := 0; i < l; i += n {
+		var v interface{}
+		if n, err = d.readRun(l - i); err != nil {
+			return
+		}
+		if v, err = d.readAny(s.Elem); err != nil {
+			return
+		}
+		for j := 0; j < n; j++ {
+			elems = append(elems, v)
+		}
+	}
+	return elems, nil
+}
+
+// readAnySlice reads the elements of an array or a slice.
+func (d *Decoder) readAnySlice(s *Schema) (out interface{}, err error) {
+	if err = d.enter(); err != nil {
+		return
+	}
+	defer d.leave()
+
+	l := s.Len
+	switch max := d.opts.maxSliceLen; {
+	case s.Kind != KindArray:
+		if l, err = d.readSliceLen(); err != nil {
+			return
+		}
+	case max > 0 && l > max:
+		return nil, limitError("array length", uint64(l), max)
+	}
+
+	size := anySize
+	if s.Elem.Kind == KindUint && s.Elem.Size == 1 {
+		size = 1
+	}
+	if err = d.allocate(l, size); err != nil {
+		return
+	}
+
+	// Byte arrays are encoded as they are
+	if s.Kind == KindArray && s.Elem.Kind == KindUint && s.Elem.Size == 1 {
+		var b []byte
+		if b, err = d.Slice(l); err == nil && d.s != nil && !d.opts.zeroCopy {
+			b = append([]byte(nil), b...)
+		}
+		return b, err
+	}
+
+	var elems []interface{}
+	for i := 0; i < l; i++ {
+		var v interface{}
+		if v, err = d.readAny(s.Elem); err != nil {
+			return
+		}
+		elems = append(elems, v)
+	}
+	return elems, nil
+}
+
+// readAnyMap reads the pairs of a map, whose keys must be comparable.
+func (d *Decoder) readAnyMap(s *Schema) (out interface{}, err error) {
+	if err = d.enter(); err != nil {
+		return
+	}
+	defer d.leave()
+
+	var l int
+	if l, err = d.readSliceOf(2 * anySize); err != nil {
+		return
+	}
+
+	m := make(map[interface{}]interface{})
+	for i := 0; i < l; i++ {
+		var k, v interface{}
+		if k, err = d.readAny(s.Key); err != nil {
+			return
+		}
+		if k != nil && !reflect.TypeOf(k).Comparable() {
+			return nil, errors.New("binary: unable to decode " + s.Name + ", whose keys are not comparable once decoded")
+		}
+		if v, err = d.readAny(s.Elem); err != nil {
+			return
+		}
+		m[k] = v
+	}
+	return m, nil
+}
+
+// readAnyStruct reads the fields of a struct, keyed by their name.
+func (d *Decoder) readAnyStruct(s *Schema) (out interface{}, err error) {
+	if err = d.enter(); err != nil {
+		return
+	}
+	defer d.leave()
+
+	m := make(map[string]interface{}, len(s.Fields))
+	if d.opts.versioned {
+		return m, d.readAnyVersioned(s, m)
+	}
+
+	var buffer [8]byte
+	var bits []byte
+	if bits, err = d.readPresence(omittedFields(s), buffer[:]); err != nil {
+		return
+	}
+
+	// Fields which are omitted because they are zero are left out of the map
+	n := 0
+	for _, f := range s.Fields {
+		if f.OmitEmpty {
+			if n++; !present(bits, n-1) {
+				continue
+			}
+		}
+
+		if m[f.Name], err = d.readAny(f.Schema); err != nil {
+			return
+		}
+	}
+	return m, nil
+}
+
+// readAnyColumns reads the columns of a slice of structs, which are turned back into the
+// fields of each struct.
+func (d *Decoder) readAnyColumns(s *Schema) (out interface{}, err error) {
+	if err = d.enter(); err != nil {
+		return
+	}
+	defer d.leave()
+
+	var l int
+	if l, err = d.readSliceOf(anySize); err != nil || l == 0 {
+		return
+	}
+
+	// The columns are read first, so that their lengths are checked against the data
+	columns := make([]reflect.Value, len(s.Fields))
+	for i, f := range s.Fields {
+		var v interface{}
+		if v, err = d.readAny(f.Schema); err != nil {
+			return
+		}
+
+		columns[i] = reflect.ValueOf(v)
+		if columns[i].Kind() != reflect.Slice || columns[i].Len() != l {
+			return nil, errors.New("binary: expected " + strconv.Itoa(l) + " values in the column " + f.Name)
+		}
+	}
+
+	var elems []interface{}
+	for i := 0; i < l; i++ {
+		row := make(map[string]interface{}, len(s.Fields))
+		for j, f := range s.Fields {
+			row[f.Name] = columns[j].Index(i).Interface()
+		}
+		elems = append(elems, row)
+	}
+	return elems, nil
+}
+
+// readAnyVersioned reads the fields of a struct which are framed with their identifier
+// and length. Fields with an unknown identifier are skipped.
+func (d *Decoder) readAnyVersioned(s *Schema, m map[string]interface{}) (err error) {
+	for {
+		var id uint64
+		var l int
+		var frame []byte
+		if id, err = d.ReadUvarint(); err != nil || id == 0 {
+			return
+		}
+		if l, err = d.readSliceLen(); err != nil {
+			return
+		}
+		if frame, err = d.Slice(l); err != nil {
+			return
+		}
+
+		for _, f := range s.Fields {
+			if f.ID != int(id) {
+				continue
+			}
+
+			// Each field is encoded by a nested encoder, like with the decoder
+			nested := d.nested(frame)
+			m[f.Name], err = nested.readAny(f.Schema)
+			nested.release()
+			if err != nil {
+				return
+			}
+			break
+		}
+	}
+}
+
+// readAnyPointer reads a pointer, which is followed by its value if it is not nil.
+func (d *Decoder) readAnyPointer(s *Schema) (interface{}, error) {
+	present, err := d.r.ReadByte()
+	switch {
+	case err != nil:
+		return nil, err
+	case present == 0:
+		return nil, nil
+	case present == 1:
+		return d.readAny(s.Elem)
+	default:
+		return nil, errors.New("binary: invalid presence byte for " + s.Name)
+	}
+}
+
+// readAnyInterface reads the value of an interface, whose concrete type must be registered.
+func (d *Decoder) readAnyInterface() (out interface{}, err error) {
+	if err = d.enter(); err != nil {
+		return
+	}
+	defer d.leave()
+
+	var l int
+	var name []byte
+	if l, err = d.readStringLen(); err != nil || l == 0 {
+		return
+	}
+	if name, err = d.Slice(l); err != nil {
+		return
+	}
+
+	t, ok := registeredTypes.Load(string(name))
+	if !ok {
+		return nil, errors.New("binary: name '" + string(name) + "' is not registered")
+	}
+
+	var s *Schema
+	if s, err = SchemaOf(t.(reflect.Type), d.opts.schema()...); err == nil {
+		out, err = d.readAny(s)
+	}
+	return
+}
@@ -0,0 +1,89 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package binary
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecodeAny(t *testing.T) {
+	expect := map[string]interface{}{
+		"Header":   map[string]interface{}{"Version": uint64(2), "Kind": "order"},
+		"Text":     "hello",
+		"Payload":  []byte{1, 2, 3},
+		"Items":    []interface{}{map[string]interface{}{"Name": "a", "Price": 1.5}, map[string]interface{}{"Name": "b", "Price": 2.5}},
+		"Points":   []interface{}{map[string]interface{}{"X": float64(1), "Y": float64(2)}},
+		"Counts":   []interface{}{int64(-1), int64(300)},
+		"Sizes":    []interface{}{uint64(1), uint64(300)},
+		"Flags":    []interface{}{true, false},
+		"Hashes":   []interface{}{uint64(1), uint64(2)},
+		"ID":       []byte{1, 2, 3, 4},
+		"Ratio":    complex(1, 2),
+		"Meta":     map[interface{}]interface{}{"a": int64(1)},
+		"Next":     map[string]interface{}{"Version": uint64(3), "Kind": ""},
+		"Created":  time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+		"Amount":   big.NewInt(-12345),
+		"Last":     int64(-7),
+		"Shape":    map[string]interface{}{"Side": float64(2)},
+		"Fraction": big.NewRat(1, 3),
+		"Ints":     map[interface{}]interface{}{int64(1): "one"},
+	}
+
+	for _, opts := range [][]Option{
+		{SelfDescribing()},
+		{SelfDescribing(), Versioned()},
+		{SelfDescribing(), InternStrings(), ByteOrder(BigEndian)},
+	} {
+		b, err := Marshal(newWalked(), opts...)
+		assert.NoError(t, err)
+
+		v, err := DecodeAny(b)
+		assert.NoError(t, err)
+		assert.Equal(t, expect, v)
+	}
+}
+
+func TestDecodeAny_Stream(t *testing.T) {
+	var buffer bytes.Buffer
+	e := NewEncoder(&buffer, SelfDescribing())
+	assert.NoError(t, e.Encode(&linkedList{1, &linkedList{2, nil}}))
+	assert.NoError(t, e.Encode([]int16{-1, 1}))
+
+	d := NewDecoder(&buffer)
+	v, err := d.DecodeAny()
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"Value": int64(1),
+		"Next":  map[string]interface{}{"Value": int64(2), "Next": nil},
+	}, v)
+
+	v, err = d.DecodeAny()
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{int64(-1), int64(1)}, v)
+}
+
+func TestDecodeAny_Errors(t *testing.T) {
+	b, err := Marshal(newWalked(), SelfDescribing())
+	assert.NoError(t, err)
+
+	// Payloads without a header, truncated or exceeding the limits are rejected
+	_, err = DecodeAny(b[2:])
+	assert.Error(t, err)
+	_, err = DecodeAny(b[:len(b)-1])
+	assert.Error(t, err)
+	_, err = DecodeAny(b, MaxDepth(2))
+	assert.True(t, errors.Is(err, ErrLimitExceeded))
+
+	// Values of custom codecs can not be decoded without them
+	b, err = Marshal([]testOpaque{"a"}, SelfDescribing())
+	assert.NoError(t, err)
+	_, err = DecodeAny(b)
+	assert.Error(t, err)
+}
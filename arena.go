@@ -0,0 +1,160 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package binary
+
+import (
+	"reflect"
+	"unsafe"
+)
+
+// Arena is a region of memory which decoded values are allocated from with the UseArena
+// option, by bumping an offset, instead of one allocation each, and which is released at
+// once with Reset. This suits request-scoped decoding, as the garbage collector only sees
+// a few large chunks. Strings, byte slices, and the slices and pointers of types without
+// pointers, such as numbers or structs of numbers, are allocated from the arena, while
+// maps and the values containing pointers are allocated as usual. An arena is not safe
+// for concurrent use.
+type Arena struct {
+	chunks [][]byte // The chunks of memory, which are kept across resets
+	chunk  int      // The index of the chunk being allocated from
+	offset int      // The offset of the free memory in the chunk
+	size   int      // The size of the chunks
+	used   int      // The number of bytes allocated since the last reset
+}
+
+// NewArena creates an arena which allocates its memory in chunks of the given size, or
+// of 64KB if it is not positive. Values larger than a quarter of a chunk are allocated
+// separately, so that they do not waste the remainder of a chunk.
+func NewArena(size int) *Arena {
+	if size <= 0 {
+		size = 64 << 10
+	}
+	return &Arena{size: size}
+}
+
+// Reset releases all of the values allocated from the arena, so that its memory can be
+// reused. The values decoded with the arena must not be used afterwards, since their
+// memory will be overwritten.
+func (a *Arena) Reset() {
+	a.chunk, a.offset, a.used = 0, 0, 0
+}
+
+// Len returns the number of bytes allocated from the arena since it was last reset.
+func (a *Arena) Len() int {
+	return a.used
+}
+
+// alloc returns n zeroed bytes aligned to the alignment, which is a power of two.
+func (a *Arena) alloc(n, align int) []byte {
+	a.used += n
+	if n > a.size/4 {
+		return make([]byte, n)
+	}
+
+	for a.chunk < len(a.chunks) {
+		offset := (a.offset + align - 1) &^ (align - 1)
+		if chunk := a.chunks[a.chunk]; offset+n <= len(chunk) {
+			a.offset = offset + n
+			b := chunk[offset:a.offset:a.offset]
+			clear(b)
+			return b
+		}
+		a.chunk, a.offset = a.chunk+1, 0
+	}
+
+	// Chunks are allocated as byte slices, which are aligned to 8 bytes or more
+	a.chunks = append(a.chunks, make([]byte, a.size))
+	a.offset = n
+	return a.chunks[a.chunk][:n:n]
+}
+
+// bytes copies bytes into the arena.
+func (a *Arena) bytes(b []byte) []byte {
+	out := a.alloc(len(b), 1)
+	copy(out, b)
+	return out
+}
+
+// string copies bytes into a string allocated from the arena.
+func (a *Arena) string(b []byte) string {
+	if len(b) == 0 {
+		return ""
+	}
+	return unsafe.String(&a.bytes(b)[0], len(b))
+}
+
+// new returns a pointer to a zero value of a type without pointers.
+func (a *Arena) new(t reflect.Type) reflect.Value {
+	b := a.alloc(int(t.Size()), t.Align())
+	if len(b) == 0 {
+		return reflect.New(t)
+	}
+	return reflect.NewAt(t, unsafe.Pointer(&b[0]))
+}
+
+// slice returns a slice of a type whose elements have no pointers, with n zero elements.
+func (a *Arena) slice(t reflect.Type, n int) reflect.Value {
+	elem := t.Elem()
+	b := a.alloc(n*int(elem.Size()), elem.Align())
+	if len(b) == 0 {
+		return reflect.MakeSlice(t, n, n)
+	}
+
+	// The header of the byte slice is reinterpreted as the one of the slice type
+	header := unsafe.Slice(&b[0], n)
+	return reflect.NewAt(t, unsafe.Pointer(&header)).Elem()
+}
+
+// newSlice returns a slice of n zero elements, allocated from the arena if there is one
+// and the elements have no pointers.
+func (d *Decoder) newSlice(t reflect.Type, n int) reflect.Value {
+	if d.opts.arena != nil && pointerFree(t.Elem()) {
+		return d.opts.arena.slice(t, n)
+	}
+	return reflect.MakeSlice(t, n, n)
+}
+
+// newPointer returns a pointer to a zero value, allocated from the arena if there is one
+// and the value has no pointers.
+func (d *Decoder) newPointer(t reflect.Type) reflect.Value {
+	if d.opts.arena != nil && pointerFree(t) {
+		return d.opts.arena.new(t)
+	}
+	return reflect.New(t)
+}
+
+// pointerFree returns whether the values of a type contain no pointers, in which case
+// they can be allocated from an arena, which the garbage collector does not scan.
+func pointerFree(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64, reflect.Complex64, reflect.Complex128:
+		return true
+	case reflect.Array:
+		return t.Len() == 0 || pointerFree(t.Elem())
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			if !pointerFree(t.Field(i).Type) {
+				return false
+			}
+		}
+		return true
+	default:
+		return false
+	}
+}
+
+// ------------------------------------------------------------------------------
+
+// UseArena allocates the decoded strings, byte slices, and the slices and pointers of
+// types without pointers from an arena, which reduces the pressure on the garbage
+// collector when many values are decoded and dropped together, such as for the requests
+// of a server. The decoded values must not be used once the arena is reset. When decoding
+// from a stream, large values are read in chunks and are not allocated from the arena.
+func UseArena(a *Arena) Option {
+	return func(o *options) {
+		o.arena = a
+	}
+}
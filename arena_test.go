@@ -0,0 +1,133 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package binary
+
+import (
+	"reflect"
+	"runtime"
+	"testing"
+	"unsafe"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type arenaPoint struct {
+	X, Y float64
+	Tag  uint8
+}
+
+type arenaRequest struct {
+	Name    string
+	Payload []byte
+	Points  []arenaPoint
+	Origin  *arenaPoint
+	Labels  []string
+	Counts  map[string]int
+}
+
+func TestArena(t *testing.T) {
+	v := arenaRequest{
+		Name:    "request",
+		Payload: []byte{1, 2, 3},
+		Points:  []arenaPoint{{X: 1, Y: 2, Tag: 3}, {X: 4, Y: 5}},
+		Origin:  &arenaPoint{X: -1},
+		Labels:  []string{"a", "bc"},
+		Counts:  map[string]int{"x": 1},
+	}
+
+	b, err := Marshal(&v)
+	assert.NoError(t, err)
+
+	arena := NewArena(1024)
+	for i := 0; i < 3; i++ {
+		arena.Reset()
+
+		var out arenaRequest
+		assert.NoError(t, Unmarshal(b, &out, UseArena(arena)))
+		assert.Equal(t, v, out)
+		assert.True(t, arena.Len() > 0)
+
+		// The values without pointers are allocated from the arena
+		assert.True(t, inArena(arena, unsafe.Pointer(unsafe.StringData(out.Name))))
+		assert.True(t, inArena(arena, unsafe.Pointer(&out.Payload[0])))
+		assert.True(t, inArena(arena, unsafe.Pointer(&out.Points[0])))
+		assert.True(t, inArena(arena, unsafe.Pointer(out.Origin)))
+		assert.True(t, inArena(arena, unsafe.Pointer(unsafe.StringData(out.Labels[1]))))
+		assert.False(t, inArena(arena, unsafe.Pointer(&out.Labels[0])))
+		runtime.GC()
+	}
+
+	// A single chunk is reused across resets
+	assert.Equal(t, 1, len(arena.chunks))
+}
+
+func TestArena_Alloc(t *testing.T) {
+	arena := NewArena(64)
+
+	a := arena.alloc(3, 1)
+	b := arena.alloc(8, 8)
+	assert.Equal(t, 3, len(a))
+	assert.Equal(t, uintptr(0), uintptr(unsafe.Pointer(&b[0]))%8)
+
+	// Allocations which do not fit move to the next chunk, large ones are separate
+	arena.alloc(16, 1)
+	arena.alloc(16, 1)
+	arena.alloc(16, 1)
+	assert.Equal(t, 1, len(arena.chunks))
+	arena.alloc(16, 1)
+	assert.Equal(t, 2, len(arena.chunks))
+	arena.alloc(100, 1)
+	assert.Equal(t, 2, len(arena.chunks))
+	assert.Equal(t, 3+8+16*4+100, arena.Len())
+
+	// Memory is zeroed when reused
+	arena.Reset()
+	c := arena.alloc(3, 1)
+	copy(c, "abc")
+	arena.Reset()
+	assert.Equal(t, []byte{0, 0, 0}, arena.alloc(3, 1))
+	assert.Equal(t, 0, NewArena(0).Len())
+}
+
+func TestArena_PointerFree(t *testing.T) {
+	assert.True(t, pointerFree(reflect.TypeOf(arenaPoint{})))
+	assert.True(t, pointerFree(reflect.TypeOf([4]int32{})))
+	assert.False(t, pointerFree(reflect.TypeOf("")))
+	assert.False(t, pointerFree(reflect.TypeOf([]int{})))
+	assert.False(t, pointerFree(reflect.TypeOf(struct{ P *int }{})))
+}
+
+func BenchmarkArena(b *testing.B) {
+	v := arenaRequest{Name: "request", Payload: make([]byte, 256), Points: make([]arenaPoint, 64)}
+	encoded, _ := Marshal(&v)
+
+	b.Run("heap", func(b *testing.B) {
+		b.ReportAllocs()
+		for n := 0; n < b.N; n++ {
+			var out arenaRequest
+			_ = Unmarshal(encoded, &out)
+		}
+	})
+
+	b.Run("arena", func(b *testing.B) {
+		b.ReportAllocs()
+		arena := NewArena(0)
+		for n := 0; n < b.N; n++ {
+			arena.Reset()
+			var out arenaRequest
+			_ = Unmarshal(encoded, &out, UseArena(arena))
+		}
+	})
+}
+
+// inArena returns whether a pointer points into one of the chunks of an arena.
+func inArena(a *Arena, p unsafe.Pointer) bool {
+	for _, c := range a.chunks {
+		start := uintptr(unsafe.Pointer(&c[0]))
+		if uintptr(p) >= start && uintptr(p) < start+uintptr(len(c)) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,162 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package binary
+
+import (
+	"fmt"
+	"reflect"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// The minimum duration of the measurement of Bench
+var benchTime = time.Second
+
+// BenchResult represents the outcome of Bench.
+type BenchResult struct {
+	Parallelism int           // The number of goroutines which ran the round-trips
+	N           int           // The number of values which were encoded and decoded
+	T           time.Duration // The total time of the round-trips
+	Bytes       int64         // The number of bytes which were encoded
+	Allocs      uint64        // The total number of memory allocations
+	AllocBytes  uint64        // The total number of bytes allocated
+}
+
+// NsPerOp returns the average time of a round-trip, in nanoseconds.
+func (r BenchResult) NsPerOp() int64 {
+	if r.N <= 0 {
+		return 0
+	}
+	return r.T.Nanoseconds() / int64(r.N)
+}
+
+// AllocsPerOp returns the average number of memory allocations of a round-trip.
+func (r BenchResult) AllocsPerOp() int64 {
+	if r.N <= 0 {
+		return 0
+	}
+	return int64(r.Allocs) / int64(r.N)
+}
+
+// AllocBytesPerOp returns the average number of bytes allocated by a round-trip.
+func (r BenchResult) AllocBytesPerOp() int64 {
+	if r.N <= 0 {
+		return 0
+	}
+	return int64(r.AllocBytes) / int64(r.N)
+}
+
+// MBPerSec returns the throughput of the round-trips, in megabytes of encoded bytes per
+// second.
+func (r BenchResult) MBPerSec() float64 {
+	if r.T <= 0 {
+		return 0
+	}
+	return float64(r.Bytes) / 1e6 / r.T.Seconds()
+}
+
+// String returns a summary of the result, similar to the output of go test -bench.
+func (r BenchResult) String() string {
+	return fmt.Sprintf("%8d\t%10d ns/op\t%7.2f MB/s\t%8d B/op\t%8d allocs/op",
+		r.N, r.NsPerOp(), r.MBPerSec(), r.AllocBytesPerOp(), r.AllocsPerOp())
+}
+
+// Bench measures the round-trips of a value, which is repeatedly encoded and decoded into
+// a new value of the same type by a number of concurrent goroutines, or by GOMAXPROCS of
+// them if the parallelism is not positive. This exercises the pools and the cache of the
+// codecs like a busy server would, so that the options can be compared on the actual
+// types of an application. The measurement lasts at least a second.
+func Bench(v interface{}, parallelism int, opts ...Option) (BenchResult, error) {
+	if parallelism <= 0 {
+		parallelism = runtime.GOMAXPROCS(0)
+	}
+
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	if !rv.IsValid() {
+		return BenchResult{}, fmt.Errorf("binary: unable to benchmark %T", v)
+	}
+
+	// Make sure the value survives a round-trip, which also warms up the cache
+	t := rv.Type()
+	if _, err := benchRoundTrip(v, t, opts); err != nil {
+		return BenchResult{}, err
+	}
+
+	// Grow the number of round-trips until the measurement lasts long enough
+	result := BenchResult{Parallelism: parallelism}
+	for n := parallelism; ; {
+		var err error
+		if result, err = benchRun(v, t, n, parallelism, opts); err != nil || result.T >= benchTime {
+			return result, err
+		}
+
+		// Predict the number of round-trips needed, like the testing package does
+		next := n * 100
+		if ns := result.NsPerOp(); ns > 0 {
+			next = int(1.2 * float64(benchTime.Nanoseconds()) / float64(ns))
+		}
+		n = min(max(next, n+1), n*100)
+	}
+}
+
+// benchRun runs n round-trips of a value over a number of goroutines.
+func benchRun(v interface{}, t reflect.Type, n, parallelism int, opts []Option) (BenchResult, error) {
+	var bytes atomic.Int64
+	var failure atomic.Pointer[error]
+	var wg sync.WaitGroup
+	var before, after runtime.MemStats
+
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	start := time.Now()
+	for g := 0; g < parallelism; g++ {
+		count := n / parallelism
+		if g < n%parallelism {
+			count++
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < count && failure.Load() == nil; i++ {
+				size, err := benchRoundTrip(v, t, opts)
+				if err != nil {
+					failure.CompareAndSwap(nil, &err)
+					return
+				}
+				bytes.Add(int64(size))
+			}
+		}()
+	}
+
+	wg.Wait()
+	elapsed := time.Since(start)
+	runtime.ReadMemStats(&after)
+	if err := failure.Load(); err != nil {
+		return BenchResult{}, *err
+	}
+
+	return BenchResult{
+		Parallelism: parallelism,
+		N:           n,
+		T:           elapsed,
+		Bytes:       bytes.Load(),
+		Allocs:      after.Mallocs - before.Mallocs,
+		AllocBytes:  after.TotalAlloc - before.TotalAlloc,
+	}, nil
+}
+
+// benchRoundTrip encodes a value into a pooled buffer and decodes it into a new value of
+// the type, returning the number of bytes it was encoded into.
+func benchRoundTrip(v interface{}, t reflect.Type, opts []Option) (int, error) {
+	b, err := MarshalPooled(v, opts...)
+	if err != nil {
+		return 0, err
+	}
+
+	defer b.Release()
+	return len(b.Bytes()), Unmarshal(b.Bytes(), reflect.New(t).Interface(), opts...)
+}
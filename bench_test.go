@@ -0,0 +1,38 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package binary
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBench(t *testing.T) {
+	defer func(d time.Duration) { benchTime = d }(benchTime)
+	benchTime = 20 * time.Millisecond
+
+	result, err := Bench(s0v, 4)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, result.Parallelism)
+	assert.True(t, result.T >= benchTime)
+	assert.True(t, result.N > 4)
+	assert.Equal(t, int64(len(s0b)*result.N), result.Bytes)
+	assert.True(t, result.NsPerOp() > 0)
+	assert.True(t, result.MBPerSec() > 0)
+	assert.True(t, result.AllocsPerOp() > 0)
+	assert.Contains(t, result.String(), "allocs/op")
+
+	// With options, and as many goroutines as processors
+	result, err = Bench(s0v, 0, InternStrings())
+	assert.NoError(t, err)
+	assert.True(t, result.Parallelism > 0)
+
+	_, err = Bench(make(chan int), 1)
+	assert.Error(t, err)
+
+	_, err = Bench(nil, 1)
+	assert.Error(t, err)
+}
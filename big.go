@@ -0,0 +1,202 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package binary
+
+import (
+	"errors"
+	"math/big"
+	"math/bits"
+	"reflect"
+)
+
+// The reflected types of the math/big package
+var (
+	typeBigInt   = reflect.TypeOf(big.Int{})
+	typeBigRat   = reflect.TypeOf(big.Rat{})
+	typeBigFloat = reflect.TypeOf(big.Float{})
+)
+
+// writeBigInt writes an arbitrary precision integer as a variable-size header, containing
+// the number of bytes of its magnitude and its sign in the lowest bit, followed by the
+// magnitude in big-endian byte order. Zero is encoded as a single byte.
+func (e *Encoder) writeBigInt(x *big.Int) {
+	n := (x.BitLen() + 7) / 8
+	header := uint64(n) << 1
+	if x.Sign() < 0 {
+		header |= 1
+	}
+
+	e.WriteUvarint(header)
+	words, i := x.Bits(), 0
+	for j := n - 1; j >= 0; j-- {
+		e.scratch[i] = byte(words[j/(bits.UintSize/8)] >> uint(8*(j%(bits.UintSize/8))))
+		if i++; i == len(e.scratch) {
+			e.Write(e.scratch[:i])
+			i = 0
+		}
+	}
+
+	if i > 0 {
+		e.Write(e.scratch[:i])
+	}
+}
+
+// readBigInt reads an arbitrary precision integer into the destination.
+func (d *Decoder) readBigInt(x *big.Int) (err error) {
+	var l int
+	var negative bool
+	var b []byte
+	if l, negative, err = d.readBigIntHeader(); err != nil {
+		return
+	}
+	if err = d.allocate(l, 1); err != nil {
+		return
+	}
+
+	if b, err = d.Slice(l); err == nil {
+		x.SetBytes(b)
+		if negative {
+			x.Neg(x)
+		}
+	}
+	return
+}
+
+// skipBigInt skips over an arbitrary precision integer.
+func (d *Decoder) skipBigInt() error {
+	l, _, err := d.readBigIntHeader()
+	if err != nil {
+		return err
+	}
+	return d.skipBytes(l)
+}
+
+// readBigIntHeader reads the header of an arbitrary precision integer, which contains the
+// number of bytes of its magnitude and its sign.
+func (d *Decoder) readBigIntHeader() (int, bool, error) {
+	header, err := d.ReadUvarint()
+	if err != nil {
+		return 0, false, err
+	}
+
+	l := header >> 1
+	switch max := d.opts.maxSliceLen; {
+	case max > 0 && l > uint64(max):
+		return 0, false, limitError("big.Int length", l, max)
+	case l > uint64(maxInt):
+		return 0, false, limitError("big.Int length", l, maxInt)
+	}
+	return int(l), header&1 == 1, nil
+}
+
+// bigIntSize returns the number of bytes an arbitrary precision integer is encoded into.
+func bigIntSize(x *big.Int) int {
+	n := (x.BitLen() + 7) / 8
+	return uvarintSize(uint64(n)<<1) + n
+}
+
+// addrOf returns a pointer to the value, copying it first if it is not addressable.
+func addrOf(rv reflect.Value) interface{} {
+	if !rv.CanAddr() {
+		ptr := reflect.New(rv.Type())
+		ptr.Elem().Set(rv)
+		return ptr.Interface()
+	}
+
+	return rv.Addr().Interface()
+}
+
+// ------------------------------------------------------------------------------
+
+type bigIntCodec struct{}
+
+// Encode encodes a value into the encoder.
+func (c *bigIntCodec) EncodeTo(e *Encoder, rv reflect.Value) error {
+	e.writeBigInt(addrOf(rv).(*big.Int))
+	return nil
+}
+
+// Decode decodes into a reflect value from the decoder.
+func (c *bigIntCodec) DecodeTo(d *Decoder, rv reflect.Value) error {
+	return d.readBigInt(rv.Addr().Interface().(*big.Int))
+}
+
+// Size returns the encoded size of the value.
+func (c *bigIntCodec) Size(rv reflect.Value) int {
+	return bigIntSize(addrOf(rv).(*big.Int))
+}
+
+// ------------------------------------------------------------------------------
+
+// bigRatCodec encodes a rational number as its numerator followed by its denominator.
+type bigRatCodec struct{}
+
+// Encode encodes a value into the encoder.
+func (c *bigRatCodec) EncodeTo(e *Encoder, rv reflect.Value) error {
+	x := addrOf(rv).(*big.Rat)
+	e.writeBigInt(x.Num())
+	e.writeBigInt(x.Denom())
+	return nil
+}
+
+// Decode decodes into a reflect value from the decoder.
+func (c *bigRatCodec) DecodeTo(d *Decoder, rv reflect.Value) (err error) {
+	var num, denom big.Int
+	if err = d.readBigInt(&num); err != nil {
+		return
+	}
+	if err = d.readBigInt(&denom); err != nil {
+		return
+	}
+	if denom.Sign() == 0 {
+		return errors.New("binary: invalid big.Rat with a zero denominator")
+	}
+
+	rv.Addr().Interface().(*big.Rat).SetFrac(&num, &denom)
+	return
+}
+
+// Size returns the encoded size of the value.
+func (c *bigRatCodec) Size(rv reflect.Value) int {
+	x := addrOf(rv).(*big.Rat)
+	return bigIntSize(x.Num()) + bigIntSize(x.Denom())
+}
+
+// ------------------------------------------------------------------------------
+
+// bigFloatCodec encodes a floating-point number using its GobEncode representation, which
+// preserves its precision, rounding mode and accuracy.
+type bigFloatCodec struct{}
+
+// Encode encodes a value into the encoder.
+func (c *bigFloatCodec) EncodeTo(e *Encoder, rv reflect.Value) (err error) {
+	var buffer []byte
+	if buffer, err = addrOf(rv).(*big.Float).GobEncode(); err == nil {
+		e.WriteUvarint(uint64(len(buffer)))
+		e.Write(buffer)
+	}
+	return
+}
+
+// Decode decodes into a reflect value from the decoder.
+func (c *bigFloatCodec) DecodeTo(d *Decoder, rv reflect.Value) (err error) {
+	var l int
+	var b []byte
+	if l, err = d.readSliceOf(1); err == nil {
+		if b, err = d.Slice(l); err == nil {
+			err = rv.Addr().Interface().(*big.Float).GobDecode(b)
+		}
+	}
+	return
+}
+
+// Size returns the encoded size of the value.
+func (c *bigFloatCodec) Size(rv reflect.Value) int {
+	buffer, err := addrOf(rv).(*big.Float).GobEncode()
+	if err != nil {
+		return -1
+	}
+
+	return uvarintSize(uint64(len(buffer))) + len(buffer)
+}
@@ -0,0 +1,94 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package binary
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBigInt(t *testing.T) {
+	huge, _ := new(big.Int).SetString("-123456789012345678901234567890123456789", 10)
+	for _, v := range []*big.Int{
+		big.NewInt(0),
+		big.NewInt(1),
+		big.NewInt(-1),
+		big.NewInt(255),
+		big.NewInt(-256),
+		huge,
+	} {
+		b, err := Marshal(v)
+		assert.NoError(t, err)
+
+		size, err := Size(v)
+		assert.NoError(t, err)
+		assert.Equal(t, len(b), size)
+
+		out := new(big.Int)
+		assert.NoError(t, Unmarshal(b, out))
+		assert.Equal(t, 0, v.Cmp(out), v.String())
+	}
+
+	b, err := Marshal(big.NewInt(-256))
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{0x5, 0x1, 0x0}, b)
+}
+
+func TestBigIntLimit(t *testing.T) {
+	b, err := Marshal(new(big.Int).Lsh(big.NewInt(1), 1024))
+	assert.NoError(t, err)
+
+	out := new(big.Int)
+	assert.True(t, errors.Is(Unmarshal(b, out, MaxSliceLen(64)), ErrLimitExceeded))
+	assert.True(t, errors.Is(Unmarshal(b, out, MaxAllocation(64)), ErrBudgetExceeded))
+	assert.NoError(t, Unmarshal(b, out, MaxAllocation(256)))
+
+	b, err = Marshal(new(big.Float).SetPrec(2048).SetInt(out))
+	assert.NoError(t, err)
+	assert.True(t, errors.Is(Unmarshal(b, new(big.Float), MaxAllocation(64)), ErrBudgetExceeded))
+}
+
+func TestBigStruct(t *testing.T) {
+	type account struct {
+		Balance big.Rat
+		Limit   *big.Int
+		Rate    *big.Float
+		Ledger  map[string]big.Rat
+		Missing *big.Rat
+	}
+
+	v := account{
+		Balance: *big.NewRat(-10, 3),
+		Limit:   big.NewInt(1000000),
+		Rate:    new(big.Float).SetPrec(200).SetFloat64(0.0125),
+		Ledger: map[string]big.Rat{
+			"a": *big.NewRat(1, 7),
+		},
+	}
+
+	b, err := Marshal(&v)
+	assert.NoError(t, err)
+
+	size, err := Size(&v)
+	assert.NoError(t, err)
+	assert.Equal(t, len(b), size)
+
+	var out account
+	assert.NoError(t, Unmarshal(b, &out))
+	assert.Equal(t, 0, v.Balance.Cmp(&out.Balance))
+	assert.Equal(t, 0, v.Limit.Cmp(out.Limit))
+	assert.Equal(t, 0, v.Rate.Cmp(out.Rate))
+	assert.Equal(t, v.Rate.Prec(), out.Rate.Prec())
+	ledger := out.Ledger["a"]
+	assert.Equal(t, "1/7", ledger.String())
+	assert.Nil(t, out.Missing)
+}
+
+func TestBigRatZeroDenominator(t *testing.T) {
+	out := new(big.Rat)
+	assert.Error(t, Unmarshal([]byte{0x2, 0x1, 0x0}, out))
+}
@@ -0,0 +1,84 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package binary
+
+import (
+	"errors"
+	"reflect"
+)
+
+// The reflected type of a slice of booleans
+var typeBools = reflect.TypeOf([]bool(nil))
+
+// scanBits returns a codec for slices of booleans which packs 8 of them per byte,
+// selected with a `binary:",bits"` tag. By default, every boolean takes a byte.
+func scanBits(t reflect.Type) (Codec, error) {
+	if t.Kind() != reflect.Slice || t.Elem() != typeBools.Elem() {
+		return nil, errors.New("binary: bits encoding is not supported for " + t.String())
+	}
+
+	return new(bitsCodec), nil
+}
+
+// ------------------------------------------------------------------------------
+
+// bitsCodec represents a codec for slices of booleans, which are prefixed with their
+// count and packed 8 per byte, from the lowest bit of every byte.
+type bitsCodec struct{}
+
+// Encode encodes a value into the encoder.
+func (c *bitsCodec) EncodeTo(e *Encoder, rv reflect.Value) (err error) {
+	v := rv.Convert(typeBools).Interface().([]bool)
+	e.WriteUvarint(uint64(len(v)))
+
+	// The bytes are packed into the scratch buffer, then written a few at a time
+	n := 0
+	for i := 0; i < len(v); i += 8 {
+		var b byte
+		for j, bit := range v[i:min(i+8, len(v))] {
+			if bit {
+				b |= 1 << j
+			}
+		}
+
+		e.scratch[n] = b
+		if n++; n == len(e.scratch) {
+			e.Write(e.scratch[:n])
+			n = 0
+		}
+	}
+
+	e.Write(e.scratch[:n])
+	return
+}
+
+// Decode decodes into a reflect value from the decoder.
+func (c *bitsCodec) DecodeTo(d *Decoder, rv reflect.Value) (err error) {
+	var l int
+	var b []byte
+	if l, err = d.readSliceOf(1); err != nil || l == 0 {
+		return
+	}
+	if b, err = d.Slice(bitsSize(l)); err != nil {
+		return
+	}
+
+	v := make([]bool, l)
+	for i := range v {
+		v[i] = b[i/8]&(1<<(i%8)) != 0
+	}
+
+	rv.Set(reflect.ValueOf(v).Convert(rv.Type()))
+	return
+}
+
+// Size returns the encoded size of the value.
+func (c *bitsCodec) Size(rv reflect.Value) int {
+	return uvarintSize(uint64(rv.Len())) + bitsSize(rv.Len())
+}
+
+// bitsSize returns the number of bytes needed to pack a number of booleans.
+func bitsSize(l int) int {
+	return l/8 + (l%8+7)/8
+}
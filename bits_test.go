@@ -0,0 +1,72 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package binary
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type flags []bool
+
+type features struct {
+	Enabled flags  `binary:",bits"`
+	Visible []bool `binary:",bits"`
+	Plain   []bool
+}
+
+func TestBits(t *testing.T) {
+	v := &features{Enabled: make(flags, 3000), Visible: []bool{true, false, true}, Plain: []bool{true}}
+	for i := 0; i < len(v.Enabled); i += 7 {
+		v.Enabled[i] = true
+	}
+
+	b, err := Marshal(v)
+	assert.NoError(t, err)
+	assert.Equal(t, 2+375+1+1+2, len(b))
+	assert.Equal(t, []byte{3, 0x05, 1, 1}, b[len(b)-4:])
+
+	size, err := Size(v)
+	assert.NoError(t, err)
+	assert.Equal(t, len(b), size)
+
+	var out features
+	assert.NoError(t, Unmarshal(b, &out))
+	assert.Equal(t, v, &out)
+
+	// Decoding from a stream, or skipping over them
+	out = features{}
+	assert.NoError(t, NewDecoder(bytes.NewReader(b)).Decode(&out))
+	assert.Equal(t, v, &out)
+
+	out = features{}
+	assert.NoError(t, Unmarshal(b, &out, Fields("Plain")))
+	assert.Equal(t, features{Plain: v.Plain}, out)
+
+	// Truncated payloads fail
+	assert.Error(t, Unmarshal(b[:100], &out))
+}
+
+func TestBits_Schema(t *testing.T) {
+	b, err := Marshal(&features{Visible: []bool{true, false}}, SelfDescribing())
+	assert.NoError(t, err)
+
+	out, err := DecodeAny(b, SelfDescribing())
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{true, false}, out.(map[string]interface{})["Visible"])
+
+	var kinds []Kind
+	assert.NoError(t, Walk(b, nil, func(t Token) error {
+		kinds = append(kinds, t.Schema.Kind)
+		return nil
+	}, SelfDescribing()))
+	assert.Equal(t, []Kind{KindBits, KindBits, KindSlice, KindStruct}, kinds)
+
+	_, err = Marshal(&struct {
+		Values []int `binary:",bits"`
+	}{})
+	assert.Error(t, err)
+}
@@ -0,0 +1,121 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package binary
+
+import (
+	"reflect"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// codecCache represents the cache of the codecs of the scanned types. Reads only load an
+// immutable map with a single atomic operation, so they never contend with each other,
+// while writes copy the map. As the number of types of a program is bounded, the cost of
+// the copies is only paid during the warm-up.
+type codecCache struct {
+	lock   sync.Mutex                             // Serializes the writes
+	codecs atomic.Pointer[map[reflect.Type]Codec] // The immutable map of the codecs
+}
+
+// Load returns the cached codec of a type, if any.
+func (c *codecCache) Load(t reflect.Type) (Codec, bool) {
+	if m := c.codecs.Load(); m != nil {
+		codec, ok := (*m)[t]
+		return codec, ok
+	}
+	return nil, false
+}
+
+// LoadOrStore returns the cached codec of a type if there is one. Otherwise, it caches
+// and returns the codec. The returned boolean is true if the codec was already cached.
+func (c *codecCache) LoadOrStore(t reflect.Type, codec Codec) (Codec, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	var codecs map[reflect.Type]Codec
+	if m := c.codecs.Load(); m != nil {
+		if existing, ok := (*m)[t]; ok {
+			return existing, true
+		}
+
+		codecs = make(map[reflect.Type]Codec, len(*m)+1)
+		for k, v := range *m {
+			codecs[k] = v
+		}
+	} else {
+		codecs = make(map[reflect.Type]Codec, 1)
+	}
+
+	codecs[t] = codec
+	c.codecs.Store(&codecs)
+	return codec, false
+}
+
+// Range calls the function for every cached codec, until it returns false.
+func (c *codecCache) Range(fn func(reflect.Type, Codec) bool) {
+	if m := c.codecs.Load(); m != nil {
+		for t, codec := range *m {
+			if !fn(t, codec) {
+				return
+			}
+		}
+	}
+}
+
+// Reset removes every cached codec.
+func (c *codecCache) Reset() {
+	c.lock.Lock()
+	c.codecs.Store(nil)
+	c.lock.Unlock()
+}
+
+// Precompile scans the types of the values upfront, along with the types behind their
+// pointers, so that encoding or decoding them later does not incur the cost of scanning
+// them on first use. It is best called during initialization, and returns the first
+// error of a type which can not be encoded. Pointers to values are dereferenced, as with
+// Marshal, so both a value and a pointer to it precompile the same type.
+func Precompile(values ...interface{}) error {
+	for _, v := range values {
+		t := reflect.TypeOf(v)
+		if t == nil {
+			continue
+		}
+
+		if t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+
+		if err := Validate(t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CachedCodec describes a codec which was scanned and cached for a type.
+type CachedCodec struct {
+	Type  reflect.Type // The type of the values
+	Codec Codec        // The codec of the values
+	Size  int          // The encoded size of the zero value, or -1 if it is unknown
+}
+
+// Cached returns the codecs which were scanned and cached so far, ordered by the name of
+// their type. The sizes of the zero values give an estimate of the smallest payloads.
+func Cached() []CachedCodec {
+	var out []CachedCodec
+	schemas.Range(func(t reflect.Type, c Codec) bool {
+		out = append(out, CachedCodec{
+			Type:  t,
+			Codec: c,
+			Size:  sizeOf(c, reflect.Zero(t)),
+		})
+		return true
+	})
+
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Type.String() < out[j].Type.String()
+	})
+	return out
+}
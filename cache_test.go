@@ -0,0 +1,97 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package binary
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type precompiledItem struct {
+	Price float64
+}
+
+type precompiled struct {
+	ID    uint32 `binary:",fixed"`
+	Item  *precompiledItem
+	Items []precompiledItem
+}
+
+func TestPrecompile(t *testing.T) {
+	assert.NoError(t, Precompile(&precompiled{}, nil))
+
+	cached := make(map[reflect.Type]CachedCodec)
+	for _, c := range Cached() {
+		cached[c.Type] = c
+	}
+
+	// The types behind pointers are cached as well
+	assert.Contains(t, cached, reflect.TypeOf(precompiled{}))
+	assert.Contains(t, cached, reflect.TypeOf(precompiledItem{}))
+	assert.Equal(t, 8, cached[reflect.TypeOf(precompiledItem{})].Size)
+	assert.Equal(t, 4+1+1, cached[reflect.TypeOf(precompiled{})].Size)
+	assert.IsType(t, new(reflectStructCodec), cached[reflect.TypeOf(precompiled{})].Codec)
+
+	// Types which can not be encoded fail upfront
+	assert.Error(t, Precompile(1, make(chan int)))
+}
+
+func TestCached_Sorted(t *testing.T) {
+	assert.NoError(t, Precompile("", 1, true))
+
+	cached := Cached()
+	for i := 1; i < len(cached); i++ {
+		assert.True(t, cached[i-1].Type.String() <= cached[i].Type.String())
+	}
+}
+
+func TestCodecCache(t *testing.T) {
+	var cache codecCache
+	_, ok := cache.Load(reflect.TypeOf(""))
+	assert.False(t, ok)
+
+	// Concurrent writers agree on a single codec per type
+	var wg sync.WaitGroup
+	types := []reflect.Type{reflect.TypeOf(""), reflect.TypeOf(0), reflect.TypeOf(true)}
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for _, typ := range types {
+				c, _ := cache.LoadOrStore(typ, new(stringCodec))
+				loaded, ok := cache.Load(typ)
+				assert.True(t, ok)
+				assert.True(t, c == loaded)
+			}
+		}()
+	}
+	wg.Wait()
+
+	count := 0
+	cache.Range(func(reflect.Type, Codec) bool {
+		count++
+		return true
+	})
+	assert.Equal(t, len(types), count)
+
+	cache.Reset()
+	_, ok = cache.Load(reflect.TypeOf(""))
+	assert.False(t, ok)
+}
+
+func BenchmarkCodecCache(b *testing.B) {
+	typ := reflect.TypeOf(precompiled{})
+	assert.NoError(b, Precompile(precompiled{}))
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			scan(typ)
+		}
+	})
+}
@@ -0,0 +1,407 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package binary
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"time"
+)
+
+// The error returned for data which is not valid CBOR
+var errInvalidCBOR = errors.New("binary: invalid CBOR data")
+
+// The major types of CBOR data items
+const (
+	cborUint   = 0
+	cborNegInt = 1
+	cborBytes  = 2
+	cborString = 3
+	cborArray  = 4
+	cborMap    = 5
+	cborTag    = 6
+	cborSimple = 7
+)
+
+// MarshalCBOR encodes the payload into CBOR, as defined by RFC 8949, using the same struct
+// tags and options as the native format. Structs are encoded as maps keyed by the names
+// of their fields, which can be taken from their json tag with the JSONTags option, and
+// times as epoch-based timestamps, or as RFC 3339 strings if they have nanoseconds. The
+// compact encodings of slices selected by tags, such as delta or gorilla, are encoded as
+// plain arrays.
+func MarshalCBOR(v interface{}, opts ...Option) ([]byte, error) {
+	w := new(cborWriter)
+	if err := marshalFormat(w, v, opts); err != nil {
+		return nil, err
+	}
+	return *w, nil
+}
+
+// UnmarshalCBOR decodes a CBOR payload, as encoded by MarshalCBOR or by any other
+// encoder, as long as it uses definite lengths. The fields of structs are matched by
+// name, the unknown ones are skipped and the missing ones are left untouched. The limits
+// of the options apply, including the allocation budget.
+func UnmarshalCBOR(b []byte, v interface{}, opts ...Option) error {
+	return unmarshalFormat(&cborReader{b: b}, v, opts)
+}
+
+// ------------------------------------------------------------------------------
+
+// cborWriter writes values in the CBOR format, with the smallest of the heads of every
+// value.
+type cborWriter []byte
+
+// writeHead writes the head of a data item, which is its major type along with its
+// argument, such as its value or its length.
+func (w *cborWriter) writeHead(major byte, n uint64) {
+	major <<= 5
+	switch {
+	case n < 24:
+		*w = append(*w, major|byte(n))
+	case n <= math.MaxUint8:
+		*w = append(*w, major|24, byte(n))
+	case n <= math.MaxUint16:
+		*w = binary.BigEndian.AppendUint16(append(*w, major|25), uint16(n))
+	case n <= math.MaxUint32:
+		*w = binary.BigEndian.AppendUint32(append(*w, major|26), uint32(n))
+	default:
+		*w = binary.BigEndian.AppendUint64(append(*w, major|27), n)
+	}
+}
+
+// writeNil writes a null value.
+func (w *cborWriter) writeNil() {
+	*w = append(*w, 0xf6)
+}
+
+// writeBool writes a boolean.
+func (w *cborWriter) writeBool(v bool) {
+	if v {
+		*w = append(*w, 0xf5)
+		return
+	}
+	*w = append(*w, 0xf4)
+}
+
+// writeInt writes a signed integer, negative ones being encoded as -1 minus their argument.
+func (w *cborWriter) writeInt(v int64) {
+	if v < 0 {
+		w.writeHead(cborNegInt, ^uint64(v))
+		return
+	}
+	w.writeHead(cborUint, uint64(v))
+}
+
+// writeUint writes an unsigned integer.
+func (w *cborWriter) writeUint(v uint64) {
+	w.writeHead(cborUint, v)
+}
+
+// writeFloat32 writes a single-precision floating-point number.
+func (w *cborWriter) writeFloat32(v float32) {
+	*w = binary.BigEndian.AppendUint32(append(*w, 0xfa), math.Float32bits(v))
+}
+
+// writeFloat64 writes a double-precision floating-point number.
+func (w *cborWriter) writeFloat64(v float64) {
+	*w = binary.BigEndian.AppendUint64(append(*w, 0xfb), math.Float64bits(v))
+}
+
+// writeString writes a text string, prefixed with its length.
+func (w *cborWriter) writeString(v string) {
+	w.writeHead(cborString, uint64(len(v)))
+	*w = append(*w, v...)
+}
+
+// writeBytes writes a byte string, prefixed with its length.
+func (w *cborWriter) writeBytes(v []byte) {
+	w.writeHead(cborBytes, uint64(len(v)))
+	*w = append(*w, v...)
+}
+
+// writeArray writes the head of an array.
+func (w *cborWriter) writeArray(n int) {
+	w.writeHead(cborArray, uint64(n))
+}
+
+// writeMap writes the head of a map.
+func (w *cborWriter) writeMap(n int) {
+	w.writeHead(cborMap, uint64(n))
+}
+
+// writeTime writes a time as seconds since the epoch with the tag 1, or as an RFC 3339
+// string with the tag 0 if it has nanoseconds, so that they are not rounded.
+func (w *cborWriter) writeTime(v time.Time) {
+	if v.Nanosecond() == 0 {
+		w.writeHead(cborTag, 1)
+		w.writeInt(v.Unix())
+		return
+	}
+
+	w.writeHead(cborTag, 0)
+	w.writeString(v.Format(time.RFC3339Nano))
+}
+
+// ------------------------------------------------------------------------------
+
+// cborReader reads values in the CBOR format from a buffer.
+type cborReader struct {
+	b []byte
+	i int // The offset of the next data item
+}
+
+// readHead reads the head of a data item, which is its major type, its additional
+// information and its argument. Indefinite lengths are not supported.
+func (r *cborReader) readHead() (major, info byte, n uint64, err error) {
+	if r.i >= len(r.b) {
+		return 0, 0, 0, io.ErrUnexpectedEOF
+	}
+
+	major, info = r.b[r.i]>>5, r.b[r.i]&0x1f
+	r.i++
+	if info < 24 {
+		return major, info, uint64(info), nil
+	}
+	if info > 27 {
+		return 0, 0, 0, errInvalidCBOR
+	}
+
+	size := 1 << (info - 24)
+	if len(r.b)-r.i < size {
+		return 0, 0, 0, io.ErrUnexpectedEOF
+	}
+
+	switch b := r.b[r.i:]; size {
+	case 1:
+		n = uint64(b[0])
+	case 2:
+		n = uint64(binary.BigEndian.Uint16(b))
+	case 4:
+		n = uint64(binary.BigEndian.Uint32(b))
+	default:
+		n = binary.BigEndian.Uint64(b)
+	}
+	r.i += size
+	return
+}
+
+// expect reads the head of a data item, which must be of a major type.
+func (r *cborReader) expect(major byte, what string) (uint64, error) {
+	offset := r.i
+	m, _, n, err := r.readHead()
+	if err == nil && m != major {
+		err = fmt.Errorf("%w: expected %s at offset %d", errInvalidCBOR, what, offset)
+	}
+	return n, err
+}
+
+// readNil reads a null or undefined value, if it is the next value.
+func (r *cborReader) readNil() bool {
+	if r.i < len(r.b) && (r.b[r.i] == 0xf6 || r.b[r.i] == 0xf7) {
+		r.i++
+		return true
+	}
+	return false
+}
+
+// readBool reads a boolean.
+func (r *cborReader) readBool() (bool, error) {
+	n, err := r.expect(cborSimple, "a boolean")
+	switch {
+	case err != nil:
+		return false, err
+	case n != 20 && n != 21:
+		return false, fmt.Errorf("%w: expected a boolean at offset %d", errInvalidCBOR, r.i-1)
+	default:
+		return n == 21, nil
+	}
+}
+
+// readInt reads an integer, which must fit into a signed 64-bit integer.
+func (r *cborReader) readInt() (int64, error) {
+	offset := r.i
+	major, _, n, err := r.readHead()
+	switch {
+	case err != nil:
+		return 0, err
+	case major != cborUint && major != cborNegInt:
+		return 0, fmt.Errorf("%w: expected an integer at offset %d", errInvalidCBOR, offset)
+	case n > math.MaxInt64:
+		return 0, UintOverflowError(n, "int64")
+	case major == cborNegInt:
+		return -1 - int64(n), nil
+	default:
+		return int64(n), nil
+	}
+}
+
+// readUint reads an unsigned integer.
+func (r *cborReader) readUint() (uint64, error) {
+	offset := r.i
+	major, _, n, err := r.readHead()
+	switch {
+	case err != nil:
+		return 0, err
+	case major == cborNegInt:
+		return 0, fmt.Errorf("%w: negative integer at offset %d", ErrOverflow, offset)
+	case major != cborUint:
+		return 0, fmt.Errorf("%w: expected an integer at offset %d", errInvalidCBOR, offset)
+	default:
+		return n, nil
+	}
+}
+
+// readFloat reads a floating-point number of any precision, or an integer.
+func (r *cborReader) readFloat() (float64, error) {
+	if r.i < len(r.b) && r.b[r.i]>>5 != cborSimple {
+		v, err := r.readInt()
+		return float64(v), err
+	}
+
+	offset := r.i
+	_, info, n, err := r.readHead()
+	switch {
+	case err != nil:
+		return 0, err
+	case info == 25:
+		return halfToFloat(uint16(n)), nil
+	case info == 26:
+		return float64(math.Float32frombits(uint32(n))), nil
+	case info == 27:
+		return math.Float64frombits(n), nil
+	default:
+		return 0, fmt.Errorf("%w: expected a number at offset %d", errInvalidCBOR, offset)
+	}
+}
+
+// readString reads a text string, which points into the buffer.
+func (r *cborReader) readString() ([]byte, error) {
+	n, err := r.expect(cborString, "a text string")
+	if err != nil {
+		return nil, err
+	}
+	return r.slice(n)
+}
+
+// readBytes reads a byte string, which points into the buffer.
+func (r *cborReader) readBytes() ([]byte, error) {
+	n, err := r.expect(cborBytes, "a byte string")
+	if err != nil {
+		return nil, err
+	}
+	return r.slice(n)
+}
+
+// slice returns the next bytes of the buffer.
+func (r *cborReader) slice(n uint64) ([]byte, error) {
+	if n > uint64(len(r.b)-r.i) {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	b := r.b[r.i : r.i+int(n)]
+	r.i += int(n)
+	return b, nil
+}
+
+// readArray reads the head of an array. As every element takes at least a byte, the
+// length can not exceed the remaining bytes.
+func (r *cborReader) readArray() (int, error) {
+	n, err := r.expect(cborArray, "an array")
+	if err == nil && n > uint64(r.remaining()) {
+		return 0, io.ErrUnexpectedEOF
+	}
+	return int(n), err
+}
+
+// readMap reads the head of a map. As every key and value takes at least a byte, the
+// length can not exceed half of the remaining bytes.
+func (r *cborReader) readMap() (int, error) {
+	n, err := r.expect(cborMap, "a map")
+	if err == nil && n > uint64(r.remaining()/2) {
+		return 0, io.ErrUnexpectedEOF
+	}
+	return int(n), err
+}
+
+// readTime reads a time, either as an RFC 3339 string with the tag 0 or as seconds since
+// the epoch with the tag 1.
+func (r *cborReader) readTime() (time.Time, error) {
+	offset := r.i
+	tag, err := r.expect(cborTag, "a time")
+	switch {
+	case err != nil:
+		return time.Time{}, err
+	case tag == 0:
+		text, err := r.readString()
+		if err != nil {
+			return time.Time{}, err
+		}
+		return time.Parse(time.RFC3339Nano, string(text))
+	case tag == 1 && r.i < len(r.b) && r.b[r.i]>>5 == cborSimple:
+		v, err := r.readFloat()
+		sec, frac := math.Modf(v)
+		return time.Unix(int64(sec), int64(frac*1e9)).UTC(), err
+	case tag == 1:
+		v, err := r.readInt()
+		return time.Unix(v, 0).UTC(), err
+	default:
+		return time.Time{}, fmt.Errorf("%w: expected a time at offset %d", errInvalidCBOR, offset)
+	}
+}
+
+// skip skips over a data item, including the items it contains.
+func (r *cborReader) skip(depth int) error {
+	if depth > maxFormatDepth {
+		return limitError("depth", uint64(depth), maxFormatDepth)
+	}
+
+	major, _, n, err := r.readHead()
+	switch {
+	case err != nil:
+		return err
+	case major == cborBytes || major == cborString:
+		_, err = r.slice(n)
+	case major == cborArray || major == cborMap:
+		if major == cborMap {
+			n *= 2
+		}
+		for ; n > 0 && err == nil; n-- {
+			err = r.skip(depth + 1)
+		}
+	case major == cborTag:
+		err = r.skip(depth + 1)
+	}
+	return err
+}
+
+// remaining returns the number of bytes which were not read yet.
+func (r *cborReader) remaining() int {
+	return len(r.b) - r.i
+}
+
+// halfToFloat converts a half-precision floating-point number, as in the appendix D
+// of RFC 8949.
+func halfToFloat(h uint16) float64 {
+	exp, mant := int(h>>10)&0x1f, float64(h&0x3ff)
+	var v float64
+	switch exp {
+	case 0:
+		v = math.Ldexp(mant, -24)
+	case 31:
+		v = math.Inf(1)
+		if mant != 0 {
+			v = math.NaN()
+		}
+	default:
+		v = math.Ldexp(mant+1024, exp-25)
+	}
+
+	if h&0x8000 != 0 {
+		return -v
+	}
+	return v
+}
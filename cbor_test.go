@@ -0,0 +1,191 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package binary
+
+import (
+	"errors"
+	"math"
+	"math/big"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCBORValues(t *testing.T) {
+	for _, tc := range []struct {
+		value  interface{}
+		expect []byte
+	}{
+		{0, []byte{0x00}},
+		{23, []byte{0x17}},
+		{24, []byte{0x18, 0x18}},
+		{1000, []byte{0x19, 0x03, 0xe8}},
+		{uint64(1000000000000), []byte{0x1b, 0x00, 0x00, 0x00, 0xe8, 0xd4, 0xa5, 0x10, 0x00}},
+		{-1, []byte{0x20}},
+		{-1000, []byte{0x39, 0x03, 0xe7}},
+		{1.5, []byte{0xfb, 0x3f, 0xf8, 0, 0, 0, 0, 0, 0}},
+		{true, []byte{0xf5}},
+		{"IETF", []byte{0x64, 0x49, 0x45, 0x54, 0x46}},
+		{[]byte{1, 2, 3, 4}, []byte{0x44, 0x01, 0x02, 0x03, 0x04}},
+		{[]int{1, 2, 3}, []byte{0x83, 0x01, 0x02, 0x03}},
+		{map[string]int{"a": 1, "b": 2}, []byte{0xa2, 0x61, 'a', 0x01, 0x61, 'b', 0x02}},
+		{time.Unix(1363896240, 0), []byte{0xc1, 0x1a, 0x51, 0x4b, 0x67, 0xb0}},
+	} {
+		b, err := MarshalCBOR(tc.value, Deterministic())
+		assert.NoError(t, err)
+		assert.Equal(t, tc.expect, b, "%v", tc.value)
+	}
+}
+
+func TestCBORRoundTrip(t *testing.T) {
+	type inner struct {
+		Name  string
+		Score *float32
+	}
+
+	type T struct {
+		ID      uint64
+		Delta   int32
+		Ratio   float64
+		Valid   bool
+		Data    []byte
+		Hash    [4]byte
+		Tags    []string
+		Values  []int    `binary:",delta"`
+		Flags   []bool   `binary:",bits"`
+		Inner   inner    `json:"inner"`
+		Next    *T       `json:"next"`
+		Items   []*inner `json:"items"`
+		Counts  map[string]int
+		When    time.Time
+		Precise time.Time
+		Big     *big.Int
+		Complex complex128
+		Skipped string `json:"-"`
+	}
+
+	score := float32(0.5)
+	v := &T{
+		ID:      1 << 40,
+		Delta:   -42,
+		Ratio:   3.25,
+		Valid:   true,
+		Data:    []byte("data"),
+		Hash:    [4]byte{1, 2, 3, 4},
+		Tags:    []string{"a", "", "c"},
+		Values:  []int{-1, 0, 1},
+		Flags:   []bool{true, false, true},
+		Inner:   inner{Name: "inner", Score: &score},
+		Next:    &T{ID: 2, Inner: inner{Name: "next"}},
+		Items:   []*inner{{Name: "x"}, nil},
+		Counts:  map[string]int{"a": 1, "b": 0},
+		When:    time.Unix(1363896240, 0).UTC(),
+		Precise: time.Date(2024, 1, 2, 3, 4, 5, 6, time.UTC),
+		Big:     new(big.Int).Lsh(big.NewInt(1), 100),
+		Complex: complex(1, -2),
+	}
+
+	b, err := MarshalCBOR(v, JSONTags())
+	assert.NoError(t, err)
+
+	out := new(T)
+	assert.NoError(t, UnmarshalCBOR(b, out, JSONTags()))
+	assert.Equal(t, v, out)
+}
+
+func TestCBORDecode(t *testing.T) {
+	type T struct {
+		A float64
+		B float32
+		C time.Time
+		D []int
+	}
+
+	var out T
+	assert.NoError(t, UnmarshalCBOR([]byte{
+		0xa5,
+		0x61, 'A', 0xf9, 0x7b, 0xff, // 65504 as a half-precision float
+		0x61, 'X', 0x82, 0xa1, 0x00, 0x40, 0xc1, 0x00, // unknown field
+		0x61, 'B', 0xf9, 0xc4, 0x00, // -4 as a half-precision float
+		0x61, 'C', 0xc0, 0x74, '2', '0', '1', '3', '-', '0', '3', '-', '2', '1', 'T', '2', '0', ':', '0', '4', ':', '0', '0', 'Z',
+		0x61, 'D', 0x80,
+	}, &out))
+	assert.Equal(t, T{A: 65504, B: -4, C: time.Unix(1363896240, 0).UTC()}, out)
+	assert.Equal(t, math.Inf(1), halfToFloat(0x7c00))
+	assert.Equal(t, 5.960464477539063e-8, halfToFloat(0x0001))
+}
+
+func TestCBORInvalid(t *testing.T) {
+	for _, b := range [][]byte{
+		{},                             // Empty
+		{0x19, 0x03},                   // Truncated integer
+		{0x64, 'a'},                    // Truncated string
+		{0x9f, 0x01, 0xff},             // Indefinite length
+		{0x9a, 0xff, 0xff, 0xff, 0xff}, // Array longer than the payload
+		{0x61, 'a'},                    // Mismatched type
+		{0x81, 0x01, 0x01},             // Trailing data
+	} {
+		var out []int
+		assert.Error(t, UnmarshalCBOR(b, &out), "%x", b)
+	}
+
+	var small uint8
+	assert.True(t, errors.Is(UnmarshalCBOR([]byte{0x19, 0x01, 0x2c}, &small), ErrOverflow))
+	assert.True(t, errors.Is(UnmarshalCBOR([]byte{0x20}, &small), ErrOverflow))
+	assert.True(t, errors.Is(UnmarshalCBOR([]byte{0x83, 0x01, 0x02, 0x03}, new([]int), MaxSliceLen(2)), ErrLimitExceeded))
+	assert.Error(t, UnmarshalCBOR([]byte{0x00}, small))
+
+	// Deeply nested unknown fields are limited
+	nested := []byte{0xa1, 0x61, 'X'}
+	for i := 0; i <= maxFormatDepth+1; i++ {
+		nested = append(nested, 0x81)
+	}
+	assert.True(t, errors.Is(UnmarshalCBOR(append(nested, 0x00), new(struct{})), ErrLimitExceeded))
+}
+
+func TestCBORBudget(t *testing.T) {
+	type record struct {
+		Name  string
+		Tags  []string
+		Data  []byte
+		Next  *record
+		Attrs map[string]int
+	}
+
+	v := record{
+		Name:  strings.Repeat("n", 600),
+		Tags:  []string{strings.Repeat("t", 600)},
+		Data:  make([]byte, 600),
+		Next:  &record{Name: "next"},
+		Attrs: make(map[string]int),
+	}
+	for i := 0; i < 50; i++ {
+		v.Attrs[strconv.Itoa(i)] = i
+	}
+
+	b, err := MarshalCBOR(v)
+	assert.NoError(t, err)
+
+	var out record
+	assert.NoError(t, UnmarshalCBOR(b, &out, MaxAllocation(4000)))
+	assert.Equal(t, v, out)
+
+	// Every kind of allocation is charged against the budget
+	for _, v := range []interface{}{v.Name, v.Tags, v.Data, v.Attrs, v} {
+		b, err := MarshalCBOR(v)
+		assert.NoError(t, err)
+
+		out := reflect.New(reflect.TypeOf(v))
+		err = UnmarshalCBOR(b, out.Interface(), MaxAllocation(500))
+		assert.True(t, errors.Is(err, ErrBudgetExceeded), "%T: %v", v, err)
+	}
+
+	// Pointers are charged as well
+	err = UnmarshalCBOR([]byte{0xa1, 0x64, 'N', 'e', 'x', 't', 0xa0}, new(record), MaxAllocation(8))
+	assert.True(t, errors.Is(err, ErrBudgetExceeded), "%v", err)
+}
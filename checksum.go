@@ -0,0 +1,100 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package binary
+
+import (
+	"errors"
+	"hash/crc32"
+)
+
+// ErrChecksum is returned when the checksum of a decoded value does not match the one
+// which was appended to it, which means that the payload is corrupt or truncated.
+var ErrChecksum = errors.New("binary: checksum mismatch")
+
+// The table of the CRC-32C polynomial, which is hardware accelerated on most platforms
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// The number of bytes of the checksum appended to every value
+const checksumSize = 4
+
+// Checksum appends the CRC-32C checksum of every encoded value after it, and verifies it
+// when decoding, failing with ErrChecksum if they do not match. This detects payloads
+// which were corrupted or truncated, rather than decoding them into garbage. Both sides
+// must use this option.
+func Checksum() Option {
+	return func(o *options) {
+		o.checksum = true
+	}
+}
+
+// trailer returns the number of bytes appended to every encoded value.
+func (o *options) trailer() int {
+	if o.checksum {
+		return checksumSize
+	}
+	return 0
+}
+
+// ------------------------------------------------------------------------------
+
+// beginChecksum starts computing the checksum of the bytes read by the decoder.
+func (d *Decoder) beginChecksum() {
+	if d.s != nil {
+		d.sum.start = d.s.i
+		return
+	}
+
+	d.sum.Reader, d.sum.crc = d.r, 0
+	d.r = &d.sum
+}
+
+// endChecksum stops computing the checksum of the bytes read by the decoder, then reads
+// the expected checksum and compares them, unless the decoding has failed.
+func (d *Decoder) endChecksum(err error) error {
+	var crc uint32
+	if d.s != nil {
+		crc = crc32.Checksum(d.s.s[d.sum.start:d.s.i], castagnoli)
+	} else {
+		d.r, crc = d.sum.Reader, d.sum.crc
+		d.sum.Reader = nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	expect, err := d.ReadUint32()
+	switch {
+	case err != nil:
+		return err
+	case expect != crc:
+		return ErrChecksum
+	default:
+		return nil
+	}
+}
+
+// checksumReader computes the checksum of the bytes read from a reader.
+type checksumReader struct {
+	Reader
+	crc   uint32  // The checksum of the bytes read so far
+	start int64   // The offset of the value, when decoding from a slice instead
+	last  [1]byte // The last byte read with ReadByte
+}
+
+// Read implements io.Reader interface.
+func (r *checksumReader) Read(p []byte) (n int, err error) {
+	n, err = r.Reader.Read(p)
+	r.crc = crc32.Update(r.crc, castagnoli, p[:n])
+	return
+}
+
+// ReadByte implements io.ByteReader interface.
+func (r *checksumReader) ReadByte() (b byte, err error) {
+	if b, err = r.Reader.ReadByte(); err == nil {
+		r.last[0] = b
+		r.crc = crc32.Update(r.crc, castagnoli, r.last[:])
+	}
+	return
+}
@@ -0,0 +1,84 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package binary
+
+import (
+	"bytes"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChecksum(t *testing.T) {
+	v := newProjected()
+	for _, opts := range [][]Option{
+		{Checksum()},
+		{Checksum(), Versioned()},
+		{Checksum(), InternStrings(), ByteOrder(BigEndian)},
+	} {
+		b, err := Marshal(v, opts...)
+		assert.NoError(t, err)
+
+		plain, err := Marshal(v, opts[1:]...)
+		assert.NoError(t, err)
+		assert.Equal(t, plain, b[:len(b)-checksumSize])
+
+		n, err := Size(v, opts...)
+		assert.NoError(t, err)
+		assert.Equal(t, len(b), n)
+
+		out := new(projected)
+		assert.NoError(t, Unmarshal(b, out, opts...))
+		assert.Equal(t, v, out)
+
+		// Every corrupted byte is detected
+		for i := range b {
+			corrupt := append([]byte(nil), b...)
+			corrupt[i] ^= 0x1
+			assert.Error(t, Unmarshal(corrupt, new(projected), opts...), "byte %d", i)
+		}
+
+		// Truncated payloads are detected as well
+		assert.Error(t, Unmarshal(b[:len(b)-1], new(projected), opts...))
+	}
+}
+
+func TestChecksum_Mismatch(t *testing.T) {
+	b, err := Marshal("hello", Checksum())
+	assert.NoError(t, err)
+
+	b[1] = 'j'
+	var out string
+	assert.Equal(t, ErrChecksum, Unmarshal(b, &out, Checksum()))
+}
+
+func TestChecksum_Stream(t *testing.T) {
+	var buffer bytes.Buffer
+	e := NewEncoderSize(&buffer, 16, Checksum())
+	assert.NoError(t, e.Encode(newProjected()))
+	assert.NoError(t, e.Encode("next"))
+	assert.NoError(t, e.Flush())
+
+	// Each value of a stream has its own checksum
+	var out projected
+	var next string
+	d := NewDecoder(iotest.OneByteReader(&buffer), Checksum())
+	assert.NoError(t, d.Decode(&out))
+	assert.NoError(t, d.Decode(&next))
+	assert.Equal(t, newProjected(), &out)
+	assert.Equal(t, "next", next)
+}
+
+func TestChecksum_SelfDescribing(t *testing.T) {
+	b, err := Marshal(newWalked(), Checksum(), SelfDescribing())
+	assert.NoError(t, err)
+
+	_, err = DecodeAny(b, Checksum())
+	assert.NoError(t, err)
+
+	b[len(b)-1] ^= 0x1
+	_, err = DecodeAny(b, Checksum())
+	assert.Equal(t, ErrChecksum, err)
+}
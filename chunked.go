@@ -0,0 +1,138 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package binary
+
+import (
+	"io"
+	"iter"
+)
+
+// ChunkedWriter writes a collection of values of type T whose count is not known up front,
+// such as the rows of a query, without holding them in memory. The values are grouped in
+// chunks, each prefixed with its count of values, and the collection ends with an empty
+// chunk, so that a reader can tell whether it is complete. A map can be written as its
+// entries, with a ChunkedWriter of Entry values.
+type ChunkedWriter[T any] struct {
+	e      *Encoder     // The encoder of the stream
+	values *Encoder     // The encoder of the values of the current chunk
+	chunk  appendWriter // The values of the current chunk
+	count  uint64       // The number of values of the current chunk
+}
+
+// NewChunkedWriter creates a writer of a collection of values of type T, which requires
+// calling Close once the values have been written. A chunk is written to the underlying
+// writer every time its values reach 4KB.
+func NewChunkedWriter[T any](w io.Writer, opts ...Option) *ChunkedWriter[T] {
+	s := &ChunkedWriter[T]{e: NewEncoderSize(w, defaultBufferSize, opts...)}
+	s.values = NewEncoder(&s.chunk, opts...)
+	return s
+}
+
+// Write encodes a value into the collection. A value which fails to encode is left out,
+// so that the writer can be used for the next values.
+func (s *ChunkedWriter[T]) Write(v T) error {
+	n := len(s.chunk)
+	if err := s.values.Encode(&v); err != nil {
+		s.chunk = s.chunk[:n]
+		return err
+	}
+
+	if s.count++; len(s.chunk) >= defaultBufferSize {
+		return s.writeChunk()
+	}
+	return nil
+}
+
+// Close writes the last chunk of values and the empty chunk which ends the collection,
+// and flushes them to the underlying writer. The writer must not be used afterwards.
+func (s *ChunkedWriter[T]) Close() error {
+	if err := s.writeChunk(); err != nil {
+		return err
+	}
+
+	s.e.WriteUvarint(0)
+	return s.e.Flush()
+}
+
+// writeChunk writes the values of the current chunk, if any, prefixed with their count.
+func (s *ChunkedWriter[T]) writeChunk() error {
+	if s.count == 0 {
+		return nil
+	}
+
+	s.e.WriteUvarint(s.count)
+	s.e.Write(s.chunk)
+	s.chunk, s.count = s.chunk[:0], 0
+	return s.e.Flush()
+}
+
+// ------------------------------------------------------------------------------
+
+// ChunkedReader reads a collection of values of type T written by a ChunkedWriter, one
+// value at a time, as the chunks arrive.
+type ChunkedReader[T any] struct {
+	d         *Decoder
+	remaining uint64 // The number of values left in the current chunk
+	done      bool   // Whether the empty chunk which ends the collection was read
+}
+
+// NewChunkedReader creates a reader of a collection of values of type T. The options must
+// match the ones used to write the values.
+func NewChunkedReader[T any](r io.Reader, opts ...Option) *ChunkedReader[T] {
+	return &ChunkedReader[T]{d: NewDecoder(r, opts...)}
+}
+
+// Next decodes the next value of the collection. It returns io.EOF once the collection
+// has ended, and io.ErrUnexpectedEOF if the stream ends before the end of the collection,
+// for example because the writer stopped before closing it.
+func (s *ChunkedReader[T]) Next() (v T, err error) {
+	for s.remaining == 0 {
+		if s.done {
+			return v, io.EOF
+		}
+
+		if s.remaining, err = s.d.ReadUvarint(); err != nil {
+			return v, unexpectedEOF(err)
+		}
+		s.done = s.remaining == 0
+	}
+
+	s.remaining--
+	err = unexpectedEOF(s.d.Decode(&v))
+	return
+}
+
+// All returns an iterator over the remaining values of the collection, which stops at the
+// end of the collection or after yielding the first error.
+func (s *ChunkedReader[T]) All() iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		for {
+			v, err := s.Next()
+			switch {
+			case err == io.EOF:
+				return
+			case !yield(v, err) || err != nil:
+				return
+			}
+		}
+	}
+}
+
+// unexpectedEOF returns io.ErrUnexpectedEOF if the stream has ended, which can not happen
+// before the end of a collection.
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// ------------------------------------------------------------------------------
+
+// Entry represents an entry of a map, whose key is encoded before its value like in the
+// map itself, in order to stream a map with a ChunkedWriter.
+type Entry[K, V any] struct {
+	Key   K
+	Value V
+}
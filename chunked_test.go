@@ -0,0 +1,109 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package binary
+
+import (
+	"bytes"
+	"io"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChunked(t *testing.T) {
+	var buffer bytes.Buffer
+	w := NewChunkedWriter[streamRecord](&buffer)
+
+	var values []streamRecord
+	for i := 0; i < 1000; i++ {
+		v := streamRecord{ID: i, Name: "record-" + strconv.Itoa(i)}
+		values = append(values, v)
+		assert.NoError(t, w.Write(v))
+	}
+
+	// Full chunks are written as the values are encoded
+	assert.NotZero(t, buffer.Len())
+	assert.NoError(t, w.Close())
+
+	r := NewChunkedReader[streamRecord](bytes.NewReader(buffer.Bytes()))
+	v, err := r.Next()
+	assert.NoError(t, err)
+	assert.Equal(t, values[0], v)
+
+	out := []streamRecord{v}
+	for v, err := range r.All() {
+		assert.NoError(t, err)
+		out = append(out, v)
+	}
+	assert.Equal(t, values, out)
+
+	_, err = r.Next()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestChunked_Empty(t *testing.T) {
+	var buffer bytes.Buffer
+	w := NewChunkedWriter[streamRecord](&buffer)
+	assert.NoError(t, w.Close())
+	assert.Equal(t, []byte{0}, buffer.Bytes())
+
+	r := NewChunkedReader[streamRecord](&buffer)
+	_, err := r.Next()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestChunked_Truncated(t *testing.T) {
+	var buffer bytes.Buffer
+	w := NewChunkedWriter[streamRecord](&buffer)
+	assert.NoError(t, w.Write(streamRecord{ID: 1, Name: "a"}))
+	assert.NoError(t, w.Write(streamRecord{ID: 2, Name: "b"}))
+	assert.NoError(t, w.Close())
+
+	// The collection ends without its empty chunk, or in the middle of a value
+	for _, n := range []int{buffer.Len() - 1, buffer.Len() - 2} {
+		r := NewChunkedReader[streamRecord](bytes.NewReader(buffer.Bytes()[:n]))
+		var errs []error
+		for _, err := range r.All() {
+			errs = append(errs, err)
+		}
+		assert.Equal(t, io.ErrUnexpectedEOF, errs[len(errs)-1])
+	}
+}
+
+func TestChunked_Failing(t *testing.T) {
+	var buffer bytes.Buffer
+	w := NewChunkedWriter[interface{}](&buffer)
+	assert.NoError(t, w.Write("a"))
+	assert.Error(t, w.Write(make(chan int)))
+	assert.NoError(t, w.Write("b"))
+	assert.NoError(t, w.Close())
+
+	var out []interface{}
+	r := NewChunkedReader[interface{}](&buffer)
+	for v, err := range r.All() {
+		assert.NoError(t, err)
+		out = append(out, v)
+	}
+	assert.Equal(t, []interface{}{"a", "b"}, out)
+}
+
+func TestChunked_Map(t *testing.T) {
+	m := map[string]int{"a": 1, "b": 2, "c": 3}
+
+	var buffer bytes.Buffer
+	w := NewChunkedWriter[Entry[string, int]](&buffer)
+	for k, v := range m {
+		assert.NoError(t, w.Write(Entry[string, int]{k, v}))
+	}
+	assert.NoError(t, w.Close())
+
+	out := make(map[string]int)
+	r := NewChunkedReader[Entry[string, int]](&buffer)
+	for e, err := range r.All() {
+		assert.NoError(t, err)
+		out[e.Key] = e.Value
+	}
+	assert.Equal(t, m, out)
+}
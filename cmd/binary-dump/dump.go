@@ -0,0 +1,105 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/kelindar/binary"
+)
+
+// entry represents a line of the dump.
+type entry struct {
+	path   string
+	kind   binary.Kind
+	name   string
+	offset int
+	raw    []byte
+	value  string
+}
+
+// dump walks over the payload and prints every value it contains, with the values
+// nested in others indented below them.
+func dump(w io.Writer, payload []byte, schema *binary.Schema, raw bool, opts ...binary.Option) error {
+	var entries []entry
+	err := binary.Walk(payload, schema, func(t binary.Token) error {
+		value, err := format(t)
+		entries = append(entries, entry{
+			path:   t.Path,
+			kind:   t.Schema.Kind,
+			name:   t.Schema.Name,
+			offset: t.Offset,
+			raw:    t.Raw,
+			value:  value,
+		})
+		return err
+	}, opts...)
+
+	// The walk visits the nested values first, so sort them after the values containing them.
+	// Even if the walk failed, the values found until then help locating the corruption.
+	sort.SliceStable(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		switch {
+		case a.offset != b.offset:
+			return a.offset < b.offset
+		case len(a.raw) != len(b.raw):
+			return len(a.raw) > len(b.raw)
+		default:
+			return depth(a.path) < depth(b.path)
+		}
+	})
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "OFFSET\tSIZE\tPATH\tKIND\tTYPE\tVALUE")
+	for _, e := range entries {
+		path := e.path
+		if path == "" {
+			path = "(root)"
+		}
+
+		value := e.value
+		if raw {
+			value = strings.TrimSpace(fmt.Sprintf("%s % x", value, e.raw))
+		}
+
+		indent := strings.Repeat("  ", depth(e.path))
+		fmt.Fprintf(tw, "%d\t%d\t%s%s\t%s\t%s\t%s\n", e.offset, len(e.raw), indent, path, e.kind, e.name, value)
+	}
+
+	if ferr := tw.Flush(); err == nil {
+		err = ferr
+	}
+	return err
+}
+
+// format returns the printed value of a token, or nothing for the values which contain
+// other ones, as those are printed on their own lines.
+func format(t binary.Token) (string, error) {
+	switch t.Schema.Kind {
+	case binary.KindArray, binary.KindSlice, binary.KindMap, binary.KindStruct,
+		binary.KindPointer, binary.KindInterface, binary.KindRLE:
+		return "", nil
+	}
+
+	v, err := t.Value()
+	switch value := v.(type) {
+	case nil:
+		return "", err
+	case []byte:
+		return fmt.Sprintf("%x", value), err
+	case string:
+		return fmt.Sprintf("%q", value), err
+	default:
+		return fmt.Sprint(value), err
+	}
+}
+
+// depth returns the number of values the value at a path is nested in.
+func depth(path string) int {
+	return strings.Count(path, ".") + strings.Count(path, "[")
+}
@@ -0,0 +1,73 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/kelindar/binary"
+	"github.com/stretchr/testify/assert"
+)
+
+type item struct {
+	Name  string
+	Price float64
+}
+
+type order struct {
+	ID    uint32
+	Items []item
+	Note  *string
+}
+
+func TestDump(t *testing.T) {
+	note := "hi"
+	v := &order{ID: 7, Items: []item{{"apple", 1.5}}, Note: &note}
+	expect := []string{
+		"OFFSET  SIZE  PATH                   KIND     TYPE         VALUE",
+		"0       20    (root)                 struct   main.order",
+		"0       1       .ID                  uvarint  uint32       7",
+		"1       15      .Items               slice    []main.item",
+		"2       14        .Items[0]          struct   main.item",
+		"2       6           .Items[0].Name   string   string       \"apple\"",
+		"8       8           .Items[0].Price  float    float64      1.5",
+		"16      4       .Note                pointer  *string",
+		"17      3       .Note                string   string       \"hi\"",
+	}
+
+	schema, err := binary.SchemaOf(reflect.TypeOf(order{}))
+	assert.NoError(t, err)
+	b, err := binary.Marshal(v)
+	assert.NoError(t, err)
+
+	var out bytes.Buffer
+	assert.NoError(t, dump(&out, b, schema, false))
+	assert.Equal(t, expect, lines(out.String()))
+
+	// Values found before a corruption are printed as well
+	out.Reset()
+	assert.Error(t, dump(&out, b[:10], schema, false))
+	assert.Len(t, lines(out.String()), 3)
+	assert.Contains(t, out.String(), ".Items[0].Name")
+}
+
+func TestDump_SelfDescribing(t *testing.T) {
+	b, err := binary.Marshal(&order{ID: 7}, binary.SelfDescribing(), binary.Versioned())
+	assert.NoError(t, err)
+
+	var out bytes.Buffer
+	assert.NoError(t, dump(&out, b, nil, true, binary.SelfDescribing()))
+	assert.Contains(t, out.String(), ".ID     uvarint  uint32       7 07")
+}
+
+// lines splits the output into lines, without their trailing spaces.
+func lines(s string) (out []string) {
+	for _, line := range strings.Split(strings.TrimSpace(s), "\n") {
+		out = append(out, strings.TrimRight(line, " "))
+	}
+	return
+}
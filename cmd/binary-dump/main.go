@@ -0,0 +1,134 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+// Binary-dump prints the structure of an encoded payload, along with the offset, size and
+// value of every value it contains. This helps with debugging corrupt payloads.
+//
+// The schema of the payload is read from its header if it was encoded with the
+// SelfDescribing option. Otherwise, it is either read from a schema file, produced by
+// Schema.MarshalBinary, or taken from a variable of the Go type exported by a plugin:
+//
+//	binary-dump payload.bin
+//	binary-dump -schema order.schema payload.bin
+//	binary-dump -plugin types.so -type Order -versioned payload.bin
+//
+// The payload is read from the standard input if no file is given.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"plugin"
+	"reflect"
+
+	"github.com/kelindar/binary"
+)
+
+var (
+	schemaFile = flag.String("schema", "", "file containing the schema of the payload")
+	pluginFile = flag.String("plugin", "", "plugin exporting a variable of the type of the payload")
+	typeName   = flag.String("type", "", "name of the variable exported by the plugin")
+	versioned  = flag.Bool("versioned", false, "the payload was encoded with the Versioned option")
+	interned   = flag.Bool("interned", false, "the payload was encoded with the InternStrings option")
+	bigEndian  = flag.Bool("big-endian", false, "fixed-width values are in big-endian byte order")
+	unexported = flag.Bool("unexported", false, "unexported fields were encoded")
+	showRaw    = flag.Bool("raw", false, "print the encoded bytes of every value")
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: binary-dump [-schema file | -plugin file -type name] [flags] [payload]\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() > 1 || (*pluginFile == "") != (*typeName == "") {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if err := run(); err != nil {
+		fmt.Fprintln(os.Stderr, "binary-dump:", err)
+		os.Exit(1)
+	}
+}
+
+// run reads the payload and its schema, then dumps it to the standard output.
+func run() error {
+	var payload []byte
+	var err error
+	if flag.NArg() == 1 {
+		payload, err = os.ReadFile(flag.Arg(0))
+	} else {
+		payload, err = io.ReadAll(os.Stdin)
+	}
+	if err != nil {
+		return err
+	}
+
+	opts := options()
+	schema, err := loadSchema(opts)
+	if err != nil {
+		return err
+	}
+
+	// Without a schema, it is read from the header of the payload
+	if schema == nil {
+		opts = append(opts, binary.SelfDescribing())
+	}
+	return dump(os.Stdout, payload, schema, *showRaw, opts...)
+}
+
+// options returns the options of the decoder, as set by the flags.
+func options() []binary.Option {
+	var opts []binary.Option
+	if *versioned {
+		opts = append(opts, binary.Versioned())
+	}
+	if *interned {
+		opts = append(opts, binary.InternStrings())
+	}
+	if *bigEndian {
+		opts = append(opts, binary.ByteOrder(binary.BigEndian))
+	}
+	if *unexported {
+		opts = append(opts, binary.Unexported(binary.UnexportedInclude))
+	}
+	return opts
+}
+
+// loadSchema loads the schema from a file or a plugin, if either is set.
+func loadSchema(opts []binary.Option) (*binary.Schema, error) {
+	switch {
+	case *schemaFile != "":
+		b, err := os.ReadFile(*schemaFile)
+		if err != nil {
+			return nil, err
+		}
+
+		schema := new(binary.Schema)
+		return schema, schema.UnmarshalBinary(b)
+
+	case *pluginFile != "":
+		p, err := plugin.Open(*pluginFile)
+		if err != nil {
+			return nil, err
+		}
+
+		symbol, err := p.Lookup(*typeName)
+		if err != nil {
+			return nil, err
+		}
+
+		// Variables are looked up as pointers to them
+		t := reflect.TypeOf(symbol)
+		if t.Kind() != reflect.Ptr || t.Elem().Kind() == reflect.Func {
+			return nil, fmt.Errorf("%s is not a variable", *typeName)
+		}
+		return binary.SchemaOf(t.Elem(), opts...)
+
+	default:
+		return nil, nil
+	}
+}
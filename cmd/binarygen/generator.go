@@ -0,0 +1,354 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// fieldKind represents the way a field gets encoded
+type fieldKind int
+
+const (
+	kindDelegate  fieldKind = iota // Delegated to the reflection-based codec
+	kindVarint                     // Signed integers
+	kindUvarint                    // Unsigned integers
+	kindFixedInt                   // Signed integers with a `fixed` tag
+	kindFixedUint                  // Unsigned integers with a `fixed` tag
+	kindFloat                      // Floating-point numbers
+	kindBool                       // Booleans
+	kindString                     // Strings
+	kindBytes                      // Byte slices
+	kindNested                     // Structs of the same package with generated methods
+)
+
+// field represents a struct field to generate the code for
+type field struct {
+	Name string    // The name of the field in the declaration
+	Type string    // The name of the type, for the primitive types
+	ID   int       // The identifier of the field, which defines the order
+	Kind fieldKind // The way the field gets encoded
+	Size int       // The size of fixed-width integers, in bytes
+}
+
+// The sizes of the integer types with an explicit size
+var fixedSizes = map[string]int{
+	"int8": 1, "int16": 2, "int32": 4, "rune": 4, "int64": 8,
+	"uint8": 1, "byte": 1, "uint16": 2, "uint32": 4, "uint64": 8,
+}
+
+// generate parses the package in the directory and generates the code of the types.
+func generate(dir string, types []string) ([]byte, error) {
+	pkg, specs, err := parseDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	generated := make(map[string]bool, len(types))
+	for _, name := range types {
+		generated[name] = true
+	}
+
+	var buffer bytes.Buffer
+	fmt.Fprintf(&buffer, "// Code generated by binarygen; DO NOT EDIT.\n\n")
+	fmt.Fprintf(&buffer, "package %s\n\n", pkg)
+	fmt.Fprintf(&buffer, "import (\n\t\"github.com/kelindar/binary\"\n)\n")
+	for _, name := range types {
+		spec, ok := specs[name]
+		if !ok {
+			return nil, errors.New("type " + name + " not found")
+		}
+
+		st, ok := spec.Type.(*ast.StructType)
+		if !ok || spec.TypeParams != nil {
+			return nil, errors.New("type " + name + " is not a non-generic struct")
+		}
+
+		fields, err := scanFields(name, st, generated, specs)
+		if err != nil {
+			return nil, err
+		}
+
+		writeEncoder(&buffer, name, fields)
+		writeDecoder(&buffer, name, fields)
+	}
+
+	return format.Source(buffer.Bytes())
+}
+
+// parseDir parses the non-test files of a directory and returns the name of the package
+// along with the type declarations it contains.
+func parseDir(dir string) (string, map[string]*ast.TypeSpec, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	if err != nil {
+		return "", nil, err
+	}
+
+	pkg := ""
+	fset := token.NewFileSet()
+	specs := make(map[string]*ast.TypeSpec)
+	for _, name := range files {
+		if strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+
+		src, err := os.ReadFile(name)
+		if err != nil {
+			return "", nil, err
+		}
+
+		file, err := parser.ParseFile(fset, name, src, 0)
+		if err != nil {
+			return "", nil, err
+		}
+
+		pkg = file.Name.Name
+		ast.Inspect(file, func(n ast.Node) bool {
+			if spec, ok := n.(*ast.TypeSpec); ok {
+				specs[spec.Name.Name] = spec
+			}
+			return true
+		})
+	}
+
+	if pkg == "" {
+		return "", nil, errors.New("no Go files found in " + dir)
+	}
+
+	return pkg, specs, nil
+}
+
+// scanFields scans the fields of a struct which need to be encoded, following the same
+// rules as the binary package for skipping and ordering the fields.
+func scanFields(typeName string, st *ast.StructType, generated map[string]bool, specs map[string]*ast.TypeSpec) ([]field, error) {
+	var fields []field
+	seen := make(map[int]string)
+	next := 1
+	for _, f := range st.Fields.List {
+		tag := ""
+		if f.Tag != nil {
+			unquoted, err := strconv.Unquote(f.Tag.Value)
+			if err != nil {
+				return nil, err
+			}
+			tag = reflect.StructTag(unquoted).Get("binary")
+		}
+
+		names := f.Names
+		if len(names) == 0 {
+			names = []*ast.Ident{embeddedName(f.Type)}
+		}
+
+		for _, ident := range names {
+			if ident == nil {
+				return nil, errors.New("unsupported embedded field in " + typeName)
+			}
+
+			if ident.Name == "_" || tag == "-" || !isEncoded(ident, len(f.Names) == 0, specs) {
+				continue
+			}
+
+			out, err := scanField(typeName, ident.Name, f.Type, tag, generated)
+			if err != nil {
+				return nil, err
+			}
+
+			if out.ID == 0 {
+				out.ID = next
+			}
+
+			if other, ok := seen[out.ID]; ok {
+				return nil, fmt.Errorf("duplicate id %d on fields %s.%s and %s.%s",
+					out.ID, typeName, other, typeName, ident.Name)
+			}
+
+			seen[out.ID] = ident.Name
+			fields = append(fields, out)
+			next = out.ID + 1
+		}
+	}
+
+	// Order the fields by their identifier
+	sort.SliceStable(fields, func(i, j int) bool {
+		return fields[i].ID < fields[j].ID
+	})
+	return fields, nil
+}
+
+// scanField scans a single field along with the options of its tag.
+func scanField(typeName, name string, expr ast.Expr, tag string, generated map[string]bool) (out field, err error) {
+	out = field{Name: name, Kind: classify(expr, generated)}
+	if ident, ok := expr.(*ast.Ident); ok {
+		out.Type = ident.Name
+	}
+
+	options := strings.Split(tag, ",")[1:]
+	for _, option := range options {
+		switch key, value, _ := strings.Cut(option, "="); key {
+		case "id":
+			if out.ID, err = strconv.Atoi(value); err != nil || out.ID <= 0 {
+				return out, fmt.Errorf("invalid id '%s' on field %s.%s", value, typeName, name)
+			}
+
+		case "fixed":
+			size, ok := fixedSizes[out.Type]
+			switch {
+			case out.Kind == kindFloat:
+			case ok && out.Kind == kindVarint:
+				out.Kind, out.Size = kindFixedInt, size
+			case ok && out.Kind == kindUvarint:
+				out.Kind, out.Size = kindFixedUint, size
+			default:
+				return out, fmt.Errorf("fixed option on field %s.%s is not supported by binarygen", typeName, name)
+			}
+
+		default:
+			return out, fmt.Errorf("option '%s' on field %s.%s is not supported by binarygen", key, typeName, name)
+		}
+	}
+
+	return out, nil
+}
+
+// classify returns the way a field of the type gets encoded.
+func classify(expr ast.Expr, generated map[string]bool) fieldKind {
+	switch t := expr.(type) {
+	case *ast.Ident:
+		switch t.Name {
+		case "int", "int8", "int16", "int32", "int64", "rune":
+			return kindVarint
+		case "uint", "uint8", "uint16", "uint32", "uint64", "byte":
+			return kindUvarint
+		case "float32", "float64":
+			return kindFloat
+		case "bool":
+			return kindBool
+		case "string":
+			return kindString
+		}
+
+		if generated[t.Name] {
+			return kindNested
+		}
+
+	case *ast.ArrayType:
+		if elem, ok := t.Elt.(*ast.Ident); ok && t.Len == nil && (elem.Name == "byte" || elem.Name == "uint8") {
+			return kindBytes
+		}
+	}
+
+	return kindDelegate
+}
+
+// isEncoded returns whether a field is encoded by the binary package, which skips the
+// unexported fields by default. The exported fields of unexported embedded structs are
+// promoted though, which is equivalent to encoding the embedded struct.
+func isEncoded(ident *ast.Ident, embedded bool, specs map[string]*ast.TypeSpec) bool {
+	if ident.IsExported() {
+		return true
+	}
+
+	if spec, ok := specs[ident.Name]; ok && embedded {
+		_, isStruct := spec.Type.(*ast.StructType)
+		return isStruct
+	}
+	return false
+}
+
+// embeddedName returns the name of an embedded field, or nil if not supported.
+func embeddedName(expr ast.Expr) *ast.Ident {
+	switch t := expr.(type) {
+	case *ast.Ident:
+		return t
+	case *ast.StarExpr:
+		return embeddedName(t.X)
+	case *ast.SelectorExpr:
+		return t.Sel
+	default:
+		return nil
+	}
+}
+
+// writeEncoder writes the EncodeBinary method of a type.
+func writeEncoder(w *bytes.Buffer, typeName string, fields []field) {
+	fmt.Fprintf(w, "\n// EncodeBinary encodes the value into the encoder without reflection.\n")
+	fmt.Fprintf(w, "func (v *%s) EncodeBinary(e *binary.Encoder) (err error) {\n", typeName)
+	for _, f := range fields {
+		switch f.Kind {
+		case kindVarint:
+			fmt.Fprintf(w, "e.WriteVarint(int64(v.%s))\n", f.Name)
+		case kindUvarint:
+			fmt.Fprintf(w, "e.WriteUvarint(uint64(v.%s))\n", f.Name)
+		case kindFixedInt, kindFixedUint:
+			fmt.Fprintf(w, "e.WriteUint%d(uint%d(v.%s))\n", f.Size*8, f.Size*8, f.Name)
+		case kindFloat:
+			fmt.Fprintf(w, "e.WriteFloat%s(v.%s)\n", f.Type[len("float"):], f.Name)
+		case kindBool:
+			fmt.Fprintf(w, "e.WriteBool(v.%s)\n", f.Name)
+		case kindString:
+			fmt.Fprintf(w, "e.WriteString(v.%s)\n", f.Name)
+		case kindBytes:
+			fmt.Fprintf(w, "e.WriteBytes(v.%s)\n", f.Name)
+		case kindNested:
+			fmt.Fprintf(w, "if err = v.%s.EncodeBinary(e); err != nil {\nreturn\n}\n", f.Name)
+		default:
+			fmt.Fprintf(w, "if err = e.Encode(&v.%s); err != nil {\nreturn\n}\n", f.Name)
+		}
+	}
+	fmt.Fprintf(w, "return\n}\n")
+}
+
+// writeDecoder writes the DecodeBinary method of a type.
+func writeDecoder(w *bytes.Buffer, typeName string, fields []field) {
+	fmt.Fprintf(w, "\n// DecodeBinary decodes the value from the decoder without reflection.\n")
+	fmt.Fprintf(w, "func (v *%s) DecodeBinary(d *binary.Decoder) (err error) {\n", typeName)
+	for _, f := range fields {
+		switch f.Kind {
+		case kindVarint:
+			writeConverted(w, f, "d.ReadVarint()")
+		case kindUvarint:
+			writeConverted(w, f, "d.ReadUvarint()")
+		case kindFixedInt, kindFixedUint:
+			writeConverted(w, f, fmt.Sprintf("d.ReadUint%d()", f.Size*8))
+		case kindFloat:
+			fmt.Fprintf(w, "if v.%s, err = d.ReadFloat%s(); err != nil {\nreturn\n}\n", f.Name, f.Type[len("float"):])
+		case kindBool:
+			fmt.Fprintf(w, "if v.%s, err = d.ReadBool(); err != nil {\nreturn\n}\n", f.Name)
+		case kindString:
+			fmt.Fprintf(w, "if v.%s, err = d.ReadString(); err != nil {\nreturn\n}\n", f.Name)
+		case kindBytes:
+			fmt.Fprintf(w, "if v.%s, err = d.ReadBytes(); err != nil {\nreturn\n}\n", f.Name)
+		case kindNested:
+			fmt.Fprintf(w, "if err = v.%s.DecodeBinary(d); err != nil {\nreturn\n}\n", f.Name)
+		default:
+			fmt.Fprintf(w, "if err = d.Decode(&v.%s); err != nil {\nreturn\n}\n", f.Name)
+		}
+	}
+	fmt.Fprintf(w, "return\n}\n")
+}
+
+// writeConverted writes the decoding of an integer which needs a conversion. Variable-size
+// integers are checked for overflows, as they may not fit into the type of the field.
+func writeConverted(w *bytes.Buffer, f field, read string) {
+	fmt.Fprintf(w, "if x, err := %s; err != nil {\nreturn err\n}", read)
+	switch {
+	case f.Kind == kindVarint && f.Type != "int64":
+		fmt.Fprintf(w, " else if int64(%s(x)) != x {\nreturn binary.IntOverflowError(x, %q)\n}", f.Type, f.Type)
+	case f.Kind == kindUvarint && f.Type != "uint64":
+		fmt.Fprintf(w, " else if uint64(%s(x)) != x {\nreturn binary.UintOverflowError(x, %q)\n}", f.Type, f.Type)
+	}
+	fmt.Fprintf(w, " else {\nv.%s = %s(x)\n}\n", f.Name, f.Type)
+}
@@ -0,0 +1,48 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenerateSample(t *testing.T) {
+	expect, err := os.ReadFile("internal/sample/sample_binary.go")
+	assert.NoError(t, err)
+
+	src, err := generate("internal/sample", []string{"Message", "Header"})
+	assert.NoError(t, err)
+	assert.Equal(t, string(expect), string(src), "run go generate in internal/sample")
+}
+
+func TestGenerateErrors(t *testing.T) {
+	tests := map[string]string{
+		"missing":  "type A struct{}",
+		"generic":  "type T[K any] struct{ V K }",
+		"alias":    "type T int",
+		"option":   "type T struct{ V string `binary:\",unknown\"` }",
+		"fixed":    "type T struct{ V string `binary:\",fixed\"` }",
+		"fixedint": "type T struct{ V int `binary:\",fixed\"` }",
+		"id":       "type T struct{ V string `binary:\",id=0\"` }",
+		"dup":      "type T struct{ A string `binary:\",id=1\"`; B string `binary:\",id=1\"` }",
+	}
+
+	for name, decl := range tests {
+		dir := t.TempDir()
+		src := "package p\n\n" + decl + "\n"
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, "p.go"), []byte(src), 0644))
+
+		_, err := generate(dir, []string{"T"})
+		assert.Error(t, err, name)
+	}
+}
+
+func TestGenerateEmptyDir(t *testing.T) {
+	_, err := generate(t.TempDir(), []string{"T"})
+	assert.Error(t, err)
+}
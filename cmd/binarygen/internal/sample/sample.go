@@ -0,0 +1,51 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+// Package sample contains the types used to test the code generated by binarygen.
+package sample
+
+import (
+	"time"
+)
+
+//go:generate go run ../.. -type=Message,Header -output=sample_binary.go
+
+// Message is a struct with fields of every kind supported by the generator.
+type Message struct {
+	Header    Header
+	Name      string
+	Payload   []byte
+	Count     int
+	Small     int8
+	Offset    int64
+	Size      uint32
+	Hash      uint64 `binary:",fixed"`
+	Delta     int16  `binary:",fixed"`
+	Flag      byte   `binary:",fixed"`
+	Ratio     float32
+	Score     float64
+	Enabled   bool
+	Timestamp time.Time
+	Tags      map[string]string
+	Values    []float64
+	Next      *Header
+	Ignored   string `binary:"-"`
+	hidden    string
+}
+
+// Header is a struct which is nested in the message and reorders its fields.
+type Header struct {
+	routing `binary:",id=4"`
+	Version uint16 `binary:",id=2"`
+	Kind    Kind   `binary:",id=1"`
+	Trace   []byte `binary:",id=3"`
+}
+
+// Kind is a named integer type, which is delegated to the reflection-based codec.
+type Kind int
+
+// routing is an unexported struct embedded in the header, whose fields get promoted.
+type routing struct {
+	Topic string
+	cache []byte
+}
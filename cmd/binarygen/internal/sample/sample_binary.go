@@ -0,0 +1,149 @@
+// Code generated by binarygen; DO NOT EDIT.
+
+package sample
+
+import (
+	"github.com/kelindar/binary"
+)
+
+// EncodeBinary encodes the value into the encoder without reflection.
+func (v *Message) EncodeBinary(e *binary.Encoder) (err error) {
+	if err = v.Header.EncodeBinary(e); err != nil {
+		return
+	}
+	e.WriteString(v.Name)
+	e.WriteBytes(v.Payload)
+	e.WriteVarint(int64(v.Count))
+	e.WriteVarint(int64(v.Small))
+	e.WriteVarint(int64(v.Offset))
+	e.WriteUvarint(uint64(v.Size))
+	e.WriteUint64(uint64(v.Hash))
+	e.WriteUint16(uint16(v.Delta))
+	e.WriteUint8(uint8(v.Flag))
+	e.WriteFloat32(v.Ratio)
+	e.WriteFloat64(v.Score)
+	e.WriteBool(v.Enabled)
+	if err = e.Encode(&v.Timestamp); err != nil {
+		return
+	}
+	if err = e.Encode(&v.Tags); err != nil {
+		return
+	}
+	if err = e.Encode(&v.Values); err != nil {
+		return
+	}
+	if err = e.Encode(&v.Next); err != nil {
+		return
+	}
+	return
+}
+
+// DecodeBinary decodes the value from the decoder without reflection.
+func (v *Message) DecodeBinary(d *binary.Decoder) (err error) {
+	if err = v.Header.DecodeBinary(d); err != nil {
+		return
+	}
+	if v.Name, err = d.ReadString(); err != nil {
+		return
+	}
+	if v.Payload, err = d.ReadBytes(); err != nil {
+		return
+	}
+	if x, err := d.ReadVarint(); err != nil {
+		return err
+	} else if int64(int(x)) != x {
+		return binary.IntOverflowError(x, "int")
+	} else {
+		v.Count = int(x)
+	}
+	if x, err := d.ReadVarint(); err != nil {
+		return err
+	} else if int64(int8(x)) != x {
+		return binary.IntOverflowError(x, "int8")
+	} else {
+		v.Small = int8(x)
+	}
+	if x, err := d.ReadVarint(); err != nil {
+		return err
+	} else {
+		v.Offset = int64(x)
+	}
+	if x, err := d.ReadUvarint(); err != nil {
+		return err
+	} else if uint64(uint32(x)) != x {
+		return binary.UintOverflowError(x, "uint32")
+	} else {
+		v.Size = uint32(x)
+	}
+	if x, err := d.ReadUint64(); err != nil {
+		return err
+	} else {
+		v.Hash = uint64(x)
+	}
+	if x, err := d.ReadUint16(); err != nil {
+		return err
+	} else {
+		v.Delta = int16(x)
+	}
+	if x, err := d.ReadUint8(); err != nil {
+		return err
+	} else {
+		v.Flag = byte(x)
+	}
+	if v.Ratio, err = d.ReadFloat32(); err != nil {
+		return
+	}
+	if v.Score, err = d.ReadFloat64(); err != nil {
+		return
+	}
+	if v.Enabled, err = d.ReadBool(); err != nil {
+		return
+	}
+	if err = d.Decode(&v.Timestamp); err != nil {
+		return
+	}
+	if err = d.Decode(&v.Tags); err != nil {
+		return
+	}
+	if err = d.Decode(&v.Values); err != nil {
+		return
+	}
+	if err = d.Decode(&v.Next); err != nil {
+		return
+	}
+	return
+}
+
+// EncodeBinary encodes the value into the encoder without reflection.
+func (v *Header) EncodeBinary(e *binary.Encoder) (err error) {
+	if err = e.Encode(&v.Kind); err != nil {
+		return
+	}
+	e.WriteUvarint(uint64(v.Version))
+	e.WriteBytes(v.Trace)
+	if err = e.Encode(&v.routing); err != nil {
+		return
+	}
+	return
+}
+
+// DecodeBinary decodes the value from the decoder without reflection.
+func (v *Header) DecodeBinary(d *binary.Decoder) (err error) {
+	if err = d.Decode(&v.Kind); err != nil {
+		return
+	}
+	if x, err := d.ReadUvarint(); err != nil {
+		return err
+	} else if uint64(uint16(x)) != x {
+		return binary.UintOverflowError(x, "uint16")
+	} else {
+		v.Version = uint16(x)
+	}
+	if v.Trace, err = d.ReadBytes(); err != nil {
+		return
+	}
+	if err = d.Decode(&v.routing); err != nil {
+		return
+	}
+	return
+}
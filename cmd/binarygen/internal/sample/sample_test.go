@@ -0,0 +1,126 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package sample
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/kelindar/binary"
+	"github.com/stretchr/testify/assert"
+)
+
+// plainMessage has the same fields as the message, but no generated methods
+type plainMessage Message
+
+func newMessage() *Message {
+	return &Message{
+		Header:    Header{routing: routing{Topic: "a/b"}, Version: 3, Kind: 7, Trace: []byte{1, 2}},
+		Name:      "Roman",
+		Payload:   []byte("hello"),
+		Count:     -42,
+		Small:     -8,
+		Offset:    1 << 40,
+		Size:      300,
+		Hash:      0xdeadbeefcafe,
+		Delta:     -2,
+		Flag:      0xff,
+		Ratio:     1.5,
+		Score:     -2.25,
+		Enabled:   true,
+		Timestamp: time.Date(2013, 1, 2, 3, 4, 5, 6, time.UTC),
+		Tags:      map[string]string{"a": "b"},
+		Values:    []float64{1, 2, 3},
+		Next:      &Header{Version: 1},
+	}
+}
+
+func TestGeneratedMatchesReflection(t *testing.T) {
+	v := newMessage()
+	b, err := binary.Marshal(v)
+	assert.NoError(t, err)
+
+	expect, err := binary.Marshal((*plainMessage)(v))
+	assert.NoError(t, err)
+	assert.Equal(t, expect, b)
+
+	size, err := binary.Size(v)
+	assert.NoError(t, err)
+	assert.Equal(t, len(b), size)
+
+	var out Message
+	assert.NoError(t, binary.Unmarshal(b, &out))
+	assert.Equal(t, v, &out)
+
+	var plain plainMessage
+	assert.NoError(t, binary.Unmarshal(b, &plain))
+	assert.Equal(t, v, (*Message)(&plain))
+}
+
+func TestGeneratedVersioned(t *testing.T) {
+	v := newMessage()
+	b, err := binary.Marshal(v, binary.Versioned())
+	assert.NoError(t, err)
+
+	var out Message
+	assert.NoError(t, binary.Unmarshal(b, &out, binary.Versioned()))
+	assert.Equal(t, v, &out)
+}
+
+func TestGeneratedLimits(t *testing.T) {
+	b, err := binary.Marshal(newMessage())
+	assert.NoError(t, err)
+
+	var out Message
+	assert.Error(t, binary.Unmarshal(b, &out, binary.MaxStringLen(2)))
+	assert.Error(t, binary.Unmarshal(b[:len(b)-1], &out))
+}
+
+func TestGeneratedUnexported(t *testing.T) {
+	v := newMessage()
+	v.hidden = "secret"
+	v.Header.cache = []byte{1}
+
+	b, err := binary.Marshal(v)
+	assert.NoError(t, err)
+
+	var out Message
+	assert.NoError(t, binary.Unmarshal(b, &out))
+	assert.Equal(t, "", out.hidden)
+	assert.Nil(t, out.Header.cache)
+	assert.Equal(t, "a/b", out.Header.Topic)
+
+	// Including the unexported fields falls back to reflection
+	b, err = binary.Marshal(v, binary.Unexported(binary.UnexportedInclude))
+	assert.NoError(t, err)
+	assert.NoError(t, binary.Unmarshal(b, &out, binary.Unexported(binary.UnexportedInclude)))
+	assert.Equal(t, v, &out)
+}
+
+func TestGeneratedOverflow(t *testing.T) {
+	type wide struct {
+		Header  Header
+		Name    string
+		Payload []byte
+		Count   int
+		Small   int64
+	}
+
+	b, err := binary.Marshal(&wide{Small: 200})
+	assert.NoError(t, err)
+
+	var out Message
+	err = binary.Unmarshal(b, &out)
+	assert.True(t, errors.Is(err, binary.ErrOverflow))
+}
+
+func TestGeneratedFields(t *testing.T) {
+	b, err := binary.Marshal(newMessage())
+	assert.NoError(t, err)
+
+	var out Message
+	assert.NoError(t, binary.Unmarshal(b, &out, binary.Fields("Header.Version", "Score")))
+	assert.Equal(t, Message{Header: Header{Version: 3}, Score: -2.25}, out)
+}
@@ -0,0 +1,62 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+// Binarygen generates EncodeBinary and DecodeBinary methods for structs, which the
+// binary package prefers over its reflection-based codecs. The generated code produces
+// the same bytes as the reflection-based codecs, so both can be used interchangeably.
+//
+// It is meant to be used with go:generate, for example:
+//
+//	//go:generate binarygen -type=Message,Header
+//
+// Fields of primitive types, strings, byte slices and other generated structs of the
+// same package are encoded without reflection, while the remaining fields are delegated
+// to the reflection-based codecs of the binary package.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	typeNames = flag.String("type", "", "comma-separated list of struct type names; must be set")
+	output    = flag.String("output", "", "output file name; default <type>_binary.go")
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: binarygen -type T[,T...] [-output file] [directory]\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if *typeNames == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	dir := "."
+	if flag.NArg() > 0 {
+		dir = flag.Arg(0)
+	}
+
+	types := strings.Split(*typeNames, ",")
+	name := *output
+	if name == "" {
+		name = strings.ToLower(types[0]) + "_binary.go"
+	}
+
+	src, err := generate(dir, types)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "binarygen:", err)
+		os.Exit(1)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, name), src, 0644); err != nil {
+		fmt.Fprintln(os.Stderr, "binarygen:", err)
+		os.Exit(1)
+	}
+}
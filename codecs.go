@@ -4,9 +4,13 @@
 package binary
 
 import (
+	"encoding"
 	"encoding/binary"
 	"errors"
 	"reflect"
+	"sort"
+	"sync"
+	"unsafe"
 )
 
 // Constants
@@ -21,6 +25,34 @@ type Codec interface {
 	DecodeTo(*Decoder, reflect.Value) error
 }
 
+// Sizer represents a codec which can compute the encoded size of a value without
+// actually encoding it. A negative size means that the size can not be computed
+// up front, in which case the value needs to be encoded in order to be measured.
+type Sizer interface {
+	Size(reflect.Value) int
+}
+
+// sizeOf returns the encoded size of a value, or -1 if the codec is unable to
+// compute it without encoding.
+func sizeOf(c Codec, rv reflect.Value) int {
+	if s, ok := c.(Sizer); ok {
+		return s.Size(rv)
+	}
+	return -1
+}
+
+// sizeOfElements returns the encoded size of all of the elements of a slice or an array.
+func sizeOfElements(c Codec, rv reflect.Value) (size int) {
+	for i := 0; i < rv.Len(); i++ {
+		n := sizeOf(c, rv.Index(i))
+		if n < 0 {
+			return -1
+		}
+		size += n
+	}
+	return
+}
+
 // ------------------------------------------------------------------------------
 
 type reflectArrayCodec struct {
@@ -31,8 +63,10 @@ type reflectArrayCodec struct {
 func (c *reflectArrayCodec) EncodeTo(e *Encoder, rv reflect.Value) (err error) {
 	l := rv.Type().Len()
 	for i := 0; i < l; i++ {
-		v := reflect.Indirect(rv.Index(i).Addr())
-		if err = c.elemCodec.EncodeTo(e, v); err != nil {
+		if err = e.opts().canceled(i); err != nil {
+			return
+		}
+		if err = c.elemCodec.EncodeTo(e, rv.Index(i)); err != nil {
 			return
 		}
 	}
@@ -41,16 +75,28 @@ func (c *reflectArrayCodec) EncodeTo(e *Encoder, rv reflect.Value) (err error) {
 
 // Decode decodes into a reflect value from the decoder.
 func (c *reflectArrayCodec) DecodeTo(d *Decoder, rv reflect.Value) (err error) {
+	if err = d.enter(); err != nil {
+		return
+	}
+	defer d.leave()
+
 	l := rv.Type().Len()
 	for i := 0; i < l; i++ {
-		v := reflect.Indirect(rv.Index(i))
-		if err = c.elemCodec.DecodeTo(d, v); err != nil {
+		if err = d.opts.canceled(i); err != nil {
+			return
+		}
+		if err = c.elemCodec.DecodeTo(d, rv.Index(i)); err != nil {
 			return
 		}
 	}
 	return
 }
 
+// Size returns the encoded size of the value.
+func (c *reflectArrayCodec) Size(rv reflect.Value) int {
+	return sizeOfElements(c.elemCodec, rv)
+}
+
 // ------------------------------------------------------------------------------
 
 type reflectSliceCodec struct {
@@ -60,8 +106,19 @@ type reflectSliceCodec struct {
 // Encode encodes a value into the encoder.
 func (c *reflectSliceCodec) EncodeTo(e *Encoder, rv reflect.Value) (err error) {
 	l := rv.Len()
+	if l > 0 {
+		if err = e.follow(rv); err != nil {
+			return
+		}
+		defer e.unfollow(rv)
+	}
+
 	e.WriteUvarint(uint64(l))
 	for i := 0; i < l; i++ {
+		if err = e.opts().canceled(i); err != nil {
+			return
+		}
+
 		v := reflect.Indirect(rv.Index(i).Addr())
 		if err = c.elemCodec.EncodeTo(e, v); err != nil {
 			return
@@ -72,12 +129,27 @@ func (c *reflectSliceCodec) EncodeTo(e *Encoder, rv reflect.Value) (err error) {
 
 // Decode decodes into a reflect value from the decoder.
 func (c *reflectSliceCodec) DecodeTo(d *Decoder, rv reflect.Value) (err error) {
-	var l uint64
-	if l, err = binary.ReadUvarint(d.r); err == nil && l > 0 {
-		rv.Set(reflect.MakeSlice(rv.Type(), int(l), int(l)))
-		for i := 0; i < int(l); i++ {
-			v := reflect.Indirect(rv.Index(i))
-			if err = c.elemCodec.DecodeTo(d, v); err != nil {
+	if err = d.enter(); err != nil {
+		return
+	}
+	defer d.leave()
+
+	var l int
+	if l, err = d.readSliceLen(); err != nil {
+		return
+	}
+
+	var slice reflect.Value
+	if slice, err = d.makeSlice(rv, l); err == nil {
+		rv.Set(slice)
+		for i := 0; i < l; i++ {
+			if err = d.opts.canceled(i); err != nil {
+				return
+			}
+			if i == rv.Len() {
+				rv.Set(growSlice(rv, l))
+			}
+			if err = c.elemCodec.DecodeTo(d, rv.Index(i)); err != nil {
 				return
 			}
 		}
@@ -85,30 +157,187 @@ func (c *reflectSliceCodec) DecodeTo(d *Decoder, rv reflect.Value) (err error) {
 	return
 }
 
+// Size returns the encoded size of the value.
+func (c *reflectSliceCodec) Size(rv reflect.Value) int {
+	if n := sizeOfElements(c.elemCodec, rv); n >= 0 {
+		return uvarintSize(uint64(rv.Len())) + n
+	}
+	return -1
+}
+
+// ------------------------------------------------------------------------------
+
+type reflectPointerCodec struct {
+	elemType  reflect.Type // The type of the element pointed to
+	elemCodec Codec        // The codec of the element, scanned lazily to support recursive types
+	once      sync.Once
+	err       error
+}
+
+// Encode encodes a value into the encoder.
+func (c *reflectPointerCodec) EncodeTo(e *Encoder, rv reflect.Value) (err error) {
+	if rv.IsNil() {
+		e.WriteBool(false)
+		return
+	}
+
+	var codec Codec
+	if codec, err = c.codec(); err != nil {
+		return
+	}
+	if e.opts().shared && e.writeShared(rv) {
+		return
+	}
+	if err = e.follow(rv); err != nil {
+		return
+	}
+
+	defer e.unfollow(rv)
+	e.WriteBool(true)
+	return codec.EncodeTo(e, rv.Elem())
+}
+
+// Decode decodes into a reflect value from the decoder.
+func (c *reflectPointerCodec) DecodeTo(d *Decoder, rv reflect.Value) (err error) {
+	var present byte
+	if present, err = d.r.ReadByte(); err != nil {
+		return
+	}
+
+	switch present {
+	case 0:
+		rv.Set(reflect.Zero(rv.Type()))
+		return nil
+	case 1:
+		var codec Codec
+		if codec, err = c.codec(); err != nil {
+			return
+		}
+
+		if rv.IsNil() || d.opts.freshPointers {
+			if err = d.allocate(1, c.elemType.Size()); err != nil {
+				return
+			}
+			rv.Set(d.newPointer(c.elemType))
+		}
+		if d.opts.shared {
+			d.pointers = append(d.pointers, rv.Elem().Addr())
+		}
+		return codec.DecodeTo(d, rv.Elem())
+	case sharedPointer:
+		return d.readShared(rv)
+	default:
+		return errors.New("binary: invalid presence byte for " + rv.Type().String())
+	}
+}
+
+// Size returns the encoded size of the value.
+func (c *reflectPointerCodec) Size(rv reflect.Value) int {
+	if rv.IsNil() {
+		return 1
+	}
+
+	codec, err := c.codec()
+	if err != nil {
+		return -1
+	}
+
+	if n := sizeOf(codec, rv.Elem()); n >= 0 {
+		return 1 + n
+	}
+	return -1
+}
+
+// codec returns the codec of the element.
+func (c *reflectPointerCodec) codec() (Codec, error) {
+	c.once.Do(func() {
+		c.elemCodec, c.err = scan(c.elemType)
+	})
+	return c.elemCodec, c.err
+}
+
 // ------------------------------------------------------------------------------
 
 type byteSliceCodec struct{}
 
 // Encode encodes a value into the encoder.
 func (c *byteSliceCodec) EncodeTo(e *Encoder, rv reflect.Value) (err error) {
-	b := rv.Bytes()
-	e.WriteUvarint(uint64(len(b)))
-	e.Write(b)
+	e.WriteLengthPrefixed(rv.Bytes())
 	return
 }
 
 // Decode decodes into a reflect value from the decoder.
-func (c *byteSliceCodec) DecodeTo(d *Decoder, rv reflect.Value) (err error) {
-	var l uint64
-	if l, err = d.ReadUvarint(); err == nil && l > 0 {
-		data := make([]byte, int(l), int(l))
-		if _, err = d.Read(data); err == nil {
-			rv.Set(reflect.ValueOf(data))
+func (c *byteSliceCodec) DecodeTo(d *Decoder, rv reflect.Value) error {
+	out := rv.Bytes()
+	err := d.readBytesTo(&out)
+	rv.SetBytes(out)
+	return err
+}
+
+// readBytesTo reads a byte slice into the destination. Unless the ZeroCopy option is set,
+// the bytes are decoded into the backing array of the destination if it has the capacity
+// for them, and a new one is allocated otherwise.
+func (d *Decoder) readBytesTo(dst *[]byte) (err error) {
+	if d.opts.zeroCopy || cap(*dst) == 0 {
+		var data []byte
+		if data, err = d.ReadBytes(); err == nil && data != nil {
+			*dst = data
+		}
+		return
+	}
+
+	var l int
+	if l, err = d.readSliceLen(); err != nil {
+		return
+	}
+
+	if l <= cap(*dst) {
+		*dst = (*dst)[:l]
+		_, err = d.Read(*dst)
+		return
+	}
+
+	var data []byte
+	if err = d.allocate(l, 1); err == nil {
+		if data, err = d.readCopy(l); err == nil {
+			*dst = data
 		}
 	}
 	return
 }
 
+// Size returns the encoded size of the value.
+func (c *byteSliceCodec) Size(rv reflect.Value) int {
+	return uvarintSize(uint64(rv.Len())) + rv.Len()
+}
+
+// ------------------------------------------------------------------------------
+
+// byteArrayCodec represents a codec for byte arrays, such as UUIDs, which are copied as
+// they are. As their length is part of the type, it is not encoded.
+type byteArrayCodec struct{}
+
+// Encode encodes a value into the encoder.
+func (c *byteArrayCodec) EncodeTo(e *Encoder, rv reflect.Value) (err error) {
+	if !rv.CanAddr() {
+		rv = reflect.ValueOf(addrOf(rv)).Elem() // Only addressable arrays can be sliced
+	}
+
+	e.Write(rv.Bytes())
+	return
+}
+
+// Decode decodes into a reflect value from the decoder.
+func (c *byteArrayCodec) DecodeTo(d *Decoder, rv reflect.Value) (err error) {
+	_, err = d.Read(rv.Bytes())
+	return
+}
+
+// Size returns the encoded size of the value.
+func (c *byteArrayCodec) Size(rv reflect.Value) int {
+	return rv.Len()
+}
+
 // ------------------------------------------------------------------------------
 
 type boolSliceCodec struct{}
@@ -126,39 +355,80 @@ func (c *boolSliceCodec) EncodeTo(e *Encoder, rv reflect.Value) (err error) {
 
 // Decode decodes into a reflect value from the decoder.
 func (c *boolSliceCodec) DecodeTo(d *Decoder, rv reflect.Value) (err error) {
-	var l uint64
-	if l, err = d.ReadUvarint(); err == nil && l > 0 {
-		buf := make([]byte, l)
-		_, err = d.r.Read(buf)
-		rv.Set(reflect.ValueOf(binaryToBools(&buf)))
+	var l int
+	if l, err = d.readSliceLen(); err != nil {
+		return
+	}
+
+	// Read into the destination when it has the capacity for the bools
+	if l <= rv.Cap() {
+		rv.Set(rv.Slice(0, l))
+		_, err = d.Read(unsafe.Slice((*byte)(rv.UnsafePointer()), l))
+		return
+	}
+
+	var buf []byte
+	if err = d.allocate(l, 1); err == nil {
+		if buf, err = d.readCopy(l); err == nil {
+			rv.Set(reflect.ValueOf(binaryToBools(&buf)))
+		}
 	}
 	return
 }
 
+// Size returns the encoded size of the value.
+func (c *boolSliceCodec) Size(rv reflect.Value) int {
+	return uvarintSize(uint64(rv.Len())) + rv.Len()
+}
+
 // ------------------------------------------------------------------------------
 
 type varintSliceCodec struct{}
 
 // Encode encodes a value into the encoder.
-func (c *varintSliceCodec) EncodeTo(e *Encoder, rv reflect.Value) (err error) {
-	l := rv.Len()
-	e.WriteUvarint(uint64(l))
-	for i := 0; i < l; i++ {
-		e.WriteVarint(rv.Index(i).Int())
+func (c *varintSliceCodec) EncodeTo(e *Encoder, rv reflect.Value) error {
+	e.WriteUvarint(uint64(rv.Len()))
+	switch rv.Type().Elem().Kind() {
+	case reflect.Int:
+		return writeVarints(e, sliceOf[int](rv))
+	case reflect.Int8:
+		return writeVarints(e, sliceOf[int8](rv))
+	case reflect.Int16:
+		return writeVarints(e, sliceOf[int16](rv))
+	case reflect.Int32:
+		return writeVarints(e, sliceOf[int32](rv))
+	default:
+		return writeVarints(e, sliceOf[int64](rv))
 	}
-	return
 }
 
 // Decode decodes into a reflect value from the decoder.
 func (c *varintSliceCodec) DecodeTo(d *Decoder, rv reflect.Value) (err error) {
-	var l uint64
-	if l, err = binary.ReadUvarint(d.r); err == nil && l > 0 {
-		slice := reflect.MakeSlice(rv.Type(), int(l), int(l))
-		for i := 0; i < int(l); i++ {
+	var l int
+	if l, err = d.readSliceLen(); err != nil {
+		return
+	}
+
+	var slice reflect.Value
+	if slice, err = d.makeSlice(rv, l); err == nil {
+		for i := 0; i < l; i++ {
+			if err = d.opts.canceled(i); err != nil {
+				return
+			}
+
 			var v int64
-			if v, err = binary.ReadVarint(d.r); err == nil {
-				slice.Index(i).SetInt(v)
+			if v, err = d.ReadVarint(); err != nil {
+				return
+			}
+			if i == slice.Len() {
+				slice = growSlice(slice, l)
+			}
+
+			elem := slice.Index(i)
+			if elem.OverflowInt(v) {
+				return IntOverflowError(v, elem.Type().String())
 			}
+			elem.SetInt(v)
 		}
 
 		rv.Set(slice)
@@ -166,29 +436,69 @@ func (c *varintSliceCodec) DecodeTo(d *Decoder, rv reflect.Value) (err error) {
 	return
 }
 
+// Size returns the encoded size of the value.
+func (c *varintSliceCodec) Size(rv reflect.Value) int {
+	size := uvarintSize(uint64(rv.Len()))
+	switch rv.Type().Elem().Kind() {
+	case reflect.Int:
+		return size + varintsSize(sliceOf[int](rv))
+	case reflect.Int8:
+		return size + varintsSize(sliceOf[int8](rv))
+	case reflect.Int16:
+		return size + varintsSize(sliceOf[int16](rv))
+	case reflect.Int32:
+		return size + varintsSize(sliceOf[int32](rv))
+	default:
+		return size + varintsSize(sliceOf[int64](rv))
+	}
+}
+
 // ------------------------------------------------------------------------------
 
 type varuintSliceCodec struct{}
 
 // Encode encodes a value into the encoder.
-func (c *varuintSliceCodec) EncodeTo(e *Encoder, rv reflect.Value) (err error) {
-	l := rv.Len()
-	e.WriteUvarint(uint64(l))
-	for i := 0; i < l; i++ {
-		e.WriteUvarint(rv.Index(i).Uint())
+func (c *varuintSliceCodec) EncodeTo(e *Encoder, rv reflect.Value) error {
+	e.WriteUvarint(uint64(rv.Len()))
+	switch rv.Type().Elem().Kind() {
+	case reflect.Uint:
+		return writeUvarints(e, sliceOf[uint](rv))
+	case reflect.Uint16:
+		return writeUvarints(e, sliceOf[uint16](rv))
+	case reflect.Uint32:
+		return writeUvarints(e, sliceOf[uint32](rv))
+	default:
+		return writeUvarints(e, sliceOf[uint64](rv))
 	}
-	return
 }
 
 // Decode decodes into a reflect value from the decoder.
 func (c *varuintSliceCodec) DecodeTo(d *Decoder, rv reflect.Value) (err error) {
-	var l, v uint64
-	if l, err = binary.ReadUvarint(d.r); err == nil && l > 0 {
-		slice := reflect.MakeSlice(rv.Type(), int(l), int(l))
-		for i := 0; i < int(l); i++ {
-			if v, err = d.ReadUvarint(); err == nil {
-				slice.Index(i).SetUint(v)
+	var l int
+	if l, err = d.readSliceLen(); err != nil {
+		return
+	}
+
+	var slice reflect.Value
+	if slice, err = d.makeSlice(rv, l); err == nil {
+		for i := 0; i < l; i++ {
+			if err = d.opts.canceled(i); err != nil {
+				return
 			}
+
+			var v uint64
+			if v, err = d.ReadUvarint(); err != nil {
+				return
+			}
+			if i == slice.Len() {
+				slice = growSlice(slice, l)
+			}
+
+			elem := slice.Index(i)
+			if elem.OverflowUint(v) {
+				return UintOverflowError(v, elem.Type().String())
+			}
+			elem.SetUint(v)
 		}
 
 		rv.Set(slice)
@@ -196,20 +506,143 @@ func (c *varuintSliceCodec) DecodeTo(d *Decoder, rv reflect.Value) (err error) {
 	return
 }
 
+// Size returns the encoded size of the value.
+func (c *varuintSliceCodec) Size(rv reflect.Value) int {
+	size := uvarintSize(uint64(rv.Len()))
+	switch rv.Type().Elem().Kind() {
+	case reflect.Uint:
+		return size + uvarintsSize(sliceOf[uint](rv))
+	case reflect.Uint16:
+		return size + uvarintsSize(sliceOf[uint16](rv))
+	case reflect.Uint32:
+		return size + uvarintsSize(sliceOf[uint32](rv))
+	default:
+		return size + uvarintsSize(sliceOf[uint64](rv))
+	}
+}
+
 // ------------------------------------------------------------------------------
 
 type reflectStructCodec []fieldCodec
 
 type fieldCodec struct {
-	Index int   // The index of the field
-	Codec Codec // The codec to use for this field
+	Index      int           // The index of the field
+	Path       []int         // The index sequence of a field promoted from an embedded struct
+	Name       string        // The name of the field
+	JSON       jsonTag       // The json tag of the field, honored with the JSONTags option
+	ID         int           // The identifier of the field
+	Codec      Codec         // The codec to use for this field, nil if the field is unsupported
+	Unexported bool          // Whether the field is unexported
+	OmitEmpty  bool          // Whether the field is only encoded when it is not zero
+	Default    reflect.Value // The value of the field when it is missing from a versioned payload, if any
+	err        error         // The error encountered while scanning an unexported field
+}
+
+// field returns the value of the field within the struct.
+func (f *fieldCodec) field(rv reflect.Value) reflect.Value {
+	if f.Path != nil {
+		return rv.FieldByIndex(f.Path)
+	}
+	return rv.Field(f.Index)
+}
+
+// fieldType returns the type of the field within the struct type.
+func (f *fieldCodec) fieldType(t reflect.Type) reflect.Type {
+	if f.Path != nil {
+		return t.FieldByIndex(f.Path).Type
+	}
+	return t.Field(f.Index).Type
+}
+
+// name returns the name of the field, which is the name of its json tag if the JSONTags
+// option is used.
+func (f *fieldCodec) name(o *options) string {
+	if o.jsonTags && f.JSON.Name != "" {
+		return f.JSON.Name
+	}
+	return f.Name
+}
+
+// access returns the value of the field within an addressable struct, taking the policy
+// for unexported fields into account. It returns false if the field must be skipped.
+func (f *fieldCodec) access(rv reflect.Value, o *options) (reflect.Value, bool, error) {
+	v := f.field(rv)
+	ok, err := f.encoded(o, rv.Type())
+	if ok && f.Unexported {
+		v = reflect.NewAt(v.Type(), unsafe.Pointer(v.UnsafeAddr())).Elem()
+	}
+	return v, ok, err
+}
+
+// encoded returns whether the field is encoded, taking the policy for unexported fields
+// and the json tag into account.
+func (f *fieldCodec) encoded(o *options, t reflect.Type) (bool, error) {
+	switch {
+	case o.jsonTags && f.JSON.Skip:
+		return false, nil
+	case !f.Unexported:
+		return true, nil
+	}
+
+	switch o.unexported {
+	case UnexportedInclude:
+		return f.err == nil, f.err
+	case UnexportedError:
+		return false, errors.New("binary: unexported field " + f.Name + " of " + t.String())
+	default:
+		return false, nil
+	}
+}
+
+// hasUnexported returns whether the struct has unexported fields.
+func (c *reflectStructCodec) hasUnexported() bool {
+	for _, i := range *c {
+		if i.Unexported {
+			return true
+		}
+	}
+	return false
 }
 
 // Encode encodes a value into the encoder.
 func (c *reflectStructCodec) EncodeTo(e *Encoder, rv reflect.Value) (err error) {
+	if e.opts().unexported == UnexportedInclude && !rv.CanAddr() && c.hasUnexported() {
+		ptr := reflect.New(rv.Type()) // Unexported fields can only be accessed by address
+		ptr.Elem().Set(rv)
+		rv = ptr.Elem()
+	}
+
+	if e.opts().versioned {
+		return c.encodeVersioned(e, rv)
+	}
+
+	var buffer [8]byte
+	var bits []byte
+	if bits, err = c.presence(rv, e.opts(), buffer[:]); err != nil {
+		return
+	}
+	e.writePresence(bits)
+
+	n := 0
 	for _, i := range *c {
-		if err = i.Codec.EncodeTo(e, rv.Field(i.Index)); err != nil {
-			return
+		if err := e.opts().canceled(0); err != nil {
+			return err
+		}
+
+		v, ok, err := i.access(rv, e.opts())
+		switch {
+		case err != nil:
+			return err
+		case !ok:
+			continue
+		case i.OmitEmpty:
+			if n++; !present(bits, n-1) {
+				continue
+			}
+		}
+
+		if err = i.Codec.EncodeTo(e, v); err != nil {
+			return err
 		}
 	}
 	return
@@ -217,89 +650,267 @@ func (c *reflectStructCodec) EncodeTo(e *Encoder, rv reflect.Value) (err error)
 
 // Decode decodes into a reflect value from the decoder.
 func (c *reflectStructCodec) DecodeTo(d *Decoder, rv reflect.Value) (err error) {
+	if err = d.enter(); err != nil {
+		return
+	}
+	defer d.leave()
+
+	if d.opts.versioned {
+		return c.decodeVersioned(d, rv)
+	}
+
+	var buffer [8]byte
+	var bits []byte
+	var omitted int
+	if omitted, err = c.omitted(&d.opts, rv.Type()); err != nil {
+		return
+	}
+	if bits, err = d.readPresence(omitted, buffer[:]); err != nil {
+		return
+	}
+
+	n := 0
+	project := d.project
 	for _, i := range *c {
-		if v := rv.Field(i.Index); v.CanSet() {
-			if err = i.Codec.DecodeTo(d, reflect.Indirect(v)); err != nil {
-				return
+		if err := d.opts.canceled(0); err != nil {
+			return err
+		}
+
+		v, ok, err := i.access(rv, &d.opts)
+		if err != nil || !ok {
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		sub, selected := project.selects(i.name(&d.opts))
+		if i.OmitEmpty {
+			if n++; !present(bits, n-1) {
+				if selected && !d.opts.merge {
+					v.Set(reflect.Zero(v.Type()))
+				}
+				continue
+			}
+		}
+
+		if !selected {
+			if err = d.skip(i.Codec, v.Type()); err != nil {
+				return err
 			}
+			continue
+		}
+
+		d.project = sub
+		if err = i.Codec.DecodeTo(d, v); err != nil {
+			return err
 		}
 	}
+
+	d.project = project
 	return
 }
 
-// ------------------------------------------------------------------------------
+// Size returns the encoded size of the value, with the unexported fields skipped.
+func (c *reflectStructCodec) Size(rv reflect.Value) (size int) {
+	omitted := 0
+	for _, i := range *c {
+		if i.Unexported {
+			continue
+		}
 
-// customCodec represents a custom binary marshaling.
-type customCodec struct {
-	marshaler      *reflect.Method
-	unmarshaler    *reflect.Method
-	ptrMarshaler   *reflect.Method
-	ptrUnmarshaler *reflect.Method
-}
+		v := i.field(rv)
+		if i.OmitEmpty {
+			if omitted++; v.IsZero() {
+				continue
+			}
+		}
 
-// Encode encodes a value into the encoder.
-func (c *customCodec) EncodeTo(e *Encoder, rv reflect.Value) (err error) {
-	m := c.GetMarshalBinary(rv)
-	if m == nil {
-		return errors.New("MarshalBinary not found on " + rv.Type().String())
+		n := sizeOf(i.Codec, v)
+		if n < 0 {
+			return -1
+		}
+		size += n
 	}
+	return size + (omitted+7)/8
+}
 
-	ret := m.Call([]reflect.Value{})
-	if !ret[1].IsNil() {
-		err = ret[1].Interface().(error)
-		return
+// encodeVersioned encodes every field prefixed with its identifier and length, followed
+// by a zero identifier which marks the end of the struct.
+func (c *reflectStructCodec) encodeVersioned(e *Encoder, rv reflect.Value) (err error) {
+	for _, i := range *c {
+		if err := e.opts().canceled(0); err != nil {
+			return err
+		}
+
+		field, ok, err := i.access(rv, e.opts())
+		switch {
+		case err != nil:
+			return err
+		case !ok, i.OmitEmpty && field.IsZero():
+			continue
+		}
+
+		e.WriteUvarint(uint64(i.ID))
+		codec := i.Codec
+		if err = e.writeNested(func() error {
+			return codec.EncodeTo(e, field)
+		}); err != nil {
+			return err
+		}
 	}
 
-	// Write the marshaled byte slice
-	buffer := ret[0].Bytes()
-	e.WriteUvarint(uint64(len(buffer)))
-	e.Write(buffer)
+	e.WriteUvarint(0)
 	return
 }
 
-// Decode decodes into a reflect value from the decoder.
-func (c *customCodec) DecodeTo(d *Decoder, rv reflect.Value) (err error) {
-	m := c.GetUnmarshalBinary(rv)
+// decodeVersioned decodes the fields by their identifier, skipping the unknown ones and
+// resetting the ones which are missing from the payload to their default value.
+func (c *reflectStructCodec) decodeVersioned(d *Decoder, rv reflect.Value) (err error) {
+	var buffer [64]bool
+	seen := buffer[:0]
+	if len(*c) > len(buffer) {
+		seen = make([]bool, len(*c))
+	}
+	seen = seen[:len(*c)]
+
+	for {
+		if err = d.opts.canceled(0); err != nil {
+			return
+		}
+
+		var id uint64
+		var l int
+		var b []byte
+		if id, err = d.ReadUvarint(); err != nil || id == 0 {
+			break
+		}
+
+		if l, err = d.readSliceLen(); err != nil {
+			return
+		}
+
+		if b, err = d.Slice(l); err != nil {
+			return
+		}
+
+		// Find the field, the fields are sorted by their identifier
+		n := sort.Search(len(*c), func(i int) bool { return (*c)[i].ID >= int(id) })
+		if n == len(*c) || (*c)[n].ID != int(id) {
+			continue // Unknown field, skip it
+		}
+
+		var v reflect.Value
+		var ok bool
+		i := (*c)[n]
+		if v, ok, err = i.access(rv, &d.opts); err != nil {
+			return
+		}
+
+		// Fields which are not selected are left unchanged
+		sub, selected := d.project.selects(i.name(&d.opts))
+		if ok && selected {
+			nested := d.nested(b)
+			nested.project = sub
+			err = i.Codec.DecodeTo(nested, v)
+			nested.release()
+			if err != nil {
+				return
+			}
+		}
+		seen[n] = true
+	}
+
+	// Reset the fields which were not present in the payload to their default value
+	if d.opts.merge {
+		return
+	}
 
-	var l uint64
-	if l, err = binary.ReadUvarint(d.r); err == nil {
-		buffer := make([]byte, l)
-		_, err = d.r.Read(buffer)
-		ret := m.Call([]reflect.Value{reflect.ValueOf(buffer)})
-		if !ret[0].IsNil() {
-			err = ret[0].Interface().(error)
+	var defaults reflect.Value
+	var defaulted bool
+	for n, i := range *c {
+		if _, selected := d.project.selects(i.name(&d.opts)); !selected || seen[n] {
+			continue
 		}
 
+		v, ok, _ := i.access(rv, &d.opts)
+		if !ok {
+			continue
+		}
+
+		if !defaulted {
+			defaults, defaulted = c.defaults(rv.Type(), &d.opts), true
+		}
+		if defaults.IsValid() {
+			dv, _, _ := i.access(defaults, &d.opts)
+			v.Set(dv)
+		} else {
+			v.Set(reflect.Zero(v.Type()))
+		}
 	}
 	return
 }
 
-func (c *customCodec) GetMarshalBinary(rv reflect.Value) *reflect.Value {
-	if c.marshaler != nil {
-		m := rv.Method(c.marshaler.Index)
-		return &m
+// ------------------------------------------------------------------------------
+
+// binaryMarshalerCodec represents a codec which delegates to the encoding.BinaryMarshaler
+// and encoding.BinaryUnmarshaler implementations of a type.
+type binaryMarshalerCodec struct {
+	ptrMarshaler   bool // Whether MarshalBinary is declared on the pointer receiver
+	ptrUnmarshaler bool // Whether UnmarshalBinary is declared on the pointer receiver
+}
+
+// Encode encodes a value into the encoder.
+func (c *binaryMarshalerCodec) EncodeTo(e *Encoder, rv reflect.Value) (err error) {
+	var buffer []byte
+	if buffer, err = c.marshaler(rv).MarshalBinary(); err == nil {
+		e.WriteUvarint(uint64(len(buffer)))
+		e.Write(buffer)
 	}
+	return
+}
 
-	if c.ptrMarshaler != nil {
-		m := rv.Addr().Method(c.ptrMarshaler.Index)
-		return &m
+// Decode decodes into a reflect value from the decoder.
+func (c *binaryMarshalerCodec) DecodeTo(d *Decoder, rv reflect.Value) (err error) {
+	var l int
+	if l, err = d.readSliceOf(1); err == nil {
+		var buffer []byte
+		if buffer, err = d.readCopy(l); err == nil {
+			err = c.unmarshaler(rv).UnmarshalBinary(buffer)
+		}
 	}
+	return
+}
 
-	return nil
+// Size returns -1, as the size is only known once MarshalBinary was called, which may be
+// expensive or return different bytes every time, so the value is encoded instead.
+func (c *binaryMarshalerCodec) Size(rv reflect.Value) int {
+	return -1
 }
 
-func (c *customCodec) GetUnmarshalBinary(rv reflect.Value) *reflect.Value {
-	if c.unmarshaler != nil {
-		m := rv.Method(c.unmarshaler.Index)
-		return &m
+// marshaler returns the encoding.BinaryMarshaler of the value. If the method is declared
+// on the pointer receiver and the value is not addressable, it is copied first.
+func (c *binaryMarshalerCodec) marshaler(rv reflect.Value) encoding.BinaryMarshaler {
+	if !c.ptrMarshaler {
+		return rv.Interface().(encoding.BinaryMarshaler)
 	}
 
-	if c.ptrUnmarshaler != nil {
-		m := rv.Addr().Method(c.ptrUnmarshaler.Index)
-		return &m
+	if !rv.CanAddr() {
+		ptr := reflect.New(rv.Type())
+		ptr.Elem().Set(rv)
+		rv = ptr.Elem()
 	}
 
-	return nil
+	return rv.Addr().Interface().(encoding.BinaryMarshaler)
+}
+
+// unmarshaler returns the encoding.BinaryUnmarshaler of the value.
+func (c *binaryMarshalerCodec) unmarshaler(rv reflect.Value) encoding.BinaryUnmarshaler {
+	if !c.ptrUnmarshaler {
+		return rv.Interface().(encoding.BinaryUnmarshaler)
+	}
+
+	return rv.Addr().Interface().(encoding.BinaryUnmarshaler)
 }
 
 // ------------------------------------------------------------------------------
@@ -311,8 +922,26 @@ type reflectMapCodec struct {
 
 // Encode encodes a value into the encoder.
 func (c *reflectMapCodec) EncodeTo(e *Encoder, rv reflect.Value) (err error) {
-	e.WriteUvarint(uint64(rv.Len()))
-	for _, key := range rv.MapKeys() {
+	if rv.Len() > 0 {
+		if err = e.follow(rv); err != nil {
+			return
+		}
+		defer e.unfollow(rv)
+	}
+
+	keys := rv.MapKeys()
+	if e.opts().sortKeys {
+		if err = sortKeys(keys); err != nil {
+			return
+		}
+	}
+
+	e.WriteUvarint(uint64(len(keys)))
+	for i, key := range keys {
+		if err = e.opts().canceled(i); err != nil {
+			return err
+		}
+
 		value := rv.MapIndex(key)
 		if err = c.writeKey(e, key); err != nil {
 			return err
@@ -327,12 +956,24 @@ func (c *reflectMapCodec) EncodeTo(e *Encoder, rv reflect.Value) (err error) {
 
 // Decode decodes into a reflect value from the decoder.
 func (c *reflectMapCodec) DecodeTo(d *Decoder, rv reflect.Value) (err error) {
-	var l uint64
-	if l, err = d.ReadUvarint(); err == nil {
-		t := rv.Type()
+	if err = d.enter(); err != nil {
+		return
+	}
+	defer d.leave()
+
+	var l int
+	t := rv.Type()
+	if l, err = d.readSliceOf(t.Key().Size() + t.Elem().Size()); err == nil {
 		vt := t.Elem()
-		rv.Set(reflect.MakeMap(t))
-		for i := 0; i < int(l); i++ {
+		if rv.IsNil() {
+			rv.Set(reflect.MakeMap(t))
+		} else {
+			rv.Clear() // Reuse the buckets of the destination
+		}
+		for i := 0; i < l; i++ {
+			if err = d.opts.canceled(i); err != nil {
+				return
+			}
 
 			var kv reflect.Value
 			if kv, err = c.readKey(d, t.Key()); err != nil {
@@ -350,6 +991,35 @@ func (c *reflectMapCodec) DecodeTo(d *Decoder, rv reflect.Value) (err error) {
 	return
 }
 
+// Size returns the encoded size of the value.
+func (c *reflectMapCodec) Size(rv reflect.Value) int {
+	size := uvarintSize(uint64(rv.Len()))
+	for _, key := range rv.MapKeys() {
+		k, v := c.sizeOfKey(key), sizeOf(c.val, rv.MapIndex(key))
+		if k < 0 || v < 0 {
+			return -1
+		}
+		size += k + v
+	}
+	return size
+}
+
+// sizeOfKey returns the encoded size of a key
+func (c *reflectMapCodec) sizeOfKey(key reflect.Value) int {
+	switch key.Kind() {
+	case reflect.Int16, reflect.Uint16:
+		return 2
+	case reflect.Int32, reflect.Uint32:
+		return 4
+	case reflect.Int64, reflect.Uint64:
+		return 8
+	case reflect.String:
+		return 2 + key.Len()
+	default:
+		return sizeOf(c.key, key)
+	}
+}
+
 // Write key writes a key to the encoder
 func (c *reflectMapCodec) writeKey(e *Encoder, key reflect.Value) (err error) {
 	switch key.Kind() {
@@ -369,15 +1039,24 @@ func (c *reflectMapCodec) writeKey(e *Encoder, key reflect.Value) (err error) {
 		e.WriteUint64(uint64(key.Uint()))
 
 	case reflect.String:
-		str := key.String()
-		e.WriteUint16(uint16(len(str)))
-		e.Write(stringToBinary(str))
+		e.writeKeyString(key.String())
 	default:
 		err = c.key.EncodeTo(e, key)
 	}
 	return
 }
 
+// writeKeyString writes a string map key, prefixed with its length as an uint16.
+func (e *Encoder) writeKeyString(v string) {
+	if e.opts().intern {
+		e.writeInterned(v)
+		return
+	}
+
+	e.WriteUint16(uint16(len(v)))
+	e.Write(stringToBinary(v))
+}
+
 // Read key reads a key from the decoder
 func (c *reflectMapCodec) readKey(d *Decoder, keyType reflect.Type) (key reflect.Value, err error) {
 	switch keyType.Kind() {
@@ -416,13 +1095,9 @@ func (c *reflectMapCodec) readKey(d *Decoder, keyType reflect.Type) (key reflect
 
 	// String keys must have max length of 65536
 	case reflect.String:
-		var l uint16
-		var b []byte
-
-		if l, err = d.ReadUint16(); err == nil {
-			if b, err = d.Slice(int(l)); err == nil {
-				key = reflect.ValueOf(string(b))
-			}
+		var s string
+		if s, err = d.readKeyString(); err == nil {
+			key = reflect.ValueOf(s)
 		}
 
 	// Default to a reflect-based approach
@@ -433,38 +1108,56 @@ func (c *reflectMapCodec) readKey(d *Decoder, keyType reflect.Type) (key reflect
 	return
 }
 
+// readKeyString reads a string map key, prefixed with its length as an uint16.
+func (d *Decoder) readKeyString() (out string, err error) {
+	if d.opts.intern {
+		return d.readInterned()
+	}
+
+	var l uint16
+	var b []byte
+	if l, err = d.ReadUint16(); err == nil {
+		if err = d.checkStringLen(int(l)); err != nil {
+			return
+		}
+		if b, err = d.Slice(int(l)); err == nil {
+			out, err = d.toString(b)
+		}
+	}
+	return
+}
+
 // ------------------------------------------------------------------------------
 
 type stringCodec struct{}
 
 // Encode encodes a value into the encoder.
 func (c *stringCodec) EncodeTo(e *Encoder, rv reflect.Value) error {
-	str := rv.String()
-	e.WriteUvarint(uint64(len(str)))
-	e.Write(stringToBinary(str))
+	e.WriteString(rv.String())
 	return nil
 }
 
 // Decode decodes into a reflect value from the decoder.
 func (c *stringCodec) DecodeTo(d *Decoder, rv reflect.Value) (err error) {
-	var l uint64
-	var b []byte
-
-	if l, err = d.ReadUvarint(); err == nil {
-		if b, err = d.Slice(int(l)); err == nil {
-			rv.SetString(string(b))
-		}
+	var out string
+	if out, err = d.ReadString(); err == nil {
+		rv.SetString(out)
 	}
 	return
 }
 
+// Size returns the encoded size of the value.
+func (c *stringCodec) Size(rv reflect.Value) int {
+	return uvarintSize(uint64(rv.Len())) + rv.Len()
+}
+
 // ------------------------------------------------------------------------------
 
 type boolCodec struct{}
 
 // Encode encodes a value into the encoder.
 func (c *boolCodec) EncodeTo(e *Encoder, rv reflect.Value) error {
-	e.writeBool(rv.Bool())
+	e.WriteBool(rv.Bool())
 	return nil
 }
 
@@ -477,6 +1170,11 @@ func (c *boolCodec) DecodeTo(d *Decoder, rv reflect.Value) (err error) {
 	return
 }
 
+// Size returns the encoded size of the value.
+func (c *boolCodec) Size(rv reflect.Value) int {
+	return 1
+}
+
 // ------------------------------------------------------------------------------
 
 type varintCodec struct{}
@@ -493,10 +1191,49 @@ func (c *varintCodec) DecodeTo(d *Decoder, rv reflect.Value) (err error) {
 	if v, err = binary.ReadVarint(d.r); err != nil {
 		return
 	}
+	if rv.OverflowInt(v) {
+		return IntOverflowError(v, rv.Type().String())
+	}
 	rv.SetInt(v)
 	return
 }
 
+// Size returns the encoded size of the value.
+func (c *varintCodec) Size(rv reflect.Value) int {
+	return varintSize(rv.Int())
+}
+
+// ------------------------------------------------------------------------------
+
+// rawVarintCodec represents a codec for signed integers which are encoded as the
+// variable-size unsigned integer of their two's complement instead of zigzag, like the
+// int32 and int64 types of protobuf. Negative numbers always take 10 bytes.
+type rawVarintCodec struct{}
+
+// Encode encodes a value into the encoder.
+func (c *rawVarintCodec) EncodeTo(e *Encoder, rv reflect.Value) error {
+	e.WriteUvarint(uint64(rv.Int()))
+	return nil
+}
+
+// Decode decodes into a reflect value from the decoder.
+func (c *rawVarintCodec) DecodeTo(d *Decoder, rv reflect.Value) (err error) {
+	var u uint64
+	if u, err = binary.ReadUvarint(d.r); err != nil {
+		return
+	}
+	if v := int64(u); rv.OverflowInt(v) {
+		return IntOverflowError(v, rv.Type().String())
+	}
+	rv.SetInt(int64(u))
+	return
+}
+
+// Size returns the encoded size of the value.
+func (c *rawVarintCodec) Size(rv reflect.Value) int {
+	return uvarintSize(uint64(rv.Int()))
+}
+
 // ------------------------------------------------------------------------------
 
 type varuintCodec struct{}
@@ -513,46 +1250,64 @@ func (c *varuintCodec) DecodeTo(d *Decoder, rv reflect.Value) (err error) {
 	if v, err = binary.ReadUvarint(d.r); err != nil {
 		return
 	}
+	if rv.OverflowUint(v) {
+		return UintOverflowError(v, rv.Type().String())
+	}
 	rv.SetUint(v)
 	return
 }
 
+// Size returns the encoded size of the value.
+func (c *varuintCodec) Size(rv reflect.Value) int {
+	return uvarintSize(rv.Uint())
+}
+
 // ------------------------------------------------------------------------------
 
 type complex64Codec struct{}
 
 // Encode encodes a value into the encoder.
 func (c *complex64Codec) EncodeTo(e *Encoder, rv reflect.Value) error {
-	e.writeComplex64(complex64(rv.Complex()))
+	e.WriteComplex64(complex64(rv.Complex()))
 	return nil
 }
 
 // Decode decodes into a reflect value from the decoder.
 func (c *complex64Codec) DecodeTo(d *Decoder, rv reflect.Value) (err error) {
 	var out complex64
-	out, err = d.readComplex64()
+	out, err = d.ReadComplex64()
 	rv.SetComplex(complex128(out))
 	return
 }
 
+// Size returns the encoded size of the value.
+func (c *complex64Codec) Size(rv reflect.Value) int {
+	return 8
+}
+
 // ------------------------------------------------------------------------------
 
 type complex128Codec struct{}
 
 // Encode encodes a value into the encoder.
 func (c *complex128Codec) EncodeTo(e *Encoder, rv reflect.Value) error {
-	e.writeComplex128(rv.Complex())
+	e.WriteComplex128(rv.Complex())
 	return nil
 }
 
 // Decode decodes into a reflect value from the decoder.
 func (c *complex128Codec) DecodeTo(d *Decoder, rv reflect.Value) (err error) {
 	var out complex128
-	out, err = d.readComplex128()
+	out, err = d.ReadComplex128()
 	rv.SetComplex(out)
 	return
 }
 
+// Size returns the encoded size of the value.
+func (c *complex128Codec) Size(rv reflect.Value) int {
+	return 16
+}
+
 // ------------------------------------------------------------------------------
 
 type float32Codec struct{}
@@ -572,6 +1327,11 @@ func (c *float32Codec) DecodeTo(d *Decoder, rv reflect.Value) (err error) {
 	return
 }
 
+// Size returns the encoded size of the value.
+func (c *float32Codec) Size(rv reflect.Value) int {
+	return 4
+}
+
 // ------------------------------------------------------------------------------
 
 type float64Codec struct{}
@@ -590,3 +1350,8 @@ func (c *float64Codec) DecodeTo(d *Decoder, rv reflect.Value) (err error) {
 	}
 	return
 }
+
+// Size returns the encoded size of the value.
+func (c *float64Codec) Size(rv reflect.Value) int {
+	return 8
+}
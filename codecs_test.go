@@ -374,3 +374,166 @@ func Test_Float64(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, v, o)
 }
+
+func TestBinaryStructTags(t *testing.T) {
+	type T struct {
+		A string `binary:"-"`
+		B string `binary:",id=2"`
+		C string `binary:",id=1"`
+	}
+
+	b, err := Marshal(&T{A: "a", B: "b", C: "c"})
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{0x1, 0x63, 0x1, 0x62}, b)
+
+	var v T
+	assert.NoError(t, Unmarshal(b, &v))
+	assert.Equal(t, T{B: "b", C: "c"}, v)
+}
+
+func TestBinaryMarshalerNonAddressable(t *testing.T) {
+	type T struct {
+		A s2
+		B time.Time
+	}
+
+	v := T{A: s2{[]byte{0x13}}, B: time.Date(2013, 1, 2, 3, 4, 5, 6, time.UTC)}
+	b, err := Marshal(v)
+	assert.NoError(t, err)
+
+	var out T
+	assert.NoError(t, Unmarshal(b, &out))
+	assert.Equal(t, v, out)
+}
+
+func TestBinaryMarshalerError(t *testing.T) {
+	b, err := Marshal(&s2{[]byte{0x1, 0x2}})
+	assert.NoError(t, err)
+
+	var out s2
+	assert.Error(t, Unmarshal(b, &out))
+}
+
+func TestPointers(t *testing.T) {
+	type inner struct {
+		Value string
+	}
+
+	type T struct {
+		A *int
+		B *inner
+		C []*inner
+		D **string
+		E *int
+	}
+
+	a, s := 5, "hi"
+	ps := &s
+	v := T{A: &a, B: &inner{"x"}, C: []*inner{{"y"}, nil}, D: &ps}
+
+	b, err := Marshal(&v)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{0x1, 0xa, 0x1, 0x1, 0x78, 0x2, 0x1, 0x1, 0x79, 0x0, 0x1, 0x1, 0x2, 0x68, 0x69, 0x0}, b)
+
+	n, err := Size(&v)
+	assert.NoError(t, err)
+	assert.Equal(t, len(b), n)
+
+	// Decode into a value with existing pointers, the nil ones should be reset
+	x := 10
+	out := T{E: &x}
+	assert.NoError(t, Unmarshal(b, &out))
+	assert.Equal(t, v, out)
+	assert.Nil(t, out.E)
+}
+
+func TestPointers_Invalid(t *testing.T) {
+	var out *int
+	assert.Error(t, Unmarshal([]byte{0x2, 0x1}, &out))
+}
+
+type linkedList struct {
+	Value int
+	Next  *linkedList
+}
+
+func TestPointers_Recursive(t *testing.T) {
+	v := &linkedList{1, &linkedList{2, &linkedList{3, nil}}}
+
+	b, err := Marshal(v)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{0x2, 0x1, 0x4, 0x1, 0x6, 0x0}, b)
+
+	var out linkedList
+	assert.NoError(t, Unmarshal(b, &out))
+	assert.Equal(t, v, &out)
+}
+
+type uuid [16]byte
+
+func TestByteArrays(t *testing.T) {
+	type T struct {
+		ID   uuid
+		Hash [4]uint8
+		Keys map[string][2]byte
+	}
+
+	v := T{
+		ID:   uuid{0: 1, 15: 200},
+		Hash: [4]uint8{0xde, 0xad, 0xbe, 0xef},
+		Keys: map[string][2]byte{"a": {1, 255}},
+	}
+
+	b, err := Marshal(&v)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{
+		0x1, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0xc8,
+		0xde, 0xad, 0xbe, 0xef,
+		0x1, 0x1, 0x0, 0x61, 0x1, 0xff,
+	}, b)
+
+	n, err := Size(&v)
+	assert.NoError(t, err)
+	assert.Equal(t, len(b), n)
+
+	var out T
+	assert.NoError(t, Unmarshal(b, &out))
+	assert.Equal(t, v, out)
+	assert.Error(t, Unmarshal(b[:10], &out))
+}
+
+func TestByteArrays_LegacyLayout(t *testing.T) {
+	// Byte arrays used to be encoded as one uvarint per byte, which no longer decodes
+	legacy := []byte{0xde, 0x01, 0xad, 0x01, 0xbe, 0x01, 0xef, 0x01}
+	var hash [4]byte
+	assert.True(t, errors.Is(Unmarshal(legacy, &hash, Strict()), ErrTrailingBytes))
+
+	// The same layout is decoded into an array of wider unsigned integers
+	var wide [4]uint16
+	assert.NoError(t, Unmarshal(legacy, &wide, Strict()))
+	for i, v := range wide {
+		hash[i] = byte(v)
+	}
+	assert.Equal(t, [4]byte{0xde, 0xad, 0xbe, 0xef}, hash)
+}
+
+func TestArraysNonAddressable(t *testing.T) {
+	v := [2]uuid{{1}, {2}}
+	b, err := Marshal(v)
+	assert.NoError(t, err)
+	assert.Len(t, b, 32)
+
+	vectors := map[int][3]float32{1: {1, 2, 3}}
+	b, err = Marshal(vectors)
+	assert.NoError(t, err)
+
+	var out map[int][3]float32
+	assert.NoError(t, Unmarshal(b, &out))
+	assert.Equal(t, vectors, out)
+
+	var slice []uuid
+	b, err = Marshal([]uuid{{1}, {2}})
+	assert.NoError(t, err)
+	assert.NoError(t, Unmarshal(b, &slice))
+	assert.Equal(t, []uuid{{1}, {2}}, slice)
+}
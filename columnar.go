@@ -0,0 +1,234 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package binary
+
+import (
+	"errors"
+	"reflect"
+	"strconv"
+)
+
+// ColumnarCodec returns a codec which encodes a slice of structs in columnar order, with
+// all of the values of the first field, followed by all of the values of the second one,
+// and so on. Similar values end up next to each other, which compresses much better, and
+// every column is encoded like a slice, so that the `binary:",column=delta"` tag option of
+// a field encodes its column with the delta option, and likewise for the other options
+// of slices, such as rle, vbyte, bits or gorilla. Registering it with RegisterCodec
+// applies it wherever the type appears, while the `binary:",columnar"` tag applies it to
+// a single field.
+func ColumnarCodec(t reflect.Type) (Codec, error) {
+	return scanColumnar(t)
+}
+
+// scanColumnar returns a codec which encodes a slice of structs in columnar order,
+// selected with a `binary:",columnar"` tag.
+func scanColumnar(t reflect.Type) (Codec, error) {
+	if t.Kind() != reflect.Slice || t.Elem().Kind() != reflect.Struct {
+		return nil, errors.New("binary: columnar encoding is not supported for " + t.String())
+	}
+
+	// The rows are encoded as they are in the formats which are not columnar
+	fallback, err := scanReflect(t)
+	if err != nil {
+		return nil, err
+	}
+
+	elem := t.Elem()
+	s, err := scanStruct(elem)
+	if err != nil {
+		return nil, nestedError(err, "[]")
+	}
+
+	out := &columnarCodec{fallback: fallback}
+	for _, f := range s.fields {
+		var field reflect.StructField
+		if f.Path != nil {
+			field = elem.FieldByIndex(f.Path)
+		} else {
+			field = elem.Field(f.Index)
+		}
+
+		// Every column is encoded like a slice, with the options of its column tag
+		option, _ := f.Tag.Options.Lookup("column")
+		slice := reflect.SliceOf(field.Type)
+		c, err := scanField(slice, fieldTag{Options: tagOptions(option)})
+		unexported := field.PkgPath != ""
+		if err != nil && !unexported {
+			return nil, nestedError(err, "[]."+f.Name)
+		}
+
+		out.columns = append(out.columns, columnCodec{
+			Type: slice,
+			fieldCodec: fieldCodec{
+				Index:      f.Index,
+				Path:       f.Path,
+				Name:       f.Name,
+				JSON:       f.JSON,
+				ID:         f.ID,
+				Codec:      c,
+				Unexported: unexported,
+				err:        err,
+			},
+		})
+	}
+	return out, nil
+}
+
+// ------------------------------------------------------------------------------
+
+// columnarCodec represents a codec for slices of structs, which are prefixed with their
+// count, followed by every field encoded as a slice of the values of all of the structs,
+// in the order of the fields. Empty slices have no columns.
+type columnarCodec struct {
+	columns  []columnCodec // The columns, one for each field
+	fallback Codec         // The codec of the rows, used by other formats
+}
+
+// columnCodec represents the field of the structs of a column, whose codec encodes a
+// slice of the values of the field.
+type columnCodec struct {
+	fieldCodec
+	Type reflect.Type // The type of the slice of values of the column
+}
+
+// Encode encodes a value into the encoder.
+func (c *columnarCodec) EncodeTo(e *Encoder, rv reflect.Value) (err error) {
+	l := rv.Len()
+	e.WriteUvarint(uint64(l))
+	if l == 0 {
+		return nil
+	}
+
+	for i := range c.columns {
+		if err = e.opts().canceled(0); err != nil {
+			return
+		}
+
+		f := &c.columns[i]
+		var values reflect.Value
+		var ok bool
+		if values, ok, err = f.gather(rv, e.opts()); err != nil || !ok {
+			if err != nil {
+				return
+			}
+			continue
+		}
+
+		if err = f.Codec.EncodeTo(e, values); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// Decode decodes into a reflect value from the decoder.
+func (c *columnarCodec) DecodeTo(d *Decoder, rv reflect.Value) (err error) {
+	if err = d.enter(); err != nil {
+		return
+	}
+	defer d.leave()
+
+	var l int
+	if l, err = d.readSliceLen(); err != nil || l == 0 {
+		rv.Set(rv.Slice(0, 0))
+		return
+	}
+
+	// The columns are decoded first, so that their lengths are checked against the input
+	// before allocating the structs
+	elem := rv.Type().Elem()
+	columns := make([]reflect.Value, len(c.columns))
+	for i := range c.columns {
+		if err = d.opts.canceled(0); err != nil {
+			return
+		}
+
+		f := &c.columns[i]
+		switch ok, err := f.encoded(&d.opts, elem); {
+		case err != nil:
+			return err
+		case !ok:
+			continue
+		}
+
+		values := reflect.New(f.Type).Elem()
+		if err = f.Codec.DecodeTo(d, values); err != nil {
+			return
+		}
+		if values.Len() != l {
+			return errors.New("binary: expected " + strconv.Itoa(l) + " values in the column " + f.Name + " of " + rv.Type().String())
+		}
+		columns[i] = values
+	}
+
+	out := rv.Slice(0, 0)
+	if l > rv.Cap() {
+		if err = d.allocate(l, elem.Size()); err != nil {
+			return
+		}
+		out = d.newSlice(rv.Type(), l)
+	}
+	out = out.Slice(0, l)
+
+	for i, values := range columns {
+		if !values.IsValid() {
+			continue
+		}
+
+		f := &c.columns[i]
+		for j := 0; j < l; j++ {
+			v, _, _ := f.access(out.Index(j), &d.opts)
+			v.Set(values.Index(j))
+		}
+	}
+
+	rv.Set(out)
+	return
+}
+
+// Size returns the encoded size of the value.
+func (c *columnarCodec) Size(rv reflect.Value) int {
+	l := rv.Len()
+	size := uvarintSize(uint64(l))
+	if l == 0 {
+		return size
+	}
+
+	var o options
+	for i := range c.columns {
+		values, ok, err := c.columns[i].gather(rv, &o)
+		if err != nil {
+			return -1
+		}
+		if !ok {
+			continue
+		}
+
+		n := sizeOf(c.columns[i].Codec, values)
+		if n < 0 {
+			return -1
+		}
+		size += n
+	}
+	return size
+}
+
+// gather returns a slice of the values of the column for every struct of a slice. It
+// returns false if the field is not encoded.
+func (f *columnCodec) gather(rv reflect.Value, o *options) (reflect.Value, bool, error) {
+	switch ok, err := f.encoded(o, rv.Type().Elem()); {
+	case err != nil:
+		return reflect.Value{}, false, err
+	case !ok:
+		return reflect.Value{}, false, nil
+	}
+
+	l := rv.Len()
+	values := reflect.MakeSlice(f.Type, l, l)
+	for j := 0; j < l; j++ {
+		v, _, _ := f.access(rv.Index(j), o)
+		values.Index(j).Set(v)
+	}
+	return values, true, nil
+}
@@ -0,0 +1,164 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package binary
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type sensorReading struct {
+	Time   int64   `binary:",column=delta"`
+	Sensor string  `binary:",column=rle"`
+	Value  float64 `binary:",column=gorilla"`
+	Valid  bool    `binary:",column=bits"`
+}
+
+type sensorSeries struct {
+	Name     string
+	Readings []sensorReading `binary:",columnar"`
+}
+
+type sensorRows struct {
+	Name     string
+	Readings []sensorReading
+}
+
+func newSensorSeries(n int) *sensorSeries {
+	v := &sensorSeries{Name: "kitchen"}
+	for i := 0; i < n; i++ {
+		v.Readings = append(v.Readings, sensorReading{
+			Time:   1700000000 + int64(i)*10,
+			Sensor: "temperature",
+			Value:  21.5,
+			Valid:  i%7 != 0,
+		})
+	}
+	return v
+}
+
+func TestColumnar(t *testing.T) {
+	for _, v := range []*sensorSeries{
+		{Name: "empty"},
+		newSensorSeries(1),
+		newSensorSeries(100),
+	} {
+		b, err := Marshal(v)
+		assert.NoError(t, err)
+
+		size, err := Size(v)
+		assert.NoError(t, err)
+		assert.Equal(t, len(b), size)
+
+		var out sensorSeries
+		assert.NoError(t, Unmarshal(b, &out))
+		if len(v.Readings) == 0 {
+			assert.Empty(t, out.Readings)
+			continue
+		}
+		assert.Equal(t, v, &out)
+	}
+
+	// Each column is encoded with its own options, which is much smaller than the rows
+	v := newSensorSeries(100)
+	b, err := Marshal(v)
+	assert.NoError(t, err)
+
+	rows, err := Marshal(&sensorRows{Name: v.Name, Readings: v.Readings})
+	assert.NoError(t, err)
+	assert.True(t, len(b) < len(rows)/4)
+}
+
+func TestColumnar_Reuse(t *testing.T) {
+	b, err := Marshal(newSensorSeries(3))
+	assert.NoError(t, err)
+
+	out := sensorSeries{Readings: make([]sensorReading, 10)}
+	readings := out.Readings
+	assert.NoError(t, Unmarshal(b, &out))
+	assert.Equal(t, 3, len(out.Readings))
+	assert.Equal(t, &readings[0], &out.Readings[0])
+}
+
+func TestColumnar_Codec(t *testing.T) {
+	typ := reflect.TypeOf([]sensorReading{})
+	codec, err := ColumnarCodec(typ)
+	assert.NoError(t, err)
+
+	RegisterCodec(typ, codec)
+	defer RegisterCodec(typ, nil)
+
+	v := newSensorSeries(10).Readings
+	b, err := Marshal(v)
+	assert.NoError(t, err)
+
+	// The structs are followed by the times of the first column, as deltas
+	assert.Equal(t, []byte{10, 10, 0x80, 0xc4, 0x9f, 0xd5, 0x0c, 10}, b[:8])
+
+	var out []sensorReading
+	assert.NoError(t, Unmarshal(b, &out))
+	assert.Equal(t, v, out)
+}
+
+func TestColumnar_Schema(t *testing.T) {
+	v := newSensorSeries(2)
+	b, err := Marshal(v, SelfDescribing())
+	assert.NoError(t, err)
+
+	out, err := DecodeAny(b, SelfDescribing())
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"Name": "kitchen",
+		"Readings": []interface{}{
+			map[string]interface{}{"Time": int64(1700000000), "Sensor": "temperature", "Value": 21.5, "Valid": false},
+			map[string]interface{}{"Time": int64(1700000010), "Sensor": "temperature", "Value": 21.5, "Valid": true},
+		},
+	}, out)
+
+	schema, err := SchemaOf(reflect.TypeOf(sensorSeries{}))
+	assert.NoError(t, err)
+	assert.Equal(t, KindColumnar, schema.Fields[1].Schema.Kind)
+	assert.Equal(t, KindDelta, schema.Fields[1].Schema.Fields[0].Schema.Kind)
+
+	// The columns can be traversed without decoding them
+	b, err = Marshal(v)
+	assert.NoError(t, err)
+
+	var paths []string
+	assert.NoError(t, Walk(b, schema, func(tok Token) error {
+		paths = append(paths, tok.Path)
+		return nil
+	}))
+	assert.Contains(t, paths, ".Readings[].Sensor")
+}
+
+func TestColumnar_Formats(t *testing.T) {
+	v := newSensorSeries(5)
+	c, err := MarshalCBOR(v)
+	assert.NoError(t, err)
+
+	var out sensorSeries
+	assert.NoError(t, UnmarshalCBOR(c, &out))
+	assert.Equal(t, v, &out)
+}
+
+func TestColumnar_Invalid(t *testing.T) {
+	_, err := ColumnarCodec(reflect.TypeOf([]int{}))
+	assert.Error(t, err)
+
+	_, err = Marshal(&struct {
+		Values map[string]sensorReading `binary:",columnar"`
+	}{})
+	assert.Error(t, err)
+
+	// The columns must have as many values as there are structs
+	b, err := Marshal(newSensorSeries(2))
+	assert.NoError(t, err)
+	b[9] = 3
+
+	var out sensorSeries
+	assert.Error(t, Unmarshal(b, &out))
+}
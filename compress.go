@@ -0,0 +1,127 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package binary
+
+import (
+	"errors"
+	"reflect"
+)
+
+// Compressor represents an algorithm compressing encoded values, such as snappy or zstd,
+// which can be plugged into encoders and decoders with the Compression option.
+type Compressor interface {
+	// Compress appends the compressed src to dst and returns the resulting slice.
+	Compress(dst, src []byte) ([]byte, error)
+
+	// Decompress appends the decompressed src to dst and returns the resulting slice.
+	Decompress(dst, src []byte) ([]byte, error)
+}
+
+// The flags preceding the encoded values, when the compression is enabled
+const (
+	flagUncompressed byte = iota
+	flagCompressed
+)
+
+// Compression compresses the encoded values whose size reaches the threshold with the
+// compressor, unless that does not make them smaller. Every value is prefixed with a flag
+// telling whether it is compressed, followed by its decompressed size and its compressed
+// size, so that decoders only need the same compressor. The MaxMessageSize option limits
+// the size of decompressed values.
+func Compression(c Compressor, threshold int) Option {
+	return func(o *options) {
+		o.compressor = c
+		o.threshold = threshold
+	}
+}
+
+// writeCompressed encodes a value into a temporary buffer, then writes it either as it is
+// or compressed.
+func (e *Encoder) writeCompressed(c Codec, rv reflect.Value) (err error) {
+	w := appenders.Get().(*appendWriter)
+	out, buffer, x := e.out, e.buffer, e.ext
+	vector, crc, hash := x.vector, x.crc, x.hash
+	e.out, e.buffer, x.vector, x.hash = w, nil, nil, nil
+	err = c.EncodeTo(e, rv)
+	e.out, e.buffer, x.vector, x.crc, x.hash = out, buffer, vector, crc, hash
+
+	var compressed []byte
+	z := appenders.Get().(*appendWriter)
+	if err == nil && len(*w) >= e.opts().threshold {
+		compressed, err = e.opts().compressor.Compress((*z)[:0], *w)
+	}
+
+	switch {
+	case err != nil:
+	case compressed != nil && len(compressed) < len(*w):
+		e.scratch[0] = flagCompressed
+		e.Write(e.scratch[:1])
+		e.WriteUvarint(uint64(len(*w)))
+		e.WriteUvarint(uint64(len(compressed)))
+		e.Write(compressed)
+		*z = compressed
+	default:
+		e.scratch[0] = flagUncompressed
+		e.Write(e.scratch[:1])
+		e.Write(*w)
+	}
+
+	*w, *z = (*w)[:0], (*z)[:0]
+	appenders.Put(w)
+	appenders.Put(z)
+	return
+}
+
+// readCompressed reads a value which may be compressed, and decodes it with the function.
+func (d *Decoder) readCompressed(decode func(*Decoder) error) (err error) {
+	var flag byte
+	if flag, err = d.r.ReadByte(); err != nil {
+		return
+	}
+
+	switch flag {
+	case flagUncompressed:
+		return decode(d)
+	case flagCompressed:
+	default:
+		return errors.New("binary: invalid compression flag")
+	}
+
+	var size uint64
+	var l int
+	var src []byte
+	if size, err = d.ReadUvarint(); err != nil {
+		return
+	}
+	if max := d.opts.maxMessage; max > 0 && size > uint64(max) {
+		return limitError("decompressed size", size, max)
+	}
+	if l, err = d.readLength(d.opts.maxMessage, "compressed size"); err != nil {
+		return
+	}
+	if src, err = d.Slice(l); err != nil {
+		return
+	}
+
+	w := appenders.Get().(*appendWriter)
+	b, err := d.opts.compressor.Decompress((*w)[:0], src)
+	switch {
+	case err != nil:
+	case uint64(len(b)) != size:
+		err = errors.New("binary: invalid compressed value")
+	default:
+		nested := d.nested(b)
+		if err = decode(nested); err == nil {
+			err = nested.consumed()
+		}
+		nested.release()
+	}
+
+	// Strings decoded without a copy point into the buffer, which can not be reused
+	if !d.opts.zeroCopy {
+		*w = b[:0]
+		appenders.Put(w)
+	}
+	return
+}
@@ -0,0 +1,115 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package binary
+
+import (
+	"bytes"
+	"compress/flate"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// flateCompressor compresses values with the DEFLATE algorithm.
+type flateCompressor struct{}
+
+func (flateCompressor) Compress(dst, src []byte) ([]byte, error) {
+	out := bytes.NewBuffer(dst)
+	w, _ := flate.NewWriter(out, flate.BestSpeed)
+	if _, err := w.Write(src); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+func (flateCompressor) Decompress(dst, src []byte) ([]byte, error) {
+	out := bytes.NewBuffer(dst)
+	_, err := io.Copy(out, flate.NewReader(bytes.NewReader(src)))
+	return out.Bytes(), err
+}
+
+func TestCompression(t *testing.T) {
+	v := newProjected()
+	v.Text = strings.Repeat("compressible ", 100)
+
+	for _, opts := range [][]Option{
+		{Compression(flateCompressor{}, 64)},
+		{Compression(flateCompressor{}, 64), Versioned(), InternStrings()},
+		{Compression(flateCompressor{}, 64), Checksum(), SelfDescribing()},
+	} {
+		b, err := Marshal(v, opts...)
+		assert.NoError(t, err)
+		assert.Equal(t, flagCompressed, b[headerSize(t, b, opts)])
+
+		plain, err := Marshal(v, opts[1:]...)
+		assert.NoError(t, err)
+		assert.True(t, len(b) < len(plain)/2)
+
+		n, err := Size(v, opts...)
+		assert.NoError(t, err)
+		assert.Equal(t, len(b), n)
+
+		out := new(projected)
+		assert.NoError(t, Unmarshal(b, out, opts...))
+		assert.Equal(t, v, out)
+
+		// Streams of compressed values can be decoded as well
+		out = new(projected)
+		assert.NoError(t, NewDecoder(iotest.HalfReader(bytes.NewReader(b)), opts...).Decode(out))
+		assert.Equal(t, v, out)
+	}
+}
+
+// headerSize returns the size of the self-describing header, if any.
+func headerSize(t *testing.T, b []byte, opts []Option) int {
+	var o options
+	o.reset(opts)
+	if !o.selfDescribing {
+		return 0
+	}
+
+	d := newDecoder(NewSliceReader(b))
+	_, err := d.readHeader(false)
+	assert.NoError(t, err)
+	return int(d.s.i)
+}
+
+func TestCompression_Threshold(t *testing.T) {
+	compression := Compression(flateCompressor{}, 64)
+
+	// Small values, and values which are not smaller once compressed, are left as they are
+	for _, v := range []interface{}{"short", []byte("incompressible, but long enough to be compressed")} {
+		plain, err := Marshal(v)
+		assert.NoError(t, err)
+
+		b, err := Marshal(v, compression)
+		assert.NoError(t, err)
+		assert.Equal(t, append([]byte{flagUncompressed}, plain...), b)
+	}
+}
+
+func TestCompression_Invalid(t *testing.T) {
+	compression := Compression(flateCompressor{}, 0)
+	b, err := Marshal(strings.Repeat("a", 1000), compression)
+	assert.NoError(t, err)
+
+	var out string
+	assert.Error(t, Unmarshal(b[:len(b)-1], &out, compression))
+	assert.Error(t, Unmarshal([]byte{0x2}, &out, compression))
+	assert.True(t, errors.Is(Unmarshal(b, &out, compression, MaxMessageSize(100)), ErrLimitExceeded))
+
+	// The decompressed size must match the one of the header
+	b[1]++
+	assert.Error(t, Unmarshal(b, &out, compression))
+
+	// Compressed values can not be walked over
+	assert.Error(t, Walk(b, &Schema{Kind: KindString}, nil, compression))
+}
@@ -0,0 +1,199 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package binary
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"time"
+)
+
+// Vector represents a conformance test vector, which pins the encoding of a value with a
+// set of options. Vectors are published in spec/vectors.json along with the specification
+// of the wire format, so that implementations in other languages can check that they
+// produce and accept the same payloads as this package.
+type Vector struct {
+	Name    string      // The name of the vector
+	Type    string      // The name of the Go type of the value, for information only
+	Options []string    // The names of the options the value is encoded with
+	Value   interface{} // The encoded value
+	Bytes   []byte      // The expected encoding of the value
+}
+
+// MarshalJSON encodes the vector into JSON, with its bytes in hexadecimal.
+func (v Vector) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Name    string      `json:"name"`
+		Type    string      `json:"type"`
+		Options []string    `json:"options,omitempty"`
+		Value   interface{} `json:"value"`
+		Hex     string      `json:"hex"`
+	}{v.Name, v.Type, v.Options, v.Value, hex.EncodeToString(v.Bytes)})
+}
+
+// options returns the options the value of the vector is encoded with.
+func (v *Vector) options() ([]Option, error) {
+	opts := make([]Option, 0, len(v.Options))
+	for _, name := range v.Options {
+		opt, ok := vectorOptions[name]
+		if !ok {
+			return nil, fmt.Errorf("binary: unknown option %q", name)
+		}
+		opts = append(opts, opt)
+	}
+	return opts, nil
+}
+
+// The options which change the wire format, by the name used in the vectors
+var vectorOptions = map[string]Option{
+	"deterministic": Deterministic(),
+	"versioned":     Versioned(),
+	"intern":        InternStrings(),
+	"checksum":      Checksum(),
+}
+
+// The types of the structs of the vectors
+type (
+	vectorPoint struct {
+		X int16 `binary:",fixed"`
+		Y int16 `binary:",fixed"`
+	}
+
+	vectorRecord struct {
+		ID    uint64
+		Name  string
+		Tags  []string
+		Score float64
+	}
+
+	vectorSparse struct {
+		A int    `binary:",omitempty"`
+		B string `binary:",omitempty"`
+		C bool
+	}
+
+	vectorNested struct {
+		Point vectorPoint
+		Next  *vectorNested
+	}
+
+	vectorVersioned struct {
+		Name string `binary:",id=1"`
+		Age  int    `binary:",id=3"`
+	}
+)
+
+// Vectors returns the conformance test vectors, which cover every kind of value which is
+// encoded without a custom codec, with the options which change the wire format.
+func Vectors() []Vector {
+	vectors := []Vector{
+		{Name: "bool/false", Value: false, Bytes: fromHex("00")},
+		{Name: "bool/true", Value: true, Bytes: fromHex("01")},
+		{Name: "uvarint/0", Value: uint64(0), Bytes: fromHex("00")},
+		{Name: "uvarint/127", Value: uint64(127), Bytes: fromHex("7f")},
+		{Name: "uvarint/128", Value: uint64(128), Bytes: fromHex("8001")},
+		{Name: "uvarint/300", Value: uint64(300), Bytes: fromHex("ac02")},
+		{Name: "uvarint/max", Value: uint64(math.MaxUint64), Bytes: fromHex("ffffffffffffffffff01")},
+		{Name: "uvarint/uint8", Value: uint8(255), Bytes: fromHex("ff01")},
+		{Name: "uvarint/uint32", Value: uint32(0x01020304), Bytes: fromHex("84868808")},
+		{Name: "varint/0", Value: int64(0), Bytes: fromHex("00")},
+		{Name: "varint/-1", Value: int64(-1), Bytes: fromHex("01")},
+		{Name: "varint/1", Value: int64(1), Bytes: fromHex("02")},
+		{Name: "varint/-64", Value: int64(-64), Bytes: fromHex("7f")},
+		{Name: "varint/64", Value: int64(64), Bytes: fromHex("8001")},
+		{Name: "varint/min", Value: int64(math.MinInt64), Bytes: fromHex("ffffffffffffffffff01")},
+		{Name: "varint/int32", Value: int32(-300), Bytes: fromHex("d704")},
+		{Name: "float/32", Value: float32(1.5), Bytes: fromHex("0000c03f")},
+		{Name: "float/64", Value: float64(-2.25), Bytes: fromHex("00000000000002c0")},
+		{Name: "string/empty", Value: "", Bytes: fromHex("00")},
+		{Name: "string/ascii", Value: "hello", Bytes: fromHex("0568656c6c6f")},
+		{Name: "string/utf8", Value: "héllo", Bytes: fromHex("0668c3a96c6c6f")},
+		{Name: "bytes/nil", Value: []byte(nil), Bytes: fromHex("00")},
+		{Name: "bytes/slice", Value: []byte{1, 2, 3}, Bytes: fromHex("03010203")},
+		{Name: "bytes/array", Value: [4]byte{0xde, 0xad, 0xbe, 0xef}, Bytes: fromHex("deadbeef")},
+		{Name: "slice/bool", Value: []bool{true, false, true}, Bytes: fromHex("03010001")},
+		{Name: "slice/int", Value: []int{1, -1, 300}, Bytes: fromHex("030201d804")},
+		{Name: "slice/uint32", Value: []uint32{1, 128}, Bytes: fromHex("02018001")},
+		{Name: "slice/float64", Value: []float64{1, -0.5}, Bytes: fromHex("02000000000000f03f000000000000e0bf")},
+		{Name: "slice/string", Value: []string{"a", "bc"}, Bytes: fromHex("020161026263")},
+		{Name: "slice/bytes", Value: [][]byte{{1}, {2, 3}}, Bytes: fromHex("020101020203")},
+		{Name: "array/int16", Value: [3]int16{1, -1, 2}, Bytes: fromHex("020104")},
+		{Name: "map/string-int", Value: map[string]int{"a": 1, "b": -1}, Options: []string{"deterministic"}, Bytes: fromHex("020100610201006201")},
+		{Name: "map/int32-string", Value: map[int32]string{1: "x", -1: "y"}, Options: []string{"deterministic"}, Bytes: fromHex("02ffffffff0179010000000178")},
+		{Name: "map/int-bool", Value: map[int]bool{300: true}, Bytes: fromHex("01d80401")},
+		{Name: "struct/fixed", Value: vectorPoint{X: 1, Y: -2}, Bytes: fromHex("0100feff")},
+		{Name: "struct/record", Value: vectorRecord{ID: 42, Name: "roman", Tags: []string{"x"}, Score: 0.5}, Bytes: fromHex("2a05726f6d616e010178000000000000e03f")},
+		{Name: "struct/omitempty-zero", Value: vectorSparse{C: true}, Bytes: fromHex("0001")},
+		{Name: "struct/omitempty-set", Value: vectorSparse{A: 1, B: "b", C: true}, Bytes: fromHex("0302016201")},
+		{Name: "struct/pointer-nil", Value: vectorNested{Point: vectorPoint{X: 1}}, Bytes: fromHex("0100000000")},
+		{Name: "struct/pointer-set", Value: vectorNested{Next: &vectorNested{Point: vectorPoint{Y: 1}}}, Bytes: fromHex("00000000010000010000")},
+		{Name: "struct/versioned", Value: vectorVersioned{Name: "a", Age: 30}, Options: []string{"versioned"}, Bytes: fromHex("0102016103013c00")},
+		{Name: "null/valid", Value: Null[int]{V: 5, Valid: true}, Bytes: fromHex("010a")},
+		{Name: "null/invalid", Value: Null[int]{}, Bytes: fromHex("00")},
+		{Name: "time/utc", Value: time.Date(2024, 1, 2, 3, 4, 5, 6, time.UTC), Bytes: fromHex("0f010000000edd25742500000006ffff")},
+		{Name: "intern/strings", Value: []string{"ab", "ab", "", "cd", "ab"}, Options: []string{"intern"}, Bytes: fromHex("05046162010004636401")},
+		{Name: "checksum/string", Value: "hello", Options: []string{"checksum"}, Bytes: fromHex("0568656c6c6f9dedecd5")},
+	}
+
+	for i := range vectors {
+		vectors[i].Type = reflect.TypeOf(vectors[i].Value).String()
+	}
+	return vectors
+}
+
+// fromHex decodes the hexadecimal bytes of a vector.
+func fromHex(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+// ------------------------------------------------------------------------------
+
+// Conformance checks that a pair of marshal and unmarshal functions conform to the wire
+// format, by encoding the value of every test vector and comparing it to the expected
+// bytes, then decoding these bytes back and comparing the result to the value. It returns
+// the errors of all the vectors which failed, joined, or nil if all of them passed. This
+// allows wrappers and reimplementations of the package to be tested with, for example,
+// Conformance(binary.Marshal, binary.Unmarshal).
+func Conformance(marshal func(interface{}, ...Option) ([]byte, error), unmarshal func([]byte, interface{}, ...Option) error) error {
+	var errs []error
+	for _, v := range Vectors() {
+		if err := conform(&v, marshal, unmarshal); err != nil {
+			errs = append(errs, fmt.Errorf("binary: vector %s: %w", v.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// conform checks a single test vector against the marshal and unmarshal functions.
+func conform(v *Vector, marshal func(interface{}, ...Option) ([]byte, error), unmarshal func([]byte, interface{}, ...Option) error) error {
+	opts, err := v.options()
+	if err != nil {
+		return err
+	}
+
+	b, err := marshal(v.Value, opts...)
+	switch {
+	case err != nil:
+		return fmt.Errorf("unable to encode: %w", err)
+	case string(b) != string(v.Bytes):
+		return fmt.Errorf("encoded as %x, expected %x", b, v.Bytes)
+	}
+
+	out := reflect.New(reflect.TypeOf(v.Value))
+	switch err := unmarshal(v.Bytes, out.Interface(), opts...); {
+	case err != nil:
+		return fmt.Errorf("unable to decode: %w", err)
+	case !reflect.DeepEqual(v.Value, out.Elem().Interface()):
+		return fmt.Errorf("decoded as %v, expected %v", out.Elem().Interface(), v.Value)
+	}
+	return nil
+}
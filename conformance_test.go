@@ -0,0 +1,80 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package binary
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var update = flag.Bool("update", false, "update the conformance test vectors of the specification")
+
+// The file the conformance test vectors are published in
+const vectorsFile = "spec/vectors.json"
+
+func TestConformance(t *testing.T) {
+	assert.NoError(t, Conformance(Marshal, Unmarshal))
+
+	// The streaming encoder and decoder conform as well
+	assert.NoError(t, Conformance(func(v interface{}, opts ...Option) ([]byte, error) {
+		var buffer bytes.Buffer
+		err := NewEncoder(&buffer, opts...).Encode(v)
+		return buffer.Bytes(), err
+	}, func(b []byte, v interface{}, opts ...Option) error {
+		return NewDecoder(bytes.NewReader(b), opts...).Decode(v)
+	}))
+}
+
+func TestConformance_Errors(t *testing.T) {
+	err := Conformance(func(v interface{}, opts ...Option) ([]byte, error) {
+		if s, ok := v.(string); ok && s == "hello" {
+			return []byte("hello"), nil
+		}
+		return Marshal(v, opts...)
+	}, Unmarshal)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "vector string/ascii: encoded as 68656c6c6f")
+
+	failure := errors.New("failure")
+	err = Conformance(Marshal, func([]byte, interface{}, ...Option) error {
+		return failure
+	})
+	assert.True(t, errors.Is(err, failure))
+	assert.Equal(t, len(Vectors()), strings.Count(err.Error(), "unable to decode"))
+}
+
+func TestConformance_Vectors(t *testing.T) {
+	names := make(map[string]bool)
+	for _, v := range Vectors() {
+		assert.False(t, names[v.Name], v.Name)
+		names[v.Name] = true
+
+		// Every vector can be decoded without its type
+		opts, err := v.options()
+		assert.NoError(t, err)
+		schema, err := SchemaOf(reflect.TypeOf(v.Value))
+		assert.NoError(t, err)
+		assert.NoError(t, Walk(v.Bytes, schema, func(Token) error { return nil }, opts...), v.Name)
+	}
+
+	b, err := json.MarshalIndent(Vectors(), "", "  ")
+	assert.NoError(t, err)
+	b = append(b, '\n')
+	if *update {
+		assert.NoError(t, os.WriteFile(vectorsFile, b, 0644))
+	}
+
+	// The published vectors are the ones of the package
+	published, err := os.ReadFile(vectorsFile)
+	assert.NoError(t, err)
+	assert.Equal(t, string(published), string(b), "run go test -run TestConformance -update")
+}
@@ -0,0 +1,361 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package binary
+
+import (
+	"maps"
+	"reflect"
+	"slices"
+	"unsafe"
+)
+
+// The reflected types of the keys and values of the containers with a codec of their own
+var (
+	typeString    = reflect.TypeOf("")
+	typeInterface = reflect.TypeOf((*interface{})(nil)).Elem()
+)
+
+// scanContainer returns a codec which encodes the most common containers of strings and
+// bytes with typed loops rather than element by element through reflection, namely
+// []string, [][]byte, map[string]string and map[string]interface{}, as well as the types
+// defined as one of them. The reflection-based codec of the container is returned for
+// other types, and kept as a fallback for the other formats and schemas otherwise, since
+// the encoding is the same.
+func scanContainer(t reflect.Type, fallback Codec) Codec {
+	switch codec := fallback.(type) {
+	case *reflectSliceCodec:
+		switch codec.elemCodec.(type) {
+		case *stringCodec:
+			return &stringSliceCodec{fallback: fallback}
+		case *byteSliceCodec:
+			return &bytesSliceCodec{fallback: fallback}
+		}
+
+	case *reflectMapCodec:
+		if t.Key() != typeString {
+			break
+		}
+
+		switch codec.val.(type) {
+		case *stringCodec:
+			return &stringMapCodec{fallback: fallback}
+		case *interfaceCodec:
+			if t.Elem().NumMethod() == 0 {
+				return &anyMapCodec{fallback: fallback}
+			}
+		}
+	}
+	return fallback
+}
+
+// stringMapOf returns the map held by a reflected value, whose values must have the same
+// memory layout as V, without copying it even if it is not addressable.
+func stringMapOf[V any](rv reflect.Value) map[string]V {
+	p := rv.UnsafePointer()
+	return *(*map[string]V)(unsafe.Pointer(&p))
+}
+
+// addressOf returns a pointer to the addressable value of a decoded container, whose type
+// must have the same memory layout as T.
+func addressOf[T any](rv reflect.Value) *T {
+	return (*T)(rv.Addr().UnsafePointer())
+}
+
+// ------------------------------------------------------------------------------
+
+// stringSliceCodec represents a codec for slices of strings.
+type stringSliceCodec struct {
+	fallback Codec // The reflection-based codec of the slice
+}
+
+// Encode encodes a value into the encoder.
+func (c *stringSliceCodec) EncodeTo(e *Encoder, rv reflect.Value) (err error) {
+	v := sliceOf[string](rv)
+	e.WriteUvarint(uint64(len(v)))
+	for i, s := range v {
+		if err = e.opts().canceled(i); err != nil {
+			return
+		}
+		e.WriteString(s)
+	}
+	return
+}
+
+// Decode decodes into a reflect value from the decoder.
+func (c *stringSliceCodec) DecodeTo(d *Decoder, rv reflect.Value) (err error) {
+	if err = d.enter(); err != nil {
+		return
+	}
+	defer d.leave()
+
+	var l int
+	var slice reflect.Value
+	if l, err = d.readSliceLen(); err != nil {
+		return
+	}
+	if slice, err = d.makeSlice(rv, l); err != nil {
+		return
+	}
+
+	rv.Set(slice)
+	out := addressOf[[]string](rv)
+	for i := 0; i < l; i++ {
+		if err = d.opts.canceled(i); err != nil {
+			return
+		}
+		if i == len(*out) {
+			rv.Set(growSlice(rv, l))
+		}
+		if (*out)[i], err = d.ReadString(); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// Size returns the encoded size of the value.
+func (c *stringSliceCodec) Size(rv reflect.Value) int {
+	v := sliceOf[string](rv)
+	size := uvarintSize(uint64(len(v)))
+	for _, s := range v {
+		size += uvarintSize(uint64(len(s))) + len(s)
+	}
+	return size
+}
+
+// ------------------------------------------------------------------------------
+
+// bytesSliceCodec represents a codec for slices of byte slices.
+type bytesSliceCodec struct {
+	fallback Codec // The reflection-based codec of the slice
+}
+
+// Encode encodes a value into the encoder.
+func (c *bytesSliceCodec) EncodeTo(e *Encoder, rv reflect.Value) (err error) {
+	v := sliceOf[[]byte](rv)
+	e.WriteUvarint(uint64(len(v)))
+	for i, b := range v {
+		if err = e.opts().canceled(i); err != nil {
+			return
+		}
+		e.WriteLengthPrefixed(b)
+	}
+	return
+}
+
+// Decode decodes into a reflect value from the decoder. The byte slices of the destination
+// are reused like the ones decoded on their own.
+func (c *bytesSliceCodec) DecodeTo(d *Decoder, rv reflect.Value) (err error) {
+	if err = d.enter(); err != nil {
+		return
+	}
+	defer d.leave()
+
+	var l int
+	var slice reflect.Value
+	if l, err = d.readSliceLen(); err != nil {
+		return
+	}
+	if slice, err = d.makeSlice(rv, l); err != nil {
+		return
+	}
+
+	rv.Set(slice)
+	out := addressOf[[][]byte](rv)
+	for i := 0; i < l; i++ {
+		if err = d.opts.canceled(i); err != nil {
+			return
+		}
+		if i == len(*out) {
+			rv.Set(growSlice(rv, l))
+		}
+		if err = d.readBytesTo(&(*out)[i]); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// Size returns the encoded size of the value.
+func (c *bytesSliceCodec) Size(rv reflect.Value) int {
+	v := sliceOf[[]byte](rv)
+	size := uvarintSize(uint64(len(v)))
+	for _, b := range v {
+		size += uvarintSize(uint64(len(b))) + len(b)
+	}
+	return size
+}
+
+// ------------------------------------------------------------------------------
+
+// stringMapCodec represents a codec for maps of strings to strings.
+type stringMapCodec struct {
+	fallback Codec // The reflection-based codec of the map
+}
+
+// Encode encodes a value into the encoder.
+func (c *stringMapCodec) EncodeTo(e *Encoder, rv reflect.Value) (err error) {
+	m := stringMapOf[string](rv)
+	e.WriteUvarint(uint64(len(m)))
+	if e.opts().sortKeys {
+		for i, k := range slices.Sorted(maps.Keys(m)) {
+			if err = e.opts().canceled(i); err != nil {
+				return
+			}
+			e.writeKeyString(k)
+			e.WriteString(m[k])
+		}
+		return
+	}
+
+	i := 0
+	for k, v := range m {
+		if err = e.opts().canceled(i); err != nil {
+			return
+		}
+		e.writeKeyString(k)
+		e.WriteString(v)
+		i++
+	}
+	return
+}
+
+// Decode decodes into a reflect value from the decoder.
+func (c *stringMapCodec) DecodeTo(d *Decoder, rv reflect.Value) (err error) {
+	if err = d.enter(); err != nil {
+		return
+	}
+	defer d.leave()
+
+	var l int
+	if l, err = d.readSliceOf(2 * typeString.Size()); err != nil {
+		return
+	}
+
+	m := mapOf[string](rv)
+	for i := 0; i < l; i++ {
+		if err = d.opts.canceled(i); err != nil {
+			return
+		}
+
+		var k, v string
+		if k, err = d.readKeyString(); err != nil {
+			return
+		}
+		if v, err = d.ReadString(); err != nil {
+			return
+		}
+		m[k] = v
+	}
+	return
+}
+
+// Size returns the encoded size of the value.
+func (c *stringMapCodec) Size(rv reflect.Value) int {
+	m := stringMapOf[string](rv)
+	size := uvarintSize(uint64(len(m)))
+	for k, v := range m {
+		size += 2 + len(k) + uvarintSize(uint64(len(v))) + len(v)
+	}
+	return size
+}
+
+// mapOf returns the map held by a reflected value, which is cleared to reuse its buckets,
+// or allocated if it is nil.
+func mapOf[V any](rv reflect.Value) map[string]V {
+	if rv.IsNil() {
+		rv.Set(reflect.MakeMap(rv.Type()))
+	}
+
+	m := *addressOf[map[string]V](rv)
+	clear(m)
+	return m
+}
+
+// ------------------------------------------------------------------------------
+
+// anyMapCodec represents a codec for maps of strings to values of any type, such as the
+// documents decoded from JSON. The values are prefixed with the name their type was
+// registered with, as in any other interface.
+type anyMapCodec struct {
+	fallback Codec // The reflection-based codec of the map
+}
+
+// Encode encodes a value into the encoder.
+func (c *anyMapCodec) EncodeTo(e *Encoder, rv reflect.Value) (err error) {
+	m := stringMapOf[interface{}](rv)
+	if len(m) > 0 {
+		if err = e.follow(rv); err != nil {
+			return
+		}
+		defer e.unfollow(rv)
+	}
+
+	e.WriteUvarint(uint64(len(m)))
+	if e.opts().sortKeys {
+		for i, k := range slices.Sorted(maps.Keys(m)) {
+			if err = e.opts().canceled(i); err != nil {
+				return
+			}
+			if err = c.writeEntry(e, k, m[k]); err != nil {
+				return
+			}
+		}
+		return
+	}
+
+	i := 0
+	for k, v := range m {
+		if err = e.opts().canceled(i); err != nil {
+			return
+		}
+		if err = c.writeEntry(e, k, v); err != nil {
+			return
+		}
+		i++
+	}
+	return
+}
+
+// writeEntry writes a key of the map along with its value.
+func (c *anyMapCodec) writeEntry(e *Encoder, k string, v interface{}) error {
+	e.writeKeyString(k)
+	return e.writeInterface(reflect.ValueOf(v), typeInterface)
+}
+
+// Decode decodes into a reflect value from the decoder.
+func (c *anyMapCodec) DecodeTo(d *Decoder, rv reflect.Value) (err error) {
+	if err = d.enter(); err != nil {
+		return
+	}
+	defer d.leave()
+
+	var l int
+	if l, err = d.readSliceOf(typeString.Size() + typeInterface.Size()); err != nil {
+		return
+	}
+
+	m := mapOf[interface{}](rv)
+	for i := 0; i < l; i++ {
+		if err = d.opts.canceled(i); err != nil {
+			return
+		}
+
+		var k string
+		var v reflect.Value
+		if k, err = d.readKeyString(); err != nil {
+			return
+		}
+		if v, err = d.readInterface(typeInterface); err != nil {
+			return
+		}
+
+		if v.IsValid() {
+			m[k] = v.Interface()
+		} else {
+			m[k] = nil
+		}
+	}
+	return
+}
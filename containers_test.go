@@ -0,0 +1,206 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package binary
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type labels map[string]string
+
+type labelName string
+
+// upperName is a string encoded in upper case by its own marshaler
+type upperName string
+
+func (n upperName) MarshalBinary() ([]byte, error) {
+	return []byte(strings.ToUpper(string(n))), nil
+}
+
+func (n *upperName) UnmarshalBinary(b []byte) error {
+	*n = upperName(b)
+	return nil
+}
+
+type document struct {
+	Tags    []string
+	Chunks  [][]byte
+	Labels  labels
+	Fields  map[string]interface{}
+	Aliases map[string]string `binary:",omitempty"`
+}
+
+func newDocument() *document {
+	return &document{
+		Tags:   []string{"a", "", "bb", "a"},
+		Chunks: [][]byte{[]byte("hello"), nil, {0, 1, 2}},
+		Labels: labels{"env": "prod", "region": "eu", "": "empty"},
+		Fields: map[string]interface{}{"square": square{Side: 2}, "name": "doc", "none": nil},
+	}
+}
+
+// fallbackOf returns the reflection-based codec of a container.
+func fallbackOf(c Codec) Codec {
+	switch codec := c.(type) {
+	case *stringSliceCodec:
+		return codec.fallback
+	case *bytesSliceCodec:
+		return codec.fallback
+	case *stringMapCodec:
+		return codec.fallback
+	case *anyMapCodec:
+		return codec.fallback
+	default:
+		return nil
+	}
+}
+
+func TestContainers_Scan(t *testing.T) {
+	tests := []struct {
+		value     interface{}
+		container bool
+	}{
+		{[]string{}, true},
+		{[][]byte{}, true},
+		{map[string]string{}, true},
+		{map[string]interface{}{}, true},
+		{labels{}, true},
+		{[]labelName{}, true},
+		{[]shape{}, false},
+		{[]upperName{}, false},
+		{map[labelName]string{}, false},
+		{map[string]upperName{}, false},
+		{map[string]shape{}, false},
+		{map[string][]byte{}, false},
+	}
+
+	for _, tc := range tests {
+		c, err := scan(reflect.TypeOf(tc.value))
+		assert.NoError(t, err)
+		assert.Equal(t, tc.container, fallbackOf(c) != nil, "%T", tc.value)
+	}
+}
+
+func TestContainers_Fallback(t *testing.T) {
+	for _, opts := range [][]Option{nil, {Deterministic()}, {InternStrings(), Deterministic()}} {
+		for _, v := range []interface{}{
+			[]string{"a", "", "bb", "a"},
+			[][]byte{[]byte("hello"), nil, {1}},
+			labels{"env": "prod", "region": "eu", "env2": "prod"},
+			map[string]interface{}{"square": square{Side: 2}, "name": "doc", "none": nil},
+		} {
+			c, err := scan(reflect.TypeOf(v))
+			assert.NoError(t, err)
+
+			// The encoding is the one of the reflection-based codec
+			b, err := marshalWith(c, reflect.ValueOf(v), opts)
+			assert.NoError(t, err)
+			expect, err := marshalWith(fallbackOf(c), reflect.ValueOf(v), opts)
+			assert.NoError(t, err)
+			if len(opts) > 0 {
+				assert.Equal(t, expect, b, "%T", v)
+			}
+
+			out := reflect.New(reflect.TypeOf(v))
+			assert.NoError(t, Unmarshal(expect, out.Interface(), opts...))
+			assert.Equal(t, v, out.Elem().Interface())
+		}
+	}
+}
+
+func TestContainers_Roundtrip(t *testing.T) {
+	for _, opts := range [][]Option{nil, {Versioned()}, {InternStrings()}, {ZeroCopy()}} {
+		v := newDocument()
+		b, err := Marshal(v, opts...)
+		assert.NoError(t, err)
+
+		if len(opts) == 0 {
+			n, err := Size(v)
+			assert.NoError(t, err)
+			assert.Equal(t, len(b), n)
+		}
+
+		var out document
+		assert.NoError(t, Unmarshal(b, &out, opts...))
+		assert.Equal(t, v, &out)
+
+		// Streams are decoded as well
+		out = document{}
+		assert.NoError(t, NewDecoder(iotest.HalfReader(bytes.NewReader(b)), opts...).Decode(&out))
+		assert.Equal(t, v.Labels, out.Labels)
+		assert.Equal(t, v.Fields, out.Fields)
+	}
+}
+
+func TestContainers_Reuse(t *testing.T) {
+	b, err := Marshal(newDocument())
+	assert.NoError(t, err)
+
+	// The byte slices and the maps of the destination are reused
+	chunk := make([]byte, 0, 16)
+	out := document{
+		Chunks: [][]byte{chunk, nil, nil, nil},
+		Labels: labels{"stale": "value"},
+	}
+	labels := out.Labels
+	assert.NoError(t, Unmarshal(b, &out))
+	assert.Equal(t, []byte("hello"), out.Chunks[0])
+	assert.True(t, &chunk[:1][0] == &out.Chunks[0][0])
+	assert.Equal(t, 3, len(labels))
+	assert.Equal(t, "prod", labels["env"])
+}
+
+func TestContainers_Errors(t *testing.T) {
+	_, err := Marshal(map[string]interface{}{"chan": make(chan int)})
+	assert.Error(t, err)
+
+	b, err := Marshal(newDocument())
+	assert.NoError(t, err)
+	for i := 0; i < len(b); i++ {
+		var out document
+		assert.Error(t, Unmarshal(b[:i], &out))
+	}
+
+	var out document
+	assert.Error(t, Unmarshal(b, &out, MaxDepth(1)))
+	assert.Error(t, Unmarshal(b, &out, MaxStringLen(1)))
+
+	// Containers are skipped when they are not projected
+	out = document{}
+	assert.NoError(t, Unmarshal(b, &out, Fields("Fields")))
+	assert.Equal(t, document{Fields: newDocument().Fields}, out)
+}
+
+func BenchmarkContainers(b *testing.B) {
+	v := newDocument()
+	for i := 0; i < 100; i++ {
+		v.Tags = append(v.Tags, "tag")
+		v.Labels[string(rune('a'+i%26))+string(rune('a'+i/26))] = "value"
+	}
+
+	enc, _ := Marshal(v)
+	b.Run("marshal", func(b *testing.B) {
+		buffer := make([]byte, 0, len(enc))
+		b.ReportAllocs()
+		b.ResetTimer()
+		for n := 0; n < b.N; n++ {
+			MarshalTo(buffer[:0], v)
+		}
+	})
+
+	b.Run("unmarshal", func(b *testing.B) {
+		var out document
+		b.ReportAllocs()
+		b.ResetTimer()
+		for n := 0; n < b.N; n++ {
+			Unmarshal(enc, &out)
+		}
+	})
+}
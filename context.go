@@ -0,0 +1,47 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package binary
+
+import (
+	"context"
+	"reflect"
+)
+
+// MarshalContext encodes the payload into binary format like Marshal, but aborts with the
+// error of the context once it is done. The context is checked between the fields of
+// structs and every 1024 elements of slices and maps, so that encoding a large value can
+// be cancelled along with the request which needs it.
+func MarshalContext(ctx context.Context, v interface{}, opts ...Option) ([]byte, error) {
+	return marshal(reflect.Indirect(reflect.ValueOf(v)), withOptions(opts, withContext(ctx)))
+}
+
+// UnmarshalContext decodes the payload from the binary format like Unmarshal, but aborts
+// with the error of the context once it is done, which is checked like MarshalContext.
+func UnmarshalContext(ctx context.Context, b []byte, v interface{}, opts ...Option) error {
+	return Unmarshal(b, v, withOptions(opts, withContext(ctx))...)
+}
+
+// EncodeContext encodes a value like Encode, but aborts with the error of the context
+// once it is done, which is checked like MarshalContext. The bytes which were written
+// before the cancellation are left in the stream.
+func (e *Encoder) EncodeContext(ctx context.Context, v interface{}) error {
+	e.extend().opts.ctx = ctx
+	err := e.Encode(v)
+	e.ext.opts.ctx = nil
+	return err
+}
+
+// DecodeContext decodes a value like Decode, but aborts with the error of the context
+// once it is done, which is checked like MarshalContext.
+func (d *Decoder) DecodeContext(ctx context.Context, v interface{}) error {
+	d.opts.ctx = ctx
+	err := d.Decode(v)
+	d.opts.ctx = nil
+	return err
+}
+
+// withOptions appends an option to a set of options, without modifying the caller's slice.
+func withOptions(opts []Option, opt Option) []Option {
+	return append(opts[:len(opts):len(opts)], opt)
+}
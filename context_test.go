@@ -0,0 +1,51 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package binary
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContext(t *testing.T) {
+	type item struct {
+		ID   int
+		Name string
+	}
+
+	items := make([]item, 5000)
+	ctx := context.Background()
+	b, err := MarshalContext(ctx, items)
+	assert.NoError(t, err)
+
+	var out []item
+	assert.NoError(t, UnmarshalContext(ctx, b, &out))
+	assert.Equal(t, items, out)
+
+	// A canceled context aborts both sides
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+
+	_, err = MarshalContext(canceled, items)
+	assert.Equal(t, context.Canceled, err)
+	assert.Equal(t, context.Canceled, UnmarshalContext(canceled, b, &out))
+	assert.Equal(t, context.Canceled, UnmarshalContext(canceled, b, &out, Versioned()))
+
+	// The context only applies to a single call
+	var buffer bytes.Buffer
+	e := NewEncoder(&buffer)
+	assert.Equal(t, context.Canceled, e.EncodeContext(canceled, map[string]int{"a": 1}))
+	buffer.Reset()
+	assert.NoError(t, e.Encode(map[string]int{"a": 1}))
+
+	d := NewDecoder(bytes.NewReader(buffer.Bytes()))
+	var m map[string]int
+	assert.Equal(t, context.Canceled, d.DecodeContext(canceled, &m))
+	d.Reset(bytes.NewReader(buffer.Bytes()))
+	assert.NoError(t, d.DecodeContext(ctx, &m))
+	assert.Equal(t, map[string]int{"a": 1}, m)
+}
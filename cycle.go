@@ -0,0 +1,113 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package binary
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"unsafe"
+)
+
+// ErrCycle is returned when encoding a value which refers to itself, such as a circular
+// linked list, which would otherwise be encoded forever.
+var ErrCycle = errors.New("binary: encountered a cycle")
+
+// The number of pointers, maps and slices nested within each other after which the encoder
+// starts recording them to detect cycles, as this is only worth it for deep values.
+const startDetectingCycles = 1000
+
+// reference identifies a pointer, a map or a slice being encoded. The type is part of it,
+// since a pointer to a struct has the same address as a pointer to its first field.
+type reference struct {
+	ptr unsafe.Pointer
+	len int
+	typ reflect.Type
+}
+
+// referenceOf returns the reference of a pointer, a map or a slice.
+func referenceOf(rv reflect.Value) reference {
+	ref := reference{ptr: rv.UnsafePointer(), typ: rv.Type()}
+	if rv.Kind() == reflect.Slice {
+		ref.len = rv.Len()
+	}
+	return ref
+}
+
+// follow records a pointer, a map or a slice which is about to be encoded, and fails with
+// ErrCycle if it is already being encoded further up. It must be followed by a call to
+// unfollow once the value is encoded, unless it returns an error.
+func (e *Encoder) follow(rv reflect.Value) error {
+	x := e.extend()
+	if x.refs++; x.refs <= startDetectingCycles {
+		return nil
+	}
+
+	ref := referenceOf(rv)
+	if _, ok := x.visited[ref]; ok {
+		x.refs--
+		return fmt.Errorf("%w via %s", ErrCycle, rv.Type())
+	}
+
+	if x.visited == nil {
+		x.visited = make(map[reference]struct{})
+	}
+	x.visited[ref] = struct{}{}
+	return nil
+}
+
+// unfollow removes a pointer, a map or a slice which was encoded.
+func (e *Encoder) unfollow(rv reflect.Value) {
+	x := e.ext
+	if x.refs > startDetectingCycles {
+		delete(x.visited, referenceOf(rv))
+	}
+	x.refs--
+}
+
+// ------------------------------------------------------------------------------
+
+// Map of whether the values of a type may refer to themselves
+var recursiveTypes = new(sync.Map)
+
+// recursive returns whether the values of a type may refer to themselves, in which case
+// their size is not computed upfront, as a cycle would never end. Interfaces may hold a
+// value of any type, so they are assumed to be recursive.
+func recursive(t reflect.Type) bool {
+	if v, ok := recursiveTypes.Load(t); ok {
+		return v.(bool)
+	}
+
+	v := reachesItself(t, make(map[reflect.Type]bool))
+	recursiveTypes.Store(t, v)
+	return v
+}
+
+// reachesItself returns whether a type may contain a cycle. The state records whether
+// a type is being visited (true) or was visited without finding any cycle (false).
+func reachesItself(t reflect.Type, state map[reflect.Type]bool) bool {
+	if visiting, ok := state[t]; ok {
+		return visiting
+	}
+
+	state[t] = true
+	defer func() { state[t] = false }()
+
+	switch t.Kind() {
+	case reflect.Interface:
+		return true
+	case reflect.Ptr, reflect.Slice, reflect.Array:
+		return reachesItself(t.Elem(), state)
+	case reflect.Map:
+		return reachesItself(t.Key(), state) || reachesItself(t.Elem(), state)
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			if reachesItself(t.Field(i).Type, state) {
+				return true
+			}
+		}
+	}
+	return false
+}
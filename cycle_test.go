@@ -0,0 +1,79 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package binary
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type cycleNode struct {
+	Value    int
+	Next     *cycleNode
+	Children []*cycleNode
+}
+
+func TestEncodeCycle(t *testing.T) {
+	list := &cycleNode{Value: 1, Next: &cycleNode{Value: 2}}
+	list.Next.Next = list
+
+	_, err := Marshal(list)
+	assert.True(t, errors.Is(err, ErrCycle))
+
+	_, err = Size(list)
+	assert.True(t, errors.Is(err, ErrCycle))
+
+	var buffer bytes.Buffer
+	e := NewEncoder(&buffer)
+	assert.True(t, errors.Is(e.Encode(list), ErrCycle))
+
+	// The encoder can be used again
+	e.Reset(&buffer)
+	assert.NoError(t, e.Encode(&cycleNode{Value: 1}))
+
+	// A cycle through a slice
+	tree := &cycleNode{Children: []*cycleNode{{}}}
+	tree.Children[0].Next = tree
+	_, err = Marshal(tree)
+	assert.True(t, errors.Is(err, ErrCycle))
+}
+
+func TestEncodeDeep(t *testing.T) {
+	shared := &cycleNode{Value: -1}
+
+	var list *cycleNode
+	for i := 0; i < 5000; i++ {
+		list = &cycleNode{Value: i, Next: list}
+		if i%100 == 0 {
+			list.Children = []*cycleNode{shared, shared}
+		}
+	}
+
+	b, err := Marshal(list)
+	assert.NoError(t, err)
+
+	size, err := Size(list)
+	assert.NoError(t, err)
+	assert.Equal(t, len(b), size)
+
+	var out cycleNode
+	assert.NoError(t, Unmarshal(b, &out))
+	assert.Equal(t, *list, out)
+}
+
+func TestRecursive(t *testing.T) {
+	type tree struct {
+		Left, Right *tree
+	}
+
+	assert.True(t, recursive(reflect.TypeOf(new(cycleNode)).Elem()))
+	assert.True(t, recursive(reflect.TypeOf(new(tree)).Elem()))
+	assert.True(t, recursive(reflect.TypeOf(new(map[string]interface{})).Elem()))
+	assert.False(t, recursive(reflect.TypeOf(new(s0)).Elem()))
+	assert.False(t, recursive(reflect.TypeOf(new(map[string][]*int)).Elem()))
+}
@@ -4,19 +4,62 @@
 package binary
 
 import (
+	"bufio"
 	"encoding/binary"
 	"errors"
+	"fmt"
 	"io"
 	"math"
+	"math/bits"
 	"reflect"
+	"slices"
 	"sync"
+	"unicode/utf8"
 )
 
 // Reusable long-lived decoder pool.
 var decoders = &sync.Pool{New: func() interface{} {
-	return NewDecoder(newReader(nil))
+	return newDecoder(NewSliceReader(nil))
 }}
 
+// The default size of the read buffer used when decoding from an io.Reader which
+// does not implement io.ByteReader.
+const defaultBufferSize = 4096
+
+// The maximum value of an int
+const maxInt = int(^uint(0) >> 1)
+
+// ErrLimitExceeded is returned when a payload exceeds one of the limits of the decoder.
+var ErrLimitExceeded = errors.New("binary: limit exceeded")
+
+// limitError returns an error which wraps ErrLimitExceeded.
+func limitError(what string, value uint64, max int) error {
+	return fmt.Errorf("%w: %s %d is larger than %d", ErrLimitExceeded, what, value, max)
+}
+
+// ErrBudgetExceeded is returned when decoding a value needs to allocate more than the
+// budget set with the MaxAllocation option.
+var ErrBudgetExceeded = errors.New("binary: allocation budget exceeded")
+
+// ErrTrailingBytes is returned with the Strict option when the input holds more bytes than
+// the decoded value.
+var ErrTrailingBytes = errors.New("binary: trailing bytes after the value")
+
+// ErrOverflow is returned when a decoded integer does not fit into its destination type.
+var ErrOverflow = errors.New("binary: integer overflow")
+
+// IntOverflowError returns an error which wraps ErrOverflow for a signed integer which
+// does not fit into the type. This is also used by the generated code.
+func IntOverflowError(value int64, typ string) error {
+	return fmt.Errorf("%w: %d does not fit into %s", ErrOverflow, value, typ)
+}
+
+// UintOverflowError returns an error which wraps ErrOverflow for an unsigned integer which
+// does not fit into the type. This is also used by the generated code.
+func UintOverflowError(value uint64, typ string) error {
+	return fmt.Errorf("%w: %d does not fit into %s", ErrOverflow, value, typ)
+}
+
 // Reader represents the interface a reader should implement.
 type Reader interface {
 	io.Reader
@@ -24,29 +67,229 @@ type Reader interface {
 }
 
 // Unmarshal decodes the payload from the binary format.
-func Unmarshal(b []byte, v interface{}) (err error) {
+func Unmarshal(b []byte, v interface{}, opts ...Option) (err error) {
+	if ok, err := unmarshalFast(b, v, opts); ok {
+		return err
+	}
+
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	if !rv.CanAddr() {
+		return errors.New("binary: can only Decode to pointer type")
+	}
+
+	return unmarshal(b, rv, opts)
+}
+
+// UnmarshalMerge decodes the payload from the binary format on top of an existing value,
+// overwriting only the fields present in the payload. It is equivalent to Unmarshal with
+// the Merge option, and only makes a difference for structs encoded with omitempty fields
+// or with the Versioned option.
+func UnmarshalMerge(b []byte, v interface{}, opts ...Option) error {
+	return Unmarshal(b, v, withOptions(opts, Merge())...)
+}
+
+// UnmarshalNext decodes the first of several values concatenated in the payload, and
+// returns the number of bytes it consumed, so that the next value can be decoded from the
+// remaining bytes without wrapping them into a reader. The Strict option is ignored, since
+// bytes are expected to follow the value.
+//
+//	for len(b) > 0 {
+//		n, err := binary.UnmarshalNext(b, &v)
+//		...
+//		b = b[n:]
+//	}
+func UnmarshalNext(b []byte, v interface{}, opts ...Option) (int, error) {
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	if !rv.CanAddr() {
+		return 0, errors.New("binary: can only Decode to pointer type")
+	}
+
+	return decodeBytes(b, rv, opts, false)
+}
+
+// unmarshal decodes the payload from the binary format into an addressable value.
+func unmarshal(b []byte, rv reflect.Value, opts []Option) error {
+	_, err := decodeBytes(b, rv, opts, true)
+	return err
+}
 
+// decodeBytes decodes a value from the start of the payload into an addressable value,
+// and returns the number of bytes consumed. Unless the whole payload holds the value,
+// the Strict option is ignored.
+func decodeBytes(b []byte, rv reflect.Value, opts []Option, whole bool) (n int, err error) {
+	var c Codec
+	if c, err = scan(rv.Type()); err != nil {
+		return
+	}
+	return decodeBytesWith(b, c, rv, opts, whole)
+}
+
+// decodeBytesWith decodes a value from the start of the payload with its codec.
+func decodeBytesWith(b []byte, c Codec, rv reflect.Value, opts []Option, whole bool) (n int, err error) {
 	// Get the decoder from the pool, reset it
 	d := decoders.Get().(*Decoder)
-	d.r.(*reader).Reset(b) // Reset the reader
+	d.r.(*SliceReader).Reset(b) // Reset the reader
+	d.opts.reset(opts)
+	d.depth = 0
 
 	// Decode and set the buffer if successful and free the decoder
-	err = d.Decode(v)
+	if err = d.decodeWith(c, rv); err == nil && whole {
+		err = d.consumed()
+	}
+	if err == nil {
+		n = len(b) - d.s.Len()
+	}
 	decoders.Put(d)
 	return
 }
 
+// consumed returns ErrTrailingBytes with the Strict option if the bytes being decoded were
+// not entirely consumed.
+func (d *Decoder) consumed() error {
+	if !d.opts.strict || d.s == nil {
+		return nil
+	}
+
+	if n := d.s.Len(); n > 0 {
+		return fmt.Errorf("%w: %d bytes were left", ErrTrailingBytes, n)
+	}
+	return nil
+}
+
+// ErrSizeExceeded is returned by DecodeLimited when a value needs more bytes than allowed.
+var ErrSizeExceeded = errors.New("binary: size exceeded")
+
+// DecodeLimited decodes a single value from the reader, reading at most max bytes from it
+// and returning ErrSizeExceeded if the value needs more than that. This is useful when
+// consuming payloads from untrusted peers. If the reader does not implement io.ByteReader,
+// it gets buffered and more bytes than the value needs may be read from it, up to max.
+func DecodeLimited(r io.Reader, v interface{}, max int64, opts ...Option) error {
+	var lr io.Reader = &limitedReader{r: r, n: max}
+	if br, ok := r.(Reader); ok {
+		lr = &limitedByteReader{limitedReader{r: br, n: max}, br}
+	}
+
+	size := defaultBufferSize
+	if max < int64(size) {
+		size = int(max) + 1 // bufio needs a positive size
+	}
+
+	return NewDecoderSize(lr, size, opts...).Decode(v)
+}
+
+// limitedReader reads from a reader up to a limit, and fails with ErrSizeExceeded after that.
+type limitedReader struct {
+	r io.Reader
+	n int64 // The number of bytes remaining
+}
+
+// Read implements io.Reader interface.
+func (l *limitedReader) Read(p []byte) (n int, err error) {
+	if l.n <= 0 {
+		return 0, ErrSizeExceeded
+	}
+
+	if int64(len(p)) > l.n {
+		p = p[:l.n]
+	}
+
+	n, err = l.r.Read(p)
+	l.n -= int64(n)
+	return
+}
+
+// limitedByteReader reads from a byte reader up to a limit, and fails with ErrSizeExceeded
+// after that.
+type limitedByteReader struct {
+	limitedReader
+	br io.ByteReader
+}
+
+// ReadByte implements io.ByteReader interface.
+func (l *limitedByteReader) ReadByte() (byte, error) {
+	if l.n <= 0 {
+		return 0, ErrSizeExceeded
+	}
+
+	b, err := l.br.ReadByte()
+	if err == nil {
+		l.n--
+	}
+	return b, err
+}
+
 // Decoder represents a binary decoder.
 type Decoder struct {
 	r       Reader
-	s       *reader // Not using the interface for better inlining
+	s       *SliceReader // Not using the interface for better inlining
 	scratch [10]byte
+	opts    options
+	depth   int            // The current nesting depth
+	nesting int            // The number of nested calls to decode
+	project projection     // The fields of the current struct to decode, or nil for all
+	sum     checksumReader // The checksum of the current value, if enabled
+
+	buffered *bufio.Reader   // The buffered reader owned by the decoder, if any
+	interned []string        // The table of interned strings
+	pointers []reflect.Value // The table of shared pointers
+	spent    int             // The number of bytes allocated for the current value
+	budget   *int            // The number of bytes allocated by the outermost decoder, if nested
+}
+
+// NewDecoder creates a binary decoder. If the reader does not implement io.ByteReader,
+// it gets wrapped in a buffered reader, so the decoder may read more data than it
+// strictly needs from the underlying reader (e.g. a net.Conn or an os.File).
+func NewDecoder(r io.Reader, opts ...Option) *Decoder {
+	return NewDecoderSize(r, defaultBufferSize, opts...)
 }
 
-// NewDecoder creates a binary decoder.
-func NewDecoder(r Reader) *Decoder {
-	var slicer *reader
-	if s, ok := r.(*reader); ok {
+// NewDecoderSize creates a binary decoder with a read buffer of at least the specified
+// size. The buffer is only used if the reader does not implement io.ByteReader.
+func NewDecoderSize(r io.Reader, size int, opts ...Option) *Decoder {
+	d := newDecoder(asReader(r, size))
+	if _, ok := r.(Reader); !ok {
+		d.buffered = d.r.(*bufio.Reader)
+	}
+
+	d.opts.reset(opts)
+	return d
+}
+
+// Reset discards the state of the decoder, along with any buffered data, and switches it
+// to reading from another reader while keeping its options. This allows long-lived
+// decoders to be reused against changing readers, such as connections, without any
+// allocation once their buffer exists.
+func (d *Decoder) Reset(r io.Reader) {
+	progress, _ := d.r.(*progressReader)
+	switch br, ok := r.(Reader); {
+	case ok:
+		d.r = br
+	case d.buffered != nil:
+		d.buffered.Reset(r)
+		d.r = d.buffered
+	default:
+		d.buffered = bufio.NewReaderSize(r, defaultBufferSize)
+		d.r = d.buffered
+	}
+
+	if progress != nil {
+		progress.Reader = d.r
+		progress.reset()
+		d.r = progress
+	}
+
+	d.s, _ = d.r.(*SliceReader)
+	d.depth = 0
+	d.nesting = 0
+	d.project = nil
+	d.sum = checksumReader{}
+	d.clearTables()
+}
+
+// newDecoder creates a binary decoder on top of a byte reader.
+func newDecoder(r Reader) *Decoder {
+	var slicer *SliceReader
+	if s, ok := r.(*SliceReader); ok {
 		slicer = s
 	}
 
@@ -56,6 +299,37 @@ func NewDecoder(r Reader) *Decoder {
 	}
 }
 
+// nested acquires a decoder for a nested payload, inheriting the options of this
+// decoder. The returned decoder must be released once it is no longer needed.
+func (d *Decoder) nested(b []byte) *Decoder {
+	n := decoders.Get().(*Decoder)
+	n.r.(*SliceReader).Reset(b)
+	n.opts = d.opts
+	n.depth = d.depth
+	n.nesting = 1 // The value is part of the outermost one
+	n.project = d.project
+	n.budget = d.allocated()
+	return n
+}
+
+// release returns a nested decoder back to the pool.
+func (d *Decoder) release() {
+	d.r.(*SliceReader).Reset(nil)
+	d.nesting = 0
+	d.budget = nil
+	d.clearTables()
+	decoders.Put(d)
+}
+
+// asReader converts an io.Reader into a Reader, buffering it if necessary.
+func asReader(r io.Reader, size int) Reader {
+	if br, ok := r.(Reader); ok {
+		return br
+	}
+
+	return bufio.NewReaderSize(r, size)
+}
+
 // Decode decodes a value by reading from the underlying io.Reader.
 func (d *Decoder) Decode(v interface{}) (err error) {
 	rv := reflect.Indirect(reflect.ValueOf(v))
@@ -63,57 +337,265 @@ func (d *Decoder) Decode(v interface{}) (err error) {
 		return errors.New("binary: can only Decode to pointer type")
 	}
 
+	return d.decode(rv)
+}
+
+// decode decodes into an addressable reflected value.
+func (d *Decoder) decode(rv reflect.Value) (err error) {
 	// Scan the type (this will load from cache)
 	var c Codec
-	if c, err = scan(rv.Type()); err == nil {
+	if c, err = scan(rv.Type()); err != nil {
+		return
+	}
+	return d.decodeWith(c, rv)
+}
+
+// decodeWith decodes into a reflected value with its codec.
+func (d *Decoder) decodeWith(c Codec, rv reflect.Value) (err error) {
+	// The tables of interned strings and shared pointers, and the projection are scoped
+	// to the outermost call, as codecs may decode nested values with Decode.
+	if d.nesting == 0 {
+		if d.opts.shared && d.opts.versioned {
+			return errSharedVersioned
+		}
+		d.spent = 0
+		d.project = d.opts.fields
+		if d.opts.checksum {
+			d.beginChecksum()
+		}
+	}
+
+	if d.nesting++; d.nesting == 1 && d.opts.selfDescribing {
+		_, err = d.readHeader(false)
+	}
+	switch {
+	case err != nil:
+	case d.nesting == 1 && d.opts.compressor != nil:
+		err = d.readCompressed(func(d *Decoder) error {
+			return c.DecodeTo(d, rv)
+		})
+	default:
 		err = c.DecodeTo(d, rv)
 	}
 
+	if d.nesting--; d.nesting == 0 {
+		if d.opts.checksum {
+			err = d.endChecksum(err)
+		}
+		d.clearTables()
+	}
 	return
 }
 
-// Read reads a set of bytes
+// clearTables clears the tables of interned strings and shared pointers.
+func (d *Decoder) clearTables() {
+	if d.interned != nil {
+		clear(d.interned)
+		d.interned = d.interned[:0]
+	}
+	if d.pointers != nil {
+		clear(d.pointers)
+		d.pointers = d.pointers[:0]
+	}
+}
+
+// Read reads exactly len(b) bytes into b. It returns io.ErrUnexpectedEOF if the
+// underlying reader runs out of data before the buffer is filled.
 func (d *Decoder) Read(b []byte) (int, error) {
-	return d.r.Read(b)
+	if d.s != nil {
+		return d.s.readFull(b)
+	}
+	return io.ReadFull(d.r, b)
 }
 
 // ReadUvarint reads a variable-length Uint64 from the buffer.
 func (d *Decoder) ReadUvarint() (uint64, error) {
+	if d.s != nil {
+		return d.s.readUvarint()
+	}
 	return binary.ReadUvarint(d.r)
 }
 
 // ReadVarint reads a variable-length Int64 from the buffer.
 func (d *Decoder) ReadVarint() (int64, error) {
-	return binary.ReadVarint(d.r)
+	if d.s == nil {
+		return binary.ReadVarint(d.r)
+	}
+
+	x, err := d.s.readUvarint()
+	v := int64(x >> 1)
+	if x&1 != 0 {
+		v = ^v
+	}
+	return v, err
+}
+
+// readSliceLen reads the length of a slice or a map, checking it against the limits.
+func (d *Decoder) readSliceLen() (int, error) {
+	return d.readLength(d.opts.maxSliceLen, "slice")
+}
+
+// readStringLen reads the length of a string, checking it against the limits.
+func (d *Decoder) readStringLen() (int, error) {
+	return d.readLength(d.opts.maxStringLen, "string")
+}
+
+// checkStringLen checks the length of a string against the limits.
+func (d *Decoder) checkStringLen(l int) error {
+	if d.opts.maxStringLen > 0 && l > d.opts.maxStringLen {
+		return limitError("string length", uint64(l), d.opts.maxStringLen)
+	}
+	return nil
+}
+
+// readLength reads a length prefix and checks it against a limit, if the limit is set.
+func (d *Decoder) readLength(max int, what string) (int, error) {
+	l, err := d.ReadUvarint()
+	switch {
+	case err != nil:
+		return 0, err
+	case max > 0 && l > uint64(max):
+		return 0, limitError(what+" length", l, max)
+	case l > uint64(maxInt):
+		return 0, limitError(what+" length", l, maxInt)
+	default:
+		return int(l), nil
+	}
+}
+
+// allocated returns the number of bytes allocated for the current value, which nested
+// decoders share with the outermost one.
+func (d *Decoder) allocated() *int {
+	if d.budget != nil {
+		return d.budget
+	}
+	return &d.spent
+}
+
+// allocate charges the allocation of n values of the size against the budget, if set.
+func (d *Decoder) allocate(n int, size uintptr) error {
+	if d.opts.maxAlloc <= 0 || size == 0 {
+		return nil
+	}
+	return charge(d.allocated(), d.opts.maxAlloc, n, size)
+}
+
+// charge charges the allocation of n values of the size against a budget of max bytes,
+// given the number of bytes spent so far.
+func charge(spent *int, max, n int, size uintptr) error {
+	if remaining := max - *spent; uint64(n) > uint64(remaining)/uint64(size) {
+		return fmt.Errorf("%w: %d bytes are larger than the remaining %d", ErrBudgetExceeded,
+			uint64(n)*uint64(size), remaining)
+	}
+
+	*spent += n * int(size)
+	return nil
+}
+
+// readSliceOf reads the length of a slice or a map, checking it against the limits and
+// charging the allocation of its elements of the size against the budget.
+func (d *Decoder) readSliceOf(size uintptr) (l int, err error) {
+	if l, err = d.readSliceLen(); err == nil {
+		err = d.allocate(l, size)
+	}
+	return
+}
+
+// enter increments the nesting depth, checking it against the limits.
+func (d *Decoder) enter() error {
+	d.depth++
+	if d.opts.maxDepth > 0 && d.depth > d.opts.maxDepth {
+		return limitError("depth", uint64(d.depth), d.opts.maxDepth)
+	}
+	return nil
+}
+
+// leave decrements the nesting depth.
+func (d *Decoder) leave() {
+	d.depth--
 }
 
-// ReadUint16 reads a uint16
+// ReadUint16 reads a uint16, in the configured byte order (little-endian by default)
 func (d *Decoder) ReadUint16() (out uint16, err error) {
 	var b []byte
 	if b, err = d.sliceOrScratch(2); err == nil {
 		_ = b[1] // bounds check hint to compiler
 		out = (uint16(b[0]) | uint16(b[1])<<8)
+		if d.opts.bigEndian {
+			out = bits.ReverseBytes16(out)
+		}
 	}
 	return
 }
 
-// ReadUint32 reads a uint32
+// ReadUint32 reads a uint32, in the configured byte order (little-endian by default)
 func (d *Decoder) ReadUint32() (out uint32, err error) {
 	var b []byte
 	if b, err = d.sliceOrScratch(4); err == nil {
 		_ = b[3] // bounds check hint to compiler
 		out = (uint32(b[0]) | uint32(b[1])<<8 | uint32(b[2])<<16 | uint32(b[3])<<24)
+		if d.opts.bigEndian {
+			out = bits.ReverseBytes32(out)
+		}
 	}
 	return
 }
 
-// ReadUint64 reads a uint64
+// ReadUint64 reads a uint64, in the configured byte order (little-endian by default)
 func (d *Decoder) ReadUint64() (out uint64, err error) {
 	var b []byte
 	if b, err = d.sliceOrScratch(8); err == nil {
 		_ = b[7] // bounds check hint to compiler
 		out = (uint64(b[0]) | uint64(b[1])<<8 | uint64(b[2])<<16 | uint64(b[3])<<24 |
 			uint64(b[4])<<32 | uint64(b[5])<<40 | uint64(b[6])<<48 | uint64(b[7])<<56)
+		if d.opts.bigEndian {
+			out = bits.ReverseBytes64(out)
+		}
+	}
+	return
+}
+
+// ReadInt8 reads a single signed byte
+func (d *Decoder) ReadInt8() (int8, error) {
+	v, err := d.r.ReadByte()
+	return int8(v), err
+}
+
+// ReadInt16 reads an int16, in the configured byte order (little-endian by default)
+func (d *Decoder) ReadInt16() (int16, error) {
+	v, err := d.ReadUint16()
+	return int16(v), err
+}
+
+// ReadInt32 reads an int32, in the configured byte order (little-endian by default)
+func (d *Decoder) ReadInt32() (int32, error) {
+	v, err := d.ReadUint32()
+	return int32(v), err
+}
+
+// ReadInt64 reads an int64, in the configured byte order (little-endian by default)
+func (d *Decoder) ReadInt64() (int64, error) {
+	v, err := d.ReadUint64()
+	return int64(v), err
+}
+
+// readFixed reads an integer of the specified size in bytes
+func (d *Decoder) readFixed(size int) (out uint64, err error) {
+	switch size {
+	case 1:
+		var v byte
+		v, err = d.r.ReadByte()
+		out = uint64(v)
+	case 2:
+		var v uint16
+		v, err = d.ReadUint16()
+		out = uint64(v)
+	case 4:
+		var v uint32
+		v, err = d.ReadUint32()
+		out = uint64(v)
+	default:
+		out, err = d.ReadUint64()
 	}
 	return
 }
@@ -138,19 +620,187 @@ func (d *Decoder) ReadFloat64() (out float64, err error) {
 
 // ReadBool reads a single boolean value from the slice.
 func (d *Decoder) ReadBool() (bool, error) {
-	b, err := d.r.ReadByte()
+	b, err := d.ReadUint8()
 	return b == 1, err
 }
 
-// ReadComplex reads a complex64
-func (d *Decoder) readComplex64() (out complex64, err error) {
-	err = binary.Read(d.r, binary.LittleEndian, &out)
+// ReadUint8 reads a single byte
+func (d *Decoder) ReadUint8() (uint8, error) {
+	if d.s != nil {
+		return d.s.ReadByte()
+	}
+	return d.r.ReadByte()
+}
+
+// ReadString reads a string prefixed with its length, checking it against the limits.
+func (d *Decoder) ReadString() (string, error) {
+	return d.ReadStringMax(0)
+}
+
+// ReadStringMax reads a string written by WriteString or WriteStringMax, and fails with
+// ErrLimitExceeded if it is longer than the maximum length, or than the MaxStringLen
+// option allows. A non-positive maximum only applies the option. This allows codecs to
+// bound the strings they read, such as names or identifiers, without handling the length
+// prefix themselves, while honoring the other options such as InternStrings.
+func (d *Decoder) ReadStringMax(maxLen int) (out string, err error) {
+	limit := lengthLimit(d.opts.maxStringLen, maxLen)
+	if d.opts.intern {
+		return d.readInternedMax(limit)
+	}
+
+	var l int
+	var b []byte
+	if l, err = d.readLength(limit, "string"); err == nil {
+		if b, err = d.Slice(l); err == nil {
+			out, err = d.toString(b)
+		}
+	}
 	return
 }
 
-// ReadComplex reads a complex128
-func (d *Decoder) readComplex128() (out complex128, err error) {
-	err = binary.Read(d.r, binary.LittleEndian, &out)
+// lengthLimit returns the smaller of the limit set by an option and the maximum length
+// given to a read, where only the positive limits apply.
+func lengthLimit(option, maxLen int) int {
+	if maxLen > 0 && (option <= 0 || maxLen < option) {
+		return maxLen
+	}
+	return option
+}
+
+// ErrInvalidUTF8 is returned when a decoded string is not valid UTF-8, if the ValidUTF8
+// option is set.
+var ErrInvalidUTF8 = errors.New("binary: invalid UTF-8 string")
+
+// toString converts the bytes sliced from the input into a string, either by copying
+// them or by pointing into the input if zero-copy decoding is enabled.
+func (d *Decoder) toString(b []byte) (string, error) {
+	switch {
+	case d.opts.validUTF8 && !utf8.Valid(b):
+		return "", ErrInvalidUTF8
+	case d.opts.zeroCopy:
+		return binaryToString(&b), nil
+	}
+
+	if err := d.allocate(len(b), 1); err != nil {
+		return "", err
+	}
+	if d.opts.arena != nil {
+		return d.opts.arena.string(b), nil
+	}
+	return string(b), nil
+}
+
+// readInterned reads a string which is either a reference to a previously read string
+// or a new string, which gets added to the table of interned strings.
+func (d *Decoder) readInterned() (string, error) {
+	return d.readInternedMax(d.opts.maxStringLen)
+}
+
+// readInternedMax reads an interned string which must not be longer than the maximum
+// length, if positive. References are checked as well, since the string they refer to
+// may have been read with a larger maximum.
+func (d *Decoder) readInternedMax(max int) (out string, err error) {
+	var header uint64
+	if header, err = d.ReadUvarint(); err != nil {
+		return
+	}
+
+	// The string is a reference to a previous one
+	if header&1 == 1 {
+		i := header >> 1
+		switch {
+		case i >= uint64(len(d.interned)):
+			return "", errors.New("binary: invalid reference to an interned string")
+		case max > 0 && len(d.interned[i]) > max:
+			return "", limitError("string length", uint64(len(d.interned[i])), max)
+		default:
+			return d.interned[i], nil
+		}
+	}
+
+	var b []byte
+	l := header >> 1
+	switch {
+	case max > 0 && l > uint64(max):
+		return "", limitError("string length", l, max)
+	case l > uint64(maxInt):
+		return "", limitError("string length", l, maxInt)
+	}
+
+	if b, err = d.Slice(int(l)); err == nil && l > 0 {
+		if out, err = d.toString(b); err == nil {
+			d.interned = append(d.interned, out)
+		}
+	}
+	return
+}
+
+// ReadBytes reads a byte slice prefixed with its length, checking it against the limits.
+// The returned slice is a copy, unless the ZeroCopy option is set, and is nil if the
+// encoded slice is empty.
+func (d *Decoder) ReadBytes() (out []byte, err error) {
+	var l int
+	if l, err = d.readSliceLen(); err == nil && l > 0 {
+		if d.opts.zeroCopy {
+			if out, err = d.Slice(l); err == nil {
+				out = out[:l:l]
+			}
+			return
+		}
+
+		if err = d.allocate(l, 1); err != nil {
+			return
+		}
+
+		out, err = d.readCopy(l)
+	}
+	return
+}
+
+// ReadSlice reads bytes prefixed with their length, as written by WriteLengthPrefixed, and
+// fails with ErrLimitExceeded if there are more than the maximum length, or than the
+// MaxSliceLen option allows. A non-positive maximum only applies the option. Like Slice,
+// the result points into the input when decoding from a byte slice, hence it must be
+// copied to be retained. It is nil if there are no bytes.
+func (d *Decoder) ReadSlice(maxLen int) ([]byte, error) {
+	l, err := d.readLength(lengthLimit(d.opts.maxSliceLen, maxLen), "slice")
+	if err != nil || l == 0 {
+		return nil, err
+	}
+
+	// Only charge the budget if the bytes are actually allocated
+	if d.s == nil {
+		if err := d.allocate(l, 1); err != nil {
+			return nil, err
+		}
+	}
+
+	out, err := d.Slice(l)
+	if err != nil {
+		return nil, err
+	}
+	return out[:l:l], nil
+}
+
+// ReadComplex64 reads a complex64 written by WriteComplex64
+func (d *Decoder) ReadComplex64() (out complex64, err error) {
+	var re, im float32
+	if re, err = d.ReadFloat32(); err == nil {
+		if im, err = d.ReadFloat32(); err == nil {
+			out = complex(re, im)
+		}
+	}
+	return
+}
+
+// ReadComplex128 reads a complex128 written by WriteComplex128
+func (d *Decoder) ReadComplex128() (out complex128, err error) {
+	var re, im float64
+	if re, err = d.ReadFloat64(); err == nil {
+		if im, err = d.ReadFloat64(); err == nil {
+			out = complex(re, im)
+		}
+	}
 	return
 }
 
@@ -162,7 +812,7 @@ func (d *Decoder) sliceOrScratch(n int) (buffer []byte, err error) {
 	}
 
 	buffer = d.scratch[:n]
-	_, err = d.r.Read(buffer)
+	_, err = d.Read(buffer)
 	return
 }
 
@@ -176,10 +826,150 @@ func (d *Decoder) Slice(n int) ([]byte, error) {
 	}
 
 	// If we don't have a slicer, we can just allocate and read
-	buffer := make([]byte, n, n)
-	if _, err := d.Read(buffer); err != nil {
+	return d.readChunked(n)
+}
+
+// The number of bytes allocated at once when the input is not known to hold a slice
+const chunkSize = 64 << 10
+
+// readCopy reads n bytes into a new slice. When decoding from a slice, the bytes are only
+// copied once the input is known to hold them.
+func (d *Decoder) readCopy(n int) ([]byte, error) {
+	if d.s == nil {
+		return d.readChunked(n)
+	}
+
+	b, err := d.s.Slice(n)
+	switch {
+	case err != nil:
 		return nil, err
+	case d.opts.arena != nil:
+		return d.opts.arena.bytes(b), nil
 	}
+	return append(make([]byte, 0, n), b...), nil
+}
+
+// readChunked reads n bytes from a stream into a new slice, which grows in chunks as the
+// bytes arrive rather than being allocated upfront, so that a forged length can not
+// allocate more memory than the bytes which were actually sent.
+func (d *Decoder) readChunked(n int) ([]byte, error) {
+	buffer := make([]byte, 0, min(n, chunkSize))
+	for len(buffer) < n {
+		if len(buffer) == cap(buffer) {
+			buffer = slices.Grow(buffer, min(len(buffer), n-len(buffer)))
+		}
 
+		m, err := io.ReadFull(d.r, buffer[len(buffer):min(cap(buffer), n)])
+		if buffer = buffer[:len(buffer)+m]; err != nil {
+			if err == io.EOF && len(buffer) > 0 {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+	}
 	return buffer, nil
 }
+
+// makeSlice returns a slice for l decoded elements. The destination is reused when it has
+// the capacity for them, which spares allocations when decoding into pooled values. Else
+// the allocation is charged against the budget and, unless the input is known to hold the
+// elements, only a first chunk is allocated, which growSlice extends as the elements get
+// decoded, so that a forged length can not allocate more than the input justifies.
+func (d *Decoder) makeSlice(rv reflect.Value, l int) (reflect.Value, error) {
+	if l <= rv.Cap() {
+		return rv.Slice(0, l), nil
+	}
+
+	t := rv.Type()
+	empty := mayBeEmpty(t.Elem())
+	if empty && l > maxEmptyLen && d.opts.maxSliceLen <= 0 && (d.s == nil || l > d.s.Len()) {
+		return reflect.Value{}, limitError("slice length", uint64(l), maxEmptyLen)
+	}
+
+	size := int(t.Elem().Size())
+	if err := d.allocate(l, uintptr(size)); err != nil {
+		return reflect.Value{}, err
+	}
+
+	n := l
+	switch {
+	case size == 0:
+	case d.s != nil && l <= d.s.Len() && !empty: // Every element takes at least a byte
+	default:
+		n = min(l, max(chunkSize/size, 1))
+	}
+	return d.newSlice(t, n), nil
+}
+
+// growSlice extends a slice allocated by makeSlice, doubling its length up to l.
+func growSlice(slice reflect.Value, l int) reflect.Value {
+	n := min(2*slice.Len(), l)
+	grown := reflect.MakeSlice(slice.Type(), n, n)
+	reflect.Copy(grown, slice)
+	return grown
+}
+
+// The maximum length of the slices whose elements may be encoded into no bytes, unless
+// the input holds as many bytes or a MaxSliceLen is set, as nothing else bounds it
+const maxEmptyLen = 1 << 20
+
+// Map of whether the values of a type may be encoded into no bytes
+var emptyTypes = new(sync.Map)
+
+// mayBeEmpty returns whether the values of a type may be encoded into no bytes, such as
+// arrays without elements or structs without any encoded field, in which case the length
+// of a slice of them is not bounded by the remaining input.
+func mayBeEmpty(t reflect.Type) bool {
+	if v, ok := emptyTypes.Load(t); ok {
+		return v.(bool)
+	}
+
+	v := encodesEmpty(t)
+	emptyTypes.Store(t, v)
+	return v
+}
+
+// encodesEmpty returns whether the values of a type may be encoded into no bytes. The
+// codecs of the types themselves may write anything, so they are assumed to.
+func encodesEmpty(t reflect.Type) bool {
+	ptr := reflect.PointerTo(t)
+	if _, ok := registered.Load(t); ok {
+		return true
+	}
+	if _, ok := ptr.MethodByName("GetBinaryCodec"); ok || ptr.Implements(typeBinaryEncoder) {
+		return true
+	}
+	if _, ok := scanBuiltin(t); ok || t.Implements(typeBinaryMarshaler) || ptr.Implements(typeBinaryMarshaler) {
+		return false
+	}
+
+	switch t.Kind() {
+	case reflect.Array:
+		return t.Len() == 0 || mayBeEmpty(t.Elem())
+	case reflect.Struct:
+		s, err := scanStruct(t)
+		if err != nil {
+			return true
+		}
+
+		for _, f := range s.fields {
+			var field reflect.StructField
+			if f.Path != nil {
+				field = t.FieldByIndex(f.Path)
+			} else {
+				field = t.Field(f.Index)
+			}
+
+			switch {
+			case f.Tag.Options.Contains("omitempty"):
+				return false // The presence bitmap takes at least a byte
+			case !field.IsExported() || f.JSON.Skip:
+			case !mayBeEmpty(field.Type):
+				return false
+			}
+		}
+		return true
+	default:
+		return false
+	}
+}
@@ -4,7 +4,15 @@
 package binary
 
 import (
+	"bytes"
+	"errors"
+	"io"
+	"math"
+	"reflect"
+	"runtime"
 	"testing"
+	"testing/iotest"
+	"unsafe"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -25,3 +33,549 @@ func TestBinaryDecodeToValueErrors(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, uint32(1), v)
 }
+
+func TestDecoder_IOReader(t *testing.T) {
+	b, err := Marshal(s1v)
+	assert.NoError(t, err)
+
+	// A reader which only implements io.Reader and returns a byte at a time
+	s := &s1{}
+	d := NewDecoderSize(iotest.OneByteReader(bytes.NewReader(b)), 16)
+	assert.NoError(t, d.Decode(s))
+	assert.Equal(t, s1v, s)
+}
+
+func TestDecoder_UnexpectedEOF(t *testing.T) {
+	b, err := Marshal(s1v)
+	assert.NoError(t, err)
+
+	s := &s1{}
+	d := NewDecoder(iotest.HalfReader(bytes.NewReader(b[:len(b)-3])))
+	assert.Error(t, d.Decode(s))
+}
+
+func TestDecoder_Limits(t *testing.T) {
+	type node struct {
+		Next *node
+	}
+
+	deep := &node{&node{&node{}}}
+	deepb, err := Marshal(deep)
+	assert.NoError(t, err)
+
+	tests := []struct {
+		value  interface{}
+		output interface{}
+		option Option
+	}{
+		{[]int{1, 2, 3}, new([]int), MaxSliceLen(2)},
+		{[]byte{1, 2, 3}, new([]byte), MaxSliceLen(2)},
+		{[]bool{true, true, true}, new([]bool), MaxSliceLen(2)},
+		{[]string{"a", "b", "c"}, new([]string), MaxSliceLen(2)},
+		{map[int]int{1: 1, 2: 2, 3: 3}, new(map[int]int), MaxSliceLen(2)},
+		{"hello", new(string), MaxStringLen(4)},
+		{map[string]int{"hello": 1}, new(map[string]int), MaxStringLen(4)},
+		{deep, new(node), MaxDepth(2)},
+	}
+
+	for _, tc := range tests {
+		b, err := Marshal(tc.value)
+		assert.NoError(t, err)
+
+		err = Unmarshal(b, tc.output, tc.option)
+		assert.True(t, errors.Is(err, ErrLimitExceeded), "%T: %v", tc.value, err)
+		assert.NoError(t, Unmarshal(b, tc.output))
+	}
+
+	assert.NoError(t, Unmarshal(deepb, new(node), MaxDepth(3)))
+}
+
+func TestDecoder_Budget(t *testing.T) {
+	type record struct {
+		Name  string
+		Tags  []string
+		Child *record
+	}
+
+	many := make([]string, 100)
+	for i := range many {
+		many[i] = "0123456789"
+	}
+
+	tests := []struct {
+		value  interface{}
+		output interface{}
+		budget int
+	}{
+		{[]int64{1, 2, 3}, new([]int64), 23},
+		{[]byte{1, 2, 3}, new([]byte), 2},
+		{"hello", new(string), 4},
+		{map[int32]int32{1: 1, 2: 2}, new(map[int32]int32), 15},
+		{&record{Child: &record{}}, new(record), int(unsafe.Sizeof(record{})) - 1},
+		{many, new([]string), len(many)*int(unsafe.Sizeof("")) + 999}, // Every string is small, but not all of them
+	}
+
+	for _, tc := range tests {
+		b, err := Marshal(tc.value)
+		assert.NoError(t, err)
+
+		err = Unmarshal(b, tc.output, MaxAllocation(tc.budget))
+		assert.True(t, errors.Is(err, ErrBudgetExceeded), "%T: %v", tc.value, err)
+		assert.NoError(t, Unmarshal(b, tc.output, MaxAllocation(10000)))
+	}
+
+	// The budget applies to every value decoded from a stream
+	var buffer bytes.Buffer
+	e := NewEncoder(&buffer)
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, e.Encode(many))
+	}
+
+	d := NewDecoder(&buffer, MaxAllocation(5000))
+	for i := 0; i < 3; i++ {
+		var out []string
+		assert.NoError(t, d.Decode(&out))
+	}
+
+	// Generic values are charged too
+	b, err := Marshal(many, SelfDescribing())
+	assert.NoError(t, err)
+	_, err = DecodeAny(b, SelfDescribing(), MaxAllocation(len(many)*int(unsafe.Sizeof(interface{}(nil)))+999))
+	assert.True(t, errors.Is(err, ErrBudgetExceeded))
+}
+
+func TestDecoder_HugeLength(t *testing.T) {
+	var out []byte
+	err := Unmarshal([]byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x1}, &out)
+	assert.Error(t, err)
+}
+
+func TestDecoder_ForgedLength(t *testing.T) {
+	type point struct {
+		X, Y int
+	}
+
+	forged := []byte{0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x10, 1} // 2^60 elements
+	outputs := []interface{}{
+		new([]int64), new([]uint32), new([]string), new([]byte), new([]bool),
+		new([]point), new([][]int), new(string), new(map[int]int),
+	}
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	for _, out := range outputs {
+		assert.Error(t, Unmarshal(forged, out), "%T", out)
+		assert.Error(t, NewDecoder(iotest.OneByteReader(bytes.NewReader(forged))).Decode(out), "%T", out)
+	}
+
+	runtime.ReadMemStats(&after)
+	assert.True(t, after.TotalAlloc-before.TotalAlloc < 10<<20)
+}
+
+func TestDecoder_ForgedEmptyElements(t *testing.T) {
+	type empty struct {
+		x int64
+	}
+
+	// Elements which are encoded into no bytes are not bounded by the remaining input
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	for _, forged := range [][]byte{
+		{0x80, 0x80, 0x80, 0x08},                               // 2^24 elements
+		{0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x10}, // 2^60 elements
+	} {
+		for _, out := range []interface{}{new([]empty), new([][0]int64)} {
+			err := Unmarshal(forged, out)
+			assert.True(t, errors.Is(err, ErrLimitExceeded), "%T: %v", out, err)
+			err = NewDecoder(bytes.NewReader(forged)).Decode(out)
+			assert.True(t, errors.Is(err, ErrLimitExceeded), "%T: %v", out, err)
+		}
+	}
+
+	runtime.ReadMemStats(&after)
+	assert.True(t, after.TotalAlloc-before.TotalAlloc < 10<<20)
+
+	// Slices of such elements within the limit are still decoded
+	var out []empty
+	assert.NoError(t, Unmarshal([]byte{0xe8, 0x07}, &out))
+	assert.Len(t, out, 1000)
+
+	// So are longer ones, with a limit of their own
+	assert.NoError(t, Unmarshal([]byte{0x80, 0x80, 0x80, 0x01}, &out, MaxSliceLen(1<<21)))
+	assert.Len(t, out, 1<<21)
+}
+
+func TestDecoder_ChunkedSlices(t *testing.T) {
+	type point struct {
+		X, Y int
+	}
+
+	points := make([]point, 50000)
+	for i := range points {
+		points[i] = point{X: i, Y: -i}
+	}
+
+	ints := make([]uint64, 50000)
+	for i := range ints {
+		ints[i] = uint64(i * i)
+	}
+
+	text := bytes.Repeat([]byte("abc"), 100000)
+	for _, v := range []interface{}{&points, &ints, &text} {
+		b, err := Marshal(v)
+		assert.NoError(t, err)
+
+		out := reflect.New(reflect.TypeOf(v).Elem())
+		assert.NoError(t, NewDecoder(iotest.HalfReader(bytes.NewReader(b))).Decode(out.Interface()))
+		assert.Equal(t, v, out.Interface())
+	}
+}
+
+func TestDecoder_ReuseSlicesAndMaps(t *testing.T) {
+	type pooled struct {
+		Ints   []int
+		Names  []string
+		Bytes  []byte
+		Flags  []bool
+		Counts map[string]int
+	}
+
+	out := pooled{
+		Ints:   make([]int, 0, 8),
+		Names:  make([]string, 0, 8),
+		Bytes:  make([]byte, 0, 8),
+		Flags:  make([]bool, 0, 8),
+		Counts: map[string]int{"stale": 1},
+	}
+
+	ints, names, raw, flags := &out.Ints[:1][0], &out.Names[:1][0], &out.Bytes[:1][0], &out.Flags[:1][0]
+	counts := reflect.ValueOf(out.Counts).UnsafePointer()
+
+	in := pooled{
+		Ints:   []int{1, 2, 3},
+		Names:  []string{"a", "b"},
+		Bytes:  []byte("abcd"),
+		Flags:  []bool{true, false, true},
+		Counts: map[string]int{"a": 1, "b": 2},
+	}
+
+	b, err := Marshal(&in)
+	assert.NoError(t, err)
+	assert.NoError(t, Unmarshal(b, &out))
+	assert.Equal(t, in, out)
+
+	// The backing arrays and the map of the destination are reused
+	assert.True(t, ints == &out.Ints[0])
+	assert.True(t, names == &out.Names[0])
+	assert.True(t, raw == &out.Bytes[0])
+	assert.True(t, flags == &out.Flags[0])
+	assert.True(t, counts == reflect.ValueOf(out.Counts).UnsafePointer())
+
+	// Empty values truncate the destination
+	b, err = Marshal(&pooled{})
+	assert.NoError(t, err)
+	assert.NoError(t, Unmarshal(b, &out))
+	assert.Len(t, out.Ints, 0)
+	assert.Len(t, out.Names, 0)
+	assert.Len(t, out.Bytes, 0)
+	assert.Len(t, out.Flags, 0)
+	assert.Len(t, out.Counts, 0)
+	assert.Equal(t, 8, cap(out.Ints))
+
+	// Larger values are allocated
+	in.Ints = make([]int, 100)
+	b, err = Marshal(&in)
+	assert.NoError(t, err)
+	assert.NoError(t, Unmarshal(b, &out))
+	assert.Equal(t, in, out)
+	assert.False(t, ints == &out.Ints[0])
+}
+
+func TestUnmarshalNext(t *testing.T) {
+	var b []byte
+	var err error
+	for _, v := range []s0{{A: "a", B: "b"}, {A: "second"}, {C: 7}} {
+		b, err = MarshalTo(b, &v)
+		assert.NoError(t, err)
+	}
+
+	var out []s0
+	for len(b) > 0 {
+		var v s0
+		n, err := UnmarshalNext(b, &v, Strict())
+		assert.NoError(t, err)
+		assert.True(t, n > 0)
+		out = append(out, v)
+		b = b[n:]
+	}
+
+	assert.Equal(t, []s0{{A: "a", B: "b"}, {A: "second"}, {C: 7}}, out)
+
+	// Truncated values consume nothing
+	n, err := UnmarshalNext([]byte{5, 'a'}, new(s0))
+	assert.Error(t, err)
+	assert.Equal(t, 0, n)
+}
+
+func TestDecodeLimited(t *testing.T) {
+	b, err := Marshal(s1v)
+	assert.NoError(t, err)
+
+	readers := []func() io.Reader{
+		func() io.Reader { return bytes.NewReader(b) },
+		func() io.Reader { return iotest.OneByteReader(bytes.NewReader(b)) },
+	}
+
+	for _, reader := range readers {
+		s := &s1{}
+		assert.NoError(t, DecodeLimited(reader(), s, int64(len(b))))
+		assert.Equal(t, s1v, s)
+
+		err := DecodeLimited(reader(), &s1{}, int64(len(b)-1))
+		assert.True(t, errors.Is(err, ErrSizeExceeded), "%v", err)
+
+		err = DecodeLimited(reader(), &s1{}, 10)
+		assert.True(t, errors.Is(err, ErrSizeExceeded), "%v", err)
+	}
+}
+
+func TestDecoderPrimitives(t *testing.T) {
+	var buffer bytes.Buffer
+	e := NewEncoder(&buffer)
+	e.WriteString("hello")
+	e.WriteBytes([]byte{1, 2, 3})
+	e.WriteBytes(nil)
+	e.WriteUint8(0xff)
+	e.WriteBool(true)
+	assert.Equal(t, []byte{0x5, 'h', 'e', 'l', 'l', 'o', 0x3, 0x1, 0x2, 0x3, 0x0, 0xff, 0x1}, buffer.Bytes())
+
+	d := NewDecoder(bytes.NewReader(buffer.Bytes()))
+	s, err := d.ReadString()
+	assert.NoError(t, err)
+	assert.Equal(t, "hello", s)
+
+	b, err := d.ReadBytes()
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{1, 2, 3}, b)
+
+	b, err = d.ReadBytes()
+	assert.NoError(t, err)
+	assert.Nil(t, b)
+
+	u, err := d.ReadUint8()
+	assert.NoError(t, err)
+	assert.Equal(t, uint8(0xff), u)
+
+	ok, err := d.ReadBool()
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	d = NewDecoder(bytes.NewReader(buffer.Bytes()), MaxStringLen(4))
+	_, err = d.ReadString()
+	assert.True(t, errors.Is(err, ErrLimitExceeded))
+}
+
+func TestDecoderReadSlice(t *testing.T) {
+	var buffer bytes.Buffer
+	e := NewEncoder(&buffer)
+	e.WriteLengthPrefixed([]byte("hello"))
+	e.WriteLengthPrefixed(nil)
+	e.WriteString("world")
+	e.WriteLengthPrefixed([]byte("too long"))
+	assert.NoError(t, e.Err())
+
+	// The framing is the same as the one of strings and byte slices
+	var s string
+	assert.NoError(t, Unmarshal(buffer.Bytes()[:6], &s))
+	assert.Equal(t, "hello", s)
+
+	input := buffer.Bytes()
+	d := NewDecoder(bytes.NewReader(input), MaxSliceLen(6))
+	b, err := d.ReadSlice(0)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("hello"), b)
+	assert.Equal(t, 5, cap(b))
+
+	b, err = d.ReadSlice(0)
+	assert.NoError(t, err)
+	assert.Nil(t, b)
+
+	_, err = d.ReadSlice(4)
+	assert.True(t, errors.Is(err, ErrLimitExceeded))
+
+	// The limit of the option applies when it is the tightest one
+	d = NewDecoder(bytes.NewReader(input[len(input)-9:]), MaxSliceLen(6))
+	_, err = d.ReadSlice(100)
+	assert.True(t, errors.Is(err, ErrLimitExceeded))
+
+	d = NewDecoder(iotest.OneByteReader(bytes.NewReader(input[len(input)-9:])))
+	b, err = d.ReadSlice(8)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("too long"), b)
+}
+
+func TestDecoderReadStringMax(t *testing.T) {
+	for _, opts := range [][]Option{nil, {InternStrings()}} {
+		var buffer bytes.Buffer
+		e := NewEncoder(&buffer, opts...)
+		assert.NoError(t, e.WriteStringMax("hello", 5))
+		assert.NoError(t, e.WriteStringMax("hello", 0))
+		assert.NoError(t, e.WriteStringMax("", 1))
+		assert.NoError(t, e.WriteStringMax("too long", 8))
+		assert.NoError(t, e.Flush())
+
+		// Strings longer than the maximum are not written
+		err := e.WriteStringMax("too long", 4)
+		assert.True(t, errors.Is(err, ErrLimitExceeded))
+
+		d := NewDecoder(bytes.NewReader(buffer.Bytes()), opts...)
+		for _, expect := range []string{"hello", "hello", "", "too long"} {
+			s, err := d.ReadStringMax(len(expect))
+			assert.NoError(t, err)
+			assert.Equal(t, expect, s)
+		}
+
+		d = NewDecoder(bytes.NewReader(buffer.Bytes()), opts...)
+		_, err = d.ReadStringMax(4)
+		assert.True(t, errors.Is(err, ErrLimitExceeded))
+
+		// References to interned strings are checked as well
+		d = NewDecoder(bytes.NewReader(buffer.Bytes()), opts...)
+		_, err = d.ReadStringMax(5)
+		assert.NoError(t, err)
+		_, err = d.ReadStringMax(4)
+		assert.True(t, errors.Is(err, ErrLimitExceeded))
+
+		// The limit of the option applies when it is the tightest one
+		d = NewDecoder(bytes.NewReader(buffer.Bytes()), append(opts, MaxStringLen(6))...)
+		_, err = d.ReadStringMax(0)
+		assert.NoError(t, err)
+		_, err = d.ReadStringMax(100)
+		assert.NoError(t, err)
+		_, err = d.ReadStringMax(100)
+		assert.NoError(t, err)
+		_, err = d.ReadStringMax(100)
+		assert.True(t, errors.Is(err, ErrLimitExceeded))
+	}
+}
+
+func TestDecoderFixedPrimitives(t *testing.T) {
+	for _, opts := range [][]Option{nil, {ByteOrder(BigEndian)}} {
+		var buffer bytes.Buffer
+		e := NewEncoder(&buffer, opts...)
+		e.WriteInt8(-1)
+		e.WriteInt16(-2)
+		e.WriteInt32(-3)
+		e.WriteInt64(-4)
+		e.WriteComplex64(complex(1, -1))
+		e.WriteComplex128(complex(2, -2))
+		assert.NoError(t, e.Err())
+		assert.Equal(t, 1+2+4+8+8+16, buffer.Len())
+
+		d := NewDecoder(bytes.NewReader(buffer.Bytes()), opts...)
+		i8, err := d.ReadInt8()
+		assert.NoError(t, err)
+		assert.Equal(t, int8(-1), i8)
+
+		i16, err := d.ReadInt16()
+		assert.NoError(t, err)
+		assert.Equal(t, int16(-2), i16)
+
+		i32, err := d.ReadInt32()
+		assert.NoError(t, err)
+		assert.Equal(t, int32(-3), i32)
+
+		i64, err := d.ReadInt64()
+		assert.NoError(t, err)
+		assert.Equal(t, int64(-4), i64)
+
+		c64, err := d.ReadComplex64()
+		assert.NoError(t, err)
+		assert.Equal(t, complex64(complex(1, -1)), c64)
+
+		c128, err := d.ReadComplex128()
+		assert.NoError(t, err)
+		assert.Equal(t, complex(2, -2), c128)
+
+		_, err = d.ReadInt8()
+		assert.Equal(t, io.EOF, err)
+	}
+}
+
+func TestDecoderFloat32(t *testing.T) {
+	for _, v := range []float32{0, 1.5, -3.25, math.MaxFloat32, float32(math.Inf(-1))} {
+		b, err := Marshal(&v)
+		assert.NoError(t, err)
+
+		var out float32
+		assert.NoError(t, Unmarshal(b, &out))
+		assert.Equal(t, v, out)
+	}
+}
+
+func TestDecoder_Overflow(t *testing.T) {
+	type Small struct {
+		A uint8
+		B int16
+		C []uint16
+		D []int8
+	}
+
+	type Large struct {
+		A uint64
+		B int64
+		C []uint64
+		D []int64
+	}
+
+	tests := []Large{
+		{A: 256},
+		{B: -32769},
+		{C: []uint64{1, 65536}},
+		{D: []int64{128}},
+	}
+
+	for _, tc := range tests {
+		b, err := Marshal(&tc)
+		assert.NoError(t, err)
+
+		var out Small
+		err = Unmarshal(b, &out)
+		assert.True(t, errors.Is(err, ErrOverflow), "%+v", tc)
+	}
+
+	// Values which fit are decoded as usual
+	b, err := Marshal(&Large{A: 255, B: -32768, C: []uint64{65535}, D: []int64{-128}})
+	assert.NoError(t, err)
+
+	var out Small
+	assert.NoError(t, Unmarshal(b, &out))
+	assert.Equal(t, Small{A: 255, B: -32768, C: []uint16{65535}, D: []int8{-128}}, out)
+}
+
+func TestDecoder_Reset(t *testing.T) {
+	b, err := Marshal(s1v, InternStrings())
+	assert.NoError(t, err)
+
+	// Readers without io.ByteReader reuse the buffer of the decoder
+	d := NewDecoder(iotest.HalfReader(bytes.NewReader(b[:len(b)-1])), InternStrings())
+	assert.Error(t, d.Decode(new(s1)))
+	for i := 0; i < 2; i++ {
+		d.Reset(iotest.HalfReader(bytes.NewReader(b)))
+		v := new(s1)
+		assert.NoError(t, d.Decode(v))
+		assert.Equal(t, s1v, v)
+	}
+
+	// Byte readers are used as they are
+	r := bytes.NewReader(b)
+	d.Reset(r)
+	v := new(s1)
+	assert.NoError(t, d.Decode(v))
+	assert.Equal(t, s1v, v)
+	assert.Zero(t, r.Len())
+
+	assert.Zero(t, testing.AllocsPerRun(10, func() {
+		d.Reset(r)
+	}))
+}
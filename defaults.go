@@ -0,0 +1,88 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package binary
+
+import (
+	"errors"
+	"reflect"
+	"strconv"
+)
+
+// Defaulter is implemented by structs which provide default values for the fields which
+// are missing from versioned payloads, typically because they were encoded before the
+// fields were added. SetDefaults is called on a new value of the struct, after applying
+// the defaults of the tags, and the missing fields are copied from it.
+type Defaulter interface {
+	SetDefaults()
+}
+
+// The reflected type of the Defaulter interface
+var typeDefaulter = reflect.TypeOf((*Defaulter)(nil)).Elem()
+
+// parseDefault parses the `default=value` option of a field tag into a value of the type
+// of the field, or returns an invalid value if the tag has no default. Defaults can only
+// be given to booleans, numbers and strings.
+func parseDefault(t reflect.Type, tag fieldTag) (reflect.Value, error) {
+	s, ok := tag.Options.Lookup("default")
+	if !ok {
+		return reflect.Value{}, nil
+	}
+
+	var err error
+	v := reflect.New(t).Elem()
+	switch t.Kind() {
+	case reflect.Bool:
+		var b bool
+		b, err = strconv.ParseBool(s)
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var n int64
+		n, err = strconv.ParseInt(s, 0, t.Bits())
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		var n uint64
+		n, err = strconv.ParseUint(s, 0, t.Bits())
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		var f float64
+		f, err = strconv.ParseFloat(s, t.Bits())
+		v.SetFloat(f)
+	case reflect.String:
+		v.SetString(s)
+	default:
+		return reflect.Value{}, &TypeError{Err: errors.New("binary: unable to set a default value to " + t.String())}
+	}
+
+	if err != nil {
+		return reflect.Value{}, &TypeError{Err: errors.New("binary: invalid default value " + strconv.Quote(s) + " for " + t.String())}
+	}
+	return v, nil
+}
+
+// defaults returns a value of the struct holding the defaults of its fields, or an invalid
+// value if the struct has no defaults, in which case missing fields are zeroed.
+func (c *reflectStructCodec) defaults(t reflect.Type, o *options) reflect.Value {
+	defaulter := reflect.PointerTo(t).Implements(typeDefaulter)
+	var out reflect.Value
+	for _, i := range *c {
+		if !i.Default.IsValid() {
+			continue
+		}
+
+		if !out.IsValid() {
+			out = reflect.New(t).Elem()
+		}
+		if v, ok, _ := i.access(out, o); ok {
+			v.Set(i.Default)
+		}
+	}
+
+	if defaulter {
+		if !out.IsValid() {
+			out = reflect.New(t).Elem()
+		}
+		out.Addr().Interface().(Defaulter).SetDefaults()
+	}
+	return out
+}
@@ -0,0 +1,140 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package binary
+
+import (
+	"errors"
+	"reflect"
+)
+
+// scanDelta returns a codec for slices of integers which encodes every element as the
+// difference with the previous one, selected with a `binary:",delta"` tag. This is very
+// compact for sorted slices, such as posting lists or timestamps, whose differences are
+// small. Unsorted slices still round-trip, but their negative differences take 10 bytes.
+func scanDelta(t reflect.Type) (Codec, error) {
+	if t.Kind() == reflect.Slice {
+		switch t.Elem().Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			return &deltaCodec{signed: true}, nil
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			return &deltaCodec{}, nil
+		}
+	}
+
+	return nil, errors.New("binary: delta encoding is not supported for " + t.String())
+}
+
+// ------------------------------------------------------------------------------
+
+// deltaCodec represents a codec for slices of integers, which are prefixed with their
+// count and encoded as the variable-size differences between consecutive elements. The
+// first element of signed slices is a signed variable-size integer, since it is the
+// difference with zero.
+type deltaCodec struct {
+	signed bool // Whether the elements are signed integers
+}
+
+// Encode encodes a value into the encoder.
+func (c *deltaCodec) EncodeTo(e *Encoder, rv reflect.Value) (err error) {
+	l := rv.Len()
+	e.WriteUvarint(uint64(l))
+
+	var prev uint64
+	for i := 0; i < l; i++ {
+		v := c.value(rv.Index(i))
+		if i == 0 && c.signed {
+			e.WriteVarint(int64(v))
+		} else {
+			e.WriteUvarint(v - prev)
+		}
+		prev = v
+	}
+	return
+}
+
+// Decode decodes into a reflect value from the decoder.
+func (c *deltaCodec) DecodeTo(d *Decoder, rv reflect.Value) (err error) {
+	var l int
+	if l, err = d.readSliceLen(); err != nil {
+		return
+	}
+
+	var slice reflect.Value
+	if slice, err = d.makeSlice(rv, l); err == nil {
+		err = d.readDelta(l, c.signed, func(i int, v uint64) error {
+			if i == slice.Len() {
+				slice = growSlice(slice, l)
+			}
+
+			elem := slice.Index(i)
+			switch {
+			case c.signed && elem.OverflowInt(int64(v)):
+				return IntOverflowError(int64(v), elem.Type().String())
+			case c.signed:
+				elem.SetInt(int64(v))
+			case elem.OverflowUint(v):
+				return UintOverflowError(v, elem.Type().String())
+			default:
+				elem.SetUint(v)
+			}
+			return nil
+		})
+		if err == nil {
+			rv.Set(slice)
+		}
+	}
+	return
+}
+
+// Size returns the encoded size of the value.
+func (c *deltaCodec) Size(rv reflect.Value) int {
+	l := rv.Len()
+	size := uvarintSize(uint64(l))
+
+	var prev uint64
+	for i := 0; i < l; i++ {
+		v := c.value(rv.Index(i))
+		if i == 0 && c.signed {
+			size += varintSize(int64(v))
+		} else {
+			size += uvarintSize(v - prev)
+		}
+		prev = v
+	}
+	return size
+}
+
+// value returns an element as an unsigned integer, so that the differences between
+// signed elements wrap around.
+func (c *deltaCodec) value(rv reflect.Value) uint64 {
+	if c.signed {
+		return uint64(rv.Int())
+	}
+	return rv.Uint()
+}
+
+// readDelta reads a number of delta-encoded integers, and calls the function with each
+// of them once they are added up. Signed integers are returned as their two's complement.
+func (d *Decoder) readDelta(l int, signed bool, fn func(i int, v uint64) error) (err error) {
+	var v uint64
+	for i := 0; i < l; i++ {
+		var delta uint64
+		if i == 0 && signed {
+			var first int64
+			first, err = d.ReadVarint()
+			delta = uint64(first)
+		} else {
+			delta, err = d.ReadUvarint()
+		}
+		if err != nil {
+			return
+		}
+
+		v += delta
+		if err = fn(i, v); err != nil {
+			return
+		}
+	}
+	return
+}
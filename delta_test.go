@@ -0,0 +1,93 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package binary
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type postings struct {
+	Docs  []uint64 `binary:",delta"`
+	Times []int64  `binary:",delta"`
+	Small []int16  `binary:",delta"`
+}
+
+func TestDelta(t *testing.T) {
+	v := &postings{
+		Docs:  []uint64{1000000, 1000001, 1000005, 1000100},
+		Times: []int64{-5, 1600000000, 1600000010, 1600000020},
+		Small: []int16{math.MaxInt16, math.MinInt16, 0},
+	}
+
+	b, err := Marshal(v)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{4, 0xc0, 0x84, 0x3d, 1, 4, 95}, b[:7])
+
+	size, err := Size(v)
+	assert.NoError(t, err)
+	assert.Equal(t, len(b), size)
+
+	var out postings
+	assert.NoError(t, Unmarshal(b, &out))
+	assert.Equal(t, v, &out)
+
+	// The deltas are much smaller than the values
+	plain, err := Marshal([]uint64{1000000, 1000001, 1000005, 1000100})
+	assert.NoError(t, err)
+	assert.Equal(t, 13, len(plain))
+}
+
+func TestDelta_Overflow(t *testing.T) {
+	b, err := Marshal(&struct {
+		Small []int64 `binary:",delta"`
+	}{[]int64{1, math.MaxInt16 + 1}})
+	assert.NoError(t, err)
+
+	var out struct {
+		Small []int16 `binary:",delta"`
+	}
+	assert.Error(t, Unmarshal(b, &out))
+}
+
+func TestDelta_Unsupported(t *testing.T) {
+	_, err := Marshal(&struct {
+		Values []float64 `binary:",delta"`
+	}{})
+	assert.Error(t, err)
+}
+
+func TestDelta_Schema(t *testing.T) {
+	v := &postings{Docs: []uint64{3, 5}, Times: []int64{-1, 1}}
+	b, err := Marshal(v, SelfDescribing())
+	assert.NoError(t, err)
+
+	out, err := DecodeAny(b, SelfDescribing())
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"Docs":  []interface{}{uint64(3), uint64(5)},
+		"Times": []interface{}{int64(-1), int64(1)},
+		"Small": []interface{}(nil),
+	}, out)
+
+	schema, err := SchemaOf(reflect.TypeOf(postings{}))
+	assert.NoError(t, err)
+	assert.Equal(t, KindDelta, schema.Fields[1].Schema.Kind)
+	assert.Equal(t, KindVarint, schema.Fields[1].Schema.Elem.Kind)
+
+	// Skipped by the walk and by the partial decoding
+	var kinds []Kind
+	assert.NoError(t, Walk(b, nil, func(t Token) error {
+		kinds = append(kinds, t.Schema.Kind)
+		return nil
+	}, SelfDescribing()))
+	assert.Equal(t, []Kind{KindDelta, KindDelta, KindDelta, KindStruct}, kinds)
+
+	var partial postings
+	assert.NoError(t, Unmarshal(b, &partial, SelfDescribing(), Fields("Small")))
+	assert.Equal(t, postings{}, partial)
+}
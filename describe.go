@@ -0,0 +1,351 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package binary
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+)
+
+// The version of the header of self-describing payloads
+const describedVersion byte = 2
+
+// The flags of the header of self-describing payloads, which record the options that
+// change the wire format.
+const (
+	flagVersioned byte = 1 << iota
+	flagInterned
+	flagBigEndian
+)
+
+// Map of the encoded schemas of the types encoded in self-describing mode
+var described = new(sync.Map)
+
+// describedKey represents the key of an encoded schema, which depends on the policy for
+// unexported fields and on the json tags.
+type describedKey struct {
+	t        reflect.Type
+	policy   UnexportedPolicy
+	jsonTags bool
+}
+
+// SelfDescribing prefixes every encoded value with a compact header, which contains its
+// schema along with the options that change the wire format. Such payloads can then be
+// traversed without the original Go type, which is useful for debugging tools and generic
+// message brokers. Decoders with this option read the header and apply the options it
+// records, while ReadSchema returns the schema it contains.
+func SelfDescribing() Option {
+	return func(o *options) {
+		o.selfDescribing = true
+	}
+}
+
+// writeHeader writes the header of a self-describing value of the type.
+func (e *Encoder) writeHeader(t reflect.Type) error {
+	if e.opts().shared {
+		return errors.New("binary: shared pointers can not be self-describing")
+	}
+
+	key := describedKey{t: t, policy: e.opts().unexported, jsonTags: e.opts().jsonTags}
+	schema, ok := described.Load(key)
+	if !ok {
+		s, err := SchemaOf(t, e.opts().schema()...)
+		if err != nil {
+			return err
+		}
+
+		b, err := s.MarshalBinary()
+		if err != nil {
+			return err
+		}
+		schema, _ = described.LoadOrStore(key, b)
+	}
+
+	var flags byte
+	if e.opts().versioned {
+		flags |= flagVersioned
+	}
+	if e.opts().intern {
+		flags |= flagInterned
+	}
+	if e.opts().bigEndian {
+		flags |= flagBigEndian
+	}
+
+	e.scratch[0] = describedVersion
+	e.scratch[1] = flags
+	e.Write(e.scratch[:2])
+	e.WriteBytes(schema.([]byte))
+	return nil
+}
+
+// ReadSchema reads the header of a self-describing value and returns its schema. The
+// options recorded by the header are applied to the decoder, so that the value which
+// follows can then be skipped or walked over.
+func (d *Decoder) ReadSchema() (*Schema, error) {
+	return d.readHeader(true)
+}
+
+// readHeader reads the header of a self-describing value, applies the options it records
+// and returns its schema if requested.
+func (d *Decoder) readHeader(decodeSchema bool) (s *Schema, err error) {
+	var version, flags byte
+	if version, err = d.r.ReadByte(); err != nil {
+		return
+	}
+	if version != describedVersion {
+		return nil, errors.New("binary: unsupported self-describing header")
+	}
+	if flags, err = d.r.ReadByte(); err != nil {
+		return
+	}
+
+	d.opts.versioned = flags&flagVersioned != 0
+	d.opts.intern = flags&flagInterned != 0
+	d.opts.bigEndian = flags&flagBigEndian != 0
+
+	var l int
+	if l, err = d.readSliceLen(); err != nil {
+		return
+	}
+
+	if !decodeSchema {
+		return nil, d.skipBytes(l)
+	}
+
+	var b []byte
+	if b, err = d.Slice(l); err == nil {
+		s = new(Schema)
+		err = s.UnmarshalBinary(b)
+	}
+	return
+}
+
+// ------------------------------------------------------------------------------
+
+// MarshalBinary encodes the schema into a compact binary form, which preserves cycles.
+func (s *Schema) MarshalBinary() ([]byte, error) {
+	index := make(map[*Schema]int)
+	var list []*Schema
+	var visit func(*Schema)
+	visit = func(s *Schema) {
+		if _, ok := index[s]; ok || s == nil {
+			return
+		}
+
+		index[s] = len(list)
+		list = append(list, s)
+		visit(s.Key)
+		visit(s.Elem)
+		for _, f := range s.Fields {
+			visit(f.Schema)
+		}
+	}
+	visit(s)
+
+	// References are written as their index plus one, with zero for none
+	ref := func(s *Schema) uint64 {
+		if s == nil {
+			return 0
+		}
+		return uint64(index[s]) + 1
+	}
+
+	var out appendWriter
+	e := NewEncoder(&out)
+	e.WriteUvarint(uint64(len(list)))
+	for _, s := range list {
+		e.WriteUint8(uint8(s.Kind))
+		e.WriteString(s.Name)
+		e.WriteUvarint(uint64(s.Size))
+		e.WriteUvarint(uint64(s.Len))
+		e.WriteUvarint(ref(s.Key))
+		e.WriteUvarint(ref(s.Elem))
+		e.WriteUvarint(uint64(len(s.Fields)))
+		for _, f := range s.Fields {
+			e.WriteString(f.Name)
+			e.WriteUvarint(uint64(f.ID))
+			e.WriteBool(f.OmitEmpty)
+			e.WriteUvarint(ref(f.Schema))
+		}
+	}
+
+	if err := e.Flush(); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// UnmarshalBinary decodes a schema encoded by MarshalBinary. As schemas may come from
+// untrusted payloads, they are validated so that traversing them always consumes input.
+func (s *Schema) UnmarshalBinary(b []byte) error {
+	d := newDecoder(NewSliceReader(b))
+	n, err := d.ReadUvarint()
+	switch {
+	case err != nil:
+		return err
+	case n == 0 || n > uint64(len(b)):
+		return errInvalidSchema
+	}
+
+	// The root is decoded into the receiver, so that cycles point to it
+	list := make([]*Schema, n)
+	list[0] = s
+	for i := 1; i < len(list); i++ {
+		list[i] = new(Schema)
+	}
+
+	ref := func() (*Schema, error) {
+		i, err := d.ReadUvarint()
+		switch {
+		case err != nil:
+			return nil, err
+		case i > uint64(len(list)):
+			return nil, errInvalidSchema
+		case i == 0:
+			return nil, nil
+		default:
+			return list[i-1], nil
+		}
+	}
+
+	for _, s := range list {
+		*s = Schema{}
+		if err = s.unmarshal(d, ref); err != nil {
+			return err
+		}
+	}
+
+	if d.s.Len() > 0 {
+		return errInvalidSchema
+	}
+
+	state := make(map[*Schema]uint8, len(list))
+	for _, s := range list {
+		if err = validateSchema(s, state); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// unmarshal decodes a single schema, whose references are resolved by the function.
+func (s *Schema) unmarshal(d *Decoder, ref func() (*Schema, error)) (err error) {
+	var kind uint8
+	var size, l, fields uint64
+	if kind, err = d.ReadUint8(); err != nil {
+		return
+	}
+	if s.Name, err = d.ReadString(); err != nil {
+		return
+	}
+	if size, err = d.ReadUvarint(); err != nil {
+		return
+	}
+	if l, err = d.ReadUvarint(); err != nil {
+		return
+	}
+	if s.Key, err = ref(); err != nil {
+		return
+	}
+	if s.Elem, err = ref(); err != nil {
+		return
+	}
+	if fields, err = d.ReadUvarint(); err != nil {
+		return
+	}
+	if fields > uint64(d.s.Len()) || l > uint64(maxInt) || size > 16 {
+		return errInvalidSchema
+	}
+
+	s.Kind, s.Size, s.Len = Kind(kind), int(size), int(l)
+	for i := 0; i < int(fields); i++ {
+		var f Field
+		var id uint64
+		if f.Name, err = d.ReadString(); err != nil {
+			return
+		}
+		if id, err = d.ReadUvarint(); err != nil {
+			return
+		}
+		if f.OmitEmpty, err = d.ReadBool(); err != nil {
+			return
+		}
+		if f.Schema, err = ref(); err != nil {
+			return
+		}
+		if f.Schema == nil || id > uint64(maxInt) {
+			return errInvalidSchema
+		}
+
+		f.ID = int(id)
+		s.Fields = append(s.Fields, f)
+	}
+	return
+}
+
+// The error returned for invalid schemas
+var errInvalidSchema = errors.New("binary: invalid schema")
+
+// validateSchema checks that the schema is consistent with its kind, and that it has no
+// cycles through arrays and structs, which would be traversed without consuming input.
+// Cycles through pointers, slices or maps are fine, as they consume input first.
+func validateSchema(s *Schema, state map[*Schema]uint8) error {
+	const visiting, done = 1, 2
+	switch state[s] {
+	case visiting:
+		return errInvalidSchema
+	case done:
+		return nil
+	}
+
+	state[s] = visiting
+	switch s.Kind {
+	case KindBool, KindVarint, KindUvarint, KindString, KindBytes, KindTime,
+		KindUnixNano, KindBigInt, KindBigRat, KindBigFloat, KindInterface, KindOpaque, KindBits, KindGorilla, KindRawVarint, KindSQL:
+	case KindInt, KindUint:
+		if s.Size != 1 && s.Size != 2 && s.Size != 4 && s.Size != 8 {
+			return errInvalidSchema
+		}
+	case KindFloat:
+		if s.Size != 4 && s.Size != 8 {
+			return errInvalidSchema
+		}
+	case KindComplex:
+		if s.Size != 8 && s.Size != 16 {
+			return errInvalidSchema
+		}
+	case KindDelta, KindVByte:
+		if s.Elem == nil || (s.Elem.Kind != KindVarint && s.Elem.Kind != KindUvarint) {
+			return errInvalidSchema
+		}
+	case KindSlice, KindPointer, KindRLE:
+		if s.Elem == nil {
+			return errInvalidSchema
+		}
+	case KindMap:
+		if s.Elem == nil || s.Key == nil {
+			return errInvalidSchema
+		}
+	case KindArray:
+		if s.Elem == nil {
+			return errInvalidSchema
+		}
+		if err := validateSchema(s.Elem, state); err != nil {
+			return err
+		}
+	case KindStruct, KindColumnar:
+		for _, f := range s.Fields {
+			if err := validateSchema(f.Schema, state); err != nil {
+				return err
+			}
+		}
+	default:
+		return errInvalidSchema
+	}
+
+	state[s] = done
+	return nil
+}
@@ -0,0 +1,107 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package binary
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSelfDescribing(t *testing.T) {
+	v := newWalked()
+	for _, opts := range [][]Option{
+		{SelfDescribing()},
+		{SelfDescribing(), Versioned()},
+		{SelfDescribing(), InternStrings(), ByteOrder(BigEndian)},
+	} {
+		b, err := Marshal(v, opts...)
+		assert.NoError(t, err)
+
+		// Decoders only need the option, as the header records the others
+		var out walked
+		assert.NoError(t, Unmarshal(b, &out, SelfDescribing()))
+		assert.Equal(t, v, &out)
+
+		// The schema is read from the header and the value can be skipped over
+		d := NewDecoder(bytes.NewReader(b))
+		s, err := d.ReadSchema()
+		assert.NoError(t, err)
+		assert.Equal(t, KindStruct, s.Kind)
+		assert.Len(t, s.Fields, 19)
+		assert.NoError(t, d.Skip(s))
+		_, err = d.ReadUint8()
+		assert.Error(t, err)
+	}
+}
+
+func TestSelfDescribing_Invalid(t *testing.T) {
+	b, err := Marshal(newWalked(), SelfDescribing())
+	assert.NoError(t, err)
+
+	var out walked
+	b[0] = describedVersion + 1
+	assert.Error(t, Unmarshal(b, &out, SelfDescribing()))
+	assert.Error(t, Unmarshal(b[:1], &out, SelfDescribing()))
+}
+
+func TestSchema_MarshalBinary(t *testing.T) {
+	for _, v := range []interface{}{walked{}, linkedList{}, map[string][]float64{}} {
+		s, err := SchemaOf(reflect.TypeOf(v))
+		assert.NoError(t, err)
+
+		b, err := s.MarshalBinary()
+		assert.NoError(t, err)
+
+		out := new(Schema)
+		assert.NoError(t, out.UnmarshalBinary(b))
+		assert.Equal(t, s, out)
+	}
+
+	// Cycles are preserved
+	s, err := SchemaOf(reflect.TypeOf(linkedList{}))
+	assert.NoError(t, err)
+	b, err := s.MarshalBinary()
+	assert.NoError(t, err)
+
+	out := new(Schema)
+	assert.NoError(t, out.UnmarshalBinary(b))
+	assert.True(t, out == out.Fields[1].Schema.Elem)
+}
+
+func TestSchema_UnmarshalInvalid(t *testing.T) {
+	encode := func(s *Schema) []byte {
+		b, err := s.MarshalBinary()
+		assert.NoError(t, err)
+		return b
+	}
+
+	// A struct which contains itself would be traversed forever
+	loop := &Schema{Kind: KindStruct}
+	loop.Fields = []Field{{Name: "A", Schema: loop}}
+
+	array := &Schema{Kind: KindArray, Len: 1}
+	array.Elem = array
+
+	tests := [][]byte{
+		nil,
+		{0x0},
+		{0x1},
+		encode(&Schema{Kind: KindInt, Size: 3}),
+		encode(&Schema{Kind: KindFloat, Size: 2}),
+		encode(&Schema{Kind: KindSlice}),
+		encode(&Schema{Kind: KindMap, Elem: &Schema{Kind: KindBool}}),
+		encode(&Schema{Kind: Kind(100)}),
+		encode(loop),
+		encode(array),
+		append(encode(&Schema{Kind: KindBool}), 0x0),
+		{0x1, byte(KindPointer), 0x0, 0x0, 0x0, 0x0, 0x5, 0x0},
+	}
+
+	for _, b := range tests {
+		assert.Error(t, new(Schema).UnmarshalBinary(b), "%v", b)
+	}
+}
@@ -0,0 +1,192 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package binary
+
+import (
+	"reflect"
+)
+
+// Diff encodes the changes between two values of type T into a patch, which turns the old
+// value into the new one when passed to Apply. Only the fields which changed are encoded,
+// so that a state which changes a little at a time, such as the state of a game sent on
+// every tick, can be replicated without sending a whole snapshot. The patch starts with a
+// bitmap of the changed fields of a struct, followed by the changed fields themselves.
+// Fields holding structs are diffed recursively, while other fields, such as slices and
+// maps, are encoded in full whenever they change. The patch relies on the layout of T,
+// so both ends must use the same type and the same options.
+func Diff[T any](old, new T, opts ...Option) ([]byte, error) {
+	rv := reflect.ValueOf(&new).Elem()
+	c, err := scan(rv.Type())
+	if err != nil {
+		return nil, err
+	}
+
+	return marshalWith(&patchCodec{codec: c, old: reflect.ValueOf(&old).Elem()}, rv, opts)
+}
+
+// Apply applies a patch produced by Diff to a value, which must be equal to the old value
+// the patch was computed from. The fields which changed are replaced, and the other ones
+// are left untouched.
+func Apply[T any](old *T, patch []byte, opts ...Option) error {
+	rv := reflect.ValueOf(old).Elem()
+	c, err := scan(rv.Type())
+	if err != nil {
+		return err
+	}
+
+	_, err = decodeBytesWith(patch, &patchCodec{codec: c}, rv, opts, true)
+	return err
+}
+
+// ------------------------------------------------------------------------------
+
+// patchCodec represents a codec which encodes the changes of a value with respect to an
+// old value, and applies them to a value when decoding.
+type patchCodec struct {
+	codec Codec         // The codec of the value
+	old   reflect.Value // The old value, when encoding
+}
+
+// Encode encodes a value into the encoder.
+func (c *patchCodec) EncodeTo(e *Encoder, rv reflect.Value) error {
+	return diffValue(e, c.codec, c.old, rv)
+}
+
+// Decode decodes into a reflect value from the decoder.
+func (c *patchCodec) DecodeTo(d *Decoder, rv reflect.Value) error {
+	return applyValue(d, c.codec, rv)
+}
+
+// diffValue encodes the changes between two values which share a codec. A struct is
+// diffed field by field, and any other value is preceded by a single bit telling whether
+// it changed.
+func diffValue(e *Encoder, c Codec, old, new reflect.Value) error {
+	if codec, ok := c.(*reflectStructCodec); ok && new.Kind() == reflect.Struct {
+		return diffStruct(e, codec, old, new)
+	}
+
+	changed := !reflect.DeepEqual(old.Interface(), new.Interface())
+	if e.writePresence([]byte{changedBit(changed)}); !changed {
+		return nil
+	}
+	return c.EncodeTo(e, new)
+}
+
+// diffStruct encodes the bitmap of the encoded fields of a struct which changed, followed
+// by the changes of each of these fields.
+func diffStruct(e *Encoder, c *reflectStructCodec, old, new reflect.Value) error {
+	var buffer [8]byte
+	bits, n := buffer[:0], 0
+	for _, f := range *c {
+		if err := e.opts().canceled(0); err != nil {
+			return err
+		}
+
+		ov, _, _ := f.access(old, e.opts())
+		nv, ok, err := f.access(new, e.opts())
+		switch {
+		case err != nil:
+			return err
+		case !ok:
+			continue
+		}
+
+		if n%8 == 0 {
+			bits = append(bits, 0)
+		}
+		if !reflect.DeepEqual(ov.Interface(), nv.Interface()) {
+			bits[n/8] |= 1 << (n % 8)
+		}
+		n++
+	}
+	e.writePresence(bits)
+
+	n = 0
+	for _, f := range *c {
+		ov, _, _ := f.access(old, e.opts())
+		nv, ok, _ := f.access(new, e.opts())
+		if !ok {
+			continue
+		}
+
+		if n++; !present(bits, n-1) {
+			continue
+		}
+		if err := diffValue(e, f.Codec, ov, nv); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// applyValue decodes the changes of a value and applies them to it. A value which changed
+// is decoded from its zero value, so that it ends up exactly like the new value.
+func applyValue(d *Decoder, c Codec, rv reflect.Value) (err error) {
+	if codec, ok := c.(*reflectStructCodec); ok && rv.Kind() == reflect.Struct {
+		return applyStruct(d, codec, rv)
+	}
+
+	var buffer [1]byte
+	var bits []byte
+	if bits, err = d.readPresence(1, buffer[:]); err != nil || !present(bits, 0) {
+		return
+	}
+
+	rv.Set(reflect.Zero(rv.Type()))
+	return c.DecodeTo(d, rv)
+}
+
+// applyStruct decodes the bitmap of the fields of a struct which changed, and applies the
+// changes of each of these fields.
+func applyStruct(d *Decoder, c *reflectStructCodec, rv reflect.Value) (err error) {
+	if err = d.enter(); err != nil {
+		return
+	}
+	defer d.leave()
+
+	var count int
+	for _, f := range *c {
+		var ok bool
+		if ok, err = f.encoded(&d.opts, rv.Type()); err != nil {
+			return
+		}
+		if ok {
+			count++
+		}
+	}
+
+	var buffer [8]byte
+	var bits []byte
+	if bits, err = d.readPresence(count, buffer[:]); err != nil {
+		return
+	}
+
+	n := 0
+	for _, f := range *c {
+		if err = d.opts.canceled(0); err != nil {
+			return
+		}
+
+		v, ok, _ := f.access(rv, &d.opts)
+		if !ok {
+			continue
+		}
+
+		if n++; !present(bits, n-1) {
+			continue
+		}
+		if err = applyValue(d, f.Codec, v); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// changedBit returns the bitmap of a single value, telling whether it changed.
+func changedBit(changed bool) byte {
+	if changed {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,131 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package binary
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type playerPosition struct {
+	X, Y float64
+}
+
+type playerState struct {
+	Name      string
+	Health    int
+	Position  playerPosition
+	Inventory []string
+	Stats     map[string]int
+	Target    *playerPosition
+	secret    int
+}
+
+func newPlayerState() playerState {
+	return playerState{
+		Name:      "Roman",
+		Health:    100,
+		Position:  playerPosition{X: 1, Y: 2},
+		Inventory: []string{"sword", "shield"},
+		Stats:     map[string]int{"kills": 3},
+		Target:    &playerPosition{X: 5, Y: 5},
+		secret:    42,
+	}
+}
+
+func TestDiff(t *testing.T) {
+	old := newPlayerState()
+	new := newPlayerState()
+	new.Health = 90
+	new.Position.Y = 3
+	new.Inventory = []string{"sword"}
+
+	patch, err := Diff(old, new)
+	assert.NoError(t, err)
+
+	full, err := Marshal(&new)
+	assert.NoError(t, err)
+	assert.True(t, len(patch) < len(full))
+
+	state := newPlayerState()
+	assert.NoError(t, Apply(&state, patch))
+	assert.Equal(t, new, state)
+}
+
+func TestDiff_Unchanged(t *testing.T) {
+	old := newPlayerState()
+	patch, err := Diff(old, newPlayerState())
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{0}, patch)
+
+	state := newPlayerState()
+	assert.NoError(t, Apply(&state, patch))
+	assert.Equal(t, old, state)
+}
+
+func TestDiff_Replaced(t *testing.T) {
+	old := newPlayerState()
+	new := playerState{Name: "Florimond", Stats: map[string]int{"deaths": 1}}
+
+	patch, err := Diff(old, new)
+	assert.NoError(t, err)
+
+	// The fields which changed are replaced rather than merged, and the unexported field
+	// is left as it was
+	state := newPlayerState()
+	assert.NoError(t, Apply(&state, patch))
+	assert.Equal(t, "Florimond", state.Name)
+	assert.Equal(t, map[string]int{"deaths": 1}, state.Stats)
+	assert.Nil(t, state.Inventory)
+	assert.Nil(t, state.Target)
+	assert.Equal(t, playerPosition{}, state.Position)
+	assert.Equal(t, 42, state.secret)
+}
+
+func TestDiff_Options(t *testing.T) {
+	old := newPlayerState()
+	new := newPlayerState()
+	new.secret = 7
+	new.Stats["kills"] = 4
+
+	for _, opts := range [][]Option{
+		{Unexported(UnexportedInclude)},
+		{Unexported(UnexportedInclude), Checksum()},
+		{Unexported(UnexportedInclude), Versioned()},
+	} {
+		patch, err := Diff(old, new, opts...)
+		assert.NoError(t, err)
+
+		state := newPlayerState()
+		assert.NoError(t, Apply(&state, patch, opts...))
+		assert.Equal(t, new, state)
+	}
+
+	// A corrupted patch is detected with the Checksum option
+	patch, err := Diff(old, new, Checksum())
+	assert.NoError(t, err)
+	patch[len(patch)-1] ^= 0xff
+	state := newPlayerState()
+	assert.Error(t, Apply(&state, patch, Checksum()))
+}
+
+func TestDiff_Scalar(t *testing.T) {
+	patch, err := Diff([]int{1, 2}, []int{1, 2, 3})
+	assert.NoError(t, err)
+
+	v := []int{1, 2}
+	assert.NoError(t, Apply(&v, patch))
+	assert.Equal(t, []int{1, 2, 3}, v)
+
+	patch, err = Diff(1, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{0}, patch)
+
+	_, err = Diff(make(chan int), make(chan int))
+	assert.Error(t, err)
+
+	var n int
+	assert.Error(t, Apply(&n, nil))
+}
@@ -4,10 +4,11 @@
 package binary
 
 import (
-	"bytes"
-	"encoding/binary"
+	"hash"
+	"hash/crc32"
 	"io"
 	"math"
+	"math/bits"
 	"reflect"
 	"sync"
 )
@@ -18,59 +19,477 @@ var encoders = &sync.Pool{New: func() interface{} {
 }}
 
 // Marshal encodes the payload into binary format.
-func Marshal(v interface{}) (output []byte, err error) {
-	var buffer bytes.Buffer
+func Marshal(v interface{}, opts ...Option) (output []byte, err error) {
+	if out, ok := appendFast(nil, v, opts); ok {
+		return out, nil
+	}
+	return marshal(reflect.Indirect(reflect.ValueOf(v)), opts)
+}
+
+// marshal encodes a reflected value into binary format. The value is encoded into a
+// buffer taken from a pool, and then copied, so that the output is allocated only once.
+func marshal(rv reflect.Value, opts []Option) (output []byte, err error) {
+	var c Codec
+	if c, err = scan(rv.Type()); err != nil {
+		return
+	}
+	return marshalWith(c, rv, opts)
+}
+
+// marshalWith encodes a reflected value into binary format with its codec.
+func marshalWith(c Codec, rv reflect.Value, opts []Option) (output []byte, err error) {
+	// Get the encoder and the buffer from the pool, reset them
+	b := buffers.Get().(*Buffer)
+	e := encoders.Get().(*Encoder)
+	e.out = (*appendWriter)(&b.b)
+	e.err = nil
+	e.configure(opts)
+
+	// Encode and copy the buffer if successful
+	if err = e.encodeWith(c, rv); err == nil {
+		output = append([]byte(nil), b.b...)
+	}
+
+	// Put the encoder and the buffer back when we're finished
+	e.out = nil
+	encoders.Put(e)
+	b.Release()
+	return
+}
+
+// Reusable long-lived pool of writers which append to a byte slice.
+var appenders = &sync.Pool{New: func() interface{} {
+	return new(appendWriter)
+}}
+
+// MarshalTo encodes the payload into binary format and appends it to the provided
+// buffer, returning the extended buffer. If the buffer has enough capacity, this
+// does not allocate. On error, the buffer is returned unmodified.
+func MarshalTo(buffer []byte, v interface{}, opts ...Option) ([]byte, error) {
+	if out, ok := appendFast(buffer, v, opts); ok {
+		return out, nil
+	}
+
+	w := appenders.Get().(*appendWriter)
+	*w = buffer
 
 	// Get the encoder from the pool, reset it
 	e := encoders.Get().(*Encoder)
-	e.out = &buffer
+	e.out = w
 	e.err = nil
+	e.configure(opts)
+
+	// Encode and extend the buffer if successful
+	err := e.Encode(v)
+	if err == nil {
+		buffer = *w
+	}
 
-	// Encode and set the buffer if successful
+	// Put the encoder and the writer back when we're finished
+	e.out = nil
+	*w = nil
+	encoders.Put(e)
+	appenders.Put(w)
+	return buffer, err
+}
+
+// Reusable long-lived pool of output buffers, returned by MarshalPooled.
+var buffers = &sync.Pool{New: func() interface{} {
+	return new(Buffer)
+}}
+
+// The maximum capacity of the buffers which are returned to the pool, so that a single
+// large value does not stay around forever.
+const maxPooledBuffer = 1 << 20
+
+// Buffer represents an encoded value, whose bytes are held by a buffer from a pool.
+type Buffer struct {
+	b []byte
+}
+
+// Bytes returns the encoded bytes, which are only valid until the buffer is released.
+func (b *Buffer) Bytes() []byte {
+	return b.b
+}
+
+// Release returns the buffer to the pool. Neither the buffer nor its bytes may be used
+// after that.
+func (b *Buffer) Release() {
+	if cap(b.b) > maxPooledBuffer {
+		b.b = nil
+	}
+
+	b.b = b.b[:0]
+	buffers.Put(b)
+}
+
+// MarshalPooled encodes the payload into binary format, into a buffer taken from a pool.
+// Releasing the buffer once its bytes have been used, for example written to a socket,
+// allows high-throughput applications to encode values without allocating.
+func MarshalPooled(v interface{}, opts ...Option) (*Buffer, error) {
+	b := buffers.Get().(*Buffer)
+	out, err := MarshalTo(b.b[:0], v, opts...)
+	if err != nil {
+		b.Release()
+		return nil, err
+	}
+
+	b.b = out
+	return b, nil
+}
+
+// appendWriter is a writer which appends to a byte slice.
+type appendWriter []byte
+
+// Write implements io.Writer interface.
+func (w *appendWriter) Write(p []byte) (int, error) {
+	*w = append(*w, p...)
+	return len(p), nil
+}
+
+// Size computes the number of bytes the value would be encoded into. If every codec
+// involved implements the Sizer interface, the size is computed without encoding the
+// value, otherwise the value is encoded into a writer which simply counts the bytes.
+func Size(v interface{}, opts ...Option) (size int, err error) {
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	var c Codec
+	if c, err = scan(rv.Type()); err != nil {
+		return
+	}
+
+	e := encoders.Get().(*Encoder)
+	e.configure(opts)
+	if e.opts().sizable() && !recursive(rv.Type()) {
+		if size = sizeOf(c, rv); size >= 0 {
+			size += e.opts().trailer()
+			encoders.Put(e)
+			return
+		}
+	}
+
+	// Fallback to encoding the value and counting the bytes
+	var counter countingWriter
+	e.out = &counter
+	e.err = nil
 	if err = e.Encode(v); err == nil {
-		output = buffer.Bytes()
+		size = int(counter)
 	}
 
-	// Put the encoder back when we're finished
 	encoders.Put(e)
 	return
 }
 
+// countingWriter is a writer which discards the bytes and only counts them.
+type countingWriter int64
+
+// Write implements io.Writer interface.
+func (w *countingWriter) Write(p []byte) (int, error) {
+	*w += countingWriter(len(p))
+	return len(p), nil
+}
+
+// uvarintSize returns the number of bytes a variable size unsigned integer is encoded into.
+func uvarintSize(x uint64) int {
+	return (bits.Len64(x|1) + 6) / 7
+}
+
+// varintSize returns the number of bytes a variable size integer is encoded into.
+func varintSize(v int64) int {
+	x := uint64(v) << 1
+	if v < 0 {
+		x = ^x
+	}
+	return uvarintSize(x)
+}
+
 // Encoder represents a binary encoder.
 type Encoder struct {
 	scratch [10]byte
 	out     io.Writer
 	err     error
+	buffer  []byte     // The pending bytes of a buffered encoder
+	nesting int        // The number of nested calls to encode
+	ext     *extension // The options and the state of the optional features, if any
+}
+
+// extension represents the options of an encoder along with the state of its optional
+// features, which is only allocated once an option or a feature is used, so that the
+// encoders without any of them remain small.
+type extension struct {
+	opts     options
+	crc      uint32                 // The checksum of the bytes written for the current value
+	hash     hash.Hash              // The hash of the bytes written, if any
+	vector   *vector                // The segments of a vectored encoder, if any
+	refs     int                    // The number of pointers, maps and slices being encoded
+	interned map[string]int         // The indices of the interned strings
+	pointers map[reference]int      // The indices of the shared pointers
+	visited  map[reference]struct{} // The references being encoded, to detect cycles
+}
+
+// The options of the encoders which were created without any, which are never modified
+var noOptions options
+
+// opts returns the options of the encoder.
+func (e *Encoder) opts() *options {
+	if e.ext == nil {
+		return &noOptions
+	}
+	return &e.ext.opts
+}
+
+// extend returns the extension of the encoder, allocating it on first use.
+func (e *Encoder) extend() *extension {
+	if e.ext == nil {
+		e.ext = new(extension)
+	}
+	return e.ext
+}
+
+// configure resets the options of the encoder, allocating its extension only if there
+// are options to apply.
+func (e *Encoder) configure(opts []Option) {
+	if e.ext != nil || len(opts) > 0 {
+		e.extend().opts.reset(opts)
+	}
+}
+
+// digest updates the checksum and the hash, if enabled, with the bytes written.
+func (x *extension) digest(p []byte) {
+	if x.opts.checksum {
+		x.crc = crc32.Update(x.crc, castagnoli, p)
+	}
+	if x.hash != nil {
+		x.hash.Write(p)
+	}
+}
+
+// NewEncoder creates a new encoder which writes directly to the writer.
+func NewEncoder(out io.Writer, opts ...Option) *Encoder {
+	e := &Encoder{out: out}
+	e.configure(opts)
+	return e
+}
+
+// NewEncoderSize creates a new buffered encoder, with a buffer of at least the specified
+// size. This avoids issuing a write to the underlying writer (e.g. a socket) for every
+// small value, but requires calling Flush once the values have been encoded.
+func NewEncoderSize(out io.Writer, size int, opts ...Option) *Encoder {
+	if size <= 0 {
+		size = defaultBufferSize
+	}
+
+	e := NewEncoder(out, opts...)
+	e.buffer = make([]byte, 0, size)
+	return e
+}
+
+// Reset discards any unflushed data and the error of the encoder, and switches it to
+// writing to another writer while keeping its options and the capacity of its buffer.
+// This allows long-lived encoders to be reused against changing writers, such as
+// connections, without any allocation.
+func (e *Encoder) Reset(out io.Writer) {
+	if w, ok := e.out.(*progressWriter); ok {
+		w.Writer = out
+		w.reset()
+	} else {
+		e.out = out
+	}
+
+	e.err = nil
+	e.nesting = 0
+	if e.buffer != nil {
+		e.buffer = e.buffer[:0]
+	}
+	if x := e.ext; x != nil {
+		x.crc = 0
+		x.refs = 0
+		clear(x.visited)
+		if x.vector != nil {
+			x.vector.reset()
+		}
+		clear(x.interned)
+		clear(x.pointers)
+	}
 }
 
-// NewEncoder creates a new encoder.
-func NewEncoder(out io.Writer) *Encoder {
-	return &Encoder{
-		out: out,
+// SetHash attaches a hash to the encoder, which is then updated with every byte the
+// encoder writes, so that the digest of the payload is computed while encoding it rather
+// than in a second pass over the bytes, for example to address it by its content. The
+// hash is left as it is by Reset, and a nil hash detaches it.
+//
+//	h := sha256.New()
+//	encoder.SetHash(h)
+//	err := encoder.Encode(v)
+//	digest := h.Sum(nil)
+func (e *Encoder) SetHash(h hash.Hash) {
+	if e.ext != nil || h != nil {
+		e.extend().hash = h
 	}
 }
 
+// Flush writes any buffered data to the underlying writer and returns the first error
+// encountered by the encoder, if any. It is a no-op for encoders without a buffer.
+func (e *Encoder) Flush() error {
+	if e.err == nil && e.vectored() {
+		_, e.err = e.writeVector(e.out)
+		return e.err
+	}
+
+	if e.err == nil && len(e.buffer) > 0 {
+		_, e.err = e.out.Write(e.buffer)
+		e.buffer = e.buffer[:0]
+	}
+	return e.err
+}
+
+// Buffered returns the number of bytes which have been encoded but not yet flushed.
+func (e *Encoder) Buffered() int {
+	if e.vectored() {
+		return e.ext.vector.size() + len(e.buffer) - e.ext.vector.mark
+	}
+	return len(e.buffer)
+}
+
+// WriteTo writes the buffered data to the specified writer instead of the underlying
+// writer of the encoder, which allows redirecting the output of a buffered encoder. It
+// implements io.WriterTo interface.
+func (e *Encoder) WriteTo(w io.Writer) (n int64, err error) {
+	if e.err != nil {
+		return 0, e.err
+	}
+	if e.vectored() {
+		return e.writeVector(w)
+	}
+
+	m, err := w.Write(e.buffer)
+	e.buffer = e.buffer[:0]
+	return int64(m), err
+}
+
 // Encode encodes the value to the binary format.
 func (e *Encoder) Encode(v interface{}) (err error) {
+	return e.encode(reflect.Indirect(reflect.ValueOf(v)))
+}
+
+// encode encodes a reflected value to the binary format.
+func (e *Encoder) encode(rv reflect.Value) (err error) {
 
 	// Scan the type (this will load from cache)
-	rv := reflect.Indirect(reflect.ValueOf(v))
 	var c Codec
 	if c, err = scan(rv.Type()); err != nil {
 		return
 	}
 
-	// Encode the value
-	if err = c.EncodeTo(e, rv); err == nil {
+	return e.encodeWith(c, rv)
+}
+
+// encodeWith encodes a reflected value with its codec. The tables of interned strings and
+// shared pointers are scoped to the outermost call, as codecs may encode nested values
+// with Encode.
+func (e *Encoder) encodeWith(c Codec, rv reflect.Value) (err error) {
+	if e.nesting == 0 {
+		if e.ext != nil {
+			e.ext.crc = 0
+		}
+		if o := e.opts(); o.shared && o.versioned {
+			return errSharedVersioned
+		}
+		if e.opts().selfDescribing {
+			if err = e.writeHeader(rv.Type()); err != nil {
+				return
+			}
+		}
+	}
+
+	if e.nesting++; e.nesting == 1 && e.opts().compressor != nil {
+		err = e.writeCompressed(c, rv)
+	} else {
+		err = c.EncodeTo(e, rv)
+	}
+
+	if err == nil {
 		err = e.err
 	}
+
+	if e.nesting--; e.nesting == 0 && e.ext != nil {
+		clear(e.ext.interned)
+		clear(e.ext.pointers)
+		if err == nil && e.ext.opts.checksum {
+			e.WriteUint32(e.ext.crc)
+			err = e.err
+		}
+	}
 	return
 }
 
-// Write writes the contents of p into the buffer.
-func (e *Encoder) Write(p []byte) {
+// writeNested encodes a nested payload into a temporary buffer and writes it prefixed
+// with its length, so that a decoder is able to skip it without knowing its type.
+func (e *Encoder) writeNested(encode func() error) (err error) {
+	w := appenders.Get().(*appendWriter)
+	out, buffer, x := e.out, e.buffer, e.ext
+	e.out, e.buffer = w, nil
+	var saved extension
+	if x != nil {
+		saved = *x
+		x.vector, x.interned, x.pointers, x.hash = nil, nil, nil, nil
+	}
+
+	err = encode()
+	e.out, e.buffer = out, buffer
+	if x != nil {
+		x.vector, x.interned, x.pointers, x.crc, x.hash = saved.vector, saved.interned, saved.pointers, saved.crc, saved.hash
+	}
+
+	if err == nil {
+		e.WriteUvarint(uint64(len(*w)))
+		e.Write(*w)
+	}
+
+	*w = (*w)[:0]
+	appenders.Put(w)
+	return
+}
+
+// Err returns the first error encountered by the encoder, if any. Once an error has been
+// encountered, the Write methods are no-ops, so custom codecs can check it in order to
+// stop early rather than encoding the rest of a large value for nothing.
+func (e *Encoder) Err() error {
+	return e.err
+}
+
+// Fail records an error which fails the encoding, unless an error was already encountered.
+// The Write methods then become no-ops and Encode returns the first error, which allows
+// custom codecs to short-circuit from within helpers which do not return errors.
+func (e *Encoder) Fail(err error) {
 	if e.err == nil {
+		e.err = err
+	}
+}
+
+// Write writes the contents of p into the buffer. It is a no-op once the encoder has
+// encountered an error.
+func (e *Encoder) Write(p []byte) {
+	if e.err != nil {
+		return
+	}
+
+	if e.ext != nil {
+		e.ext.digest(p)
+	}
+
+	switch {
+	case e.buffer == nil:
 		_, e.err = e.out.Write(p)
+	case len(e.buffer)+len(p) <= cap(e.buffer):
+		e.buffer = append(e.buffer, p...)
+	default:
+		if e.Flush(); e.err == nil {
+			if len(p) >= cap(e.buffer) {
+				_, e.err = e.out.Write(p) // Large writes bypass the buffer
+				return
+			}
+			e.buffer = append(e.buffer, p...)
+		}
 	}
 }
 
@@ -103,15 +522,23 @@ func (e *Encoder) WriteUvarint(x uint64) {
 	e.Write(e.scratch[:(i + 1)])
 }
 
-// WriteUint16 writes a Uint16
+// WriteUint16 writes a Uint16, in the configured byte order (little-endian by default)
 func (e *Encoder) WriteUint16(v uint16) {
+	if e.opts().bigEndian {
+		v = bits.ReverseBytes16(v)
+	}
+
 	e.scratch[0] = byte(v)
 	e.scratch[1] = byte(v >> 8)
 	e.Write(e.scratch[:2])
 }
 
-// WriteUint32 writes a Uint32
+// WriteUint32 writes a Uint32, in the configured byte order (little-endian by default)
 func (e *Encoder) WriteUint32(v uint32) {
+	if e.opts().bigEndian {
+		v = bits.ReverseBytes32(v)
+	}
+
 	e.scratch[0] = byte(v)
 	e.scratch[1] = byte(v >> 8)
 	e.scratch[2] = byte(v >> 16)
@@ -119,8 +546,12 @@ func (e *Encoder) WriteUint32(v uint32) {
 	e.Write(e.scratch[:4])
 }
 
-// WriteUint64 writes a Uint64
+// WriteUint64 writes a Uint64, in the configured byte order (little-endian by default)
 func (e *Encoder) WriteUint64(v uint64) {
+	if e.opts().bigEndian {
+		v = bits.ReverseBytes64(v)
+	}
+
 	e.scratch[0] = byte(v)
 	e.scratch[1] = byte(v >> 8)
 	e.scratch[2] = byte(v >> 16)
@@ -132,18 +563,79 @@ func (e *Encoder) WriteUint64(v uint64) {
 	e.Write(e.scratch[:8])
 }
 
+// WriteInt8 writes a single signed byte
+func (e *Encoder) WriteInt8(v int8) {
+	e.WriteUint8(uint8(v))
+}
+
+// WriteInt16 writes an Int16, in the configured byte order (little-endian by default)
+func (e *Encoder) WriteInt16(v int16) {
+	e.WriteUint16(uint16(v))
+}
+
+// WriteInt32 writes an Int32, in the configured byte order (little-endian by default)
+func (e *Encoder) WriteInt32(v int32) {
+	e.WriteUint32(uint32(v))
+}
+
+// WriteInt64 writes an Int64, in the configured byte order (little-endian by default)
+func (e *Encoder) WriteInt64(v int64) {
+	e.WriteUint64(uint64(v))
+}
+
+// writeFixed writes an integer of the specified size in bytes
+func (e *Encoder) writeFixed(v uint64, size int) {
+	switch size {
+	case 1:
+		e.scratch[0] = byte(v)
+		e.Write(e.scratch[:1])
+	case 2:
+		e.WriteUint16(uint16(v))
+	case 4:
+		e.WriteUint32(uint32(v))
+	default:
+		e.WriteUint64(v)
+	}
+}
+
 // WriteFloat32 a 32-bit floating point number
 func (e *Encoder) WriteFloat32(v float32) {
+	if e.opts().canonical {
+		switch {
+		case v != v:
+			v = float32(math.NaN())
+		case v == 0:
+			v = 0 // Negative zero
+		}
+	}
+
 	e.WriteUint32(math.Float32bits(v))
 }
 
 // WriteFloat64 a 64-bit floating point number
 func (e *Encoder) WriteFloat64(v float64) {
+	if e.opts().canonical {
+		v = canonicalFloat64(v)
+	}
+
 	e.WriteUint64(math.Float64bits(v))
 }
 
+// canonicalFloat64 normalizes a 64-bit floating point number, so that all the NaNs and
+// both zeros are respectively encoded into the same bytes.
+func canonicalFloat64(v float64) float64 {
+	switch {
+	case v != v:
+		return math.NaN()
+	case v == 0:
+		return 0 // Negative zero
+	default:
+		return v
+	}
+}
+
 // WriteBool writes a single boolean value into the buffer
-func (e *Encoder) writeBool(v bool) {
+func (e *Encoder) WriteBool(v bool) {
 	e.scratch[0] = 0
 	if v {
 		e.scratch[0] = 1
@@ -151,12 +643,79 @@ func (e *Encoder) writeBool(v bool) {
 	e.Write(e.scratch[:1])
 }
 
-// Writes a complex number
-func (e *Encoder) writeComplex64(v complex64) {
-	e.err = binary.Write(e.out, binary.LittleEndian, v)
+// WriteUint8 writes a single byte into the buffer
+func (e *Encoder) WriteUint8(v uint8) {
+	e.scratch[0] = v
+	e.Write(e.scratch[:1])
+}
+
+// WriteString writes a string prefixed with its length
+func (e *Encoder) WriteString(v string) {
+	if e.opts().intern {
+		e.writeInterned(v)
+		return
+	}
+
+	e.WriteLengthPrefixed(stringToBinary(v))
+}
+
+// WriteStringMax writes a string like WriteString, and fails with ErrLimitExceeded if it
+// is longer than the maximum length, in which case nothing is written. This catches the
+// strings which the decoder would reject with ReadStringMax before they are sent.
+func (e *Encoder) WriteStringMax(v string, maxLen int) error {
+	if maxLen > 0 && len(v) > maxLen {
+		return limitError("string length", uint64(len(v)), maxLen)
+	}
+
+	e.WriteString(v)
+	return nil
+}
+
+// writeInterned writes a string which was already written as a reference to its index,
+// or otherwise as its length followed by its bytes. The lowest bit of the header tells
+// whether it is a reference. Empty strings are never interned.
+func (e *Encoder) writeInterned(v string) {
+	if e.err != nil {
+		return
+	}
+
+	x := e.extend()
+	if i, ok := x.interned[v]; ok {
+		e.WriteUvarint(uint64(i)<<1 | 1)
+		return
+	}
+
+	if len(v) > 0 {
+		if x.interned == nil {
+			x.interned = make(map[string]int)
+		}
+		x.interned[v] = len(x.interned)
+	}
+
+	e.WriteUvarint(uint64(len(v)) << 1)
+	e.Write(stringToBinary(v))
+}
+
+// WriteBytes writes a byte slice prefixed with its length
+func (e *Encoder) WriteBytes(v []byte) {
+	e.WriteLengthPrefixed(v)
+}
+
+// WriteLengthPrefixed writes bytes prefixed with their length as a uvarint, which is how
+// strings and byte slices are encoded. They can be read back with ReadSlice.
+func (e *Encoder) WriteLengthPrefixed(p []byte) {
+	e.WriteUvarint(uint64(len(p)))
+	e.writeSegment(p)
+}
+
+// WriteComplex64 writes a complex64 as its real part followed by its imaginary part
+func (e *Encoder) WriteComplex64(v complex64) {
+	e.WriteFloat32(real(v))
+	e.WriteFloat32(imag(v))
 }
 
-// Writes a complex number
-func (e *Encoder) writeComplex128(v complex128) {
-	e.err = binary.Write(e.out, binary.LittleEndian, v)
+// WriteComplex128 writes a complex128 as its real part followed by its imaginary part
+func (e *Encoder) WriteComplex128(v complex128) {
+	e.WriteFloat64(real(v))
+	e.WriteFloat64(imag(v))
 }
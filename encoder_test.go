@@ -7,6 +7,9 @@ import (
 	"bytes"
 	"encoding/gob"
 	"encoding/json"
+	"errors"
+	"io"
+	"reflect"
 	"testing"
 	"unsafe"
 
@@ -111,6 +114,15 @@ func Benchmark_Binary(b *testing.B) {
 		}
 	})
 
+	b.Run("marshal-append", func(b *testing.B) {
+		buffer := make([]byte, 0, 1024)
+		b.ReportAllocs()
+		b.ResetTimer()
+		for n := 0; n < b.N; n++ {
+			MarshalTo(buffer[:0], &v)
+		}
+	})
+
 	encoder := NewEncoder(new(bytes.Buffer))
 	b.Run("marshal-to", func(b *testing.B) {
 		b.ReportAllocs()
@@ -128,6 +140,22 @@ func Benchmark_Binary(b *testing.B) {
 			Unmarshal(enc, &out)
 		}
 	})
+
+	b.Run("encode", func(b *testing.B) {
+		b.ReportAllocs()
+		b.ResetTimer()
+		for n := 0; n < b.N; n++ {
+			Encode(v)
+		}
+	})
+
+	b.Run("decode", func(b *testing.B) {
+		b.ReportAllocs()
+		b.ResetTimer()
+		for n := 0; n < b.N; n++ {
+			Decode[benchStruct](enc)
+		}
+	})
 }
 
 func Benchmark_Gob(b *testing.B) {
@@ -185,7 +213,11 @@ func TestBinaryEncodeStruct(t *testing.T) {
 
 func TestEncoderSizeOf(t *testing.T) {
 	var e Encoder
-	assert.Equal(t, 48, int(unsafe.Sizeof(e)))
+	size := 88
+	if unsafe.Sizeof(uintptr(0)) == 4 {
+		size = 48 // On 32-bit platforms
+	}
+	assert.Equal(t, size, int(unsafe.Sizeof(e)))
 }
 
 func TestMarshalWithCustomCodec(t *testing.T) {
@@ -200,3 +232,261 @@ func TestMarshalWithCustomCodec(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, v, out)
 }
+
+// testOpaque is a type with a codec which does not implement the Sizer interface.
+type testOpaque string
+
+type testOpaqueCodec struct{}
+
+func (c *testOpaqueCodec) EncodeTo(e *Encoder, rv reflect.Value) error {
+	return new(stringCodec).EncodeTo(e, rv)
+}
+
+func (c *testOpaqueCodec) DecodeTo(d *Decoder, rv reflect.Value) error {
+	return new(stringCodec).DecodeTo(d, rv)
+}
+
+// GetBinaryCodec retrieves a custom binary codec.
+func (s *testOpaque) GetBinaryCodec() Codec {
+	return new(testOpaqueCodec)
+}
+
+func TestSize(t *testing.T) {
+	type custom struct {
+		Value  testCustom
+		Opaque []testOpaque
+	}
+
+	tests := []interface{}{
+		s0v,
+		s1v,
+		&testMsg,
+		newComposite(),
+		[]int64{-1, 0, 1, 1 << 40},
+		&[2]float32{1, 2},
+		map[int16]string{1: "a", 2: "bb"},
+		complex(1, 2),
+		&custom{Value: "custom codec", Opaque: []testOpaque{"a", "bc"}},
+		&s2{[]byte{0x13}},
+	}
+
+	for _, v := range tests {
+		b, err := Marshal(v)
+		assert.NoError(t, err)
+
+		n, err := Size(v)
+		assert.NoError(t, err)
+		assert.Equal(t, len(b), n, "%T", v)
+	}
+}
+
+// countedMarshaler counts the number of times it was marshaled
+type countedMarshaler struct {
+	calls *int
+}
+
+func (m countedMarshaler) MarshalBinary() ([]byte, error) {
+	*m.calls++
+	return []byte{byte(*m.calls)}, nil
+}
+
+func (m countedMarshaler) UnmarshalBinary([]byte) error {
+	return nil
+}
+
+func TestSize_Marshaler(t *testing.T) {
+	var calls int
+	v := []countedMarshaler{{&calls}, {&calls}}
+
+	// The marshalers are called once per value, and never to compute a size
+	b, err := Marshal(v)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{2, 1, 1, 1, 2}, b)
+	assert.Equal(t, 2, calls)
+
+	n, err := Size(v)
+	assert.NoError(t, err)
+	assert.Equal(t, 5, n)
+	assert.Equal(t, 4, calls)
+}
+
+func TestSize_Unsupported(t *testing.T) {
+	_, err := Size(make(chan int))
+	assert.Error(t, err)
+}
+
+func TestMarshalTo(t *testing.T) {
+	prefix := []byte{0xff}
+	b, err := MarshalTo(prefix, s0v)
+	assert.NoError(t, err)
+	assert.Equal(t, append([]byte{0xff}, s0b...), b)
+
+	b, err = MarshalTo(prefix, make(chan int))
+	assert.Error(t, err)
+	assert.Equal(t, prefix, b)
+}
+
+func TestMarshalTo_NoAlloc(t *testing.T) {
+	v := testMsg
+	buffer := make([]byte, 0, 1024)
+	MarshalTo(buffer, &v) // Warm up the codec cache and the pools
+
+	allocs := testing.AllocsPerRun(100, func() {
+		MarshalTo(buffer[:0], &v)
+	})
+	assert.Equal(t, float64(0), allocs)
+}
+
+// countingBuffer counts the number of writes issued to a buffer
+type countingBuffer struct {
+	bytes.Buffer
+	writes int
+}
+
+func (w *countingBuffer) Write(p []byte) (int, error) {
+	w.writes++
+	return w.Buffer.Write(p)
+}
+
+func TestEncoder_Buffered(t *testing.T) {
+	var out countingBuffer
+	e := NewEncoderSize(&out, 64)
+	for i := 0; i < 10; i++ {
+		assert.NoError(t, e.Encode(s1v))
+	}
+
+	assert.NotZero(t, e.Buffered())
+	assert.NoError(t, e.Flush())
+	assert.Zero(t, e.Buffered())
+	assert.True(t, out.writes < 20)
+
+	d := NewDecoder(&out.Buffer)
+	for i := 0; i < 10; i++ {
+		v := new(s1)
+		assert.NoError(t, d.Decode(v))
+		assert.Equal(t, s1v, v)
+	}
+}
+
+func TestEncoder_BufferedLargeWrite(t *testing.T) {
+	var out countingBuffer
+	e := NewEncoderSize(&out, 16)
+	payload := bytes.Repeat([]byte{0x1}, 100)
+	assert.NoError(t, e.Encode(&payload))
+	assert.NoError(t, e.Flush())
+	assert.Equal(t, 2, out.writes)
+
+	var v []byte
+	assert.NoError(t, Unmarshal(out.Bytes(), &v))
+	assert.Equal(t, payload, v)
+}
+
+func TestEncoder_WriteTo(t *testing.T) {
+	var out, redirected bytes.Buffer
+	e := NewEncoderSize(&out, 0)
+	v := complex(float32(1), float32(2))
+	assert.NoError(t, e.Encode(&v))
+
+	n, err := e.WriteTo(&redirected)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(8), n)
+	assert.NoError(t, e.Flush())
+	assert.Zero(t, out.Len())
+
+	var o complex64
+	assert.NoError(t, Unmarshal(redirected.Bytes(), &o))
+	assert.Equal(t, v, o)
+}
+
+func TestEncoder_Reset(t *testing.T) {
+	var first, second bytes.Buffer
+	e := NewEncoderSize(&first, 1024, InternStrings())
+	assert.NoError(t, e.Encode(s1v))
+
+	// Unflushed data is discarded, while the options and the buffer are kept
+	e.Reset(&second)
+	assert.Zero(t, e.Buffered())
+	assert.NoError(t, e.Encode(s1v))
+	assert.NoError(t, e.Flush())
+	assert.Zero(t, first.Len())
+
+	expect, err := Marshal(s1v, InternStrings())
+	assert.NoError(t, err)
+	assert.Equal(t, expect, second.Bytes())
+
+	assert.Zero(t, testing.AllocsPerRun(10, func() {
+		e.Reset(io.Discard)
+	}))
+}
+
+// testFailing is a type with a codec which fails half-way through, without returning
+// the error.
+type testFailing struct{}
+
+var errTestFailing = errors.New("failing")
+
+type testFailingCodec struct{}
+
+func (c *testFailingCodec) EncodeTo(e *Encoder, rv reflect.Value) error {
+	e.WriteString("before")
+	e.Fail(errTestFailing)
+	e.Fail(errors.New("ignored"))
+	e.WriteString("after")
+	return nil
+}
+
+func (c *testFailingCodec) DecodeTo(d *Decoder, rv reflect.Value) error {
+	return nil
+}
+
+// GetBinaryCodec retrieves a custom binary codec.
+func (s *testFailing) GetBinaryCodec() Codec {
+	return new(testFailingCodec)
+}
+
+func TestEncoder_Fail(t *testing.T) {
+	var buffer bytes.Buffer
+	e := NewEncoderSize(&buffer, 64, Checksum(), InternStrings())
+	assert.NoError(t, e.Err())
+	assert.Equal(t, errTestFailing, e.Encode(&testFailing{}))
+	assert.Equal(t, errTestFailing, e.Err())
+
+	// The writes after the error are no-ops
+	buffered, crc := e.Buffered(), e.ext.crc
+	e.WriteString("more")
+	e.WriteUint64(1)
+	e.Write([]byte{1, 2, 3})
+	assert.Equal(t, buffered, e.Buffered())
+	assert.Equal(t, crc, e.ext.crc)
+	assert.Equal(t, errTestFailing, e.Encode("value"))
+	assert.Equal(t, errTestFailing, e.Flush())
+	assert.Zero(t, buffer.Len())
+
+	// Reset clears the error
+	e.Reset(&buffer)
+	assert.NoError(t, e.Err())
+	assert.NoError(t, e.Encode("value"))
+
+	_, err := Marshal(&struct{ A testFailing }{})
+	assert.Equal(t, errTestFailing, err)
+}
+
+func TestMarshalPooled(t *testing.T) {
+	b, err := MarshalPooled(s0v)
+	assert.NoError(t, err)
+	assert.Equal(t, s0b, b.Bytes())
+	b.Release()
+
+	_, err = MarshalPooled(make(chan int))
+	assert.Error(t, err)
+	if raceEnabled {
+		return
+	}
+
+	v := testMsg
+	allocs := testing.AllocsPerRun(100, func() {
+		b, _ := MarshalPooled(&v)
+		b.Release()
+	})
+	assert.Equal(t, float64(0), allocs)
+}
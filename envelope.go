@@ -0,0 +1,81 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package binary
+
+import (
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+)
+
+// ErrUnauthenticated is returned when an envelope can not be opened, because it was
+// sealed with a different key or has been tampered with.
+var ErrUnauthenticated = errors.New("binary: message authentication failed")
+
+// The version of the format of the envelopes
+const envelopeVersion byte = 1
+
+// Envelope encrypts encoded values with an authenticated cipher, such as AES-GCM or
+// ChaCha20-Poly1305, so that applications do not need their own framing around Marshal.
+// A sealed value consists of a version header, followed by a random nonce and by the
+// encrypted value, and the version header is authenticated along with the value. An
+// envelope is safe for concurrent use.
+type Envelope struct {
+	aead cipher.AEAD
+	opts []Option
+}
+
+// NewEnvelope creates an envelope which encrypts values with the cipher, created by the
+// caller with its key, and encodes them with the options. For example, with AES-256-GCM:
+//
+//	block, err := aes.NewCipher(key)
+//	aead, err := cipher.NewGCM(block)
+//	envelope := binary.NewEnvelope(aead)
+func NewEnvelope(aead cipher.AEAD, opts ...Option) *Envelope {
+	return &Envelope{aead: aead, opts: opts}
+}
+
+// Seal encodes and encrypts a value.
+func (e *Envelope) Seal(v interface{}) ([]byte, error) {
+	w := appenders.Get().(*appendWriter)
+	defer appenders.Put(w)
+
+	plain, err := MarshalTo((*w)[:0], v, e.opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	// The header and the nonce are written first, then authenticated with the value
+	n := e.aead.NonceSize()
+	out := make([]byte, 1+n, 1+n+len(plain)+e.aead.Overhead())
+	out[0] = envelopeVersion
+	nonce := out[1 : 1+n]
+	if _, err = rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	out = e.aead.Seal(out, nonce, plain, out[:1])
+	clear(plain) // The buffer is reused, so do not leave the value around
+	*w = plain[:0]
+	return out, nil
+}
+
+// Open decrypts and decodes a value sealed by an envelope with the same key. It fails
+// with ErrUnauthenticated if the value was sealed with a different key or was modified.
+func (e *Envelope) Open(b []byte, v interface{}) error {
+	n := e.aead.NonceSize()
+	switch {
+	case len(b) < 1+n+e.aead.Overhead():
+		return errors.New("binary: envelope is too short")
+	case b[0] != envelopeVersion:
+		return errors.New("binary: unsupported envelope version")
+	}
+
+	plain, err := e.aead.Open(nil, b[1:1+n], b[1+n:], b[:1])
+	if err != nil {
+		return ErrUnauthenticated
+	}
+
+	return Unmarshal(plain, v, e.opts...)
+}
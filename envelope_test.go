@@ -0,0 +1,68 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package binary
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestEnvelope creates an envelope using AES-GCM with a key filled with the byte.
+func newTestEnvelope(t *testing.T, key byte, opts ...Option) *Envelope {
+	block, err := aes.NewCipher(bytes.Repeat([]byte{key}, 32))
+	assert.NoError(t, err)
+
+	aead, err := cipher.NewGCM(block)
+	assert.NoError(t, err)
+	return NewEnvelope(aead, opts...)
+}
+
+func TestEnvelope(t *testing.T) {
+	envelope := newTestEnvelope(t, 1, Versioned())
+	v := newProjected()
+
+	b, err := envelope.Seal(v)
+	assert.NoError(t, err)
+	assert.Equal(t, envelopeVersion, b[0])
+
+	plain, err := Marshal(v, Versioned())
+	assert.NoError(t, err)
+	assert.False(t, bytes.Contains(b, []byte("hello")))
+	assert.Equal(t, 1+12+len(plain)+16, len(b))
+
+	out := new(projected)
+	assert.NoError(t, envelope.Open(b, out))
+	assert.Equal(t, v, out)
+
+	// Every value is sealed with a different nonce
+	other, err := envelope.Seal(v)
+	assert.NoError(t, err)
+	assert.NotEqual(t, b, other)
+}
+
+func TestEnvelope_Tampered(t *testing.T) {
+	envelope := newTestEnvelope(t, 1)
+	b, err := envelope.Seal("secret")
+	assert.NoError(t, err)
+
+	var out string
+	for i := range b {
+		tampered := append([]byte(nil), b...)
+		tampered[i] ^= 0x1
+		assert.Error(t, envelope.Open(tampered, &out), "byte %d", i)
+	}
+
+	// The header is authenticated, even if its version is known
+	tampered := append([]byte(nil), b...)
+	tampered[0] = 0x0
+	assert.Error(t, envelope.Open(tampered, &out))
+
+	assert.Equal(t, ErrUnauthenticated, newTestEnvelope(t, 2).Open(b, &out))
+	assert.Error(t, envelope.Open(b[:10], &out))
+	assert.Equal(t, "", out)
+}
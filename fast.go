@@ -0,0 +1,298 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package binary
+
+import (
+	"encoding/binary"
+	"io"
+	"math"
+	"reflect"
+	"slices"
+	"sync/atomic"
+)
+
+// Whether a codec or a validator was registered for a predeclared type, such as int or
+// []byte, in which case the fast paths which bypass the codecs are disabled.
+var overridden atomic.Bool
+
+// overridesBuiltins returns whether a codec or a validator is registered for a type which
+// does not belong to a package, such as int, []byte or map[string]int.
+func overridesBuiltins() (found bool) {
+	builtin := func(k, _ interface{}) bool {
+		found = k.(reflect.Type).PkgPath() == ""
+		return !found
+	}
+
+	if registered.Range(builtin); !found {
+		validators.Range(builtin)
+	}
+	return
+}
+
+// ------------------------------------------------------------------------------
+
+// appendFast appends the encoding of the values of the most common types, and of pointers
+// to them, without going through reflection. It returns false for other types, or when
+// options are set, as they may change the encoding.
+func appendFast(b []byte, v interface{}, opts []Option) ([]byte, bool) {
+	if len(opts) > 0 || overridden.Load() {
+		return b, false
+	}
+
+	switch x := v.(type) {
+	case int:
+		return binary.AppendVarint(b, int64(x)), true
+	case *int:
+		return appendPointer(b, x, func(b []byte, v int) []byte { return binary.AppendVarint(b, int64(v)) })
+	case int64:
+		return binary.AppendVarint(b, x), true
+	case *int64:
+		return appendPointer(b, x, binary.AppendVarint)
+	case int32:
+		return binary.AppendVarint(b, int64(x)), true
+	case *int32:
+		return appendPointer(b, x, func(b []byte, v int32) []byte { return binary.AppendVarint(b, int64(v)) })
+	case uint:
+		return binary.AppendUvarint(b, uint64(x)), true
+	case *uint:
+		return appendPointer(b, x, func(b []byte, v uint) []byte { return binary.AppendUvarint(b, uint64(v)) })
+	case uint64:
+		return binary.AppendUvarint(b, x), true
+	case *uint64:
+		return appendPointer(b, x, binary.AppendUvarint)
+	case uint32:
+		return binary.AppendUvarint(b, uint64(x)), true
+	case *uint32:
+		return appendPointer(b, x, func(b []byte, v uint32) []byte { return binary.AppendUvarint(b, uint64(v)) })
+	case bool:
+		return appendBool(b, x), true
+	case *bool:
+		return appendPointer(b, x, appendBool)
+	case float64:
+		return binary.LittleEndian.AppendUint64(b, math.Float64bits(x)), true
+	case *float64:
+		return appendPointer(b, x, func(b []byte, v float64) []byte {
+			return binary.LittleEndian.AppendUint64(b, math.Float64bits(v))
+		})
+	case string:
+		return appendString(b, x), true
+	case *string:
+		return appendPointer(b, x, appendString)
+	case []byte:
+		return appendBytes(b, x), true
+	case *[]byte:
+		return appendPointer(b, x, appendBytes)
+	case []string:
+		return appendStrings(b, x), true
+	case *[]string:
+		return appendPointer(b, x, appendStrings)
+	case []int:
+		return appendVarintSlice(b, x), true
+	case *[]int:
+		return appendPointer(b, x, appendVarintSlice[int])
+	case []int64:
+		return appendVarintSlice(b, x), true
+	case *[]int64:
+		return appendPointer(b, x, appendVarintSlice[int64])
+	case []uint64:
+		return appendUvarintSlice(b, x), true
+	case *[]uint64:
+		return appendPointer(b, x, appendUvarintSlice[uint64])
+	default:
+		return b, false
+	}
+}
+
+// appendPointer appends the value a pointer points to, unless it is nil.
+func appendPointer[T any](b []byte, p *T, fn func([]byte, T) []byte) ([]byte, bool) {
+	if p == nil {
+		return b, false
+	}
+	return fn(b, *p), true
+}
+
+// appendBool appends a boolean as a single byte.
+func appendBool(b []byte, v bool) []byte {
+	if v {
+		return append(b, 1)
+	}
+	return append(b, 0)
+}
+
+// appendString appends a string prefixed with its length.
+func appendString(b []byte, v string) []byte {
+	b = slices.Grow(b, uvarintSize(uint64(len(v)))+len(v))
+	return append(binary.AppendUvarint(b, uint64(len(v))), v...)
+}
+
+// appendBytes appends a byte slice prefixed with its length.
+func appendBytes(b []byte, v []byte) []byte {
+	b = slices.Grow(b, uvarintSize(uint64(len(v)))+len(v))
+	return append(binary.AppendUvarint(b, uint64(len(v))), v...)
+}
+
+// appendStrings appends a slice of strings prefixed with its length.
+func appendStrings(b []byte, v []string) []byte {
+	size := uvarintSize(uint64(len(v)))
+	for _, s := range v {
+		size += uvarintSize(uint64(len(s))) + len(s)
+	}
+
+	b = binary.AppendUvarint(slices.Grow(b, size), uint64(len(v)))
+	for _, s := range v {
+		b = append(binary.AppendUvarint(b, uint64(len(s))), s...)
+	}
+	return b
+}
+
+// appendVarintSlice appends a slice of signed integers prefixed with its length.
+func appendVarintSlice[T int | int64](b []byte, v []T) []byte {
+	b = slices.Grow(b, uvarintSize(uint64(len(v)))+varintsSize(v))
+	return appendVarints(binary.AppendUvarint(b, uint64(len(v))), v)
+}
+
+// appendUvarintSlice appends a slice of unsigned integers prefixed with its length.
+func appendUvarintSlice[T uint64](b []byte, v []T) []byte {
+	b = slices.Grow(b, uvarintSize(uint64(len(v)))+uvarintsSize(v))
+	return appendUvarints(binary.AppendUvarint(b, uint64(len(v))), v)
+}
+
+// ------------------------------------------------------------------------------
+
+// unmarshalFast decodes the values of the most common types without going through
+// reflection, following the semantics of their codecs. It returns false for other types,
+// or when options are set, as they may change the decoding.
+func unmarshalFast(b []byte, v interface{}, opts []Option) (ok bool, err error) {
+	if len(opts) > 0 || overridden.Load() {
+		return false, nil
+	}
+
+	// Nil pointers are reported by the regular path
+	if rv := reflect.ValueOf(v); rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return false, nil
+	}
+
+	d := decoders.Get().(*Decoder)
+	d.r.(*SliceReader).Reset(b)
+	d.opts.reset(nil)
+	d.depth = 0
+	ok, err = decodeFast(d, v)
+	decoders.Put(d)
+	return
+}
+
+// decodeFast decodes a value of one of the most common types with a decoder reading from
+// a byte slice, or returns false for other types.
+func decodeFast(d *Decoder, v interface{}) (bool, error) {
+	var err error
+	switch p := v.(type) {
+	case *int:
+		err = readInto(d, p, readInt[int])
+	case *int64:
+		err = readInto(d, p, (*Decoder).ReadVarint)
+	case *int32:
+		err = readInto(d, p, readInt[int32])
+	case *uint:
+		err = readInto(d, p, readUint[uint])
+	case *uint64:
+		err = readInto(d, p, (*Decoder).ReadUvarint)
+	case *uint32:
+		err = readInto(d, p, readUint[uint32])
+	case *bool:
+		err = readInto(d, p, (*Decoder).ReadBool)
+	case *float64:
+		err = readInto(d, p, (*Decoder).ReadFloat64)
+	case *string:
+		err = readInto(d, p, (*Decoder).ReadString)
+	case *[]byte:
+		err = d.readBytesInto(p)
+	case *[]string:
+		err = readSlice(d, p, (*Decoder).ReadString)
+	case *[]int:
+		err = readSlice(d, p, readInt[int])
+	case *[]int64:
+		err = readSlice(d, p, (*Decoder).ReadVarint)
+	case *[]uint64:
+		err = readSlice(d, p, (*Decoder).ReadUvarint)
+	default:
+		return false, nil
+	}
+	return true, err
+}
+
+// readInto reads a value into the destination, which is left unchanged on error.
+func readInto[T any](d *Decoder, dst *T, read func(*Decoder) (T, error)) error {
+	v, err := read(d)
+	if err == nil {
+		*dst = v
+	}
+	return err
+}
+
+// readInt reads a signed integer which must fit into its type.
+func readInt[T int | int32](d *Decoder) (T, error) {
+	v, err := d.ReadVarint()
+	if err == nil && int64(T(v)) != v {
+		err = IntOverflowError(v, reflect.TypeFor[T]().String())
+	}
+	return T(v), err
+}
+
+// readUint reads an unsigned integer which must fit into its type.
+func readUint[T uint | uint32](d *Decoder) (T, error) {
+	v, err := d.ReadUvarint()
+	if err == nil && uint64(T(v)) != v {
+		err = UintOverflowError(v, reflect.TypeFor[T]().String())
+	}
+	return T(v), err
+}
+
+// readBytesInto reads a byte slice into the destination, reusing its backing array if it
+// has the capacity for the bytes.
+func (d *Decoder) readBytesInto(dst *[]byte) error {
+	l, err := d.readSliceLen()
+	switch {
+	case err != nil:
+		return err
+	case l <= cap(*dst):
+		*dst = (*dst)[:l]
+		_, err = d.Read(*dst)
+		return err
+	}
+
+	b, err := d.readCopy(l)
+	if err == nil {
+		*dst = b
+	}
+	return err
+}
+
+// readSlice reads a slice into the destination, reusing its backing array if it has the
+// capacity for the elements. Every element takes at least a byte, so a length which the
+// remaining input can not hold is rejected before allocating.
+func readSlice[T any](d *Decoder, dst *[]T, read func(*Decoder) (T, error)) error {
+	l, err := d.readSliceLen()
+	switch {
+	case err != nil:
+		return err
+	case l > cap(*dst) && l > d.s.Len():
+		return io.EOF
+	}
+
+	out := (*dst)[:0]
+	if l > cap(out) {
+		out = make([]T, 0, l)
+	}
+
+	for i := 0; i < l; i++ {
+		v, err := read(d)
+		if err != nil {
+			return err
+		}
+		out = append(out, v)
+	}
+
+	*dst = out
+	return nil
+}
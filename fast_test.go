@@ -0,0 +1,109 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package binary
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// The values of the types which have a fast path
+var fastValues = []interface{}{
+	0, -1, math.MaxInt, int64(math.MinInt64), int32(-300), uint(300), uint64(math.MaxUint64), uint32(7),
+	true, false, 3.14, math.Inf(-1), "", "hello", []byte(nil), []byte("bytes"),
+	[]string{"a", "", "bc"}, []int{-1, 0, math.MinInt}, []int64{5, -5, 1 << 40}, []uint64{0, 1 << 63}, []string(nil),
+}
+
+func TestFastPath(t *testing.T) {
+	for _, v := range fastValues {
+		expect, err := marshal(reflect.ValueOf(v), nil)
+		assert.NoError(t, err)
+
+		// The encoding is the same as the one of the codecs, for values and pointers
+		out, ok := appendFast(nil, v, nil)
+		assert.True(t, ok, "%T", v)
+		assert.Equal(t, expect, out, "%T", v)
+
+		ptr := reflect.New(reflect.TypeOf(v))
+		ptr.Elem().Set(reflect.ValueOf(v))
+		out, ok = appendFast([]byte{0xff}, ptr.Interface(), nil)
+		assert.True(t, ok, "%T", v)
+		assert.Equal(t, append([]byte{0xff}, expect...), out)
+
+		// The decoding is the same as the one of the codecs
+		fast := reflect.New(reflect.TypeOf(v))
+		ok, err = unmarshalFast(expect, fast.Interface(), nil)
+		assert.True(t, ok)
+		assert.NoError(t, err)
+
+		slow := reflect.New(reflect.TypeOf(v))
+		assert.NoError(t, unmarshal(expect, slow.Elem(), nil))
+		assert.Equal(t, slow.Interface(), fast.Interface())
+	}
+}
+
+func TestFastPath_Errors(t *testing.T) {
+	b, err := Marshal(int64(math.MaxInt64))
+	assert.NoError(t, err)
+
+	var small int32
+	assert.True(t, errors.Is(Unmarshal(b, &small), ErrOverflow))
+	assert.Equal(t, int32(0), small)
+
+	var list []int
+	assert.Error(t, Unmarshal([]byte{100, 1}, &list))
+	assert.Error(t, Unmarshal(b, (*int)(nil)))
+
+	// Slices with enough capacity are reused
+	list = make([]int, 0, 8)
+	first := &list[:1][0]
+	b, err = Marshal([]int{1, 2, 3})
+	assert.NoError(t, err)
+	assert.NoError(t, Unmarshal(b, &list))
+	assert.Equal(t, []int{1, 2, 3}, list)
+	assert.True(t, first == &list[0])
+}
+
+func TestFastPath_Overridden(t *testing.T) {
+	RegisterValidator(func(v int) error {
+		if v < 0 {
+			return errors.New("negative")
+		}
+		return nil
+	})
+
+	b, err := Marshal(-1)
+	assert.NoError(t, err)
+
+	var out int
+	assert.Error(t, Unmarshal(b, &out))
+
+	RegisterValidator[int](nil)
+	assert.NoError(t, Unmarshal(b, &out))
+	assert.Equal(t, -1, out)
+}
+
+func BenchmarkFastPath(b *testing.B) {
+	b.Run("int", func(b *testing.B) {
+		b.ReportAllocs()
+		var out int
+		for n := 0; n < b.N; n++ {
+			enc, _ := Marshal(n)
+			_ = Unmarshal(enc, &out)
+		}
+	})
+
+	b.Run("string", func(b *testing.B) {
+		b.ReportAllocs()
+		var out string
+		for n := 0; n < b.N; n++ {
+			enc, _ := Marshal("hello world")
+			_ = Unmarshal(enc, &out)
+		}
+	})
+}
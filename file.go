@@ -0,0 +1,129 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package binary
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// ErrFileVersion is returned by LoadFile when the version of a file does not match the
+// one of the FileVersion option, or when the file has no version header.
+var ErrFileVersion = errors.New("binary: file version mismatch")
+
+// The magic bytes which start the header of a versioned file
+var fileMagic = [4]byte{'B', 'I', 'N', 0}
+
+// FileVersion writes a header holding the magic bytes of this package and a version in
+// the files saved by SaveFile, and checks it in LoadFile, which fails with ErrFileVersion
+// if the version of the file does not match. This tells apart the snapshots of an
+// application state saved with an older layout, so that they can be migrated.
+func FileVersion(version uint64) Option {
+	return func(o *options) {
+		o.fileVersion = version
+		o.fileVersioned = true
+	}
+}
+
+// SaveFile encodes a value into a file atomically, so that a crash while saving leaves
+// either the previous file or the new one, never a truncated file. The value is encoded
+// into a temporary file in the same directory, which is synced to stable storage and then
+// renamed over the file. An existing file keeps its permissions, while new files are only
+// readable and writable by their owner. The Checksum option detects files which were
+// corrupted afterwards, and the FileVersion option writes a version header.
+func SaveFile(path string, v interface{}, opts ...Option) (err error) {
+	var o options
+	o.reset(opts)
+
+	dir, name := filepath.Split(path)
+	f, err := os.CreateTemp(dir, "."+name+".*.tmp")
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err != nil {
+			f.Close()
+			os.Remove(f.Name())
+		}
+	}()
+
+	if info, err := os.Stat(path); err == nil {
+		if err = f.Chmod(info.Mode().Perm()); err != nil {
+			return err
+		}
+	}
+
+	e := NewEncoderSize(f, defaultBufferSize, opts...)
+	if o.fileVersioned {
+		e.Write(fileMagic[:])
+		e.WriteUvarint(o.fileVersion)
+	}
+	if err = e.Encode(v); err != nil {
+		return err
+	}
+	if err = e.Flush(); err != nil {
+		return err
+	}
+	if err = f.Sync(); err != nil {
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	if err = os.Rename(f.Name(), path); err != nil {
+		return err
+	}
+
+	syncDir(filepath.Dir(path))
+	return nil
+}
+
+// syncDir commits the entries of a directory to stable storage, so that a renamed file
+// survives a crash of the machine. This is not supported on every platform, where the
+// rename is durable on its own, so errors are ignored.
+func syncDir(dir string) {
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+}
+
+// LoadFile decodes a value from a file saved by SaveFile. The options must match the ones
+// used to save it.
+func LoadFile(path string, v interface{}, opts ...Option) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var o options
+	if o.reset(opts); o.fileVersioned {
+		if b, err = readFileHeader(b, o.fileVersion); err != nil {
+			return err
+		}
+	}
+
+	return Unmarshal(b, v, opts...)
+}
+
+// readFileHeader checks the version header of a file, and returns the bytes which follow.
+func readFileHeader(b []byte, version uint64) ([]byte, error) {
+	if len(b) < len(fileMagic) || [4]byte(b[:4]) != fileMagic {
+		return nil, fmt.Errorf("%w: expected version %d, the file has no version header", ErrFileVersion, version)
+	}
+
+	r := NewSliceReader(b[len(fileMagic):])
+	actual, err := r.readUvarint()
+	switch {
+	case err != nil:
+		return nil, err
+	case actual != version:
+		return nil, fmt.Errorf("%w: expected version %d, the file has version %d", ErrFileVersion, version, actual)
+	default:
+		return b[len(b)-r.Len():], nil
+	}
+}
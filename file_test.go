@@ -0,0 +1,97 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package binary
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fileState struct {
+	Name    string
+	Counter uint64
+	Tags    map[string]string
+}
+
+func TestFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.bin")
+	for _, opts := range [][]Option{
+		nil,
+		{Checksum()},
+		{FileVersion(3), Checksum()},
+		{FileVersion(0), SelfDescribing()},
+	} {
+		v := fileState{Name: "state", Counter: 42, Tags: map[string]string{"a": "b"}}
+		assert.NoError(t, SaveFile(path, &v, opts...))
+
+		var out fileState
+		assert.NoError(t, LoadFile(path, &out, opts...))
+		assert.Equal(t, v, out)
+	}
+
+	// No temporary files are left around
+	entries, err := os.ReadDir(filepath.Dir(path))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(entries))
+}
+
+func TestFile_Version(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.bin")
+	assert.NoError(t, SaveFile(path, &fileState{Name: "state"}, FileVersion(1)))
+
+	b, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{'B', 'I', 'N', 0, 1, 5}, b[:6])
+
+	var out fileState
+	err = LoadFile(path, &out, FileVersion(2))
+	assert.True(t, errors.Is(err, ErrFileVersion))
+
+	// Files saved without a version have no header
+	assert.NoError(t, SaveFile(path, &fileState{Name: "state"}))
+	err = LoadFile(path, &out, FileVersion(1))
+	assert.True(t, errors.Is(err, ErrFileVersion))
+}
+
+func TestFile_Permissions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.bin")
+	assert.NoError(t, os.WriteFile(path, nil, 0640))
+	assert.NoError(t, SaveFile(path, &fileState{}))
+
+	info, err := os.Stat(path)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0640), info.Mode().Perm())
+}
+
+func TestFile_Invalid(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "state.bin")
+	assert.NoError(t, SaveFile(path, &fileState{Name: "previous"}, Checksum()))
+
+	// A value which fails to encode leaves the previous file
+	assert.Error(t, SaveFile(path, &struct{ C chan int }{}))
+
+	var out fileState
+	assert.NoError(t, LoadFile(path, &out, Checksum()))
+	assert.Equal(t, "previous", out.Name)
+
+	entries, err := os.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(entries))
+
+	// A corrupted file fails to load
+	b, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	b[1] ^= 0xff
+	assert.NoError(t, os.WriteFile(path, b, 0600))
+	assert.True(t, errors.Is(LoadFile(path, &out, Checksum()), ErrChecksum))
+
+	// Missing files and directories fail
+	assert.True(t, errors.Is(LoadFile(filepath.Join(dir, "missing"), &out), os.ErrNotExist))
+	assert.Error(t, SaveFile(filepath.Join(dir, "missing", "state.bin"), &out))
+}
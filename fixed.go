@@ -0,0 +1,184 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package binary
+
+import (
+	"errors"
+	"reflect"
+	"strconv"
+)
+
+// scanFixed returns a codec which encodes integers as fixed-width values instead of
+// variable-size integers, which is selected with a `binary:",fixed"` tag. Slices and
+// arrays of integers are supported as well, while floating-point numbers are always
+// encoded with a fixed width. The width is the size of the type, unless it is set with
+// a `binary:",fixed32"` or `binary:",fixed64"` tag, like the fixed types of protobuf.
+func scanFixed(t reflect.Type, size int) (Codec, error) {
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return &fixedIntCodec{size: fixedWidth(t, size)}, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return &fixedUintCodec{size: fixedWidth(t, size)}, nil
+	case reflect.Float32, reflect.Float64, reflect.Complex64, reflect.Complex128:
+		if size == 0 {
+			return scanCodec(t)
+		}
+
+	case reflect.Slice:
+		elemCodec, err := scanFixed(t.Elem(), size)
+		if err != nil {
+			return nil, nestedError(err, "[]")
+		}
+
+		return sliceCodecOf(t, elemCodec), nil
+
+	case reflect.Array:
+		elemCodec, err := scanFixed(t.Elem(), size)
+		if err != nil {
+			return nil, nestedError(err, "[]")
+		}
+
+		return &reflectArrayCodec{
+			elemCodec: elemCodec,
+		}, nil
+	}
+
+	option := "fixed"
+	if size > 0 {
+		option += strconv.Itoa(size * 8)
+	}
+	return nil, errors.New("binary: " + option + " encoding is not supported for " + t.String())
+}
+
+// fixedWidth returns the number of bytes of a fixed-width integer, which is the size of
+// its type unless it was set explicitly. Integers whose size depends on the platform, such
+// as int, always take 8 bytes so that the payloads are the same on every platform.
+func fixedWidth(t reflect.Type, size int) int {
+	switch {
+	case size > 0:
+		return size
+	case t.Kind() == reflect.Int, t.Kind() == reflect.Uint, t.Kind() == reflect.Uintptr:
+		return 8
+	default:
+		return int(t.Size())
+	}
+}
+
+// scanVarint returns a codec which encodes integers as variable-size integers, either
+// with zigzag, which is the default for signed integers and is selected explicitly with a
+// `binary:",zigzag"` tag, or as the two's complement of signed integers with a
+// `binary:",varint"` tag, like the int32 and int64 types of protobuf. Slices and arrays
+// of integers are supported as well.
+func scanVarint(t reflect.Type, zigzag bool) (Codec, error) {
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if zigzag {
+			return new(varintCodec), nil
+		}
+		return new(rawVarintCodec), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if !zigzag {
+			return new(varuintCodec), nil
+		}
+
+	case reflect.Slice:
+		elemCodec, err := scanVarint(t.Elem(), zigzag)
+		if err != nil {
+			return nil, nestedError(err, "[]")
+		}
+
+		return sliceCodecOf(t, elemCodec), nil
+
+	case reflect.Array:
+		elemCodec, err := scanVarint(t.Elem(), zigzag)
+		if err != nil {
+			return nil, nestedError(err, "[]")
+		}
+
+		return &reflectArrayCodec{
+			elemCodec: elemCodec,
+		}, nil
+	}
+
+	option := "varint"
+	if zigzag {
+		option = "zigzag"
+	}
+	return nil, errors.New("binary: " + option + " encoding is not supported for " + t.String())
+}
+
+// ------------------------------------------------------------------------------
+
+type fixedIntCodec struct {
+	size int // The size of the integer, in bytes
+}
+
+// Encode encodes a value into the encoder.
+func (c *fixedIntCodec) EncodeTo(e *Encoder, rv reflect.Value) error {
+	v := rv.Int()
+	if shift := uint(64 - 8*c.size); v<<shift>>shift != v {
+		return IntOverflowError(v, "int"+strconv.Itoa(8*c.size))
+	}
+
+	e.writeFixed(uint64(v), c.size)
+	return nil
+}
+
+// Decode decodes into a reflect value from the decoder.
+func (c *fixedIntCodec) DecodeTo(d *Decoder, rv reflect.Value) (err error) {
+	var u uint64
+	if u, err = d.readFixed(c.size); err != nil {
+		return
+	}
+
+	shift := uint(64 - 8*c.size) // Sign-extend the value
+	v := int64(u<<shift) >> shift
+	if rv.OverflowInt(v) {
+		return IntOverflowError(v, rv.Type().String())
+	}
+
+	rv.SetInt(v)
+	return
+}
+
+// Size returns the encoded size of the value.
+func (c *fixedIntCodec) Size(rv reflect.Value) int {
+	return c.size
+}
+
+// ------------------------------------------------------------------------------
+
+type fixedUintCodec struct {
+	size int // The size of the integer, in bytes
+}
+
+// Encode encodes a value into the encoder.
+func (c *fixedUintCodec) EncodeTo(e *Encoder, rv reflect.Value) error {
+	v := rv.Uint()
+	if c.size < 8 && v>>(8*c.size) != 0 {
+		return UintOverflowError(v, "uint"+strconv.Itoa(8*c.size))
+	}
+
+	e.writeFixed(v, c.size)
+	return nil
+}
+
+// Decode decodes into a reflect value from the decoder.
+func (c *fixedUintCodec) DecodeTo(d *Decoder, rv reflect.Value) (err error) {
+	var v uint64
+	if v, err = d.readFixed(c.size); err != nil {
+		return
+	}
+	if rv.OverflowUint(v) {
+		return UintOverflowError(v, rv.Type().String())
+	}
+
+	rv.SetUint(v)
+	return
+}
+
+// Size returns the encoded size of the value.
+func (c *fixedUintCodec) Size(rv reflect.Value) int {
+	return c.size
+}
@@ -0,0 +1,142 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package binary
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFixed(t *testing.T) {
+	type T struct {
+		A int8     `binary:",fixed"`
+		B int16    `binary:",fixed"`
+		C uint32   `binary:",fixed"`
+		D int      `binary:",fixed"` // 8 bytes on every platform
+		E []uint16 `binary:",fixed"`
+		F [2]int32 `binary:",fixed"`
+		G float64  `binary:",fixed"`
+	}
+
+	v := T{A: -1, B: -2, C: 3, D: -4, E: []uint16{5, 6}, F: [2]int32{-7, 8}, G: 1.5}
+	b, err := Marshal(&v)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{
+		0xff,
+		0xfe, 0xff,
+		0x3, 0x0, 0x0, 0x0,
+		0xfc, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff,
+		0x2, 0x5, 0x0, 0x6, 0x0,
+		0xf9, 0xff, 0xff, 0xff, 0x8, 0x0, 0x0, 0x0,
+		0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0xf8, 0x3f,
+	}, b)
+
+	n, err := Size(&v)
+	assert.NoError(t, err)
+	assert.Equal(t, len(b), n)
+
+	var out T
+	assert.NoError(t, Unmarshal(b, &out))
+	assert.Equal(t, v, out)
+}
+
+func TestFixed_Unsupported(t *testing.T) {
+	type T struct {
+		A string `binary:",fixed"`
+	}
+
+	_, err := Marshal(&T{})
+	assert.Error(t, err)
+}
+
+func TestFixedWidth(t *testing.T) {
+	type T struct {
+		A int     `binary:",fixed32"`
+		B uint8   `binary:",fixed64"`
+		C []int64 `binary:",fixed32"`
+		D int32   `binary:",fixed64"`
+		E [1]uint `binary:",fixed32"`
+	}
+
+	v := T{A: -1, B: 2, C: []int64{3}, D: -4, E: [1]uint{5}}
+	b, err := Marshal(&v)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{
+		0xff, 0xff, 0xff, 0xff,
+		0x2, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0,
+		0x1, 0x3, 0x0, 0x0, 0x0,
+		0xfc, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff,
+		0x5, 0x0, 0x0, 0x0,
+	}, b)
+
+	n, err := Size(&v)
+	assert.NoError(t, err)
+	assert.Equal(t, len(b), n)
+
+	var out T
+	assert.NoError(t, Unmarshal(b, &out))
+	assert.Equal(t, v, out)
+
+	// Values which do not fit fail to encode, or to decode into smaller types
+	_, err = Marshal(&T{C: []int64{1 << 40}})
+	assert.True(t, errors.Is(err, ErrOverflow))
+	_, err = Marshal(&struct {
+		E [1]uint64 `binary:",fixed32"`
+	}{[1]uint64{1 << 32}})
+	assert.True(t, errors.Is(err, ErrOverflow))
+
+	b[5] = 0xff
+	assert.True(t, errors.Is(Unmarshal(b, &out), ErrOverflow))
+
+	_, err = Marshal(&struct {
+		F float64 `binary:",fixed32"`
+	}{})
+	assert.Error(t, err)
+}
+
+func TestVarintStrategy(t *testing.T) {
+	type T struct {
+		A int64   `binary:",varint"`
+		B int32   `binary:",zigzag"`
+		C []int16 `binary:",varint"`
+		D uint    `binary:",varint"`
+	}
+
+	v := T{A: -1, B: -1, C: []int16{-2, 3}, D: 300}
+	b, err := Marshal(&v)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{
+		0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x1,
+		0x1,
+		0x2, 0xfe, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x1, 0x3,
+		0xac, 0x2,
+	}, b)
+
+	n, err := Size(&v)
+	assert.NoError(t, err)
+	assert.Equal(t, len(b), n)
+
+	var out T
+	assert.NoError(t, Unmarshal(b, &out))
+	assert.Equal(t, v, out)
+
+	// Values which do not fit fail to decode
+	var small struct {
+		A int8 `binary:",varint"`
+	}
+	assert.True(t, errors.Is(Unmarshal([]byte{0xac, 0x2}, &small), ErrOverflow))
+
+	_, err = Marshal(&struct {
+		U uint `binary:",zigzag"`
+	}{})
+	assert.Error(t, err)
+
+	b, err = Marshal(&v, SelfDescribing())
+	assert.NoError(t, err)
+	decoded, err := DecodeAny(b, SelfDescribing())
+	assert.NoError(t, err)
+	assert.Equal(t, int64(-1), decoded.(map[string]interface{})["A"])
+}
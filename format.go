@@ -0,0 +1,650 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package binary
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding"
+	"errors"
+	"reflect"
+	"strconv"
+	"time"
+	"unicode/utf8"
+)
+
+// formatWriter represents a self-describing wire format, such as MessagePack, into which
+// values can be encoded by traversing the codecs scanned for the native format. This way
+// the struct tags and the options are honored in the same way by every format.
+type formatWriter interface {
+	writeNil()
+	writeBool(v bool)
+	writeInt(v int64)
+	writeUint(v uint64)
+	writeFloat32(v float32)
+	writeFloat64(v float64)
+	writeString(v string)
+	writeBytes(v []byte)
+	writeTime(v time.Time)
+	writeArray(n int) // Followed by n values
+	writeMap(n int)   // Followed by n keys, each followed by its value
+}
+
+// formatEncoder encodes values into a self-describing format, according to their codecs.
+type formatEncoder struct {
+	w    formatWriter // The format to write to
+	opts options      // The encoding options
+}
+
+// marshalFormat encodes a value into a self-describing format.
+func marshalFormat(w formatWriter, v interface{}, opts []Option) error {
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	c, err := scan(rv.Type())
+	if err != nil {
+		return err
+	}
+
+	e := formatEncoder{w: w}
+	e.opts.reset(opts)
+	return e.encode(c, rv)
+}
+
+// encode encodes a value according to its codec. Structs are encoded as maps of their
+// field names, and the compact encodings of slices selected by tags as plain arrays.
+func (e *formatEncoder) encode(c Codec, rv reflect.Value) error {
+	switch codec := c.(type) {
+	case *boolCodec, *varintCodec, *varuintCodec, *rawVarintCodec, *fixedIntCodec, *fixedUintCodec,
+		*float32Codec, *float64Codec, *stringCodec, *rawStringCodec:
+		return e.scalar(rv)
+	case *complex64Codec, *complex128Codec:
+		e.w.writeArray(2)
+		e.w.writeFloat64(real(rv.Complex()))
+		e.w.writeFloat64(imag(rv.Complex()))
+	case *byteSliceCodec:
+		e.w.writeBytes(rv.Bytes())
+	case *ipCodec:
+		e.w.writeBytes(ipBytes(rv))
+	case *byteArrayCodec:
+		b := make([]byte, rv.Len())
+		reflect.Copy(reflect.ValueOf(b), rv)
+		e.w.writeBytes(b)
+	case *binaryMarshalerCodec:
+		b, err := codec.marshaler(rv).MarshalBinary()
+		if err != nil {
+			return err
+		}
+		e.w.writeBytes(b)
+	case *textMarshalerCodec:
+		text, err := codec.marshaler(rv).MarshalText()
+		if err != nil {
+			return err
+		}
+		e.w.writeString(string(text))
+	case *sqlCodec:
+		v, err := codec.valuer(rv).Value()
+		if err != nil {
+			return err
+		}
+		return e.sqlValue(v)
+	case *netipCodec:
+		b, err := addrOf(rv).(encoding.BinaryMarshaler).MarshalBinary()
+		if err != nil {
+			return err
+		}
+		e.w.writeBytes(b)
+	case *bigIntCodec, *bigRatCodec, *bigFloatCodec:
+		text, err := addrOf(rv).(encoding.TextMarshaler).MarshalText()
+		if err != nil {
+			return err
+		}
+		e.w.writeString(string(text))
+	case *timeCodec:
+		e.w.writeTime(timeOf(rv))
+
+	case *boolSliceCodec, *varintSliceCodec, *varuintSliceCodec, *deltaCodec, *vbyteCodec, *bitsCodec, *gorillaCodec:
+		e.w.writeArray(rv.Len())
+		for i := 0; i < rv.Len(); i++ {
+			if err := e.scalar(rv.Index(i)); err != nil {
+				return err
+			}
+		}
+	case *rleCodec:
+		return e.elements(codec.elemCodec, rv)
+	case *reflectSliceCodec:
+		return e.elements(codec.elemCodec, rv)
+	case *reflectArrayCodec:
+		return e.elements(codec.elemCodec, rv)
+	case *plainSliceCodec:
+		return e.encode(codec.fallback, rv)
+	case *stringSliceCodec:
+		return e.encode(codec.fallback, rv)
+	case *bytesSliceCodec:
+		return e.encode(codec.fallback, rv)
+	case *stringMapCodec:
+		return e.encode(codec.fallback, rv)
+	case *anyMapCodec:
+		return e.encode(codec.fallback, rv)
+	case *packedCodec:
+		return e.encode(codec.fallback, rv)
+	case *columnarCodec:
+		return e.encode(codec.fallback, rv)
+	case *hookedCodec:
+		prepared, err := codec.prepare(rv)
+		if err != nil {
+			return err
+		}
+		return e.encode(codec.Codec, prepared)
+	case *generatedCodec:
+		if codec.fallback == nil {
+			return errors.New("binary: unable to encode " + rv.Type().String() + ", which only has generated code, into this format")
+		}
+		return e.encode(codec.fallback, rv)
+
+	case *reflectPointerCodec:
+		if rv.IsNil() {
+			e.w.writeNil()
+			return nil
+		}
+
+		elemCodec, err := codec.codec()
+		if err != nil {
+			return err
+		}
+		return e.encode(elemCodec, rv.Elem())
+
+	case *nullCodec:
+		if !rv.Field(1).Bool() {
+			e.w.writeNil()
+			return nil
+		}
+
+		elemCodec, err := codec.codec()
+		if err != nil {
+			return err
+		}
+		return e.encode(elemCodec, rv.Field(0))
+
+	case *interfaceCodec:
+		if rv.IsNil() {
+			e.w.writeNil()
+			return nil
+		}
+
+		elemCodec, err := scan(rv.Elem().Type())
+		if err != nil {
+			return err
+		}
+		return e.encode(elemCodec, rv.Elem())
+
+	case *reflectMapCodec:
+		if rv.IsNil() {
+			e.w.writeNil()
+			return nil
+		}
+
+		keys := rv.MapKeys()
+		if e.opts.sortKeys {
+			if err := sortKeys(keys); err != nil {
+				return err
+			}
+		}
+
+		e.w.writeMap(len(keys))
+		for _, key := range keys {
+			if err := e.encode(codec.key, key); err != nil {
+				return err
+			}
+			if err := e.encode(codec.val, rv.MapIndex(key)); err != nil {
+				return err
+			}
+		}
+
+	case *reflectStructCodec:
+		return e.fields(codec, rv)
+	default:
+		return errors.New("binary: unable to encode " + rv.Type().String() + ", which is encoded by a custom codec, into this format")
+	}
+	return nil
+}
+
+// scalar encodes a boolean, a number or a string according to its kind.
+func (e *formatEncoder) scalar(rv reflect.Value) error {
+	switch rv.Kind() {
+	case reflect.Bool:
+		e.w.writeBool(rv.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		e.w.writeInt(rv.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		e.w.writeUint(rv.Uint())
+	case reflect.Float32:
+		e.w.writeFloat32(float32(rv.Float()))
+	case reflect.Float64:
+		e.w.writeFloat64(rv.Float())
+	case reflect.String:
+		e.w.writeString(rv.String())
+	default:
+		return errors.New("binary: unable to encode " + rv.Type().String() + " into this format")
+	}
+	return nil
+}
+
+// elements encodes the elements of a slice or an array as an array.
+func (e *formatEncoder) elements(elemCodec Codec, rv reflect.Value) error {
+	e.w.writeArray(rv.Len())
+	for i := 0; i < rv.Len(); i++ {
+		if err := e.encode(elemCodec, rv.Index(i)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fields encodes the fields of a struct as a map of their names, which honors the
+// options for unexported fields and json tags. The empty fields tagged with omitempty
+// are omitted.
+func (e *formatEncoder) fields(c *reflectStructCodec, rv reflect.Value) error {
+	if e.opts.unexported == UnexportedInclude && !rv.CanAddr() && c.hasUnexported() {
+		ptr := reflect.New(rv.Type()) // Unexported fields can only be accessed by address
+		ptr.Elem().Set(rv)
+		rv = ptr.Elem()
+	}
+
+	values := make([]reflect.Value, len(*c))
+	count := 0
+	for i, f := range *c {
+		v, ok, err := f.access(rv, &e.opts)
+		switch {
+		case err != nil:
+			return err
+		case ok && !(f.OmitEmpty && v.IsZero()):
+			values[i] = v
+			count++
+		}
+	}
+
+	e.w.writeMap(count)
+	for i, f := range *c {
+		if values[i].IsValid() {
+			e.w.writeString(f.name(&e.opts))
+			if err := e.encode(f.Codec, values[i]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// ------------------------------------------------------------------------------
+
+// The maximum nesting depth of the values decoded from a self-describing format, unless
+// the MaxDepth option is set
+const maxFormatDepth = 10000
+
+// formatReader represents a self-describing wire format, such as CBOR, from which values
+// can be decoded by traversing the codecs scanned for the native format.
+type formatReader interface {
+	readNil() bool // Consumes a nil value and returns true, if it is the next value
+	readBool() (bool, error)
+	readInt() (int64, error)
+	readUint() (uint64, error)
+	readFloat() (float64, error)
+	readString() ([]byte, error)
+	readBytes() ([]byte, error)
+	readTime() (time.Time, error)
+	readArray() (int, error) // Followed by n values
+	readMap() (int, error)   // Followed by n keys, each followed by its value
+	skip(depth int) error
+	remaining() int
+}
+
+// formatDecoder decodes values from a self-describing format, according to their codecs.
+type formatDecoder struct {
+	r     formatReader // The format to read from
+	opts  options      // The decoding options
+	depth int          // The current nesting depth
+	spent int          // The number of bytes allocated, charged against the budget
+}
+
+// unmarshalFormat decodes a value from a self-describing format, which must contain only
+// that value.
+func unmarshalFormat(r formatReader, v interface{}, opts []Option) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return errors.New("binary: can only Decode to pointer type")
+	}
+
+	c, err := scan(rv.Type().Elem())
+	if err != nil {
+		return err
+	}
+
+	d := formatDecoder{r: r}
+	d.opts.reset(opts)
+	if err = d.decode(c, rv.Elem()); err == nil && r.remaining() > 0 {
+		err = errors.New("binary: unexpected data after the value")
+	}
+	return err
+}
+
+// decode decodes a value according to its codec, in the same way as it is encoded.
+// The fields of structs which are missing are left untouched, and the unknown ones are
+// skipped.
+func (d *formatDecoder) decode(c Codec, rv reflect.Value) (err error) {
+	d.depth++
+	defer func() { d.depth-- }()
+	if max := d.maxDepth(); d.depth > max {
+		return limitError("depth", uint64(d.depth), max)
+	}
+
+	switch codec := c.(type) {
+	case *boolCodec, *varintCodec, *varuintCodec, *rawVarintCodec, *fixedIntCodec, *fixedUintCodec,
+		*float32Codec, *float64Codec, *stringCodec, *rawStringCodec:
+		return d.scalar(rv)
+	case *complex64Codec, *complex128Codec:
+		var n int
+		var re, im float64
+		if n, err = d.r.readArray(); err == nil && n != 2 {
+			err = errors.New("binary: expected 2 parts for " + rv.Type().String())
+		}
+		if err == nil {
+			if re, err = d.r.readFloat(); err == nil {
+				im, err = d.r.readFloat()
+			}
+		}
+		rv.SetComplex(complex(re, im))
+	case *byteSliceCodec, *ipCodec:
+		var b []byte
+		if b, err = d.bytes(); err == nil {
+			rv.SetBytes(b)
+		}
+	case *byteArrayCodec:
+		var b []byte
+		if b, err = d.r.readBytes(); err == nil && len(b) != rv.Len() {
+			err = errors.New("binary: expected " + strconv.Itoa(rv.Len()) + " bytes for " + rv.Type().String())
+		}
+		reflect.Copy(rv, reflect.ValueOf(b))
+	case *binaryMarshalerCodec:
+		var b []byte
+		if b, err = d.bytes(); err == nil {
+			err = codec.unmarshaler(rv).UnmarshalBinary(b)
+		}
+	case *textMarshalerCodec:
+		var text []byte
+		if text, err = d.string(); err == nil {
+			err = codec.unmarshaler(rv).UnmarshalText(text)
+		}
+	case *sqlCodec:
+		var v driver.Value
+		if v, err = d.sqlValue(); err == nil {
+			err = rv.Addr().Interface().(sql.Scanner).Scan(v)
+		}
+	case *netipCodec:
+		var b []byte
+		if b, err = d.bytes(); err == nil {
+			err = rv.Addr().Interface().(encoding.BinaryUnmarshaler).UnmarshalBinary(b)
+		}
+	case *bigIntCodec, *bigRatCodec, *bigFloatCodec:
+		var text []byte
+		if text, err = d.string(); err == nil {
+			err = rv.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText(text)
+		}
+	case *timeCodec:
+		var t time.Time
+		if t, err = d.r.readTime(); err == nil {
+			setTime(rv, t)
+		}
+
+	case *boolSliceCodec, *varintSliceCodec, *varuintSliceCodec, *deltaCodec, *vbyteCodec, *bitsCodec, *gorillaCodec:
+		return d.elements(nil, rv)
+	case *rleCodec:
+		return d.elements(codec.elemCodec, rv)
+	case *reflectSliceCodec:
+		return d.elements(codec.elemCodec, rv)
+	case *reflectArrayCodec:
+		return d.elements(codec.elemCodec, rv)
+	case *plainSliceCodec:
+		return d.decode(codec.fallback, rv)
+	case *stringSliceCodec:
+		return d.decode(codec.fallback, rv)
+	case *bytesSliceCodec:
+		return d.decode(codec.fallback, rv)
+	case *stringMapCodec:
+		return d.decode(codec.fallback, rv)
+	case *anyMapCodec:
+		return d.decode(codec.fallback, rv)
+	case *packedCodec:
+		return d.decode(codec.fallback, rv)
+	case *columnarCodec:
+		return d.decode(codec.fallback, rv)
+	case *hookedCodec:
+		if err = d.decode(codec.Codec, rv); err != nil {
+			return err
+		}
+		return codec.complete(rv)
+	case *generatedCodec:
+		if codec.fallback == nil {
+			return errors.New("binary: unable to decode " + rv.Type().String() + ", which only has generated code, from this format")
+		}
+		return d.decode(codec.fallback, rv)
+
+	case *reflectPointerCodec:
+		if d.r.readNil() {
+			rv.Set(reflect.Zero(rv.Type()))
+			return nil
+		}
+
+		var elemCodec Codec
+		if elemCodec, err = codec.codec(); err != nil {
+			return err
+		}
+		if rv.IsNil() || d.opts.freshPointers {
+			if err = d.allocate(1, rv.Type().Elem().Size()); err != nil {
+				return err
+			}
+			rv.Set(reflect.New(rv.Type().Elem()))
+		}
+		return d.decode(elemCodec, rv.Elem())
+
+	case *nullCodec:
+		if d.r.readNil() {
+			rv.Set(reflect.Zero(rv.Type()))
+			return nil
+		}
+
+		var elemCodec Codec
+		if elemCodec, err = codec.codec(); err != nil {
+			return err
+		}
+		rv.Field(1).SetBool(true)
+		return d.decode(elemCodec, rv.Field(0))
+
+	case *interfaceCodec:
+		if !d.r.readNil() {
+			return errors.New("binary: unable to decode " + rv.Type().String() + " from this format, which does not carry the type of the value")
+		}
+		rv.Set(reflect.Zero(rv.Type()))
+
+	case *reflectMapCodec:
+		if d.r.readNil() {
+			rv.Set(reflect.Zero(rv.Type()))
+			return nil
+		}
+
+		var n int
+		if n, err = d.length(d.r.readMap()); err != nil {
+			return
+		}
+
+		t := rv.Type()
+		if err = d.allocate(n, t.Key().Size()+t.Elem().Size()); err != nil {
+			return
+		}
+		rv.Set(reflect.MakeMapWithSize(t, n))
+		for i := 0; i < n; i++ {
+			key := reflect.New(t.Key()).Elem()
+			val := reflect.New(t.Elem()).Elem()
+			if err = d.decode(codec.key, key); err != nil {
+				return
+			}
+			if err = d.decode(codec.val, val); err != nil {
+				return
+			}
+			rv.SetMapIndex(key, val)
+		}
+
+	case *reflectStructCodec:
+		return d.fields(codec, rv)
+	default:
+		return errors.New("binary: unable to decode " + rv.Type().String() + ", which is encoded by a custom codec, from this format")
+	}
+	return
+}
+
+// maxDepth returns the maximum nesting depth.
+func (d *formatDecoder) maxDepth() int {
+	if d.opts.maxDepth > 0 {
+		return d.opts.maxDepth
+	}
+	return maxFormatDepth
+}
+
+// allocate charges the allocation of n values of the size against the budget, if set.
+func (d *formatDecoder) allocate(n int, size uintptr) error {
+	if d.opts.maxAlloc <= 0 || size == 0 {
+		return nil
+	}
+	return charge(&d.spent, d.opts.maxAlloc, n, size)
+}
+
+// length checks the length of an array or a map against the limits.
+func (d *formatDecoder) length(n int, err error) (int, error) {
+	if err == nil && d.opts.maxSliceLen > 0 && n > d.opts.maxSliceLen {
+		return 0, limitError("slice length", uint64(n), d.opts.maxSliceLen)
+	}
+	return n, err
+}
+
+// string reads a string, checking it against the limits.
+func (d *formatDecoder) string() ([]byte, error) {
+	b, err := d.r.readString()
+	switch {
+	case err != nil:
+		return nil, err
+	case d.opts.maxStringLen > 0 && len(b) > d.opts.maxStringLen:
+		return nil, limitError("string length", uint64(len(b)), d.opts.maxStringLen)
+	case d.opts.validUTF8 && !utf8.Valid(b):
+		return nil, ErrInvalidUTF8
+	default:
+		return b, d.allocate(len(b), 1)
+	}
+}
+
+// bytes reads a byte slice, which is copied unless the ZeroCopy option is used.
+func (d *formatDecoder) bytes() ([]byte, error) {
+	b, err := d.r.readBytes()
+	if err != nil || d.opts.zeroCopy {
+		return b, err
+	}
+	if err = d.allocate(len(b), 1); err != nil {
+		return nil, err
+	}
+	return append([]byte(nil), b...), nil
+}
+
+// scalar decodes a boolean, a number or a string according to its kind.
+func (d *formatDecoder) scalar(rv reflect.Value) error {
+	switch rv.Kind() {
+	case reflect.Bool:
+		v, err := d.r.readBool()
+		rv.SetBool(v)
+		return err
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		v, err := d.r.readInt()
+		if err == nil && rv.OverflowInt(v) {
+			return IntOverflowError(v, rv.Type().String())
+		}
+		rv.SetInt(v)
+		return err
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		v, err := d.r.readUint()
+		if err == nil && rv.OverflowUint(v) {
+			return UintOverflowError(v, rv.Type().String())
+		}
+		rv.SetUint(v)
+		return err
+	case reflect.Float32, reflect.Float64:
+		v, err := d.r.readFloat()
+		rv.SetFloat(v)
+		return err
+	case reflect.String:
+		v, err := d.string()
+		rv.SetString(string(v))
+		return err
+	default:
+		return errors.New("binary: unable to decode " + rv.Type().String() + " from this format")
+	}
+}
+
+// elements decodes an array into the elements of a slice or an array, which must have
+// the same length. Without a codec, the elements are decoded as scalars.
+func (d *formatDecoder) elements(elemCodec Codec, rv reflect.Value) error {
+	n, err := d.length(d.r.readArray())
+	switch {
+	case err != nil:
+		return err
+	case rv.Kind() == reflect.Array && n != rv.Len():
+		return errors.New("binary: expected " + strconv.Itoa(rv.Len()) + " elements for " + rv.Type().String())
+	case rv.Kind() == reflect.Slice && n == 0:
+		rv.Set(reflect.Zero(rv.Type()))
+	case rv.Kind() == reflect.Slice:
+		if err = d.allocate(n, rv.Type().Elem().Size()); err != nil {
+			return err
+		}
+		rv.Set(reflect.MakeSlice(rv.Type(), n, n))
+	}
+
+	for i := 0; i < n && err == nil; i++ {
+		if elemCodec == nil {
+			err = d.scalar(rv.Index(i))
+		} else {
+			err = d.decode(elemCodec, rv.Index(i))
+		}
+	}
+	return err
+}
+
+// fields decodes a map of field names into the fields of a struct, which honors the
+// options for unexported fields and json tags.
+func (d *formatDecoder) fields(c *reflectStructCodec, rv reflect.Value) error {
+	n, err := d.length(d.r.readMap())
+	for i := 0; i < n && err == nil; i++ {
+		var name []byte
+		if name, err = d.r.readString(); err != nil {
+			return err
+		}
+
+		field := c.lookup(string(name), &d.opts, rv.Type())
+		if field == nil {
+			err = d.r.skip(d.depth)
+			continue
+		}
+
+		var v reflect.Value
+		if v, _, err = field.access(rv, &d.opts); err == nil {
+			err = d.decode(field.Codec, v)
+		}
+	}
+	return err
+}
+
+// lookup returns the encoded field with a name, or nil if there is none.
+func (c *reflectStructCodec) lookup(name string, o *options, t reflect.Type) *fieldCodec {
+	for i := range *c {
+		f := &(*c)[i]
+		if ok, _ := f.encoded(o, t); ok && f.name(o) == name {
+			return f
+		}
+	}
+	return nil
+}
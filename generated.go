@@ -0,0 +1,91 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package binary
+
+import (
+	"errors"
+	"reflect"
+)
+
+// BinaryEncoder is implemented by types which encode themselves without reflection,
+// typically with code generated by the binarygen command. The generated code must
+// produce the same bytes as the reflection-based codec of the type.
+type BinaryEncoder interface {
+	EncodeBinary(e *Encoder) error
+}
+
+// BinaryDecoder is implemented by types which decode themselves without reflection,
+// typically with code generated by the binarygen command.
+type BinaryDecoder interface {
+	DecodeBinary(d *Decoder) error
+}
+
+// The reflected types of the generated code interfaces
+var (
+	typeBinaryEncoder = reflect.TypeOf((*BinaryEncoder)(nil)).Elem()
+	typeBinaryDecoder = reflect.TypeOf((*BinaryDecoder)(nil)).Elem()
+)
+
+// scanGenerated scans whether the pointer to a type implements both BinaryEncoder and
+// BinaryDecoder, in which case these are preferred over the reflection-based codec.
+func scanGenerated(t reflect.Type) (Codec, bool) {
+	if t.Kind() == reflect.Ptr {
+		return nil, false // Pointers are handled by the pointer codec
+	}
+
+	ptr := reflect.PtrTo(t)
+	if !ptr.Implements(typeBinaryEncoder) || !ptr.Implements(typeBinaryDecoder) {
+		return nil, false
+	}
+
+	// The reflection-based codec is used for the options the generated code does not
+	// support, so we ignore the error in case the type is only supported by its methods.
+	fallback, _ := scanReflect(t)
+	return &generatedCodec{fallback: fallback}, true
+}
+
+// ------------------------------------------------------------------------------
+
+// generatedCodec represents a codec which delegates to the BinaryEncoder and BinaryDecoder
+// implementations of a type.
+type generatedCodec struct {
+	fallback Codec // The reflection-based codec, used for the options the code does not support
+}
+
+// Encode encodes a value into the encoder.
+func (c *generatedCodec) EncodeTo(e *Encoder, rv reflect.Value) error {
+	if !e.opts().generated() {
+		if c.fallback == nil {
+			return errors.New("binary: options are not supported by the generated code of " + rv.Type().String())
+		}
+		return c.fallback.EncodeTo(e, rv)
+	}
+
+	return addrOf(rv).(BinaryEncoder).EncodeBinary(e)
+}
+
+// Decode decodes into a reflect value from the decoder.
+func (c *generatedCodec) DecodeTo(d *Decoder, rv reflect.Value) (err error) {
+	if !d.opts.generated() || d.project != nil {
+		if c.fallback == nil {
+			return errors.New("binary: options are not supported by the generated code of " + rv.Type().String())
+		}
+		return c.fallback.DecodeTo(d, rv)
+	}
+
+	if err = d.enter(); err != nil {
+		return
+	}
+	defer d.leave()
+
+	return rv.Addr().Interface().(BinaryDecoder).DecodeBinary(d)
+}
+
+// Size returns the encoded size of the value.
+func (c *generatedCodec) Size(rv reflect.Value) int {
+	if c.fallback == nil {
+		return -1
+	}
+	return sizeOf(c.fallback, rv)
+}
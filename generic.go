@@ -0,0 +1,36 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package binary
+
+import (
+	"reflect"
+)
+
+// Encode encodes a value of type T into binary format, and is equivalent to calling
+// Marshal with a pointer to the value. If T is an interface type, the dynamic type of the
+// value must have been registered with Register.
+func Encode[T any](v T, opts ...Option) ([]byte, error) {
+	c, err := codecOf[T]()
+	if err != nil {
+		return nil, err
+	}
+	return marshalWith(c, reflect.ValueOf(&v).Elem(), opts)
+}
+
+// Decode decodes a value of type T from the binary format. It is the counterpart of
+// Encode and is equivalent to calling Unmarshal with a pointer to a zero value of T.
+func Decode[T any](b []byte, opts ...Option) (out T, err error) {
+	var c Codec
+	if c, err = codecOf[T](); err == nil {
+		_, err = decodeBytesWith(b, c, reflect.ValueOf(&out).Elem(), opts, true)
+	}
+	return
+}
+
+// codecOf returns the codec of a type parameter, which is resolved from the static type
+// rather than from the dynamic type of a value, so that Encode and Decode go straight to
+// the codec.
+func codecOf[T any]() (Codec, error) {
+	return scan(reflect.TypeFor[T]())
+}
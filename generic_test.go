@@ -0,0 +1,95 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package binary
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenericStruct(t *testing.T) {
+	v := newBenchStruct()
+	b, err := Encode(v)
+	assert.NoError(t, err)
+
+	expect, err := Marshal(&v)
+	assert.NoError(t, err)
+	assert.Equal(t, expect, b)
+
+	out, err := Decode[benchStruct](b)
+	assert.NoError(t, err)
+	assert.Equal(t, v, out)
+}
+
+func TestGenericPrimitives(t *testing.T) {
+	b, err := Encode("hello")
+	assert.NoError(t, err)
+
+	s, err := Decode[string](b)
+	assert.NoError(t, err)
+	assert.Equal(t, "hello", s)
+
+	b, err = Encode(map[string][]int{"a": {1, 2, 3}})
+	assert.NoError(t, err)
+
+	m, err := Decode[map[string][]int](b)
+	assert.NoError(t, err)
+	assert.Equal(t, []int{1, 2, 3}, m["a"])
+}
+
+func TestGenericPointer(t *testing.T) {
+	b, err := Encode[*s0](nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{0x0}, b)
+
+	out, err := Decode[*s0](b)
+	assert.NoError(t, err)
+	assert.Nil(t, out)
+
+	b, err = Encode(s0v)
+	assert.NoError(t, err)
+	assert.Equal(t, append([]byte{0x1}, s0b...), b)
+
+	out, err = Decode[*s0](b)
+	assert.NoError(t, err)
+	assert.Equal(t, s0v, out)
+}
+
+func TestGenericOptions(t *testing.T) {
+	b, err := Encode([]string{"hello", "world"})
+	assert.NoError(t, err)
+
+	_, err = Decode[[]string](b, MaxSliceLen(1))
+	assert.Error(t, err)
+}
+
+func TestGenericUnsupported(t *testing.T) {
+	_, err := Encode(make(chan int))
+	assert.Error(t, err)
+
+	_, err = Decode[chan int]([]byte{0x0})
+	assert.Error(t, err)
+}
+
+func BenchmarkGeneric(b *testing.B) {
+	v := newBenchStruct()
+	encoded, _ := Encode(v)
+
+	b.Run("encode", func(b *testing.B) {
+		b.ReportAllocs()
+		b.ResetTimer()
+		for n := 0; n < b.N; n++ {
+			Encode(v)
+		}
+	})
+
+	b.Run("decode", func(b *testing.B) {
+		b.ReportAllocs()
+		b.ResetTimer()
+		for n := 0; n < b.N; n++ {
+			Decode[benchStruct](encoded)
+		}
+	})
+}
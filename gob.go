@@ -0,0 +1,62 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package binary
+
+import (
+	"encoding/gob"
+	"errors"
+	"io"
+)
+
+// FromGob migrates a stream of values of type T written by a gob.Encoder into a stream of
+// messages, which can be read back with DecodeMessage. The values are migrated one at a
+// time, so that streams larger than memory can be converted, and the options apply to
+// the messages. It returns the number of values which were migrated, which are written
+// even if a later value fails to migrate.
+func FromGob[T any](dst io.Writer, src io.Reader, opts ...Option) (n int, err error) {
+	in := gob.NewDecoder(src)
+	out := NewEncoderSize(dst, defaultBufferSize, opts...)
+	defer func() {
+		if flushErr := out.Flush(); flushErr != nil && flushErr != err {
+			err = errors.Join(err, flushErr)
+		}
+	}()
+
+	for {
+		var v T
+		if err = in.Decode(&v); err == io.EOF {
+			return n, nil
+		}
+		if err != nil {
+			return
+		}
+
+		if err = out.EncodeMessage(&v); err != nil {
+			return
+		}
+		n++
+	}
+}
+
+// ToGob migrates a stream of messages of type T written with EncodeMessage back into a
+// stream of values which can be read by a gob.Decoder. The options must match the ones
+// used to encode the messages. It returns the number of values which were migrated.
+func ToGob[T any](dst io.Writer, src io.Reader, opts ...Option) (n int, err error) {
+	in := NewDecoder(src, opts...)
+	out := gob.NewEncoder(dst)
+	for {
+		var v T
+		if err = in.DecodeMessage(&v); err == io.EOF {
+			return n, nil
+		}
+		if err != nil {
+			return
+		}
+
+		if err = out.Encode(&v); err != nil {
+			return
+		}
+		n++
+	}
+}
@@ -0,0 +1,99 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package binary
+
+import (
+	"bytes"
+	"encoding/gob"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type gobRecord struct {
+	Name   string
+	Values []int
+	Meta   map[string]string
+}
+
+func TestGobMigration(t *testing.T) {
+	records := []gobRecord{
+		{Name: "a", Values: []int{1, 2}, Meta: map[string]string{"k": "a"}},
+		{Name: "b", Meta: map[string]string{"k": "b"}},
+		{Name: "c", Meta: map[string]string{"k": "c"}},
+	}
+
+	var original bytes.Buffer
+	enc := gob.NewEncoder(&original)
+	for _, r := range records {
+		assert.NoError(t, enc.Encode(r))
+	}
+
+	// From gob into messages
+	var migrated bytes.Buffer
+	n, err := FromGob[gobRecord](&migrated, &original)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, n)
+
+	d := NewDecoder(bytes.NewReader(migrated.Bytes()))
+	for _, expect := range records {
+		var r gobRecord
+		assert.NoError(t, d.DecodeMessage(&r))
+		assert.Equal(t, expect, r)
+	}
+	assert.Equal(t, io.EOF, d.DecodeMessage(new(gobRecord)))
+
+	// And back into gob
+	var back bytes.Buffer
+	n, err = ToGob[gobRecord](&back, &migrated)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, n)
+
+	dec := gob.NewDecoder(&back)
+	for _, expect := range records {
+		var r gobRecord
+		assert.NoError(t, dec.Decode(&r))
+		assert.Equal(t, expect, r)
+	}
+}
+
+func TestGobMigrationErrors(t *testing.T) {
+	var original bytes.Buffer
+	assert.NoError(t, gob.NewEncoder(&original).Encode("not a record"))
+
+	n, err := FromGob[gobRecord](io.Discard, &original)
+	assert.Error(t, err)
+	assert.Equal(t, 0, n)
+
+	// The values migrated before an error are written
+	original.Reset()
+	enc := gob.NewEncoder(&original)
+	assert.NoError(t, enc.Encode(gobRecord{Name: "a"}))
+	assert.NoError(t, enc.Encode(gobRecord{Name: "b"}))
+	assert.NoError(t, enc.Encode("not a record"))
+
+	var migrated bytes.Buffer
+	n, err = FromGob[gobRecord](&migrated, &original)
+	assert.Error(t, err)
+	assert.Equal(t, 2, n)
+
+	var names []string
+	for d := NewDecoder(&migrated); ; {
+		var v gobRecord
+		if err := d.DecodeMessage(&v); err != nil {
+			assert.Equal(t, io.EOF, err)
+			break
+		}
+		names = append(names, v.Name)
+	}
+	assert.Equal(t, []string{"a", "b"}, names)
+
+	// A truncated message fails the migration
+	b, err := Marshal(&gobRecord{Name: "a"})
+	assert.NoError(t, err)
+	n, err = ToGob[gobRecord](io.Discard, bytes.NewReader(append([]byte{byte(len(b) + 1)}, b...)))
+	assert.Error(t, err)
+	assert.Equal(t, 0, n)
+}
@@ -0,0 +1,259 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package binary
+
+import (
+	"errors"
+	"io"
+	"math"
+	"math/bits"
+	"reflect"
+)
+
+// scanGorilla returns a codec for slices of float64 which compresses them like the
+// Gorilla time series database, selected with a `binary:",gorilla"` tag. Every value is
+// XORed with the previous one and only the bits which differ are written, which shrinks
+// slowly changing series, such as telemetry, by an order of magnitude.
+func scanGorilla(t reflect.Type) (Codec, error) {
+	if t.Kind() != reflect.Slice || t.Elem().Kind() != reflect.Float64 {
+		return nil, errors.New("binary: gorilla encoding is not supported for " + t.String())
+	}
+
+	return new(gorillaCodec), nil
+}
+
+// ------------------------------------------------------------------------------
+
+// gorillaCodec represents a codec for slices of float64, which are prefixed with their
+// count and, unless they are empty, by the size of their compressed bits.
+type gorillaCodec struct{}
+
+// Encode encodes a value into the encoder.
+func (c *gorillaCodec) EncodeTo(e *Encoder, rv reflect.Value) (err error) {
+	l := rv.Len()
+	e.WriteUvarint(uint64(l))
+	if l == 0 {
+		return
+	}
+
+	w := appenders.Get().(*appendWriter)
+	b := appendGorilla((*w)[:0], rv, e.opts().canonical)
+	e.WriteUvarint(uint64(len(b)))
+	e.Write(b)
+
+	*w = b[:0]
+	appenders.Put(w)
+	return
+}
+
+// Decode decodes into a reflect value from the decoder.
+func (c *gorillaCodec) DecodeTo(d *Decoder, rv reflect.Value) (err error) {
+	var l int
+	var b []byte
+	if l, b, err = d.readGorillaBits(); err != nil || l == 0 {
+		return
+	}
+	if err = d.allocate(l, rv.Type().Elem().Size()); err != nil {
+		return
+	}
+
+	slice := d.newSlice(rv.Type(), l)
+	if err = readGorilla(b, l, func(i int, v float64) {
+		slice.Index(i).SetFloat(v)
+	}); err == nil {
+		rv.Set(slice)
+	}
+	return
+}
+
+// Size returns the encoded size of the value.
+func (c *gorillaCodec) Size(rv reflect.Value) int {
+	l := rv.Len()
+	if l == 0 {
+		return 1
+	}
+
+	w := appenders.Get().(*appendWriter)
+	b := appendGorilla((*w)[:0], rv, false)
+	size := uvarintSize(uint64(l)) + uvarintSize(uint64(len(b))) + len(b)
+
+	*w = b[:0]
+	appenders.Put(w)
+	return size
+}
+
+// appendGorilla compresses the values of a slice and appends them to a buffer. The first
+// value is written as it is, then every value is XORed with the previous one: a zero bit
+// means they are equal, otherwise the bits 10 are followed by the meaningful bits of the
+// XOR in the same window as the previous one, or the bits 11 by the number of leading
+// zeros in 5 bits, the number of meaningful bits in 6 bits and the meaningful bits. The
+// values are normalized first if canonical.
+func appendGorilla(dst []byte, rv reflect.Value, canonical bool) []byte {
+	bitsOf := func(i int) uint64 {
+		v := rv.Index(i).Float()
+		if canonical {
+			v = canonicalFloat64(v)
+		}
+		return math.Float64bits(v)
+	}
+
+	w := bitWriter{b: dst}
+	prev := bitsOf(0)
+	w.write(prev, 64)
+
+	var leading, trailing uint
+	window := false
+	for i := 1; i < rv.Len(); i++ {
+		v := bitsOf(i)
+		xor := v ^ prev
+		prev = v
+
+		if xor == 0 {
+			w.write(0, 1)
+			continue
+		}
+
+		lz := uint(bits.LeadingZeros64(xor))
+		tz := uint(bits.TrailingZeros64(xor))
+		if lz > 31 {
+			lz = 31 // The number of leading zeros is written in 5 bits
+		}
+
+		if window && lz >= leading && tz >= trailing {
+			w.write(0b10, 2)
+			w.write(xor>>trailing, 64-leading-trailing)
+			continue
+		}
+
+		meaningful := 64 - lz - tz
+		w.write(0b11, 2)
+		w.write(uint64(lz), 5)
+		w.write(uint64(meaningful&63), 6) // 64 meaningful bits are written as 0
+		w.write(xor>>tz, meaningful)
+		leading, trailing, window = lz, tz, true
+	}
+	return w.b
+}
+
+// readGorilla decompresses a number of values, and calls the function with each of them.
+func readGorilla(b []byte, l int, fn func(i int, v float64)) error {
+	r := bitReader{b: b}
+	prev, err := r.read(64)
+	if err != nil {
+		return err
+	}
+
+	fn(0, math.Float64frombits(prev))
+	var leading, trailing uint
+	window := false
+	for i := 1; i < l; i++ {
+		var flag, xor uint64
+		if flag, err = r.read(1); err != nil {
+			return err
+		}
+
+		if flag == 1 {
+			if flag, err = r.read(1); err != nil {
+				return err
+			}
+
+			if flag == 1 {
+				var lz, meaningful uint64
+				if lz, err = r.read(5); err != nil {
+					return err
+				}
+				if meaningful, err = r.read(6); err != nil {
+					return err
+				}
+				if meaningful == 0 {
+					meaningful = 64
+				}
+				if lz+meaningful > 64 {
+					return errInvalidGorilla
+				}
+				leading, trailing, window = uint(lz), uint(64-lz-meaningful), true
+			} else if !window {
+				return errInvalidGorilla
+			}
+
+			if xor, err = r.read(64 - leading - trailing); err != nil {
+				return err
+			}
+			xor <<= trailing
+		}
+
+		prev ^= xor
+		fn(i, math.Float64frombits(prev))
+	}
+	return nil
+}
+
+// The error returned for invalid compressed floating-point numbers
+var errInvalidGorilla = errors.New("binary: invalid gorilla encoding")
+
+// readGorillaBits reads the number of values of a compressed slice and their bits.
+func (d *Decoder) readGorillaBits() (l int, b []byte, err error) {
+	var n int
+	if l, err = d.readSliceLen(); err != nil || l == 0 {
+		return
+	}
+	if n, err = d.readSliceLen(); err != nil {
+		return
+	}
+
+	// Every value but the first one takes at least a bit
+	if n < 8 || uint64(l-1) > 8*uint64(n)-64 {
+		return 0, nil, errInvalidGorilla
+	}
+
+	b, err = d.Slice(n)
+	return
+}
+
+// ------------------------------------------------------------------------------
+
+// bitWriter appends bits to a buffer, from the highest bit of every byte.
+type bitWriter struct {
+	b    []byte
+	free uint // The number of unused bits in the last byte
+}
+
+// write writes the lowest n bits of a value.
+func (w *bitWriter) write(v uint64, n uint) {
+	for n > 0 {
+		if w.free == 0 {
+			w.b = append(w.b, 0)
+			w.free = 8
+		}
+
+		k := min(n, w.free)
+		chunk := (v >> (n - k)) & (1<<k - 1)
+		w.b[len(w.b)-1] |= byte(chunk << (w.free - k))
+		w.free -= k
+		n -= k
+	}
+}
+
+// bitReader reads bits from a buffer, from the highest bit of every byte.
+type bitReader struct {
+	b []byte
+	i uint64 // The index of the next bit
+}
+
+// read reads n bits into the lowest bits of a value.
+func (r *bitReader) read(n uint) (v uint64, err error) {
+	if uint64(len(r.b))*8-r.i < uint64(n) {
+		return 0, io.ErrUnexpectedEOF
+	}
+
+	for n > 0 {
+		free := 8 - uint(r.i%8)
+		k := min(n, free)
+		chunk := (uint64(r.b[r.i/8]) >> (free - k)) & (1<<k - 1)
+		v = v<<k | chunk
+		r.i += uint64(k)
+		n -= k
+	}
+	return
+}
@@ -0,0 +1,119 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package binary
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type series struct {
+	Values []float64 `binary:",gorilla"`
+}
+
+func TestGorilla(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	random := make([]float64, 100)
+	for i := range random {
+		random[i] = rng.NormFloat64() * 1e6
+	}
+
+	for _, values := range [][]float64{
+		nil,
+		{42},
+		{1, 1, 1, 1, 1.5, 1.5, 2, 2},
+		{math.NaN(), math.Inf(1), math.Inf(-1), math.Copysign(0, -1), 0, math.MaxFloat64, math.SmallestNonzeroFloat64},
+		random,
+	} {
+		v := &series{Values: values}
+		b, err := Marshal(v)
+		assert.NoError(t, err)
+
+		size, err := Size(v)
+		assert.NoError(t, err)
+		assert.Equal(t, len(b), size)
+
+		var out series
+		assert.NoError(t, Unmarshal(b, &out))
+		assert.Equal(t, len(values), len(out.Values))
+		for i := range values {
+			assert.Equal(t, math.Float64bits(values[i]), math.Float64bits(out.Values[i]))
+		}
+	}
+}
+
+func TestGorilla_Canonical(t *testing.T) {
+	nan := math.Float64frombits(0x7ff8000000000002)
+	for _, pair := range [][2][]float64{
+		{{1, math.Copysign(0, -1), 2}, {1, 0, 2}},
+		{{1, nan, 2}, {1, math.NaN(), 2}},
+	} {
+		a, err := Marshal(&series{Values: pair[0]}, Canonical())
+		assert.NoError(t, err)
+		b, err := Marshal(&series{Values: pair[1]}, Canonical())
+		assert.NoError(t, err)
+		assert.Equal(t, b, a)
+
+		size, err := Size(&series{Values: pair[0]}, Canonical())
+		assert.NoError(t, err)
+		assert.Equal(t, len(a), size)
+
+		// Without the option, the bits are kept as they are
+		a, err = Marshal(&series{Values: pair[0]})
+		assert.NoError(t, err)
+		b, err = Marshal(&series{Values: pair[1]})
+		assert.NoError(t, err)
+		assert.NotEqual(t, b, a)
+	}
+}
+
+func TestGorilla_Telemetry(t *testing.T) {
+	v := &series{Values: make([]float64, 1000)}
+	for i := range v.Values {
+		v.Values[i] = 20 + float64(i/50)*0.5
+	}
+
+	compressed, err := Marshal(v)
+	assert.NoError(t, err)
+	plain, err := Marshal(v.Values)
+	assert.NoError(t, err)
+	assert.True(t, len(compressed)*10 < len(plain))
+}
+
+func TestGorilla_Invalid(t *testing.T) {
+	b, err := Marshal(&series{Values: []float64{1, 2, 3}})
+	assert.NoError(t, err)
+
+	var out series
+	assert.Error(t, Unmarshal(b[:len(b)-1], &out))
+	assert.Error(t, Unmarshal([]byte{200, 1, 8, 0, 0, 0, 0, 0, 0, 0, 0}, &out))
+
+	// The window is reused before it was set
+	assert.Error(t, Unmarshal([]byte{2, 9, 0, 0, 0, 0, 0, 0, 0, 0, 0x80}, &out))
+	assert.Error(t, Unmarshal(b, &out, Fields("Other"), MaxSliceLen(2)))
+
+	_, err = Marshal(&struct {
+		Values []float32 `binary:",gorilla"`
+	}{})
+	assert.Error(t, err)
+}
+
+func TestGorilla_Schema(t *testing.T) {
+	b, err := Marshal(&series{Values: []float64{1, 2}}, SelfDescribing())
+	assert.NoError(t, err)
+
+	out, err := DecodeAny(b, SelfDescribing())
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"Values": []interface{}{1.0, 2.0}}, out)
+
+	var kinds []Kind
+	assert.NoError(t, Walk(b, nil, func(t Token) error {
+		kinds = append(kinds, t.Schema.Kind)
+		return nil
+	}, SelfDescribing()))
+	assert.Equal(t, []Kind{KindGorilla, KindStruct}, kinds)
+}
@@ -0,0 +1,75 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package binary
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type hashedDocument struct {
+	Title   string
+	Body    []byte
+	Numbers []int64
+	Scores  []float64
+	Tags    map[string]string
+}
+
+func TestEncoder_Hash(t *testing.T) {
+	v := &hashedDocument{
+		Title:   "document",
+		Body:    bytes.Repeat([]byte("body"), 1000),
+		Numbers: []int64{1, -2, 3, 1 << 40},
+		Scores:  []float64{1.5, 2.5},
+		Tags:    map[string]string{"a": strings.Repeat("b", 100)},
+	}
+
+	for _, opts := range [][]Option{
+		nil,
+		{Checksum()},
+		{Versioned(), Deterministic()},
+		{Compression(flateCompressor{}, 64)},
+		{SelfDescribing(), InternStrings()},
+	} {
+		for _, newEncoder := range []func(*bytes.Buffer) *Encoder{
+			func(b *bytes.Buffer) *Encoder { return NewEncoder(b, opts...) },
+			func(b *bytes.Buffer) *Encoder { return NewEncoderSize(b, 64, opts...) },
+			func(b *bytes.Buffer) *Encoder { return NewVectoredEncoder(b, 16, opts...) },
+		} {
+			var buffer bytes.Buffer
+			h := sha256.New()
+			e := newEncoder(&buffer)
+			e.SetHash(h)
+			assert.NoError(t, e.Encode(v))
+			assert.NoError(t, e.Encode(v))
+			assert.NoError(t, e.Flush())
+
+			// The digest is the one of every byte which was written
+			expect := sha256.Sum256(buffer.Bytes())
+			assert.Equal(t, expect[:], h.Sum(nil))
+
+			var out hashedDocument
+			assert.NoError(t, NewDecoder(&buffer, opts...).Decode(&out))
+			assert.Equal(t, v, &out)
+		}
+	}
+}
+
+func TestEncoder_HashDetach(t *testing.T) {
+	var buffer bytes.Buffer
+	h := sha256.New()
+	e := NewEncoder(&buffer)
+	e.SetHash(h)
+	assert.NoError(t, e.Encode("hashed"))
+
+	e.SetHash(nil)
+	assert.NoError(t, e.Encode("not hashed"))
+
+	expect := sha256.Sum256([]byte("\x06hashed"))
+	assert.Equal(t, expect[:], h.Sum(nil))
+}
@@ -0,0 +1,122 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package binary
+
+import (
+	"reflect"
+)
+
+// BeforeEncoder is implemented by types which prepare their values before they get encoded,
+// for example to normalize them or to check their invariants. An error aborts the encoding.
+type BeforeEncoder interface {
+	BeforeEncode() error
+}
+
+// AfterDecoder is implemented by types which complete their values once they are decoded,
+// for example to fill in defaults or to check their invariants. An error fails the decoding.
+type AfterDecoder interface {
+	AfterDecode() error
+}
+
+// The reflected types of the hooks
+var (
+	typeBeforeEncoder = reflect.TypeOf((*BeforeEncoder)(nil)).Elem()
+	typeAfterDecoder  = reflect.TypeOf((*AfterDecoder)(nil)).Elem()
+)
+
+// withHooks wraps the codec of a type with its hooks and its validator, if any.
+func withHooks(t reflect.Type, c Codec) Codec {
+	if !hooked(t) {
+		return c
+	}
+
+	ptr := reflect.PointerTo(t)
+	return &hookedCodec{
+		Codec:        c,
+		beforeEncode: ptr.Implements(typeBeforeEncoder),
+		afterDecode:  ptr.Implements(typeAfterDecoder),
+		validate:     validatorOf(t),
+	}
+}
+
+// hooked returns whether a type has hooks or a validator.
+func hooked(t reflect.Type) bool {
+	if t.Kind() == reflect.Interface || t.Kind() == reflect.Ptr {
+		return validatorOf(t) != nil
+	}
+
+	ptr := reflect.PointerTo(t)
+	return ptr.Implements(typeBeforeEncoder) || ptr.Implements(typeAfterDecoder) || validatorOf(t) != nil
+}
+
+// elemKind returns the kind of the elements of an array or a slice, or reflect.Invalid if
+// they are hooked, so that the fast-paths which bypass their codec are not taken.
+func elemKind(t reflect.Type) reflect.Kind {
+	if hooked(t.Elem()) {
+		return reflect.Invalid
+	}
+	return t.Elem().Kind()
+}
+
+// ------------------------------------------------------------------------------
+
+// hookedCodec represents a codec which calls the hooks of the values it encodes and
+// decodes, and validates the values it decodes.
+type hookedCodec struct {
+	Codec
+	beforeEncode bool                      // Whether the type implements BeforeEncoder
+	afterDecode  bool                      // Whether the type implements AfterDecoder
+	validate     func(reflect.Value) error // The validator of the type, if any
+}
+
+// Encode encodes a value into the encoder.
+func (c *hookedCodec) EncodeTo(e *Encoder, rv reflect.Value) error {
+	var err error
+	if rv, err = c.prepare(rv); err != nil {
+		return err
+	}
+	return c.Codec.EncodeTo(e, rv)
+}
+
+// prepare calls the BeforeEncode hook of a value, which is copied if it is not addressable,
+// and returns the value to encode.
+func (c *hookedCodec) prepare(rv reflect.Value) (reflect.Value, error) {
+	if !c.beforeEncode {
+		return rv, nil
+	}
+
+	ptr := addrOf(rv)
+	return reflect.ValueOf(ptr).Elem(), ptr.(BeforeEncoder).BeforeEncode()
+}
+
+// Decode decodes into a reflect value from the decoder.
+func (c *hookedCodec) DecodeTo(d *Decoder, rv reflect.Value) error {
+	if err := c.Codec.DecodeTo(d, rv); err != nil {
+		return err
+	}
+	return c.complete(rv)
+}
+
+// complete calls the AfterDecode hook of a decoded value, then validates it.
+func (c *hookedCodec) complete(rv reflect.Value) error {
+	if c.afterDecode {
+		if err := addrOf(rv).(AfterDecoder).AfterDecode(); err != nil {
+			return err
+		}
+	}
+
+	if c.validate != nil {
+		return c.validate(rv)
+	}
+	return nil
+}
+
+// Size returns the encoded size of the value. It is unknown when the BeforeEncode hook
+// may change the value.
+func (c *hookedCodec) Size(rv reflect.Value) int {
+	if c.beforeEncode {
+		return -1
+	}
+	return sizeOf(c.Codec, rv)
+}
@@ -0,0 +1,86 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package binary
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var errNoEmail = errors.New("missing email")
+
+type hookedUser struct {
+	Email   string
+	Retries int
+}
+
+// BeforeEncode normalizes the email.
+func (u *hookedUser) BeforeEncode() error {
+	if u.Email == "" {
+		return errNoEmail
+	}
+	u.Email = strings.ToLower(u.Email)
+	return nil
+}
+
+// AfterDecode fills in the default number of retries.
+func (u *hookedUser) AfterDecode() error {
+	if u.Retries == 0 {
+		u.Retries = 3
+	}
+	return nil
+}
+
+type hookedTeam struct {
+	Owner   hookedUser
+	Members []hookedUser
+	ByName  map[string]*hookedUser
+}
+
+func TestHooks(t *testing.T) {
+	in := hookedTeam{
+		Owner:   hookedUser{Email: "Alice@Example.com", Retries: 1},
+		Members: []hookedUser{{Email: "BOB@example.com"}},
+		ByName:  map[string]*hookedUser{"carol": {Email: "Carol@example.com"}},
+	}
+
+	b, err := Marshal(&in)
+	assert.NoError(t, err)
+
+	var out hookedTeam
+	assert.NoError(t, Unmarshal(b, &out))
+	assert.Equal(t, hookedUser{Email: "alice@example.com", Retries: 1}, out.Owner)
+	assert.Equal(t, []hookedUser{{Email: "bob@example.com", Retries: 3}}, out.Members)
+	assert.Equal(t, &hookedUser{Email: "carol@example.com", Retries: 3}, out.ByName["carol"])
+
+	// The other formats call the hooks as well
+	b, err = MarshalCBOR(&in)
+	assert.NoError(t, err)
+	out = hookedTeam{}
+	assert.NoError(t, UnmarshalCBOR(b, &out))
+	assert.Equal(t, 3, out.Members[0].Retries)
+
+	// An error aborts the encoding
+	_, err = Marshal(&hookedTeam{Members: []hookedUser{{}}})
+	assert.True(t, errors.Is(err, errNoEmail))
+}
+
+func TestHooks_Validator(t *testing.T) {
+	RegisterValidator(func(u hookedUser) error {
+		if u.Retries > 5 {
+			return errors.New("too many retries")
+		}
+		return nil
+	})
+	defer RegisterValidator[hookedUser](nil)
+
+	b, err := Marshal(&hookedUser{Email: "a@b.c", Retries: 9})
+	assert.NoError(t, err)
+
+	var out hookedUser
+	assert.Error(t, Unmarshal(b, &out))
+}
@@ -0,0 +1,157 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package binary
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"iter"
+	"strconv"
+)
+
+// errInvalidIndex is returned when the index of an indexed collection is corrupted.
+var errInvalidIndex = errors.New("binary: invalid index")
+
+// IndexedWriter writes a collection of values of type T followed by an index of their
+// offsets, so that an IndexedReader can seek to a single value of a huge collection, such
+// as a file or an object in a bucket, and decode it without reading the others. The values
+// are encoded one after the other, each like Marshal, and the index consists of the offset
+// of every value and of the end of the last one, followed by the count of values, all as
+// little-endian 64-bit integers.
+type IndexedWriter[T any] struct {
+	e       *Encoder // The encoder of the underlying writer
+	opts    []Option // The options of the values
+	value   []byte   // The buffer of the value being written
+	offsets []uint64 // The offsets of the values written so far
+	offset  uint64   // The offset of the next value
+}
+
+// NewIndexedWriter creates a buffered writer of a collection of values of type T, which
+// requires calling Close once the values have been written, to write the index.
+func NewIndexedWriter[T any](w io.Writer, opts ...Option) *IndexedWriter[T] {
+	return &IndexedWriter[T]{e: NewEncoderSize(w, defaultBufferSize), opts: opts}
+}
+
+// Write encodes a value into the collection. A value which fails to encode is left out,
+// so that the writer can be used for the next values.
+func (s *IndexedWriter[T]) Write(v T) (err error) {
+	if s.value, err = MarshalTo(s.value[:0], &v, s.opts...); err != nil {
+		return err
+	}
+
+	s.offsets = append(s.offsets, s.offset)
+	s.offset += uint64(len(s.value))
+	s.e.Write(s.value)
+	return nil
+}
+
+// Close writes the index of the values and flushes them to the underlying writer. The
+// writer must not be used afterwards.
+func (s *IndexedWriter[T]) Close() error {
+	var b [8]byte
+	for _, offset := range append(s.offsets, s.offset) {
+		s.e.Write(binary.LittleEndian.AppendUint64(b[:0], offset))
+	}
+
+	s.e.Write(binary.LittleEndian.AppendUint64(b[:0], uint64(len(s.offsets))))
+	return s.e.Flush()
+}
+
+// MarshalIndexed encodes a collection of values along with the index of their offsets,
+// like an IndexedWriter.
+func MarshalIndexed[T any](values []T, opts ...Option) ([]byte, error) {
+	var out appendWriter
+	w := NewIndexedWriter[T](&out, opts...)
+	for _, v := range values {
+		if err := w.Write(v); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ------------------------------------------------------------------------------
+
+// IndexedReader reads the values of a collection written by an IndexedWriter, in any
+// order, by reading the offsets of a value from the index and decoding only its bytes.
+// A reader is safe for concurrent use, as long as the underlying io.ReaderAt is.
+type IndexedReader[T any] struct {
+	r     io.ReaderAt
+	opts  []Option
+	count int   // The number of values of the collection
+	index int64 // The offset of the index, which is also the end of the last value
+}
+
+// NewIndexedReader creates a reader of a collection of values of type T, whose encoded
+// size is needed to locate the index at its end. The options must match the ones used to
+// write the values. To read a collection from memory, use a bytes.Reader.
+func NewIndexedReader[T any](r io.ReaderAt, size int64, opts ...Option) (*IndexedReader[T], error) {
+	var b [8]byte
+	if size < 16 {
+		return nil, errInvalidIndex
+	}
+	if _, err := r.ReadAt(b[:], size-8); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+
+	// The index holds one more offset than there are values
+	count := binary.LittleEndian.Uint64(b[:])
+	if count >= uint64(size-8)/8 {
+		return nil, errInvalidIndex
+	}
+
+	s := &IndexedReader[T]{r: r, opts: opts, count: int(count)}
+	s.index = size - 8 - 8*int64(count+1)
+	return s, nil
+}
+
+// Len returns the number of values of the collection.
+func (s *IndexedReader[T]) Len() int {
+	return s.count
+}
+
+// At decodes the value at an index of the collection.
+func (s *IndexedReader[T]) At(i int) (v T, err error) {
+	if i < 0 || i >= s.count {
+		return v, errors.New("binary: index " + strconv.Itoa(i) + " out of range with " + strconv.Itoa(s.count) + " values")
+	}
+
+	// The value ends where the next one starts
+	var b [16]byte
+	if _, err = s.r.ReadAt(b[:], s.index+8*int64(i)); err != nil {
+		return v, unexpectedEOF(err)
+	}
+
+	start := binary.LittleEndian.Uint64(b[:8])
+	end := binary.LittleEndian.Uint64(b[8:])
+	if start > end || end > uint64(s.index) {
+		return v, errInvalidIndex
+	}
+
+	value := make([]byte, end-start)
+	if _, err = s.r.ReadAt(value, int64(start)); err != nil {
+		return v, unexpectedEOF(err)
+	}
+
+	err = Unmarshal(value, &v, s.opts...)
+	return
+}
+
+// All returns an iterator over the values of the collection, in order, which stops after
+// yielding the first error.
+func (s *IndexedReader[T]) All() iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		for i := 0; i < s.count; i++ {
+			v, err := s.At(i)
+			if !yield(v, err) || err != nil {
+				return
+			}
+		}
+	}
+}
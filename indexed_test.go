@@ -0,0 +1,149 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package binary
+
+import (
+	"bytes"
+	"io"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type indexedEvent struct {
+	ID      uint64
+	Name    string
+	Payload []byte
+}
+
+func newIndexedEvents(n int) []indexedEvent {
+	events := make([]indexedEvent, 0, n)
+	for i := 0; i < n; i++ {
+		events = append(events, indexedEvent{
+			ID:      uint64(i),
+			Name:    "event-" + strconv.Itoa(i),
+			Payload: bytes.Repeat([]byte{byte(i)}, i%50+1),
+		})
+	}
+	return events
+}
+
+func TestIndexed(t *testing.T) {
+	events := newIndexedEvents(1000)
+	b, err := MarshalIndexed(events)
+	assert.NoError(t, err)
+
+	r, err := NewIndexedReader[indexedEvent](bytes.NewReader(b), int64(len(b)))
+	assert.NoError(t, err)
+	assert.Equal(t, len(events), r.Len())
+
+	// The values can be read in any order
+	for _, i := range []int{999, 0, 500, 1} {
+		v, err := r.At(i)
+		assert.NoError(t, err)
+		assert.Equal(t, events[i], v)
+	}
+
+	var out []indexedEvent
+	for v, err := range r.All() {
+		assert.NoError(t, err)
+		out = append(out, v)
+	}
+	assert.Equal(t, events, out)
+
+	_, err = r.At(1000)
+	assert.Error(t, err)
+	_, err = r.At(-1)
+	assert.Error(t, err)
+}
+
+// countingReaderAt counts the bytes read from the underlying reader.
+type countingReaderAt struct {
+	r    io.ReaderAt
+	read int
+}
+
+func (r *countingReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	n, err := r.r.ReadAt(p, off)
+	r.read += n
+	return n, err
+}
+
+func TestIndexed_Seek(t *testing.T) {
+	events := newIndexedEvents(1000)
+	b, err := MarshalIndexed(events, Checksum())
+	assert.NoError(t, err)
+
+	// Only the footer, the offsets and the value are read
+	counter := &countingReaderAt{r: bytes.NewReader(b)}
+	r, err := NewIndexedReader[indexedEvent](counter, int64(len(b)), Checksum())
+	assert.NoError(t, err)
+
+	v, err := r.At(700)
+	assert.NoError(t, err)
+	assert.Equal(t, events[700], v)
+	assert.True(t, counter.read < 100)
+}
+
+func TestIndexed_Writer(t *testing.T) {
+	var buffer bytes.Buffer
+	w := NewIndexedWriter[string](&buffer)
+	assert.NoError(t, w.Write("a"))
+	assert.NoError(t, w.Write(""))
+	assert.NoError(t, w.Write("abc"))
+	assert.NoError(t, w.Close())
+	assert.Equal(t, []byte{
+		1, 'a', 0, 3, 'a', 'b', 'c',
+		0, 0, 0, 0, 0, 0, 0, 0,
+		2, 0, 0, 0, 0, 0, 0, 0,
+		3, 0, 0, 0, 0, 0, 0, 0,
+		7, 0, 0, 0, 0, 0, 0, 0,
+		3, 0, 0, 0, 0, 0, 0, 0,
+	}, buffer.Bytes())
+
+	// An empty collection only has its index
+	b, err := MarshalIndexed[string](nil)
+	assert.NoError(t, err)
+
+	r, err := NewIndexedReader[string](bytes.NewReader(b), int64(len(b)))
+	assert.NoError(t, err)
+	assert.Equal(t, 0, r.Len())
+}
+
+func TestIndexed_Invalid(t *testing.T) {
+	b, err := MarshalIndexed([]string{"a", "b"})
+	assert.NoError(t, err)
+
+	// The index must fit in the collection
+	_, err = NewIndexedReader[string](bytes.NewReader(b[:8]), 8)
+	assert.Error(t, err)
+
+	corrupted := append([]byte(nil), b...)
+	corrupted[len(b)-8] = 100
+	_, err = NewIndexedReader[string](bytes.NewReader(corrupted), int64(len(b)))
+	assert.Error(t, err)
+
+	// The offsets must be within the values
+	corrupted = append([]byte(nil), b...)
+	corrupted[4] = 100
+	r, err := NewIndexedReader[string](bytes.NewReader(corrupted), int64(len(b)))
+	assert.NoError(t, err)
+
+	_, err = r.At(0)
+	assert.Error(t, err)
+
+	// Values which are not encoded as expected fail to decode
+	events, err := NewIndexedReader[indexedEvent](bytes.NewReader(b), int64(len(b)))
+	assert.NoError(t, err)
+
+	_, err = events.At(0)
+	assert.Error(t, err)
+}
+
+func TestIndexed_Unsupported(t *testing.T) {
+	w := NewIndexedWriter[chan int](io.Discard)
+	assert.Error(t, w.Write(make(chan int)))
+	assert.NoError(t, w.Close())
+}
@@ -0,0 +1,154 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package binary
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+)
+
+// The registry of concrete types which can be encoded within interfaces
+var (
+	registeredTypes = new(sync.Map) // name -> reflect.Type
+	registeredNames = new(sync.Map) // reflect.Type -> name
+	registerLock    sync.Mutex
+)
+
+// Register records a concrete type under a name, so that values of that type can be
+// encoded into interface-typed fields (such as interface{} or error) and decoded back
+// into the same concrete type. The name is written on the wire, so it must be identical
+// on both sides. Similarly to gob, registering the same type or name twice panics.
+func Register(name string, value interface{}) {
+	if name == "" {
+		panic("binary: attempt to register empty name")
+	}
+
+	t := reflect.TypeOf(value)
+	if t == nil {
+		panic("binary: attempt to register nil value")
+	}
+
+	registerLock.Lock()
+	defer registerLock.Unlock()
+	if other, loaded := registeredTypes.Load(name); loaded && other != t {
+		panic("binary: registering duplicate types for " + name + ": " + other.(reflect.Type).String() + " != " + t.String())
+	}
+
+	if other, loaded := registeredNames.Load(t); loaded && other != name {
+		panic("binary: registering duplicate names for " + t.String() + ": " + other.(string) + " != " + name)
+	}
+
+	registeredTypes.Store(name, t)
+	registeredNames.Store(t, name)
+}
+
+// ------------------------------------------------------------------------------
+
+type interfaceCodec struct{}
+
+// Encode encodes a value into the encoder.
+func (c *interfaceCodec) EncodeTo(e *Encoder, rv reflect.Value) error {
+	return e.writeInterface(rv.Elem(), rv.Type())
+}
+
+// writeInterface writes the value held by an interface of a type, prefixed with the name
+// its concrete type was registered with, or an empty name if the value is invalid.
+func (e *Encoder) writeInterface(elem reflect.Value, t reflect.Type) (err error) {
+	if !elem.IsValid() {
+		e.WriteUvarint(0)
+		return
+	}
+
+	name, ok := registeredNames.Load(elem.Type())
+	if !ok {
+		return errors.New("binary: type " + elem.Type().String() + " is not registered for encoding into " + t.String())
+	}
+
+	var codec Codec
+	if codec, err = scan(elem.Type()); err == nil {
+		str := name.(string)
+		e.WriteUvarint(uint64(len(str)))
+		e.Write(stringToBinary(str))
+		err = codec.EncodeTo(e, elem)
+	}
+	return
+}
+
+// Decode decodes into a reflect value from the decoder.
+func (c *interfaceCodec) DecodeTo(d *Decoder, rv reflect.Value) error {
+	elem, err := d.readInterface(rv.Type())
+	switch {
+	case err != nil:
+		return err
+	case elem.IsValid():
+		rv.Set(elem)
+	default:
+		rv.Set(reflect.Zero(rv.Type()))
+	}
+	return nil
+}
+
+// readInterface reads a value of the concrete type registered under the name which
+// precedes it, which must be assignable to an interface of a type. The value is invalid
+// if the name is empty, as for nil interfaces.
+func (d *Decoder) readInterface(t reflect.Type) (out reflect.Value, err error) {
+	if err = d.enter(); err != nil {
+		return
+	}
+	defer d.leave()
+
+	var l int
+	var name []byte
+	if l, err = d.readStringLen(); err != nil || l == 0 {
+		return
+	}
+
+	if name, err = d.Slice(l); err != nil {
+		return
+	}
+
+	registered, ok := registeredTypes.Load(binaryToString(&name))
+	if !ok {
+		return out, errors.New("binary: name '" + string(name) + "' is not registered for decoding into " + t.String())
+	}
+
+	elemType := registered.(reflect.Type)
+	if !elemType.AssignableTo(t) {
+		return out, errors.New("binary: type " + elemType.String() + " is not assignable to " + t.String())
+	}
+
+	var codec Codec
+	if codec, err = scan(elemType); err == nil {
+		elem := reflect.New(elemType).Elem()
+		if err = codec.DecodeTo(d, elem); err == nil {
+			out = elem
+		}
+	}
+	return
+}
+
+// Size returns the encoded size of the value.
+func (c *interfaceCodec) Size(rv reflect.Value) int {
+	if rv.IsNil() {
+		return 1
+	}
+
+	elem := rv.Elem()
+	name, ok := registeredNames.Load(elem.Type())
+	if !ok {
+		return -1
+	}
+
+	codec, err := scan(elem.Type())
+	if err != nil {
+		return -1
+	}
+
+	if n := sizeOf(codec, elem); n >= 0 {
+		l := len(name.(string))
+		return uvarintSize(uint64(l)) + l + n
+	}
+	return -1
+}
@@ -0,0 +1,83 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package binary
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type shape interface {
+	Area() float64
+}
+
+type square struct {
+	Side float64
+}
+
+func (s square) Area() float64 { return s.Side * s.Side }
+
+type circle struct {
+	Radius float64
+}
+
+func (c *circle) Area() float64 { return 3 * c.Radius * c.Radius }
+
+func init() {
+	Register("square", square{})
+	Register("circle", &circle{})
+	Register("string", "")
+}
+
+func TestInterface(t *testing.T) {
+	type T struct {
+		Shapes []shape
+		Any    interface{}
+		None   interface{}
+	}
+
+	v := T{
+		Shapes: []shape{square{2}, &circle{1}, nil},
+		Any:    "hello",
+	}
+
+	b, err := Marshal(&v)
+	assert.NoError(t, err)
+
+	n, err := Size(&v)
+	assert.NoError(t, err)
+	assert.Equal(t, len(b), n)
+
+	var out T
+	assert.NoError(t, Unmarshal(b, &out))
+	assert.Equal(t, v, out)
+}
+
+func TestInterface_Errors(t *testing.T) {
+	type T struct {
+		Shape shape
+	}
+
+	_, err := Marshal(&T{Shape: unregistered{}})
+	assert.Error(t, err)
+
+	b, err := Marshal(&struct{ Any interface{} }{"hello"})
+	assert.NoError(t, err)
+	assert.Error(t, Unmarshal(b, &T{}))
+
+	assert.Error(t, Unmarshal([]byte{0x3, 'f', 'o', 'o'}, &T{}))
+}
+
+func TestRegister_Duplicate(t *testing.T) {
+	assert.NotPanics(t, func() { Register("square", square{}) })
+	assert.Panics(t, func() { Register("square", circle{}) })
+	assert.Panics(t, func() { Register("rectangle", square{}) })
+	assert.Panics(t, func() { Register("", square{}) })
+	assert.Panics(t, func() { Register("nil", nil) })
+}
+
+type unregistered struct{}
+
+func (unregistered) Area() float64 { return 0 }
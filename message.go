@@ -0,0 +1,50 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package binary
+
+import (
+	"errors"
+	"reflect"
+)
+
+// EncodeMessage encodes the value as a message framed with its length, so that several
+// messages can be written to the same stream and read back with DecodeMessage, even if
+// some of them fail to decode.
+func (e *Encoder) EncodeMessage(v interface{}) error {
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	if err := e.writeNested(func() error {
+		return e.encode(rv)
+	}); err != nil {
+		return err
+	}
+	return e.err
+}
+
+// DecodeMessage decodes a message which was framed with its length by EncodeMessage. The
+// whole frame is always consumed, so that the next message can be decoded even if this
+// one failed to decode, and any bytes of the frame which were not needed by the value are
+// skipped. The size of the frame can be limited with the MaxMessageSize option, in which
+// case larger frames are not consumed and the stream cannot be resumed.
+func (d *Decoder) DecodeMessage(v interface{}) (err error) {
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	if !rv.CanAddr() {
+		return errors.New("binary: can only Decode to pointer type")
+	}
+
+	var l int
+	var b []byte
+	if l, err = d.readLength(d.opts.maxMessage, "message"); err != nil {
+		return
+	}
+	if b, err = d.Slice(l); err != nil {
+		return
+	}
+
+	nested := d.nested(b)
+	if err = nested.decode(rv); err == nil {
+		err = nested.consumed()
+	}
+	nested.release()
+	return
+}
@@ -0,0 +1,97 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package binary
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMessages(t *testing.T) {
+	var buffer bytes.Buffer
+	e := NewEncoder(&buffer)
+	assert.NoError(t, e.EncodeMessage(s0v))
+	assert.NoError(t, e.EncodeMessage("hello"))
+	assert.NoError(t, e.EncodeMessage(s0v))
+	assert.Equal(t, append([]byte{byte(len(s0b))}, s0b...), buffer.Bytes()[:len(s0b)+1])
+
+	readers := []func() io.Reader{
+		func() io.Reader { return bytes.NewReader(buffer.Bytes()) },
+		func() io.Reader { return iotest.OneByteReader(bytes.NewReader(buffer.Bytes())) },
+	}
+
+	for _, reader := range readers {
+		d := NewDecoder(reader())
+		var s s0
+		assert.NoError(t, d.DecodeMessage(&s))
+		assert.Equal(t, *s0v, s)
+
+		// The second message is a string, which can not be decoded as s0
+		var other []s0
+		assert.Error(t, d.DecodeMessage(&other))
+
+		// The decoder resumes with the third message
+		s = s0{}
+		assert.NoError(t, d.DecodeMessage(&s))
+		assert.Equal(t, *s0v, s)
+
+		assert.Equal(t, io.EOF, d.DecodeMessage(&s))
+	}
+}
+
+func TestMessageTrailingBytes(t *testing.T) {
+	var buffer bytes.Buffer
+	e := NewEncoder(&buffer)
+	assert.NoError(t, e.EncodeMessage([]string{"a", "b"}))
+	assert.NoError(t, e.EncodeMessage(uint8(7)))
+
+	d := NewDecoder(&buffer)
+	var first uint8
+	assert.NoError(t, d.DecodeMessage(&first))
+	assert.Equal(t, uint8(2), first)
+
+	var second uint8
+	assert.NoError(t, d.DecodeMessage(&second))
+	assert.Equal(t, uint8(7), second)
+}
+
+func TestMessageBuffered(t *testing.T) {
+	var buffer bytes.Buffer
+	e := NewEncoderSize(&buffer, 64)
+	for i := 0; i < 100; i++ {
+		assert.NoError(t, e.EncodeMessage(i))
+	}
+	assert.NoError(t, e.Flush())
+
+	d := NewDecoder(&buffer)
+	for i := 0; i < 100; i++ {
+		var v int
+		assert.NoError(t, d.DecodeMessage(&v))
+		assert.Equal(t, i, v)
+	}
+}
+
+func TestMessageLimit(t *testing.T) {
+	var buffer bytes.Buffer
+	assert.NoError(t, NewEncoder(&buffer).EncodeMessage("hello world"))
+
+	var out string
+	err := NewDecoder(bytes.NewReader(buffer.Bytes()), MaxMessageSize(4)).DecodeMessage(&out)
+	assert.True(t, errors.Is(err, ErrLimitExceeded))
+
+	err = NewDecoder(bytes.NewReader(buffer.Bytes()[:5])).DecodeMessage(&out)
+	assert.Error(t, err)
+
+	assert.Error(t, NewDecoder(&buffer).DecodeMessage(out))
+}
+
+func TestMessageUnsupported(t *testing.T) {
+	e := NewEncoder(new(bytes.Buffer))
+	assert.Error(t, e.EncodeMessage(make(chan int)))
+}
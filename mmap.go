@@ -0,0 +1,63 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package binary
+
+import (
+	"errors"
+	"os"
+)
+
+// MappedFile represents a file mapped into memory, so that huge values can be decoded
+// from it without reading the whole file upfront, as the operating system pages its
+// contents in as they are accessed. Along with the ZeroCopy option, the decoded strings,
+// byte slices and slices of numbers point into the mapped memory instead of being copied,
+// and the values must then neither be modified, since the memory is read-only, nor used
+// after the file is closed. On platforms which do not support memory mapping, the file
+// is read into memory instead.
+//
+//	f, err := binary.MapFile("dataset.bin")
+//	defer f.Close()
+//	err = binary.Unmarshal(f.Bytes(), &v, binary.ZeroCopy())
+type MappedFile struct {
+	data   []byte
+	mapped bool // Whether the data is mapped, rather than read into memory
+}
+
+// MapFile maps the contents of a file into memory, which requires calling Close once the
+// values decoded from it are no longer in use.
+func MapFile(path string) (*MappedFile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	switch {
+	case err != nil:
+		return nil, err
+	case info.Size() == 0:
+		return new(MappedFile), nil
+	case info.Size() > int64(maxInt):
+		return nil, errors.New("binary: file " + path + " is too large to be mapped")
+	}
+
+	return mapFile(f, int(info.Size()))
+}
+
+// Bytes returns the contents of the file.
+func (m *MappedFile) Bytes() []byte {
+	return m.data
+}
+
+// Close unmaps the file. The values decoded from it with the ZeroCopy option must not
+// be used afterwards.
+func (m *MappedFile) Close() (err error) {
+	if m.mapped {
+		err = unmapFile(m.data)
+	}
+
+	m.data, m.mapped = nil, false
+	return
+}
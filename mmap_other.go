@@ -0,0 +1,25 @@
+//go:build !unix
+
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package binary
+
+import (
+	"io"
+	"os"
+)
+
+// mapFile reads the contents of a file into memory, as memory mapping is not supported.
+func mapFile(f *os.File, size int) (*MappedFile, error) {
+	data := make([]byte, size)
+	if _, err := io.ReadFull(f, data); err != nil {
+		return nil, err
+	}
+	return &MappedFile{data: data}, nil
+}
+
+// unmapFile is never called, as files are never mapped.
+func unmapFile(data []byte) error {
+	return nil
+}
@@ -0,0 +1,76 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package binary
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"unsafe"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type mappedDataset struct {
+	Header [7]byte // Aligns the values on 8 bytes
+	Values []float64
+	Name   string
+	Points []float64
+}
+
+// within returns whether a pointer points into a slice.
+func within(b []byte, p unsafe.Pointer) bool {
+	start := uintptr(unsafe.Pointer(unsafe.SliceData(b)))
+	return uintptr(p) >= start && uintptr(p) < start+uintptr(len(b))
+}
+
+func TestMappedFile(t *testing.T) {
+	v := mappedDataset{
+		Values: []float64{1.5, 2.5, 3.5},
+		Name:   "dataset",
+		Points: []float64{4.5, 5.5},
+	}
+
+	path := filepath.Join(t.TempDir(), "dataset.bin")
+	assert.NoError(t, SaveFile(path, &v))
+
+	f, err := MapFile(path)
+	assert.NoError(t, err)
+
+	var out mappedDataset
+	assert.NoError(t, Unmarshal(f.Bytes(), &out, ZeroCopy()))
+	assert.Equal(t, v, out)
+
+	// The strings and the aligned slices point into the mapped file, while the slices which
+	// are not aligned are copied
+	assert.True(t, within(f.Bytes(), unsafe.Pointer(unsafe.StringData(out.Name))))
+	assert.True(t, within(f.Bytes(), unsafe.Pointer(&out.Values[0])))
+	assert.False(t, within(f.Bytes(), unsafe.Pointer(&out.Points[0])))
+	assert.Equal(t, len(out.Values), cap(out.Values))
+	assert.NoError(t, f.Close())
+	assert.Nil(t, f.Bytes())
+
+	// Without the option, everything is copied
+	f, err = MapFile(path)
+	assert.NoError(t, err)
+	defer f.Close()
+
+	out = mappedDataset{}
+	assert.NoError(t, Unmarshal(f.Bytes(), &out))
+	assert.Equal(t, v, out)
+	assert.False(t, within(f.Bytes(), unsafe.Pointer(&out.Values[0])))
+}
+
+func TestMappedFile_Empty(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "empty.bin")
+	assert.NoError(t, os.WriteFile(path, nil, 0600))
+
+	f, err := MapFile(path)
+	assert.NoError(t, err)
+	assert.Empty(t, f.Bytes())
+	assert.NoError(t, f.Close())
+
+	_, err = MapFile(filepath.Join(t.TempDir(), "missing.bin"))
+	assert.Error(t, err)
+}
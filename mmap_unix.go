@@ -0,0 +1,25 @@
+//go:build unix
+
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package binary
+
+import (
+	"os"
+	"syscall"
+)
+
+// mapFile maps the contents of a file into read-only memory.
+func mapFile(f *os.File, size int) (*MappedFile, error) {
+	data, err := syscall.Mmap(int(f.Fd()), 0, size, syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, &os.PathError{Op: "mmap", Path: f.Name(), Err: err}
+	}
+	return &MappedFile{data: data, mapped: true}, nil
+}
+
+// unmapFile unmaps the memory of a mapped file.
+func unmapFile(data []byte) error {
+	return syscall.Munmap(data)
+}
@@ -0,0 +1,139 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package binary
+
+import (
+	"encoding/binary"
+	"math"
+	"time"
+)
+
+// MarshalMsgpack encodes the payload into MessagePack, using the same struct tags and
+// options as the native format. Structs are encoded as maps keyed by the names of their
+// fields, which can be taken from their json tag with the JSONTags option, and times as
+// the timestamp extension. The compact encodings of slices selected by tags, such as
+// delta or gorilla, are encoded as plain arrays.
+func MarshalMsgpack(v interface{}, opts ...Option) ([]byte, error) {
+	w := new(msgpackWriter)
+	if err := marshalFormat(w, v, opts); err != nil {
+		return nil, err
+	}
+	return *w, nil
+}
+
+// ------------------------------------------------------------------------------
+
+// msgpackWriter writes values in the MessagePack format, with the smallest of the
+// representations of every value.
+type msgpackWriter []byte
+
+// writeNil writes a nil value.
+func (w *msgpackWriter) writeNil() {
+	*w = append(*w, 0xc0)
+}
+
+// writeBool writes a boolean.
+func (w *msgpackWriter) writeBool(v bool) {
+	if v {
+		*w = append(*w, 0xc3)
+		return
+	}
+	*w = append(*w, 0xc2)
+}
+
+// writeInt writes a signed integer, as an unsigned one if it is positive.
+func (w *msgpackWriter) writeInt(v int64) {
+	switch {
+	case v >= 0:
+		w.writeUint(uint64(v))
+	case v >= -32:
+		*w = append(*w, byte(v))
+	case v >= math.MinInt8:
+		*w = append(*w, 0xd0, byte(v))
+	case v >= math.MinInt16:
+		*w = binary.BigEndian.AppendUint16(append(*w, 0xd1), uint16(v))
+	case v >= math.MinInt32:
+		*w = binary.BigEndian.AppendUint32(append(*w, 0xd2), uint32(v))
+	default:
+		*w = binary.BigEndian.AppendUint64(append(*w, 0xd3), uint64(v))
+	}
+}
+
+// writeUint writes an unsigned integer.
+func (w *msgpackWriter) writeUint(v uint64) {
+	switch {
+	case v <= 0x7f:
+		*w = append(*w, byte(v))
+	case v <= math.MaxUint8:
+		*w = append(*w, 0xcc, byte(v))
+	case v <= math.MaxUint16:
+		*w = binary.BigEndian.AppendUint16(append(*w, 0xcd), uint16(v))
+	case v <= math.MaxUint32:
+		*w = binary.BigEndian.AppendUint32(append(*w, 0xce), uint32(v))
+	default:
+		*w = binary.BigEndian.AppendUint64(append(*w, 0xcf), v)
+	}
+}
+
+// writeFloat32 writes a single-precision floating-point number.
+func (w *msgpackWriter) writeFloat32(v float32) {
+	*w = binary.BigEndian.AppendUint32(append(*w, 0xca), math.Float32bits(v))
+}
+
+// writeFloat64 writes a double-precision floating-point number.
+func (w *msgpackWriter) writeFloat64(v float64) {
+	*w = binary.BigEndian.AppendUint64(append(*w, 0xcb), math.Float64bits(v))
+}
+
+// writeString writes a string, prefixed with its length.
+func (w *msgpackWriter) writeString(v string) {
+	w.writeHeader(len(v), 0xa0, 32, [3]byte{0xd9, 0xda, 0xdb})
+	*w = append(*w, v...)
+}
+
+// writeBytes writes a byte slice, prefixed with its length.
+func (w *msgpackWriter) writeBytes(v []byte) {
+	w.writeHeader(len(v), 0, 0, [3]byte{0xc4, 0xc5, 0xc6})
+	*w = append(*w, v...)
+}
+
+// writeArray writes the header of an array.
+func (w *msgpackWriter) writeArray(n int) {
+	w.writeHeader(n, 0x90, 16, [3]byte{0, 0xdc, 0xdd})
+}
+
+// writeMap writes the header of a map.
+func (w *msgpackWriter) writeMap(n int) {
+	w.writeHeader(n, 0x80, 16, [3]byte{0, 0xde, 0xdf})
+}
+
+// writeHeader writes the length of a string, a byte slice, an array or a map, either
+// packed into the fixed prefix if it is below the limit, or after the prefix of its
+// 1, 2 or 4-byte length. A zero prefix means that the length can not take a byte.
+func (w *msgpackWriter) writeHeader(n int, fixed byte, limit int, prefixes [3]byte) {
+	switch {
+	case n < limit:
+		*w = append(*w, fixed|byte(n))
+	case n <= math.MaxUint8 && prefixes[0] != 0:
+		*w = append(*w, prefixes[0], byte(n))
+	case n <= math.MaxUint16:
+		*w = binary.BigEndian.AppendUint16(append(*w, prefixes[1]), uint16(n))
+	default:
+		*w = binary.BigEndian.AppendUint32(append(*w, prefixes[2]), uint32(n))
+	}
+}
+
+// writeTime writes a time as the timestamp extension, in the smallest of its formats.
+func (w *msgpackWriter) writeTime(v time.Time) {
+	sec, nsec := v.Unix(), uint64(v.Nanosecond())
+	switch {
+	case uint64(sec)>>34 == 0 && nsec == 0 && sec <= math.MaxUint32:
+		*w = binary.BigEndian.AppendUint32(append(*w, 0xd6, 0xff), uint32(sec))
+	case uint64(sec)>>34 == 0:
+		*w = binary.BigEndian.AppendUint64(append(*w, 0xd7, 0xff), nsec<<34|uint64(sec))
+	default:
+		*w = binary.BigEndian.AppendUint32(append(*w, 0xc7, 12, 0xff), uint32(nsec))
+		*w = binary.BigEndian.AppendUint64(*w, uint64(sec))
+	}
+}
@@ -0,0 +1,88 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package binary
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMsgpackStruct(t *testing.T) {
+	type T struct {
+		Name  string   `json:"name"`
+		Age   uint8    `json:"age"`
+		Tags  []string `json:"tags"`
+		Next  *T       `json:"next"`
+		Score float64  `json:"-"`
+		Note  string   `binary:",omitempty" json:"note"`
+	}
+
+	b, err := MarshalMsgpack(&T{Name: "alice", Age: 30, Tags: []string{"a", "b"}, Score: 1}, JSONTags())
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{
+		0x84,
+		0xa4, 'n', 'a', 'm', 'e', 0xa5, 'a', 'l', 'i', 'c', 'e',
+		0xa3, 'a', 'g', 'e', 0x1e,
+		0xa4, 't', 'a', 'g', 's', 0x92, 0xa1, 'a', 0xa1, 'b',
+		0xa4, 'n', 'e', 'x', 't', 0xc0,
+	}, b)
+}
+
+func TestMsgpackValues(t *testing.T) {
+	long := strings.Repeat("x", 40)
+	for _, tc := range []struct {
+		value  interface{}
+		expect []byte
+	}{
+		{true, []byte{0xc3}},
+		{int8(-1), []byte{0xff}},
+		{-33, []byte{0xd0, 0xdf}},
+		{200, []byte{0xcc, 0xc8}},
+		{-200, []byte{0xd1, 0xff, 0x38}},
+		{uint32(70000), []byte{0xce, 0x00, 0x01, 0x11, 0x70}},
+		{int64(-1 << 40), []byte{0xd3, 0xff, 0xff, 0xff, 0x00, 0x00, 0x00, 0x00, 0x00}},
+		{uint64(1 << 40), []byte{0xcf, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00}},
+		{float32(1), []byte{0xca, 0x3f, 0x80, 0x00, 0x00}},
+		{1.5, []byte{0xcb, 0x3f, 0xf8, 0, 0, 0, 0, 0, 0}},
+		{long, append([]byte{0xd9, 40}, long...)},
+		{[]byte{1, 2}, []byte{0xc4, 0x02, 0x01, 0x02}},
+		{[2]byte{1, 2}, []byte{0xc4, 0x02, 0x01, 0x02}},
+		{make([]int, 16), append([]byte{0xdc, 0x00, 0x10}, make([]byte, 16)...)},
+		{map[string]int{"b": 2, "a": 1}, []byte{0x82, 0xa1, 'a', 0x01, 0xa1, 'b', 0x02}},
+		{time.Unix(1, 0), []byte{0xd6, 0xff, 0x00, 0x00, 0x00, 0x01}},
+		{time.Unix(1, 1), []byte{0xd7, 0xff, 0x00, 0x00, 0x00, 0x04, 0x00, 0x00, 0x00, 0x01}},
+		{time.Unix(-1, 0), []byte{0xc7, 0x0c, 0xff, 0, 0, 0, 0, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}},
+	} {
+		b, err := MarshalMsgpack(tc.value, Deterministic())
+		assert.NoError(t, err)
+		assert.Equal(t, tc.expect, b, "%v", tc.value)
+	}
+}
+
+func TestMsgpackTaggedSlices(t *testing.T) {
+	type T struct {
+		A []int     `binary:",delta"`
+		B []bool    `binary:",bits"`
+		C []float64 `binary:",gorilla"`
+		D []string  `binary:",rle"`
+	}
+
+	b, err := MarshalMsgpack(T{A: []int{1, 2}, B: []bool{true}, C: []float64{0}, D: []string{"x", "x"}})
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{
+		0x84,
+		0xa1, 'A', 0x92, 0x01, 0x02,
+		0xa1, 'B', 0x91, 0xc3,
+		0xa1, 'C', 0x91, 0xcb, 0, 0, 0, 0, 0, 0, 0, 0,
+		0xa1, 'D', 0x92, 0xa1, 'x', 0xa1, 'x',
+	}, b)
+}
+
+func TestMsgpackCustomCodec(t *testing.T) {
+	_, err := MarshalMsgpack(&struct{ V testOpaque }{})
+	assert.Error(t, err)
+}
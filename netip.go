@@ -0,0 +1,194 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package binary
+
+import (
+	"errors"
+	"net"
+	"net/netip"
+	"reflect"
+)
+
+// The reflected types of the net and net/netip packages
+var (
+	typeIP       = reflect.TypeOf(net.IP{})
+	typeAddr     = reflect.TypeOf(netip.Addr{})
+	typePrefix   = reflect.TypeOf(netip.Prefix{})
+	typeAddrPort = reflect.TypeOf(netip.AddrPort{})
+)
+
+// The error returned for addresses whose length is neither 0, 4 nor at least 16 bytes
+var errInvalidAddr = errors.New("binary: invalid IP address encoding")
+
+// ------------------------------------------------------------------------------
+
+// netipCodec represents a native codec for netip.Addr, netip.Prefix and netip.AddrPort.
+// It produces the same bytes as their MarshalBinary methods prefixed with their length,
+// but without allocating: the 4 or 16 bytes of the address, or none if it is the zero
+// address, followed by its zone, and by the bits of a prefix or the port, little-endian.
+type netipCodec struct {
+	suffix int // The number of bytes after the address, 1 for a prefix and 2 for a port
+}
+
+// Encode encodes a value into the encoder.
+func (c *netipCodec) EncodeTo(e *Encoder, rv reflect.Value) error {
+	addr, extra := c.split(rv)
+	e.WriteUvarint(uint64(c.size(addr)))
+	switch {
+	case addr.Is4():
+		a := addr.As4()
+		e.Write(append(e.scratch[:0], a[:]...))
+	case addr.Is6():
+		a := addr.As16()
+		e.Write(append(e.scratch[:0], a[:8]...))
+		e.Write(append(e.scratch[:0], a[8:]...))
+		e.Write(stringToBinary(addr.Zone()))
+	}
+
+	switch c.suffix {
+	case 1:
+		e.WriteUint8(byte(extra))
+	case 2:
+		e.scratch[0] = byte(extra)
+		e.scratch[1] = byte(extra >> 8)
+		e.Write(e.scratch[:2])
+	}
+	return nil
+}
+
+// Decode decodes into a reflect value from the decoder.
+func (c *netipCodec) DecodeTo(d *Decoder, rv reflect.Value) (err error) {
+	var l int
+	var addr netip.Addr
+	if l, err = d.readSliceLen(); err != nil {
+		return
+	}
+	if l < c.suffix {
+		return errInvalidAddr
+	}
+	if addr, err = d.readAddr(l - c.suffix); err != nil {
+		return
+	}
+
+	switch ptr := rv.Addr().Interface().(type) {
+	case *netip.Addr:
+		*ptr = addr
+	case *netip.Prefix:
+		var bits byte
+		if bits, err = d.ReadUint8(); err == nil {
+			*ptr = netip.PrefixFrom(addr, int(bits))
+		}
+	case *netip.AddrPort:
+		var b []byte
+		if b, err = d.sliceOrScratch(2); err == nil {
+			*ptr = netip.AddrPortFrom(addr, uint16(b[0])|uint16(b[1])<<8)
+		}
+	}
+	return
+}
+
+// Size returns the encoded size of the value.
+func (c *netipCodec) Size(rv reflect.Value) int {
+	addr, _ := c.split(rv)
+	n := c.size(addr)
+	return uvarintSize(uint64(n)) + n
+}
+
+// size returns the number of bytes of an address and of the suffix, without their length.
+func (c *netipCodec) size(addr netip.Addr) int {
+	switch {
+	case addr.Is4():
+		return 4 + c.suffix
+	case addr.Is6():
+		return 16 + len(addr.Zone()) + c.suffix
+	default:
+		return c.suffix
+	}
+}
+
+// split returns the address of a reflected value, along with the bits of a prefix or the
+// port, without copying the value to the heap if it is addressable.
+func (c *netipCodec) split(rv reflect.Value) (netip.Addr, int) {
+	switch ptr := addrOf(rv).(type) {
+	case *netip.Prefix:
+		return ptr.Addr().WithZone(""), ptr.Bits()
+	case *netip.AddrPort:
+		return ptr.Addr(), int(ptr.Port())
+	case *netip.Addr:
+		return *ptr, 0
+	default:
+		return netip.Addr{}, 0
+	}
+}
+
+// readAddr reads an address of a number of bytes, 0 for the zero address, 4 for an IPv4
+// address, or 16 for an IPv6 address followed by its zone.
+func (d *Decoder) readAddr(n int) (netip.Addr, error) {
+	switch {
+	case n == 0:
+		return netip.Addr{}, nil
+	case n == 4:
+		b, err := d.sliceOrScratch(4)
+		if err != nil {
+			return netip.Addr{}, err
+		}
+		return netip.AddrFrom4([4]byte(b)), nil
+	case n < 16:
+		return netip.Addr{}, errInvalidAddr
+	}
+
+	// The scratch buffer is smaller than an address, which is read in two halves
+	var a [16]byte
+	for i := 0; i < 16; i += 8 {
+		b, err := d.sliceOrScratch(8)
+		if err != nil {
+			return netip.Addr{}, err
+		}
+		copy(a[i:], b)
+	}
+
+	addr := netip.AddrFrom16(a)
+	if n > 16 {
+		if err := d.checkStringLen(n - 16); err != nil {
+			return netip.Addr{}, err
+		}
+
+		zone, err := d.Slice(n - 16)
+		if err != nil {
+			return netip.Addr{}, err
+		}
+		addr = addr.WithZone(string(zone))
+	}
+	return addr, nil
+}
+
+// ------------------------------------------------------------------------------
+
+// ipCodec represents a codec for net.IP, which is a byte slice. IPv4 addresses are encoded
+// in their 4-byte form, even if they are held in 16 bytes, as net.ParseIP returns them.
+// The decoded addresses are equal to the encoded ones according to net.IP.Equal.
+type ipCodec struct {
+	byteSliceCodec
+}
+
+// Encode encodes a value into the encoder.
+func (c *ipCodec) EncodeTo(e *Encoder, rv reflect.Value) error {
+	e.WriteLengthPrefixed(ipBytes(rv))
+	return nil
+}
+
+// Size returns the encoded size of the value.
+func (c *ipCodec) Size(rv reflect.Value) int {
+	n := len(ipBytes(rv))
+	return uvarintSize(uint64(n)) + n
+}
+
+// ipBytes returns the bytes of an IP address, in their 4-byte form for IPv4 addresses.
+func ipBytes(rv reflect.Value) []byte {
+	ip := net.IP(rv.Bytes())
+	if ip4 := ip.To4(); ip4 != nil {
+		return ip4
+	}
+	return ip
+}
@@ -0,0 +1,176 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package binary
+
+import (
+	"bytes"
+	"encoding"
+	"io"
+	"net"
+	"net/netip"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type networkRoute struct {
+	Source  netip.Addr
+	Network netip.Prefix
+	Gateway netip.AddrPort
+	Peer    net.IP
+	Subnet  net.IPNet
+}
+
+func TestNetip_Compatible(t *testing.T) {
+	for _, v := range []encoding.BinaryMarshaler{
+		netip.Addr{},
+		netip.MustParseAddr("10.0.0.1"),
+		netip.MustParseAddr("2001:db8::1"),
+		netip.MustParseAddr("::ffff:10.0.0.1"),
+		netip.MustParseAddr("fe80::1%eth0"),
+		netip.Prefix{},
+		netip.MustParsePrefix("10.0.0.0/8"),
+		netip.MustParsePrefix("2001:db8::/32"),
+		netip.AddrPort{},
+		netip.MustParseAddrPort("10.0.0.1:8080"),
+		netip.MustParseAddrPort("[fe80::1%eth0]:443"),
+	} {
+		expect, err := v.MarshalBinary()
+		assert.NoError(t, err)
+
+		// The bytes are the same as the ones of MarshalBinary, prefixed with their length
+		b, err := Marshal(v)
+		assert.NoError(t, err)
+		assert.Equal(t, append([]byte{byte(len(expect))}, expect...), b)
+
+		size, err := Size(v)
+		assert.NoError(t, err)
+		assert.Equal(t, len(b), size)
+	}
+}
+
+func TestNetip_Roundtrip(t *testing.T) {
+	v := networkRoute{
+		Source:  netip.MustParseAddr("fe80::1%eth0"),
+		Network: netip.MustParsePrefix("192.168.0.0/16"),
+		Gateway: netip.MustParseAddrPort("192.168.0.1:53"),
+		Peer:    net.ParseIP("192.168.1.10"),
+	}
+	_, subnet, err := net.ParseCIDR("2001:db8::/32")
+	assert.NoError(t, err)
+	v.Subnet = *subnet
+
+	b, err := Marshal(&v)
+	assert.NoError(t, err)
+
+	// Decoding from a slice and from a stream give the same addresses
+	for _, d := range []*Decoder{
+		NewDecoder(NewSliceReader(b)),
+		NewDecoder(io.MultiReader(bytes.NewReader(b))),
+	} {
+		var out networkRoute
+		assert.NoError(t, d.Decode(&out))
+		assert.Equal(t, v.Source, out.Source)
+		assert.Equal(t, v.Network, out.Network)
+		assert.Equal(t, v.Gateway, out.Gateway)
+		assert.True(t, v.Peer.Equal(out.Peer))
+		assert.Equal(t, v.Subnet.String(), out.Subnet.String())
+	}
+}
+
+func TestNetip_IP(t *testing.T) {
+	ip := net.ParseIP("10.0.0.1")
+	assert.Len(t, ip, net.IPv6len)
+
+	// IPv4 addresses are encoded in their 4-byte form
+	b, err := Marshal(ip)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{4, 10, 0, 0, 1}, b)
+
+	size, err := Size(ip)
+	assert.NoError(t, err)
+	assert.Equal(t, len(b), size)
+
+	var out net.IP
+	assert.NoError(t, Unmarshal(b, &out))
+	assert.True(t, ip.Equal(out))
+
+	// IPv6 and empty addresses are encoded as they are
+	for _, ip := range []net.IP{nil, net.ParseIP("2001:db8::1")} {
+		b, err := Marshal(ip)
+		assert.NoError(t, err)
+		assert.Equal(t, 1+len(ip), len(b))
+
+		var out net.IP
+		assert.NoError(t, Unmarshal(b, &out))
+		assert.True(t, ip.Equal(out))
+	}
+}
+
+func TestNetip_Allocs(t *testing.T) {
+	if raceEnabled {
+		t.Skip("the pools drop their items at random in race builds")
+	}
+
+	v := networkRoute{
+		Source:  netip.MustParseAddr("2001:db8::1"),
+		Network: netip.MustParsePrefix("10.0.0.0/8"),
+		Gateway: netip.MustParseAddrPort("10.0.0.1:8080"),
+	}
+
+	e := NewEncoder(io.Discard)
+	var out networkRoute
+	b, err := Marshal(&v)
+	assert.NoError(t, err)
+
+	assert.Zero(t, testing.AllocsPerRun(10, func() {
+		assert.NoError(t, e.Encode(&v))
+	}))
+	assert.Zero(t, testing.AllocsPerRun(10, func() {
+		assert.NoError(t, Unmarshal(b, &out))
+	}))
+}
+
+func TestNetip_Invalid(t *testing.T) {
+	for _, b := range [][]byte{
+		{3, 1, 2, 3},
+		{6, 1, 2, 3, 4, 5, 6},
+		{0},
+		{5, 10, 0, 0},
+	} {
+		var out netip.Prefix
+		assert.Error(t, Unmarshal(b, &out))
+	}
+
+	var out netip.AddrPort
+	assert.Error(t, Unmarshal([]byte{1, 0}, &out))
+}
+
+func TestNetip_Schema(t *testing.T) {
+	s, err := SchemaOf(reflect.TypeOf(networkRoute{}))
+	assert.NoError(t, err)
+	for _, f := range s.Fields[:4] {
+		assert.Equal(t, KindBytes, f.Schema.Kind, f.Name)
+	}
+}
+
+func TestNetip_Formats(t *testing.T) {
+	v := networkRoute{
+		Source:  netip.MustParseAddr("10.0.0.1"),
+		Network: netip.MustParsePrefix("10.0.0.0/8"),
+		Gateway: netip.MustParseAddrPort("[2001:db8::1]:80"),
+		Peer:    net.ParseIP("10.0.0.2"),
+	}
+
+	b, err := MarshalCBOR(&v)
+	assert.NoError(t, err)
+
+	var out networkRoute
+	assert.NoError(t, UnmarshalCBOR(b, &out))
+	assert.Equal(t, v.Source, out.Source)
+	assert.Equal(t, v.Network, out.Network)
+	assert.Equal(t, v.Gateway, out.Gateway)
+	assert.True(t, v.Peer.Equal(out.Peer))
+}
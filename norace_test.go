@@ -0,0 +1,10 @@
+//go:build !race
+
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package binary
+
+// Whether the race detector is enabled, in which case the pools drop their items at
+// random, so that the allocations can not be counted exactly.
+const raceEnabled = false
@@ -0,0 +1,140 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package binary
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// Null represents a value which may be null, such as a nullable column of a database. It
+// has the same fields as sql.Null, and implements sql.Scanner and driver.Valuer, so that
+// it can be scanned from a row and bound to a query as well. It is encoded like a pointer,
+// as a presence byte followed by the value only if it is valid, and decodes into a *T.
+type Null[T any] struct {
+	V     T
+	Valid bool // Valid is true if V is not null
+}
+
+// NullOf returns a valid nullable value.
+func NullOf[T any](v T) Null[T] {
+	return Null[T]{V: v, Valid: true}
+}
+
+// Scan implements the sql.Scanner interface.
+func (n *Null[T]) Scan(value any) error {
+	var v sql.Null[T]
+	if err := v.Scan(value); err != nil {
+		return err
+	}
+
+	n.V, n.Valid = v.V, v.Valid
+	return nil
+}
+
+// Value implements the driver.Valuer interface.
+func (n Null[T]) Value() (driver.Value, error) {
+	return sql.Null[T]{V: n.V, Valid: n.Valid}.Value()
+}
+
+// nullable is implemented by the nullable types of this package.
+func (Null[T]) nullable() {}
+
+// The reflected type of the interface implemented by the nullable types
+var typeNullable = reflect.TypeOf((*interface{ nullable() })(nil)).Elem()
+
+// isNullable returns whether a type is a struct made of a value followed by its validity,
+// which is encoded like a pointer. This is the case of Null and of the null types of the
+// database/sql package, such as sql.NullString or sql.Null.
+func isNullable(t reflect.Type) bool {
+	switch {
+	case t.Kind() != reflect.Struct || t.NumField() != 2:
+		return false
+	case t.Field(1).Name != "Valid" || t.Field(1).Type.Kind() != reflect.Bool:
+		return false
+	default:
+		return t.Implements(typeNullable) || (t.PkgPath() == "database/sql" && strings.HasPrefix(t.Name(), "Null"))
+	}
+}
+
+// ------------------------------------------------------------------------------
+
+// nullCodec represents a codec for nullable values, which are structs whose first field
+// is the value and whose second field tells whether it is valid. It produces the same
+// bytes as a pointer to the value, which is nil if it is not valid.
+type nullCodec struct {
+	elemType  reflect.Type // The type of the value
+	elemCodec Codec        // The codec of the value, scanned lazily to support recursive types
+	once      sync.Once
+	err       error
+}
+
+// Encode encodes a value into the encoder.
+func (c *nullCodec) EncodeTo(e *Encoder, rv reflect.Value) (err error) {
+	if !rv.Field(1).Bool() {
+		e.WriteBool(false)
+		return
+	}
+
+	var codec Codec
+	if codec, err = c.codec(); err != nil {
+		return
+	}
+
+	e.WriteBool(true)
+	return codec.EncodeTo(e, rv.Field(0))
+}
+
+// Decode decodes into a reflect value from the decoder.
+func (c *nullCodec) DecodeTo(d *Decoder, rv reflect.Value) (err error) {
+	var present byte
+	if present, err = d.r.ReadByte(); err != nil {
+		return
+	}
+
+	switch present {
+	case 0:
+		rv.Set(reflect.Zero(rv.Type()))
+		return nil
+	case 1:
+		var codec Codec
+		if codec, err = c.codec(); err != nil {
+			return
+		}
+
+		rv.Field(1).SetBool(true)
+		return codec.DecodeTo(d, rv.Field(0))
+	default:
+		return errors.New("binary: invalid presence byte for " + rv.Type().String())
+	}
+}
+
+// Size returns the encoded size of the value.
+func (c *nullCodec) Size(rv reflect.Value) int {
+	if !rv.Field(1).Bool() {
+		return 1
+	}
+
+	codec, err := c.codec()
+	if err != nil {
+		return -1
+	}
+
+	if n := sizeOf(codec, rv.Field(0)); n >= 0 {
+		return 1 + n
+	}
+	return -1
+}
+
+// codec returns the codec of the value.
+func (c *nullCodec) codec() (Codec, error) {
+	c.once.Do(func() {
+		c.elemCodec, c.err = scan(c.elemType)
+	})
+	return c.elemCodec, c.err
+}
@@ -0,0 +1,117 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package binary
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type nullableRow struct {
+	ID      int64
+	Name    Null[string]
+	Age     Null[int64]
+	Deleted Null[time.Time]
+}
+
+type pointerRow struct {
+	ID      int64
+	Name    *string
+	Age     *int64
+	Deleted *time.Time
+}
+
+func TestNull(t *testing.T) {
+	for _, v := range []nullableRow{
+		{ID: 1},
+		{ID: 2, Name: NullOf("Roman"), Age: NullOf(int64(-42))},
+		{ID: 3, Age: NullOf(int64(0)), Deleted: NullOf(time.Unix(1700000000, 0).UTC())},
+	} {
+		b, err := Marshal(&v)
+		assert.NoError(t, err)
+
+		size, err := Size(&v)
+		assert.NoError(t, err)
+		assert.Equal(t, len(b), size)
+
+		var out nullableRow
+		assert.NoError(t, Unmarshal(b, &out))
+		assert.Equal(t, v, out)
+	}
+}
+
+func TestNull_Pointer(t *testing.T) {
+	name := "Roman"
+	v := nullableRow{ID: 1, Name: NullOf(name)}
+	b, err := Marshal(&v)
+	assert.NoError(t, err)
+
+	// Nullable values are encoded like pointers, which are nil when the value is null
+	expect, err := Marshal(&pointerRow{ID: 1, Name: &name})
+	assert.NoError(t, err)
+	assert.Equal(t, expect, b)
+
+	var out pointerRow
+	assert.NoError(t, Unmarshal(b, &out))
+	assert.Equal(t, name, *out.Name)
+	assert.Nil(t, out.Age)
+}
+
+func TestNull_Reset(t *testing.T) {
+	b, err := Marshal(&nullableRow{ID: 1})
+	assert.NoError(t, err)
+
+	out := nullableRow{Name: NullOf("stale")}
+	assert.NoError(t, Unmarshal(b, &out))
+	assert.Equal(t, Null[string]{}, out.Name)
+
+	// Invalid presence bytes are rejected
+	assert.Error(t, Unmarshal([]byte{2}, &out.Name))
+}
+
+func TestNull_SQL(t *testing.T) {
+	var v Null[int64]
+	assert.NoError(t, v.Scan(int64(42)))
+	assert.Equal(t, NullOf(int64(42)), v)
+
+	value, err := v.Value()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(42), value)
+
+	assert.NoError(t, v.Scan(nil))
+	assert.False(t, v.Valid)
+
+	value, err = v.Value()
+	assert.NoError(t, err)
+	assert.Nil(t, value)
+}
+
+func TestNull_Schema(t *testing.T) {
+	s, err := SchemaOf(reflect.TypeOf(nullableRow{}))
+	assert.NoError(t, err)
+	assert.Equal(t, KindPointer, s.Fields[1].Schema.Kind)
+	assert.Equal(t, KindString, s.Fields[1].Schema.Elem.Kind)
+
+	b, err := Marshal(&nullableRow{ID: 1, Age: NullOf(int64(7))}, SelfDescribing())
+	assert.NoError(t, err)
+
+	out, err := DecodeAny(b, SelfDescribing())
+	assert.NoError(t, err)
+	fields := out.(map[string]interface{})
+	assert.Nil(t, fields["Name"])
+	assert.EqualValues(t, 7, fields["Age"])
+}
+
+func TestNull_Formats(t *testing.T) {
+	v := nullableRow{ID: 1, Name: NullOf("Roman")}
+	b, err := MarshalCBOR(&v)
+	assert.NoError(t, err)
+
+	var out nullableRow
+	assert.NoError(t, UnmarshalCBOR(b, &out))
+	assert.Equal(t, v, out)
+}
@@ -0,0 +1,107 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package binary
+
+import (
+	"reflect"
+)
+
+// The fields of structs tagged with `binary:",omitempty"` are only encoded when they are
+// not zero. Such structs are prefixed with a presence bitmap, which has a bit for each of
+// these fields in the order of the fields, from the lowest bit of the first byte. With the
+// Versioned option, the zero fields are simply left out instead, as they are decoded as
+// zero when they are missing.
+
+// presence returns the presence bitmap of the fields tagged with omitempty, which is empty
+// if the struct has none.
+func (c *reflectStructCodec) presence(rv reflect.Value, o *options, buffer []byte) ([]byte, error) {
+	bits, n := buffer[:0], 0
+	for _, i := range *c {
+		if !i.OmitEmpty {
+			continue
+		}
+
+		v, ok, err := i.access(rv, o)
+		switch {
+		case err != nil:
+			return nil, err
+		case !ok:
+			continue
+		}
+
+		if n%8 == 0 {
+			bits = append(bits, 0)
+		}
+		if !v.IsZero() {
+			bits[n/8] |= 1 << (n % 8)
+		}
+		n++
+	}
+	return bits, nil
+}
+
+// writePresence writes the presence bitmap through the scratch buffer, so that the bitmap
+// itself does not escape to the heap.
+func (e *Encoder) writePresence(bits []byte) {
+	for len(bits) > 0 {
+		n := copy(e.scratch[:], bits)
+		e.Write(e.scratch[:n])
+		bits = bits[n:]
+	}
+}
+
+// omitted returns the number of encoded fields tagged with omitempty.
+func (c *reflectStructCodec) omitted(o *options, t reflect.Type) (n int, err error) {
+	for _, i := range *c {
+		if !i.OmitEmpty {
+			continue
+		}
+
+		var ok bool
+		if ok, err = i.encoded(o, t); err != nil {
+			return
+		}
+		if ok {
+			n++
+		}
+	}
+	return
+}
+
+// readPresence reads the presence bitmap of a struct with a number of fields tagged
+// with omitempty.
+func (d *Decoder) readPresence(n int, buffer []byte) (bits []byte, err error) {
+	if n == 0 {
+		return buffer[:0], nil
+	}
+
+	if size := (n + 7) / 8; size <= len(buffer) {
+		bits = buffer[:size]
+	} else {
+		bits = make([]byte, size)
+	}
+
+	for i := range bits {
+		if bits[i], err = d.r.ReadByte(); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// present returns whether the field tagged with omitempty at an index of the bitmap
+// is present.
+func present(bits []byte, i int) bool {
+	return bits[i/8]&(1<<(i%8)) != 0
+}
+
+// omittedFields returns the number of fields of a struct schema tagged with omitempty.
+func omittedFields(s *Schema) (n int) {
+	for _, f := range s.Fields {
+		if f.OmitEmpty {
+			n++
+		}
+	}
+	return
+}
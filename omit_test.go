@@ -0,0 +1,126 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package binary
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type sparse struct {
+	ID     int                `binary:",omitempty"`
+	Name   string             `binary:",omitempty"`
+	Kind   uint8              // Always encoded
+	Tags   []string           `binary:",omitempty"`
+	Labels map[string]string  `binary:",omitempty"`
+	Score  float64            `binary:",omitempty"`
+	Next   *sparse            `binary:",omitempty"`
+	Inner  struct{ A, B int } `binary:",omitempty"`
+	Flag   bool               `binary:",omitempty"`
+	Extra  string             `binary:",omitempty"`
+}
+
+func TestOmitEmpty(t *testing.T) {
+	for _, opts := range [][]Option{nil, {Versioned()}, {InternStrings()}} {
+		empty, err := Marshal(&sparse{}, opts...)
+		assert.NoError(t, err)
+
+		v := &sparse{Name: "a", Kind: 1, Score: 1.5, Next: &sparse{ID: 2}, Extra: "b"}
+		b, err := Marshal(v, opts...)
+		assert.NoError(t, err)
+
+		size, err := Size(v, opts...)
+		assert.NoError(t, err)
+		assert.Equal(t, len(b), size)
+
+		// Fields which are not encoded are zeroed
+		out := &sparse{ID: 9, Tags: []string{"x"}, Flag: true}
+		assert.NoError(t, Unmarshal(b, out, opts...))
+		assert.Equal(t, v, out)
+
+		out = &sparse{ID: 9}
+		assert.NoError(t, Unmarshal(empty, out, opts...))
+		assert.Equal(t, &sparse{}, out)
+	}
+
+	// The zero struct only needs the bitmap of its 9 omitted fields and the kind
+	b, err := Marshal(&sparse{})
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{0, 0, 0}, b)
+
+	b, err = Marshal(&sparse{ID: 1, Extra: "x"})
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{0x01, 0x01, 2, 0, 1, 'x'}, b)
+}
+
+func TestOmitEmpty_Partial(t *testing.T) {
+	type record struct {
+		First  sparse
+		Second sparse
+	}
+
+	v := &record{
+		First:  sparse{Name: "a", Tags: []string{"b"}},
+		Second: sparse{ID: 1, Extra: "c"},
+	}
+	b, err := Marshal(v)
+	assert.NoError(t, err)
+
+	// Structs with omitted fields are skipped over
+	var out record
+	assert.NoError(t, Unmarshal(b, &out, Fields("Second.Extra")))
+	assert.Equal(t, record{Second: sparse{Extra: "c"}}, out)
+}
+
+func TestOmitEmpty_Merge(t *testing.T) {
+	for _, opts := range [][]Option{nil, {Versioned()}} {
+		overlay := &sparse{Name: "user", Kind: 2, Next: &sparse{Extra: "next"}}
+		b, err := Marshal(overlay, opts...)
+		assert.NoError(t, err)
+
+		// Only the fields present in the payload are overwritten
+		out := &sparse{ID: 9, Name: "default", Tags: []string{"x"}, Next: &sparse{ID: 3}, Flag: true}
+		assert.NoError(t, UnmarshalMerge(b, out, opts...))
+		assert.Equal(t, &sparse{
+			ID:   9,
+			Name: "user",
+			Kind: 2,
+			Tags: []string{"x"},
+			Next: &sparse{ID: 3, Extra: "next"},
+			Flag: true,
+		}, out)
+
+		// Without merging, the missing fields are reset
+		assert.NoError(t, Unmarshal(b, out, opts...))
+		assert.Equal(t, overlay, out)
+	}
+}
+
+func TestOmitEmpty_Schema(t *testing.T) {
+	v := &sparse{Name: "a", Kind: 1, Next: &sparse{ID: 2}}
+	b, err := Marshal(v, SelfDescribing())
+	assert.NoError(t, err)
+
+	out, err := DecodeAny(b, SelfDescribing())
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"Name": "a",
+		"Kind": uint64(1),
+		"Next": map[string]interface{}{"ID": int64(2), "Kind": uint64(0)},
+	}, out)
+
+	schema, err := SchemaOf(reflect.TypeOf(sparse{}))
+	assert.NoError(t, err)
+	assert.True(t, schema.Fields[0].OmitEmpty)
+	assert.False(t, schema.Fields[2].OmitEmpty)
+
+	var paths []string
+	assert.NoError(t, Walk(b, nil, func(t Token) error {
+		paths = append(paths, t.Path)
+		return nil
+	}, SelfDescribing()))
+	assert.Equal(t, []string{".Name", ".Kind", ".Next.ID", ".Next.Kind", ".Next", ".Next", ""}, paths)
+}
@@ -0,0 +1,269 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package binary
+
+import (
+	"errors"
+	"reflect"
+	"strconv"
+	"sync"
+)
+
+// oneOf is implemented by the tagged unions, which hold at most one of their alternatives,
+// numbered from 1 as 0 means that none is set.
+type oneOf interface {
+	which() int
+	slot(i int) interface{} // Returns a pointer to an alternative
+	reset()
+}
+
+// OneOf2 is a tagged union, which holds a value of either of two types, or none. It is
+// encoded as the number of the alternative in a single byte, followed by the value, which
+// gives sum types a compact encoding without registering the types like interfaces.
+type OneOf2[T1, T2 any] struct {
+	index uint8
+	v1    T1
+	v2    T2
+}
+
+// Index returns the number of the alternative which is set, from 1, or 0 if none is set.
+func (u OneOf2[T1, T2]) Index() int { return int(u.index) }
+
+// Value returns the value which is set, or nil if none is set.
+func (u OneOf2[T1, T2]) Value() interface{} { return valueOf(&u) }
+
+// Get1 returns the value of the first type and whether it is the one set.
+func (u OneOf2[T1, T2]) Get1() (T1, bool) { return u.v1, u.index == 1 }
+
+// Get2 returns the value of the second type and whether it is the one set.
+func (u OneOf2[T1, T2]) Get2() (T2, bool) { return u.v2, u.index == 2 }
+
+// Set1 sets the value of the first type.
+func (u *OneOf2[T1, T2]) Set1(v T1) { *u = OneOf2[T1, T2]{index: 1, v1: v} }
+
+// Set2 sets the value of the second type.
+func (u *OneOf2[T1, T2]) Set2(v T2) { *u = OneOf2[T1, T2]{index: 2, v2: v} }
+
+// GetBinaryCodec returns the codec of the union.
+func (u *OneOf2[T1, T2]) GetBinaryCodec() Codec {
+	return newOneOfCodec(reflect.TypeFor[T1](), reflect.TypeFor[T2]())
+}
+
+func (u *OneOf2[T1, T2]) which() int { return int(u.index) }
+func (u *OneOf2[T1, T2]) reset()     { *u = OneOf2[T1, T2]{} }
+func (u *OneOf2[T1, T2]) slot(i int) interface{} {
+	u.index = uint8(i)
+	return pick(i, &u.v1, &u.v2)
+}
+
+// ------------------------------------------------------------------------------
+
+// OneOf3 is a tagged union, which holds a value of one of three types, or none. It is
+// encoded like OneOf2.
+type OneOf3[T1, T2, T3 any] struct {
+	index uint8
+	v1    T1
+	v2    T2
+	v3    T3
+}
+
+// Index returns the number of the alternative which is set, from 1, or 0 if none is set.
+func (u OneOf3[T1, T2, T3]) Index() int { return int(u.index) }
+
+// Value returns the value which is set, or nil if none is set.
+func (u OneOf3[T1, T2, T3]) Value() interface{} { return valueOf(&u) }
+
+// Get1 returns the value of the first type and whether it is the one set.
+func (u OneOf3[T1, T2, T3]) Get1() (T1, bool) { return u.v1, u.index == 1 }
+
+// Get2 returns the value of the second type and whether it is the one set.
+func (u OneOf3[T1, T2, T3]) Get2() (T2, bool) { return u.v2, u.index == 2 }
+
+// Get3 returns the value of the third type and whether it is the one set.
+func (u OneOf3[T1, T2, T3]) Get3() (T3, bool) { return u.v3, u.index == 3 }
+
+// Set1 sets the value of the first type.
+func (u *OneOf3[T1, T2, T3]) Set1(v T1) { *u = OneOf3[T1, T2, T3]{index: 1, v1: v} }
+
+// Set2 sets the value of the second type.
+func (u *OneOf3[T1, T2, T3]) Set2(v T2) { *u = OneOf3[T1, T2, T3]{index: 2, v2: v} }
+
+// Set3 sets the value of the third type.
+func (u *OneOf3[T1, T2, T3]) Set3(v T3) { *u = OneOf3[T1, T2, T3]{index: 3, v3: v} }
+
+// GetBinaryCodec returns the codec of the union.
+func (u *OneOf3[T1, T2, T3]) GetBinaryCodec() Codec {
+	return newOneOfCodec(reflect.TypeFor[T1](), reflect.TypeFor[T2](), reflect.TypeFor[T3]())
+}
+
+func (u *OneOf3[T1, T2, T3]) which() int { return int(u.index) }
+func (u *OneOf3[T1, T2, T3]) reset()     { *u = OneOf3[T1, T2, T3]{} }
+func (u *OneOf3[T1, T2, T3]) slot(i int) interface{} {
+	u.index = uint8(i)
+	return pick(i, &u.v1, &u.v2, &u.v3)
+}
+
+// ------------------------------------------------------------------------------
+
+// OneOf4 is a tagged union, which holds a value of one of four types, or none. It is
+// encoded like OneOf2.
+type OneOf4[T1, T2, T3, T4 any] struct {
+	index uint8
+	v1    T1
+	v2    T2
+	v3    T3
+	v4    T4
+}
+
+// Index returns the number of the alternative which is set, from 1, or 0 if none is set.
+func (u OneOf4[T1, T2, T3, T4]) Index() int { return int(u.index) }
+
+// Value returns the value which is set, or nil if none is set.
+func (u OneOf4[T1, T2, T3, T4]) Value() interface{} { return valueOf(&u) }
+
+// Get1 returns the value of the first type and whether it is the one set.
+func (u OneOf4[T1, T2, T3, T4]) Get1() (T1, bool) { return u.v1, u.index == 1 }
+
+// Get2 returns the value of the second type and whether it is the one set.
+func (u OneOf4[T1, T2, T3, T4]) Get2() (T2, bool) { return u.v2, u.index == 2 }
+
+// Get3 returns the value of the third type and whether it is the one set.
+func (u OneOf4[T1, T2, T3, T4]) Get3() (T3, bool) { return u.v3, u.index == 3 }
+
+// Get4 returns the value of the fourth type and whether it is the one set.
+func (u OneOf4[T1, T2, T3, T4]) Get4() (T4, bool) { return u.v4, u.index == 4 }
+
+// Set1 sets the value of the first type.
+func (u *OneOf4[T1, T2, T3, T4]) Set1(v T1) { *u = OneOf4[T1, T2, T3, T4]{index: 1, v1: v} }
+
+// Set2 sets the value of the second type.
+func (u *OneOf4[T1, T2, T3, T4]) Set2(v T2) { *u = OneOf4[T1, T2, T3, T4]{index: 2, v2: v} }
+
+// Set3 sets the value of the third type.
+func (u *OneOf4[T1, T2, T3, T4]) Set3(v T3) { *u = OneOf4[T1, T2, T3, T4]{index: 3, v3: v} }
+
+// Set4 sets the value of the fourth type.
+func (u *OneOf4[T1, T2, T3, T4]) Set4(v T4) { *u = OneOf4[T1, T2, T3, T4]{index: 4, v4: v} }
+
+// GetBinaryCodec returns the codec of the union.
+func (u *OneOf4[T1, T2, T3, T4]) GetBinaryCodec() Codec {
+	return newOneOfCodec(reflect.TypeFor[T1](), reflect.TypeFor[T2](), reflect.TypeFor[T3](), reflect.TypeFor[T4]())
+}
+
+func (u *OneOf4[T1, T2, T3, T4]) which() int { return int(u.index) }
+func (u *OneOf4[T1, T2, T3, T4]) reset()     { *u = OneOf4[T1, T2, T3, T4]{} }
+func (u *OneOf4[T1, T2, T3, T4]) slot(i int) interface{} {
+	u.index = uint8(i)
+	return pick(i, &u.v1, &u.v2, &u.v3, &u.v4)
+}
+
+// ------------------------------------------------------------------------------
+
+// pick returns the pointer to the alternative with the number, from 1.
+func pick(i int, alternatives ...interface{}) interface{} {
+	return alternatives[i-1]
+}
+
+// valueOf returns the value which is set in a union, or nil if none is set.
+func valueOf(u oneOf) interface{} {
+	if i := u.which(); i > 0 {
+		return reflect.ValueOf(u.slot(i)).Elem().Interface()
+	}
+	return nil
+}
+
+// oneOfCodec represents a codec for the tagged unions.
+type oneOfCodec struct {
+	types  []reflect.Type // The types of the alternatives
+	codecs []Codec        // The codecs of the alternatives, scanned lazily to support recursive types
+	once   sync.Once
+	err    error
+}
+
+// newOneOfCodec creates a codec for a union of the types.
+func newOneOfCodec(types ...reflect.Type) *oneOfCodec {
+	return &oneOfCodec{types: types}
+}
+
+// codec returns the codec of the alternative with the number, from 1.
+func (c *oneOfCodec) codec(i int) (Codec, error) {
+	c.once.Do(func() {
+		c.codecs = make([]Codec, len(c.types))
+		for j, t := range c.types {
+			if c.codecs[j], c.err = scan(t); c.err != nil {
+				return
+			}
+		}
+	})
+	if c.err != nil {
+		return nil, c.err
+	}
+	return c.codecs[i-1], nil
+}
+
+// union returns the union of a reflect value, which is copied if it is not addressable.
+func (c *oneOfCodec) union(rv reflect.Value) oneOf {
+	if !rv.CanAddr() {
+		ptr := reflect.New(rv.Type())
+		ptr.Elem().Set(rv)
+		rv = ptr.Elem()
+	}
+	return rv.Addr().Interface().(oneOf)
+}
+
+// Encode encodes a value into the encoder.
+func (c *oneOfCodec) EncodeTo(e *Encoder, rv reflect.Value) error {
+	u := c.union(rv)
+	i := u.which()
+	e.WriteUint8(uint8(i))
+	if i == 0 {
+		return nil
+	}
+
+	codec, err := c.codec(i)
+	if err != nil {
+		return err
+	}
+	return codec.EncodeTo(e, reflect.ValueOf(u.slot(i)).Elem())
+}
+
+// Decode decodes into a reflect value from the decoder.
+func (c *oneOfCodec) DecodeTo(d *Decoder, rv reflect.Value) error {
+	i, err := d.ReadUint8()
+	switch {
+	case err != nil:
+		return err
+	case int(i) > len(c.types):
+		return errors.New("binary: invalid alternative " + strconv.Itoa(int(i)) + " for " + rv.Type().String())
+	}
+
+	u := rv.Addr().Interface().(oneOf)
+	if u.reset(); i == 0 {
+		return nil
+	}
+
+	codec, err := c.codec(int(i))
+	if err != nil {
+		return err
+	}
+	return codec.DecodeTo(d, reflect.ValueOf(u.slot(int(i))).Elem())
+}
+
+// Size returns the encoded size of the value.
+func (c *oneOfCodec) Size(rv reflect.Value) int {
+	u := c.union(rv)
+	i := u.which()
+	if i == 0 {
+		return 1
+	}
+
+	codec, err := c.codec(i)
+	if err != nil {
+		return -1
+	}
+	if n := sizeOf(codec, reflect.ValueOf(u.slot(i)).Elem()); n >= 0 {
+		return 1 + n
+	}
+	return -1
+}
@@ -0,0 +1,87 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package binary
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type shapeCircle struct {
+	Radius float64
+}
+
+type shapeRect struct {
+	Width, Height float64
+}
+
+type drawing struct {
+	Name   string
+	Shapes []OneOf3[shapeCircle, shapeRect, string]
+	Tag    OneOf2[int, string]
+}
+
+func TestOneOf(t *testing.T) {
+	var circle, rect, label OneOf3[shapeCircle, shapeRect, string]
+	circle.Set1(shapeCircle{Radius: 1})
+	rect.Set2(shapeRect{Width: 2, Height: 3})
+	label.Set3("hello")
+
+	in := drawing{
+		Name:   "canvas",
+		Shapes: []OneOf3[shapeCircle, shapeRect, string]{circle, rect, label, {}},
+	}
+	in.Tag.Set2("draft")
+
+	b, err := Marshal(&in)
+	assert.NoError(t, err)
+	assert.NoError(t, Verify(&in))
+
+	var out drawing
+	assert.NoError(t, Unmarshal(b, &out))
+	assert.Equal(t, in, out)
+
+	v, ok := out.Shapes[1].Get2()
+	assert.True(t, ok)
+	assert.Equal(t, shapeRect{Width: 2, Height: 3}, v)
+	assert.Equal(t, 3, out.Shapes[2].Index())
+	assert.Equal(t, "hello", out.Shapes[2].Value())
+	assert.Equal(t, 0, out.Shapes[3].Index())
+	assert.Nil(t, out.Shapes[3].Value())
+
+	_, ok = out.Tag.Get1()
+	assert.False(t, ok)
+}
+
+func TestOneOf_Encoding(t *testing.T) {
+	var u OneOf2[uint8, string]
+	u.Set2("a")
+
+	b, err := Marshal(&u)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{2, 1, 'a'}, b)
+
+	// Decoding replaces the alternative which was set
+	out := OneOf2[uint8, string]{}
+	out.Set1(42)
+	assert.NoError(t, Unmarshal(b, &out))
+	assert.Equal(t, u, out)
+
+	// Unknown alternatives are rejected
+	assert.Error(t, Unmarshal([]byte{3, 1}, &out))
+}
+
+func TestOneOf4(t *testing.T) {
+	var u OneOf4[int, string, bool, []float64]
+	u.Set4([]float64{1.5, 2.5})
+
+	b, err := Marshal(&u)
+	assert.NoError(t, err)
+
+	var out OneOf4[int, string, bool, []float64]
+	out.Set3(true)
+	assert.NoError(t, Unmarshal(b, &out))
+	assert.Equal(t, u, out)
+}
@@ -0,0 +1,283 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package binary
+
+import (
+	"context"
+	"encoding/binary"
+)
+
+// Option represents an option which configures an encoder or a decoder. Options which
+// are not relevant to one of them are simply ignored, so the same set of options can
+// be used on both sides of the wire.
+type Option func(*options)
+
+// options represents the configuration of an encoder or a decoder.
+type options struct {
+	sortKeys       bool             // Whether map keys should be sorted
+	canonical      bool             // Whether floating-point numbers should be normalized
+	bigEndian      bool             // Whether fixed-width values are in big-endian byte order
+	selfDescribing bool             // Whether values are prefixed with their schema
+	checksum       bool             // Whether values are followed by their checksum
+	intern         bool             // Whether repeated strings are written as references
+	shared         bool             // Whether repeated pointers are written as references
+	zeroCopy       bool             // Whether decoded strings and byte slices point into the input
+	freshPointers  bool             // Whether decoded pointers are newly allocated rather than reused
+	validUTF8      bool             // Whether decoded strings must be valid UTF-8
+	strict         bool             // Whether decoding fails if the input is not entirely consumed
+	unexported     UnexportedPolicy // How the unexported fields of structs are handled
+	jsonTags       bool             // Whether json tags select and name the fields of structs
+	versioned      bool             // Whether structs are encoded with field identifiers
+	fileVersioned  bool             // Whether the saved files start with a version header
+	merge          bool             // Whether decoding leaves the fields missing from the payload untouched
+	maxSliceLen    int              // The maximum length of a slice or a map, if positive
+	maxStringLen   int              // The maximum length of a string, if positive
+	maxDepth       int              // The maximum nesting depth, if positive
+	maxMessage     int              // The maximum size of a framed message, if positive
+	maxAlloc       int              // The maximum number of bytes allocated by a decoding, if positive
+	fields         projection       // The fields to decode, or nil to decode all of them
+	compressor     Compressor       // The compressor of the encoded values, if any
+	threshold      int              // The minimum size of the encoded values to compress
+	ctx            context.Context  // The context which aborts encoding and decoding once done, if any
+	arena          *Arena           // The arena the decoded values are allocated from, if any
+	fileVersion    uint64           // The version written in the header of the saved files
+}
+
+// reset resets the configuration and applies a set of options on top of it.
+func (o *options) reset(opts []Option) {
+	*o = options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+}
+
+// The number of elements of slices and maps between two checks of the context
+const cancelInterval = 1024
+
+// canceled returns the error of the context once it is done. Within slices and maps, the
+// context is only checked every few elements, given the index of the element.
+func (o *options) canceled(i int) error {
+	if o.ctx == nil || i%cancelInterval != 0 {
+		return nil
+	}
+	return o.ctx.Err()
+}
+
+// withContext sets the context which aborts encoding and decoding once done.
+func withContext(ctx context.Context) Option {
+	return func(o *options) {
+		o.ctx = ctx
+	}
+}
+
+// sizable returns whether the Sizer implementations of the codecs can be used to
+// compute the encoded size, as some options change the wire format.
+func (o *options) sizable() bool {
+	return !o.versioned && !o.intern && !o.shared && !o.selfDescribing && o.compressor == nil &&
+		o.unexported == UnexportedSkip && !o.jsonTags && !o.canonical
+}
+
+// generated returns whether the code generated by binarygen supports the options, as it
+// only encodes the exported fields of structs positionally.
+func (o *options) generated() bool {
+	return !o.versioned && o.unexported == UnexportedSkip && !o.jsonTags
+}
+
+// schema returns the options which change the schema of the encoded values.
+func (o *options) schema() []Option {
+	opts := []Option{Unexported(o.unexported)}
+	if o.jsonTags {
+		opts = append(opts, JSONTags())
+	}
+	return opts
+}
+
+// Deterministic sorts the keys of maps when encoding, so that equal values are always
+// encoded into the same bytes. This is useful when the output needs to be hashed or
+// signed, at the expense of encoding maps more slowly. Maps whose keys are compared by
+// their address, such as pointers, fail to encode since their order is not stable.
+func Deterministic() Option {
+	return func(o *options) {
+		o.sortKeys = true
+	}
+}
+
+// Canonical guarantees that equal values are always encoded into the same bytes, which
+// is required for content addressing or signatures. On top of sorting the keys of maps
+// like Deterministic, it normalizes floating-point numbers, so that every NaN is encoded
+// with the same bits and negative zero is encoded as zero.
+func Canonical() Option {
+	return func(o *options) {
+		o.sortKeys = true
+		o.canonical = true
+	}
+}
+
+// InternStrings writes every distinct string only once per encoded value, and repeated
+// occurrences as a reference to the first one, which shrinks payloads containing many
+// repetitive strings such as labels or enumerations. Both sides must use this option.
+func InternStrings() Option {
+	return func(o *options) {
+		o.intern = true
+	}
+}
+
+// ValidUTF8 fails decoding with ErrInvalidUTF8 if a string is not valid UTF-8, which is
+// useful at API boundaries. By default, strings are decoded as they are, which suits
+// internal blobs. Either way, strings and byte slices share the same wire format, so one
+// can be decoded as the other, unless the InternStrings option is used. Byte slices are
+// never validated, so fields holding arbitrary bytes should be declared as []byte.
+func ValidUTF8() Option {
+	return func(o *options) {
+		o.validUTF8 = true
+	}
+}
+
+// Strict fails decoding with ErrTrailingBytes if the input holds more bytes than the
+// decoded value, which usually reveals a framing bug or a mismatch between the types on
+// both sides. This applies to the byte slices given to Unmarshal, as well as to framed
+// messages and compressed values, but not to streams which hold a sequence of values.
+func Strict() Option {
+	return func(o *options) {
+		o.strict = true
+	}
+}
+
+// ZeroCopy decodes strings and byte slices without copying them, pointing into the
+// input buffer of Unmarshal instead, which avoids most of the allocations when decoding.
+// The input buffer must not be modified or reused afterwards for as long as the decoded
+// value is in use, since strings would change and break their immutability. Decoded byte
+// slices have their capacity limited to their length, so appending to them copies. The
+// slices of floating-point numbers and of the other values which are copied as they are
+// point into the input as well, if it is aligned for their elements, such as the data of
+// a MappedFile. When decoding from a stream, the bytes are read into a new buffer, so
+// this is safe.
+func ZeroCopy() Option {
+	return func(o *options) {
+		o.zeroCopy = true
+	}
+}
+
+// Merge leaves the fields of structs which are missing from the payload untouched when
+// decoding, instead of resetting them. These are the fields tagged with omitempty which
+// hold their zero value, and the fields missing from payloads encoded with the Versioned
+// option. This allows overlaying a partial value, such as the settings of a user, on top
+// of a complete one, such as the default configuration. Nested structs are merged field
+// by field, while other values present in the payload, such as slices and maps, replace
+// the ones of the destination.
+func Merge() Option {
+	return func(o *options) {
+		o.merge = true
+	}
+}
+
+// FreshPointers allocates a new value for every decoded pointer. By default, a pointer
+// which is not nil in the destination is reused, and the value is decoded into the one it
+// points to, which allows pooling nested structs. With this option, the values which the
+// destination pointed to are left untouched, so that they can be retained elsewhere.
+func FreshPointers() Option {
+	return func(o *options) {
+		o.freshPointers = true
+	}
+}
+
+// ByteOrder selects the byte order of the fixed-width values, which is LittleEndian by
+// default. This applies to floating-point and complex numbers, integers with a `fixed`
+// tag and the integer keys of maps, so that payloads can match existing C structs or
+// network protocols with BigEndian. The same order must be used on both sides.
+func ByteOrder(order binary.ByteOrder) Option {
+	var probe [2]byte
+	order.PutUint16(probe[:], 1)
+	return func(o *options) {
+		o.bigEndian = probe[0] == 0
+	}
+}
+
+// Versioned encodes every struct as a sequence of (identifier, length, value) triplets,
+// so that a decoder skips the fields it does not know about and leaves the fields which
+// are missing from the payload zeroed. This allows adding or removing fields without
+// breaking existing payloads, at the expense of a slightly larger payload. Identifiers
+// are assigned with the `binary:",id=N"` tag and should be specified explicitly, as
+// otherwise they depend on the order of declaration. Both sides must use this option.
+func Versioned() Option {
+	return func(o *options) {
+		o.versioned = true
+	}
+}
+
+// MaxSliceLen limits the number of elements of the slices and maps a decoder accepts,
+// including byte slices. Larger payloads fail with ErrLimitExceeded before allocating.
+func MaxSliceLen(n int) Option {
+	return func(o *options) {
+		o.maxSliceLen = n
+	}
+}
+
+// MaxStringLen limits the length of the strings a decoder accepts. Longer strings fail
+// with ErrLimitExceeded before allocating.
+func MaxStringLen(n int) Option {
+	return func(o *options) {
+		o.maxStringLen = n
+	}
+}
+
+// MaxDepth limits how deeply structs, interfaces and collections can be nested
+// within each other, which protects against stack exhaustion with recursive types. Deeper
+// payloads fail with ErrLimitExceeded.
+func MaxDepth(n int) Option {
+	return func(o *options) {
+		o.maxDepth = n
+	}
+}
+
+// MaxMessageSize limits the size of the messages framed with their length, which are
+// decoded with DecodeMessage. Larger messages fail with ErrLimitExceeded before their
+// content is read.
+func MaxMessageSize(n int) Option {
+	return func(o *options) {
+		o.maxMessage = n
+	}
+}
+
+// MaxAllocation limits the total number of bytes a decoder allocates for the slices,
+// maps, strings and pointers of a single value, which fails with ErrBudgetExceeded before
+// allocating over the budget. Unlike the limits of individual lengths, this bounds the
+// memory a payload can claim overall, for example when decoding untrusted blobs for many
+// tenants in the same process.
+func MaxAllocation(n int) Option {
+	return func(o *options) {
+		o.maxAlloc = n
+	}
+}
+
+// UnexportedPolicy defines how the unexported fields of structs are handled.
+type UnexportedPolicy uint8
+
+// The policies for the unexported fields of structs
+const (
+	UnexportedSkip    UnexportedPolicy = iota // Unexported fields are silently skipped (default)
+	UnexportedError                           // Structs with unexported fields fail to encode and decode
+	UnexportedInclude                         // Unexported fields are encoded, accessing them with unsafe
+)
+
+// Unexported sets the policy for the unexported fields of structs. By default, they are
+// skipped like with encoding/json and encoding/gob. Including them bypasses the visibility
+// rules of the language, so it is best used with types you own. Both sides must use the
+// same policy.
+func Unexported(policy UnexportedPolicy) Option {
+	return func(o *options) {
+		o.unexported = policy
+	}
+}
+
+// JSONTags honors the json tags of the fields of structs which have no binary tag, so
+// that existing types can be encoded without tagging them again. Fields tagged with
+// `json:"-"` are skipped, and the names of the json tags are used by the Fields option,
+// the schemas and DecodeAny. Other json options, such as omitempty, are ignored. Both
+// sides must use this option.
+func JSONTags() Option {
+	return func(o *options) {
+		o.jsonTags = true
+	}
+}
@@ -0,0 +1,583 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package binary
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+	"unsafe"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type versionedInner struct {
+	X int `binary:",id=1"`
+}
+
+type versionedV1 struct {
+	Name  string         `binary:",id=1"`
+	Age   int            `binary:",id=2"`
+	Inner versionedInner `binary:",id=3"`
+}
+
+type versionedV2 struct {
+	Name  string         `binary:",id=1"`
+	Inner versionedInner `binary:",id=3"`
+	Tags  []string       `binary:",id=4"`
+}
+
+func TestVersioned(t *testing.T) {
+	v1 := versionedV1{Name: "Roman", Age: 30, Inner: versionedInner{X: 5}}
+	b, err := Marshal(&v1, Versioned())
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{
+		0x1, 0x6, 0x5, 'R', 'o', 'm', 'a', 'n',
+		0x2, 0x1, 0x3c,
+		0x3, 0x4, 0x1, 0x1, 0xa, 0x0,
+		0x0,
+	}, b)
+
+	n, err := Size(&v1, Versioned())
+	assert.NoError(t, err)
+	assert.Equal(t, len(b), n)
+
+	// Newer decoder skips the removed field and leaves the new one empty
+	v2 := versionedV2{Tags: []string{"existing"}}
+	assert.NoError(t, Unmarshal(b, &v2, Versioned()))
+	assert.Equal(t, versionedV2{Name: "Roman", Inner: versionedInner{X: 5}}, v2)
+
+	// Older decoder skips the new field
+	v2.Tags = []string{"a", "b"}
+	b, err = Marshal(&v2, Versioned())
+	assert.NoError(t, err)
+
+	var out versionedV1
+	assert.NoError(t, Unmarshal(b, &out, Versioned()))
+	assert.Equal(t, versionedV1{Name: "Roman", Inner: versionedInner{X: 5}}, out)
+}
+
+type versionedV3 struct {
+	Name    string         `binary:",id=1"`
+	Retries int            `binary:",id=5,default=3"`
+	Ratio   float64        `binary:",id=6,default=0.5"`
+	Enabled bool           `binary:",id=7,default=true"`
+	Region  string         `binary:",id=8,default=eu-west"`
+	Tags    []string       `binary:",id=4"`
+	Inner   versionedInner `binary:",id=3"`
+}
+
+// SetDefaults sets the tags which are missing from older payloads.
+func (v *versionedV3) SetDefaults() {
+	v.Tags = []string{"default"}
+}
+
+func TestVersioned_Defaults(t *testing.T) {
+	b, err := Marshal(&versionedV1{Name: "Roman", Inner: versionedInner{X: 5}}, Versioned())
+	assert.NoError(t, err)
+
+	// Missing fields get the defaults of their tag and of the Defaulter
+	out := versionedV3{Retries: 10, Region: "us"}
+	assert.NoError(t, Unmarshal(b, &out, Versioned()))
+	assert.Equal(t, versionedV3{
+		Name:    "Roman",
+		Retries: 3,
+		Ratio:   0.5,
+		Enabled: true,
+		Region:  "eu-west",
+		Tags:    []string{"default"},
+		Inner:   versionedInner{X: 5},
+	}, out)
+
+	// Fields present in the payload are decoded as usual
+	in := versionedV3{Name: "Roman", Retries: 0, Region: "us", Tags: []string{"a"}}
+	b, err = Marshal(&in, Versioned())
+	assert.NoError(t, err)
+	assert.NoError(t, Unmarshal(b, &out, Versioned()))
+	assert.Equal(t, in, out)
+
+	// Invalid defaults are reported when scanning the type
+	type invalid struct {
+		Count int `binary:",default=many"`
+	}
+	_, err = Marshal(&invalid{})
+	assert.Error(t, err)
+}
+
+func TestVersioned_Truncated(t *testing.T) {
+	b, err := Marshal(&versionedV1{Name: "Roman"}, Versioned())
+	assert.NoError(t, err)
+
+	var out versionedV1
+	assert.Error(t, Unmarshal(b[:4], &out, Versioned()))
+}
+
+func TestCanonical(t *testing.T) {
+	type value struct {
+		F32 float32
+		F64 float64
+		C   complex128
+		M   map[float64]string
+		S   []float32
+	}
+
+	nan32 := math.Float32frombits(0x7fc00001)
+	nan64 := math.Float64frombits(0xfff8000000000001)
+	negZero := math.Copysign(0, -1)
+
+	a := value{
+		F32: nan32,
+		F64: negZero,
+		C:   complex(nan64, negZero),
+		M:   map[float64]string{1: "a", 2: "b", 3: "c", 4: "d"},
+		S:   []float32{float32(negZero), nan32},
+	}
+	b := value{
+		F32: float32(math.NaN()),
+		F64: 0,
+		C:   complex(math.NaN(), 0),
+		M:   map[float64]string{4: "d", 3: "c", 2: "b", 1: "a"},
+		S:   []float32{0, float32(math.NaN())},
+	}
+
+	ea, err := Marshal(&a, Canonical())
+	assert.NoError(t, err)
+	eb, err := Marshal(&b, Canonical())
+	assert.NoError(t, err)
+	assert.Equal(t, ea, eb)
+
+	// Without the option, the bits are preserved
+	ea, err = Marshal(&a)
+	assert.NoError(t, err)
+	var out value
+	assert.NoError(t, Unmarshal(ea, &out))
+	assert.Equal(t, math.Float32bits(nan32), math.Float32bits(out.F32))
+	assert.True(t, math.Signbit(out.F64))
+}
+
+func TestInternStrings(t *testing.T) {
+	type record struct {
+		Level  string
+		Source string
+		Labels map[string]string
+		Tags   []string
+		Empty  string
+	}
+
+	input := make([]record, 100)
+	for i := range input {
+		input[i] = record{
+			Level:  "info",
+			Source: "service",
+			Labels: map[string]string{"region": "eu-west", "zone": "a"},
+			Tags:   []string{"info", "production"},
+		}
+	}
+
+	plain, err := Marshal(&input)
+	assert.NoError(t, err)
+
+	interned, err := Marshal(&input, InternStrings())
+	assert.NoError(t, err)
+	assert.True(t, len(interned) < len(plain)/3, "%d vs %d", len(interned), len(plain))
+
+	size, err := Size(&input, InternStrings())
+	assert.NoError(t, err)
+	assert.Equal(t, len(interned), size)
+
+	var output []record
+	assert.NoError(t, Unmarshal(interned, &output, InternStrings()))
+	assert.Equal(t, input, output)
+
+	// The table is scoped to a single value
+	first, err := Marshal(&input, InternStrings(), Deterministic())
+	assert.NoError(t, err)
+	again, err := Marshal(&input, InternStrings(), Deterministic())
+	assert.NoError(t, err)
+	assert.Equal(t, first, again)
+}
+
+func TestInternStringsVersioned(t *testing.T) {
+	type v1 struct {
+		A string `binary:",id=1"`
+		B string `binary:",id=2"`
+		C string `binary:",id=3"`
+	}
+	type v2 struct {
+		A string `binary:",id=1"`
+		C string `binary:",id=3"`
+	}
+
+	b, err := Marshal(&v1{A: "x", B: "y", C: "y"}, InternStrings(), Versioned())
+	assert.NoError(t, err)
+
+	var out v2
+	assert.NoError(t, Unmarshal(b, &out, InternStrings(), Versioned()))
+	assert.Equal(t, v2{A: "x", C: "y"}, out)
+}
+
+func TestInternStringsInvalid(t *testing.T) {
+	var out []string
+	assert.Error(t, Unmarshal([]byte{0x2, 0x2, 'a', 0x3}, &out, InternStrings()))
+	assert.True(t, errors.Is(Unmarshal([]byte{0x1, 0x8, 'a', 'b', 'c', 'd'}, &out, InternStrings(), MaxStringLen(2)), ErrLimitExceeded))
+}
+
+func TestInternStringsStream(t *testing.T) {
+	var buffer bytes.Buffer
+	e := NewEncoder(&buffer, InternStrings())
+	assert.NoError(t, e.Encode([]string{"a", "a"}))
+	assert.NoError(t, e.Encode([]string{"a", "a"}))
+	assert.NoError(t, e.EncodeMessage([]string{"a", "a"}))
+
+	d := NewDecoder(&buffer, InternStrings())
+	for i := 0; i < 2; i++ {
+		var out []string
+		assert.NoError(t, d.Decode(&out))
+		assert.Equal(t, []string{"a", "a"}, out)
+	}
+
+	var out []string
+	assert.NoError(t, d.DecodeMessage(&out))
+	assert.Equal(t, []string{"a", "a"}, out)
+}
+
+func TestStrict(t *testing.T) {
+	b, err := Marshal(&versionedV1{Name: "Roman", Age: 30})
+	assert.NoError(t, err)
+
+	// Trailing bytes are only rejected with the option
+	var out versionedV1
+	padded := append(b, 0, 0)
+	assert.NoError(t, Unmarshal(padded, &out))
+	assert.NoError(t, Unmarshal(b, &out, Strict()))
+	err = Unmarshal(padded, &out, Strict())
+	assert.True(t, errors.Is(err, ErrTrailingBytes))
+	assert.Contains(t, err.Error(), "2 bytes")
+
+	// Framed messages must be consumed entirely
+	var buffer bytes.Buffer
+	buffer.Write([]byte{byte(len(padded))})
+	buffer.Write(padded)
+	buffer.Write([]byte{byte(len(b))})
+	buffer.Write(b)
+
+	d := NewDecoder(&buffer, Strict())
+	assert.True(t, errors.Is(d.DecodeMessage(&out), ErrTrailingBytes))
+	assert.NoError(t, d.DecodeMessage(&out))
+	assert.Equal(t, "Roman", out.Name)
+
+	// So are compressed values
+	c, err := Marshal(&versionedV1{Name: strings.Repeat("a", 100)}, Compression(flateCompressor{}, 0))
+	assert.NoError(t, err)
+	assert.NoError(t, Unmarshal(c, &out, Compression(flateCompressor{}, 0), Strict()))
+}
+
+func TestZeroCopy(t *testing.T) {
+	type value struct {
+		Name    string
+		Payload []byte
+		Labels  map[string]string
+		Empty   []byte
+	}
+
+	input := value{
+		Name:    "hello",
+		Payload: []byte{1, 2, 3},
+		Labels:  map[string]string{"key": "value"},
+	}
+
+	b, err := Marshal(&input)
+	assert.NoError(t, err)
+
+	var out value
+	assert.NoError(t, Unmarshal(b, &out, ZeroCopy()))
+	assert.Equal(t, input, out)
+
+	// Decoded values point into the input buffer
+	within := func(p *byte) bool {
+		addr := uintptr(unsafe.Pointer(p))
+		start := uintptr(unsafe.Pointer(&b[0]))
+		return addr >= start && addr < start+uintptr(len(b))
+	}
+	assert.True(t, within(unsafe.StringData(out.Name)))
+	assert.True(t, within(&out.Payload[0]))
+	assert.Equal(t, len(out.Payload), cap(out.Payload))
+	for k, v := range out.Labels {
+		assert.True(t, within(unsafe.StringData(k)))
+		assert.True(t, within(unsafe.StringData(v)))
+	}
+
+	// Without the option, decoded values are copied
+	var copied value
+	assert.NoError(t, Unmarshal(b, &copied))
+	assert.False(t, within(unsafe.StringData(copied.Name)))
+	assert.False(t, within(&copied.Payload[0]))
+
+	// Decoding from a stream reads into a new buffer
+	var streamed value
+	assert.NoError(t, NewDecoder(iotest.OneByteReader(bytes.NewReader(b)), ZeroCopy()).Decode(&streamed))
+	assert.Equal(t, input, streamed)
+	assert.False(t, within(unsafe.StringData(streamed.Name)))
+}
+
+func TestFreshPointers(t *testing.T) {
+	type inner struct {
+		Name string
+	}
+	type outer struct {
+		Inner *inner
+	}
+
+	b, err := Marshal(&outer{Inner: &inner{Name: "new"}})
+	assert.NoError(t, err)
+
+	// By default, the value pointed to is decoded into
+	kept := &inner{Name: "old"}
+	out := outer{Inner: kept}
+	assert.NoError(t, Unmarshal(b, &out))
+	assert.True(t, kept == out.Inner)
+	assert.Equal(t, "new", kept.Name)
+
+	// With the option, a new value is allocated
+	kept = &inner{Name: "old"}
+	out = outer{Inner: kept}
+	assert.NoError(t, Unmarshal(b, &out, FreshPointers()))
+	assert.False(t, kept == out.Inner)
+	assert.Equal(t, "old", kept.Name)
+	assert.Equal(t, "new", out.Inner.Name)
+}
+
+func TestZeroCopyInterned(t *testing.T) {
+	input := []string{"a", "b", "a"}
+	b, err := Marshal(&input, InternStrings())
+	assert.NoError(t, err)
+
+	var out []string
+	assert.NoError(t, Unmarshal(b, &out, InternStrings(), ZeroCopy()))
+	assert.Equal(t, input, out)
+}
+
+func TestUnexported(t *testing.T) {
+	type inner struct {
+		secret string
+		Public int
+	}
+
+	type value struct {
+		Name    string
+		count   int
+		inner   inner
+		Ch      int
+		created time.Time
+		channel chan int // Unsupported, but skipped by default
+	}
+
+	v := value{Name: "a", count: 5, inner: inner{"x", 7}, Ch: 1, created: time.Unix(1, 0).UTC()}
+
+	// Unexported fields are skipped by default
+	b, err := Marshal(&v)
+	assert.NoError(t, err)
+	expect, err := Marshal(&struct {
+		Name string
+		Ch   int
+	}{"a", 1})
+	assert.NoError(t, err)
+	assert.Equal(t, expect, b)
+
+	size, err := Size(&v)
+	assert.NoError(t, err)
+	assert.Equal(t, len(b), size)
+
+	var out value
+	assert.NoError(t, Unmarshal(b, &out))
+	assert.Equal(t, value{Name: "a", Ch: 1}, out)
+
+	// The error policy refuses structs with unexported fields
+	_, err = Marshal(&v, Unexported(UnexportedError))
+	assert.Error(t, err)
+	assert.Error(t, Unmarshal(b, &out, Unexported(UnexportedError)))
+
+	// Unsupported unexported fields fail when included
+	_, err = Marshal(&v, Unexported(UnexportedInclude))
+	assert.Error(t, err)
+}
+
+func TestUnexportedInclude(t *testing.T) {
+	type inner struct {
+		secret string
+		Public int
+	}
+
+	type value struct {
+		Name    string
+		count   int
+		inner   inner
+		created time.Time
+		tags    map[string]inner
+	}
+
+	v := value{
+		Name:    "a",
+		count:   5,
+		inner:   inner{"x", 7},
+		created: time.Unix(1, 0).UTC(),
+		tags:    map[string]inner{"k": {"y", 8}},
+	}
+
+	for _, opts := range [][]Option{
+		{Unexported(UnexportedInclude)},
+		{Unexported(UnexportedInclude), Versioned()},
+	} {
+		b, err := Marshal(&v, opts...)
+		assert.NoError(t, err)
+
+		size, err := Size(&v, opts...)
+		assert.NoError(t, err)
+		assert.Equal(t, len(b), size)
+
+		var out value
+		assert.NoError(t, Unmarshal(b, &out, opts...))
+		assert.Equal(t, v, out)
+	}
+}
+
+func TestByteOrder(t *testing.T) {
+	type T struct {
+		A uint32 `binary:",fixed"`
+		B int16  `binary:",fixed"`
+		C float32
+		D complex64
+		E map[uint16]float64
+		F []float32
+		G uint32
+	}
+
+	v := T{A: 1, B: -2, C: 1, D: complex(1, -1), E: map[uint16]float64{1: 2}, F: []float32{1, 2}, G: 300}
+	b, err := Marshal(&v, ByteOrder(BigEndian))
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{
+		0x0, 0x0, 0x0, 0x1,
+		0xff, 0xfe,
+		0x3f, 0x80, 0x0, 0x0,
+		0x3f, 0x80, 0x0, 0x0, 0xbf, 0x80, 0x0, 0x0,
+		0x1, 0x0, 0x1, 0x40, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0,
+		0x2, 0x3f, 0x80, 0x0, 0x0, 0x40, 0x0, 0x0, 0x0,
+		0xac, 0x2, // Variable-size integers are not affected
+	}, b)
+
+	n, err := Size(&v, ByteOrder(BigEndian))
+	assert.NoError(t, err)
+	assert.Equal(t, len(b), n)
+
+	var out T
+	assert.NoError(t, Unmarshal(b, &out, ByteOrder(BigEndian)))
+	assert.Equal(t, v, out)
+
+	// Little-endian is the default
+	little, err := Marshal(&v, ByteOrder(LittleEndian))
+	assert.NoError(t, err)
+	expect, err := Marshal(&v)
+	assert.NoError(t, err)
+	assert.Equal(t, expect, little)
+	assert.NotEqual(t, b, little)
+}
+
+func TestValidUTF8(t *testing.T) {
+	invalid := []byte{'a', 0xff, 'b'}
+	b, err := Marshal(&invalid)
+	assert.NoError(t, err)
+
+	// Byte slices and strings are interchangeable on the wire
+	var s string
+	assert.NoError(t, Unmarshal(b, &s))
+	assert.Equal(t, string(invalid), s)
+	assert.Equal(t, ErrInvalidUTF8, Unmarshal(b, &s, ValidUTF8()))
+
+	var raw []byte
+	assert.NoError(t, Unmarshal(b, &raw, ValidUTF8()))
+	assert.Equal(t, invalid, raw)
+
+	// Interned strings, map keys and nested strings are validated as well
+	for _, opts := range [][]Option{{ValidUTF8()}, {ValidUTF8(), InternStrings()}} {
+		b, err := Marshal(map[string][]string{string(invalid): {"ok"}}, opts...)
+		assert.NoError(t, err)
+		var out map[string][]string
+		assert.Equal(t, ErrInvalidUTF8, Unmarshal(b, &out, opts...))
+
+		b, err = Marshal([]string{"ok", "héllo", string(invalid)}, opts...)
+		assert.NoError(t, err)
+		var list []string
+		assert.Equal(t, ErrInvalidUTF8, Unmarshal(b, &list, opts...))
+
+		b, err = Marshal([]string{"ok", "héllo"}, opts...)
+		assert.NoError(t, err)
+		assert.NoError(t, Unmarshal(b, &list, opts...))
+		assert.Equal(t, []string{"ok", "héllo"}, list)
+	}
+}
+
+func TestJSONTags(t *testing.T) {
+	type meta struct {
+		Source string `json:"source"`
+	}
+
+	type secret struct {
+		Token string
+	}
+
+	type user struct {
+		ID       int    `json:"id,omitempty"`
+		Name     string `json:"name"`
+		Password string `json:"-"`
+		Email    string `json:"email" binary:"mail"`
+		Note     string `json:"-" binary:""`
+		meta
+		secret `json:"-"`
+	}
+
+	v := user{ID: 1, Name: "Roman", Password: "hunter2", Email: "a@b.c", Note: "n",
+		meta: meta{"web"}, secret: secret{"t"}}
+
+	// The json tags are ignored by default
+	plain, err := Marshal(&v)
+	assert.NoError(t, err)
+	b, err := Marshal(&v, JSONTags())
+	assert.NoError(t, err)
+	assert.NotContains(t, string(b), "hunter2")
+	assert.NotContains(t, string(b), "\x01t")
+	assert.True(t, len(b) < len(plain))
+
+	size, err := Size(&v, JSONTags())
+	assert.NoError(t, err)
+	assert.Equal(t, len(b), size)
+
+	var out user
+	assert.NoError(t, Unmarshal(b, &out, JSONTags()))
+	assert.Equal(t, user{ID: 1, Name: "Roman", Email: "a@b.c", Note: "n", meta: meta{"web"}}, out)
+
+	// The names of the json tags are used, unless there is a binary tag
+	schema, err := SchemaOf(reflect.TypeOf(v), JSONTags())
+	assert.NoError(t, err)
+	var names []string
+	for _, f := range schema.Fields {
+		names = append(names, f.Name)
+	}
+	assert.Equal(t, []string{"id", "name", "mail", "Note", "source"}, names)
+
+	out = user{}
+	assert.NoError(t, Unmarshal(b, &out, JSONTags(), Fields("name")))
+	assert.Equal(t, user{Name: "Roman"}, out)
+
+	// Versioned structs skip the same fields
+	b, err = Marshal(&v, JSONTags(), Versioned())
+	assert.NoError(t, err)
+	out = user{Password: "kept"}
+	assert.NoError(t, Unmarshal(b, &out, JSONTags(), Versioned()))
+	assert.Equal(t, user{ID: 1, Name: "Roman", Password: "kept", Email: "a@b.c", Note: "n", meta: meta{"web"}}, out)
+}
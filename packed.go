@@ -0,0 +1,212 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package binary
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"reflect"
+	"unsafe"
+)
+
+// PackedCodec returns a codec which copies the memory of the values of a struct type as it
+// is, for structs made only of fixed-size numbers, and arrays or structs of them, without
+// padding. Every number is encoded with its width in the byte order of the options, so
+// the bytes are swapped when it does not match the one of the platform. Registering it
+// with RegisterCodec applies it wherever the type appears, while the `binary:",packed"`
+// tag applies it to a single field, or to the elements of a slice or an array.
+func PackedCodec(t reflect.Type) (Codec, error) {
+	if t.Kind() != reflect.Struct {
+		return nil, errors.New("binary: packed encoding is not supported for " + t.String())
+	}
+	return scanPacked(t)
+}
+
+// scanPacked returns a codec which copies the memory of structs, selected with a
+// `binary:",packed"` tag, for a struct or a slice or an array of structs.
+func scanPacked(t reflect.Type) (Codec, error) {
+	switch t.Kind() {
+	case reflect.Struct:
+		var layout []packedNumber
+		if err := packedLayout(t, &layout); err != nil {
+			return nil, err
+		}
+
+		fallback, err := scan(t)
+		if err != nil {
+			return nil, err
+		}
+
+		return &packedCodec{size: int(t.Size()), layout: layout, fallback: fallback}, nil
+
+	case reflect.Slice:
+		elemCodec, err := scanPacked(t.Elem())
+		if err != nil {
+			return nil, nestedError(err, "[]")
+		}
+
+		return sliceCodecOf(t, elemCodec), nil
+
+	case reflect.Array:
+		elemCodec, err := scanPacked(t.Elem())
+		if err != nil {
+			return nil, nestedError(err, "[]")
+		}
+
+		return &reflectArrayCodec{
+			elemCodec: elemCodec,
+		}, nil
+	}
+
+	return nil, errors.New("binary: packed encoding is not supported for " + t.String())
+}
+
+// packedNumber describes a number of a packed struct, or an explicit padding field.
+type packedNumber struct {
+	size  int  // The size of the number, in bytes
+	float bool // Whether the number is a floating-point number
+	blank bool // Whether the number is in a blank field, which is encoded as zeros
+}
+
+// packedLayout appends the numbers a type is made of, in memory order, and fails if the
+// type contains anything else or padding. Integers whose size depends on the platform,
+// such as int, and booleans, which may only hold 0 or 1, are rejected. So are the fields
+// which are not encoded otherwise, such as unexported ones, while blank fields are
+// padding which can be filled explicitly.
+func packedLayout(t reflect.Type, layout *[]packedNumber) error {
+	switch t.Kind() {
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		*layout = append(*layout, packedNumber{size: int(t.Size())})
+		return nil
+
+	case reflect.Float32, reflect.Float64:
+		*layout = append(*layout, packedNumber{size: int(t.Size()), float: true})
+		return nil
+
+	case reflect.Complex64, reflect.Complex128:
+		part := packedNumber{size: int(t.Size() / 2), float: true}
+		*layout = append(*layout, part, part)
+		return nil
+
+	case reflect.Array:
+		var elem []packedNumber
+		if err := packedLayout(t.Elem(), &elem); err != nil {
+			return nestedError(err, "[]")
+		}
+
+		for i := 0; i < t.Len(); i++ {
+			*layout = append(*layout, elem...)
+		}
+		return nil
+
+	case reflect.Struct:
+		var offset uintptr
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			switch {
+			case field.Offset != offset:
+				return errors.New("binary: packed encoding is not supported for " + t.String() + ", which has padding before " + field.Name)
+			case field.Name != "_" && (!field.IsExported() || parseTag(field).Skip):
+				return errors.New("binary: packed encoding is not supported for " + t.String() + ", which skips the field " + field.Name)
+			}
+
+			var numbers []packedNumber
+			if err := packedLayout(field.Type, &numbers); err != nil {
+				return nestedError(err, "."+field.Name)
+			}
+
+			if field.Name == "_" {
+				numbers = []packedNumber{{size: int(field.Type.Size()), blank: true}}
+			}
+			*layout = append(*layout, numbers...)
+			offset += field.Type.Size()
+		}
+
+		if offset != t.Size() {
+			return errors.New("binary: packed encoding is not supported for " + t.String() + ", which has trailing padding")
+		}
+		return nil
+	}
+
+	return errors.New("binary: packed encoding is not supported for " + t.String())
+}
+
+// ------------------------------------------------------------------------------
+
+// packedCodec represents a codec which copies the memory of a struct made only of
+// fixed-size numbers. The numbers are encoded one by one when the byte order of the
+// platform does not match the one of the options, or when they need to be normalized.
+type packedCodec struct {
+	size     int            // The size of the struct, in bytes
+	layout   []packedNumber // The numbers of the struct, in memory order
+	fallback Codec          // The codec of the struct, used by the other formats
+}
+
+// swapped returns whether the bytes of the numbers need to be swapped.
+func (c *packedCodec) swapped(o *options) bool {
+	return littleEndian == o.bigEndian
+}
+
+// Encode encodes a value into the encoder.
+func (c *packedCodec) EncodeTo(e *Encoder, rv reflect.Value) error {
+	if !rv.CanAddr() {
+		ptr := reflect.New(rv.Type())
+		ptr.Elem().Set(rv)
+		rv = ptr.Elem()
+	}
+
+	b := unsafe.Slice((*byte)(rv.Addr().UnsafePointer()), c.size)
+	if !c.swapped(e.opts()) && !e.opts().canonical {
+		e.Write(b)
+		return nil
+	}
+
+	for _, n := range c.layout {
+		switch {
+		case n.blank:
+			for i := 0; i < n.size; i++ {
+				e.writeFixed(0, 1)
+			}
+		case n.float && n.size == 4:
+			e.WriteFloat32(math.Float32frombits(binary.NativeEndian.Uint32(b)))
+		case n.float:
+			e.WriteFloat64(math.Float64frombits(binary.NativeEndian.Uint64(b)))
+		case n.size == 1:
+			e.writeFixed(uint64(b[0]), 1)
+		case n.size == 2:
+			e.writeFixed(uint64(binary.NativeEndian.Uint16(b)), 2)
+		case n.size == 4:
+			e.writeFixed(uint64(binary.NativeEndian.Uint32(b)), 4)
+		default:
+			e.writeFixed(binary.NativeEndian.Uint64(b), 8)
+		}
+		b = b[n.size:]
+	}
+	return nil
+}
+
+// Decode decodes into a reflect value from the decoder.
+func (c *packedCodec) DecodeTo(d *Decoder, rv reflect.Value) error {
+	b := unsafe.Slice((*byte)(rv.Addr().UnsafePointer()), c.size)
+	if _, err := d.Read(b); err != nil {
+		return err
+	}
+
+	if c.swapped(&d.opts) {
+		for _, n := range c.layout {
+			for i, j := 0, n.size-1; i < j && !n.blank; i, j = i+1, j-1 {
+				b[i], b[j] = b[j], b[i]
+			}
+			b = b[n.size:]
+		}
+	}
+	return nil
+}
+
+// Size returns the encoded size of the value.
+func (c *packedCodec) Size(rv reflect.Value) int {
+	return c.size
+}
@@ -0,0 +1,195 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package binary
+
+import (
+	"io"
+	"reflect"
+	"unsafe"
+)
+
+// Whether the platform stores the values in little-endian byte order, in which case the
+// memory layout of the fixed-size values matches their encoding.
+var littleEndian = func() bool {
+	v := uint16(1)
+	return *(*byte)(unsafe.Pointer(&v)) == 1
+}()
+
+// scanPlain returns the size in memory of a type whose encoding is exactly its memory
+// layout on a little-endian platform. This is the case for floating-point and complex
+// numbers, byte arrays, integers with a `fixed` tag, structs with a `packed` tag, as well
+// as arrays and structs made only of such values, as long as the structs have no padding
+// and their fields are encoded in the order of declaration. The number of nesting levels
+// the type adds when decoded is returned as well, so the depth limit can be honored.
+func scanPlain(t reflect.Type, c Codec) (size, depth int, ok bool) {
+	switch codec := c.(type) {
+	case *float32Codec, *float64Codec, *complex64Codec, *complex128Codec:
+		return int(t.Size()), 0, true
+
+	case *byteArrayCodec:
+		return t.Len(), 0, true
+
+	case *packedCodec:
+		return codec.size, 0, true
+
+	case *fixedIntCodec:
+		return codec.size, 0, codec.size == int(t.Size())
+
+	case *fixedUintCodec:
+		return codec.size, 0, codec.size == int(t.Size())
+
+	case *reflectArrayCodec:
+		if t.Kind() != reflect.Array {
+			return 0, 0, false
+		}
+
+		elemSize, elemDepth, ok := scanPlain(t.Elem(), codec.elemCodec)
+		return elemSize * t.Len(), elemDepth + 1, ok && elemSize*t.Len() == int(t.Size())
+
+	case *reflectStructCodec:
+		if t.Kind() != reflect.Struct {
+			return 0, 0, false
+		}
+
+		for _, f := range *codec {
+			var field reflect.StructField
+			if f.Path != nil {
+				field = fieldByPath(t, f.Path)
+			} else {
+				field = t.Field(f.Index)
+			}
+
+			fieldSize, fieldDepth, ok := scanPlain(field.Type, f.Codec)
+			if !ok || f.Unexported || f.OmitEmpty || int(field.Offset) != size {
+				return 0, 0, false
+			}
+
+			size += fieldSize
+			if fieldDepth > depth {
+				depth = fieldDepth
+			}
+		}
+		return size, depth + 1, size == int(t.Size())
+	}
+
+	return 0, 0, false
+}
+
+// sliceCodecOf returns the codec of a slice type given the codec of its elements, copying
+// the elements at once when their encoding is their memory layout.
+func sliceCodecOf(t reflect.Type, elemCodec Codec) Codec {
+	fallback := &reflectSliceCodec{elemCodec: elemCodec}
+	if size, depth, ok := scanPlain(t.Elem(), elemCodec); ok && size > 0 {
+		return &plainSliceCodec{elemSize: size, depth: depth, fallback: fallback}
+	}
+	return fallback
+}
+
+// fieldByPath returns a field promoted from embedded structs, with its offset relative
+// to the outer struct.
+func fieldByPath(t reflect.Type, path []int) (field reflect.StructField) {
+	var offset uintptr
+	for _, i := range path {
+		field = t.Field(i)
+		offset += field.Offset
+		t = field.Type
+	}
+
+	field.Offset = offset
+	return
+}
+
+// ------------------------------------------------------------------------------
+
+// plainSliceCodec represents a codec for slices of values whose encoding is exactly their
+// memory layout, such as []float64 or slices of structs of floats. On little-endian
+// platforms, the elements are copied at once instead of being encoded one by one. The
+// encoding is the same as the one of the element-wise codec, which is used otherwise,
+// for instance when the byte order of the platform does not match the one of the options.
+type plainSliceCodec struct {
+	elemSize int   // The size of an element, in bytes
+	depth    int   // The number of nesting levels of an element
+	fallback Codec // The element-wise codec of the slice
+}
+
+// Encode encodes a value into the encoder.
+func (c *plainSliceCodec) EncodeTo(e *Encoder, rv reflect.Value) error {
+	if littleEndian == e.opts().bigEndian || e.opts().canonical || e.opts().versioned {
+		return c.fallback.EncodeTo(e, rv)
+	}
+
+	l := rv.Len()
+	e.WriteUvarint(uint64(l))
+	if l > 0 {
+		e.writeSegment(unsafe.Slice((*byte)(rv.UnsafePointer()), l*c.elemSize))
+	}
+	return nil
+}
+
+// Decode decodes into a reflect value from the decoder.
+func (c *plainSliceCodec) DecodeTo(d *Decoder, rv reflect.Value) (err error) {
+	if littleEndian == d.opts.bigEndian || d.opts.versioned || (d.opts.maxDepth > 0 && d.depth+1+c.depth > d.opts.maxDepth) {
+		return c.fallback.DecodeTo(d, rv)
+	}
+
+	var l int
+	if l, err = d.readSliceLen(); err != nil {
+		return
+	}
+
+	// Make sure the input is large enough before allocating the slice
+	if l > maxInt/c.elemSize || (d.s != nil && l*c.elemSize > d.s.Len()) {
+		return io.EOF
+	}
+
+	// With the ZeroCopy option, the slice points into the input if it is aligned for the
+	// elements, as mapped files are, and the elements are copied otherwise
+	if out, ok := c.alias(d, rv.Type(), l); ok {
+		rv.Set(out)
+		return nil
+	}
+
+	// The elements are read in chunks when the input is not known to hold them
+	var out reflect.Value
+	if out, err = d.makeSlice(rv, l); err != nil {
+		return
+	}
+
+	for n := 0; n < l; n = out.Len() {
+		if n == out.Len() {
+			out = growSlice(out, l)
+		}
+
+		b := unsafe.Slice((*byte)(out.UnsafePointer()), out.Len()*c.elemSize)
+		if _, err = d.Read(b[n*c.elemSize:]); err != nil {
+			return
+		}
+	}
+
+	rv.Set(out)
+	return
+}
+
+// alias returns a slice of l elements which points into the input, if the ZeroCopy option
+// is set and the input is aligned for the elements. Its capacity is its length, so that
+// appending to it never writes into the input.
+func (c *plainSliceCodec) alias(d *Decoder, t reflect.Type, l int) (reflect.Value, bool) {
+	if !d.opts.zeroCopy || d.s == nil || l == 0 || d.opts.arena != nil {
+		return reflect.Value{}, false
+	}
+
+	next := d.s.s[d.s.i:]
+	if uintptr(unsafe.Pointer(unsafe.SliceData(next)))%uintptr(t.Elem().Align()) != 0 {
+		return reflect.Value{}, false
+	}
+
+	b, _ := d.s.Slice(l * c.elemSize) // The length was checked against the input
+	return reflect.SliceAt(t.Elem(), unsafe.Pointer(unsafe.SliceData(b)), l).Convert(t), true
+}
+
+// Size returns the encoded size of the value.
+func (c *plainSliceCodec) Size(rv reflect.Value) int {
+	l := rv.Len()
+	return uvarintSize(uint64(l)) + l*c.elemSize
+}
@@ -0,0 +1,345 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package binary
+
+import (
+	"bytes"
+	"io"
+	"math"
+	"reflect"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type plainPoint struct {
+	X, Y float32
+}
+
+type plainSample struct {
+	plainPoint
+	Z     float64
+	ID    uint32   `binary:",fixed"`
+	Delta int16    `binary:",fixed"`
+	Flags [2]uint8 `binary:",fixed"`
+	Phase complex64
+	Range [2]float32
+}
+
+func TestPlainSlice_Scan(t *testing.T) {
+	tests := []struct {
+		value interface{}
+		plain bool
+	}{
+		{[]float32{}, true},
+		{[]float64{}, true},
+		{[]complex128{}, true},
+		{[]plainPoint{}, true},
+		{[]plainSample{}, true},
+		{[][3]plainPoint{}, true},
+		{[]struct{}{}, false},
+		{[]struct{ A, B int32 }{}, false},
+		{[]struct{ A, B string }{}, false},
+		{[]struct {
+			A [1]byte
+			B float32 // Padded
+		}{}, false},
+		{[]struct {
+			A float32 `binary:",id=2"`
+			B float32 `binary:",id=1"`
+		}{}, false},
+		{[]struct {
+			A float32
+			b float32
+		}{}, false},
+		{[]struct {
+			A float32
+			B float32 `binary:"-"`
+		}{}, false},
+	}
+
+	for _, tc := range tests {
+		c, err := scan(reflect.TypeOf(tc.value))
+		assert.NoError(t, err)
+
+		_, plain := c.(*plainSliceCodec)
+		assert.Equal(t, tc.plain, plain, "%T", tc.value)
+	}
+}
+
+func TestPlainSlice_Fixed(t *testing.T) {
+	type T struct {
+		A []int32 `binary:",fixed"`
+		B []int32
+	}
+
+	s, err := scanType(reflect.TypeOf(T{}))
+	assert.NoError(t, err)
+
+	fields := *s.(*reflectStructCodec)
+	assert.IsType(t, new(plainSliceCodec), fields[0].Codec)
+	assert.IsType(t, new(varintSliceCodec), fields[1].Codec)
+}
+
+func TestPlainSlice_Roundtrip(t *testing.T) {
+	v := make([]plainSample, 100)
+	for i := range v {
+		v[i] = plainSample{
+			plainPoint: plainPoint{X: float32(i), Y: float32(i) * 2},
+			Z:          float64(i) / 3,
+			ID:         uint32(i) << 20,
+			Delta:      -int16(i),
+			Flags:      [2]uint8{uint8(i), 255},
+			Phase:      complex(float32(i), 1),
+			Range:      [2]float32{0.5, float32(i)},
+		}
+	}
+
+	b, err := Marshal(v)
+	assert.NoError(t, err)
+
+	// The element-wise encoding is used for canonical output and must be identical
+	expect, err := Marshal(v, Canonical())
+	assert.NoError(t, err)
+	assert.Equal(t, expect, b)
+
+	n, err := Size(v)
+	assert.NoError(t, err)
+	assert.Equal(t, len(b), n)
+
+	var out []plainSample
+	assert.NoError(t, Unmarshal(b, &out))
+	assert.Equal(t, v, out)
+
+	// Decoding from a stream reads the elements at once as well
+	out = nil
+	assert.NoError(t, NewDecoder(iotest.HalfReader(bytes.NewReader(b))).Decode(&out))
+	assert.Equal(t, v, out)
+}
+
+func TestPlainSlice_Empty(t *testing.T) {
+	b, err := Marshal([]plainPoint{})
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{0x0}, b)
+
+	var out []plainPoint
+	assert.NoError(t, Unmarshal(b, &out))
+	assert.Len(t, out, 0)
+}
+
+func TestPlainSlice_Truncated(t *testing.T) {
+	b, err := Marshal([]plainPoint{{1, 2}, {3, 4}})
+	assert.NoError(t, err)
+
+	var out []plainPoint
+	assert.Equal(t, io.EOF, Unmarshal(b[:len(b)-1], &out))
+	assert.Nil(t, out)
+
+	// A huge length is rejected before allocating
+	assert.Equal(t, io.EOF, Unmarshal([]byte{0xff, 0xff, 0xff, 0xff, 0x07}, &out))
+}
+
+func TestPlainSlice_MaxDepth(t *testing.T) {
+	type T struct {
+		Points []plainPoint
+	}
+
+	b, err := Marshal(&T{Points: []plainPoint{{1, 2}}})
+	assert.NoError(t, err)
+
+	var out T
+	assert.NoError(t, Unmarshal(b, &out, MaxDepth(3)))
+	assert.Error(t, Unmarshal(b, &out, MaxDepth(2)))
+}
+
+func BenchmarkPlainSlice(b *testing.B) {
+	v := make([]plainPoint, 1000000)
+	for i := range v {
+		v[i] = plainPoint{X: float32(i), Y: float32(i) / 2}
+	}
+
+	enc, _ := Marshal(v)
+	b.Run("marshal", func(b *testing.B) {
+		buffer := make([]byte, 0, len(enc))
+		b.ReportAllocs()
+		b.ResetTimer()
+		for n := 0; n < b.N; n++ {
+			MarshalTo(buffer[:0], v)
+		}
+	})
+
+	b.Run("unmarshal", func(b *testing.B) {
+		var out []plainPoint
+		b.ReportAllocs()
+		b.ResetTimer()
+		for n := 0; n < b.N; n++ {
+			Unmarshal(enc, &out)
+		}
+	})
+}
+
+type packedParticle struct {
+	Position [3]float32
+	Velocity [3]float32
+	ID       uint32
+	Charge   int16
+	Kind     uint8
+	_        uint8
+}
+
+type packedState struct {
+	Tick      uint64
+	Particle  packedParticle    `binary:",packed"`
+	Particles []packedParticle  `binary:",packed"`
+	Pair      [2]packedParticle `binary:",packed"`
+}
+
+func TestPacked(t *testing.T) {
+	p := packedParticle{Position: [3]float32{1, 2, 3}, Velocity: [3]float32{-1, 0.5, 0}, ID: 0x01020304, Charge: -2, Kind: 7}
+	v := packedState{Tick: 42, Particle: p, Particles: []packedParticle{p, p}, Pair: [2]packedParticle{p}}
+
+	// The fields are encoded like fixed-width values, in the byte order of the options
+	type fixedParticle struct {
+		Position [3]float32
+		Velocity [3]float32
+		ID       uint32 `binary:",fixed"`
+		Charge   int16  `binary:",fixed"`
+		Kind     uint8  `binary:",fixed"`
+		Pad      uint8  `binary:",fixed"`
+	}
+
+	f := fixedParticle{Position: p.Position, Velocity: p.Velocity, ID: p.ID, Charge: p.Charge, Kind: p.Kind}
+	for _, opts := range [][]Option{nil, {ByteOrder(BigEndian)}, {Canonical()}} {
+		expect, err := Marshal(&struct{ P fixedParticle }{f}, opts...)
+		assert.NoError(t, err)
+
+		encoded, err := Marshal(&struct {
+			P packedParticle `binary:",packed"`
+		}{p}, opts...)
+		assert.NoError(t, err)
+		assert.Equal(t, expect, encoded)
+
+		b, err := Marshal(&v, opts...)
+		assert.NoError(t, err)
+		assert.Equal(t, 2+5*len(expect), len(b)) // The tick, the length of the slice and 5 particles
+
+		var out packedState
+		assert.NoError(t, Unmarshal(b, &out, opts...))
+		assert.Equal(t, v, out)
+	}
+}
+
+func TestPacked_Scan(t *testing.T) {
+	c, err := scanType(reflect.TypeOf(packedState{}))
+	assert.NoError(t, err)
+
+	fields := *c.(*reflectStructCodec)
+	assert.IsType(t, new(packedCodec), fields[1].Codec)
+	assert.IsType(t, new(plainSliceCodec), fields[2].Codec)
+	assert.IsType(t, new(reflectArrayCodec), fields[3].Codec)
+
+	tests := []interface{}{
+		struct {
+			A struct{ A, B int } `binary:",packed"` // Platform-dependent
+		}{},
+		struct {
+			A struct{ A bool } `binary:",packed"`
+		}{},
+		struct {
+			A struct {
+				A uint8
+				B uint32 // Padded
+			} `binary:",packed"`
+		}{},
+		struct {
+			A struct {
+				A uint32
+				B uint8 // Padded
+			} `binary:",packed"`
+		}{},
+		struct {
+			A struct{ A string } `binary:",packed"`
+		}{},
+		struct {
+			A int32 `binary:",packed"`
+		}{},
+		struct {
+			A struct {
+				A uint32
+				b uint32 // Unexported
+			} `binary:",packed"`
+		}{},
+		struct {
+			A struct {
+				A uint32
+				B uint32 `binary:"-"`
+			} `binary:",packed"`
+		}{},
+	}
+
+	for _, tc := range tests {
+		_, err := scanType(reflect.TypeOf(tc))
+		assert.Error(t, err, "%T", tc)
+	}
+
+	_, err = PackedCodec(reflect.TypeOf(uint32(0)))
+	assert.Error(t, err)
+}
+
+func TestPacked_Canonical(t *testing.T) {
+	type sample struct {
+		B float64
+		A float32
+		C uint16
+		_ [2]uint8
+	}
+
+	type fixedSample struct {
+		B   float64
+		A   float32
+		C   uint16   `binary:",fixed"`
+		Pad [2]uint8 `binary:",fixed"`
+	}
+
+	// Floating-point numbers are normalized like the ones of the other codecs
+	negZero := math.Copysign(0, -1)
+	nan := math.Float64frombits(0x7ff8000000000001)
+	for _, opts := range [][]Option{{Canonical()}, {Canonical(), ByteOrder(BigEndian)}} {
+		expect, err := Marshal(&struct{ S []fixedSample }{[]fixedSample{{A: float32(negZero), B: nan, C: 1}}}, opts...)
+		assert.NoError(t, err)
+
+		b, err := Marshal(&struct {
+			S []sample `binary:",packed"`
+		}{[]sample{{A: float32(negZero), B: nan, C: 1}}}, opts...)
+		assert.NoError(t, err)
+		assert.Equal(t, expect, b)
+
+		canonical, err := Marshal(&struct{ S []fixedSample }{[]fixedSample{{B: math.NaN(), C: 1}}}, opts...)
+		assert.NoError(t, err)
+		assert.Equal(t, canonical, b)
+	}
+}
+
+func TestPacked_Formats(t *testing.T) {
+	v := packedState{Particle: packedParticle{ID: 1, Charge: -1}}
+
+	b, err := MarshalCBOR(&v)
+	assert.NoError(t, err)
+
+	var out packedState
+	assert.NoError(t, UnmarshalCBOR(b, &out))
+	assert.Equal(t, v, out)
+
+	s, err := SchemaOf(reflect.TypeOf(v))
+	assert.NoError(t, err)
+	assert.Equal(t, KindArray, s.Fields[1].Schema.Kind)
+	assert.Equal(t, 32, s.Fields[1].Schema.Len)
+
+	encoded, err := Marshal(&v)
+	assert.NoError(t, err)
+
+	d := NewDecoder(bytes.NewReader(encoded))
+	assert.NoError(t, d.Skip(s))
+}
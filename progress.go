@@ -0,0 +1,111 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package binary
+
+import (
+	"io"
+)
+
+// OnProgress registers a function which is called with the total number of bytes written
+// to the underlying writer, every time at least the specified number of bytes have been
+// written since the previous call. This allows surfacing the progress of large payloads,
+// and aborting them: an error returned by the function fails the encoding with it. The
+// count starts over when the encoder is reset, and a nil function removes the hook.
+func (e *Encoder) OnProgress(every int64, fn func(n int64) error) {
+	out := e.out
+	if w, ok := out.(*progressWriter); ok {
+		out = w.Writer
+	}
+
+	if e.out = out; fn != nil {
+		e.out = &progressWriter{Writer: out, progress: newProgress(every, fn)}
+	}
+}
+
+// OnProgress registers a function which is called with the total number of bytes consumed
+// from the reader, every time at least the specified number of bytes have been consumed
+// since the previous call. The bytes which were buffered but not yet decoded are not
+// counted. An error returned by the function fails the decoding with it. The count starts
+// over when the decoder is reset, and a nil function removes the hook.
+func (d *Decoder) OnProgress(every int64, fn func(n int64) error) {
+	r := d.r
+	if p, ok := r.(*progressReader); ok {
+		r = p.Reader
+	}
+
+	if d.r = r; fn != nil {
+		d.r = &progressReader{Reader: r, progress: newProgress(every, fn)}
+	}
+	d.s, _ = d.r.(*SliceReader)
+}
+
+// ------------------------------------------------------------------------------
+
+// progress counts bytes and calls a function at regular intervals.
+type progress struct {
+	fn    func(n int64) error
+	every int64 // The number of bytes between two calls
+	n     int64 // The number of bytes so far
+	next  int64 // The number of bytes at which the function is called next
+}
+
+// newProgress creates a counter which calls the function every number of bytes.
+func newProgress(every int64, fn func(n int64) error) progress {
+	every = max(every, 1)
+	return progress{fn: fn, every: every, next: every}
+}
+
+// reset starts counting over.
+func (p *progress) reset() {
+	p.n, p.next = 0, p.every
+}
+
+// add counts the bytes and calls the function if the next multiple of the interval has
+// been reached.
+func (p *progress) add(n int) error {
+	if p.n += int64(n); p.n < p.next {
+		return nil
+	}
+
+	p.next += (p.n - p.next + p.every) / p.every * p.every
+	return p.fn(p.n)
+}
+
+// progressWriter counts the bytes written to a writer.
+type progressWriter struct {
+	io.Writer
+	progress
+}
+
+// Write implements io.Writer interface.
+func (w *progressWriter) Write(p []byte) (n int, err error) {
+	n, err = w.Writer.Write(p)
+	if perr := w.add(n); err == nil {
+		err = perr
+	}
+	return
+}
+
+// progressReader counts the bytes read from a reader.
+type progressReader struct {
+	Reader
+	progress
+}
+
+// Read implements io.Reader interface.
+func (r *progressReader) Read(p []byte) (n int, err error) {
+	n, err = r.Reader.Read(p)
+	if perr := r.add(n); perr != nil {
+		return 0, perr // Otherwise io.ReadFull would ignore the error
+	}
+	return
+}
+
+// ReadByte implements io.ByteReader interface.
+func (r *progressReader) ReadByte() (b byte, err error) {
+	if b, err = r.Reader.ReadByte(); err == nil {
+		err = r.add(1)
+	}
+	return
+}
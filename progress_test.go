@@ -0,0 +1,93 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package binary
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEncoderProgress(t *testing.T) {
+	values := make([]string, 1000)
+	for i := range values {
+		values[i] = "some value"
+	}
+
+	var buffer bytes.Buffer
+	var calls []int64
+	e := NewEncoderSize(&buffer, 512)
+	e.OnProgress(1024, func(n int64) error {
+		calls = append(calls, n)
+		return nil
+	})
+
+	assert.NoError(t, e.Encode(values))
+	assert.NoError(t, e.Flush())
+	assert.NotEmpty(t, calls)
+	for i, n := range calls {
+		assert.True(t, n >= int64(i+1)*1024)
+	}
+
+	// The count starts over after a reset
+	calls = nil
+	e.Reset(&buffer)
+	assert.NoError(t, e.Encode(values))
+	assert.NoError(t, e.Flush())
+	assert.True(t, calls[0] >= 1024 && calls[0] < 2048)
+
+	// The hook aborts the encoding
+	deadline := errors.New("deadline")
+	e.OnProgress(1024, func(n int64) error {
+		return deadline
+	})
+	e.Reset(&buffer)
+	assert.NoError(t, e.Encode("small"))
+	assert.Equal(t, deadline, e.Encode(values))
+
+	// The hook is removed
+	e.OnProgress(0, nil)
+	e.Reset(&buffer)
+	assert.NoError(t, e.Encode(values))
+	assert.NoError(t, e.Flush())
+}
+
+func TestDecoderProgress(t *testing.T) {
+	values := make([]string, 1000)
+	for i := range values {
+		values[i] = "some value"
+	}
+
+	b, err := Marshal(values)
+	assert.NoError(t, err)
+
+	for _, r := range []func() *Decoder{
+		func() *Decoder { return NewDecoder(bytes.NewReader(b)) },
+		func() *Decoder { return NewDecoder(iotest.OneByteReader(bytes.NewReader(b))) },
+	} {
+		var last int64
+		d := r()
+		d.OnProgress(1000, func(n int64) error {
+			assert.True(t, n/1000 > last/1000)
+			last = n
+			return nil
+		})
+
+		var out []string
+		assert.NoError(t, d.Decode(&out))
+		assert.Equal(t, values, out)
+		assert.True(t, last > int64(len(b))-1000)
+
+		// The hook aborts the decoding
+		deadline := errors.New("deadline")
+		d.OnProgress(1000, func(n int64) error {
+			return deadline
+		})
+		d.Reset(bytes.NewReader(b))
+		assert.Equal(t, deadline, d.Decode(&out))
+	}
+}
@@ -0,0 +1,57 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package binary
+
+import (
+	"strings"
+)
+
+// projection represents the set of fields to decode, by name. A nil projection of a
+// field means that the field is decoded entirely.
+type projection map[string]projection
+
+// Fields decodes only the fields with the specified names and skips over the remaining
+// ones, which are left unchanged. The fields of nested structs can be selected with a
+// dotted path, such as "Header.Version", and apply to the elements of slices, maps and
+// pointers as well. This avoids materializing large values when only a few fields are
+// needed, especially with the Versioned option, where unselected fields are skipped
+// without reading them.
+func Fields(paths ...string) Option {
+	project := make(projection, len(paths))
+	for _, path := range paths {
+		project.add(strings.Split(path, "."))
+	}
+
+	return func(o *options) {
+		o.fields = project
+	}
+}
+
+// add adds the path of a field to the projection.
+func (p projection) add(path []string) {
+	sub, ok := p[path[0]]
+	switch {
+	case len(path) == 1:
+		p[path[0]] = nil // The entire field
+	case ok && sub == nil:
+		// The entire field is already selected
+	default:
+		if sub == nil {
+			sub = make(projection)
+			p[path[0]] = sub
+		}
+		sub.add(path[1:])
+	}
+}
+
+// selects returns whether the field is selected, along with the projection of its
+// own fields. A nil projection selects every field.
+func (p projection) selects(name string) (projection, bool) {
+	if p == nil {
+		return nil, true
+	}
+
+	sub, ok := p[name]
+	return sub, ok
+}
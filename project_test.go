@@ -0,0 +1,119 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package binary
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type projectedItem struct {
+	Name  string
+	Price float64
+}
+
+type projectedHeader struct {
+	Version uint16
+	Kind    string
+}
+
+type projected struct {
+	Header  projectedHeader
+	Text    string
+	Payload []byte
+	Items   []projectedItem
+	Points  []plainPoint
+	Counts  []int
+	Sizes   []uint
+	Flags   []bool
+	Hashes  [2]uint64 `binary:",fixed"`
+	ID      [4]byte
+	Ratio   complex128
+	Meta    map[string]int
+	Next    *projectedHeader
+	Created time.Time
+	Amount  *big.Int
+	Last    int32
+}
+
+func newProjected() *projected {
+	return &projected{
+		Header:  projectedHeader{Version: 2, Kind: "order"},
+		Text:    "hello",
+		Payload: []byte{1, 2, 3},
+		Items:   []projectedItem{{"a", 1.5}, {"b", 2.5}},
+		Points:  []plainPoint{{1, 2}},
+		Counts:  []int{-1, 300},
+		Sizes:   []uint{1, 300},
+		Flags:   []bool{true, false},
+		Hashes:  [2]uint64{1, 2},
+		ID:      [4]byte{1, 2, 3, 4},
+		Ratio:   complex(1, 2),
+		Meta:    map[string]int{"a": 1},
+		Next:    &projectedHeader{Version: 3},
+		Created: time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+		Amount:  big.NewInt(-12345),
+		Last:    -7,
+	}
+}
+
+func TestFields(t *testing.T) {
+	v := newProjected()
+	for _, opts := range [][]Option{nil, {Versioned()}, {InternStrings()}} {
+		b, err := Marshal(v, opts...)
+		assert.NoError(t, err)
+
+		out := projected{Text: "unchanged", Header: projectedHeader{Kind: "unchanged"}}
+		assert.NoError(t, Unmarshal(b, &out, append(opts, Fields("Header.Version", "Items.Price", "Last"))...))
+		assert.Equal(t, projected{
+			Header: projectedHeader{Version: 2, Kind: "unchanged"},
+			Text:   "unchanged",
+			Items:  []projectedItem{{Price: 1.5}, {Price: 2.5}},
+			Last:   -7,
+		}, out)
+	}
+}
+
+func TestFields_Entire(t *testing.T) {
+	v := newProjected()
+	b, err := Marshal(v)
+	assert.NoError(t, err)
+
+	var out projected
+	assert.NoError(t, Unmarshal(b, &out, Fields("Header", "Header.Kind", "Next.Version")))
+	assert.Equal(t, v.Header, out.Header)
+	assert.Equal(t, v.Next, out.Next)
+	assert.Nil(t, out.Items)
+}
+
+func TestFields_Stream(t *testing.T) {
+	v := newProjected()
+	b, err := Marshal(v)
+	assert.NoError(t, err)
+
+	var out projected
+	d := NewDecoder(iotest.HalfReader(bytes.NewReader(append(b, b...))), Fields("Last"))
+	assert.NoError(t, d.Decode(&out))
+	assert.Equal(t, int32(-7), out.Last)
+
+	out.Last = 0
+	assert.NoError(t, d.Decode(&out))
+	assert.Equal(t, int32(-7), out.Last)
+	assert.Nil(t, out.Items)
+}
+
+func TestFields_Truncated(t *testing.T) {
+	b, err := Marshal(newProjected())
+	assert.NoError(t, err)
+
+	var out projected
+	for _, n := range []int{2, 10, 30, len(b) - 1} {
+		assert.Error(t, Unmarshal(b[:n], &out, Fields("Last")))
+	}
+}
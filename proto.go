@@ -0,0 +1,639 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package binary
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"reflect"
+	"strconv"
+	"sync"
+)
+
+// The wire types of the protobuf encoding
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+// The maximum nesting depth of protobuf messages, like the official implementation
+const maxProtoDepth = 10000
+
+// The error returned for payloads which are not valid protobuf messages
+var errInvalidProto = errors.New("binary: invalid protobuf message")
+
+// Map of the protobuf descriptions of the structs encountered so far
+var protoMessages = new(sync.Map)
+
+// MarshalProto encodes a struct in the protobuf wire format, so that it can be decoded by
+// existing protobuf consumers without generating code with protoc. The field numbers are
+// the identifiers of the fields, which are best set explicitly with an `id` option. Zero
+// values are omitted like in proto3, except behind pointers. Signed integers are encoded
+// like int64 by default, like sint64 with a `zigzag` option, like sfixed32 or sfixed64
+// with a `fixed32` or `fixed64` option, and unsigned integers likewise. Slices of numbers
+// are packed, and maps are encoded as repeated entries.
+func MarshalProto(v interface{}) ([]byte, error) {
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	m, err := protoMessageOf(rv.Type())
+	if err != nil {
+		return nil, err
+	}
+
+	return m.append(nil, rv)
+}
+
+// UnmarshalProto decodes a protobuf message into a struct, which is reset first. Fields
+// with an unknown number are skipped, and repeated numbers are decoded whether or not
+// they are packed. Groups, which are deprecated, are not supported.
+func UnmarshalProto(b []byte, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return errors.New("binary: can only Decode to pointer type")
+	}
+
+	rv = rv.Elem()
+	m, err := protoMessageOf(rv.Type())
+	if err != nil {
+		return err
+	}
+
+	rv.Set(reflect.Zero(rv.Type()))
+	return m.decode(b, rv, 0)
+}
+
+// protoMessageOf returns the protobuf description of a struct.
+func protoMessageOf(t reflect.Type) (*protoMessage, error) {
+	if m, ok := protoMessages.Load(t); ok {
+		return m.(*protoMessage), nil
+	}
+
+	if t.Kind() != reflect.Struct {
+		return nil, errors.New("binary: protobuf encoding is only supported for structs, not " + t.String())
+	}
+
+	m, err := scanProtoMessage(t, make(map[reflect.Type]*protoMessage))
+	if err != nil {
+		return nil, rootedError(err, t)
+	}
+
+	stored, _ := protoMessages.LoadOrStore(t, m)
+	return stored.(*protoMessage), nil
+}
+
+// ------------------------------------------------------------------------------
+
+// protoKind represents the way a value is encoded in the protobuf wire format.
+type protoKind uint8
+
+// The kinds of protobuf values
+const (
+	protoBool     protoKind = iota // A varint, 0 or 1
+	protoInt                       // A signed integer as the varint of its two's complement
+	protoUint                      // An unsigned integer as a varint
+	protoSint                      // A signed integer as a zigzag varint
+	protoFixed32                   // An integer as 4 bytes
+	protoFixed64                   // An integer as 8 bytes
+	protoFloat                     // A float32 as 4 bytes
+	protoDouble                    // A float64 as 8 bytes
+	protoString                    // A string prefixed with its length
+	protoBytes                     // A byte slice prefixed with its length
+	protoStruct                    // A struct prefixed with its length
+	protoRepeated                  // A slice, packed if its elements are numbers
+	protoMap                       // A map, as repeated messages of a key and a value
+)
+
+// protoType describes the way the values of a type are encoded in the protobuf format.
+type protoType struct {
+	kind    protoKind
+	signed  bool          // Whether a fixed-width integer is signed
+	ptr     bool          // Whether the value is behind a pointer, and encoded even if zero
+	elem    *protoType    // The elements of a slice, or the values of a map
+	key     *protoType    // The keys of a map
+	message *protoMessage // The fields of a struct
+}
+
+// protoMessage describes the fields of a struct encoded as a protobuf message.
+type protoMessage struct {
+	fields  []protoField
+	numbers map[int]int // The index of the fields by their number
+}
+
+// protoField describes a field of a protobuf message.
+type protoField struct {
+	num  int        // The number of the field
+	path []int      // The index sequence of the field within the struct
+	typ  *protoType // The way the field is encoded
+}
+
+// scanProtoMessage scans the fields of a struct. The structs which were already scanned
+// are reused, to support recursive types.
+func scanProtoMessage(t reflect.Type, seen map[reflect.Type]*protoMessage) (*protoMessage, error) {
+	if m, ok := seen[t]; ok {
+		return m, nil
+	}
+
+	s, err := scanStruct(t)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &protoMessage{numbers: make(map[int]int, len(s.fields))}
+	seen[t] = m
+	for _, f := range s.fields {
+		path := f.Path
+		if path == nil {
+			path = []int{f.Index}
+		}
+
+		// Unexported fields are skipped
+		field := t.FieldByIndex(path)
+		if field.PkgPath != "" {
+			continue
+		}
+		if f.ID >= 1<<29 {
+			return nil, errors.New("binary: invalid protobuf field number " + strconv.Itoa(f.ID) + " on field " + t.String() + "." + f.Name)
+		}
+
+		typ, err := scanProto(field.Type, f.Tag, seen)
+		if err != nil {
+			return nil, nestedError(err, "."+f.Name)
+		}
+
+		m.numbers[f.ID] = len(m.fields)
+		m.fields = append(m.fields, protoField{num: f.ID, path: path, typ: typ})
+	}
+	return m, nil
+}
+
+// scanProto scans the way the values of a type are encoded, taking the options of the
+// tag of their field into account.
+func scanProto(t reflect.Type, tag fieldTag, seen map[reflect.Type]*protoMessage) (*protoType, error) {
+	fixed := tag.Options.Contains("fixed")
+	switch t.Kind() {
+	case reflect.Ptr:
+		elem, err := scanProto(t.Elem(), tag, seen)
+		if err != nil {
+			return nil, err
+		}
+		if elem.ptr || elem.kind == protoRepeated || elem.kind == protoMap {
+			break
+		}
+
+		p := *elem
+		p.ptr = true
+		return &p, nil
+
+	case reflect.Bool:
+		return &protoType{kind: protoBool}, nil
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		switch {
+		case tag.Options.Contains("zigzag"):
+			return &protoType{kind: protoSint}, nil
+		case tag.Options.Contains("fixed32"), fixed && t.Size() == 4:
+			return &protoType{kind: protoFixed32, signed: true}, nil
+		case tag.Options.Contains("fixed64"), fixed && t.Size() == 8:
+			return &protoType{kind: protoFixed64, signed: true}, nil
+		case !fixed:
+			return &protoType{kind: protoInt}, nil
+		}
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		switch {
+		case tag.Options.Contains("fixed32"), fixed && t.Size() == 4:
+			return &protoType{kind: protoFixed32}, nil
+		case tag.Options.Contains("fixed64"), fixed && t.Size() == 8:
+			return &protoType{kind: protoFixed64}, nil
+		case !fixed:
+			return &protoType{kind: protoUint}, nil
+		}
+
+	case reflect.Float32:
+		return &protoType{kind: protoFloat}, nil
+	case reflect.Float64:
+		return &protoType{kind: protoDouble}, nil
+	case reflect.String:
+		return &protoType{kind: protoString}, nil
+
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &protoType{kind: protoBytes}, nil
+		}
+
+		elem, err := scanProto(t.Elem(), tag, seen)
+		if err != nil {
+			return nil, nestedError(err, "[]")
+		}
+		if elem.kind == protoRepeated || elem.kind == protoMap || (elem.ptr && elem.kind != protoStruct) {
+			break
+		}
+		return &protoType{kind: protoRepeated, elem: elem}, nil
+
+	case reflect.Map:
+		key, err := scanProto(t.Key(), fieldTag{}, seen)
+		if err != nil {
+			return nil, nestedError(err, "[key]")
+		}
+		// Like in protobuf, keys can only be integers, booleans or strings
+		if (key.kind > protoFixed64 && key.kind != protoString) || key.ptr {
+			break
+		}
+
+		val, err := scanProto(t.Elem(), tag, seen)
+		if err != nil {
+			return nil, nestedError(err, "[]")
+		}
+		if val.kind == protoRepeated || val.kind == protoMap {
+			break
+		}
+		return &protoType{kind: protoMap, key: key, elem: val}, nil
+
+	case reflect.Struct:
+		m, err := scanProtoMessage(t, seen)
+		if err != nil {
+			return nil, err
+		}
+		return &protoType{kind: protoStruct, message: m}, nil
+	}
+
+	return nil, &TypeError{Err: errors.New("binary: protobuf encoding is not supported for " + t.String())}
+}
+
+// wire returns the wire type of the values.
+func (p *protoType) wire() int {
+	switch p.kind {
+	case protoBool, protoInt, protoUint, protoSint:
+		return wireVarint
+	case protoFixed32, protoFloat:
+		return wireFixed32
+	case protoFixed64, protoDouble:
+		return wireFixed64
+	default:
+		return wireBytes
+	}
+}
+
+// packed returns whether repeated values are packed, which is the case for numbers.
+func (p *protoType) packed() bool {
+	return p.wire() != wireBytes && !p.ptr
+}
+
+// ------------------------------------------------------------------------------
+
+// append appends the fields of a struct to a buffer.
+func (m *protoMessage) append(b []byte, rv reflect.Value) (_ []byte, err error) {
+	for _, f := range m.fields {
+		if b, err = f.typ.appendField(b, f.num, rv.FieldByIndex(f.path)); err != nil {
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
+// appendField appends a field prefixed with its number and its wire type, unless it is
+// zero and not behind a pointer.
+func (p *protoType) appendField(b []byte, num int, rv reflect.Value) ([]byte, error) {
+	switch {
+	case p.kind == protoRepeated:
+		return p.appendRepeated(b, num, rv)
+	case p.kind == protoMap:
+		return p.appendMap(b, num, rv)
+	case p.ptr && rv.IsNil(), !p.ptr && rv.IsZero():
+		return b, nil
+	}
+
+	b = binary.AppendUvarint(b, uint64(num)<<3|uint64(p.wire()))
+	return p.appendValue(b, rv)
+}
+
+// appendRepeated appends the elements of a slice, either packed or as repeated fields.
+func (p *protoType) appendRepeated(b []byte, num int, rv reflect.Value) (_ []byte, err error) {
+	l := rv.Len()
+	if l == 0 {
+		return b, nil
+	}
+
+	if p.elem.packed() {
+		b = binary.AppendUvarint(b, uint64(num)<<3|wireBytes)
+		return appendDelimited(b, func(b []byte) ([]byte, error) {
+			for i := 0; i < l && err == nil; i++ {
+				b, err = p.elem.appendValue(b, rv.Index(i))
+			}
+			return b, err
+		})
+	}
+
+	for i := 0; i < l && err == nil; i++ {
+		b = binary.AppendUvarint(b, uint64(num)<<3|uint64(p.elem.wire()))
+		b, err = p.elem.appendValue(b, rv.Index(i))
+	}
+	return b, err
+}
+
+// appendMap appends the entries of a map, as messages with the key as their first field
+// and the value as their second field.
+func (p *protoType) appendMap(b []byte, num int, rv reflect.Value) (_ []byte, err error) {
+	for it := rv.MapRange(); it.Next() && err == nil; {
+		b = binary.AppendUvarint(b, uint64(num)<<3|wireBytes)
+		b, err = appendDelimited(b, func(b []byte) (_ []byte, err error) {
+			b = binary.AppendUvarint(b, 1<<3|uint64(p.key.wire()))
+			if b, err = p.key.appendValue(b, it.Key()); err != nil {
+				return
+			}
+
+			b = binary.AppendUvarint(b, 2<<3|uint64(p.elem.wire()))
+			return p.elem.appendValue(b, it.Value())
+		})
+	}
+	return b, err
+}
+
+// appendValue appends a value without its number. Nil pointers are encoded as the zero
+// value, as the elements of a slice can not be omitted.
+func (p *protoType) appendValue(b []byte, rv reflect.Value) ([]byte, error) {
+	if p.ptr {
+		if rv.IsNil() {
+			rv = reflect.Zero(rv.Type().Elem())
+		} else {
+			rv = rv.Elem()
+		}
+	}
+
+	switch p.kind {
+	case protoBool:
+		if rv.Bool() {
+			return append(b, 1), nil
+		}
+		return append(b, 0), nil
+	case protoInt:
+		return binary.AppendUvarint(b, uint64(rv.Int())), nil
+	case protoUint:
+		return binary.AppendUvarint(b, rv.Uint()), nil
+	case protoSint:
+		return binary.AppendVarint(b, rv.Int()), nil
+	case protoFixed32:
+		if p.signed {
+			if v := rv.Int(); v != int64(int32(v)) {
+				return nil, IntOverflowError(v, "sfixed32")
+			}
+			return binary.LittleEndian.AppendUint32(b, uint32(rv.Int())), nil
+		}
+		if v := rv.Uint(); v > math.MaxUint32 {
+			return nil, UintOverflowError(v, "fixed32")
+		}
+		return binary.LittleEndian.AppendUint32(b, uint32(rv.Uint())), nil
+	case protoFixed64:
+		if p.signed {
+			return binary.LittleEndian.AppendUint64(b, uint64(rv.Int())), nil
+		}
+		return binary.LittleEndian.AppendUint64(b, rv.Uint()), nil
+	case protoFloat:
+		return binary.LittleEndian.AppendUint32(b, math.Float32bits(float32(rv.Float()))), nil
+	case protoDouble:
+		return binary.LittleEndian.AppendUint64(b, math.Float64bits(rv.Float())), nil
+	case protoString:
+		b = binary.AppendUvarint(b, uint64(rv.Len()))
+		return append(b, rv.String()...), nil
+	case protoBytes:
+		b = binary.AppendUvarint(b, uint64(rv.Len()))
+		return append(b, rv.Bytes()...), nil
+	default:
+		return appendDelimited(b, func(b []byte) ([]byte, error) {
+			return p.message.append(b, rv)
+		})
+	}
+}
+
+// appendDelimited appends the bytes produced by a function, prefixed with their length.
+// The bytes are moved once their length is known.
+func appendDelimited(b []byte, fn func([]byte) ([]byte, error)) ([]byte, error) {
+	start := len(b)
+	b, err := fn(b)
+	if err != nil {
+		return nil, err
+	}
+
+	n := len(b) - start
+	size := uvarintSize(uint64(n))
+	b = append(b, make([]byte, size)...)
+	copy(b[start+size:], b[start:start+n])
+	binary.PutUvarint(b[start:], uint64(n))
+	return b, nil
+}
+
+// ------------------------------------------------------------------------------
+
+// protoValue represents an encoded value along with its number and its wire type.
+type protoValue struct {
+	num    int    // The number of the field
+	wire   int    // The wire type of the value
+	scalar uint64 // The value of numbers
+	bytes  []byte // The value of strings, byte slices, messages and packed numbers
+}
+
+// readProtoValue reads a value prefixed with its number and its wire type, and returns
+// the number of bytes read.
+func readProtoValue(b []byte) (v protoValue, n int, err error) {
+	tag, n := binary.Uvarint(b)
+	if n <= 0 || tag>>3 == 0 || tag>>3 >= 1<<29 {
+		return v, 0, errInvalidProto
+	}
+
+	v.num, v.wire = int(tag>>3), int(tag&7)
+	var k int
+	if v.wire == wireBytes {
+		var l uint64
+		if l, k = binary.Uvarint(b[n:]); k <= 0 || l > uint64(len(b)-n-k) {
+			return v, 0, errInvalidProto
+		}
+		v.bytes = b[n+k : n+k+int(l)]
+		return v, n + k + int(l), nil
+	}
+
+	if v.scalar, k = readProtoScalar(b[n:], v.wire); k <= 0 {
+		return v, 0, errInvalidProto
+	}
+	return v, n + k, nil
+}
+
+// readProtoScalar reads a number of a wire type, and returns the number of bytes read
+// or zero if it is invalid.
+func readProtoScalar(b []byte, wire int) (uint64, int) {
+	switch {
+	case wire == wireVarint:
+		return binary.Uvarint(b)
+	case wire == wireFixed64 && len(b) >= 8:
+		return binary.LittleEndian.Uint64(b), 8
+	case wire == wireFixed32 && len(b) >= 4:
+		return uint64(binary.LittleEndian.Uint32(b)), 4
+	default:
+		return 0, 0
+	}
+}
+
+// decode decodes the fields of a message into a struct, skipping the unknown ones.
+func (m *protoMessage) decode(b []byte, rv reflect.Value, depth int) error {
+	if depth > maxProtoDepth {
+		return limitError("depth", uint64(depth), maxProtoDepth)
+	}
+
+	for len(b) > 0 {
+		v, n, err := readProtoValue(b)
+		if err != nil {
+			return err
+		}
+
+		b = b[n:]
+		if i, ok := m.numbers[v.num]; ok {
+			f := m.fields[i]
+			if err = f.typ.decodeField(rv.FieldByIndex(f.path), v, depth); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// decodeField decodes a value into a field, appending it to repeated fields.
+func (p *protoType) decodeField(rv reflect.Value, v protoValue, depth int) error {
+	switch p.kind {
+	case protoRepeated:
+		if v.wire == wireBytes && p.elem.packed() {
+			return p.decodePacked(rv, v.bytes)
+		}
+
+		elem := reflect.New(rv.Type().Elem()).Elem()
+		if err := p.elem.decodeValue(elem, v, depth); err != nil {
+			return err
+		}
+		rv.Set(reflect.Append(rv, elem))
+		return nil
+
+	case protoMap:
+		if v.wire != wireBytes {
+			return errInvalidProto
+		}
+		return p.decodeEntry(rv, v.bytes, depth)
+
+	default:
+		return p.decodeValue(rv, v, depth)
+	}
+}
+
+// decodePacked decodes packed numbers and appends them to a slice.
+func (p *protoType) decodePacked(rv reflect.Value, b []byte) error {
+	wire := p.elem.wire()
+	for len(b) > 0 {
+		scalar, n := readProtoScalar(b, wire)
+		if n <= 0 {
+			return errInvalidProto
+		}
+
+		b = b[n:]
+		elem := reflect.New(rv.Type().Elem()).Elem()
+		if err := p.elem.decodeValue(elem, protoValue{wire: wire, scalar: scalar}, 0); err != nil {
+			return err
+		}
+		rv.Set(reflect.Append(rv, elem))
+	}
+	return nil
+}
+
+// decodeEntry decodes an entry of a map. Missing keys and values are zero.
+func (p *protoType) decodeEntry(rv reflect.Value, b []byte, depth int) error {
+	if rv.IsNil() {
+		rv.Set(reflect.MakeMap(rv.Type()))
+	}
+
+	key := reflect.New(rv.Type().Key()).Elem()
+	val := reflect.New(rv.Type().Elem()).Elem()
+	for len(b) > 0 {
+		v, n, err := readProtoValue(b)
+		if err != nil {
+			return err
+		}
+
+		b = b[n:]
+		switch v.num {
+		case 1:
+			err = p.key.decodeValue(key, v, depth)
+		case 2:
+			err = p.elem.decodeValue(val, v, depth+1)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	rv.SetMapIndex(key, val)
+	return nil
+}
+
+// decodeValue decodes a single value, which must have the expected wire type.
+func (p *protoType) decodeValue(rv reflect.Value, v protoValue, depth int) error {
+	if v.wire != p.wire() {
+		return errInvalidProto
+	}
+
+	if p.ptr {
+		if rv.IsNil() {
+			rv.Set(reflect.New(rv.Type().Elem()))
+		}
+		rv = rv.Elem()
+	}
+
+	switch p.kind {
+	case protoBool:
+		rv.SetBool(v.scalar != 0)
+	case protoInt:
+		return setProtoInt(rv, int64(v.scalar))
+	case protoSint:
+		return setProtoInt(rv, int64(v.scalar>>1)^-int64(v.scalar&1))
+	case protoUint:
+		return setProtoUint(rv, v.scalar)
+	case protoFixed32, protoFixed64:
+		switch {
+		case p.signed && p.kind == protoFixed32:
+			return setProtoInt(rv, int64(int32(v.scalar)))
+		case p.signed:
+			return setProtoInt(rv, int64(v.scalar))
+		default:
+			return setProtoUint(rv, v.scalar)
+		}
+	case protoFloat:
+		rv.SetFloat(float64(math.Float32frombits(uint32(v.scalar))))
+	case protoDouble:
+		rv.SetFloat(math.Float64frombits(v.scalar))
+	case protoString:
+		rv.SetString(string(v.bytes))
+	case protoBytes:
+		rv.SetBytes(append([]byte{}, v.bytes...))
+	case protoStruct:
+		return p.message.decode(v.bytes, rv, depth+1)
+	}
+	return nil
+}
+
+// setProtoInt sets a signed integer, unless it does not fit into the value.
+func setProtoInt(rv reflect.Value, v int64) error {
+	if rv.OverflowInt(v) {
+		return IntOverflowError(v, rv.Type().String())
+	}
+
+	rv.SetInt(v)
+	return nil
+}
+
+// setProtoUint sets an unsigned integer, unless it does not fit into the value.
+func setProtoUint(rv reflect.Value, v uint64) error {
+	if rv.OverflowUint(v) {
+		return UintOverflowError(v, rv.Type().String())
+	}
+
+	rv.SetUint(v)
+	return nil
+}
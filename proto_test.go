@@ -0,0 +1,175 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package binary
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type protoInner struct {
+	Name  string   `binary:",id=1"`
+	Score *float32 `binary:",id=2"`
+}
+
+type protoOuter struct {
+	ID      uint64                `binary:",id=1"`
+	Delta   int32                 `binary:",id=2,zigzag"`
+	Hash    uint32                `binary:",id=3,fixed32"`
+	Offset  int64                 `binary:",id=4,fixed"`
+	Ratio   float64               `binary:",id=5"`
+	Valid   bool                  `binary:",id=6"`
+	Data    []byte                `binary:",id=7"`
+	Tags    []string              `binary:",id=8"`
+	Values  []int16               `binary:",id=9"`
+	Inner   protoInner            `binary:",id=10"`
+	Next    *protoOuter           `binary:",id=11"`
+	Items   []*protoInner         `binary:",id=12"`
+	Counts  map[string]int        `binary:",id=13"`
+	ByID    map[uint32]protoInner `binary:",id=14"`
+	Count   *int                  `binary:",id=15"`
+	private int
+}
+
+func TestProtoWireFormat(t *testing.T) {
+	type T struct {
+		A int32   `binary:",id=1"`
+		B string  `binary:",id=2"`
+		C []int32 `binary:",id=3"`
+		D int64   `binary:",id=4,zigzag"`
+		E uint32  `binary:",id=5,fixed32"`
+		F float64 `binary:",id=6"`
+		G int32   `binary:",id=7"`
+		H bool    `binary:",id=8"`
+	}
+
+	b, err := MarshalProto(&T{
+		A: 150,
+		B: "testing",
+		C: []int32{3, 270, 86942},
+		D: -1,
+		E: 1,
+		F: 1,
+		G: -1,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{
+		0x08, 0x96, 0x01,
+		0x12, 0x07, 't', 'e', 's', 't', 'i', 'n', 'g',
+		0x1a, 0x06, 0x03, 0x8e, 0x02, 0x9e, 0xa7, 0x05,
+		0x20, 0x01,
+		0x2d, 0x01, 0x00, 0x00, 0x00,
+		0x31, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0xf0, 0x3f,
+		0x38, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01,
+	}, b)
+
+	var out T
+	assert.NoError(t, UnmarshalProto(b, &out))
+	assert.Equal(t, T{A: 150, B: "testing", C: []int32{3, 270, 86942}, D: -1, E: 1, F: 1, G: -1}, out)
+}
+
+func TestProtoRoundTrip(t *testing.T) {
+	score, count := float32(0.5), 0
+	v := &protoOuter{
+		ID:     1 << 40,
+		Delta:  -42,
+		Hash:   0xdeadbeef,
+		Offset: -7,
+		Ratio:  3.25,
+		Valid:  true,
+		Data:   []byte("data"),
+		Tags:   []string{"a", "", "c"},
+		Values: []int16{-1, 0, 1},
+		Inner:  protoInner{Name: "inner", Score: &score},
+		Next:   &protoOuter{ID: 2, Inner: protoInner{Name: "next"}},
+		Items:  []*protoInner{{Name: "x"}, {}},
+		Counts: map[string]int{"a": 1, "b": 0},
+		ByID:   map[uint32]protoInner{7: {Name: "seven"}},
+		Count:  &count,
+	}
+
+	b, err := MarshalProto(v)
+	assert.NoError(t, err)
+
+	out := &protoOuter{private: 1, Tags: []string{"stale"}}
+	assert.NoError(t, UnmarshalProto(b, out))
+	assert.Equal(t, v, out)
+}
+
+func TestProtoZero(t *testing.T) {
+	b, err := MarshalProto(protoOuter{private: 1})
+	assert.NoError(t, err)
+	assert.Empty(t, b)
+
+	// Pointers are encoded even if they point to a zero value
+	count := 0
+	b, err = MarshalProto(protoOuter{Count: &count})
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{0x78, 0x00}, b)
+}
+
+func TestProtoUnknownAndUnpacked(t *testing.T) {
+	type T struct {
+		A []int32 `binary:",id=1"`
+		B string  `binary:",id=3"`
+	}
+
+	var out T
+	assert.NoError(t, UnmarshalProto([]byte{
+		0x08, 0x01, // A, unpacked
+		0x10, 0x96, 0x01, // unknown varint
+		0x0a, 0x02, 0x02, 0x03, // A, packed
+		0x29, 1, 2, 3, 4, 5, 6, 7, 8, // unknown fixed64
+		0x22, 0x01, 'x', // unknown bytes
+		0x35, 1, 2, 3, 4, // unknown fixed32
+		0x1a, 0x02, 'o', 'k',
+		0x08, 0x04, // A, unpacked
+	}, &out))
+	assert.Equal(t, T{A: []int32{1, 2, 3, 4}, B: "ok"}, out)
+}
+
+func TestProtoInvalid(t *testing.T) {
+	type T struct {
+		A uint8  `binary:",id=1"`
+		B string `binary:",id=2"`
+	}
+
+	for _, b := range [][]byte{
+		{0x08},             // Truncated varint
+		{0x12, 0x05, 'a'},  // Truncated bytes
+		{0x00, 0x01},       // Field number zero
+		{0x0b},             // Group
+		{0x0d, 1, 2, 3, 4}, // Mismatched wire type
+	} {
+		var out T
+		assert.Error(t, UnmarshalProto(b, &out), "%x", b)
+	}
+
+	var out T
+	err := UnmarshalProto([]byte{0x08, 0xac, 0x02}, &out)
+	assert.True(t, errors.Is(err, ErrOverflow))
+	assert.Error(t, UnmarshalProto(nil, out))
+}
+
+func TestProtoUnsupported(t *testing.T) {
+	type T struct {
+		A [][]int `binary:",id=1"`
+	}
+
+	_, err := MarshalProto(&T{})
+	var typeErr *TypeError
+	assert.True(t, errors.As(err, &typeErr))
+	assert.Equal(t, ".A", typeErr.Path)
+
+	_, err = MarshalProto(42)
+	assert.Error(t, err)
+
+	type U struct {
+		A int64 `binary:",id=1,fixed32"`
+	}
+	_, err = MarshalProto(&U{A: 1 << 40})
+	assert.True(t, errors.Is(err, ErrOverflow))
+}
@@ -0,0 +1,90 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package binary
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strconv"
+)
+
+// scanRaw returns a codec for the fields encoded as exactly N bytes without a length
+// prefix, selected with a `binary:",raw"` tag on byte arrays or a `binary:",raw=N"` tag
+// on strings, for fixed-layout protocols such as magic numbers or fixed-length ids.
+func scanRaw(t reflect.Type, options tagOptions) (Codec, error) {
+	value, _ := options.Lookup("raw")
+	size := -1
+	if value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			return nil, errors.New("binary: invalid raw size " + strconv.Quote(value) + " for " + t.String())
+		}
+		size = n
+	}
+
+	switch {
+	case t.Kind() == reflect.Array && t.Elem().Kind() == reflect.Uint8:
+		if size >= 0 && size != t.Len() {
+			return nil, errors.New("binary: raw size " + value + " does not match " + t.String())
+		}
+		return new(byteArrayCodec), nil
+	case t.Kind() == reflect.String && size > 0:
+		return &rawStringCodec{size: size}, nil
+	case t.Kind() == reflect.String:
+		return nil, errors.New("binary: raw encoding requires a size for " + t.String())
+	default:
+		return nil, errors.New("binary: raw encoding is not supported for " + t.String())
+	}
+}
+
+// ------------------------------------------------------------------------------
+
+// rawStringCodec represents a codec for strings which are encoded as exactly a number of
+// bytes, padded with zeros, without a length prefix. The trailing zeros are removed when
+// decoding, and strings longer than the size can not be encoded.
+type rawStringCodec struct {
+	size int // The number of bytes of the encoded strings
+}
+
+// Encode encodes a value into the encoder.
+func (c *rawStringCodec) EncodeTo(e *Encoder, rv reflect.Value) error {
+	s := rv.String()
+	if len(s) > c.size {
+		return errors.New("binary: string of " + strconv.Itoa(len(s)) + " bytes exceeds its raw size of " + strconv.Itoa(c.size))
+	}
+
+	e.Write(stringToBinary(s))
+	for n := c.size - len(s); n > 0; n -= len(e.scratch) {
+		e.Write(zeroBytes(e.scratch[:min(n, len(e.scratch))]))
+	}
+	return nil
+}
+
+// Decode decodes into a reflect value from the decoder.
+func (c *rawStringCodec) DecodeTo(d *Decoder, rv reflect.Value) (err error) {
+	var b []byte
+	var out string
+	if b, err = d.Slice(c.size); err != nil {
+		return
+	}
+	if out, err = d.toString(bytes.TrimRight(b, "\x00")); err == nil {
+		rv.SetString(out)
+	}
+	return
+}
+
+// Size returns the encoded size of the value.
+func (c *rawStringCodec) Size(rv reflect.Value) int {
+	if rv.Len() > c.size {
+		return -1
+	}
+	return c.size
+}
+
+// zeroBytes zeroes a byte slice and returns it.
+func zeroBytes(b []byte) []byte {
+	clear(b)
+	return b
+}
@@ -0,0 +1,112 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package binary
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type rawHeader struct {
+	Magic   [4]byte  `binary:",raw"`
+	ID      [16]byte `binary:",raw=16"`
+	Name    string   `binary:",raw=12"`
+	Version uint16   `binary:",fixed"`
+}
+
+func TestRaw(t *testing.T) {
+	v := rawHeader{
+		Magic:   [4]byte{'B', 'I', 'N', '1'},
+		ID:      [16]byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16},
+		Name:    "gateway-01",
+		Version: 2,
+	}
+
+	b, err := Marshal(&v)
+	assert.NoError(t, err)
+	assert.Equal(t, 4+16+12+2, len(b))
+	assert.Equal(t, []byte("BIN1"), b[:4])
+	assert.Equal(t, []byte("gateway-01\x00\x00"), b[20:32])
+
+	size, err := Size(&v)
+	assert.NoError(t, err)
+	assert.Equal(t, len(b), size)
+
+	// Decoding from a slice and from a stream give the same value
+	for _, d := range []*Decoder{
+		NewDecoder(NewSliceReader(b)),
+		NewDecoder(io.MultiReader(bytes.NewReader(b))),
+	} {
+		var out rawHeader
+		assert.NoError(t, d.Decode(&out))
+		assert.Equal(t, v, out)
+	}
+}
+
+func TestRaw_Full(t *testing.T) {
+	v := rawHeader{Name: "exactly-12by"}
+	b, err := Marshal(&v)
+	assert.NoError(t, err)
+
+	var out rawHeader
+	assert.NoError(t, Unmarshal(b, &out))
+	assert.Equal(t, v, out)
+
+	// Strings longer than their size can not be encoded
+	v.Name = "more-than-12-bytes"
+	_, err = Marshal(&v)
+	assert.Error(t, err)
+}
+
+func TestRaw_Truncated(t *testing.T) {
+	b, err := Marshal(&rawHeader{Name: "short"})
+	assert.NoError(t, err)
+
+	var out rawHeader
+	assert.Error(t, Unmarshal(b[:25], &out))
+}
+
+func TestRaw_Invalid(t *testing.T) {
+	for _, v := range []interface{}{
+		struct {
+			V string `binary:",raw"`
+		}{},
+		struct {
+			V string `binary:",raw=0"`
+		}{},
+		struct {
+			V [4]byte `binary:",raw=8"`
+		}{},
+		struct {
+			V []byte `binary:",raw=8"`
+		}{},
+		struct {
+			V uint32 `binary:",raw"`
+		}{},
+	} {
+		_, err := Marshal(v)
+		assert.Error(t, err)
+	}
+}
+
+func TestRaw_Schema(t *testing.T) {
+	s, err := SchemaOf(reflect.TypeOf(rawHeader{}))
+	assert.NoError(t, err)
+	assert.Equal(t, KindArray, s.Fields[2].Schema.Kind)
+	assert.Equal(t, 12, s.Fields[2].Schema.Len)
+
+	// Values can be read back from their schema
+	b, err := Marshal(&rawHeader{Name: "edge", Version: 7}, SelfDescribing())
+	assert.NoError(t, err)
+
+	out, err := DecodeAny(b, SelfDescribing())
+	assert.NoError(t, err)
+	fields := out.(map[string]interface{})
+	assert.Len(t, fields["Name"], 12)
+	assert.EqualValues(t, 7, fields["Version"])
+}
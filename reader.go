@@ -9,21 +9,25 @@
 package binary
 
 import (
+	"encoding/binary"
+	"errors"
 	"io"
 )
 
-// A Reader implements the io.Reader, io.ReaderAt, io.WriterTo, io.Seeker,
-// io.ByteScanner, and io.RuneScanner interfaces by reading from
-// a byte slice.
-// Unlike a Buffer, a Reader is read-only and supports seeking.
-type reader struct {
+// SliceReader implements the io.Reader and io.ByteReader interfaces by reading from a
+// byte slice. A Decoder created over a SliceReader takes the same path as Unmarshal: it
+// reads variable-size integers straight from the slice, and points strings and byte
+// slices into it with the ZeroCopy option, which is faster than over a bytes.Reader.
+// Resetting it to another slice allows a long-lived decoder to be reused for in-memory
+// payloads without any allocation.
+type SliceReader struct {
 	s []byte
 	i int64 // current reading index
 }
 
 // Len returns the number of bytes of the unread portion of the
 // slice.
-func (r *reader) Len() int {
+func (r *SliceReader) Len() int {
 	if r.i >= int64(len(r.s)) {
 		return 0
 	}
@@ -34,10 +38,10 @@ func (r *reader) Len() int {
 // Size is the number of bytes available for reading via ReadAt.
 // The returned value is always the same and is not affected by calls
 // to any other method.
-func (r *reader) Size() int64 { return int64(len(r.s)) }
+func (r *SliceReader) Size() int64 { return int64(len(r.s)) }
 
 // Read implements the io.Reader interface.
-func (r *reader) Read(b []byte) (n int, err error) {
+func (r *SliceReader) Read(b []byte) (n int, err error) {
 	if r.i >= int64(len(r.s)) {
 		return 0, io.EOF
 	}
@@ -48,7 +52,7 @@ func (r *reader) Read(b []byte) (n int, err error) {
 }
 
 // ReadByte implements the io.ByteReader interface.
-func (r *reader) ReadByte() (byte, error) {
+func (r *SliceReader) ReadByte() (byte, error) {
 	if r.i >= int64(len(r.s)) {
 		return 0, io.EOF
 	}
@@ -58,11 +62,66 @@ func (r *reader) ReadByte() (byte, error) {
 	return b, nil
 }
 
+// The error returned for variable-size integers which do not fit in 64 bits, which is the
+// same as the one of encoding/binary.
+var errVarintOverflow = errors.New("binary: varint overflows a 64-bit integer")
+
+// readUvarint reads a variable-size unsigned integer straight from the slice, with the
+// same results as binary.ReadUvarint but without an interface call for every byte.
+func (r *SliceReader) readUvarint() (uint64, error) {
+	if r.i >= int64(len(r.s)) {
+		return 0, io.EOF
+	}
+
+	b := r.s[r.i:]
+	if b[0] < 0x80 {
+		r.i++
+		return uint64(b[0]), nil
+	}
+
+	var x uint64
+	var s uint
+	for i, c := range b {
+		if i == binary.MaxVarintLen64 {
+			r.i += int64(i)
+			return x, errVarintOverflow
+		}
+
+		if c < 0x80 {
+			r.i += int64(i + 1)
+			if i == binary.MaxVarintLen64-1 && c > 1 {
+				return x, errVarintOverflow
+			}
+			return x | uint64(c)<<s, nil
+		}
+
+		x |= uint64(c&0x7f) << s
+		s += 7
+	}
+
+	r.i = int64(len(r.s))
+	return x, io.ErrUnexpectedEOF
+}
+
+// readFull reads exactly len(b) bytes, with the same results as io.ReadFull.
+func (r *SliceReader) readFull(b []byte) (int, error) {
+	n := copy(b, r.s[min(r.i, int64(len(r.s))):])
+	r.i += int64(n)
+	switch {
+	case n == len(b):
+		return n, nil
+	case n == 0:
+		return 0, io.EOF
+	default:
+		return n, io.ErrUnexpectedEOF
+	}
+}
+
 // Slice selects a sub-slice of next bytes. This is similar to Read() but does not
 // actually perform a copy, but simply uses the underlying slice (if available) and
 // returns a sub-slice pointing to the same array. Since this requires access
-// to the underlying data, this is only available for our default reader.
-func (r *reader) Slice(n int) ([]byte, error) {
+// to the underlying data, this is only available for the slice reader.
+func (r *SliceReader) Slice(n int) ([]byte, error) {
 	if r.i+int64(n) > int64(len(r.s)) {
 		return nil, io.EOF
 	}
@@ -72,13 +131,13 @@ func (r *reader) Slice(n int) ([]byte, error) {
 	return r.s[cur:r.i], nil
 }
 
-// Reset resets the Reader to be reading from b.
-func (r *reader) Reset(b []byte) {
+// Reset resets the reader to be reading from b.
+func (r *SliceReader) Reset(b []byte) {
 	r.s = b
 	r.i = 0
 }
 
-// newReader returns a new Reader reading from b.
-func newReader(b []byte) *reader {
-	return &reader{b, 0}
+// NewSliceReader returns a new reader reading from b.
+func NewSliceReader(b []byte) *SliceReader {
+	return &SliceReader{b, 0}
 }
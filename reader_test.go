@@ -4,16 +4,116 @@
 package binary
 
 import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+	"math/rand"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 )
 
 func TestReader_Slice(t *testing.T) {
-	r := newReader([]byte("0123456789"))
+	r := NewSliceReader([]byte("0123456789"))
 
 	out, err := r.Slice(3)
 	assert.NoError(t, err)
 	assert.Len(t, out, 3)
 	assert.Equal(t, "012", string(out))
 }
+
+func TestReader_Uvarint(t *testing.T) {
+	inputs := [][]byte{
+		{},
+		{0x00},
+		{0x7f},
+		{0x80},
+		{0x80, 0x01},
+		{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01},
+		{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x02},
+		{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01},
+		binary.AppendUvarint(nil, math.MaxUint64),
+		binary.AppendUvarint([]byte{0x80}, 300),
+	}
+
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 100; i++ {
+		inputs = append(inputs, binary.AppendUvarint(nil, rng.Uint64()>>uint(rng.Intn(64))))
+	}
+
+	// The results must match the ones of encoding/binary, read after read
+	for _, in := range inputs {
+		expect, actual := bytes.NewReader(in), NewSliceReader(in)
+		for {
+			v1, err1 := binary.ReadUvarint(expect)
+			v2, err2 := actual.readUvarint()
+			assert.Equal(t, v1, v2, "%x", in)
+			assert.Equal(t, err1, err2, "%x", in)
+			assert.Equal(t, expect.Len(), actual.Len(), "%x", in)
+			if err1 != nil {
+				break
+			}
+		}
+	}
+}
+
+func TestReader_ReadFull(t *testing.T) {
+	for _, n := range []int{0, 3, 10, 11} {
+		in := []byte("0123456789")
+		expect, actual := bytes.NewReader(in), NewSliceReader(in)
+		for i := 0; i < 3; i++ {
+			b1, b2 := make([]byte, n), make([]byte, n)
+			n1, err1 := io.ReadFull(expect, b1)
+			n2, err2 := actual.readFull(b2)
+			assert.Equal(t, n1, n2)
+			assert.Equal(t, err1, err2)
+			assert.Equal(t, b1, b2)
+		}
+	}
+}
+
+func BenchmarkReader_Uvarint(b *testing.B) {
+	var in []byte
+	for i := 0; i < 1024; i++ {
+		in = binary.AppendUvarint(in, uint64(i*i))
+	}
+
+	b.ReportAllocs()
+	d := newDecoder(NewSliceReader(nil))
+	for n := 0; n < b.N; n++ {
+		d.s.Reset(in)
+		for i := 0; i < 1024; i++ {
+			_, _ = d.ReadUvarint()
+		}
+	}
+}
+
+func TestSliceReader_Decoder(t *testing.T) {
+	type T struct {
+		Name string
+		Data []byte
+	}
+
+	r := NewSliceReader(nil)
+	d := NewDecoder(r, ZeroCopy())
+	for _, v := range []T{{Name: "a", Data: []byte{1}}, {Name: "bc", Data: []byte{2, 3}}} {
+		b, err := Marshal(&v)
+		assert.NoError(t, err)
+
+		r.Reset(b)
+		var out T
+		assert.NoError(t, d.Decode(&out))
+		assert.Equal(t, v, out)
+		assert.Equal(t, 0, r.Len())
+		assert.Equal(t, int64(len(b)), r.Size())
+
+		// The byte slice points into the payload
+		assert.True(t, &b[len(b)-1] == &out.Data[len(out.Data)-1])
+	}
+
+	d.Reset(NewSliceReader([]byte{5}))
+	v, err := d.ReadUvarint()
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(5), v)
+}
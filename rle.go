@@ -0,0 +1,155 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package binary
+
+import (
+	"errors"
+	"math"
+	"reflect"
+)
+
+// The error returned when a run is empty or longer than the remaining elements
+var errInvalidRun = errors.New("binary: invalid run length")
+
+// scanRLE returns a codec for slices of booleans, numbers or strings which encodes the
+// runs of identical elements once along with their length, selected with a
+// `binary:",rle"` tag. This is very compact for slices with long runs, such as flags or
+// sensor readings, but slightly larger than the default encoding for the others.
+func scanRLE(t reflect.Type) (Codec, error) {
+	if t.Kind() == reflect.Slice {
+		switch t.Elem().Kind() {
+		case reflect.Bool, reflect.String, reflect.Float32, reflect.Float64,
+			reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			elemCodec, err := scanType(t.Elem())
+			if err != nil {
+				return nil, nestedError(err, "[]")
+			}
+
+			return &rleCodec{elemCodec: elemCodec}, nil
+		}
+	}
+
+	return nil, errors.New("binary: rle encoding is not supported for " + t.String())
+}
+
+// ------------------------------------------------------------------------------
+
+// rleCodec represents a codec for slices which are prefixed with their length and
+// encoded as runs of identical elements, each run being its length followed by the
+// element.
+type rleCodec struct {
+	elemCodec Codec // The codec of the elements
+}
+
+// Encode encodes a value into the encoder.
+func (c *rleCodec) EncodeTo(e *Encoder, rv reflect.Value) (err error) {
+	l := rv.Len()
+	e.WriteUvarint(uint64(l))
+	for i := 0; i < l; {
+		n := runLength(rv, i)
+		e.WriteUvarint(uint64(n))
+		if err = c.elemCodec.EncodeTo(e, rv.Index(i)); err != nil {
+			return
+		}
+		i += n
+	}
+	return
+}
+
+// Decode decodes into a reflect value from the decoder.
+func (c *rleCodec) DecodeTo(d *Decoder, rv reflect.Value) (err error) {
+	var l int
+	if l, err = d.readSliceLen(); err != nil {
+		return
+	}
+
+	var slice reflect.Value
+	if slice, err = d.makeSlice(rv, l); err == nil {
+		for i := 0; i < l; {
+			var n int
+			if n, err = d.readRun(l - i); err != nil {
+				return
+			}
+			for i+n > slice.Len() {
+				slice = growSlice(slice, l)
+			}
+
+			first := slice.Index(i)
+			if err = c.elemCodec.DecodeTo(d, first); err != nil {
+				return
+			}
+
+			for j := i + 1; j < i+n; j++ {
+				slice.Index(j).Set(first)
+			}
+			i += n
+		}
+
+		rv.Set(slice)
+	}
+	return
+}
+
+// Size returns the encoded size of the value.
+func (c *rleCodec) Size(rv reflect.Value) int {
+	l := rv.Len()
+	size := uvarintSize(uint64(l))
+	for i := 0; i < l; {
+		n := runLength(rv, i)
+		elem := sizeOf(c.elemCodec, rv.Index(i))
+		if elem < 0 {
+			return -1
+		}
+
+		size += uvarintSize(uint64(n)) + elem
+		i += n
+	}
+	return size
+}
+
+// runLength returns the number of identical elements of a slice starting at an index.
+// Floating-point numbers are compared by their bits, so that they round-trip exactly.
+func runLength(rv reflect.Value, i int) (n int) {
+	l, first := rv.Len(), rv.Index(i)
+	for n = 1; i+n < l; n++ {
+		next := rv.Index(i + n)
+		switch first.Kind() {
+		case reflect.Bool:
+			if next.Bool() != first.Bool() {
+				return
+			}
+		case reflect.String:
+			if next.String() != first.String() {
+				return
+			}
+		case reflect.Float32, reflect.Float64:
+			if math.Float64bits(next.Float()) != math.Float64bits(first.Float()) {
+				return
+			}
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			if next.Int() != first.Int() {
+				return
+			}
+		default:
+			if next.Uint() != first.Uint() {
+				return
+			}
+		}
+	}
+	return
+}
+
+// readRun reads the length of a run, which can not exceed the remaining elements.
+func (d *Decoder) readRun(remaining int) (int, error) {
+	n, err := d.ReadUvarint()
+	switch {
+	case err != nil:
+		return 0, err
+	case n == 0 || n > uint64(remaining):
+		return 0, errInvalidRun
+	default:
+		return int(n), nil
+	}
+}
@@ -0,0 +1,88 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package binary
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type readings struct {
+	Flags   []bool    `binary:",rle"`
+	Values  []float64 `binary:",rle"`
+	Status  []string  `binary:",rle"`
+	Counter []int32   `binary:",rle"`
+}
+
+func TestRLE(t *testing.T) {
+	v := &readings{
+		Flags:   make([]bool, 1000),
+		Values:  []float64{1.5, 1.5, 1.5, math.NaN(), math.NaN(), math.Copysign(0, -1), 0},
+		Status:  []string{"ok", "ok", "ok", "ok", "down", "ok"},
+		Counter: []int32{-1, -1, 2},
+	}
+	v.Flags[500] = true
+
+	for _, opts := range [][]Option{nil, {InternStrings()}, {Versioned()}} {
+		b, err := Marshal(v, opts...)
+		assert.NoError(t, err)
+		assert.True(t, len(b) < 100)
+
+		size, err := Size(v, opts...)
+		assert.NoError(t, err)
+		assert.Equal(t, len(b), size)
+
+		var out readings
+		assert.NoError(t, Unmarshal(b, &out, opts...))
+		assert.Equal(t, v.Flags, out.Flags)
+		assert.Equal(t, v.Status, out.Status)
+		assert.Equal(t, v.Counter, out.Counter)
+		for i := range v.Values {
+			assert.Equal(t, math.Float64bits(v.Values[i]), math.Float64bits(out.Values[i]))
+		}
+	}
+
+	// Runs of flags are encoded with their length
+	b, err := Marshal(&readings{Flags: v.Flags})
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{0xe8, 0x07, 0xf4, 0x03, 0, 1, 1, 0xf3, 0x03, 0, 0, 0, 0}, b)
+}
+
+func TestRLE_Invalid(t *testing.T) {
+	var out readings
+	for _, b := range [][]byte{
+		{3, 0, 1},       // Empty run
+		{3, 4, 1},       // Run longer than the slice
+		{3, 2, 1, 2, 0}, // Truncated
+	} {
+		assert.Error(t, Unmarshal(b, &out))
+		assert.Error(t, Unmarshal(b, &out, Fields("Values")))
+	}
+
+	_, err := Marshal(&struct {
+		Values [][]int `binary:",rle"`
+	}{})
+	assert.Error(t, err)
+}
+
+func TestRLE_Schema(t *testing.T) {
+	v := &readings{Status: []string{"a", "a", "b"}}
+	b, err := Marshal(v, SelfDescribing())
+	assert.NoError(t, err)
+
+	out, err := DecodeAny(b, SelfDescribing())
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{"a", "a", "b"}, out.(map[string]interface{})["Status"])
+
+	var paths []string
+	assert.NoError(t, Walk(b, nil, func(t Token) error {
+		if t.Schema.Kind == KindString {
+			paths = append(paths, t.Path)
+		}
+		return nil
+	}, SelfDescribing()))
+	assert.Equal(t, []string{".Status[0]", ".Status[2]"}, paths)
+}
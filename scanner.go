@@ -4,53 +4,178 @@
 package binary
 
 import (
+	"encoding"
 	"errors"
 	"reflect"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
 )
 
 // Map of all the schemas we've encountered so far
-var schemas = new(sync.Map)
+var schemas = new(codecCache)
+
+// Map of all the codecs registered explicitly
+var registered = new(sync.Map)
+
+// RegisterCodec registers a codec to use for a specific type, overriding the codec the
+// scanner would otherwise choose. This also applies when the type is nested within other
+// types. Registering a codec invalidates previously scanned types, so it is best done
+// once during initialization. A nil codec removes the registration.
+func RegisterCodec(t reflect.Type, c Codec) {
+	if c == nil {
+		registered.Delete(t)
+	} else {
+		registered.Store(t, c)
+	}
+
+	// Invalidate the caches, as nested types may have been scanned with the previous codec
+	invalidate()
+}
+
+// invalidate clears the codecs and the schemas scanned so far.
+func invalidate() {
+	overridden.Store(overridesBuiltins())
+	schemas.Reset()
+	described.Range(func(k, _ interface{}) bool {
+		described.Delete(k)
+		return true
+	})
+}
+
+// TypeError is returned when a type can not be encoded, because a type nested within it
+// is not supported or is misconfigured.
+type TypeError struct {
+	Type reflect.Type // The type which was scanned
+	Path string       // The path of the offending type within it, such as ".Items[].Meta"
+	Err  error        // The reason why the offending type can not be encoded
+}
+
+// Error returns the error message, which starts with the path of the offending type.
+func (e *TypeError) Error() string {
+	path := e.Path
+	if e.Type != nil {
+		path = e.Type.String() + path
+	}
+
+	reason := strings.TrimPrefix(e.Err.Error(), "binary: ")
+	if path == "" {
+		return "binary: " + reason
+	}
+	return "binary: " + path + ": " + reason
+}
+
+// Unwrap returns the reason why the type can not be encoded.
+func (e *TypeError) Unwrap() error {
+	return e.Err
+}
+
+// nestedError prefixes the path of an error with the segment leading to the type which
+// caused it, such as a field name or "[]" for the elements of slices, arrays and maps.
+func nestedError(err error, segment string) error {
+	if e, ok := err.(*TypeError); ok {
+		return &TypeError{Path: segment + e.Path, Err: e.Err}
+	}
+	return &TypeError{Path: segment, Err: err}
+}
+
+// rootedError sets the type which was scanned on an error, if it has a path.
+func rootedError(err error, t reflect.Type) error {
+	if e, ok := err.(*TypeError); ok {
+		return &TypeError{Type: t, Path: e.Path, Err: e.Err}
+	}
+	return err
+}
+
+// Validate checks that the values of a type can be encoded with the options, including
+// the types of the pointers it contains, which are otherwise scanned once they are first
+// encountered. This allows checking the types at startup rather than on first use. The
+// returned error is a *TypeError which contains the path of the offending type.
+func Validate(t reflect.Type, opts ...Option) error {
+	if t == nil {
+		return &TypeError{Err: errNilType}
+	}
+
+	_, err := SchemaOf(t, opts...)
+	return err
+}
 
 // Scan gets a codec for the type and uses a cached schema if the type was
 // previously scanned.
 func scan(t reflect.Type) (c Codec, err error) {
 
 	// Attempt to load from cache first
-	if f, ok := schemas.Load(t); ok {
-		c = f.(Codec)
-		return
+	if c, ok := schemas.Load(t); ok {
+		return c, nil
 	}
 
 	// Scan for the first time
 	c, err = scanType(t)
 	if err != nil {
-		return
+		return nil, rootedError(err, t)
 	}
 
-	// Load or store again
-	if f, ok := schemas.LoadOrStore(t, c); ok {
-		c = f.(Codec)
-		return
-	}
+	// Load or store again, as the type may have been scanned concurrently
+	c, _ = schemas.LoadOrStore(t, c)
 	return
 }
 
 // ScanType scans the type
 func scanType(t reflect.Type) (Codec, error) {
+	c, err := scanCodec(t)
+	if err != nil {
+		return nil, err
+	}
+	return withHooks(t, c), nil
+}
+
+// scanCodec selects the codec of a type, without its validator.
+func scanCodec(t reflect.Type) (Codec, error) {
+	if c, ok := registered.Load(t); ok {
+		return c.(Codec), nil
+	}
+
 	if custom, ok := scanCustomCodec(t); ok {
 		return custom, nil
 	}
 
+	if generated, ok := scanGenerated(t); ok {
+		return generated, nil
+	}
+
+	if builtin, ok := scanBuiltin(t); ok {
+		return builtin, nil
+	}
+
 	if custom, ok := scanBinaryMarshaler(t); ok {
 		return custom, nil
 	}
 
+	return scanReflect(t)
+}
+
+// scanReflect scans the type based on its kind, using the reflection-based codecs.
+func scanReflect(t reflect.Type) (Codec, error) {
 	switch t.Kind() {
+	case reflect.Interface:
+		return new(interfaceCodec), nil
+
+	case reflect.Ptr:
+		return &reflectPointerCodec{
+			elemType: t.Elem(),
+		}, nil
+
 	case reflect.Array:
+
+		// Fast-path for byte arrays, such as UUIDs or hashes
+		if elemKind(t) == reflect.Uint8 {
+			return new(byteArrayCodec), nil
+		}
+
 		elemCodec, err := scanType(t.Elem())
 		if err != nil {
-			return nil, err
+			return nil, nestedError(err, "[]")
 		}
 
 		return &reflectArrayCodec{
@@ -60,7 +185,7 @@ func scanType(t reflect.Type) (Codec, error) {
 	case reflect.Slice:
 
 		// Fast-paths for simple numeric slices and string slices
-		switch t.Elem().Kind() {
+		switch elemKind(t) {
 
 		case reflect.Uint8:
 			return new(byteSliceCodec), nil
@@ -91,27 +216,51 @@ func scanType(t reflect.Type) (Codec, error) {
 		default:
 			elemCodec, err := scanType(t.Elem())
 			if err != nil {
-				return nil, err
+				return nil, nestedError(err, "[]")
 			}
 
-			return &reflectSliceCodec{
-				elemCodec: elemCodec,
-			}, nil
+			return scanContainer(t, sliceCodecOf(t, elemCodec)), nil
 		}
 
 	case reflect.Struct:
-		s := scanStruct(t)
+		s, err := scanStruct(t)
+		if err != nil {
+			return nil, nestedError(err, "")
+		}
+
 		var v reflectStructCodec
-		for _, i := range s.fields {
-			field := t.Field(i)
-			if c, err := scanType(field.Type); err == nil {
-				v = append(v, fieldCodec{
-					Index: i,
-					Codec: c,
-				})
+		for _, f := range s.fields {
+			var field reflect.StructField
+			if f.Path != nil {
+				field = t.FieldByIndex(f.Path)
 			} else {
-				return nil, err
+				field = t.Field(f.Index)
+			}
+
+			// Unexported fields are only scanned when included, so their error is deferred
+			c, err := scanField(field.Type, f.Tag)
+			unexported := field.PkgPath != ""
+			if err != nil && !unexported {
+				return nil, nestedError(err, "."+f.Name)
 			}
+
+			def, defErr := parseDefault(field.Type, f.Tag)
+			if defErr != nil {
+				return nil, nestedError(defErr, "."+f.Name)
+			}
+
+			v = append(v, fieldCodec{
+				Index:      f.Index,
+				Path:       f.Path,
+				Name:       f.Name,
+				JSON:       f.JSON,
+				ID:         f.ID,
+				Codec:      c,
+				Unexported: unexported,
+				OmitEmpty:  f.Tag.Options.Contains("omitempty"),
+				Default:    def,
+				err:        err,
+			})
 		}
 
 		return &v, nil
@@ -119,18 +268,18 @@ func scanType(t reflect.Type) (Codec, error) {
 	case reflect.Map:
 		key, err := scanType(t.Key())
 		if err != nil {
-			return nil, err
+			return nil, nestedError(err, "[key]")
 		}
 
 		val, err := scanType(t.Elem())
 		if err != nil {
-			return nil, err
+			return nil, nestedError(err, "[]")
 		}
 
-		return &reflectMapCodec{
+		return scanContainer(t, &reflectMapCodec{
 			key: key,
 			val: val,
-		}, nil
+		}), nil
 
 	case reflect.String:
 		return new(stringCodec), nil
@@ -173,45 +322,223 @@ func scanType(t reflect.Type) (Codec, error) {
 		return new(float64Codec), nil
 	}
 
-	return nil, errors.New("binary: unsupported type " + t.String())
+	return nil, &TypeError{Err: errors.New("binary: unsupported type " + t.String())}
+}
+
+// scanField scans the type of a struct field, taking the options of its tag into account.
+func scanField(t reflect.Type, tag fieldTag) (c Codec, err error) {
+	switch {
+	case tag.Options.Contains("fixed"):
+		c, err = scanFixed(t, 0)
+	case tag.Options.Contains("fixed32"):
+		c, err = scanFixed(t, 4)
+	case tag.Options.Contains("fixed64"):
+		c, err = scanFixed(t, 8)
+	case tag.Options.Contains("zigzag"):
+		c, err = scanVarint(t, true)
+	case tag.Options.Contains("varint"):
+		c, err = scanVarint(t, false)
+	case tag.Options.Contains("unixnano"):
+		c, err = scanTime(t)
+	case tag.Options.Contains("text"):
+		c, err = scanText(t)
+	case tag.Options.Contains("delta"):
+		c, err = scanDelta(t)
+	case tag.Options.Contains("rle"):
+		c, err = scanRLE(t)
+	case tag.Options.Contains("bits"):
+		c, err = scanBits(t)
+	case tag.Options.Contains("gorilla"):
+		c, err = scanGorilla(t)
+	case tag.Options.Contains("vbyte"):
+		c, err = scanVByte(t)
+	case tag.Options.Contains("packed"):
+		c, err = scanPacked(t)
+	case tag.Options.Contains("raw"):
+		c, err = scanRaw(t, tag.Options)
+	case tag.Options.Contains("sql"):
+		c, err = scanSQL(t)
+	case tag.Options.Contains("columnar"):
+		c, err = scanColumnar(t)
+	default:
+		return scanType(t)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+	return withHooks(t, c), nil
 }
 
 type scannedStruct struct {
-	fields []int
+	fields []scannedField
+}
+
+type scannedField struct {
+	Index int    // The index of the field in the struct
+	Path  []int  // The index sequence of a field promoted from an embedded struct
+	Name  string // The name of the field, either from the tag or the declaration
+	ID    int    // The identifier of the field, which also defines the order
+	Tag   fieldTag
+	JSON  jsonTag // The json tag of the field, honored with the JSONTags option
 }
 
-func scanStruct(t reflect.Type) (meta *scannedStruct) {
-	l := t.NumField()
+// scanStruct scans the fields of a struct which need to be encoded. Fields can be
+// skipped with a `binary:"-"` tag and their order can be changed by assigning them
+// an explicit identifier with a `binary:",id=N"` tag. Fields without an explicit
+// identifier are numbered sequentially after the previous field. The fields of
+// embedded structs are promoted, as if they were declared in the outer struct.
+func scanStruct(t reflect.Type) (meta *scannedStruct, err error) {
 	meta = new(scannedStruct)
-	for i := 0; i < l; i++ {
-		if t.Field(i).Name != "_" {
-			meta.fields = append(meta.fields, i)
-		}
+	seen := make(map[int]string, t.NumField())
+	if _, err = meta.scan(t, nil, 1, seen); err != nil {
+		return nil, err
 	}
+
+	// Order the fields by their identifier
+	sort.SliceStable(meta.fields, func(i, j int) bool {
+		return meta.fields[i].ID < meta.fields[j].ID
+	})
 	return
 }
 
-// scanBinaryMarshaler scans whether a type has a custom binary marshaling implemented.
-func scanBinaryMarshaler(t reflect.Type) (out *customCodec, ok bool) {
-	out = new(customCodec)
-	if m, ok := t.MethodByName("MarshalBinary"); ok {
-		out.marshaler = &m
-	} else if m, ok := reflect.PtrTo(t).MethodByName("MarshalBinary"); ok {
-		out.ptrMarshaler = &m
+// scan appends the fields of a struct, recursing into the embedded structs, and returns
+// the identifier of the next field.
+func (meta *scannedStruct) scan(t reflect.Type, path []int, next int, seen map[int]string) (int, error) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := parseTag(field)
+		if field.Name == "_" || tag.Skip {
+			continue
+		}
+
+		f := scannedField{Index: i, Name: field.Name, ID: next, Tag: tag, JSON: parseJSONTag(field)}
+		if path != nil {
+			f.Path = appendPath(path, i)
+		}
+		if tag.Name != "" {
+			f.Name = tag.Name
+		}
+
+		if v, ok := tag.Options.Lookup("id"); ok {
+			var err error
+			if f.ID, err = strconv.Atoi(v); err != nil || f.ID <= 0 {
+				return 0, errors.New("binary: invalid id '" + v + "' on field " + t.String() + "." + field.Name)
+			}
+		}
+
+		// Promote the fields of embedded structs, unless they are named explicitly with a
+		// tag or are encoded by a codec of their own. An identifier on the embedded struct
+		// sets the identifier of its first field. Skipping it with a json tag skips them.
+		if field.Anonymous && tag.Name == "" && isPromoted(field.Type) {
+			var err error
+			promoted := len(meta.fields)
+			if next, err = meta.scan(field.Type, appendPath(path, i), f.ID, seen); err != nil {
+				return 0, err
+			}
+			for k := promoted; f.JSON.Skip && k < len(meta.fields); k++ {
+				meta.fields[k].JSON.Skip = true
+			}
+			continue
+		}
+
+		if other, ok := seen[f.ID]; ok {
+			return 0, errors.New("binary: duplicate id " + strconv.Itoa(f.ID) + " on fields " +
+				t.String() + "." + other + " and " + t.String() + "." + field.Name)
+		}
+
+		seen[f.ID] = field.Name
+		meta.fields = append(meta.fields, f)
+		next = f.ID + 1
 	}
 
-	if m, ok := t.MethodByName("UnmarshalBinary"); ok {
-		out.unmarshaler = &m
-	} else if m, ok := reflect.PtrTo(t).MethodByName("UnmarshalBinary"); ok {
-		out.ptrUnmarshaler = &m
+	return next, nil
+}
+
+// appendPath returns a copy of the index sequence with an index appended to it.
+func appendPath(path []int, i int) []int {
+	return append(path[:len(path):len(path)], i)
+}
+
+// isPromoted returns whether the fields of an embedded type are promoted to the outer
+// struct, which is the case for structs without a codec of their own.
+func isPromoted(t reflect.Type) bool {
+	if t.Kind() != reflect.Struct {
+		return false
 	}
 
-	// Checks whether we have both marshaler and unmarshaler attached
-	if (out.marshaler != nil || out.ptrMarshaler != nil) && (out.unmarshaler != nil || out.ptrUnmarshaler != nil) {
-		return out, true
+	if _, ok := registered.Load(t); ok {
+		return false
 	}
 
-	return nil, false
+	_, custom := scanCustomCodec(t)
+	_, generated := scanGenerated(t)
+	_, builtin := scanBuiltin(t)
+	_, marshaler := scanBinaryMarshaler(t)
+	return !custom && !generated && !builtin && !marshaler
+}
+
+// The reflected types of the marshaling interfaces
+var (
+	typeBinaryMarshaler   = reflect.TypeOf((*encoding.BinaryMarshaler)(nil)).Elem()
+	typeBinaryUnmarshaler = reflect.TypeOf((*encoding.BinaryUnmarshaler)(nil)).Elem()
+)
+
+// scanBinaryMarshaler scans whether a type implements both encoding.BinaryMarshaler
+// and encoding.BinaryUnmarshaler, either on the value or on the pointer receiver.
+func scanBinaryMarshaler(t reflect.Type) (out *binaryMarshalerCodec, ok bool) {
+	if t.Kind() == reflect.Ptr {
+		return nil, false // Pointers are handled by the pointer codec
+	}
+
+	ptr := reflect.PtrTo(t)
+	out = new(binaryMarshalerCodec)
+	switch {
+	case t.Implements(typeBinaryMarshaler):
+	case ptr.Implements(typeBinaryMarshaler):
+		out.ptrMarshaler = true
+	default:
+		return nil, false
+	}
+
+	switch {
+	case t.Kind() != reflect.Ptr && ptr.Implements(typeBinaryUnmarshaler):
+		out.ptrUnmarshaler = true
+	case t.Implements(typeBinaryUnmarshaler):
+	default:
+		return nil, false
+	}
+
+	return out, true
+}
+
+// scanBuiltin returns the native codec of the standard library types which are
+// supported out of the box, such as time.Time, big.Int or netip.Addr.
+func scanBuiltin(t reflect.Type) (Codec, bool) {
+	if isNullable(t) {
+		return &nullCodec{elemType: t.Field(0).Type}, true
+	}
+
+	switch t {
+	case typeTime:
+		return new(timeCodec), true
+	case typeBigInt:
+		return new(bigIntCodec), true
+	case typeBigRat:
+		return new(bigRatCodec), true
+	case typeBigFloat:
+		return new(bigFloatCodec), true
+	case typeIP:
+		return new(ipCodec), true
+	case typeAddr:
+		return new(netipCodec), true
+	case typePrefix:
+		return &netipCodec{suffix: 1}, true
+	case typeAddrPort:
+		return &netipCodec{suffix: 2}, true
+	default:
+		return nil, false
+	}
 }
 
 // scanCustomCodec scans whether a type has a custom codec implemented.
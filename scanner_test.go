@@ -5,8 +5,11 @@ package binary
 
 import (
 	"bytes"
+	"errors"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -40,3 +43,273 @@ func TestScanner_Custom(t *testing.T) {
 	assert.NoError(t, err)
 	assert.NotNil(t, codec)
 }
+
+func TestScanner_Tags(t *testing.T) {
+	type T struct {
+		A string `binary:"-"`
+		B string `binary:",id=3"`
+		C string `binary:"renamed,id=1"`
+		D string
+	}
+
+	s, err := scanStruct(reflect.TypeOf(T{}))
+	assert.NoError(t, err)
+	assert.Equal(t, []scannedField{
+		{Index: 2, Name: "renamed", ID: 1, Tag: fieldTag{Name: "renamed", Options: "id=1"}},
+		{Index: 3, Name: "D", ID: 2},
+		{Index: 1, Name: "B", ID: 3, Tag: fieldTag{Options: "id=3"}},
+	}, s.fields)
+}
+
+func TestScanner_TagsInvalid(t *testing.T) {
+	type T1 struct {
+		A string `binary:",id=2"`
+		B string `binary:",id=2"`
+	}
+
+	type T2 struct {
+		A string `binary:",id=x"`
+	}
+
+	_, err := scan(reflect.TypeOf(T1{}))
+	assert.Error(t, err)
+
+	_, err = scan(reflect.TypeOf(T2{}))
+	assert.Error(t, err)
+}
+
+type testRegistered struct {
+	Value string
+}
+
+type testUpperCodec struct{}
+
+func (c *testUpperCodec) EncodeTo(e *Encoder, rv reflect.Value) error {
+	e.WriteUvarint(uint64(len(rv.Field(0).String())))
+	e.Write([]byte(strings.ToUpper(rv.Field(0).String())))
+	return nil
+}
+
+func (c *testUpperCodec) DecodeTo(d *Decoder, rv reflect.Value) error {
+	return new(stringCodec).DecodeTo(d, rv.Field(0))
+}
+
+func TestScanner_RegisterCodec(t *testing.T) {
+	type wrapper struct {
+		Inner testRegistered
+	}
+
+	rt := reflect.TypeOf(testRegistered{})
+	v := wrapper{Inner: testRegistered{Value: "hello"}}
+
+	// Scan the nested type first, to make sure the registration invalidates it
+	b, err := Marshal(&v)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("\x05hello"), b)
+
+	RegisterCodec(rt, new(testUpperCodec))
+	defer RegisterCodec(rt, nil)
+
+	b, err = Marshal(&v)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("\x05HELLO"), b)
+
+	var out wrapper
+	assert.NoError(t, Unmarshal(b, &out))
+	assert.Equal(t, "HELLO", out.Inner.Value)
+}
+
+type embeddedHeader struct {
+	ID   uint32
+	Kind string
+}
+
+type embeddedTrace struct {
+	Span uint64
+}
+
+// EmbeddedPointer is exported, as an embedded pointer is not promoted and needs to be settable
+type EmbeddedPointer struct {
+	Span uint64
+}
+
+type embeddedMeta struct {
+	embeddedTrace
+	Tags []string
+}
+
+func TestScanner_Embedded(t *testing.T) {
+	type T struct {
+		embeddedHeader
+		*EmbeddedPointer
+		embeddedMeta
+		Named   embeddedHeader `binary:"named"`
+		Created time.Time
+		Body    string
+	}
+
+	s, err := scanStruct(reflect.TypeOf(T{}))
+	assert.NoError(t, err)
+
+	var names []string
+	for _, f := range s.fields {
+		names = append(names, f.Name)
+	}
+	assert.Equal(t, []string{"ID", "Kind", "EmbeddedPointer", "Span", "Tags", "named", "Created", "Body"}, names)
+	assert.Equal(t, []int{0, 1}, s.fields[1].Path)
+	assert.Equal(t, []int{2, 0, 0}, s.fields[3].Path)
+	assert.Nil(t, s.fields[2].Path)
+
+	v := T{
+		embeddedHeader:  embeddedHeader{ID: 1, Kind: "a"},
+		EmbeddedPointer: &EmbeddedPointer{Span: 2},
+		embeddedMeta:    embeddedMeta{embeddedTrace{Span: 3}, []string{"x"}},
+		Named:           embeddedHeader{ID: 4},
+		Created:         time.Date(2013, 1, 2, 3, 4, 5, 6, time.UTC),
+		Body:            "hello",
+	}
+
+	for _, opts := range [][]Option{nil, {Versioned()}} {
+		b, err := Marshal(&v, opts...)
+		assert.NoError(t, err)
+
+		var out T
+		assert.NoError(t, Unmarshal(b, &out, opts...))
+		assert.Equal(t, v, out)
+	}
+}
+
+func TestScanner_EmbeddedLayout(t *testing.T) {
+	type flat struct {
+		ID   uint32
+		Kind string
+		Body string
+	}
+	type nested struct {
+		embeddedHeader
+		Body string
+	}
+
+	a, err := Marshal(&flat{ID: 1, Kind: "a", Body: "b"})
+	assert.NoError(t, err)
+	b, err := Marshal(&nested{embeddedHeader{ID: 1, Kind: "a"}, "b"})
+	assert.NoError(t, err)
+	assert.Equal(t, a, b)
+
+	// Promoted fields are versioned as if they were declared in the outer struct
+	a, err = Marshal(&flat{ID: 1, Kind: "a", Body: "b"}, Versioned())
+	assert.NoError(t, err)
+	b, err = Marshal(&nested{embeddedHeader{ID: 1, Kind: "a"}, "b"}, Versioned())
+	assert.NoError(t, err)
+	assert.Equal(t, a, b)
+}
+
+func TestScanner_EmbeddedIDs(t *testing.T) {
+	type T struct {
+		Body           string `binary:",id=1"`
+		embeddedHeader `binary:",id=10"`
+		Next           string
+	}
+
+	s, err := scanStruct(reflect.TypeOf(T{}))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, s.fields[0].ID)
+	assert.Equal(t, 10, s.fields[1].ID)
+	assert.Equal(t, 11, s.fields[2].ID)
+	assert.Equal(t, 12, s.fields[3].ID)
+
+	type Conflict struct {
+		ID             uint32 `binary:",id=1"`
+		embeddedHeader `binary:",id=1"`
+	}
+
+	_, err = scanStruct(reflect.TypeOf(Conflict{}))
+	assert.Error(t, err)
+}
+
+func TestScanner_AnonymousStruct(t *testing.T) {
+	type T struct {
+		Meta struct {
+			A int
+			B []string
+		}
+	}
+
+	var v T
+	v.Meta.A = 5
+	v.Meta.B = []string{"x"}
+
+	b, err := Marshal(&v)
+	assert.NoError(t, err)
+
+	var out T
+	assert.NoError(t, Unmarshal(b, &out))
+	assert.Equal(t, v, out)
+}
+
+type typeErrorItem struct {
+	Name string
+	Meta map[string]chan int
+}
+
+type typeErrorOrder struct {
+	Items []typeErrorItem
+}
+
+type typeErrorNode struct {
+	Value int
+	Next  *typeErrorNode
+	Tail  *struct{ F func() }
+}
+
+func TestScanner_TypeError(t *testing.T) {
+	tests := []struct {
+		value  interface{}
+		expect string
+	}{
+		{typeErrorOrder{}, "binary: binary.typeErrorOrder.Items[].Meta[]: unsupported type chan int"},
+		{map[chan int]string{}, "binary: map[chan int]string[key]: unsupported type chan int"},
+		{[2]func(){}, "binary: [2]func()[]: unsupported type func()"},
+		{struct {
+			A int `binary:",id=x"`
+		}{}, "binary: struct { A int \"binary:\\\",id=x\\\"\" }: invalid id 'x' on field struct { A int \"binary:\\\",id=x\\\"\" }.A"},
+		{struct {
+			T []string `binary:",fixed"`
+		}{}, "binary: struct { T []string \"binary:\\\",fixed\\\"\" }.T[]: fixed encoding is not supported for string"},
+	}
+
+	for _, tc := range tests {
+		_, err := scan(reflect.TypeOf(tc.value))
+		assert.EqualError(t, err, tc.expect)
+
+		var typeErr *TypeError
+		assert.True(t, errors.As(err, &typeErr))
+		assert.Equal(t, reflect.TypeOf(tc.value), typeErr.Type)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	assert.NoError(t, Validate(reflect.TypeOf(walked{})))
+	assert.NoError(t, Validate(reflect.TypeOf(linkedList{})))
+
+	// Pointers are scanned when first encoded, but are validated upfront
+	_, err := Marshal(&typeErrorNode{Value: 1})
+	assert.NoError(t, err)
+	assert.EqualError(t, Validate(reflect.TypeOf(typeErrorNode{})),
+		"binary: binary.typeErrorNode.Tail.F: unsupported type func()")
+
+	// Unexported fields are checked depending on the policy
+	type private struct {
+		A int
+		b chan int
+	}
+	assert.NoError(t, Validate(reflect.TypeOf(private{})))
+	assert.EqualError(t, Validate(reflect.TypeOf(private{}), Unexported(UnexportedInclude)),
+		"binary: binary.private.b: unsupported type chan int")
+
+	// The type of a nil interface is nil
+	var typeErr *TypeError
+	err = Validate(reflect.TypeOf(nil))
+	assert.True(t, errors.As(err, &typeErr))
+	assert.True(t, errors.Is(err, errNilType))
+}
@@ -0,0 +1,349 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package binary
+
+import (
+	"errors"
+	"reflect"
+	"strconv"
+)
+
+// Kind represents the way a value is encoded on the wire.
+type Kind uint8
+
+// The kinds of encoded values
+const (
+	KindOpaque    Kind = iota // A value encoded by a custom codec, which can not be described
+	KindBool                  // A single byte, 0 or 1
+	KindVarint                // A variable-size signed integer
+	KindUvarint               // A variable-size unsigned integer
+	KindInt                   // A fixed-width signed integer of Size bytes
+	KindUint                  // A fixed-width unsigned integer of Size bytes
+	KindFloat                 // A floating-point number of Size bytes
+	KindComplex               // A complex number of Size bytes
+	KindString                // A string prefixed with its length
+	KindBytes                 // A byte slice prefixed with its length
+	KindArray                 // Len values of the Elem schema
+	KindSlice                 // Values of the Elem schema prefixed with their count
+	KindMap                   // Pairs of Key and Elem values prefixed with their count
+	KindStruct                // The values of the Fields, in order, after a bitmap of the OmitEmpty ones
+	KindPointer               // A presence byte followed by the Elem value if present
+	KindInterface             // The registered name of the type followed by its value
+	KindTime                  // A time in the format of time.MarshalBinary, prefixed with its length
+	KindUnixNano              // A time as variable-size nanoseconds since the Unix epoch
+	KindBigInt                // An arbitrary precision integer
+	KindBigRat                // An arbitrary precision rational number, as two integers
+	KindBigFloat              // An arbitrary precision floating-point number, prefixed with its length
+	KindDelta                 // Integers of the Elem schema prefixed with their count, as differences
+	KindRLE                   // Runs of Elem values prefixed with their count, each after its length
+	KindBits                  // Booleans prefixed with their count, packed 8 per byte
+	KindGorilla               // Float64 values prefixed with their count and the size of their XORed bits
+	KindRawVarint             // A signed integer as the variable-size unsigned integer of its two's complement
+	KindVByte                 // Integers of the Elem schema prefixed with their count, in the Stream VByte format
+	KindSQL                   // A value returned by driver.Valuer, prefixed with its kind
+	KindColumnar              // Structs prefixed with their count, as the slices of the values of each of the Fields
+)
+
+// The names of the kinds
+var kindNames = [...]string{
+	KindOpaque:    "opaque",
+	KindBool:      "bool",
+	KindVarint:    "varint",
+	KindUvarint:   "uvarint",
+	KindInt:       "int",
+	KindUint:      "uint",
+	KindFloat:     "float",
+	KindComplex:   "complex",
+	KindString:    "string",
+	KindBytes:     "bytes",
+	KindArray:     "array",
+	KindSlice:     "slice",
+	KindMap:       "map",
+	KindStruct:    "struct",
+	KindPointer:   "pointer",
+	KindInterface: "interface",
+	KindTime:      "time",
+	KindUnixNano:  "unixnano",
+	KindBigInt:    "bigint",
+	KindBigRat:    "bigrat",
+	KindBigFloat:  "bigfloat",
+	KindDelta:     "delta",
+	KindRLE:       "rle",
+	KindBits:      "bits",
+	KindGorilla:   "gorilla",
+	KindRawVarint: "rawvarint",
+	KindVByte:     "vbyte",
+	KindSQL:       "sql",
+	KindColumnar:  "columnar",
+}
+
+// String returns the name of the kind.
+func (k Kind) String() string {
+	if int(k) < len(kindNames) {
+		return kindNames[k]
+	}
+	return "kind(" + strconv.Itoa(int(k)) + ")"
+}
+
+// Schema describes the way the values of a type are encoded, so that encoded payloads
+// can be traversed without the original Go type. Schemas of recursive types contain
+// cycles, through the Elem of a pointer.
+type Schema struct {
+	Kind   Kind    // The way the value is encoded
+	Name   string  // The name of the Go type, for information only
+	Size   int     // The size of fixed-width numbers, or of the length of map keys strings
+	Len    int     // The number of elements of an array
+	Key    *Schema // The schema of the keys of a map
+	Elem   *Schema // The schema of the elements of an array, slice, map or pointer
+	Fields []Field // The fields of a struct, ordered by their identifier
+}
+
+// Field describes a field of a struct.
+type Field struct {
+	Name      string  // The name of the field
+	ID        int     // The identifier of the field, used by the Versioned option
+	OmitEmpty bool    // Whether the field is only encoded when it is not zero
+	Schema    *Schema // The schema of the value of the field
+}
+
+// errNilType is returned when describing a nil type, such as the type of a nil interface.
+var errNilType = errors.New("binary: unable to describe a nil type")
+
+// SchemaOf returns the schema of the values of a type, as encoded with the options.
+// Only the Unexported option changes the schema, by including the unexported fields, and
+// the JSONTags option, by skipping and renaming the fields according to their json tag.
+func SchemaOf(t reflect.Type, opts ...Option) (*Schema, error) {
+	if t == nil {
+		return nil, errNilType
+	}
+
+	c, err := scan(t)
+	if err != nil {
+		return nil, err
+	}
+
+	var o options
+	o.reset(opts)
+	s, err := describe(c, t, &o, make(map[reflect.Type]*Schema))
+	if err != nil {
+		return nil, rootedError(err, t)
+	}
+	return s, nil
+}
+
+// describe returns the schema of a type given its codec. The schemas of the structs
+// which were already described are reused, to support recursive types.
+func describe(c Codec, t reflect.Type, o *options, seen map[reflect.Type]*Schema) (*Schema, error) {
+	s := &Schema{Name: t.String()}
+	switch codec := c.(type) {
+	case *boolCodec:
+		s.Kind = KindBool
+	case *varintCodec:
+		s.Kind = KindVarint
+	case *varuintCodec:
+		s.Kind = KindUvarint
+	case *rawVarintCodec:
+		s.Kind = KindRawVarint
+	case *fixedIntCodec:
+		s.Kind, s.Size = KindInt, codec.size
+	case *fixedUintCodec:
+		s.Kind, s.Size = KindUint, codec.size
+	case *float32Codec:
+		s.Kind, s.Size = KindFloat, 4
+	case *float64Codec:
+		s.Kind, s.Size = KindFloat, 8
+	case *complex64Codec:
+		s.Kind, s.Size = KindComplex, 8
+	case *complex128Codec:
+		s.Kind, s.Size = KindComplex, 16
+	case *stringCodec:
+		s.Kind = KindString
+	case *byteSliceCodec, *ipCodec, *netipCodec, *binaryMarshalerCodec, *textMarshalerCodec:
+		s.Kind = KindBytes
+	case *deltaCodec:
+		s.Kind, s.Elem = KindDelta, &Schema{Kind: KindUvarint, Name: t.Elem().String()}
+		if codec.signed {
+			s.Elem.Kind = KindVarint
+		}
+	case *vbyteCodec:
+		s.Kind, s.Elem = KindVByte, &Schema{Kind: KindUvarint, Name: t.Elem().String()}
+		if codec.signed {
+			s.Elem.Kind = KindVarint
+		}
+	case *bitsCodec:
+		s.Kind = KindBits
+	case *packedCodec:
+		s.Kind, s.Len = KindArray, codec.size
+		s.Elem = &Schema{Kind: KindUint, Name: "uint8", Size: 1}
+	case *rawStringCodec:
+		s.Kind, s.Len = KindArray, codec.size
+		s.Elem = &Schema{Kind: KindUint, Name: "uint8", Size: 1}
+	case *gorillaCodec:
+		s.Kind = KindGorilla
+	case *sqlCodec:
+		s.Kind = KindSQL
+	case *rleCodec:
+		elem, err := describe(codec.elemCodec, t.Elem(), o, seen)
+		if err != nil {
+			return nil, nestedError(err, "[]")
+		}
+		s.Kind, s.Elem = KindRLE, elem
+	case *byteArrayCodec:
+		s.Kind, s.Len = KindArray, t.Len()
+		s.Elem = &Schema{Kind: KindUint, Name: t.Elem().String(), Size: 1}
+	case *boolSliceCodec:
+		s.Kind, s.Elem = KindSlice, &Schema{Kind: KindBool, Name: t.Elem().String()}
+	case *varintSliceCodec:
+		s.Kind, s.Elem = KindSlice, &Schema{Kind: KindVarint, Name: t.Elem().String()}
+	case *varuintSliceCodec:
+		s.Kind, s.Elem = KindSlice, &Schema{Kind: KindUvarint, Name: t.Elem().String()}
+	case *timeCodec:
+		s.Kind = KindTime
+		if codec.unixNano {
+			s.Kind = KindUnixNano
+		}
+	case *bigIntCodec:
+		s.Kind = KindBigInt
+	case *bigRatCodec:
+		s.Kind = KindBigRat
+	case *bigFloatCodec:
+		s.Kind = KindBigFloat
+	case *interfaceCodec:
+		s.Kind = KindInterface
+	case *plainSliceCodec:
+		return describe(codec.fallback, t, o, seen)
+	case *stringSliceCodec:
+		return describe(codec.fallback, t, o, seen)
+	case *bytesSliceCodec:
+		return describe(codec.fallback, t, o, seen)
+	case *stringMapCodec:
+		return describe(codec.fallback, t, o, seen)
+	case *anyMapCodec:
+		return describe(codec.fallback, t, o, seen)
+	case *hookedCodec:
+		return describe(codec.Codec, t, o, seen)
+	case *generatedCodec:
+		if codec.fallback == nil {
+			s.Kind = KindOpaque
+			break
+		}
+		return describe(codec.fallback, t, o, seen)
+
+	case *columnarCodec:
+		s.Kind = KindColumnar
+		for i := range codec.columns {
+			f := &codec.columns[i]
+			switch ok, err := f.encoded(o, t.Elem()); {
+			case err != nil:
+				return nil, nestedError(err, "[]."+f.Name)
+			case !ok:
+				continue
+			}
+
+			column, err := describe(f.Codec, f.Type, o, seen)
+			if err != nil {
+				return nil, nestedError(err, "[]."+f.Name)
+			}
+
+			s.Fields = append(s.Fields, Field{Name: f.name(o), ID: f.ID, Schema: column})
+		}
+
+	case *reflectSliceCodec:
+		elem, err := describe(codec.elemCodec, t.Elem(), o, seen)
+		if err != nil {
+			return nil, nestedError(err, "[]")
+		}
+		s.Kind, s.Elem = KindSlice, elem
+
+	case *reflectArrayCodec:
+		elem, err := describe(codec.elemCodec, t.Elem(), o, seen)
+		if err != nil {
+			return nil, nestedError(err, "[]")
+		}
+		s.Kind, s.Len, s.Elem = KindArray, t.Len(), elem
+
+	case *reflectPointerCodec:
+		elemCodec, err := codec.codec()
+		if err != nil {
+			return nil, nestedError(err, "")
+		}
+
+		elem, err := describe(elemCodec, t.Elem(), o, seen)
+		if err != nil {
+			return nil, nestedError(err, "")
+		}
+		s.Kind, s.Elem = KindPointer, elem
+
+	case *nullCodec:
+		elemCodec, err := codec.codec()
+		if err != nil {
+			return nil, nestedError(err, "")
+		}
+
+		elem, err := describe(elemCodec, codec.elemType, o, seen)
+		if err != nil {
+			return nil, nestedError(err, "")
+		}
+		s.Kind, s.Elem = KindPointer, elem
+
+	case *reflectMapCodec:
+		key, err := describeKey(codec.key, t.Key(), o, seen)
+		if err != nil {
+			return nil, nestedError(err, "[key]")
+		}
+
+		elem, err := describe(codec.val, t.Elem(), o, seen)
+		if err != nil {
+			return nil, nestedError(err, "[]")
+		}
+		s.Kind, s.Key, s.Elem = KindMap, key, elem
+
+	case *reflectStructCodec:
+		if described, ok := seen[t]; ok {
+			return described, nil
+		}
+
+		s.Kind = KindStruct
+		seen[t] = s
+		for _, f := range *codec {
+			switch ok, err := f.encoded(o, t); {
+			case err != nil:
+				return nil, nestedError(err, "."+f.Name)
+			case !ok:
+				continue
+			}
+
+			field, err := describe(f.Codec, f.fieldType(t), o, seen)
+			if err != nil {
+				return nil, nestedError(err, "."+f.Name)
+			}
+
+			s.Fields = append(s.Fields, Field{Name: f.name(o), ID: f.ID, OmitEmpty: f.OmitEmpty, Schema: field})
+		}
+
+	default:
+		s.Kind = KindOpaque
+	}
+	return s, nil
+}
+
+// describeKey returns the schema of the keys of a map, as some of them are encoded with
+// a fixed width.
+func describeKey(c Codec, t reflect.Type, o *options, seen map[reflect.Type]*Schema) (*Schema, error) {
+	switch t.Kind() {
+	case reflect.Int16, reflect.Int32, reflect.Int64:
+		return &Schema{Kind: KindInt, Name: t.String(), Size: int(t.Size())}, nil
+	case reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Kind: KindUint, Name: t.String(), Size: int(t.Size())}, nil
+	case reflect.String:
+		return &Schema{Kind: KindString, Name: t.String(), Size: 2}, nil
+	default:
+		return describe(c, t, o, seen)
+	}
+}
+
+// errOpaque returns the error for a value which can not be traversed without its codec.
+func errOpaque(s *Schema) error {
+	return errors.New("binary: unable to traverse " + s.Name + ", which is encoded by a custom codec")
+}
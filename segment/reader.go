@@ -0,0 +1,74 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package segment
+
+import (
+	"io"
+	"iter"
+
+	"github.com/kelindar/binary"
+)
+
+// Reader reads the records of type T of a segment file, in the order they were appended.
+// It keeps track of the offset of the next record, so that reading can resume once an
+// incomplete record has been completed by the writer, which allows following a file as
+// it grows. A reader is not safe for concurrent use.
+type Reader[T any] struct {
+	r      io.ReaderAt
+	opts   []binary.Option
+	offset int64  // The offset of the next record
+	buffer []byte // The buffer of the payload of the records
+}
+
+// NewReader creates a reader of the records of a segment file, such as an *os.File,
+// starting at its first record. The options must match the ones used to write them.
+func NewReader[T any](r io.ReaderAt, opts ...binary.Option) *Reader[T] {
+	return &Reader[T]{r: r, opts: opts}
+}
+
+// Next decodes the next record. It returns io.EOF once the file ends after a whole record,
+// and io.ErrUnexpectedEOF if the last record is incomplete, because it is still being
+// written or its write was torn by a crash. In both cases, the reader stays at the same
+// offset, so that Next can be called again once more records are written. It returns
+// ErrCorrupt if the record does not match its checksum, and stays at its offset as well.
+// A record which fails to decode is skipped, so that the next call moves on to the
+// following one.
+func (r *Reader[T]) Next() (v T, err error) {
+	var payload []byte
+	if payload, err = readRecord(r.r, r.offset, r.buffer); err != nil {
+		return
+	}
+
+	r.buffer = payload
+	r.offset += headerSize + int64(len(payload))
+	err = binary.Unmarshal(payload, &v, r.opts...)
+	return
+}
+
+// Offset returns the offset of the next record.
+func (r *Reader[T]) Offset() int64 {
+	return r.offset
+}
+
+// Resume moves the reader to the record at an offset, as returned by Writer.Append or by
+// Offset, for example to resume reading from a checkpoint.
+func (r *Reader[T]) Resume(offset int64) {
+	r.offset = offset
+}
+
+// All returns an iterator over the records up to the end of the file, which stops after
+// yielding the first error other than io.EOF.
+func (r *Reader[T]) All() iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		for {
+			v, err := r.Next()
+			switch {
+			case err == io.EOF:
+				return
+			case !yield(v, err) || err != nil:
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,69 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package segment
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReader_Resume(t *testing.T) {
+	b := appendRecord(nil, []byte{1, 5, 'f', 'i', 'r', 's', 't'})
+	second := appendRecord(nil, []byte{2, 6, 's', 'e', 'c', 'o', 'n', 'd'})
+
+	// The second record is still being written
+	r := NewReader[event](bytes.NewReader(append(b, second[:10]...)))
+	v, err := r.Next()
+	assert.NoError(t, err)
+	assert.Equal(t, event{ID: 1, Name: "first"}, v)
+
+	_, err = r.Next()
+	assert.Equal(t, io.ErrUnexpectedEOF, err)
+	assert.Equal(t, int64(len(b)), r.Offset())
+
+	// Once it has been written, reading resumes from the same offset
+	r.r = bytes.NewReader(append(b, second...))
+	v, err = r.Next()
+	assert.NoError(t, err)
+	assert.Equal(t, event{ID: 2, Name: "second"}, v)
+
+	_, err = r.Next()
+	assert.Equal(t, io.EOF, err)
+
+	// A reader can start from the offset of any record
+	r.Resume(int64(len(b)))
+	v, err = r.Next()
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(2), v.ID)
+}
+
+func TestReader_Corrupt(t *testing.T) {
+	b := appendRecord(nil, []byte{1, 5, 'f', 'i', 'r', 's', 't'})
+	b[10] = 'F'
+
+	r := NewReader[event](bytes.NewReader(b))
+	_, err := r.Next()
+	assert.Equal(t, ErrCorrupt, err)
+	assert.Equal(t, int64(0), r.Offset())
+
+	// A torn header whose length is garbage does not allocate it
+	b = []byte{0xff, 0xff, 0xff, 0xff, 0, 0, 0, 0, 1}
+	r = NewReader[event](bytes.NewReader(b))
+	_, err = r.Next()
+	assert.Equal(t, io.ErrUnexpectedEOF, err)
+
+	// A record which fails to decode is skipped
+	b = appendRecord(nil, []byte{1})
+	b = appendRecord(b, []byte{2, 0})
+	r = NewReader[event](bytes.NewReader(b))
+	_, err = r.Next()
+	assert.Error(t, err)
+
+	v, err := r.Next()
+	assert.NoError(t, err)
+	assert.Equal(t, event{ID: 2}, v)
+}
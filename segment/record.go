@@ -0,0 +1,88 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package segment
+
+import (
+	bin "encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+)
+
+// ErrCorrupt is returned when a complete record does not match its checksum, which means
+// that the file was corrupted after the record was written.
+var ErrCorrupt = errors.New("segment: corrupt record")
+
+// The table of the CRC-32C polynomial, which is hardware accelerated on most platforms
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// The size of the header of a record, made of the length and the checksum of its payload
+const headerSize = 8
+
+// The size of the records buffered by a writer before they are written to the file
+const bufferSize = 4096
+
+// appendRecord appends a record to the buffer, as the little-endian length of the payload
+// and the CRC-32C checksum of the length and the payload, followed by the payload.
+func appendRecord(b []byte, payload []byte) []byte {
+	var header [headerSize]byte
+	bin.LittleEndian.PutUint32(header[:4], uint32(len(payload)))
+	crc := crc32.Update(crc32.Checksum(header[:4], castagnoli), castagnoli, payload)
+	bin.LittleEndian.PutUint32(header[4:], crc)
+
+	b = append(b, header[:]...)
+	return append(b, payload...)
+}
+
+// readRecord reads the payload of the record at an offset. It returns io.EOF if there are
+// no bytes at the offset, and io.ErrUnexpectedEOF if the record is incomplete, because it
+// is still being written or its write was torn by a crash.
+func readRecord(r io.ReaderAt, offset int64, buffer []byte) ([]byte, error) {
+	var header [headerSize]byte
+	switch n, err := r.ReadAt(header[:], offset); {
+	case n == headerSize:
+	case n == 0 && err == io.EOF:
+		return nil, io.EOF
+	case err == io.EOF:
+		return nil, io.ErrUnexpectedEOF
+	default:
+		return nil, err
+	}
+
+	// The payload is read in steps, so that the garbage length of a torn header does not
+	// allocate more than the size of the file
+	size := int64(bin.LittleEndian.Uint32(header[:4]))
+	section := io.NewSectionReader(r, offset+headerSize, size)
+	payload := buffer[:0]
+	for int64(len(payload)) < size {
+		if len(payload) == cap(payload) {
+			payload = append(payload, 0)[:len(payload)]
+		}
+
+		n, err := section.Read(payload[len(payload):min(int64(cap(payload)), size)])
+		payload = payload[:len(payload)+n]
+		switch {
+		case err == io.EOF && int64(len(payload)) < size:
+			return nil, io.ErrUnexpectedEOF
+		case err != nil && err != io.EOF:
+			return nil, err
+		}
+	}
+
+	crc := crc32.Update(crc32.Checksum(header[:4], castagnoli), castagnoli, payload)
+	if crc != bin.LittleEndian.Uint32(header[4:]) {
+		return nil, ErrCorrupt
+	}
+	return payload, nil
+}
+
+// recordEnd returns the offset at which the record at an offset ends, according to the
+// length in its header, or -1 if the header can not be read.
+func recordEnd(r io.ReaderAt, offset int64) int64 {
+	var header [4]byte
+	if _, err := r.ReadAt(header[:], offset); err != nil {
+		return -1
+	}
+	return offset + headerSize + int64(bin.LittleEndian.Uint32(header[:]))
+}
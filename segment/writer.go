@@ -0,0 +1,135 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package segment
+
+import (
+	"errors"
+	"io"
+	"math"
+	"os"
+	"strconv"
+
+	"github.com/kelindar/binary"
+)
+
+// Writer appends records of type T to a segment file. Every record is encoded with the
+// binary package, and framed with its length and its CRC-32C checksum, so that a record
+// which was only partially written when the process crashed can be detected. A writer
+// is not safe for concurrent use, and a file must only have one writer at a time.
+type Writer[T any] struct {
+	file   *os.File
+	opts   []binary.Option
+	value  []byte // The buffer of the value being encoded
+	buffer []byte // The records which are not written to the file yet
+	size   int64  // The size of the file, including the buffered records
+}
+
+// Open opens a segment file for appending records, creating it if it does not exist. If
+// the file ends with an incomplete or corrupt record, for example because the process
+// crashed while writing it, the file is truncated to the end of the last valid record,
+// so that new records are appended after it. A corrupt record followed by others fails
+// with ErrCorrupt instead, leaving the file as it is.
+func Open[T any](path string, opts ...binary.Option) (*Writer[T], error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	size, err := recoverFile(file)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return &Writer[T]{file: file, opts: opts, size: size}, nil
+}
+
+// recoverFile returns the end of the last valid record of a file, truncating the torn
+// record which follows it, if any. Only the last record of the file can have been torn
+// by a crash, so a corrupt record before it is an error, as truncating it would drop the
+// valid records which follow.
+func recoverFile(file *os.File) (int64, error) {
+	info, err := file.Stat()
+	if err != nil {
+		return 0, err
+	}
+
+	var offset int64
+	var buffer []byte
+	for {
+		payload, err := readRecord(file, offset, buffer)
+		switch {
+		case err == io.EOF:
+			return offset, nil
+		case err == io.ErrUnexpectedEOF:
+			return offset, file.Truncate(offset)
+		case err == ErrCorrupt && recordEnd(file, offset) == info.Size():
+			return offset, file.Truncate(offset)
+		case err != nil:
+			return 0, err
+		}
+
+		offset += headerSize + int64(len(payload))
+		buffer = payload
+	}
+}
+
+// Append encodes a record and buffers it, returning the offset at which it is written in
+// the file. A value which fails to encode is left out, so that the writer can be used for
+// the next values. The records are written to the file by Flush, Sync or Close.
+func (w *Writer[T]) Append(v T) (offset int64, err error) {
+	if w.value, err = binary.MarshalTo(w.value[:0], &v, w.opts...); err != nil {
+		return 0, err
+	}
+	if uint64(len(w.value)) > math.MaxUint32 {
+		return 0, errors.New("segment: record of " + strconv.Itoa(len(w.value)) + " bytes is too large")
+	}
+
+	offset = w.size
+	w.buffer = appendRecord(w.buffer, w.value)
+	w.size += headerSize + int64(len(w.value))
+	if len(w.buffer) >= bufferSize {
+		err = w.Flush()
+	}
+	return
+}
+
+// Size returns the size of the file, including the records which are not written yet.
+func (w *Writer[T]) Size() int64 {
+	return w.size
+}
+
+// Flush writes the buffered records to the file. They are then visible to the readers,
+// but may be lost if the machine crashes before they are synced.
+func (w *Writer[T]) Flush() error {
+	if len(w.buffer) == 0 {
+		return nil
+	}
+
+	offset := w.size - int64(len(w.buffer))
+	if _, err := w.file.WriteAt(w.buffer, offset); err != nil {
+		return err
+	}
+
+	w.buffer = w.buffer[:0]
+	return nil
+}
+
+// Sync writes the buffered records to the file and commits them to stable storage, so
+// that they survive a crash of the machine.
+func (w *Writer[T]) Sync() error {
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	return w.file.Sync()
+}
+
+// Close syncs the buffered records and closes the file.
+func (w *Writer[T]) Close() error {
+	if err := w.Sync(); err != nil {
+		w.file.Close()
+		return err
+	}
+	return w.file.Close()
+}
@@ -0,0 +1,157 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package segment
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kelindar/binary"
+	"github.com/stretchr/testify/assert"
+)
+
+type event struct {
+	ID   uint64
+	Name string
+}
+
+func TestWriter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.log")
+	w, err := Open[event](path)
+	assert.NoError(t, err)
+
+	var offsets []int64
+	for i := 0; i < 1000; i++ {
+		offset, err := w.Append(event{ID: uint64(i), Name: "event"})
+		assert.NoError(t, err)
+		offsets = append(offsets, offset)
+	}
+	assert.NoError(t, w.Close())
+	assert.Equal(t, int64(0), offsets[0])
+	assert.Equal(t, int64(8+1+6), offsets[1])
+
+	// Every record is framed with its length and its checksum
+	info, err := os.Stat(path)
+	assert.NoError(t, err)
+	assert.Equal(t, w.Size(), info.Size())
+
+	// Reopening the file appends after the existing records
+	w, err = Open[event](path)
+	assert.NoError(t, err)
+	assert.Equal(t, info.Size(), w.Size())
+
+	offset, err := w.Append(event{ID: 1000})
+	assert.NoError(t, err)
+	assert.Equal(t, info.Size(), offset)
+	assert.NoError(t, w.Close())
+
+	f, err := os.Open(path)
+	assert.NoError(t, err)
+	defer f.Close()
+
+	var count int
+	for v, err := range NewReader[event](f).All() {
+		assert.NoError(t, err)
+		assert.Equal(t, uint64(count), v.ID)
+		count++
+	}
+	assert.Equal(t, 1001, count)
+}
+
+func TestWriter_Recover(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.log")
+	w, err := Open[event](path)
+	assert.NoError(t, err)
+
+	_, err = w.Append(event{ID: 1, Name: "first"})
+	assert.NoError(t, err)
+	second, err := w.Append(event{ID: 2, Name: "second"})
+	assert.NoError(t, err)
+	assert.NoError(t, w.Close())
+
+	// The write of the second record is torn by a crash
+	assert.NoError(t, os.Truncate(path, second+5))
+
+	w, err = Open[event](path)
+	assert.NoError(t, err)
+	assert.Equal(t, second, w.Size())
+
+	_, err = w.Append(event{ID: 3, Name: "third"})
+	assert.NoError(t, err)
+	assert.NoError(t, w.Close())
+
+	f, err := os.Open(path)
+	assert.NoError(t, err)
+	defer f.Close()
+
+	var names []string
+	for v, err := range NewReader[event](f).All() {
+		assert.NoError(t, err)
+		names = append(names, v.Name)
+	}
+	assert.Equal(t, []string{"first", "third"}, names)
+}
+
+func TestWriter_RecoverCorrupt(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.log")
+	w, err := Open[event](path)
+	assert.NoError(t, err)
+
+	_, err = w.Append(event{ID: 1, Name: "first"})
+	assert.NoError(t, err)
+	second, err := w.Append(event{ID: 2, Name: "second"})
+	assert.NoError(t, err)
+	third, err := w.Append(event{ID: 3, Name: "third"})
+	assert.NoError(t, err)
+	assert.NoError(t, w.Close())
+
+	// A byte of the second record is flipped, which is not a torn write
+	b, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	size := len(b)
+	b[second+headerSize] ^= 0xff
+	assert.NoError(t, os.WriteFile(path, b, 0644))
+
+	_, err = Open[event](path)
+	assert.True(t, errors.Is(err, ErrCorrupt))
+	info, err := os.Stat(path)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(size), info.Size())
+
+	// The same corruption in the last record is dropped like a torn write
+	b[second+headerSize] ^= 0xff
+	b[third+headerSize] ^= 0xff
+	assert.NoError(t, os.WriteFile(path, b, 0644))
+
+	w, err = Open[event](path)
+	assert.NoError(t, err)
+	assert.Equal(t, third, w.Size())
+	assert.NoError(t, w.Close())
+}
+
+func TestWriter_Options(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.log")
+	w, err := Open[event](path, binary.Checksum())
+	assert.NoError(t, err)
+
+	_, err = w.Append(event{ID: 1, Name: strings.Repeat("a", 100)})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(8+1+101+4), w.Size())
+
+	// A value which fails to encode is left out
+	wrong, err := Open[chan int](filepath.Join(t.TempDir(), "chan.log"))
+	assert.NoError(t, err)
+	_, err = wrong.Append(make(chan int))
+	assert.Error(t, err)
+	assert.Equal(t, int64(0), wrong.Size())
+	assert.NoError(t, wrong.Close())
+	assert.NoError(t, w.Close())
+
+	// Files which can not be opened fail
+	_, err = Open[event](filepath.Join(t.TempDir(), "missing", "events.log"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,67 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package binary
+
+import (
+	"errors"
+	"reflect"
+)
+
+// SharedPointers writes every pointer only once per encoded value, and its repeated
+// occurrences as a reference to the first one, which the decoder restores as the same
+// pointer. This preserves the aliasing of graph-like data models, where several values
+// point to a shared one, and allows encoding values which refer to themselves, such as
+// circular lists. Both sides must use this option, which is not supported along with the
+// SelfDescribing and Versioned options, as versioned fields are encoded on their own.
+func SharedPointers() Option {
+	return func(o *options) {
+		o.shared = true
+	}
+}
+
+// errSharedVersioned is returned when shared pointers are used along with versioned
+// structs, whose fields can not refer to the pointers of the others.
+var errSharedVersioned = errors.New("binary: shared pointers can not be versioned")
+
+// The presence byte of a pointer which is a reference to a previous one, followed by the
+// index of that pointer, in the order in which the pointers were first encoded.
+const sharedPointer = 2
+
+// writeShared writes a reference to a pointer if it was already encoded, otherwise it
+// records the pointer so that its value gets encoded, and returns false.
+func (e *Encoder) writeShared(rv reflect.Value) bool {
+	ref, x := referenceOf(rv), e.extend()
+	if i, ok := x.pointers[ref]; ok {
+		e.WriteUint8(sharedPointer)
+		e.WriteUvarint(uint64(i))
+		return true
+	}
+
+	if x.pointers == nil {
+		x.pointers = make(map[reference]int)
+	}
+	x.pointers[ref] = len(x.pointers)
+	return false
+}
+
+// readShared reads a reference to a previously decoded pointer and sets it.
+func (d *Decoder) readShared(rv reflect.Value) error {
+	if !d.opts.shared {
+		return errors.New("binary: invalid presence byte for " + rv.Type().String())
+	}
+
+	i, err := d.ReadUvarint()
+	switch {
+	case err != nil:
+		return err
+	case i >= uint64(len(d.pointers)):
+		return errors.New("binary: invalid reference to a shared pointer")
+	case !d.pointers[i].Type().AssignableTo(rv.Type()):
+		return errors.New("binary: shared pointer of type " + d.pointers[i].Type().String() +
+			" is not assignable to " + rv.Type().String())
+	}
+
+	rv.Set(d.pointers[i])
+	return nil
+}
@@ -0,0 +1,96 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package binary
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type sharedNode struct {
+	Name  string
+	Edges []*sharedNode
+}
+
+type sharedGraph struct {
+	Root  *sharedNode
+	Nodes []*sharedNode
+	Count *int
+}
+
+func TestSharedPointers(t *testing.T) {
+	a := &sharedNode{Name: "a"}
+	b := &sharedNode{Name: "b", Edges: []*sharedNode{a}}
+	c := &sharedNode{Name: "c", Edges: []*sharedNode{a, b}}
+	a.Edges = []*sharedNode{c} // A cycle
+
+	count := 3
+	graph := &sharedGraph{Root: a, Nodes: []*sharedNode{a, b, c}, Count: &count}
+
+	for _, opts := range [][]Option{
+		{SharedPointers()},
+		{SharedPointers(), InternStrings()},
+	} {
+		out, err := Marshal(graph, opts...)
+		assert.NoError(t, err)
+
+		size, err := Size(graph, opts...)
+		assert.NoError(t, err)
+		assert.Equal(t, len(out), size)
+
+		var g sharedGraph
+		assert.NoError(t, Unmarshal(out, &g, opts...))
+		assert.Equal(t, 3, *g.Count)
+		assert.Equal(t, []string{"a", "b", "c"}, []string{g.Nodes[0].Name, g.Nodes[1].Name, g.Nodes[2].Name})
+
+		// The pointers are shared, like in the original graph
+		assert.True(t, g.Root == g.Nodes[0])
+		assert.True(t, g.Nodes[1].Edges[0] == g.Root)
+		assert.True(t, g.Nodes[2].Edges[1] == g.Nodes[1])
+		assert.True(t, g.Root.Edges[0].Edges[0] == g.Root)
+	}
+}
+
+func TestSharedPointersProjection(t *testing.T) {
+	a := &sharedNode{Name: "a"}
+	graph := &sharedGraph{Root: a, Nodes: []*sharedNode{a}}
+
+	out, err := Marshal(graph, SharedPointers())
+	assert.NoError(t, err)
+
+	// The skipped pointers are still known to the decoder
+	var g sharedGraph
+	assert.NoError(t, Unmarshal(out, &g, SharedPointers(), Fields("Nodes")))
+	assert.Nil(t, g.Root)
+	assert.Equal(t, "a", g.Nodes[0].Name)
+}
+
+func TestSharedPointersErrors(t *testing.T) {
+	a := &sharedNode{Name: "a"}
+	out, err := Marshal(&sharedGraph{Root: a, Nodes: []*sharedNode{a}}, SharedPointers())
+	assert.NoError(t, err)
+
+	// Both sides need the option
+	assert.Error(t, Unmarshal(out, new(sharedGraph)))
+
+	// A reference to a pointer which was not decoded
+	assert.Error(t, Unmarshal([]byte{2, 0}, new(*int), SharedPointers()))
+
+	// A reference to a pointer of another type
+	var v struct {
+		A *int
+		B *string
+	}
+	assert.Error(t, Unmarshal([]byte{1, 2, 2, 0}, &v, SharedPointers()))
+
+	_, err = Marshal(&sharedGraph{}, SharedPointers(), SelfDescribing())
+	assert.Error(t, err)
+
+	// Versioned fields are encoded on their own, so they can not share pointers
+	a.Edges = []*sharedNode{a}
+	_, err = Marshal(&sharedGraph{Root: a}, SharedPointers(), Versioned())
+	assert.Equal(t, errSharedVersioned, err)
+	assert.Equal(t, errSharedVersioned, Unmarshal([]byte{0}, new(sharedGraph), SharedPointers(), Versioned()))
+}
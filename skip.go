@@ -0,0 +1,321 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package binary
+
+import (
+	"io"
+	"reflect"
+)
+
+// skipper represents a codec which can skip over an encoded value without decoding it.
+type skipper interface {
+	skip(d *Decoder, t reflect.Type) error
+}
+
+// skip skips over an encoded value of a type. Codecs which can not skip over their values
+// decode them into a temporary value instead, which is then discarded.
+func (d *Decoder) skip(c Codec, t reflect.Type) error {
+	if s, ok := c.(skipper); ok {
+		return s.skip(d, t)
+	}
+
+	project := d.project
+	d.project = nil
+	err := c.DecodeTo(d, reflect.New(t).Elem())
+	d.project = project
+	return err
+}
+
+// skipBytes skips over a number of bytes of the input.
+func (d *Decoder) skipBytes(n int) (err error) {
+	if d.s != nil {
+		_, err = d.s.Slice(n)
+		return
+	}
+
+	var skipped int64
+	if skipped, err = io.CopyN(io.Discard, d.r, int64(n)); err == io.EOF && skipped > 0 {
+		err = io.ErrUnexpectedEOF
+	}
+	return
+}
+
+// skipLength skips over a length prefix followed by as many values of a fixed size.
+func (d *Decoder) skipLength(size int) (err error) {
+	var l int
+	if l, err = d.readSliceLen(); err == nil {
+		if l > maxInt/size {
+			return io.EOF
+		}
+		err = d.skipBytes(l * size)
+	}
+	return
+}
+
+// ------------------------------------------------------------------------------
+
+func (c *varintCodec) skip(d *Decoder, _ reflect.Type) (err error) {
+	_, err = d.ReadVarint()
+	return
+}
+
+func (c *rawVarintCodec) skip(d *Decoder, _ reflect.Type) (err error) {
+	_, err = d.ReadUvarint()
+	return
+}
+
+func (c *varuintCodec) skip(d *Decoder, _ reflect.Type) (err error) {
+	_, err = d.ReadUvarint()
+	return
+}
+
+func (c *boolCodec) skip(d *Decoder, _ reflect.Type) error {
+	return d.skipBytes(1)
+}
+
+func (c *float32Codec) skip(d *Decoder, _ reflect.Type) error {
+	return d.skipBytes(4)
+}
+
+func (c *float64Codec) skip(d *Decoder, _ reflect.Type) error {
+	return d.skipBytes(8)
+}
+
+func (c *complex64Codec) skip(d *Decoder, _ reflect.Type) error {
+	return d.skipBytes(8)
+}
+
+func (c *complex128Codec) skip(d *Decoder, _ reflect.Type) error {
+	return d.skipBytes(16)
+}
+
+func (c *fixedIntCodec) skip(d *Decoder, _ reflect.Type) error {
+	return d.skipBytes(c.size)
+}
+
+func (c *fixedUintCodec) skip(d *Decoder, _ reflect.Type) error {
+	return d.skipBytes(c.size)
+}
+
+func (c *byteArrayCodec) skip(d *Decoder, t reflect.Type) error {
+	return d.skipBytes(t.Len())
+}
+
+func (c *rawStringCodec) skip(d *Decoder, _ reflect.Type) error {
+	return d.skipBytes(c.size)
+}
+
+func (c *byteSliceCodec) skip(d *Decoder, _ reflect.Type) error {
+	return d.skipLength(1)
+}
+
+func (c *netipCodec) skip(d *Decoder, _ reflect.Type) error {
+	return d.skipLength(1)
+}
+
+func (c *sqlCodec) skip(d *Decoder, _ reflect.Type) (err error) {
+	_, err = d.readSQLValue()
+	return
+}
+
+func (c *textMarshalerCodec) skip(d *Decoder, _ reflect.Type) (err error) {
+	var l int
+	if l, err = d.readStringLen(); err == nil {
+		err = d.skipBytes(l)
+	}
+	return
+}
+
+func (c *deltaCodec) skip(d *Decoder, _ reflect.Type) (err error) {
+	var l int
+	if l, err = d.readSliceLen(); err == nil {
+		err = d.readDelta(l, false, func(int, uint64) error { return nil })
+	}
+	return
+}
+
+func (c *vbyteCodec) skip(d *Decoder, _ reflect.Type) (err error) {
+	_, _, _, err = d.readVByte()
+	return
+}
+
+func (c *rleCodec) skip(d *Decoder, t reflect.Type) (err error) {
+	var l, n int
+	if l, err = d.readSliceLen(); err != nil {
+		return
+	}
+
+	for i := 0; i < l; i += n {
+		if n, err = d.readRun(l - i); err != nil {
+			return
+		}
+		if err = d.skip(c.elemCodec, t.Elem()); err != nil {
+			return
+		}
+	}
+	return
+}
+
+func (c *bitsCodec) skip(d *Decoder, _ reflect.Type) (err error) {
+	var l int
+	if l, err = d.readSliceLen(); err == nil {
+		err = d.skipBytes(bitsSize(l))
+	}
+	return
+}
+
+func (c *gorillaCodec) skip(d *Decoder, _ reflect.Type) (err error) {
+	_, _, err = d.readGorillaBits()
+	return
+}
+
+func (c *boolSliceCodec) skip(d *Decoder, _ reflect.Type) error {
+	return d.skipLength(1)
+}
+
+func (c *stringCodec) skip(d *Decoder, _ reflect.Type) (err error) {
+	if d.opts.intern {
+		_, err = d.readInterned() // The table of interned strings needs to be kept
+		return
+	}
+
+	var l int
+	if l, err = d.readStringLen(); err == nil {
+		err = d.skipBytes(l)
+	}
+	return
+}
+
+func (c *varintSliceCodec) skip(d *Decoder, _ reflect.Type) (err error) {
+	var l int
+	if l, err = d.readSliceLen(); err == nil {
+		for i := 0; i < l && err == nil; i++ {
+			_, err = d.ReadVarint()
+		}
+	}
+	return
+}
+
+func (c *varuintSliceCodec) skip(d *Decoder, _ reflect.Type) (err error) {
+	var l int
+	if l, err = d.readSliceLen(); err == nil {
+		for i := 0; i < l && err == nil; i++ {
+			_, err = d.ReadUvarint()
+		}
+	}
+	return
+}
+
+func (c *plainSliceCodec) skip(d *Decoder, t reflect.Type) error {
+	if d.opts.versioned {
+		return d.skip(c.fallback, t)
+	}
+	return d.skipLength(c.elemSize)
+}
+
+func (c *stringSliceCodec) skip(d *Decoder, t reflect.Type) error {
+	return d.skip(c.fallback, t)
+}
+
+func (c *bytesSliceCodec) skip(d *Decoder, t reflect.Type) error {
+	return d.skip(c.fallback, t)
+}
+
+func (c *packedCodec) skip(d *Decoder, _ reflect.Type) error {
+	return d.skipBytes(c.size)
+}
+
+func (c *columnarCodec) skip(d *Decoder, t reflect.Type) (err error) {
+	var l int
+	if l, err = d.readSliceLen(); err != nil || l == 0 {
+		return
+	}
+
+	for i := range c.columns {
+		f := &c.columns[i]
+		switch ok, err := f.encoded(&d.opts, t.Elem()); {
+		case err != nil:
+			return err
+		case !ok:
+			continue
+		}
+
+		if err = d.skip(f.Codec, f.Type); err != nil {
+			return
+		}
+	}
+	return
+}
+
+func (c *reflectSliceCodec) skip(d *Decoder, t reflect.Type) (err error) {
+	var l int
+	if l, err = d.readSliceLen(); err == nil {
+		for i := 0; i < l && err == nil; i++ {
+			err = d.skip(c.elemCodec, t.Elem())
+		}
+	}
+	return
+}
+
+func (c *reflectArrayCodec) skip(d *Decoder, t reflect.Type) (err error) {
+	for i := 0; i < t.Len() && err == nil; i++ {
+		err = d.skip(c.elemCodec, t.Elem())
+	}
+	return
+}
+
+func (c *reflectStructCodec) skip(d *Decoder, t reflect.Type) (err error) {
+	if err = d.enter(); err != nil {
+		return
+	}
+	defer d.leave()
+
+	if d.opts.versioned {
+		for {
+			var id uint64
+			var l int
+			if id, err = d.ReadUvarint(); err != nil || id == 0 {
+				return
+			}
+			if l, err = d.readSliceLen(); err != nil {
+				return
+			}
+			if err = d.skipBytes(l); err != nil {
+				return
+			}
+		}
+	}
+
+	var buffer [8]byte
+	var bits []byte
+	var omitted int
+	if omitted, err = c.omitted(&d.opts, t); err != nil {
+		return
+	}
+	if bits, err = d.readPresence(omitted, buffer[:]); err != nil {
+		return
+	}
+
+	n := 0
+	for _, i := range *c {
+		var ok bool
+		if ok, err = i.encoded(&d.opts, t); err != nil {
+			return
+		}
+
+		switch {
+		case !ok:
+			continue
+		case i.OmitEmpty:
+			if n++; !present(bits, n-1) {
+				continue
+			}
+		}
+
+		if err = d.skip(i.Codec, i.fieldType(t)); err != nil {
+			return
+		}
+	}
+	return
+}
@@ -0,0 +1,129 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package binary
+
+import (
+	"errors"
+	"reflect"
+	"sort"
+)
+
+// sortKeys sorts the keys of a map in a deterministic order. Keys which are compared by
+// their address, such as pointers, are rejected since their order would change from one
+// process to another.
+func sortKeys(keys []reflect.Value) error {
+	for _, k := range keys {
+		if err := sortable(k); err != nil {
+			return err
+		}
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		return compare(keys[i], keys[j]) < 0
+	})
+	return nil
+}
+
+// sortable returns an error if a key can not be ordered by its value.
+func sortable(v reflect.Value) error {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.UnsafePointer, reflect.Chan:
+		return errors.New("binary: map keys of type " + v.Type().String() + " can not be sorted, as they are compared by address")
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if err := sortable(v.Field(i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if err := sortable(v.Index(i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Interface:
+		if !v.IsNil() {
+			return sortable(v.Elem())
+		}
+	}
+	return nil
+}
+
+// compare compares two values of the same type, which can be used as map keys. It
+// returns -1, 0 or 1 depending on whether a is less than, equal to or greater than b.
+func compare(a, b reflect.Value) int {
+	switch a.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return compareOrdered(a.Int() < b.Int(), a.Int() > b.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return compareOrdered(a.Uint() < b.Uint(), a.Uint() > b.Uint())
+	case reflect.String:
+		return compareOrdered(a.String() < b.String(), a.String() > b.String())
+	case reflect.Float32, reflect.Float64:
+		return compareFloat(a.Float(), b.Float())
+	case reflect.Complex64, reflect.Complex128:
+		if c := compareFloat(real(a.Complex()), real(b.Complex())); c != 0 {
+			return c
+		}
+		return compareFloat(imag(a.Complex()), imag(b.Complex()))
+	case reflect.Bool:
+		return compareOrdered(!a.Bool() && b.Bool(), a.Bool() && !b.Bool())
+	case reflect.Struct:
+		for i := 0; i < a.NumField(); i++ {
+			if c := compare(a.Field(i), b.Field(i)); c != 0 {
+				return c
+			}
+		}
+		return 0
+	case reflect.Array:
+		for i := 0; i < a.Len(); i++ {
+			if c := compare(a.Index(i), b.Index(i)); c != 0 {
+				return c
+			}
+		}
+		return 0
+	case reflect.Interface:
+		if a.IsNil() || b.IsNil() {
+			return compareOrdered(a.IsNil() && !b.IsNil(), !a.IsNil() && b.IsNil())
+		}
+
+		ta, tb := a.Elem().Type(), b.Elem().Type()
+		if ta != tb {
+			return compareOrdered(ta.String() < tb.String(), ta.String() > tb.String())
+		}
+		return compare(a.Elem(), b.Elem())
+	default:
+		return 0
+	}
+}
+
+// compareOrdered converts the result of a comparison to an integer.
+func compareOrdered(less, greater bool) int {
+	switch {
+	case less:
+		return -1
+	case greater:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// compareFloat compares two floating-point numbers, ordering NaN before other values.
+func compareFloat(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	case a == b:
+		return 0
+	case a != a && b != b:
+		return 0
+	case a != a:
+		return -1
+	default:
+		return 1
+	}
+}
@@ -0,0 +1,96 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package binary
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSortKeys(t *testing.T) {
+	type key struct {
+		A int
+		B string
+	}
+
+	tests := []struct {
+		input  interface{}
+		output interface{}
+	}{
+		{[]int{3, -1, 2}, []int{-1, 2, 3}},
+		{[]uint8{3, 1, 2}, []uint8{1, 2, 3}},
+		{[]string{"b", "c", "a"}, []string{"a", "b", "c"}},
+		{[]bool{true, false}, []bool{false, true}},
+		{[]float64{2, math.Inf(-1), 1}, []float64{math.Inf(-1), 1, 2}},
+		{[]complex64{complex(1, 2), complex(1, 1)}, []complex64{complex(1, 1), complex(1, 2)}},
+		{[][2]int{{1, 2}, {1, 1}}, [][2]int{{1, 1}, {1, 2}}},
+		{[]key{{2, "a"}, {1, "b"}, {1, "a"}}, []key{{1, "a"}, {1, "b"}, {2, "a"}}},
+		{[]interface{}{"a", 2, 1, nil}, []interface{}{nil, 1, 2, "a"}},
+	}
+
+	for _, tc := range tests {
+		rv := reflect.ValueOf(tc.input)
+		keys := make([]reflect.Value, rv.Len())
+		for i := range keys {
+			keys[i] = rv.Index(i)
+		}
+
+		assert.NoError(t, sortKeys(keys))
+		out := reflect.MakeSlice(rv.Type(), 0, rv.Len())
+		for _, k := range keys {
+			out = reflect.Append(out, k)
+		}
+		assert.Equal(t, tc.output, out.Interface())
+	}
+}
+
+func TestDeterministic(t *testing.T) {
+	v := map[string]int{}
+	for i := 0; i < 100; i++ {
+		v[string(rune('A'+i))] = i
+	}
+
+	expect, err := Marshal(&v, Deterministic())
+	assert.NoError(t, err)
+
+	for i := 0; i < 10; i++ {
+		b, err := Marshal(&v, Deterministic())
+		assert.NoError(t, err)
+		assert.Equal(t, expect, b)
+	}
+
+	var out map[string]int
+	assert.NoError(t, Unmarshal(expect, &out))
+	assert.Equal(t, v, out)
+}
+
+func TestDeterministic_Unsortable(t *testing.T) {
+	type key struct {
+		Name *string
+	}
+
+	a, b := "a", "b"
+	for _, v := range []interface{}{
+		map[*string]int{&a: 1, &b: 2},
+		map[key]int{{&a}: 1, {&b}: 2},
+		map[interface{}]int{&a: 1, "b": 2},
+	} {
+		for _, opts := range [][]Option{{Deterministic()}, {Canonical()}} {
+			_, err := Marshal(v, opts...)
+			assert.Error(t, err, "%T", v)
+			assert.Contains(t, err.Error(), "compared by address")
+		}
+	}
+
+	// Without sorting, the keys are encoded in their iteration order
+	encoded, err := Marshal(map[*string]int{&a: 1, &b: 2})
+	assert.NoError(t, err)
+
+	var out map[*string]int
+	assert.NoError(t, Unmarshal(encoded, &out))
+	assert.Len(t, out, 2)
+}
@@ -0,0 +1,237 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package binary
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+// The reflected types of the database/sql interfaces
+var (
+	typeValuer  = reflect.TypeOf((*driver.Valuer)(nil)).Elem()
+	typeScanner = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+)
+
+// The kinds of the values returned by driver.Valuer, which precede the values
+const (
+	sqlNull byte = iota
+	sqlInt64
+	sqlFloat64
+	sqlBool
+	sqlBytes
+	sqlString
+	sqlTime
+)
+
+// SQLCodec returns a codec which encodes the values of a type as the value returned by
+// their driver.Valuer implementation, and decodes them with their sql.Scanner one, for
+// types which only know how to convert themselves to and from a database column, such
+// as decimals or enumerations. Registering it with RegisterCodec applies it wherever the
+// type appears, while the `binary:",sql"` tag applies it to a single field.
+func SQLCodec(t reflect.Type) (Codec, error) {
+	return scanSQL(t)
+}
+
+// scanSQL returns a codec which encodes a value with its driver.Valuer and decodes it
+// with its sql.Scanner, selected with a `binary:",sql"` tag.
+func scanSQL(t reflect.Type) (Codec, error) {
+	if t.Kind() == reflect.Ptr {
+		return nil, errors.New("binary: sql encoding is not supported for " + t.String())
+	}
+
+	ptr := reflect.PtrTo(t)
+	out := new(sqlCodec)
+	switch {
+	case t.Implements(typeValuer):
+	case ptr.Implements(typeValuer):
+		out.ptrValuer = true
+	default:
+		return nil, errors.New("binary: " + t.String() + " does not implement driver.Valuer")
+	}
+
+	if !ptr.Implements(typeScanner) {
+		return nil, errors.New("binary: " + t.String() + " does not implement sql.Scanner")
+	}
+	return out, nil
+}
+
+// ------------------------------------------------------------------------------
+
+// sqlCodec represents a codec which delegates to the driver.Valuer and sql.Scanner
+// implementations of a type. The value is prefixed with its kind, which is one of the
+// types a driver.Value can hold: nil, int64, float64, bool, []byte, string or time.Time.
+type sqlCodec struct {
+	ptrValuer bool // Whether Value is declared on the pointer receiver
+}
+
+// Encode encodes a value into the encoder.
+func (c *sqlCodec) EncodeTo(e *Encoder, rv reflect.Value) error {
+	v, err := c.valuer(rv).Value()
+	if err != nil {
+		return err
+	}
+	return e.writeSQLValue(v)
+}
+
+// Decode decodes into a reflect value from the decoder.
+func (c *sqlCodec) DecodeTo(d *Decoder, rv reflect.Value) error {
+	v, err := d.readSQLValue()
+	if err != nil {
+		return err
+	}
+	return rv.Addr().Interface().(sql.Scanner).Scan(v)
+}
+
+// Size returns -1, as the size is only known once Value was called, which may be
+// expensive or return a different value every time, so the value is encoded instead.
+func (c *sqlCodec) Size(rv reflect.Value) int {
+	return -1
+}
+
+// valuer returns the driver.Valuer of the value. If the method is declared on the
+// pointer receiver and the value is not addressable, it is copied first.
+func (c *sqlCodec) valuer(rv reflect.Value) driver.Valuer {
+	if !c.ptrValuer {
+		return rv.Interface().(driver.Valuer)
+	}
+	return addrOf(rv).(driver.Valuer)
+}
+
+// writeSQLValue writes a value returned by driver.Valuer, prefixed with its kind.
+func (e *Encoder) writeSQLValue(v driver.Value) error {
+	switch v := v.(type) {
+	case nil:
+		e.WriteUint8(sqlNull)
+	case int64:
+		e.WriteUint8(sqlInt64)
+		e.WriteVarint(v)
+	case float64:
+		e.WriteUint8(sqlFloat64)
+		e.WriteFloat64(v)
+	case bool:
+		e.WriteUint8(sqlBool)
+		e.WriteBool(v)
+	case []byte:
+		e.WriteUint8(sqlBytes)
+		e.WriteBytes(v)
+	case string:
+		e.WriteUint8(sqlString)
+		e.WriteString(v)
+	case time.Time:
+		e.WriteUint8(sqlTime)
+		return new(timeCodec).EncodeTo(e, reflect.ValueOf(v))
+	default:
+		return errors.New("binary: unsupported driver value of type " + reflect.TypeOf(v).String())
+	}
+	return nil
+}
+
+// readSQLValue reads a value which was returned by driver.Valuer, prefixed with its kind.
+func (d *Decoder) readSQLValue() (driver.Value, error) {
+	kind, err := d.ReadUint8()
+	if err != nil {
+		return nil, err
+	}
+
+	switch kind {
+	case sqlNull:
+		return nil, nil
+	case sqlInt64:
+		return d.ReadVarint()
+	case sqlFloat64:
+		return d.ReadFloat64()
+	case sqlBool:
+		return d.ReadBool()
+	case sqlBytes:
+		return d.ReadBytes()
+	case sqlString:
+		return d.ReadString()
+	case sqlTime:
+		var v time.Time
+		if err := new(timeCodec).DecodeTo(d, reflect.ValueOf(&v).Elem()); err != nil {
+			return nil, err
+		}
+		return v, nil
+	default:
+		return nil, errors.New("binary: invalid driver value kind " + strconv.Itoa(int(kind)))
+	}
+}
+
+// ------------------------------------------------------------------------------
+
+// sqlValue encodes a value returned by driver.Valuer into a self-describing format, as an
+// array of its kind and the value, so that it can be decoded without knowing its type.
+func (e *formatEncoder) sqlValue(v driver.Value) error {
+	e.w.writeArray(2)
+	switch v := v.(type) {
+	case nil:
+		e.w.writeUint(uint64(sqlNull))
+		e.w.writeNil()
+	case int64:
+		e.w.writeUint(uint64(sqlInt64))
+		e.w.writeInt(v)
+	case float64:
+		e.w.writeUint(uint64(sqlFloat64))
+		e.w.writeFloat64(v)
+	case bool:
+		e.w.writeUint(uint64(sqlBool))
+		e.w.writeBool(v)
+	case []byte:
+		e.w.writeUint(uint64(sqlBytes))
+		e.w.writeBytes(v)
+	case string:
+		e.w.writeUint(uint64(sqlString))
+		e.w.writeString(v)
+	case time.Time:
+		e.w.writeUint(uint64(sqlTime))
+		e.w.writeTime(v)
+	default:
+		return errors.New("binary: unsupported driver value of type " + reflect.TypeOf(v).String())
+	}
+	return nil
+}
+
+// sqlValue decodes a value returned by driver.Valuer from a self-describing format.
+func (d *formatDecoder) sqlValue() (driver.Value, error) {
+	n, err := d.r.readArray()
+	switch {
+	case err != nil:
+		return nil, err
+	case n != 2:
+		return nil, errors.New("binary: expected the kind and the value of a driver value")
+	}
+
+	kind, err := d.r.readUint()
+	if err != nil {
+		return nil, err
+	}
+
+	switch byte(kind) {
+	case sqlNull:
+		if !d.r.readNil() {
+			return nil, errors.New("binary: expected a nil driver value")
+		}
+		return nil, nil
+	case sqlInt64:
+		return d.r.readInt()
+	case sqlFloat64:
+		return d.r.readFloat()
+	case sqlBool:
+		return d.r.readBool()
+	case sqlBytes:
+		return d.bytes()
+	case sqlString:
+		b, err := d.string()
+		return string(b), err
+	case sqlTime:
+		return d.r.readTime()
+	default:
+		return nil, errors.New("binary: invalid driver value kind " + strconv.FormatUint(kind, 10))
+	}
+}
@@ -0,0 +1,184 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package binary
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type sqlRow struct {
+	Name    sql.NullString
+	Age     sql.NullInt64
+	Rank    sql.NullInt32
+	Level   sql.NullInt16
+	Flags   sql.NullByte
+	Score   sql.NullFloat64
+	Active  sql.NullBool
+	Created sql.NullTime
+	Email   sql.Null[string]
+}
+
+func TestSQL_Null(t *testing.T) {
+	for _, v := range []sqlRow{
+		{},
+		{
+			Name:    sql.NullString{String: "Roman", Valid: true},
+			Age:     sql.NullInt64{Int64: 42, Valid: true},
+			Rank:    sql.NullInt32{Int32: -1, Valid: true},
+			Level:   sql.NullInt16{Int16: 7, Valid: true},
+			Flags:   sql.NullByte{Byte: 0xff, Valid: true},
+			Score:   sql.NullFloat64{Float64: 1.5, Valid: true},
+			Active:  sql.NullBool{Valid: true},
+			Created: sql.NullTime{Time: time.Unix(1700000000, 0).UTC(), Valid: true},
+			Email:   sql.Null[string]{V: "roman@example.com", Valid: true},
+		},
+	} {
+		b, err := Marshal(&v)
+		assert.NoError(t, err)
+
+		size, err := Size(&v)
+		assert.NoError(t, err)
+		assert.Equal(t, len(b), size)
+
+		var out sqlRow
+		assert.NoError(t, Unmarshal(b, &out))
+		assert.Equal(t, v, out)
+	}
+
+	// Null values only take their presence byte
+	b, err := Marshal(&sqlRow{})
+	assert.NoError(t, err)
+	assert.Equal(t, make([]byte, 9), b)
+}
+
+func TestSQL_NullPointer(t *testing.T) {
+	b, err := Marshal(&sql.NullString{String: "Roman", Valid: true})
+	assert.NoError(t, err)
+
+	// The null types are encoded like pointers, and like the Null type of this package
+	name := "Roman"
+	expect, err := Marshal(&name)
+	assert.NoError(t, err)
+	assert.Equal(t, append([]byte{1}, expect...), b)
+
+	var out Null[string]
+	assert.NoError(t, Unmarshal(b, &out))
+	assert.Equal(t, NullOf(name), out)
+}
+
+// sqlMoney is an amount of money which only converts to and from a database column.
+type sqlMoney struct {
+	cents int64
+}
+
+func (m sqlMoney) Value() (driver.Value, error) {
+	return strconv.FormatFloat(float64(m.cents)/100, 'f', 2, 64), nil
+}
+
+func (m *sqlMoney) Scan(v any) error {
+	s, ok := v.(string)
+	if !ok {
+		return errors.New("expected a string")
+	}
+
+	f, err := strconv.ParseFloat(s, 64)
+	m.cents = int64(f*100 + 0.5)
+	return err
+}
+
+// sqlRecord is a value which converts to any of the driver values.
+type sqlRecord struct {
+	v driver.Value
+}
+
+func (r *sqlRecord) Value() (driver.Value, error) { return r.v, nil }
+func (r *sqlRecord) Scan(v any) error             { r.v = v; return nil }
+
+type sqlOrder struct {
+	ID     int64
+	Amount sqlMoney  `binary:",sql"`
+	Extra  sqlRecord `binary:",sql"`
+}
+
+func TestSQL_Valuer(t *testing.T) {
+	for _, extra := range []driver.Value{
+		nil,
+		int64(-42),
+		float64(1.5),
+		true,
+		[]byte("raw"),
+		"text",
+		time.Unix(1700000000, 0).UTC(),
+	} {
+		v := sqlOrder{ID: 1, Amount: sqlMoney{cents: 1234}, Extra: sqlRecord{extra}}
+		b, err := Marshal(&v)
+		assert.NoError(t, err)
+
+		size, err := Size(&v)
+		assert.NoError(t, err)
+		assert.Equal(t, len(b), size)
+
+		var out sqlOrder
+		assert.NoError(t, Unmarshal(b, &out))
+		assert.Equal(t, v, out)
+
+		// The values can be decoded from their schema, or converted to another format
+		s, err := Marshal(&v, SelfDescribing())
+		assert.NoError(t, err)
+
+		fields, err := DecodeAny(s, SelfDescribing())
+		assert.NoError(t, err)
+		assert.Equal(t, "12.34", fields.(map[string]interface{})["Amount"])
+		assert.Equal(t, extra, fields.(map[string]interface{})["Extra"])
+
+		c, err := MarshalCBOR(&v)
+		assert.NoError(t, err)
+
+		out = sqlOrder{}
+		assert.NoError(t, UnmarshalCBOR(c, &out))
+		assert.Equal(t, v, out)
+	}
+}
+
+func TestSQL_Codec(t *testing.T) {
+	codec, err := SQLCodec(reflect.TypeOf(sqlMoney{}))
+	assert.NoError(t, err)
+
+	RegisterCodec(reflect.TypeOf(sqlMoney{}), codec)
+	defer RegisterCodec(reflect.TypeOf(sqlMoney{}), nil)
+
+	b, err := Marshal(sqlMoney{cents: 99})
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{byte(sqlString), 4, '0', '.', '9', '9'}, b)
+
+	var out sqlMoney
+	assert.NoError(t, Unmarshal(b, &out))
+	assert.Equal(t, int64(99), out.cents)
+
+	s, err := SchemaOf(reflect.TypeOf(sqlMoney{}))
+	assert.NoError(t, err)
+	assert.Equal(t, KindSQL, s.Kind)
+}
+
+func TestSQL_Invalid(t *testing.T) {
+	_, err := SQLCodec(reflect.TypeOf(0))
+	assert.Error(t, err)
+
+	_, err = SQLCodec(reflect.TypeOf(&sqlMoney{}))
+	assert.Error(t, err)
+
+	_, err = Marshal(&sqlOrder{Extra: sqlRecord{int32(1)}})
+	assert.Error(t, err)
+
+	var out sqlOrder
+	assert.Error(t, Unmarshal([]byte{1, 9}, &out))
+}
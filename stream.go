@@ -0,0 +1,110 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package binary
+
+import (
+	"io"
+	"iter"
+)
+
+// StreamWriter writes a sequence of values of type T to a stream, each of them framed
+// with its length like EncodeMessage. As the stream has no header, it suits append-only
+// files: records can be appended over time, even by different writers.
+type StreamWriter[T any] struct {
+	e *Encoder
+}
+
+// NewStreamWriter creates a buffered writer of values of type T, which requires calling
+// Flush once the values have been written.
+func NewStreamWriter[T any](w io.Writer, opts ...Option) *StreamWriter[T] {
+	return &StreamWriter[T]{e: NewEncoderSize(w, defaultBufferSize, opts...)}
+}
+
+// Write encodes a value into the stream.
+func (s *StreamWriter[T]) Write(v T) error {
+	return s.e.EncodeMessage(&v)
+}
+
+// Flush writes any buffered values to the underlying writer.
+func (s *StreamWriter[T]) Flush() error {
+	return s.e.Flush()
+}
+
+// StreamReader reads a sequence of values of type T written by a StreamWriter.
+type StreamReader[T any] struct {
+	d *Decoder
+}
+
+// NewStreamReader creates a reader of values of type T. The options must match the ones
+// used to write the values.
+func NewStreamReader[T any](r io.Reader, opts ...Option) *StreamReader[T] {
+	return &StreamReader[T]{d: NewDecoder(r, opts...)}
+}
+
+// Next decodes the next value of the stream. It returns io.EOF once the stream ends after
+// a whole value, and io.ErrUnexpectedEOF if the last value is truncated, for example
+// because the writer stopped while appending it. A value which fails to decode is
+// skipped, so that the next call moves on to the following value.
+func (s *StreamReader[T]) Next() (v T, err error) {
+	err = s.d.DecodeMessage(&v)
+	return
+}
+
+// All returns an iterator over the remaining values of the stream, which stops at the end
+// of the stream or after yielding the first error.
+func (s *StreamReader[T]) All() iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		for {
+			v, err := s.Next()
+			switch {
+			case err == io.EOF:
+				return
+			case !yield(v, err) || err != nil:
+				return
+			}
+		}
+	}
+}
+
+// ------------------------------------------------------------------------------
+
+// EncodeStream writes a sequence of values of type T to a stream, prefixed with their
+// count so that the reader knows whether the sequence is complete. Each value is framed
+// with its length, like the values of a StreamWriter.
+func EncodeStream[T any](w io.Writer, values []T, opts ...Option) error {
+	e := NewEncoderSize(w, defaultBufferSize, opts...)
+	e.WriteUvarint(uint64(len(values)))
+	for i := range values {
+		if err := e.EncodeMessage(&values[i]); err != nil {
+			return err
+		}
+	}
+	return e.Flush()
+}
+
+// DecodeStream reads a sequence of values of type T written by EncodeStream, and calls
+// the function with each of them. It returns io.ErrUnexpectedEOF if the stream ends
+// before the count of values, and stops at the first error returned by the function.
+func DecodeStream[T any](r io.Reader, fn func(T) error, opts ...Option) error {
+	d := NewDecoder(r, opts...)
+	n, err := d.ReadUvarint()
+	if err != nil {
+		return err
+	}
+
+	for i := uint64(0); i < n; i++ {
+		var v T
+		switch err := d.DecodeMessage(&v); {
+		case err == io.EOF:
+			return io.ErrUnexpectedEOF
+		case err != nil:
+			return err
+		}
+
+		if err := fn(v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
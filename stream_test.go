@@ -0,0 +1,89 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package binary
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type streamRecord struct {
+	ID   int
+	Name string
+}
+
+func TestStreamWriter(t *testing.T) {
+	var buffer bytes.Buffer
+	w := NewStreamWriter[streamRecord](&buffer)
+	assert.NoError(t, w.Write(streamRecord{ID: 1, Name: "a"}))
+	assert.NoError(t, w.Write(streamRecord{ID: 2, Name: "b"}))
+	assert.NoError(t, w.Flush())
+
+	// Records are appended by another writer
+	w = NewStreamWriter[streamRecord](&buffer)
+	assert.NoError(t, w.Write(streamRecord{ID: 3, Name: "c"}))
+	assert.NoError(t, w.Flush())
+
+	r := NewStreamReader[streamRecord](bytes.NewReader(buffer.Bytes()))
+	v, err := r.Next()
+	assert.NoError(t, err)
+	assert.Equal(t, streamRecord{ID: 1, Name: "a"}, v)
+
+	var out []streamRecord
+	for v, err := range r.All() {
+		assert.NoError(t, err)
+		out = append(out, v)
+	}
+	assert.Equal(t, []streamRecord{{ID: 2, Name: "b"}, {ID: 3, Name: "c"}}, out)
+
+	_, err = r.Next()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestStreamTruncated(t *testing.T) {
+	var buffer bytes.Buffer
+	w := NewStreamWriter[streamRecord](&buffer)
+	assert.NoError(t, w.Write(streamRecord{ID: 1, Name: "a"}))
+	assert.NoError(t, w.Write(streamRecord{ID: 2, Name: "b"}))
+	assert.NoError(t, w.Flush())
+
+	r := NewStreamReader[streamRecord](bytes.NewReader(buffer.Bytes()[:buffer.Len()-1]))
+	var errs []error
+	for _, err := range r.All() {
+		errs = append(errs, err)
+	}
+	assert.Equal(t, []error{nil, io.ErrUnexpectedEOF}, errs)
+}
+
+func TestEncodeStream(t *testing.T) {
+	values := []streamRecord{{ID: 1, Name: "a"}, {ID: 2, Name: "b"}}
+
+	var buffer bytes.Buffer
+	assert.NoError(t, EncodeStream(&buffer, values))
+	assert.Equal(t, byte(2), buffer.Bytes()[0])
+
+	var out []streamRecord
+	assert.NoError(t, DecodeStream(bytes.NewReader(buffer.Bytes()), func(v streamRecord) error {
+		out = append(out, v)
+		return nil
+	}))
+	assert.Equal(t, values, out)
+
+	// The stream ends before the count of values
+	err := DecodeStream(bytes.NewReader(buffer.Bytes()[:4]), func(v streamRecord) error {
+		return nil
+	})
+	assert.Equal(t, io.ErrUnexpectedEOF, err)
+
+	// The function stops the decoding
+	stop := errors.New("stop")
+	err = DecodeStream(bytes.NewReader(buffer.Bytes()), func(v streamRecord) error {
+		return stop
+	})
+	assert.Equal(t, stop, err)
+}
@@ -0,0 +1,106 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package binary
+
+import (
+	"reflect"
+	"strings"
+)
+
+// The name of the struct tag used by this package.
+const tagName = "binary"
+
+// fieldTag represents a parsed `binary:"name,option,key=value"` struct tag.
+type fieldTag struct {
+	Name    string     // The name of the field, empty if not specified
+	Skip    bool       // Whether the field should be skipped entirely
+	Options tagOptions // The remaining comma-separated options
+}
+
+// parseTag parses the binary struct tag of a field.
+func parseTag(field reflect.StructField) (tag fieldTag) {
+	value, ok := field.Tag.Lookup(tagName)
+	if !ok {
+		return
+	}
+
+	if value == "-" {
+		tag.Skip = true
+		return
+	}
+
+	tag.Name, tag.Options = value, ""
+	if i := strings.Index(value, ","); i >= 0 {
+		tag.Name, tag.Options = value[:i], tagOptions(value[i+1:])
+	}
+	return
+}
+
+// The name of the struct tag honored by the JSONTags option.
+const jsonTagName = "json"
+
+// jsonTag represents the parts of a `json:"name,omitempty"` struct tag which select and
+// name the fields, honored by the JSONTags option for the fields without a binary tag.
+type jsonTag struct {
+	Name string // The name of the field, empty if not specified
+	Skip bool   // Whether the field is skipped with `json:"-"`
+}
+
+// parseJSONTag parses the json struct tag of a field, unless it has a binary tag which
+// takes precedence.
+func parseJSONTag(field reflect.StructField) (tag jsonTag) {
+	if _, ok := field.Tag.Lookup(tagName); ok {
+		return
+	}
+
+	value, ok := field.Tag.Lookup(jsonTagName)
+	switch {
+	case !ok:
+		return
+	case value == "-":
+		tag.Skip = true
+		return
+	}
+
+	tag.Name = value
+	if i := strings.Index(value, ","); i >= 0 {
+		tag.Name = value[:i]
+	}
+	return
+}
+
+// tagOptions is the string following a comma in a struct field's tag, or
+// the empty string. It does not include the leading comma.
+type tagOptions string
+
+// Contains reports whether a comma-separated list of options contains a
+// particular flag option.
+func (o tagOptions) Contains(name string) bool {
+	_, ok := o.Lookup(name)
+	return ok
+}
+
+// Lookup returns the value of an option, which is either a flag ("fixed") or
+// a key-value pair ("id=3").
+func (o tagOptions) Lookup(name string) (value string, ok bool) {
+	s := string(o)
+	for s != "" {
+		var next string
+		if i := strings.Index(s, ","); i >= 0 {
+			s, next = s[:i], s[i+1:]
+		}
+
+		key := s
+		if i := strings.Index(s, "="); i >= 0 {
+			key, value = s[:i], s[i+1:]
+		}
+
+		if key == name {
+			return value, true
+		}
+
+		s, value = next, ""
+	}
+	return "", false
+}
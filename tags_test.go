@@ -0,0 +1,38 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package binary
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTag(t *testing.T) {
+	type T struct {
+		A int `binary:"-"`
+		B int `binary:"b,id=3,fixed"`
+		C int `binary:",id=4"`
+		D int
+	}
+
+	rt := reflect.TypeOf(T{})
+	assert.True(t, parseTag(rt.Field(0)).Skip)
+
+	b := parseTag(rt.Field(1))
+	assert.Equal(t, "b", b.Name)
+	assert.True(t, b.Options.Contains("fixed"))
+	assert.False(t, b.Options.Contains("fix"))
+	id, ok := b.Options.Lookup("id")
+	assert.True(t, ok)
+	assert.Equal(t, "3", id)
+
+	c := parseTag(rt.Field(2))
+	assert.Equal(t, "", c.Name)
+	assert.True(t, c.Options.Contains("id"))
+
+	d := parseTag(rt.Field(3))
+	assert.Equal(t, fieldTag{}, d)
+}
@@ -0,0 +1,77 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package binary
+
+import (
+	"io"
+	"net"
+	"slices"
+	"strconv"
+)
+
+// SinkError is returned when one of the writers of a Tee fails, telling which one failed
+// so that the caller knows whether, say, the connection or the journal is broken. The
+// writers which precede it have received the bytes, while the ones which follow it have
+// not.
+type SinkError struct {
+	Index int   // The index of the writer which failed, in the order given to Tee
+	Err   error // The error returned by the writer
+}
+
+// Error returns the message of the error.
+func (e *SinkError) Error() string {
+	return "binary: write to sink " + strconv.Itoa(e.Index) + ": " + e.Err.Error()
+}
+
+// Unwrap returns the error returned by the writer.
+func (e *SinkError) Unwrap() error {
+	return e.Err
+}
+
+// Tee returns a writer which writes to several writers at once, such as a connection and
+// a journal on disk, so that an encoder can write a value to all of them. Like with
+// io.MultiWriter, the writers are written in order and writing stops at the first one
+// which fails, but the error is a *SinkError telling which one failed, and the encoder
+// then stops encoding as with any other error. A vectored encoder writes its segments to
+// every writer with net.Buffers, keeping a single writev system call per connection.
+//
+//	encoder := binary.NewEncoderSize(binary.Tee(conn, journal), 4096)
+func Tee(writers ...io.Writer) io.Writer {
+	return &teeWriter{writers: writers}
+}
+
+// teeWriter represents a writer which writes to several writers.
+type teeWriter struct {
+	writers []io.Writer
+}
+
+// Write implements io.Writer interface.
+func (t *teeWriter) Write(p []byte) (int, error) {
+	for i, w := range t.writers {
+		n, err := w.Write(p)
+		switch {
+		case err != nil:
+			return 0, &SinkError{Index: i, Err: err}
+		case n != len(p):
+			return 0, &SinkError{Index: i, Err: io.ErrShortWrite}
+		}
+	}
+	return len(p), nil
+}
+
+// writeBuffers writes the segments of a vectored encoder to every writer, with a copy of
+// the segments for each of them but the last, since writing consumes them.
+func (t *teeWriter) writeBuffers(segments net.Buffers) (n int64, err error) {
+	for i, w := range t.writers {
+		buffers := segments
+		if i < len(t.writers)-1 {
+			buffers = slices.Clone(segments)
+		}
+
+		if n, err = buffers.WriteTo(w); err != nil {
+			return 0, &SinkError{Index: i, Err: err}
+		}
+	}
+	return
+}
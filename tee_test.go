@@ -0,0 +1,91 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package binary
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTee(t *testing.T) {
+	v := &hashedDocument{
+		Title: "document",
+		Body:  bytes.Repeat([]byte("body"), 1000),
+		Tags:  map[string]string{"a": strings.Repeat("b", 100)},
+	}
+
+	expect, err := Marshal(v)
+	assert.NoError(t, err)
+
+	for _, newEncoder := range []func(io.Writer) *Encoder{
+		func(w io.Writer) *Encoder { return NewEncoder(w) },
+		func(w io.Writer) *Encoder { return NewEncoderSize(w, 64) },
+		func(w io.Writer) *Encoder { return NewVectoredEncoder(w, 16) },
+	} {
+		var conn, journal bytes.Buffer
+		e := newEncoder(Tee(&conn, &journal))
+		assert.NoError(t, e.Encode(v))
+		assert.NoError(t, e.Flush())
+		assert.Equal(t, expect, conn.Bytes())
+		assert.Equal(t, expect, journal.Bytes())
+	}
+}
+
+// failingWriter fails after accepting a number of bytes.
+type failingWriter struct {
+	n int
+}
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	if len(p) > w.n {
+		return 0, io.ErrClosedPipe
+	}
+
+	w.n -= len(p)
+	return len(p), nil
+}
+
+func TestTee_Error(t *testing.T) {
+	for _, newEncoder := range []func(io.Writer) *Encoder{
+		func(w io.Writer) *Encoder { return NewEncoder(w) },
+		func(w io.Writer) *Encoder { return NewVectoredEncoder(w, 16) },
+	} {
+		var conn bytes.Buffer
+		e := newEncoder(Tee(&conn, &failingWriter{n: 10}))
+		err := e.Encode(&hashedDocument{Body: bytes.Repeat([]byte("body"), 100)})
+		if err == nil {
+			err = e.Flush()
+		}
+
+		// The error tells which writer failed
+		var sink *SinkError
+		assert.True(t, errors.As(err, &sink))
+		assert.Equal(t, 1, sink.Index)
+		assert.True(t, errors.Is(err, io.ErrClosedPipe))
+		assert.Equal(t, "binary: write to sink 1: io: read/write on closed pipe", err.Error())
+
+		// The encoder stops writing once a writer failed
+		n := conn.Len()
+		assert.Error(t, e.Encode("more"))
+		assert.Error(t, e.Flush())
+		assert.Equal(t, n, conn.Len())
+	}
+}
+
+func TestTee_ShortWrite(t *testing.T) {
+	_, err := Tee(io.Discard, shortWriter{}).Write([]byte("abc"))
+	assert.True(t, errors.Is(err, io.ErrShortWrite))
+}
+
+// shortWriter writes one byte less than it is given.
+type shortWriter struct{}
+
+func (shortWriter) Write(p []byte) (int, error) {
+	return len(p) - 1, nil
+}
@@ -0,0 +1,118 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package binary
+
+import (
+	"encoding"
+	"errors"
+	"reflect"
+)
+
+// The reflected types of the text marshaling interfaces
+var (
+	typeTextMarshaler   = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	typeTextUnmarshaler = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// TextCodec returns a codec which encodes the values of a type in their text form,
+// prefixed with its length, for types which implement both encoding.TextMarshaler and
+// encoding.TextUnmarshaler but are not supported otherwise, such as some UUID types.
+// Registering it with RegisterCodec applies it wherever the type appears, while the
+// `binary:",text"` tag applies it to a single field.
+func TextCodec(t reflect.Type) (Codec, error) {
+	return scanText(t)
+}
+
+// scanText returns a codec which encodes a value with its encoding.TextMarshaler and
+// decodes it with its encoding.TextUnmarshaler, selected with a `binary:",text"` tag.
+func scanText(t reflect.Type) (Codec, error) {
+	if t.Kind() == reflect.Ptr {
+		return nil, errors.New("binary: text encoding is not supported for " + t.String())
+	}
+
+	ptr := reflect.PtrTo(t)
+	out := new(textMarshalerCodec)
+	switch {
+	case t.Implements(typeTextMarshaler):
+	case ptr.Implements(typeTextMarshaler):
+		out.ptrMarshaler = true
+	default:
+		return nil, errors.New("binary: " + t.String() + " does not implement encoding.TextMarshaler")
+	}
+
+	switch {
+	case ptr.Implements(typeTextUnmarshaler):
+		out.ptrUnmarshaler = true
+	case t.Implements(typeTextUnmarshaler):
+	default:
+		return nil, errors.New("binary: " + t.String() + " does not implement encoding.TextUnmarshaler")
+	}
+
+	return out, nil
+}
+
+// ------------------------------------------------------------------------------
+
+// textMarshalerCodec represents a codec which delegates to the encoding.TextMarshaler
+// and encoding.TextUnmarshaler implementations of a type.
+type textMarshalerCodec struct {
+	ptrMarshaler   bool // Whether MarshalText is declared on the pointer receiver
+	ptrUnmarshaler bool // Whether UnmarshalText is declared on the pointer receiver
+}
+
+// Encode encodes a value into the encoder.
+func (c *textMarshalerCodec) EncodeTo(e *Encoder, rv reflect.Value) (err error) {
+	var text []byte
+	if text, err = c.marshaler(rv).MarshalText(); err == nil {
+		e.WriteUvarint(uint64(len(text)))
+		e.Write(text)
+	}
+	return
+}
+
+// Decode decodes into a reflect value from the decoder.
+func (c *textMarshalerCodec) DecodeTo(d *Decoder, rv reflect.Value) (err error) {
+	var l int
+	var text []byte
+	if l, err = d.readStringLen(); err != nil {
+		return
+	}
+
+	// UnmarshalText must copy the text if it needs to retain it, so it is not copied here
+	if text, err = d.Slice(l); err == nil {
+		err = c.unmarshaler(rv).UnmarshalText(text)
+	}
+	return
+}
+
+// Size returns -1, as the size is only known once MarshalText was called, which may be
+// expensive or return different bytes every time, so the value is encoded instead.
+func (c *textMarshalerCodec) Size(rv reflect.Value) int {
+	return -1
+}
+
+// marshaler returns the encoding.TextMarshaler of the value. If the method is declared
+// on the pointer receiver and the value is not addressable, it is copied first.
+func (c *textMarshalerCodec) marshaler(rv reflect.Value) encoding.TextMarshaler {
+	if !c.ptrMarshaler {
+		return rv.Interface().(encoding.TextMarshaler)
+	}
+
+	if !rv.CanAddr() {
+		ptr := reflect.New(rv.Type())
+		ptr.Elem().Set(rv)
+		rv = ptr.Elem()
+	}
+
+	return rv.Addr().Interface().(encoding.TextMarshaler)
+}
+
+// unmarshaler returns the encoding.TextUnmarshaler of the value.
+func (c *textMarshalerCodec) unmarshaler(rv reflect.Value) encoding.TextUnmarshaler {
+	if !c.ptrUnmarshaler {
+		return rv.Interface().(encoding.TextUnmarshaler)
+	}
+
+	return rv.Addr().Interface().(encoding.TextUnmarshaler)
+}
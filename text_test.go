@@ -0,0 +1,130 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package binary
+
+import (
+	"errors"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// textID implements only the text marshaling interfaces, and has no exported fields
+type textID struct {
+	value string
+}
+
+func (id textID) MarshalText() ([]byte, error) {
+	if strings.Contains(id.value, "!") {
+		return nil, errors.New("invalid id")
+	}
+	return []byte("id:" + id.value), nil
+}
+
+func (id *textID) UnmarshalText(b []byte) error {
+	if !strings.HasPrefix(string(b), "id:") {
+		return errors.New("invalid id")
+	}
+	id.value = string(b[3:])
+	return nil
+}
+
+func TestText(t *testing.T) {
+	type host struct {
+		Name string
+		IP   net.IP `binary:",text"`
+		ID   textID `binary:",text"`
+	}
+
+	v := &host{Name: "a", IP: net.ParseIP("192.168.1.1"), ID: textID{"42"}}
+	b, err := Marshal(v)
+	assert.NoError(t, err)
+	assert.Contains(t, string(b), "192.168.1.1")
+	assert.Contains(t, string(b), "id:42")
+
+	size, err := Size(v)
+	assert.NoError(t, err)
+	assert.Equal(t, len(b), size)
+
+	var out host
+	assert.NoError(t, Unmarshal(b, &out))
+	assert.Equal(t, v, &out)
+
+	// Errors of the methods are returned
+	_, err = Marshal(&host{ID: textID{"!"}})
+	assert.Error(t, err)
+	b[len(b)-5] = 'x'
+	assert.Error(t, Unmarshal(b, &out))
+}
+
+// countedText counts the number of times it was marshaled
+type countedText struct {
+	calls *int
+}
+
+func (m countedText) MarshalText() ([]byte, error) {
+	*m.calls++
+	return []byte("text"), nil
+}
+
+func (m *countedText) UnmarshalText([]byte) error {
+	return nil
+}
+
+func TestText_Calls(t *testing.T) {
+	type msg struct {
+		Text countedText `binary:",text"`
+	}
+
+	// The marshaler is called once per value, and never to compute a size
+	var calls int
+	b, err := Marshal(&msg{countedText{&calls}})
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("\x04text"), b)
+	assert.Equal(t, 1, calls)
+
+	n, err := Size(&msg{countedText{&calls}})
+	assert.NoError(t, err)
+	assert.Equal(t, 5, n)
+	assert.Equal(t, 2, calls)
+}
+
+func TestText_Unsupported(t *testing.T) {
+	type invalid struct {
+		Name string `binary:",text"`
+	}
+
+	_, err := Marshal(&invalid{})
+	assert.Error(t, err)
+
+	_, err = TextCodec(reflect.TypeOf(&textID{}))
+	assert.Error(t, err)
+}
+
+func TestText_Register(t *testing.T) {
+	type msg struct {
+		IDs []textID
+	}
+
+	codec, err := TextCodec(reflect.TypeOf(textID{}))
+	assert.NoError(t, err)
+	RegisterCodec(reflect.TypeOf(textID{}), codec)
+	defer RegisterCodec(reflect.TypeOf(textID{}), nil)
+
+	v := &msg{IDs: []textID{{"1"}, {"2"}}}
+	b, err := Marshal(v)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{2, 4, 'i', 'd', ':', '1', 4, 'i', 'd', ':', '2'}, b)
+
+	var out msg
+	assert.NoError(t, Unmarshal(b, &out))
+	assert.Equal(t, v, &out)
+
+	schema, err := SchemaOf(reflect.TypeOf(textID{}))
+	assert.NoError(t, err)
+	assert.Equal(t, KindBytes, schema.Kind)
+}
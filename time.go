@@ -0,0 +1,234 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package binary
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"time"
+)
+
+// The reflected type of time.Time
+var typeTime = reflect.TypeOf(time.Time{})
+
+// The number of seconds between January 1, year 1 and the Unix epoch
+const unixToInternal int64 = 62135596800
+
+// The versions of the binary format of time.MarshalBinary
+const (
+	timeVersionV1 byte = 1 // Zone offset in minutes
+	timeVersionV2 byte = 2 // Zone offset in minutes and seconds
+)
+
+// The range of the times which can be represented as Unix nanoseconds. The lowest
+// value is reserved to represent the zero time.
+var (
+	minUnixNano = time.Unix(0, math.MinInt64)
+	maxUnixNano = time.Unix(0, math.MaxInt64)
+)
+
+// scanTime returns a codec for time.Time which encodes the time as the number of
+// nanoseconds since the Unix epoch, selected with a `binary:",unixnano"` tag. This is
+// more compact than the default encoding, but the location is not preserved and the
+// time is decoded in UTC.
+func scanTime(t reflect.Type) (Codec, error) {
+	if t != typeTime {
+		return nil, errors.New("binary: unixnano encoding is not supported for " + t.String())
+	}
+
+	return &timeCodec{unixNano: true}, nil
+}
+
+// ------------------------------------------------------------------------------
+
+// timeCodec represents a native codec for time.Time. By default, it produces the same
+// bytes as time.MarshalBinary prefixed with their length, preserving the zone offset,
+// but without allocating. The monotonic clock reading is never encoded.
+type timeCodec struct {
+	unixNano bool // Whether the time is encoded as nanoseconds since the Unix epoch
+}
+
+// Encode encodes a value into the encoder.
+func (c *timeCodec) EncodeTo(e *Encoder, rv reflect.Value) error {
+	t := timeOf(rv)
+	if c.unixNano {
+		n, err := unixNanoOf(t)
+		if err == nil {
+			e.WriteVarint(n)
+		}
+		return err
+	}
+
+	version, offsetMin, offsetSec, err := zoneOf(t)
+	if err != nil {
+		return err
+	}
+
+	// Seconds since year 1, in big-endian like time.MarshalBinary
+	sec := t.Unix() + unixToInternal
+	e.scratch[0] = 15
+	e.scratch[1] = version
+	for i := 0; i < 8; i++ {
+		e.scratch[2+i] = byte(sec >> uint(56-8*i))
+	}
+	if version == timeVersionV2 {
+		e.scratch[0] = 16
+	}
+	e.Write(e.scratch[:10])
+
+	// Nanoseconds and zone offset, in big-endian like time.MarshalBinary
+	nsec := int32(t.Nanosecond())
+	e.scratch[0] = byte(nsec >> 24)
+	e.scratch[1] = byte(nsec >> 16)
+	e.scratch[2] = byte(nsec >> 8)
+	e.scratch[3] = byte(nsec)
+	e.scratch[4] = byte(offsetMin >> 8)
+	e.scratch[5] = byte(offsetMin)
+	e.scratch[6] = byte(offsetSec)
+	if version == timeVersionV2 {
+		e.Write(e.scratch[:7])
+		return nil
+	}
+
+	e.Write(e.scratch[:6])
+	return nil
+}
+
+// Decode decodes into a reflect value from the decoder.
+func (c *timeCodec) DecodeTo(d *Decoder, rv reflect.Value) (err error) {
+	if c.unixNano {
+		var n int64
+		if n, err = d.ReadVarint(); err == nil {
+			setTime(rv, fromUnixNano(n))
+		}
+		return
+	}
+
+	var l uint64
+	var version byte
+	if l, err = d.ReadUvarint(); err != nil {
+		return
+	}
+	if l == 0 {
+		return errors.New("binary: invalid time, no data")
+	}
+	if version, err = d.r.ReadByte(); err != nil {
+		return
+	}
+
+	switch {
+	case version == timeVersionV1 && l == 15:
+	case version == timeVersionV2 && l == 16:
+	default:
+		return errors.New("binary: invalid time encoding")
+	}
+
+	var b []byte
+	if b, err = d.sliceOrScratch(8); err != nil {
+		return
+	}
+
+	var sec int64
+	for i := 0; i < 8; i++ {
+		sec = sec<<8 | int64(b[i])
+	}
+
+	if b, err = d.sliceOrScratch(int(l) - 9); err != nil {
+		return
+	}
+
+	nsec := int64(b[0])<<24 | int64(b[1])<<16 | int64(b[2])<<8 | int64(b[3])
+	offset := int(int16(b[4])<<8|int16(b[5])) * 60
+	if version == timeVersionV2 {
+		offset += int(int8(b[6]))
+	}
+
+	t := time.Unix(sec-unixToInternal, nsec)
+	switch _, local := t.Zone(); {
+	case offset == -60:
+		t = t.UTC()
+	case offset != local:
+		t = t.In(time.FixedZone("", offset))
+	}
+
+	setTime(rv, t)
+	return
+}
+
+// Size returns the encoded size of the value.
+func (c *timeCodec) Size(rv reflect.Value) int {
+	t := timeOf(rv)
+	if c.unixNano {
+		n, err := unixNanoOf(t)
+		if err != nil {
+			return -1
+		}
+		return varintSize(n)
+	}
+
+	switch version, _, _, err := zoneOf(t); {
+	case err != nil:
+		return -1
+	case version == timeVersionV2:
+		return 17
+	default:
+		return 16
+	}
+}
+
+// timeOf returns the time of a reflected value, without copying it to the heap if
+// the value is addressable.
+func timeOf(rv reflect.Value) time.Time {
+	if rv.CanAddr() {
+		return *rv.Addr().Interface().(*time.Time)
+	}
+	return rv.Interface().(time.Time)
+}
+
+// setTime sets the time of an addressable reflected value.
+func setTime(rv reflect.Value, t time.Time) {
+	*rv.Addr().Interface().(*time.Time) = t
+}
+
+// zoneOf returns the zone offset of the time the same way time.MarshalBinary does.
+func zoneOf(t time.Time) (version byte, offsetMin int16, offsetSec int8, err error) {
+	version = timeVersionV1
+	if t.Location() == time.UTC {
+		return version, -1, 0, nil
+	}
+
+	_, offset := t.Zone()
+	if offset%60 != 0 {
+		version = timeVersionV2
+		offsetSec = int8(offset % 60)
+	}
+
+	offset /= 60
+	if offset < -32768 || offset == -1 || offset > 32767 {
+		return 0, 0, 0, errors.New("binary: unexpected zone offset")
+	}
+
+	return version, int16(offset), offsetSec, nil
+}
+
+// unixNanoOf returns the number of nanoseconds since the Unix epoch of the time.
+func unixNanoOf(t time.Time) (int64, error) {
+	switch {
+	case t.IsZero():
+		return math.MinInt64, nil
+	case !t.After(minUnixNano) || t.After(maxUnixNano):
+		return 0, errors.New("binary: time " + t.String() + " is out of the unixnano range")
+	default:
+		return t.UnixNano(), nil
+	}
+}
+
+// fromUnixNano returns the time in UTC of a number of nanoseconds since the Unix epoch.
+func fromUnixNano(n int64) time.Time {
+	if n == math.MinInt64 {
+		return time.Time{}
+	}
+	return time.Unix(0, n).UTC()
+}
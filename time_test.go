@@ -0,0 +1,102 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package binary
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTimeMatchesMarshalBinary(t *testing.T) {
+	base := time.Date(2013, 1, 2, 3, 4, 5, 6, time.UTC)
+	for _, v := range []time.Time{
+		{},
+		base,
+		base.Local(),
+		base.In(time.FixedZone("CET", 3600)),
+		base.In(time.FixedZone("LMT", -17762)),
+		time.Date(1, 1, 1, 0, 0, 0, 1, time.UTC),
+		time.Now(),
+	} {
+		expect, err := v.MarshalBinary()
+		assert.NoError(t, err)
+
+		b, err := Marshal(&v)
+		assert.NoError(t, err)
+		assert.Equal(t, expect, b[1:])
+		assert.Equal(t, len(expect), int(b[0]))
+
+		size, err := Size(&v)
+		assert.NoError(t, err)
+		assert.Equal(t, len(b), size)
+
+		var out, ref time.Time
+		assert.NoError(t, Unmarshal(b, &out))
+		assert.NoError(t, ref.UnmarshalBinary(expect))
+		assert.Equal(t, ref, out)
+		assert.True(t, v.Equal(out))
+	}
+}
+
+func TestTimeInvalid(t *testing.T) {
+	var v time.Time
+	assert.Error(t, Unmarshal([]byte{0x0}, &v))
+	assert.Error(t, Unmarshal([]byte{0x2, 0x1, 0x0}, &v))
+	assert.Error(t, Unmarshal([]byte{0xf, 0x1, 0x0}, &v))
+}
+
+func TestTimeUnixNano(t *testing.T) {
+	type event struct {
+		At      time.Time `binary:",unixnano"`
+		Elapsed time.Duration
+		Timeout time.Duration `binary:",fixed"`
+	}
+
+	v := event{
+		At:      time.Date(2013, 1, 2, 3, 4, 5, 6, time.FixedZone("CET", 3600)),
+		Elapsed: 1500 * time.Millisecond,
+		Timeout: time.Minute,
+	}
+
+	b, err := Marshal(&v)
+	assert.NoError(t, err)
+	assert.Equal(t, 9+5+8, len(b))
+
+	var out event
+	assert.NoError(t, Unmarshal(b, &out))
+	assert.True(t, v.At.Equal(out.At))
+	assert.Equal(t, time.UTC, out.At.Location())
+	assert.Equal(t, v.Elapsed, out.Elapsed)
+	assert.Equal(t, v.Timeout, out.Timeout)
+
+	// The zero time is preserved
+	b, err = Marshal(&event{})
+	assert.NoError(t, err)
+	assert.NoError(t, Unmarshal(b, &out))
+	assert.True(t, out.At.IsZero())
+
+	// Times outside of the range fail to encode
+	_, err = Marshal(&event{At: time.Date(3000, 1, 1, 0, 0, 0, 0, time.UTC)})
+	assert.Error(t, err)
+}
+
+func TestTimeUnixNanoUnsupported(t *testing.T) {
+	type invalid struct {
+		At int64 `binary:",unixnano"`
+	}
+
+	_, err := Marshal(&invalid{})
+	assert.Error(t, err)
+}
+
+func TestTimeNoAlloc(t *testing.T) {
+	v := []time.Time{time.Date(2013, 1, 2, 3, 4, 5, 6, time.UTC)}
+	buffer := make([]byte, 0, 64)
+	allocs := testing.AllocsPerRun(100, func() {
+		buffer, _ = MarshalTo(buffer[:0], &v)
+	})
+	assert.Equal(t, 0.0, allocs)
+}
@@ -0,0 +1,44 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package binary
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// Map of the validators registered for the types
+var validators = new(sync.Map) // reflect.Type -> func(reflect.Value) error
+
+// RegisterValidator registers a function which checks every decoded value of a type, such
+// as an enumeration, and fails the decoding with its error if the value is invalid. This
+// rejects the values which are out of range, for example when they come from an older or
+// a newer peer, at the boundary rather than deep in the business logic. It applies to the
+// values nested within other types as well, and registering a nil function removes the
+// validator. Like RegisterCodec, it is best done once during initialization.
+func RegisterValidator[T any](fn func(v T) error) {
+	t := reflect.TypeFor[T]()
+	if fn == nil {
+		validators.Delete(t)
+	} else {
+		validators.Store(t, func(rv reflect.Value) error {
+			if err := fn(*addrOf(rv).(*T)); err != nil {
+				return fmt.Errorf("binary: invalid %s: %w", rv.Type(), err)
+			}
+			return nil
+		})
+	}
+
+	// Invalidate the caches, as the types may have been scanned without the validator
+	invalidate()
+}
+
+// validatorOf returns the validator registered for a type, or nil if there is none.
+func validatorOf(t reflect.Type) func(reflect.Value) error {
+	if fn, ok := validators.Load(t); ok {
+		return fn.(func(reflect.Value) error)
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package binary
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testColor uint8
+
+var errUnknownColor = errors.New("unknown color")
+
+type palette struct {
+	Main   testColor
+	Fixed  testColor `binary:",fixed"`
+	Colors []testColor
+	Byname map[string]testColor
+}
+
+func TestRegisterValidator(t *testing.T) {
+	RegisterValidator(func(v testColor) error {
+		if v > 2 {
+			return fmt.Errorf("%w %d", errUnknownColor, v)
+		}
+		return nil
+	})
+	defer RegisterValidator[testColor](nil)
+
+	valid := palette{Main: 1, Fixed: 2, Colors: []testColor{0, 1}, Byname: map[string]testColor{"red": 2}}
+	for _, invalid := range []palette{
+		{Main: 3},
+		{Fixed: 3},
+		{Colors: []testColor{1, 3}},
+		{Byname: map[string]testColor{"pink": 3}},
+	} {
+		b, err := Marshal(&invalid)
+		assert.NoError(t, err)
+
+		var out palette
+		err = Unmarshal(b, &out)
+		assert.True(t, errors.Is(err, errUnknownColor))
+		assert.Contains(t, err.Error(), "binary: invalid binary.testColor")
+
+		// The other formats are validated as well
+		b, err = MarshalCBOR(&invalid)
+		assert.NoError(t, err)
+		assert.True(t, errors.Is(UnmarshalCBOR(b, &out), errUnknownColor))
+	}
+
+	b, err := Marshal(&valid)
+	assert.NoError(t, err)
+
+	var out palette
+	assert.NoError(t, Unmarshal(b, &out))
+	assert.Equal(t, valid, out)
+
+	// Removing the validator allows any value again
+	RegisterValidator[testColor](nil)
+	b, err = Marshal(&palette{Main: 3})
+	assert.NoError(t, err)
+	assert.NoError(t, Unmarshal(b, &out))
+}
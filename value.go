@@ -0,0 +1,130 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package binary
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+)
+
+// EncodedValue wraps a value so that it can be written as a message framed with its
+// length, like EncodeMessage, with any function accepting an io.WriterTo. The value is
+// encoded into a pooled buffer and its length is written along with it, in a single
+// vectorized write on connections which support it, such as a *net.TCPConn.
+type EncodedValue struct {
+	v    interface{}
+	opts []Option
+}
+
+// NewEncodedValue wraps a value to be encoded with the options.
+func NewEncodedValue(v interface{}, opts ...Option) *EncodedValue {
+	return &EncodedValue{v: v, opts: opts}
+}
+
+// WriteTo encodes the value and writes it, framed with its length, to the writer. It
+// implements io.WriterTo interface.
+func (ev *EncodedValue) WriteTo(w io.Writer) (int64, error) {
+	b, err := MarshalPooled(ev.v, ev.opts...)
+	if err != nil {
+		return 0, err
+	}
+
+	defer b.Release()
+	header := make([]byte, binary.MaxVarintLen64)
+	header = header[:binary.PutUvarint(header, uint64(len(b.Bytes())))]
+	buffers := net.Buffers{header, b.Bytes()}
+	return buffers.WriteTo(w)
+}
+
+// ------------------------------------------------------------------------------
+
+// DecodedValue wraps a pointer so that a message framed with its length can be decoded
+// into it with any function accepting an io.ReaderFrom. Only the bytes of the message
+// are read, so that the reader can be shared with other consumers, unlike a Decoder
+// which may buffer the bytes which follow.
+type DecodedValue struct {
+	v    interface{}
+	opts []Option
+}
+
+// NewDecodedValue wraps a pointer to be decoded with the options. The size of the message
+// can be limited with the MaxMessageSize option.
+func NewDecodedValue(v interface{}, opts ...Option) *DecodedValue {
+	return &DecodedValue{v: v, opts: opts}
+}
+
+// ReadFrom reads a message framed with its length from the reader and decodes it. It
+// returns io.EOF if the reader ends before the message, and io.ErrUnexpectedEOF if it
+// ends within the message. It implements io.ReaderFrom interface.
+func (dv *DecodedValue) ReadFrom(r io.Reader) (int64, error) {
+	var o options
+	o.reset(dv.opts)
+
+	br, ok := r.(io.ByteReader)
+	if !ok {
+		br = &byteReader{r: r}
+	}
+
+	l, err := binary.ReadUvarint(br)
+	n := int64(uvarintSize(l))
+	switch {
+	case err != nil:
+		return 0, err
+	case o.maxMessage > 0 && l > uint64(o.maxMessage):
+		return n, limitError("message length", l, o.maxMessage)
+	case l > uint64(maxInt):
+		return n, limitError("message length", l, maxInt)
+	}
+
+	// Zero-copy values point into the message, which can not be pooled
+	w := new(appendWriter)
+	if !o.zeroCopy {
+		w = appenders.Get().(*appendWriter)
+		defer func() {
+			*w = (*w)[:0]
+			appenders.Put(w)
+		}()
+	}
+
+	*w, err = readFull(r, (*w)[:0], int(l))
+	n += int64(len(*w))
+	if err == nil {
+		err = Unmarshal(*w, dv.v, dv.opts...)
+	}
+	return n, err
+}
+
+// readFull appends exactly l bytes from the reader to the buffer. The buffer grows as
+// the bytes arrive rather than upfront, so that a forged length can not allocate more
+// memory than the bytes which were actually sent.
+func readFull(r io.Reader, dst []byte, l int) ([]byte, error) {
+	for end := len(dst) + l; len(dst) < end; {
+		if len(dst) == cap(dst) {
+			dst = append(dst, 0)[:len(dst)]
+		}
+
+		n, err := r.Read(dst[len(dst):min(cap(dst), end)])
+		dst = dst[:len(dst)+n]
+		switch {
+		case err == io.EOF && len(dst) < end:
+			return dst, io.ErrUnexpectedEOF
+		case err != nil && err != io.EOF:
+			return dst, err
+		}
+	}
+	return dst, nil
+}
+
+// byteReader reads single bytes from a reader, without reading any further.
+type byteReader struct {
+	r io.Reader
+	b [1]byte
+}
+
+// ReadByte reads a single byte. It implements io.ByteReader interface.
+func (r *byteReader) ReadByte() (byte, error) {
+	_, err := io.ReadFull(r.r, r.b[:])
+	return r.b[0], err
+}
@@ -0,0 +1,127 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package binary
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEncodedValue(t *testing.T) {
+	var buffer bytes.Buffer
+	n, err := NewEncodedValue(s0v).WriteTo(&buffer)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(len(s0b)+1), n)
+
+	// The frames can be read with DecodeMessage
+	buffer.Reset()
+	_, err = NewEncodedValue(s0v).WriteTo(&buffer)
+	assert.NoError(t, err)
+	_, err = NewEncodedValue("hello").WriteTo(&buffer)
+	assert.NoError(t, err)
+
+	d := NewDecoder(bytes.NewReader(buffer.Bytes()))
+	var s s0
+	var str string
+	assert.NoError(t, d.DecodeMessage(&s))
+	assert.NoError(t, d.DecodeMessage(&str))
+	assert.Equal(t, *s0v, s)
+	assert.Equal(t, "hello", str)
+
+	// Values which fail to encode are not written
+	buffer.Reset()
+	_, err = NewEncodedValue(make(chan int)).WriteTo(&buffer)
+	assert.Error(t, err)
+	assert.Zero(t, buffer.Len())
+}
+
+func TestDecodedValue(t *testing.T) {
+	var buffer bytes.Buffer
+	e := NewEncoder(&buffer)
+	assert.NoError(t, e.EncodeMessage(s0v))
+	assert.NoError(t, e.EncodeMessage("hello"))
+	buffer.WriteString("rest")
+
+	readers := []func() io.Reader{
+		func() io.Reader { return bytes.NewReader(buffer.Bytes()) },
+		func() io.Reader { return iotest.OneByteReader(bytes.NewReader(buffer.Bytes())) },
+	}
+
+	for _, reader := range readers {
+		r := reader()
+
+		var s s0
+		n, err := NewDecodedValue(&s).ReadFrom(r)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(len(s0b)+1), n)
+		assert.Equal(t, *s0v, s)
+
+		var str string
+		n, err = NewDecodedValue(&str, ZeroCopy()).ReadFrom(r)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(7), n)
+		assert.Equal(t, "hello", str)
+
+		// The bytes after the messages are left in the reader
+		rest, err := io.ReadAll(r)
+		assert.NoError(t, err)
+		assert.Equal(t, "rest", string(rest))
+
+		_, err = NewDecodedValue(&str).ReadFrom(r)
+		assert.Equal(t, io.EOF, err)
+	}
+}
+
+func TestDecodedValue_Pooled(t *testing.T) {
+	expect, err := Marshal(s0v, Versioned())
+	assert.NoError(t, err)
+
+	// The buffers of the messages are emptied before they are reused by the encoders
+	for i := 0; i < 10; i++ {
+		b, err := Marshal(s0v)
+		assert.NoError(t, err)
+
+		var s s0
+		_, err = NewDecodedValue(&s).ReadFrom(bytes.NewReader(append([]byte{byte(len(b))}, b...)))
+		assert.NoError(t, err)
+
+		out, err := Marshal(s0v, Versioned())
+		assert.NoError(t, err)
+		assert.Equal(t, expect, out)
+	}
+}
+
+func TestDecodedValueErrors(t *testing.T) {
+	var str string
+	_, err := NewDecodedValue(&str).ReadFrom(bytes.NewReader([]byte{5, 'a', 'b'}))
+	assert.Equal(t, io.ErrUnexpectedEOF, err)
+
+	_, err = NewDecodedValue(&str, MaxMessageSize(2)).ReadFrom(bytes.NewReader([]byte{5, 'a', 'b'}))
+	assert.True(t, errors.Is(err, ErrLimitExceeded))
+
+	// A forged length only allocates as much as the bytes which were sent
+	_, err = NewDecodedValue(&str).ReadFrom(bytes.NewReader([]byte{0xff, 0xff, 0xff, 0xff, 0x07, 'a'}))
+	assert.Equal(t, io.ErrUnexpectedEOF, err)
+}
+
+func TestValueOverConnection(t *testing.T) {
+	client, server := net.Pipe()
+	defer server.Close()
+
+	go func() {
+		NewEncodedValue(s0v).WriteTo(client)
+		client.Close()
+	}()
+
+	var s s0
+	_, err := NewDecodedValue(&s).ReadFrom(server)
+	assert.NoError(t, err)
+	assert.Equal(t, *s0v, s)
+}
@@ -0,0 +1,165 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package binary
+
+import (
+	"reflect"
+	"slices"
+	"sync"
+	"unsafe"
+)
+
+// The number of integers of a slice which are encoded at once, which matches the interval
+// at which the context is checked.
+const batchLen = cancelInterval
+
+// Reusable pool of buffers for the batches which can not be appended to the output in place.
+var batches = &sync.Pool{New: func() interface{} {
+	b := make([]byte, 0, batchLen*3)
+	return &b
+}}
+
+// writeBatch writes a batch of n bytes appended by the function, without going through
+// Write for every value. The bytes are appended in place when the encoder is buffered or
+// encodes into a byte slice, and into a pooled buffer otherwise.
+func (e *Encoder) writeBatch(n int, fn func([]byte) []byte) {
+	if e.err != nil {
+		return
+	}
+
+	if e.buffer != nil && len(e.buffer)+n > cap(e.buffer) {
+		if e.Flush(); e.err != nil {
+			return
+		}
+	}
+
+	switch w, ok := e.out.(*appendWriter); {
+	case e.buffer != nil && n <= cap(e.buffer):
+		e.buffer = e.appendBatch(e.buffer, fn)
+	case e.buffer == nil && ok:
+		*w = e.appendBatch(slices.Grow(*w, n), fn)
+	default:
+		b := batches.Get().(*[]byte)
+		*b = fn(slices.Grow((*b)[:0], n))
+		e.Write(*b)
+		batches.Put(b)
+	}
+}
+
+// appendBatch appends a batch of bytes to a slice, updating the checksum and the hash if
+// enabled.
+func (e *Encoder) appendBatch(b []byte, fn func([]byte) []byte) []byte {
+	start := len(b)
+	b = fn(b)
+	if e.ext != nil {
+		e.ext.digest(b[start:])
+	}
+	return b
+}
+
+// ------------------------------------------------------------------------------
+
+// writeUvarints writes the elements of a slice of unsigned integers, in batches.
+func writeUvarints[T uint | uint16 | uint32 | uint64](e *Encoder, v []T) error {
+	for i := 0; i < len(v); i += batchLen {
+		if err := e.opts().canceled(i); err != nil {
+			return err
+		}
+
+		batch := v[i:min(i+batchLen, len(v))]
+		e.writeBatch(uvarintsSize(batch), func(b []byte) []byte {
+			return appendUvarints(b, batch)
+		})
+	}
+	return nil
+}
+
+// writeVarints writes the elements of a slice of signed integers, in batches.
+func writeVarints[T int | int8 | int16 | int32 | int64](e *Encoder, v []T) error {
+	for i := 0; i < len(v); i += batchLen {
+		if err := e.opts().canceled(i); err != nil {
+			return err
+		}
+
+		batch := v[i:min(i+batchLen, len(v))]
+		e.writeBatch(varintsSize(batch), func(b []byte) []byte {
+			return appendVarints(b, batch)
+		})
+	}
+	return nil
+}
+
+// uvarintsSize returns the number of bytes the unsigned integers are encoded into.
+func uvarintsSize[T uint | uint16 | uint32 | uint64](v []T) (n int) {
+	for _, x := range v {
+		n += uvarintSize(uint64(x))
+	}
+	return
+}
+
+// varintsSize returns the number of bytes the signed integers are encoded into.
+func varintsSize[T int | int8 | int16 | int32 | int64](v []T) (n int) {
+	for _, x := range v {
+		n += uvarintSize(zigzag(int64(x)))
+	}
+	return
+}
+
+// appendUvarints appends unsigned integers as variable-size integers. Four integers are
+// handled per iteration, which are appended at once when they all fit in a single byte,
+// as is common for counters, identifiers and small measurements.
+func appendUvarints[T uint | uint16 | uint32 | uint64](b []byte, v []T) []byte {
+	for ; len(v) >= 4; v = v[4:] {
+		x0, x1, x2, x3 := uint64(v[0]), uint64(v[1]), uint64(v[2]), uint64(v[3])
+		if x0|x1|x2|x3 < 0x80 {
+			b = append(b, byte(x0), byte(x1), byte(x2), byte(x3))
+			continue
+		}
+
+		b = appendUvarint(appendUvarint(appendUvarint(appendUvarint(b, x0), x1), x2), x3)
+	}
+
+	for _, x := range v {
+		b = appendUvarint(b, uint64(x))
+	}
+	return b
+}
+
+// appendVarints appends signed integers as variable-size integers, with zigzag encoding.
+func appendVarints[T int | int8 | int16 | int32 | int64](b []byte, v []T) []byte {
+	for ; len(v) >= 4; v = v[4:] {
+		x0, x1, x2, x3 := zigzag(int64(v[0])), zigzag(int64(v[1])), zigzag(int64(v[2])), zigzag(int64(v[3]))
+		if x0|x1|x2|x3 < 0x80 {
+			b = append(b, byte(x0), byte(x1), byte(x2), byte(x3))
+			continue
+		}
+
+		b = appendUvarint(appendUvarint(appendUvarint(appendUvarint(b, x0), x1), x2), x3)
+	}
+
+	for _, x := range v {
+		b = appendUvarint(b, zigzag(int64(x)))
+	}
+	return b
+}
+
+// appendUvarint appends a variable-size unsigned integer.
+func appendUvarint(b []byte, x uint64) []byte {
+	for x >= 0x80 {
+		b = append(b, byte(x)|0x80)
+		x >>= 7
+	}
+	return append(b, byte(x))
+}
+
+// zigzag maps a signed integer to an unsigned one, so that small negative numbers are
+// encoded into few bytes as well.
+func zigzag(v int64) uint64 {
+	return uint64(v<<1) ^ uint64(v>>63)
+}
+
+// sliceOf returns the elements of a reflected slice without copying them.
+func sliceOf[T any](rv reflect.Value) []T {
+	return unsafe.Slice((*T)(rv.UnsafePointer()), rv.Len())
+}
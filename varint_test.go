@@ -0,0 +1,95 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package binary
+
+import (
+	"bytes"
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVarintBatch(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	unsigned := make([]uint64, 3*batchLen+3)
+	signed := make([]int32, len(unsigned))
+	for i := range unsigned {
+		switch i % 5 {
+		case 0, 1, 2: // Runs of single-byte values
+			unsigned[i], signed[i] = uint64(r.Intn(0x80)), int32(r.Intn(0x80)-0x40)
+		case 3:
+			unsigned[i], signed[i] = r.Uint64(), r.Int31()
+		default:
+			unsigned[i], signed[i] = math.MaxUint64, math.MinInt32
+		}
+	}
+
+	// The encoding must match the one of the element-wise primitives
+	var expect bytes.Buffer
+	e := NewEncoder(&expect)
+	e.WriteUvarint(uint64(len(unsigned)))
+	for _, v := range unsigned {
+		e.WriteUvarint(v)
+	}
+	e.WriteUvarint(uint64(len(signed)))
+	for _, v := range signed {
+		e.WriteVarint(int64(v))
+	}
+
+	type T struct {
+		Unsigned []uint64
+		Signed   []int32
+	}
+
+	v := T{Unsigned: unsigned, Signed: signed}
+	b, err := Marshal(&v)
+	assert.NoError(t, err)
+	assert.Equal(t, expect.Bytes(), b)
+
+	for _, size := range []int{0, 16, 1 << 16} {
+		var out bytes.Buffer
+		e := NewEncoderSize(&out, size)
+		if size == 0 {
+			e = NewEncoder(&out)
+		}
+
+		assert.NoError(t, e.Encode(&v))
+		assert.NoError(t, e.Flush())
+		assert.Equal(t, expect.Bytes(), out.Bytes(), "buffer of %d bytes", size)
+	}
+
+	// The checksum covers the batches
+	b, err = Marshal(&v, Checksum())
+	assert.NoError(t, err)
+
+	var decoded T
+	assert.NoError(t, Unmarshal(b, &decoded, Checksum()))
+	assert.Equal(t, v, decoded)
+}
+
+func TestVarintSize(t *testing.T) {
+	for _, v := range []uint64{0, 1, 0x7f, 0x80, 0x3fff, 0x4000, math.MaxUint32, math.MaxUint64} {
+		assert.Equal(t, len(appendUvarint(nil, v)), uvarintSize(v), "%d", v)
+	}
+
+	assert.Equal(t, uint64(0), zigzag(0))
+	assert.Equal(t, uint64(1), zigzag(-1))
+	assert.Equal(t, uint64(2), zigzag(1))
+	assert.Equal(t, uint64(math.MaxUint64), zigzag(math.MinInt64))
+}
+
+func BenchmarkVarintSlice(b *testing.B) {
+	v := make([]uint64, 4096)
+	for i := range v {
+		v[i] = uint64(i % 100)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		_, _ = Marshal(struct{ V []uint64 }{v})
+	}
+}
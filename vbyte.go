@@ -0,0 +1,300 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package binary
+
+import (
+	"encoding/binary"
+	"errors"
+	"reflect"
+)
+
+// scanVByte returns a codec for slices of integers in the Stream VByte format, selected
+// with a `binary:",vbyte"` tag. The widths of the integers are grouped in control bytes,
+// ahead of the integers themselves, so that four of them are decoded at once without a
+// branch per byte, which is substantially faster than variable-size integers for large
+// numeric columns.
+func scanVByte(t reflect.Type) (Codec, error) {
+	if t.Kind() == reflect.Slice {
+		switch t.Elem().Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			return &vbyteCodec{signed: true}, nil
+		case reflect.Uint, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			return &vbyteCodec{}, nil
+		}
+	}
+
+	return nil, errors.New("binary: vbyte encoding is not supported for " + t.String())
+}
+
+// The widths of the integers for each 2-bit code of the control bytes
+var vbyteWidths = [4]int{1, 2, 4, 8}
+
+// The number of bytes taken by four integers, for each control byte
+var vbyteSizes = func() (sizes [256]uint8) {
+	for c := range sizes {
+		for i := 0; i < 4; i++ {
+			sizes[c] += uint8(vbyteWidths[(c>>(2*i))&3])
+		}
+	}
+	return
+}()
+
+// The error returned for invalid Stream VByte integers
+var errInvalidVByte = errors.New("binary: invalid vbyte encoding")
+
+// ------------------------------------------------------------------------------
+
+// vbyteCodec represents a codec for slices of integers, which are prefixed with their
+// count, followed by a control byte for every four integers, giving their widths with 2
+// bits each from the lowest ones, and by the integers in little-endian byte order. The
+// widths are 1, 2, 4 or 8 bytes, regardless of the type. Signed integers are zigzag
+// encoded first, so that small negative numbers are small as well.
+type vbyteCodec struct {
+	signed bool // Whether the elements are signed integers
+}
+
+// Encode encodes a value into the encoder.
+func (c *vbyteCodec) EncodeTo(e *Encoder, rv reflect.Value) error {
+	l := rv.Len()
+	e.WriteUvarint(uint64(l))
+	if l == 0 {
+		return nil
+	}
+
+	switch rv.Type().Elem().Kind() {
+	case reflect.Int:
+		writeVByte(e, sliceOf[int](rv), zigzagOf)
+	case reflect.Int8:
+		writeVByte(e, sliceOf[int8](rv), zigzagOf)
+	case reflect.Int16:
+		writeVByte(e, sliceOf[int16](rv), zigzagOf)
+	case reflect.Int32:
+		writeVByte(e, sliceOf[int32](rv), zigzagOf)
+	case reflect.Int64:
+		writeVByte(e, sliceOf[int64](rv), zigzagOf)
+	case reflect.Uint:
+		writeVByte(e, sliceOf[uint](rv), uint64Of)
+	case reflect.Uint16:
+		writeVByte(e, sliceOf[uint16](rv), uint64Of)
+	case reflect.Uint32:
+		writeVByte(e, sliceOf[uint32](rv), uint64Of)
+	default:
+		writeVByte(e, sliceOf[uint64](rv), uint64Of)
+	}
+	return nil
+}
+
+// Decode decodes into a reflect value from the decoder.
+func (c *vbyteCodec) DecodeTo(d *Decoder, rv reflect.Value) (err error) {
+	var l int
+	var control, data []byte
+	if l, control, data, err = d.readVByte(); err != nil {
+		return
+	}
+
+	// The input holds at least a byte per integer, so the slice can be allocated at once
+	out := rv.Slice(0, 0)
+	if l > rv.Cap() {
+		if err = d.allocate(l, rv.Type().Elem().Size()); err != nil {
+			return
+		}
+		out = d.newSlice(rv.Type(), l)
+	}
+	out = out.Slice(0, l)
+
+	switch out.Type().Elem().Kind() {
+	case reflect.Int:
+		err = readVByte(sliceOf[int](out), control, data, unzigzagTo[int])
+	case reflect.Int8:
+		err = readVByte(sliceOf[int8](out), control, data, unzigzagTo[int8])
+	case reflect.Int16:
+		err = readVByte(sliceOf[int16](out), control, data, unzigzagTo[int16])
+	case reflect.Int32:
+		err = readVByte(sliceOf[int32](out), control, data, unzigzagTo[int32])
+	case reflect.Int64:
+		err = readVByte(sliceOf[int64](out), control, data, unzigzagTo[int64])
+	case reflect.Uint:
+		err = readVByte(sliceOf[uint](out), control, data, uintTo[uint])
+	case reflect.Uint16:
+		err = readVByte(sliceOf[uint16](out), control, data, uintTo[uint16])
+	case reflect.Uint32:
+		err = readVByte(sliceOf[uint32](out), control, data, uintTo[uint32])
+	default:
+		err = readVByte(sliceOf[uint64](out), control, data, uintTo[uint64])
+	}
+
+	if err == nil {
+		rv.Set(out)
+	}
+	return
+}
+
+// Size returns the encoded size of the value.
+func (c *vbyteCodec) Size(rv reflect.Value) int {
+	l := rv.Len()
+	size := uvarintSize(uint64(l)) + (l+3)/4
+	switch rv.Type().Elem().Kind() {
+	case reflect.Int:
+		return size + vbyteSize(sliceOf[int](rv), zigzagOf)
+	case reflect.Int8:
+		return size + vbyteSize(sliceOf[int8](rv), zigzagOf)
+	case reflect.Int16:
+		return size + vbyteSize(sliceOf[int16](rv), zigzagOf)
+	case reflect.Int32:
+		return size + vbyteSize(sliceOf[int32](rv), zigzagOf)
+	case reflect.Int64:
+		return size + vbyteSize(sliceOf[int64](rv), zigzagOf)
+	case reflect.Uint:
+		return size + vbyteSize(sliceOf[uint](rv), uint64Of)
+	case reflect.Uint16:
+		return size + vbyteSize(sliceOf[uint16](rv), uint64Of)
+	case reflect.Uint32:
+		return size + vbyteSize(sliceOf[uint32](rv), uint64Of)
+	default:
+		return size + vbyteSize(sliceOf[uint64](rv), uint64Of)
+	}
+}
+
+// ------------------------------------------------------------------------------
+
+// The integer types of the slices encoded in the Stream VByte format
+type vbyteInt interface {
+	int | int8 | int16 | int32 | int64 | uint | uint16 | uint32 | uint64
+}
+
+// zigzagOf returns a signed integer mapped to an unsigned one.
+func zigzagOf[T vbyteInt](v T) uint64 { return zigzag(int64(v)) }
+
+// uint64Of returns an unsigned integer as a uint64.
+func uint64Of[T vbyteInt](v T) uint64 { return uint64(v) }
+
+// unzigzagTo returns the signed integer of the type mapped to an unsigned one, which must
+// fit into the type.
+func unzigzagTo[T int | int8 | int16 | int32 | int64](x uint64) (T, error) {
+	v := int64(x>>1) ^ -int64(x&1)
+	if int64(T(v)) != v {
+		return 0, IntOverflowError(v, reflect.TypeFor[T]().String())
+	}
+	return T(v), nil
+}
+
+// uintTo returns the unsigned integer of the type, which must fit into the type.
+func uintTo[T uint | uint16 | uint32 | uint64](x uint64) (T, error) {
+	if uint64(T(x)) != x {
+		return 0, UintOverflowError(x, reflect.TypeFor[T]().String())
+	}
+	return T(x), nil
+}
+
+// vbyteCode returns the 2-bit code of the width of an integer.
+func vbyteCode(x uint64) int {
+	switch {
+	case x < 1<<8:
+		return 0
+	case x < 1<<16:
+		return 1
+	case x < 1<<32:
+		return 2
+	default:
+		return 3
+	}
+}
+
+// vbyteSize returns the number of bytes the integers take, without their control bytes.
+func vbyteSize[T vbyteInt](v []T, conv func(T) uint64) (n int) {
+	for _, x := range v {
+		n += vbyteWidths[vbyteCode(conv(x))]
+	}
+	return
+}
+
+// writeVByte writes the control bytes and the integers of a non-empty slice.
+func writeVByte[T vbyteInt](e *Encoder, v []T, conv func(T) uint64) {
+	n := (len(v)+3)/4 + vbyteSize(v, conv)
+	e.writeBatch(n, func(b []byte) []byte {
+		return appendVByte(b, v, conv)
+	})
+}
+
+// appendVByte appends the control bytes of the integers, followed by the integers.
+func appendVByte[T vbyteInt](b []byte, v []T, conv func(T) uint64) []byte {
+	control := len(b)
+	b = append(b, make([]byte, (len(v)+3)/4)...)
+	for i, x := range v {
+		u := conv(x)
+		code := vbyteCode(u)
+		b[control+i/4] |= byte(code << (2 * (i % 4)))
+		switch code {
+		case 0:
+			b = append(b, byte(u))
+		case 1:
+			b = binary.LittleEndian.AppendUint16(b, uint16(u))
+		case 2:
+			b = binary.LittleEndian.AppendUint32(b, uint32(u))
+		default:
+			b = binary.LittleEndian.AppendUint64(b, u)
+		}
+	}
+	return b
+}
+
+// readVByte decodes the integers of a slice from their control bytes and their data,
+// four at a time for every full control byte.
+func readVByte[T vbyteInt](out []T, control, data []byte, conv func(uint64) (T, error)) error {
+	i := 0
+	for _, c := range control {
+		n := min(len(out)-i, 4)
+		for j := 0; j < n; j++ {
+			width := vbyteWidths[(c>>(2*j))&3]
+			var x uint64
+			switch width {
+			case 1:
+				x = uint64(data[0])
+			case 2:
+				x = uint64(binary.LittleEndian.Uint16(data))
+			case 4:
+				x = uint64(binary.LittleEndian.Uint32(data))
+			default:
+				x = binary.LittleEndian.Uint64(data)
+			}
+
+			v, err := conv(x)
+			if err != nil {
+				return err
+			}
+
+			out[i+j] = v
+			data = data[width:]
+		}
+		i += n
+	}
+	return nil
+}
+
+// readVByte reads the number of integers of a slice, their control bytes and their data,
+// whose size is given by the control bytes. The unused codes of the last control byte
+// must be zero.
+func (d *Decoder) readVByte() (l int, control, data []byte, err error) {
+	if l, err = d.readSliceLen(); err != nil || l == 0 {
+		return
+	}
+	if control, err = d.Slice((l + 3) / 4); err != nil {
+		return
+	}
+
+	n := 0
+	for _, c := range control[:len(control)-1] {
+		n += int(vbyteSizes[c])
+	}
+
+	// The unused codes of the last control byte are zero, which count as a byte each
+	last := control[len(control)-1]
+	if used := 2 * (l - 4*(len(control)-1)); used < 8 && last>>used != 0 {
+		return 0, nil, nil, errInvalidVByte
+	}
+	n += int(vbyteSizes[last]) - (4 - (l - 4*(len(control)-1)))
+
+	data, err = d.Slice(n)
+	return
+}
@@ -0,0 +1,155 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package binary
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type vbyteColumn struct {
+	IDs    []uint32 `binary:",vbyte"`
+	Deltas []int64  `binary:",vbyte"`
+	Small  []int8   `binary:",vbyte"`
+}
+
+func TestVByte(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	ids := make([]uint32, 1001)
+	deltas := make([]int64, 1002)
+	for i := range ids {
+		ids[i] = rng.Uint32() >> uint(rng.Intn(32))
+	}
+	for i := range deltas {
+		deltas[i] = rng.Int63() >> uint(rng.Intn(63))
+		if i%2 == 0 {
+			deltas[i] = -deltas[i]
+		}
+	}
+
+	for _, v := range []*vbyteColumn{
+		{},
+		{IDs: []uint32{1}, Deltas: []int64{-1}, Small: []int8{math.MinInt8, math.MaxInt8}},
+		{IDs: []uint32{0, 1 << 8, 1 << 16, math.MaxUint32, 7}, Deltas: []int64{math.MinInt64, math.MaxInt64}},
+		{IDs: ids, Deltas: deltas},
+	} {
+		b, err := Marshal(v)
+		assert.NoError(t, err)
+
+		size, err := Size(v)
+		assert.NoError(t, err)
+		assert.Equal(t, len(b), size)
+
+		var out vbyteColumn
+		assert.NoError(t, Unmarshal(b, &out))
+		assert.Equal(t, len(v.IDs), len(out.IDs))
+		for i := range v.IDs {
+			assert.Equal(t, v.IDs[i], out.IDs[i])
+		}
+		assert.Equal(t, len(v.Deltas), len(out.Deltas))
+		for i := range v.Deltas {
+			assert.Equal(t, v.Deltas[i], out.Deltas[i])
+		}
+		assert.Equal(t, len(v.Small), len(out.Small))
+	}
+}
+
+func TestVByte_Format(t *testing.T) {
+	b, err := Marshal(&struct {
+		V []uint64 `binary:",vbyte"`
+	}{V: []uint64{1, 300, 70000, 1 << 40, 2}})
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{
+		5,                // The count
+		0b11_10_01_00, 0, // The control bytes, from the lowest bits
+		1,
+		0x2c, 0x01,
+		0x70, 0x11, 0x01, 0x00,
+		0, 0, 0, 0, 0, 1, 0, 0,
+		2,
+	}, b)
+}
+
+func TestVByte_Invalid(t *testing.T) {
+	b, err := Marshal(&vbyteColumn{IDs: []uint32{1, 2, 300}})
+	assert.NoError(t, err)
+
+	var out vbyteColumn
+	assert.Error(t, Unmarshal(b[:5], &out))
+	assert.Error(t, Unmarshal([]byte{200, 1, 0}, &out))
+
+	// The unused codes of the last control byte must be zero
+	assert.True(t, errors.Is(Unmarshal([]byte{1, 0b100, 1, 0, 0}, &out), errInvalidVByte))
+
+	// The integers must fit into the type
+	b, err = Marshal(&struct {
+		V []uint64 `binary:",vbyte"`
+	}{V: []uint64{math.MaxUint32 + 1}})
+	assert.NoError(t, err)
+	assert.Error(t, Unmarshal(b, &out))
+
+	_, err = Marshal(&struct {
+		V []byte `binary:",vbyte"`
+	}{})
+	assert.Error(t, err)
+}
+
+func TestVByte_Schema(t *testing.T) {
+	v := &vbyteColumn{IDs: []uint32{1, 2}, Deltas: []int64{-3}}
+	b, err := Marshal(v, SelfDescribing())
+	assert.NoError(t, err)
+
+	out, err := DecodeAny(b, SelfDescribing())
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"IDs":    []interface{}{uint64(1), uint64(2)},
+		"Deltas": []interface{}{int64(-3)},
+		"Small":  nil,
+	}, out)
+
+	var kinds []Kind
+	assert.NoError(t, Walk(b, nil, func(t Token) error {
+		kinds = append(kinds, t.Schema.Kind)
+		return nil
+	}, SelfDescribing()))
+	assert.Equal(t, []Kind{KindVByte, KindVByte, KindVByte, KindStruct}, kinds)
+
+	var skipped vbyteColumn
+	assert.NoError(t, Unmarshal(b, &skipped, SelfDescribing(), Fields("Deltas")))
+	assert.Equal(t, []int64{-3}, skipped.Deltas)
+}
+
+func BenchmarkVByte(b *testing.B) {
+	type plain struct{ V []uint32 }
+	type packed struct {
+		V []uint32 `binary:",vbyte"`
+	}
+
+	v := make([]uint32, 4096)
+	for i := range v {
+		v[i] = uint32(i * 1000)
+	}
+
+	encoded, _ := Marshal(&plain{V: v})
+	b.Run("varint", func(b *testing.B) {
+		b.ReportAllocs()
+		var out plain
+		for n := 0; n < b.N; n++ {
+			_ = Unmarshal(encoded, &out)
+		}
+	})
+
+	compressed, _ := Marshal(&packed{V: v})
+	b.Run("vbyte", func(b *testing.B) {
+		b.ReportAllocs()
+		var out packed
+		for n := 0; n < b.N; n++ {
+			_ = Unmarshal(compressed, &out)
+		}
+	})
+}
@@ -0,0 +1,94 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package binary
+
+import (
+	"io"
+	"net"
+)
+
+// The minimum size of the byte slices and strings referenced by a vectored encoder, when
+// no threshold is specified
+const defaultVectorThreshold = 1024
+
+// NewVectoredEncoder creates a buffered encoder which does not copy large byte slices and
+// strings into its buffer, but references them as segments between the buffered bytes.
+// Flush writes all of the segments at once with net.Buffers, which issues a single writev
+// system call when the writer is a network connection. This avoids copying payloads, such
+// as the body of a request after its header. Byte slices of at least the threshold, or of
+// 1KB if it is not positive, must not be modified until the encoder is flushed.
+func NewVectoredEncoder(out io.Writer, threshold int, opts ...Option) *Encoder {
+	if threshold <= 0 {
+		threshold = defaultVectorThreshold
+	}
+
+	e := NewEncoderSize(out, defaultBufferSize, opts...)
+	e.extend().vector = &vector{threshold: threshold}
+	return e
+}
+
+// vectored returns whether the encoder is a vectored encoder.
+func (e *Encoder) vectored() bool {
+	return e.ext != nil && e.ext.vector != nil
+}
+
+// vector represents the segments collected by a vectored encoder.
+type vector struct {
+	segments  net.Buffers // The segments to write, except for the last buffered bytes
+	mark      int         // The offset of the buffered bytes which are not in a segment yet
+	threshold int         // The minimum size of the byte slices which are referenced
+}
+
+// reset discards the segments.
+func (v *vector) reset() {
+	clear(v.segments)
+	v.segments = v.segments[:0]
+	v.mark = 0
+}
+
+// writeSegment writes bytes which are owned by the caller, which a vectored encoder
+// references instead of copying them when they are large enough.
+func (e *Encoder) writeSegment(p []byte) {
+	if !e.vectored() || len(p) < e.ext.vector.threshold || e.err != nil {
+		e.Write(p)
+		return
+	}
+
+	v := e.ext.vector
+	e.ext.digest(p)
+
+	// The buffered bytes so far become a segment, which later writes do not overwrite
+	if n := len(e.buffer); n > v.mark {
+		v.segments = append(v.segments, e.buffer[v.mark:n:n])
+		v.mark = n
+	}
+	v.segments = append(v.segments, p)
+}
+
+// writeVector writes the segments and the remaining buffered bytes to a writer.
+func (e *Encoder) writeVector(w io.Writer) (n int64, err error) {
+	v := e.ext.vector
+	if len(e.buffer) > v.mark {
+		v.segments = append(v.segments, e.buffer[v.mark:])
+	}
+
+	// Writing consumes the slice of segments, so a copy of its header is written
+	segments := v.segments
+	if t, ok := w.(*teeWriter); ok {
+		n, err = t.writeBuffers(segments)
+	} else {
+		n, err = segments.WriteTo(w)
+	}
+	v.reset()
+	e.buffer = e.buffer[:0]
+	return
+}
+
+// size returns the number of bytes of the segments.
+func (v *vector) size() (n int) {
+	for _, s := range v.segments {
+		n += len(s)
+	}
+	return
+}
@@ -0,0 +1,126 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package binary
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type rpcRequest struct {
+	Method string
+	ID     uint64
+	Body   []byte
+}
+
+// recorder records the individual writes it receives.
+type recorder struct {
+	bytes.Buffer
+	writes int
+}
+
+func (r *recorder) Write(p []byte) (int, error) {
+	r.writes++
+	return r.Buffer.Write(p)
+}
+
+func TestVectoredEncoder(t *testing.T) {
+	body := bytes.Repeat([]byte{7}, 4096)
+	v := rpcRequest{Method: "Get", ID: 42, Body: body}
+
+	expect, err := Marshal(&v)
+	assert.NoError(t, err)
+
+	var out recorder
+	e := NewVectoredEncoder(&out, 0)
+	assert.NoError(t, e.Encode(&v))
+	assert.Equal(t, len(expect), e.Buffered())
+	assert.Equal(t, 0, out.Len())
+
+	// The body is referenced rather than copied, so changes show until it is flushed
+	body[0] = 8
+	assert.NoError(t, e.Flush())
+	assert.Equal(t, 0, e.Buffered())
+	assert.Equal(t, 2, out.writes) // The header, then the body
+	assert.Equal(t, len(expect), out.Len())
+	assert.Equal(t, byte(8), out.Bytes()[len(expect)-len(body)])
+
+	var decoded rpcRequest
+	assert.NoError(t, Unmarshal(out.Bytes(), &decoded))
+	assert.Equal(t, v, decoded)
+}
+
+func TestVectoredEncoder_Multiple(t *testing.T) {
+	values := []rpcRequest{
+		{Method: "Small", ID: 1, Body: []byte{1, 2, 3}},
+		{Method: "Large", ID: 2, Body: bytes.Repeat([]byte{1}, 100)},
+		{Method: "Large", ID: 3, Body: bytes.Repeat([]byte{2}, 100)},
+	}
+
+	var expect bytes.Buffer
+	plain := NewEncoder(&expect, Checksum())
+	for i := range values {
+		assert.NoError(t, plain.Encode(&values[i]))
+	}
+
+	var out bytes.Buffer
+	e := NewVectoredEncoder(&out, 64, Checksum())
+	for i := range values {
+		assert.NoError(t, e.Encode(&values[i]))
+	}
+
+	var redirected bytes.Buffer
+	n, err := e.WriteTo(&redirected)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(expect.Len()), n)
+	assert.Equal(t, expect.Bytes(), redirected.Bytes())
+	assert.Equal(t, 0, out.Len())
+
+	// Reset discards the collected segments
+	assert.NoError(t, e.Encode(&values[1]))
+	e.Reset(&out)
+	assert.Equal(t, 0, e.Buffered())
+	assert.NoError(t, e.Encode(&values[0]))
+	assert.NoError(t, e.Flush())
+
+	var decoded rpcRequest
+	assert.NoError(t, Unmarshal(out.Bytes(), &decoded, Checksum()))
+	assert.Equal(t, values[0], decoded)
+}
+
+func TestVectoredEncoder_Conn(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip(err)
+	}
+	defer ln.Close()
+
+	v := rpcRequest{Method: "Put", ID: 1, Body: bytes.Repeat([]byte{9}, 64<<10)}
+	go func() {
+		conn, err := net.Dial("tcp", ln.Addr().String())
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		e := NewVectoredEncoder(conn, 0)
+		_ = e.Encode(&v)
+		_ = e.Flush()
+	}()
+
+	conn, err := ln.Accept()
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	b, err := io.ReadAll(conn)
+	assert.NoError(t, err)
+
+	var decoded rpcRequest
+	assert.NoError(t, Unmarshal(b, &decoded))
+	assert.Equal(t, v, decoded)
+}
@@ -0,0 +1,48 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package binary
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+)
+
+// Verify checks that a value survives a round-trip through the encoder and the decoder,
+// which is useful to catch asymmetries in custom codecs. The value is encoded, decoded
+// into a new value of the same type, which is then encoded again and must produce the
+// same bytes. The size computed by Size must also match the number of encoded bytes.
+// Maps are sorted for the comparison, as their iteration order is random.
+func Verify(v interface{}, opts ...Option) error {
+	opts = append(opts[:len(opts):len(opts)], Deterministic())
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	encoded, err := marshal(rv, opts)
+	if err != nil {
+		return err
+	}
+
+	size, err := Size(v, opts...)
+	switch {
+	case err != nil:
+		return err
+	case size != len(encoded):
+		return fmt.Errorf("binary: size of %s is %d, but it is encoded into %d bytes", rv.Type(), size, len(encoded))
+	}
+
+	decoded := reflect.New(rv.Type()).Elem()
+	if err := unmarshal(encoded, decoded, opts); err != nil {
+		return fmt.Errorf("binary: unable to decode %s: %w", rv.Type(), err)
+	}
+
+	reencoded, err := marshal(decoded, opts)
+	if err != nil {
+		return fmt.Errorf("binary: unable to encode decoded %s: %w", rv.Type(), err)
+	}
+
+	if !bytes.Equal(encoded, reencoded) {
+		return fmt.Errorf("binary: round-trip of %s is not symmetric, encoded %x but decoded value encodes to %x",
+			rv.Type(), encoded, reencoded)
+	}
+	return nil
+}
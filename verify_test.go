@@ -0,0 +1,105 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package binary
+
+import (
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// lossyCodec encodes integers but always decodes them as zero
+type lossyCodec struct{}
+
+func (c *lossyCodec) EncodeTo(e *Encoder, rv reflect.Value) error {
+	e.WriteVarint(rv.Int())
+	return nil
+}
+
+func (c *lossyCodec) DecodeTo(d *Decoder, rv reflect.Value) error {
+	_, err := d.ReadVarint()
+	return err
+}
+
+type lossy int64
+
+func (v *lossy) GetBinaryCodec() Codec {
+	return new(lossyCodec)
+}
+
+type verifyStruct struct {
+	Int     int
+	Float32 float32
+	Float64 float64
+	Complex complex64
+	String  string
+	Map     map[string]int
+	Slice   []uint16
+	Time    time.Time
+	Next    *verifyStruct
+}
+
+func TestVerify(t *testing.T) {
+	assert.NoError(t, Verify(s0v))
+	assert.NoError(t, Verify(newBenchStruct()))
+	assert.NoError(t, Verify(&verifyStruct{
+		Int:     -1,
+		Float32: 1.5,
+		Float64: math.NaN(),
+		Complex: complex(1, 2),
+		String:  "hello",
+		Map:     map[string]int{"a": 1, "b": 2, "c": 3},
+		Slice:   []uint16{},
+		Time:    time.Now(),
+		Next:    &verifyStruct{Int: 1},
+	}))
+}
+
+func TestVerifyAsymmetric(t *testing.T) {
+	v := struct{ A lossy }{A: 42}
+	assert.Error(t, Verify(&v))
+	assert.NoError(t, Verify(&struct{ A lossy }{}))
+}
+
+func TestVerifyUnsupported(t *testing.T) {
+	assert.Error(t, Verify(make(chan int)))
+}
+
+func FuzzVerify(f *testing.F) {
+	f.Add(int64(0), float32(0), 0.0, "", []byte(nil), false)
+	f.Add(int64(-1), float32(1.5), math.Inf(1), "hello", []byte{1, 2, 3}, true)
+	f.Add(int64(math.MaxInt64), float32(math.NaN()), -0.0, "\xff", []byte{}, true)
+	f.Fuzz(func(t *testing.T, i int64, f32 float32, f64 float64, s string, b []byte, ok bool) {
+		v := struct {
+			I   int64
+			F32 float32
+			F64 float64
+			S   string
+			B   []byte
+			OK  bool
+			M   map[string]float32
+			P   *int64
+		}{i, f32, f64, s, b, ok, map[string]float32{s: f32}, &i}
+
+		if err := Verify(&v); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func FuzzUnmarshal(f *testing.F) {
+	f.Add(s0b)
+	f.Add([]byte{0x1, 0xff, 0xff, 0xff, 0xff, 0x1})
+	f.Fuzz(func(t *testing.T, b []byte) {
+		var v verifyStruct
+		if err := Unmarshal(b, &v, MaxSliceLen(1024), MaxStringLen(1024), MaxDepth(16)); err == nil {
+			if _, err := Marshal(&v); err != nil {
+				t.Fatal(err)
+			}
+		}
+	})
+}
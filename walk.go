@@ -0,0 +1,403 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package binary
+
+import (
+	"errors"
+	"reflect"
+	"strconv"
+)
+
+// Token represents an encoded value, found while walking over a payload.
+type Token struct {
+	Path   string  // The path of the value, such as "Items[2].Price"
+	Schema *Schema // The schema of the value
+	Offset int     // The offset of the value within the payload
+	Raw    []byte  // The encoded bytes of the value
+	d      *Decoder
+}
+
+// Value decodes the value of the token into generic values, in the same way as DecodeAny.
+// It must be called by the WalkFunc, as the token refers to the state of the walk.
+func (t Token) Value() (interface{}, error) {
+	if t.d == nil {
+		return nil, errors.New("binary: the token does not belong to a walk")
+	}
+
+	// Interned strings may refer to the ones read before the value
+	d := t.d.nested(t.Raw)
+	d.interned = t.d.interned[:len(t.d.interned):len(t.d.interned)]
+	v, err := d.readAny(t.Schema)
+	d.interned = nil
+	d.release()
+	return v, err
+}
+
+// WalkFunc is called for every value found while walking over a payload. The values
+// within arrays, slices, maps, structs and pointers are visited before the value which
+// contains them. Returning an error stops the walk.
+type WalkFunc func(Token) error
+
+// Walk traverses an encoded payload according to its schema, without materializing any
+// Go value, and calls the function for every value it contains. This is useful to
+// validate, inspect or proxy payloads. The options must match the ones used to encode
+// the payload, and the limits of the decoder apply. With the SelfDescribing option, the
+// header of the payload is read first and the schema may be nil, in which case the one of
+// the header is used.
+func Walk(b []byte, s *Schema, fn WalkFunc, opts ...Option) (err error) {
+	d := decoders.Get().(*Decoder)
+	d.r.(*SliceReader).Reset(b)
+	d.opts.reset(opts)
+	d.depth = 0
+
+	var header *Schema
+	if d.opts.selfDescribing {
+		header, err = d.readHeader(s == nil)
+	}
+
+	if s == nil {
+		s = header
+	}
+
+	switch {
+	case err != nil:
+	case s == nil:
+		err = errors.New("binary: unable to walk without a schema")
+	case d.opts.compressor != nil:
+		err = errors.New("binary: unable to walk over compressed values")
+	default:
+		w := walker{d: d, b: b, fn: fn}
+		err = w.walk(s, "")
+	}
+
+	d.clearTables()
+	decoders.Put(d)
+	return
+}
+
+// Skip skips over an encoded value according to its schema, without decoding it. This
+// is useful to move past values of a stream which are not needed.
+func (d *Decoder) Skip(s *Schema) error {
+	w := walker{d: d}
+	d.nesting++
+	err := w.walk(s, "")
+	if d.nesting--; d.nesting == 0 {
+		d.clearTables()
+	}
+	return err
+}
+
+// ------------------------------------------------------------------------------
+
+// walker traverses encoded values according to their schema.
+type walker struct {
+	d    *Decoder // The decoder to read from
+	b    []byte   // The entire payload, nil when only skipping over values
+	base int      // The offset of the input of the decoder within the payload
+	fn   WalkFunc // The function to call for every value, if any
+}
+
+// offset returns the current offset within the payload.
+func (w *walker) offset() int {
+	if w.fn == nil {
+		return 0
+	}
+	return w.base + int(w.d.s.i)
+}
+
+// path returns the path of a nested value, if the paths are needed.
+func (w *walker) path(parent, child string) string {
+	if w.fn == nil {
+		return ""
+	}
+	return parent + child
+}
+
+// walk traverses a value and calls the function with it.
+func (w *walker) walk(s *Schema, path string) error {
+	start := w.offset()
+	if err := w.value(s, path); err != nil {
+		return err
+	}
+
+	if w.fn != nil {
+		end := w.offset()
+		return w.fn(Token{Path: path, Schema: s, Offset: start, Raw: w.b[start:end:end], d: w.d})
+	}
+	return nil
+}
+
+// value traverses the contents of a value.
+func (w *walker) value(s *Schema, path string) (err error) {
+	d := w.d
+	switch s.Kind {
+	case KindBool:
+		return d.skipBytes(1)
+	case KindVarint:
+		_, err = d.ReadVarint()
+	case KindUvarint, KindRawVarint:
+		_, err = d.ReadUvarint()
+	case KindInt, KindUint, KindFloat, KindComplex:
+		return d.skipBytes(s.Size)
+	case KindString:
+		return w.string(s)
+	case KindBytes, KindTime, KindBigFloat:
+		return d.skipLength(1)
+	case KindUnixNano:
+		_, err = d.ReadVarint()
+	case KindBigInt:
+		return d.skipBigInt()
+	case KindBigRat:
+		if err = d.skipBigInt(); err == nil {
+			err = d.skipBigInt()
+		}
+
+	case KindDelta:
+		var l int
+		if l, err = d.readSliceLen(); err == nil {
+			err = d.readDelta(l, false, func(int, uint64) error { return nil })
+		}
+	case KindVByte:
+		_, _, _, err = d.readVByte()
+	case KindRLE:
+		return w.runs(s, path)
+	case KindGorilla:
+		_, _, err = d.readGorillaBits()
+	case KindSQL:
+		_, err = d.readSQLValue()
+	case KindBits:
+		var l int
+		if l, err = d.readSliceLen(); err == nil {
+			err = d.skipBytes(bitsSize(l))
+		}
+	case KindArray, KindSlice, KindMap:
+		return w.elements(s, path)
+	case KindStruct:
+		return w.fields(s, path)
+	case KindColumnar:
+		return w.columns(s, path)
+	case KindPointer:
+		return w.pointer(s, path)
+	case KindInterface:
+		return w.iface(path)
+	default:
+		return errOpaque(s)
+	}
+	return
+}
+
+// string traverses a string, which may be interned or have a fixed-width length.
+func (w *walker) string(s *Schema) (err error) {
+	switch {
+	case w.d.opts.intern:
+		_, err = w.d.readInterned()
+		return
+	case s.Size == 2:
+		var l uint16
+		if l, err = w.d.ReadUint16(); err == nil {
+			err = w.d.skipBytes(int(l))
+		}
+		return
+	default:
+		var l int
+		if l, err = w.d.readStringLen(); err == nil {
+			err = w.d.skipBytes(l)
+		}
+		return
+	}
+}
+
+// elements traverses the elements of an array, a slice or a map.
+func (w *walker) elements(s *Schema, path string) (err error) {
+	if err = w.d.enter(); err != nil {
+		return
+	}
+	defer w.d.leave()
+
+	l := s.Len
+	switch max := w.d.opts.maxSliceLen; {
+	case s.Kind != KindArray:
+		if l, err = w.d.readSliceLen(); err != nil {
+			return
+		}
+	case max > 0 && l > max:
+		return limitError("array length", uint64(l), max)
+	}
+
+	for i := 0; i < l; i++ {
+		index := w.path(path, "["+strconv.Itoa(i)+"]")
+		if s.Kind != KindMap {
+			if err = w.walk(s.Elem, index); err != nil {
+				return
+			}
+			continue
+		}
+
+		if err = w.walk(s.Key, w.path(index, ".key")); err != nil {
+			return
+		}
+		if err = w.walk(s.Elem, w.path(index, ".value")); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// runs traverses the runs of a run-length encoded slice, with the element of every run
+// at the index of its first occurrence.
+func (w *walker) runs(s *Schema, path string) (err error) {
+	if err = w.d.enter(); err != nil {
+		return
+	}
+	defer w.d.leave()
+
+	var l, n int
+	if l, err = w.d.readSliceLen(); err != nil {
+		return
+	}
+
+	for i := 0; i < l; i += n {
+		if n, err = w.d.readRun(l - i); err != nil {
+			return
+		}
+		if err = w.walk(s.Elem, w.path(path, "["+strconv.Itoa(i)+"]")); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// fields traverses the fields of a struct.
+func (w *walker) fields(s *Schema, path string) (err error) {
+	if err = w.d.enter(); err != nil {
+		return
+	}
+	defer w.d.leave()
+
+	if w.d.opts.versioned {
+		return w.versioned(s, path)
+	}
+
+	var buffer [8]byte
+	var bits []byte
+	if bits, err = w.d.readPresence(omittedFields(s), buffer[:]); err != nil {
+		return
+	}
+
+	n := 0
+	for _, f := range s.Fields {
+		if f.OmitEmpty {
+			if n++; !present(bits, n-1) {
+				continue
+			}
+		}
+
+		if err = w.walk(f.Schema, w.path(path, "."+f.Name)); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// columns traverses the columns of a slice of structs, which are missing if it is empty.
+func (w *walker) columns(s *Schema, path string) (err error) {
+	if err = w.d.enter(); err != nil {
+		return
+	}
+	defer w.d.leave()
+
+	var l int
+	if l, err = w.d.readSliceLen(); err != nil || l == 0 {
+		return
+	}
+
+	for _, f := range s.Fields {
+		if err = w.walk(f.Schema, w.path(path, "[]."+f.Name)); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// versioned traverses the fields of a struct which are framed with their identifier
+// and length. Fields with an unknown identifier are skipped.
+func (w *walker) versioned(s *Schema, path string) (err error) {
+	for {
+		var id uint64
+		var l int
+		if id, err = w.d.ReadUvarint(); err != nil || id == 0 {
+			return
+		}
+		if l, err = w.d.readSliceLen(); err != nil {
+			return
+		}
+
+		start := w.offset()
+		var frame []byte
+		if frame, err = w.d.Slice(l); err != nil {
+			return
+		}
+
+		for _, f := range s.Fields {
+			if f.ID != int(id) {
+				continue
+			}
+
+			// Each field is encoded by a nested encoder, like with the decoder
+			nested := walker{d: w.d.nested(frame), b: w.b, base: start, fn: w.fn}
+			err = nested.walk(f.Schema, w.path(path, "."+f.Name))
+			nested.d.release()
+			if err != nil {
+				return
+			}
+			break
+		}
+	}
+}
+
+// pointer traverses a pointer, which is followed by its value if it is not nil.
+func (w *walker) pointer(s *Schema, path string) (err error) {
+	var present byte
+	if present, err = w.d.r.ReadByte(); err != nil {
+		return
+	}
+
+	switch present {
+	case 0:
+		return nil
+	case 1:
+		return w.walk(s.Elem, path)
+	default:
+		return errors.New("binary: invalid presence byte for " + s.Name)
+	}
+}
+
+// iface traverses the value of an interface, whose concrete type must be registered.
+func (w *walker) iface(path string) (err error) {
+	if err = w.d.enter(); err != nil {
+		return
+	}
+	defer w.d.leave()
+
+	var l int
+	var name []byte
+	if l, err = w.d.readStringLen(); err != nil || l == 0 {
+		return
+	}
+
+	if name, err = w.d.Slice(l); err != nil {
+		return
+	}
+
+	t, ok := registeredTypes.Load(string(name))
+	if !ok {
+		return errors.New("binary: name '" + string(name) + "' is not registered")
+	}
+
+	var s *Schema
+	if s, err = SchemaOf(t.(reflect.Type), Unexported(w.d.opts.unexported)); err == nil {
+		err = w.walk(s, path)
+	}
+	return
+}
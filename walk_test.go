@@ -0,0 +1,183 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package binary
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type walked struct {
+	projected
+	Shape    shape
+	Fraction *big.Rat
+	Ints     map[int32]string
+	hidden   string
+}
+
+func newWalked() *walked {
+	return &walked{
+		projected: *newProjected(),
+		Shape:     square{2},
+		Fraction:  big.NewRat(1, 3),
+		Ints:      map[int32]string{1: "one"},
+	}
+}
+
+func TestSchemaOf(t *testing.T) {
+	s, err := SchemaOf(reflect.TypeOf(walked{}))
+	assert.NoError(t, err)
+	assert.Equal(t, KindStruct, s.Kind)
+	assert.Len(t, s.Fields, 19)
+
+	kinds := make(map[string]*Schema)
+	for _, f := range s.Fields {
+		kinds[f.Name] = f.Schema
+	}
+
+	assert.Equal(t, KindStruct, kinds["Header"].Kind)
+	assert.Equal(t, KindSlice, kinds["Points"].Kind)
+	assert.Equal(t, KindFloat, kinds["Points"].Elem.Fields[0].Schema.Kind)
+	assert.Equal(t, &Schema{Kind: KindUint, Name: "uint64", Size: 8}, kinds["Hashes"].Elem)
+	assert.Equal(t, KindArray, kinds["ID"].Kind)
+	assert.Equal(t, 4, kinds["ID"].Len)
+	assert.Equal(t, &Schema{Kind: KindString, Name: "string", Size: 2}, kinds["Meta"].Key)
+	assert.Equal(t, &Schema{Kind: KindInt, Name: "int32", Size: 4}, kinds["Ints"].Key)
+	assert.Equal(t, KindTime, kinds["Created"].Kind)
+	assert.Equal(t, KindBigInt, kinds["Amount"].Elem.Kind)
+	assert.Equal(t, KindInterface, kinds["Shape"].Kind)
+	assert.Equal(t, "bigrat", kinds["Fraction"].Elem.Kind.String())
+
+	// Unexported fields are described only when included
+	s, err = SchemaOf(reflect.TypeOf(walked{}), Unexported(UnexportedInclude))
+	assert.NoError(t, err)
+	assert.Len(t, s.Fields, 20)
+
+	_, err = SchemaOf(reflect.TypeOf(walked{}), Unexported(UnexportedError))
+	assert.Error(t, err)
+
+	_, err = SchemaOf(nil)
+	assert.Equal(t, errNilType, err)
+}
+
+func TestSchemaOf_Recursive(t *testing.T) {
+	s, err := SchemaOf(reflect.TypeOf(linkedList{}))
+	assert.NoError(t, err)
+	assert.Equal(t, KindPointer, s.Fields[1].Schema.Kind)
+	assert.True(t, s == s.Fields[1].Schema.Elem)
+
+	b, err := Marshal(&linkedList{1, &linkedList{2, nil}})
+	assert.NoError(t, err)
+
+	var paths []string
+	assert.NoError(t, Walk(b, s, func(tok Token) error {
+		paths = append(paths, tok.Path)
+		return nil
+	}))
+	assert.Equal(t, []string{".Value", ".Next.Value", ".Next.Next", ".Next", ".Next", ""}, paths)
+}
+
+func TestWalk(t *testing.T) {
+	s, err := SchemaOf(reflect.TypeOf(walked{}))
+	assert.NoError(t, err)
+
+	v := newWalked()
+	for _, opts := range [][]Option{nil, {Versioned()}, {InternStrings()}, {ByteOrder(BigEndian)}} {
+		b, err := Marshal(v, opts...)
+		assert.NoError(t, err)
+
+		tokens := make(map[string]Token)
+		assert.NoError(t, Walk(b, s, func(tok Token) error {
+			tokens[tok.Path] = tok
+			return nil
+		}, opts...))
+
+		// The entire payload is the last value, and every value can be decoded on its own
+		assert.Equal(t, b, tokens[""].Raw)
+		assert.Equal(t, 0, tokens[""].Offset)
+		assert.Equal(t, tokens[".Items[1].Price"].Raw, b[tokens[".Items[1].Price"].Offset:][:8])
+
+		var price float64
+		assert.NoError(t, Unmarshal(tokens[".Items[1].Price"].Raw, &price, opts...))
+		assert.Equal(t, 2.5, price)
+
+		var last int32
+		assert.NoError(t, Unmarshal(tokens[".Last"].Raw, &last, opts...))
+		assert.Equal(t, int32(-7), last)
+		assert.Contains(t, tokens, ".Meta[0].key")
+		assert.Contains(t, tokens, ".Shape.Side")
+	}
+}
+
+func TestWalk_Values(t *testing.T) {
+	for _, opts := range [][]Option{{SelfDescribing()}, {SelfDescribing(), Versioned(), InternStrings()}} {
+		b, err := Marshal(newWalked(), opts...)
+		assert.NoError(t, err)
+
+		// The schema is read from the header and values are decoded during the walk
+		values := make(map[string]interface{})
+		assert.NoError(t, Walk(b, nil, func(tok Token) error {
+			v, err := tok.Value()
+			values[tok.Path] = v
+			return err
+		}, SelfDescribing()))
+
+		assert.Equal(t, "b", values[".Items[1].Name"])
+		assert.Equal(t, "a", values[".Meta[0].key"])
+		assert.Equal(t, int64(-7), values[".Last"])
+		assert.Equal(t, map[string]interface{}{"Version": uint64(2), "Kind": "order"}, values[".Header"])
+
+		expect, err := DecodeAny(b)
+		assert.NoError(t, err)
+		assert.Equal(t, expect, values[""])
+	}
+
+	_, err := Token{}.Value()
+	assert.Error(t, err)
+	assert.Error(t, Walk([]byte{0x0}, nil, nil))
+}
+
+func TestWalk_Errors(t *testing.T) {
+	s, err := SchemaOf(reflect.TypeOf(walked{}))
+	assert.NoError(t, err)
+
+	b, err := Marshal(newWalked())
+	assert.NoError(t, err)
+
+	// Truncated payloads
+	for _, n := range []int{0, 10, 50, len(b) - 1} {
+		assert.Error(t, Walk(b[:n], s, func(Token) error { return nil }))
+	}
+
+	// Errors of the function stop the walk
+	stop := errors.New("stop")
+	assert.Equal(t, stop, Walk(b, s, func(Token) error { return stop }))
+
+	// Values encoded with a custom codec can not be traversed
+	s, err = SchemaOf(reflect.TypeOf(testOpaque("")))
+	assert.NoError(t, err)
+	assert.Equal(t, KindOpaque, s.Kind)
+	assert.Error(t, Walk([]byte{0x0}, s, nil))
+}
+
+func TestDecoderSkip(t *testing.T) {
+	s, err := SchemaOf(reflect.TypeOf(walked{}))
+	assert.NoError(t, err)
+
+	var buffer bytes.Buffer
+	e := NewEncoder(&buffer)
+	assert.NoError(t, e.Encode(newWalked()))
+	assert.NoError(t, e.Encode("next"))
+
+	var out string
+	d := NewDecoder(&buffer)
+	assert.NoError(t, d.Skip(s))
+	assert.NoError(t, d.Decode(&out))
+	assert.Equal(t, "next", out)
+}